@@ -0,0 +1,138 @@
+package mycache
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	pb "github.com/linhx1999/MyCache-Go/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AccessLogConfig 访问日志配置
+type AccessLogConfig struct {
+	SampleRate    float64                // 正常请求的采样比例，0~1，0 表示只记录慢请求和失败的请求
+	SlowThreshold time.Duration          // 耗时超过该值的请求总是记录，0 表示不单独记录慢请求
+	Logger        func(e AccessLogEntry) // 输出日志的函数，nil 时使用标准库 log 输出
+}
+
+// AccessLogEntry 一条访问日志
+// key 只记录哈希值，避免日志中出现敏感的 key
+type AccessLogEntry struct {
+	Method  string        // RPC 方法名，如 "Get"
+	Group   string        // 缓存组，流式请求为空
+	KeyHash uint64        // key 的 xxhash，批量和流式请求为 0
+	Keys    int           // 请求涉及的 key 数量
+	Size    int           // 请求和响应中值的总字节数
+	Latency time.Duration // 处理耗时
+	Code    codes.Code    // gRPC 状态码
+	Slow    bool          // 是否超过慢请求阈值
+}
+
+// WithAccessLog 启用 RPC 访问日志，记录方法、组、key 哈希、大小、耗时和状态码
+// 失败和超过 SlowThreshold 的请求总是记录，其余请求按 SampleRate 采样
+func WithAccessLog(config AccessLogConfig) ServerOption {
+	return func(o *ServerOptions) {
+		o.AccessLog = &config
+	}
+}
+
+// accessLogger 按配置采样并输出访问日志
+type accessLogger struct {
+	config AccessLogConfig
+}
+
+// newAccessLogger 创建访问日志记录器
+func newAccessLogger(config AccessLogConfig) *accessLogger {
+	if config.Logger == nil {
+		config.Logger = defaultAccessLog
+	}
+	return &accessLogger{config: config}
+}
+
+// defaultAccessLog 使用标准库 log 输出访问日志
+func defaultAccessLog(e AccessLogEntry) {
+	slow := ""
+	if e.Slow {
+		slow = " slow"
+	}
+	log.Printf("[Server] access method=%s group=%s key=%016x keys=%d size=%d latency=%v code=%s%s",
+		e.Method, e.Group, e.KeyHash, e.Keys, e.Size, e.Latency, e.Code, slow)
+}
+
+// record 判断是否需要记录并输出日志
+func (l *accessLogger) record(e AccessLogEntry) {
+	e.Slow = l.config.SlowThreshold > 0 && e.Latency >= l.config.SlowThreshold
+	if e.Code == codes.OK && !e.Slow && (l.config.SampleRate <= 0 || rand.Float64() >= l.config.SampleRate) {
+		return
+	}
+	l.config.Logger(e)
+}
+
+// unaryInterceptor 记录一元请求
+func (l *accessLogger) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+
+	e := AccessLogEntry{
+		Method:  info.FullMethod[strings.LastIndex(info.FullMethod, "/")+1:],
+		Latency: time.Since(start),
+		Code:    status.Code(err),
+	}
+	describeRequest(&e, req)
+	// Set 的响应会回传写入的值，与请求重复，不计入
+	if e.Method != "Set" {
+		e.Size += responseSize(resp)
+	}
+	l.record(e)
+	return resp, err
+}
+
+// streamInterceptor 记录流式请求，耗时为整个流的持续时间
+func (l *accessLogger) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	l.record(AccessLogEntry{
+		Method:  info.FullMethod[strings.LastIndex(info.FullMethod, "/")+1:],
+		Latency: time.Since(start),
+		Code:    status.Code(err),
+	})
+	return err
+}
+
+// describeRequest 从请求中提取组、key 哈希、key 数量和值大小
+func describeRequest(e *AccessLogEntry, req interface{}) {
+	switch r := req.(type) {
+	case *pb.Request:
+		e.Group = r.GetGroup()
+		e.KeyHash = xxhash.Sum64String(r.GetKey())
+		e.Keys = 1
+		e.Size = len(r.GetValue())
+	case *pb.BatchRequest:
+		e.Group = r.GetGroup()
+		e.Keys = len(r.GetKeys()) + len(r.GetEntries())
+		for _, entry := range r.GetEntries() {
+			e.Size += len(entry.GetValue())
+		}
+	}
+}
+
+// responseSize 返回响应中值的字节数
+func responseSize(resp interface{}) int {
+	switch r := resp.(type) {
+	case *pb.ResponseForGet:
+		return len(r.GetValue())
+	case *pb.BatchResponse:
+		size := 0
+		for _, entry := range r.GetEntries() {
+			size += len(entry.GetValue())
+		}
+		return size
+	}
+	return 0
+}
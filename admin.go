@@ -0,0 +1,328 @@
+package mycache
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultAdminKeysLimit 列出 key 时默认返回的数量
+const defaultAdminKeysLimit = 100
+
+// defaultAdminHotKeysLimit 列出热点 key 时默认返回的数量
+const defaultAdminHotKeysLimit = 10
+
+// WithAdminAddr 在 addr 上提供运维管理接口，请求需携带 "Authorization: Bearer <token>"
+//
+//	GET  /admin/groups                    列出所有缓存组
+//	GET  /admin/groups/{group}/stats      组、本地存储的统计信息
+//	POST /admin/groups/{group}/purge      立即清理已过期的项
+//	POST /admin/groups/{group}/clear      清空组的本地缓存
+//	GET  /admin/groups/{group}/keys?n=100 列出本地缓存中的前 n 个 key 及其大小和过期时间
+//	GET  /admin/groups/{group}/hotkeys?n=10 本节点访问最多的 n 个 key 及其近似计数，需启用 WithTopKeys
+//	GET  /admin/peers                     所有组使用的节点及其健康状态
+//	POST /admin/rebalance?group=&drop=    协调整个集群再平衡，以 NDJSON 逐行返回各节点的进度
+//	POST /admin/backup?dir=&group=        协调整个集群备份到 dir，以 NDJSON 逐行返回各节点的结果，最后一行为清单路径
+//
+// token 为空时不做认证，只应在受信任的网络中使用。调试接口需另外通过 WithAdminDebug 启用。
+func WithAdminAddr(addr, token string) ServerOption {
+	return func(o *ServerOptions) {
+		o.AdminAddr = addr
+		o.AdminToken = token
+	}
+}
+
+// WithAdminDebug 在管理接口上启用调试接口（默认关闭），同样需要 token
+//
+//	/debug/pprof/   net/http/pprof 的 CPU、堆、goroutine 等 profile
+//	/debug/vars     expvar 导出的运行时信息，包含各组的统计（mycache_groups）
+func WithAdminDebug() ServerOption {
+	return func(o *ServerOptions) {
+		o.AdminDebug = true
+	}
+}
+
+// publishExpvarOnce 保证 expvar 变量只注册一次
+var publishExpvarOnce sync.Once
+
+// registerDebug 注册 pprof 和 expvar 接口
+// expvar 变量是进程级的，同一进程中有多个服务器时只导出第一个启用调试接口的服务器的组
+func (s *Server) registerDebug(mux *http.ServeMux) {
+	publishExpvarOnce.Do(func() {
+		expvar.Publish("mycache_groups", expvar.Func(func() interface{} {
+			stats := make(map[string]map[string]interface{})
+			for _, group := range s.servedGroups() {
+				stats[group.name] = group.Stats()
+			}
+			return stats
+		}))
+	})
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+}
+
+// adminKey 列出 key 时返回的单个 key 的信息
+type adminKey struct {
+	Key      string     `json:"key"`
+	Size     int        `json:"size"`
+	ExpireAt *time.Time `json:"expire_at,omitempty"` // 永不过期时省略
+}
+
+// newAdminMux 创建管理接口的路由
+func (s *Server) newAdminMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/groups", s.adminGroups)
+	mux.HandleFunc("GET /admin/groups/{group}/stats", s.adminStats)
+	mux.HandleFunc("POST /admin/groups/{group}/purge", s.adminPurge)
+	mux.HandleFunc("POST /admin/groups/{group}/clear", s.adminClear)
+	mux.HandleFunc("GET /admin/groups/{group}/keys", s.adminKeys)
+	mux.HandleFunc("GET /admin/groups/{group}/hotkeys", s.adminHotKeys)
+	mux.HandleFunc("GET /admin/peers", s.adminPeers)
+	mux.HandleFunc("POST /admin/rebalance", s.adminRebalance)
+	mux.HandleFunc("POST /admin/backup", s.adminBackup)
+	if s.opts.AdminDebug {
+		s.registerDebug(mux)
+	}
+	return mux
+}
+
+// startAdmin 在后台启动管理接口，未设置 AdminAddr 时不做任何事
+func (s *Server) startAdmin() error {
+	if s.opts.AdminAddr == "" {
+		return nil
+	}
+	if s.opts.AdminToken == "" {
+		log.Printf("[Server] WARN: admin endpoints at %s are not protected by a token", s.opts.AdminAddr)
+	}
+
+	s.adminServer = &http.Server{
+		Addr:              s.opts.AdminAddr,
+		Handler:           s.requireToken(s.newAdminMux()),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	lis, err := s.listen(s.opts.AdminAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen admin: %v", err)
+	}
+
+	go func() {
+		log.Printf("[Server] admin listening at %s", s.opts.AdminAddr)
+		if err := s.adminServer.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("[Server] ERROR: admin server stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// requireToken 校验请求携带的 token，token 未配置时直接放行
+func (s *Server) requireToken(next http.Handler) http.Handler {
+	token := s.opts.AdminToken
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// adminGroups 处理 GET /admin/groups
+func (s *Server) adminGroups(w http.ResponseWriter, r *http.Request) {
+	names := []string{}
+	for _, group := range s.servedGroups() {
+		names = append(names, group.name)
+	}
+	writeJSON(w, names)
+}
+
+// adminStats 处理 GET /admin/groups/{group}/stats
+func (s *Server) adminStats(w http.ResponseWriter, r *http.Request) {
+	if group := s.httpGroup(w, r); group != nil {
+		writeJSON(w, group.Stats())
+	}
+}
+
+// adminPurge 处理 POST /admin/groups/{group}/purge
+func (s *Server) adminPurge(w http.ResponseWriter, r *http.Request) {
+	group := s.httpGroup(w, r)
+	if group == nil {
+		return
+	}
+	purged := group.PurgeExpired()
+	log.Printf("[Server] admin purged %d expired keys from group [%s]", purged, group.name)
+	writeJSON(w, map[string]int{"purged": purged})
+}
+
+// adminClear 处理 POST /admin/groups/{group}/clear
+func (s *Server) adminClear(w http.ResponseWriter, r *http.Request) {
+	group := s.httpGroup(w, r)
+	if group == nil {
+		return
+	}
+	group.Clear()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// adminKeys 处理 GET /admin/groups/{group}/keys，返回本地缓存遍历顺序中的前 n 个 key
+func (s *Server) adminKeys(w http.ResponseWriter, r *http.Request) {
+	group := s.httpGroup(w, r)
+	if group == nil {
+		return
+	}
+
+	limit := defaultAdminKeysLimit
+	if v := r.URL.Query().Get("n"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid n", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	keys := make([]adminKey, 0, limit)
+	group.localCache.Range(func(key string, view ByteView) bool {
+		entry := adminKey{Key: key, Size: view.Len()}
+		if !view.expire.IsZero() {
+			expire := view.expire
+			entry.ExpireAt = &expire
+		}
+		keys = append(keys, entry)
+		return len(keys) < limit
+	})
+	writeJSON(w, keys)
+}
+
+// adminHotKeys 处理 GET /admin/groups/{group}/hotkeys，组未启用热点统计时返回 404
+func (s *Server) adminHotKeys(w http.ResponseWriter, r *http.Request) {
+	group := s.httpGroup(w, r)
+	if group == nil {
+		return
+	}
+	if group.topKeys == nil {
+		http.Error(w, fmt.Sprintf("hot key tracking is not enabled for group %s", group.name), http.StatusNotFound)
+		return
+	}
+
+	limit := defaultAdminHotKeysLimit
+	if v := r.URL.Query().Get("n"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid n", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	writeJSON(w, group.TopKeys(limit))
+}
+
+// adminPeers 处理 GET /admin/peers
+func (s *Server) adminPeers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.groupPeerStatus())
+}
+
+// adminRebalance 处理 POST /admin/rebalance，group 为空时处理所有组，drop=true 时迁移后从原节点删除，
+// local=true 时只在本节点执行。每条进度为一行 JSON（RebalanceProgress），写出后立即刷新
+func (s *Server) adminRebalance(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	group := query.Get("group")
+	if group != "" && s.group(group) == nil {
+		http.Error(w, fmt.Sprintf("group %s not found", group), http.StatusNotFound)
+		return
+	}
+	drop, _ := strconv.ParseBool(query.Get("drop"))
+	local, _ := strconv.ParseBool(query.Get("local"))
+
+	log.Printf("[Server] admin rebalance requested: group=%q drop=%v local=%v", group, drop, local)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	s.rebalance(r.Context(), group, drop, local, func(p RebalanceProgress) error {
+		if err := enc.Encode(p); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+}
+
+// adminBackup 处理 POST /admin/backup，每个备份结果为一行 JSON（BackupFile），
+// 最后一行为 {"manifest": 清单路径}，失败时为 {"error": 错误}
+func (s *Server) adminBackup(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	dir, group := query.Get("dir"), query.Get("group")
+	if _, err := s.resolveBackupDir(dir, ""); err != nil {
+		code := http.StatusBadRequest
+		if errors.Is(err, errBackupDisabled) {
+			code = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	if group != "" && s.group(group) == nil {
+		http.Error(w, fmt.Sprintf("group %s not found", group), http.StatusNotFound)
+		return
+	}
+
+	log.Printf("[Server] admin backup requested: dir=%q group=%q", dir, group)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	manifest, err := s.backup(r.Context(), dir, group, "", false, func(f BackupFile) error {
+		if err := enc.Encode(f); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		enc.Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	enc.Encode(map[string]string{"manifest": manifest})
+}
+
+// groupPeerStatus 汇总已注册的组使用的 ClientPicker 中的节点，按地址排序
+func (s *Server) groupPeerStatus() []PeerStatus {
+	seen := make(map[string]bool)
+	statuses := []PeerStatus{}
+	for _, group := range s.servedGroups() {
+		picker, ok := group.peers.(*ClientPicker)
+		if !ok {
+			continue
+		}
+		for _, status := range picker.PeerStatus() {
+			if !seen[status.Addr] {
+				seen[status.Addr] = true
+				statuses = append(statuses, status)
+			}
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Addr < statuses[j].Addr
+	})
+	return statuses
+}
@@ -0,0 +1,224 @@
+package mycache
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	authorizationMetadataKey = "authorization" // "Bearer <token>"
+	apiKeyMetadataKey        = "x-api-key"     // 直接携带 API key
+
+	// healthServicePrefix 健康检查服务的方法前缀，不做认证，便于负载均衡器探测
+	healthServicePrefix = "/grpc.health.v1.Health/"
+)
+
+var (
+	// ErrUnauthenticated 请求未携带凭证或凭证无效
+	ErrUnauthenticated = errors.New("cache: unauthenticated")
+	// ErrTokenExpired JWT 已过期或尚未生效
+	ErrTokenExpired = errors.New("cache: token expired or not yet valid")
+)
+
+// AuthFunc 校验请求携带的 token，返回的 ctx 会传给后续的处理函数（可附加身份信息）
+// token 取自元数据 "authorization: Bearer <token>" 或 "x-api-key"，未携带时为空字符串
+type AuthFunc func(ctx context.Context, token string) (context.Context, error)
+
+// WithAuth 为所有 RPC 启用认证（健康检查除外），校验失败的请求返回 Unauthenticated
+// 可使用 StaticTokenAuth、JWTAuth 或自定义的 AuthFunc；客户端通过 WithClientToken 携带凭证
+func WithAuth(fn AuthFunc) ServerOption {
+	return func(o *ServerOptions) {
+		o.Auth = fn
+	}
+}
+
+// StaticTokenAuth 返回校验静态 token（或 API key）的 AuthFunc，token 与任意一个匹配即通过
+func StaticTokenAuth(tokens ...string) AuthFunc {
+	return func(ctx context.Context, token string) (context.Context, error) {
+		if token == "" {
+			return ctx, ErrUnauthenticated
+		}
+		for _, t := range tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+				return ctx, nil
+			}
+		}
+		return ctx, ErrUnauthenticated
+	}
+}
+
+// jwtClaimsKey JWT 声明在 context 中的键
+type jwtClaimsKey struct{}
+
+// JWTAuth 返回校验 HS256 签名 JWT 的 AuthFunc，并检查 exp 和 nbf 声明
+// 校验通过后，声明可通过 JWTClaimsFromContext 获取，sub 声明作为请求所属的租户（见 WithTenants）
+func JWTAuth(secret []byte) AuthFunc {
+	return func(ctx context.Context, token string) (context.Context, error) {
+		claims, err := parseJWT(token, secret, time.Now())
+		if err != nil {
+			return ctx, err
+		}
+		ctx = context.WithValue(ctx, jwtClaimsKey{}, claims)
+		if sub, ok := claims["sub"].(string); ok && sub != "" {
+			ctx = WithTenant(ctx, sub)
+		}
+		return ctx, nil
+	}
+}
+
+// JWTClaimsFromContext 返回 JWTAuth 校验通过后附加在 ctx 中的声明
+func JWTClaimsFromContext(ctx context.Context) (map[string]interface{}, bool) {
+	claims, ok := ctx.Value(jwtClaimsKey{}).(map[string]interface{})
+	return claims, ok
+}
+
+// parseJWT 校验 HS256 JWT 的签名和有效期，返回其中的声明
+func parseJWT(token string, secret []byte, now time.Time) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrUnauthenticated
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return nil, ErrUnauthenticated
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrUnauthenticated
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, ErrUnauthenticated
+	}
+
+	var claims map[string]interface{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, ErrUnauthenticated
+	}
+	if exp, ok := claims["exp"].(float64); ok && now.Unix() >= int64(exp) {
+		return nil, ErrTokenExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Unix() < int64(nbf) {
+		return nil, ErrTokenExpired
+	}
+	return claims, nil
+}
+
+// decodeJWTPart 解码 JWT 中 base64url 编码的 JSON 部分
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// requestToken 从请求元数据中取出 token
+func requestToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if v := md.Get(authorizationMetadataKey); len(v) > 0 {
+		if token, ok := strings.CutPrefix(v[0], "Bearer "); ok {
+			return token
+		}
+	}
+	if v := md.Get(apiKeyMetadataKey); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// authenticate 对请求执行认证，失败时返回 Unauthenticated 状态
+func authenticate(ctx context.Context, fn AuthFunc, method string) (context.Context, error) {
+	if strings.HasPrefix(method, healthServicePrefix) {
+		return ctx, nil
+	}
+	ctx, err := fn(ctx, requestToken(ctx))
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return ctx, err
+		}
+		return ctx, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return ctx, nil
+}
+
+// authUnaryInterceptor 返回对一元请求做认证的拦截器
+func authUnaryInterceptor(fn AuthFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, fn, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// authStreamInterceptor 返回对流式请求做认证的拦截器
+func authStreamInterceptor(fn AuthFunc) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), fn, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// TokenSource 返回客户端请求携带的 token，用于定期刷新的 JWT 等场景
+type TokenSource func(ctx context.Context) (string, error)
+
+// WithClientToken 为每个请求携带静态 token（"authorization: Bearer <token>"）
+// 通过 ClientPicker 使用时配合 WithClientOptions，使节点间请求通过对端的认证
+func WithClientToken(token string) ClientOption {
+	return WithClientTokenSource(func(context.Context) (string, error) {
+		return token, nil
+	})
+}
+
+// WithClientTokenSource 每个请求前调用 source 获取 token，适用于会过期、需要刷新的凭证
+func WithClientTokenSource(source TokenSource) ClientOption {
+	return func(o *clientOptions) {
+		o.tokenSource = source
+	}
+}
+
+// tokenCredentials 将 token 作为 per-RPC 凭证附加到请求
+type tokenCredentials struct {
+	source TokenSource
+}
+
+var _ credentials.PerRPCCredentials = tokenCredentials{}
+
+func (c tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token, err := c.source(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{authorizationMetadataKey: "Bearer " + token}, nil
+}
+
+// RequireTransportSecurity 节点间默认使用明文连接，不强制要求 TLS
+func (c tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
@@ -0,0 +1,466 @@
+package mycache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linhx1999/MyCache-Go/consistenthash"
+	pb "github.com/linhx1999/MyCache-Go/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// backupManifestName 备份清单的文件名，位于本次备份的目录下
+	backupManifestName = "manifest.json"
+	// backupIDLayout 未指定备份 ID 时按 UTC 时间生成
+	backupIDLayout = "20060102T150405Z"
+)
+
+// BackupFile 一个节点上一个组的备份文件，格式与快照文件相同（见 WithSnapshot）
+type BackupFile struct {
+	Node    string `json:"node"`
+	Group   string `json:"group"`
+	Path    string `json:"path,omitempty"` // 相对于本次备份目录的路径，以 "/" 分隔
+	Entries int    `json:"entries"`
+	Bytes   int64  `json:"bytes"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BackupManifest 一次集群备份的清单，由协调者在所有节点完成后写入
+type BackupManifest struct {
+	ID          string          `json:"id"`
+	CreatedAt   time.Time       `json:"created_at"`
+	Coordinator string          `json:"coordinator"`
+	Ring        json.RawMessage `json:"ring,omitempty"` // 协调者备份时的哈希环，恢复时据此判断 key 的归属
+	Files       []BackupFile    `json:"files"`
+}
+
+// WithRestore 创建组时从集群备份恢复本节点负责的缓存项，dir 为包含 manifest.json 的备份目录
+//
+// 整个集群维护后重建时，各节点以相同的备份目录启动即可恢复到备份时的状态，而不必全部回源。
+// 节点按备份时的哈希环判断 key 的归属，本节点不在其中（如地址已变化）时按当前的哈希环判断，
+// 没有使用 ClientPicker 时恢复全部缓存项。在快照（WithSnapshot）之前恢复，快照中较新的值会覆盖备份。
+func WithRestore(dir string) GroupOption {
+	return func(g *Group) {
+		g.restoreDir = dir
+	}
+}
+
+var (
+	// errBackupDisabled 节点没有配置备份根目录
+	errBackupDisabled = errors.New("cache: backup is disabled on this node, see WithBackupRoot")
+	// errInvalidBackupPath 请求中的备份目录或 ID 指向备份根目录之外
+	errInvalidBackupPath = errors.New("cache: invalid backup path")
+)
+
+// WithBackupRoot 设置集群备份写入的根目录，未设置时拒绝 Backup 请求
+//
+// Backup 请求中的目录按相对于 root 的路径解析（绝对路径必须位于 root 之下），不能通过 ".." 写到 root 之外。
+// 集群备份时各节点的 root 应指向同一个共享存储。
+func WithBackupRoot(root string) ServerOption {
+	return func(o *ServerOptions) {
+		o.BackupRoot = root
+	}
+}
+
+// resolveBackupDir 返回备份 id 在本节点上的目录，dir 和 id 来自请求，不能指向备份根目录之外
+func (s *Server) resolveBackupDir(dir, id string) (string, error) {
+	root := s.opts.BackupRoot
+	if root == "" {
+		return "", errBackupDisabled
+	}
+	if id == "." || strings.Contains(id, "..") || strings.ContainsAny(id, `/\`) {
+		return "", fmt.Errorf("%w: id %q must not contain path separators or \"..\"", errInvalidBackupPath, id)
+	}
+
+	rel := dir
+	if filepath.IsAbs(dir) {
+		var err error
+		if rel, err = filepath.Rel(root, dir); err != nil {
+			rel = dir
+		}
+	}
+	if rel == "" {
+		rel = "."
+	}
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%w: dir %q is outside the backup root", errInvalidBackupPath, dir)
+	}
+	return filepath.Join(root, rel, id), nil
+}
+
+// ReadBackupManifest 读取备份目录中的清单
+func ReadBackupManifest(dir string) (*BackupManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, backupManifestName))
+	if err != nil {
+		return nil, fmt.Errorf("cache: failed to read backup manifest: %w", err)
+	}
+	var manifest BackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("cache: invalid backup manifest %s: %w", dir, err)
+	}
+	return &manifest, nil
+}
+
+// RestoreBackup 从集群备份恢复本节点负责的缓存项，返回恢复的数量
+// 已过期、超出内存配额、无法解密或不属于本节点的缓存项被跳过，不会触发事件，也不会同步到其他节点
+func (g *Group) RestoreBackup(dir string) (int, error) {
+	manifest, err := ReadBackupManifest(dir)
+	if err != nil {
+		return 0, err
+	}
+	owns, release := g.backupOwnership(manifest.Ring)
+	defer release()
+
+	now := time.Now()
+	restored := 0
+	var errs []error
+	for _, file := range manifest.Files {
+		if file.Group != g.name || file.Error != "" || file.Path == "" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(file.Path)))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		err = readSnapshot(data, g.cipher, func(key string, view ByteView) {
+			if owns(key) && g.restoreEntry(key, view, now) {
+				restored++
+			}
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file.Path, err))
+		}
+	}
+
+	g.stats.backupRestores.Add(int64(restored))
+	if len(errs) > 0 {
+		return restored, fmt.Errorf("cache: failed to restore backup %s: %w", dir, errors.Join(errs...))
+	}
+	return restored, nil
+}
+
+// startRestore 创建组时从备份恢复，未设置 WithRestore 时不做任何事
+func (g *Group) startRestore() {
+	if g.restoreDir == "" {
+		return
+	}
+	start := time.Now()
+	n, err := g.RestoreBackup(g.restoreDir)
+	if err != nil {
+		log.Printf("[MyCache] %v", err)
+	}
+	if n > 0 {
+		log.Printf("[MyCache] restored %d entries for group [%s] from backup in %v", n, g.name, time.Since(start))
+	}
+}
+
+// backupOwnership 返回判断本节点是否负责 key 的函数，以及释放资源的函数
+func (g *Group) backupOwnership(ringState []byte) (owns func(key string) bool, release func()) {
+	picker, ok := g.peers.(*ClientPicker)
+	if !ok || picker.selfAddr == "" {
+		return func(string) bool { return true }, func() {}
+	}
+
+	if len(ringState) > 0 {
+		ring := consistenthash.New(picker.ringOpts...)
+		err := ring.Unmarshal(ringState)
+		if err == nil {
+			if _, ok := ring.Replicas()[picker.selfAddr]; ok {
+				// GetN 不计入负载统计，不会触发恢复用的哈希环自行调整
+				return func(key string) bool {
+					owners := ring.GetN(key, 1)
+					return len(owners) > 0 && owners[0] == picker.selfAddr
+				}, func() { ring.Close() }
+			}
+		} else {
+			log.Printf("[MyCache] WARN: ignoring ring in backup manifest: %v", err)
+		}
+		ring.Close()
+	}
+
+	return func(key string) bool {
+		_, ok, isSelf := picker.PickPeer(key)
+		return !ok || isSelf
+	}, func() {}
+}
+
+// backupTo 将本节点负责的未过期缓存项写入 path，返回写入的数量
+func (g *Group) backupTo(path string) (int, error) {
+	if !g.cipher.usable() {
+		return 0, errors.New("cache: encryption key unavailable")
+	}
+
+	var entries []snapshotEntry
+	now := time.Now()
+	g.localCache.Range(func(key string, view ByteView) bool {
+		if !view.expire.IsZero() && !view.expire.After(now) {
+			return true
+		}
+		if g.peers != nil {
+			// 副本等不属于本节点的 key 由其 owner 备份
+			if _, ok, isSelf := g.peers.PickPeer(key); ok && !isSelf {
+				return true
+			}
+		}
+		entries = append(entries, snapshotEntry{key: key, view: view})
+		return true
+	})
+
+	if err := writeSnapshot(path, entries, g.cipher); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// Backup 实现 Cache 服务的 Backup 方法，以流的形式返回各节点各组的备份结果
+func (s *Server) Backup(req *pb.BackupRequest, stream grpc.ServerStreamingServer[pb.BackupProgress]) error {
+	if _, err := s.resolveBackupDir(req.Dir, req.Id); err != nil {
+		if errors.Is(err, errBackupDisabled) {
+			return status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	manifest, err := s.backup(stream.Context(), req.Dir, req.Group, req.Id, req.Local, func(f BackupFile) error {
+		return stream.Send(backupFileToPB(f))
+	})
+	if err != nil {
+		return err
+	}
+	if manifest != "" {
+		return stream.Send(&pb.BackupProgress{Manifest: manifest})
+	}
+	return nil
+}
+
+// backup 将本节点负责的缓存项备份到备份根目录下的 dir/id 中，local 为 false 时同时让所有已发现的节点备份，
+// 全部完成后写入清单并返回其路径。send 串行调用，单个节点或组失败记录在结果中，不影响其他节点
+//
+// dir 由各节点相对于自己的备份根目录解析（见 WithBackupRoot），集群备份时各节点的根目录应为同一个共享存储
+// （如 NFS 或对象存储的挂载点）。
+func (s *Server) backup(ctx context.Context, dir, group, id string, local bool, send func(BackupFile) error) (string, error) {
+	groups := s.servedGroups()
+	if group != "" {
+		g := s.group(group)
+		if g == nil {
+			return "", errGroupNotFound(group)
+		}
+		groups = []*Group{g}
+	}
+	if id == "" {
+		id = time.Now().UTC().Format(backupIDLayout)
+	}
+	backupDir, err := s.resolveBackupDir(dir, id)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		files   []BackupFile
+		sendErr error
+	)
+	report := func(f BackupFile) {
+		mu.Lock()
+		defer mu.Unlock()
+		files = append(files, f)
+		if sendErr != nil {
+			return
+		}
+		if sendErr = send(f); sendErr != nil {
+			cancel()
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		node := s.AdvertiseAddr()
+		for _, g := range groups {
+			if ctx.Err() != nil {
+				return
+			}
+			report(s.backupGroup(g, backupDir, node))
+		}
+	}()
+
+	if !local {
+		for _, client := range clusterPeers(groups) {
+			wg.Add(1)
+			go func(client *Client) {
+				defer wg.Done()
+				err := client.backup(ctx, &pb.BackupRequest{Dir: dir, Group: group, Id: id, Local: true}, report)
+				if err != nil && ctx.Err() == nil {
+					report(BackupFile{Node: client.addr, Error: err.Error()})
+				}
+			}(client)
+		}
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if sendErr != nil {
+		return "", sendErr
+	}
+	if local {
+		return "", nil
+	}
+
+	manifest := BackupManifest{
+		ID:          id,
+		CreatedAt:   time.Now(),
+		Coordinator: s.AdvertiseAddr(),
+		Ring:        backupRing(groups),
+		Files:       files,
+	}
+	manifestPath := filepath.Join(backupDir, backupManifestName)
+	if err := writeBackupManifest(manifestPath, &manifest); err != nil {
+		return "", fmt.Errorf("cache: failed to write backup manifest: %w", err)
+	}
+	log.Printf("[Server] backup %s written to %s (%d files)", id, backupDir, len(files))
+	return manifestPath, nil
+}
+
+// backupGroup 备份本节点的一个组，文件为 {node}/{group}.snapshot
+func (s *Server) backupGroup(g *Group, backupDir, node string) BackupFile {
+	rel := path.Join(url.PathEscape(node), url.PathEscape(g.name)+".snapshot")
+	file := BackupFile{Node: node, Group: g.name, Path: rel}
+
+	full := filepath.Join(backupDir, filepath.FromSlash(rel))
+	n, err := g.backupTo(full)
+	if err != nil {
+		file.Error = err.Error()
+		return file
+	}
+	file.Entries = n
+	if info, err := os.Stat(full); err == nil {
+		file.Bytes = info.Size()
+	}
+	return file
+}
+
+// backupRing 返回第一个使用 ClientPicker 的组的哈希环状态，都没有时返回 nil
+func backupRing(groups []*Group) json.RawMessage {
+	for _, g := range groups {
+		picker, ok := g.peers.(*ClientPicker)
+		if !ok {
+			continue
+		}
+		picker.mu.RLock()
+		state, err := picker.consHash.Marshal()
+		picker.mu.RUnlock()
+		if err == nil {
+			return state
+		}
+	}
+	return nil
+}
+
+// writeBackupManifest 写入清单，先写临时文件再原子替换
+func writeBackupManifest(path string, manifest *BackupManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Backup 让该节点协调整个集群的备份，fn 依次收到各节点各组的结果，返回清单文件的路径
+//
+// 各节点把自己负责的缓存项写入备份根目录（见 WithBackupRoot）下 dir 中以时间命名的子目录，
+// dir 为空时直接写入根目录，各节点的根目录应为同一个共享存储。
+// 单个节点或组失败时记录在清单和 BackupFile.Error 中，不影响其他节点；恢复见 WithRestore。
+func (c *Client) Backup(ctx context.Context, dir, group string, fn func(BackupFile)) (string, error) {
+	var manifest string
+	err := c.backupStream(ctx, &pb.BackupRequest{Dir: dir, Group: group}, func(p *pb.BackupProgress) {
+		if p.GetManifest() != "" {
+			manifest = p.GetManifest()
+			return
+		}
+		if fn != nil {
+			fn(backupFileFromPB(p))
+		}
+	})
+	return manifest, err
+}
+
+// backup 调用 Backup RPC 并对收到的每个备份结果调用 fn
+func (c *Client) backup(ctx context.Context, req *pb.BackupRequest, fn func(BackupFile)) error {
+	return c.backupStream(ctx, req, func(p *pb.BackupProgress) {
+		if p.GetManifest() == "" {
+			fn(backupFileFromPB(p))
+		}
+	})
+}
+
+// backupStream 调用 Backup RPC，备份可能持续较长时间，不使用请求超时，也不重试
+func (c *Client) backupStream(ctx context.Context, req *pb.BackupRequest, fn func(*pb.BackupProgress)) error {
+	if !c.Supports(FeatureBackup) {
+		return fmt.Errorf("cache: peer %s does not support backup", c.addr)
+	}
+
+	stream, err := c.pick().Backup(ctx, req)
+	if err != nil {
+		return fromStatusError(err)
+	}
+	for {
+		p, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fromStatusError(err)
+		}
+		fn(p)
+	}
+}
+
+// backupFileToPB 将备份结果转换为 RPC 消息
+func backupFileToPB(f BackupFile) *pb.BackupProgress {
+	return &pb.BackupProgress{
+		Node:    f.Node,
+		Group:   f.Group,
+		Path:    f.Path,
+		Entries: int64(f.Entries),
+		Bytes:   f.Bytes,
+		Error:   f.Error,
+	}
+}
+
+// backupFileFromPB 将 RPC 消息转换为备份结果
+func backupFileFromPB(p *pb.BackupProgress) BackupFile {
+	return BackupFile{
+		Node:    p.GetNode(),
+		Group:   p.GetGroup(),
+		Path:    p.GetPath(),
+		Entries: int(p.GetEntries()),
+		Bytes:   p.GetBytes(),
+		Error:   p.GetError(),
+	}
+}
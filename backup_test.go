@@ -0,0 +1,196 @@
+package mycache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/linhx1999/MyCache-Go/consistenthash"
+	pb "github.com/linhx1999/MyCache-Go/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestServer_ResolveBackupDir(t *testing.T) {
+	root := t.TempDir()
+	s := &Server{opts: &ServerOptions{BackupRoot: root}}
+
+	tests := []struct {
+		dir, id string
+		want    string
+		err     error
+	}{
+		{"", "b1", filepath.Join(root, "b1"), nil},
+		{"daily", "b1", filepath.Join(root, "daily", "b1"), nil},
+		{filepath.Join(root, "daily"), "b1", filepath.Join(root, "daily", "b1"), nil},
+		{root, "b1", filepath.Join(root, "b1"), nil},
+		{"../etc", "b1", "", errInvalidBackupPath},
+		{"daily/../../etc", "b1", "", errInvalidBackupPath},
+		{"/etc", "b1", "", errInvalidBackupPath},
+		{"daily", "../b1", "", errInvalidBackupPath},
+		{"daily", "a/b", "", errInvalidBackupPath},
+		{"daily", `a\b`, "", errInvalidBackupPath},
+		{"daily", "..", "", errInvalidBackupPath},
+		{"daily", ".", "", errInvalidBackupPath},
+	}
+	for _, tt := range tests {
+		got, err := s.resolveBackupDir(tt.dir, tt.id)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("resolveBackupDir(%q, %q) 错误应为 %v，实际为 %v", tt.dir, tt.id, tt.err, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("resolveBackupDir(%q, %q) 应为 %q，实际为 %q", tt.dir, tt.id, tt.want, got)
+		}
+	}
+
+	s.opts.BackupRoot = ""
+	if _, err := s.resolveBackupDir("daily", "b1"); !errors.Is(err, errBackupDisabled) {
+		t.Errorf("未设置备份根目录时应拒绝备份，实际为 %v", err)
+	}
+}
+
+// startBackupServer 启动提供 g 的服务器并返回连接它的客户端
+func startBackupServer(t *testing.T, g *Group, opts ...ServerOption) *Client {
+	t.Helper()
+	addr := freeAddr(t)
+	srv, err := NewServer(addr, "backup-test", append([]ServerOption{WithoutRegistry()}, opts...)...)
+	if err != nil {
+		t.Fatalf("创建服务器失败: %v", err)
+	}
+	srv.RegisterGroup(g)
+	go srv.Start()
+	t.Cleanup(srv.Stop)
+
+	client, err := DialNode(addr, WithWaitForReady(true))
+	if err != nil {
+		t.Fatalf("DialNode 失败: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestBackup_RequiresRoot(t *testing.T) {
+	g := NewGroup("backup-noroot", 1<<20, DataSourceFunc(func(ctx context.Context, key string) ([]byte, error) {
+		return nil, errors.New("not found")
+	}))
+	defer g.Close()
+	client := startBackupServer(t, g)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := client.Backup(ctx, t.TempDir(), "", func(BackupFile) {})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("未设置备份根目录时应返回 FailedPrecondition，实际为 %v", err)
+	}
+}
+
+func TestBackup_RejectsPathOutsideRoot(t *testing.T) {
+	g := NewGroup("backup-escape", 1<<20, DataSourceFunc(func(ctx context.Context, key string) ([]byte, error) {
+		return nil, errors.New("not found")
+	}))
+	defer g.Close()
+	g.Set(context.Background(), "k", []byte("v"))
+
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	client := startBackupServer(t, g, WithBackupRoot(root))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	for _, req := range []struct{ dir, id string }{
+		{"../outside", "b1"},
+		{base, "b1"},
+		{"", "../../outside"},
+	} {
+		err := client.backup(ctx, &pb.BackupRequest{Dir: req.dir, Id: req.id}, func(BackupFile) {})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("dir=%q id=%q 应返回 InvalidArgument，实际为 %v", req.dir, req.id, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(base, "outside")); !os.IsNotExist(err) {
+		t.Errorf("不应在备份根目录之外写入文件")
+	}
+
+	manifest, err := client.Backup(ctx, "daily", "", func(f BackupFile) {
+		if f.Error != "" {
+			t.Errorf("备份 %s 失败: %s", f.Group, f.Error)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Backup 失败: %v", err)
+	}
+	if rel, err := filepath.Rel(filepath.Join(root, "daily"), manifest); err != nil || !filepath.IsLocal(rel) {
+		t.Errorf("清单应写入备份根目录下，实际为 %s", manifest)
+	}
+}
+
+func TestRestoreBackup_SkipsKeysNotOwned(t *testing.T) {
+	const self, peer = "127.0.0.1:1", "127.0.0.1:2"
+
+	// 备份时的哈希环只有本节点和 peer
+	ring := consistenthash.New()
+	ring.Add(self)
+	ring.Add(peer)
+	defer ring.Close()
+	state, err := ring.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal 失败: %v", err)
+	}
+
+	dir := t.TempDir()
+	var entries []snapshotEntry
+	owned := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		entries = append(entries, snapshotEntry{key: key, view: ByteView{b: []byte(key)}})
+		owned[key] = ring.GetN(key, 1)[0] == self
+	}
+	if err := writeSnapshot(filepath.Join(dir, "node", "backup-owned.snapshot"), entries, nil); err != nil {
+		t.Fatalf("writeSnapshot 失败: %v", err)
+	}
+	err = writeBackupManifest(filepath.Join(dir, backupManifestName), &BackupManifest{
+		ID:    "b1",
+		Ring:  state,
+		Files: []BackupFile{{Node: "node", Group: "backup-owned", Path: "node/backup-owned.snapshot", Entries: len(entries)}},
+	})
+	if err != nil {
+		t.Fatalf("写入清单失败: %v", err)
+	}
+
+	// 当前又多了一个节点，恢复时仍按备份时的哈希环判断归属
+	picker, err := NewStaticPicker(self, []string{peer, "127.0.0.1:3"})
+	if err != nil {
+		t.Fatalf("NewStaticPicker 失败: %v", err)
+	}
+	defer picker.Close()
+	g := NewGroup("backup-owned", 1<<20, DataSourceFunc(func(ctx context.Context, key string) ([]byte, error) {
+		return nil, errors.New("not found")
+	}), WithPeers(picker))
+	defer g.Close()
+
+	n, err := g.RestoreBackup(dir)
+	if err != nil {
+		t.Fatalf("RestoreBackup 失败: %v", err)
+	}
+	want := 0
+	for key, mine := range owned {
+		if mine {
+			want++
+		}
+		_, ok := g.localCache.Get(context.Background(), key)
+		if ok != mine {
+			t.Errorf("%s 归属本节点为 %v，恢复结果为 %v", key, mine, ok)
+		}
+	}
+	if want == 0 || want == len(entries) {
+		t.Fatalf("测试数据应有一部分 key 不属于本节点，实际本节点负责 %d 个", want)
+	}
+	if n != want {
+		t.Errorf("应恢复本节点负责的 %d 个缓存项，实际为 %d 个", want, n)
+	}
+}
@@ -0,0 +1,231 @@
+package mycache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// GetMulti 批量获取多个 key
+//
+// 先查本地缓存，未命中的 key 按 owner 节点分组，每个节点只发起一次 MGet 请求；
+// owner 为本节点、节点不可达或节点未返回的 key 在本节点加载。
+// 部分 key 失败时返回已获取的结果以及包含各 key 错误的汇总错误。
+func (g *Group) GetMulti(ctx context.Context, keys []string) (map[string]ByteView, error) {
+	values, failed := g.getMulti(ctx, keys)
+	if len(failed) == 0 {
+		return values, nil
+	}
+
+	errs := make([]error, 0, len(failed))
+	for _, key := range keys {
+		if err, ok := failed[key]; ok {
+			errs = append(errs, fmt.Errorf("key %s: %w", key, err))
+			delete(failed, key) // 重复的 key 只记录一次
+		}
+	}
+	return values, errors.Join(errs...)
+}
+
+// getMulti 批量获取多个 key，分别返回成功的结果和各 key 的错误
+func (g *Group) getMulti(ctx context.Context, keys []string) (map[string]ByteView, map[string]error) {
+	values := make(map[string]ByteView, len(keys))
+	failed := make(map[string]error)
+
+	if g.closed.Load() == 1 {
+		for _, key := range keys {
+			failed[key] = ErrGroupClosed
+		}
+		return values, failed
+	}
+	if err := ctx.Err(); err != nil {
+		for _, key := range keys {
+			failed[key] = err
+		}
+		return values, failed
+	}
+
+	// 从本地缓存获取
+	var missing []string
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key == "" {
+			failed[key] = ErrKeyRequired
+			continue
+		}
+		if err := g.policy.checkKey(ctx, key); err != nil {
+			failed[key] = err
+			continue
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		g.recordAccess(key)
+		if view, ok := g.localCache.Get(ctx, key); ok {
+			g.stats.localHits.Add(1)
+			values[key] = view
+			continue
+		}
+		g.stats.localMisses.Add(1)
+		missing = append(missing, key)
+	}
+	if len(missing) == 0 {
+		return values, failed
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	loadLocal := func(key string) {
+		defer wg.Done()
+		result, err := g.load(ctx, key, g.singleFlightLoader, func(ctx context.Context) (interface{}, error) {
+			return g.loadFromDataSource(ctx, key)
+		})
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			failed[key] = err
+			return
+		}
+		values[key] = result.view
+	}
+
+	// 其他节点转发过来的请求直接在本节点加载
+	if g.peers == nil || ctx.Value("from_peer") != nil {
+		for _, key := range missing {
+			wg.Add(1)
+			go loadLocal(key)
+		}
+		wg.Wait()
+		return values, failed
+	}
+
+	// 按 owner 节点分组
+	var local []string
+	byPeer := make(map[Peer][]string)
+	for _, key := range missing {
+		peer, ok, isSelf := g.peers.PickPeer(key)
+		if !ok || isSelf {
+			local = append(local, key)
+			continue
+		}
+		byPeer[peer] = append(byPeer[peer], key)
+	}
+
+	for peer, peerKeys := range byPeer {
+		wg.Add(1)
+		go func(peer Peer, peerKeys []string) {
+			defer wg.Done()
+			got, err := peer.MGet(ctx, g.name, peerKeys)
+			if err != nil {
+				log.Printf("[MyCache] failed to mget from peer: %v", err)
+			}
+
+			for _, key := range peerKeys {
+				bytes, ok := got[key]
+				if ok {
+					g.stats.peerHits.Add(1)
+					view := ByteView{b: bytes}
+					if g.checkQuota(key, view.Len()) == nil {
+						view = g.saveToLocal(key, view)
+					}
+					mu.Lock()
+					values[key] = view
+					mu.Unlock()
+					continue
+				}
+
+				g.stats.peerMisses.Add(1)
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					// 调用方已经超时或取消，不再回源
+					mu.Lock()
+					failed[key] = ctxErr
+					mu.Unlock()
+					continue
+				}
+				if g.ownerOnlyLoad && err == nil {
+					// owner 已经查询过数据源，不再重复回源
+					g.stats.ownerErrors.Add(1)
+					mu.Lock()
+					failed[key] = fmt.Errorf("cache: owner failed to get key %s", key)
+					mu.Unlock()
+					continue
+				}
+				wg.Add(1)
+				go loadLocal(key)
+			}
+		}(peer, peerKeys)
+	}
+
+	for _, key := range local {
+		wg.Add(1)
+		go loadLocal(key)
+	}
+	wg.Wait()
+
+	return values, failed
+}
+
+// DeleteMulti 批量删除多个 key
+// 不是从其他节点同步过来的请求会按 owner 节点分组，每个节点只发起一次 MDelete 请求
+func (g *Group) DeleteMulti(ctx context.Context, keys []string) error {
+	if g.closed.Load() == 1 {
+		return ErrGroupClosed
+	}
+
+	for _, key := range keys {
+		if key == "" {
+			return ErrKeyRequired
+		}
+	}
+
+	origin := originFromContext(ctx)
+	for _, key := range keys {
+		g.localCache.Delete(key)
+		g.forgetLoads(key)
+		g.publish(EventDelete, key, ByteView{}, origin)
+	}
+
+	isPeerRequest := ctx.Value("from_peer") != nil
+	if !isPeerRequest && g.peers != nil {
+		if g.syncer == nil {
+			go g.syncDeletesToPeers(keys)
+			return nil
+		}
+		for _, key := range keys {
+			g.syncer.add("delete", key, nil, time.Time{})
+		}
+	}
+
+	return nil
+}
+
+// syncDeletesToPeers 将批量删除按 owner 节点分组同步到其他节点
+func (g *Group) syncDeletesToPeers(keys []string) {
+	byPeer := make(map[Peer][]string)
+	for _, key := range keys {
+		peer, ok, isSelf := g.peers.PickPeer(key)
+		if !ok || isSelf {
+			continue
+		}
+		byPeer[peer] = append(byPeer[peer], key)
+	}
+
+	syncCtx := context.WithValue(context.Background(), "from_peer", true)
+	for peer, peerKeys := range byPeer {
+		if err := peer.MDelete(syncCtx, g.name, peerKeys); err != nil {
+			for _, key := range peerKeys {
+				g.addHint("delete", key, nil, time.Time{})
+			}
+			log.Printf("[MyCache] failed to sync mdelete to peer: %v", err)
+			continue
+		}
+		for _, key := range peerKeys {
+			g.clearHint(key)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package mycache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startBatchServer 启动提供 g 的服务器并返回连接它的客户端
+func startBatchServer(t *testing.T, g *Group) *Client {
+	t.Helper()
+	addr := freeAddr(t)
+	srv, err := NewServer(addr, "batch-test", WithoutRegistry())
+	if err != nil {
+		t.Fatalf("创建服务器失败: %v", err)
+	}
+	srv.RegisterGroup(g)
+	go srv.Start()
+	t.Cleanup(srv.Stop)
+
+	client, err := DialNode(addr, WithWaitForReady(true))
+	if err != nil {
+		t.Fatalf("DialNode 失败: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func newBatchGroup(name string) *Group {
+	return NewGroup(name, 1<<20, DataSourceFunc(func(ctx context.Context, key string) ([]byte, error) {
+		return nil, errors.New("not found")
+	}))
+}
+
+func TestBatch_MSetPartialErrors(t *testing.T) {
+	g := newBatchGroup("batch-mset")
+	defer g.Close()
+	client := startBatchServer(t, g)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := client.MSet(ctx, g.name, map[string][]byte{
+		"a":     []byte("1"),
+		"empty": nil,
+		"b":     []byte("2"),
+	})
+	if err == nil {
+		t.Fatal("部分 key 设置失败时应返回错误")
+	}
+	// 汇总错误只包含失败的 key，其余 key 照常写入
+	if msg := err.Error(); !strings.Contains(msg, "key empty") || strings.Contains(msg, "key a") || strings.Contains(msg, "key b") {
+		t.Errorf("错误应只包含失败的 key empty，实际为 %v", err)
+	}
+	for key, want := range map[string]string{"a": "1", "b": "2"} {
+		if view, ok := g.localCache.Get(ctx, key); !ok || view.String() != want {
+			t.Errorf("%s 应写入 %s，实际为 %q（found=%v）", key, want, view.String(), ok)
+		}
+	}
+	if _, ok := g.localCache.Get(ctx, "empty"); ok {
+		t.Error("设置失败的 key 不应写入")
+	}
+}
+
+func TestBatch_MDeleteRejectsWholeBatch(t *testing.T) {
+	g := newBatchGroup("batch-mdelete")
+	defer g.Close()
+	client := startBatchServer(t, g)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	g.Set(ctx, "a", []byte("1"))
+
+	// 批量删除先校验全部 key，有一个无效时整批都不删除
+	if err := client.MDelete(ctx, g.name, []string{"a", ""}); err == nil {
+		t.Fatal("包含空 key 时应返回错误")
+	}
+	if _, ok := g.localCache.Get(ctx, "a"); !ok {
+		t.Error("整批被拒绝时不应删除 a")
+	}
+
+	if err := client.MDelete(ctx, g.name, []string{"a", "missing"}); err != nil {
+		t.Fatalf("MDelete 失败: %v", err)
+	}
+	if _, ok := g.localCache.Get(ctx, "a"); ok {
+		t.Error("MDelete 后不应读到 a")
+	}
+}
+
+func TestBatch_MDeleteEachPartialErrors(t *testing.T) {
+	g := newBatchGroup("batch-mdelete-each")
+	defer g.Close()
+	client := startBatchServer(t, g)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	g.Set(ctx, "a", []byte("1"))
+	g.Set(ctx, "b", []byte("2"))
+	// 对端不支持批量请求时逐个删除，失败的 key 不影响其余 key
+	client.proto.Store(&peerProtocol{version: ProtocolVersion, features: map[string]bool{}})
+
+	err := client.MDelete(ctx, g.name, []string{"a", "", "b"})
+	if err == nil {
+		t.Fatal("部分 key 删除失败时应返回错误")
+	}
+	if msg := err.Error(); strings.Contains(msg, "key a") || strings.Contains(msg, "key b") {
+		t.Errorf("错误应只包含失败的 key，实际为 %v", err)
+	}
+	for _, key := range []string{"a", "b"} {
+		if _, ok := g.localCache.Get(ctx, key); ok {
+			t.Errorf("%s 应已删除", key)
+		}
+	}
+}
@@ -1,24 +1,98 @@
 package mycache
 
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/linhx1999/MyCache-Go/store"
+)
+
 // ByteView 只读的字节视图，用于缓存数据
 type ByteView struct {
-	b []byte
+	b       []byte
+	expire  time.Time // 过期时间，零值表示永不过期（由 Cache 写入时记录）
+	written time.Time // 写入本地缓存的时间
 }
 
+var _ io.WriterTo = ByteView{}
+
 func (b ByteView) Len() int {
 	return len(b.b)
 }
 
-func (b ByteView) ByteSLice() []byte {
+// ByteSlice 返回数据的一份拷贝，调用方可以任意修改
+func (b ByteView) ByteSlice() []byte {
 	return cloneBytes(b.b)
 }
 
+// ByteSLice 返回数据的一份拷贝
+//
+// Deprecated: 方法名存在拼写错误，请使用 ByteSlice
+func (b ByteView) ByteSLice() []byte {
+	return b.ByteSlice()
+}
+
 func (b ByteView) String() string {
 	return string(b.b)
 }
 
+// At 返回下标 i 处的字节，越界时 panic
+func (b ByteView) At(i int) byte {
+	return b.b[i]
+}
+
+// Slice 返回 [from, to) 范围的子视图，与原视图共享底层数据，不发生拷贝
+func (b ByteView) Slice(from, to int) ByteView {
+	return ByteView{b: b.b[from:to], expire: b.expire, written: b.written}
+}
+
+// Reader 返回读取视图数据的 io.Reader，不发生拷贝
+// 适合将大体积缓存值以流的方式写入 HTTP 响应等场景
+func (b ByteView) Reader() io.Reader {
+	return bytes.NewReader(b.b)
+}
+
+// WriteTo 将视图数据直接写入 w，实现 io.WriterTo 接口，不发生拷贝
+func (b ByteView) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(b.b)
+	return int64(n), err
+}
+
 func cloneBytes(b []byte) []byte {
 	c := make([]byte, len(b))
 	copy(c, b)
 	return c
 }
+
+// byteViewCodec 磁盘层和 arena 存储中 ByteView 与字节之间的编解码，解码时直接引用数据，不发生拷贝
+// 格式为：过期时间(varint) | 写入时间(varint) | 数据，时间为 Unix 纳秒，0 表示未设置
+type byteViewCodec struct{}
+
+func (byteViewCodec) Encode(value store.Value) ([]byte, time.Time, error) {
+	view, ok := value.(ByteView)
+	if !ok {
+		return nil, time.Time{}, fmt.Errorf("cache: unexpected value type %T", value)
+	}
+	data := make([]byte, 0, 2*binary.MaxVarintLen64+len(view.b))
+	data = binary.AppendVarint(data, unixNano(view.expire))
+	data = binary.AppendVarint(data, unixNano(view.written))
+	data = append(data, view.b...)
+	return data, view.expire, nil
+}
+
+func (byteViewCodec) Decode(data []byte) (store.Value, error) {
+	expire, n := binary.Varint(data)
+	if n <= 0 {
+		return nil, errors.New("cache: invalid disk value")
+	}
+	data = data[n:]
+	written, n := binary.Varint(data)
+	if n <= 0 {
+		return nil, errors.New("cache: invalid disk value")
+	}
+	return ByteView{b: data[n:], expire: fromUnixNano(expire), written: fromUnixNano(written)}, nil
+}
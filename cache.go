@@ -13,23 +13,41 @@ import (
 // Cache 是对底层缓存存储的封装
 type Cache struct {
 	mu          sync.RWMutex
-	store       store.Store  // 底层存储实现
-	opts        CacheOptions // 缓存配置选项
-	hits        int64        // 缓存命中次数
-	misses      int64        // 缓存未命中次数
-	initialized int32        // 原子变量，标记缓存是否已初始化
-	closed      int32        // 原子变量，标记缓存是否已关闭
+	store       store.Store     // 底层存储实现
+	opts        CacheOptions    // 缓存配置选项
+	hits        int64           // 缓存命中次数
+	misses      int64           // 缓存未命中次数
+	initialized int32           // 原子变量，标记缓存是否已初始化
+	closed      int32           // 原子变量，标记缓存是否已关闭
+	clearing    int32           // 原子变量，标记缓存是否正在清空
+	deleting    sync.Map        // 正在被主动删除的 key，用于区分主动删除与淘汰
+	onRemoved   removalListener // 被动移除监听器
+	cipher      *valueCipher    // 值加密，nil 表示不加密
 }
 
+// removeReason 缓存项被底层存储移除的原因
+type removeReason int
+
+const (
+	removeDeleted removeReason = iota // 清空缓存导致的移除
+	removeEvicted                     // 容量淘汰
+	removeExpired                     // 过期
+)
+
+// removalListener 被动移除监听器
+type removalListener func(key string, value ByteView, reason removeReason)
+
 // CacheOptions 缓存配置选项
 type CacheOptions struct {
-	CacheType    store.CacheType                     // 缓存类型: LRU, LRU2 等
+	CacheType    store.CacheType                     // 缓存类型: LRU, LRU2, Arena
 	MaxBytes     int64                               // 最大内存使用量
 	BucketCount  uint16                              // 缓存桶数量 (用于 LRU2)
 	CapPerBucket uint16                              // 每个缓存桶的容量 (用于 LRU2)
 	Level2Cap    uint16                              // 二级缓存桶的容量 (用于 LRU2)
 	CleanupTime  time.Duration                       // 清理间隔
 	OnEvicted    func(key string, value store.Value) // 驱逐回调
+	DiskDir      string                              // 磁盘层的目录，设置后内存淘汰的缓存项写入磁盘，为空表示不启用
+	DiskMaxBytes int64                               // 磁盘层的容量，不大于 0 时使用 1GB
 }
 
 // DefaultCacheOptions 返回默认的缓存配置
@@ -71,11 +89,26 @@ func (c *Cache) ensureInitialized() {
 			CapPerBucket:    c.opts.CapPerBucket,
 			Level2Cap:       c.opts.Level2Cap,
 			CleanupInterval: c.opts.CleanupTime,
-			OnEvicted:       c.opts.OnEvicted,
+			OnEvicted:       c.handleEvicted,
+			Codec:           byteViewCodec{},
 		}
 
-		// 创建存储实例
-		c.store = store.NewStore(c.opts.CacheType, storeOpts)
+		// 创建存储实例，磁盘层创建失败时只使用内存
+		if c.opts.DiskDir != "" {
+			tiered, err := store.NewTieredStore(c.opts.CacheType, storeOpts, store.DiskOptions{
+				Dir:      c.opts.DiskDir,
+				MaxBytes: c.opts.DiskMaxBytes,
+				Codec:    byteViewCodec{},
+			})
+			if err != nil {
+				log.Printf("[Cache] WARN: failed to create disk tier, using memory only: %v", err)
+			} else {
+				c.store = tiered
+			}
+		}
+		if c.store == nil {
+			c.store = store.NewStore(c.opts.CacheType, storeOpts)
+		}
 
 		// 标记为已初始化
 		atomic.StoreInt32(&c.initialized, 1)
@@ -93,6 +126,19 @@ func (c *Cache) Add(key string, value ByteView) {
 
 	c.ensureInitialized()
 
+	value.expire = time.Time{}
+	if value.written.IsZero() {
+		value.written = time.Now()
+	}
+	if !c.cipher.usable() {
+		// 密钥不可用时不缓存，创建组时已记录错误
+		return
+	}
+	value, err := c.cipher.sealView(value)
+	if err != nil {
+		log.Printf("[Cache] WARN: Failed to encrypt key %s: %v", key, err)
+		return
+	}
 	if err := c.store.Set(key, value); err != nil {
 		log.Printf("[Cache] WARN: Failed to add key %s to cache: %v", key, err)
 	}
@@ -120,18 +166,28 @@ func (c *Cache) Get(ctx context.Context, key string) (value ByteView, ok bool) {
 		return ByteView{}, false
 	}
 
-	// 更新命中计数
-	atomic.AddInt64(&c.hits, 1)
+	bv, ok := val.(ByteView)
+	if !ok {
+		// 类型断言失败
+		log.Printf("[Cache] WARN: Type assertion failed for key %s, expected ByteView", key)
+		atomic.AddInt64(&c.misses, 1)
+		return ByteView{}, false
+	}
 
-	// 转换并返回
-	if bv, ok := val.(ByteView); ok {
-		return bv, true
+	// 解密失败（如密钥已更换）的值无法再使用，删除后视为未命中
+	bv, err := c.cipher.openView(bv)
+	if err != nil {
+		log.Printf("[Cache] WARN: Failed to decrypt key %s, dropping it: %v", key, err)
+		c.deleting.Store(key, struct{}{})
+		c.store.Delete(key)
+		c.deleting.Delete(key)
+		atomic.AddInt64(&c.misses, 1)
+		return ByteView{}, false
 	}
 
-	// 类型断言失败
-	log.Printf("[Cache] WARN: Type assertion failed for key %s, expected ByteView", key)
-	atomic.AddInt64(&c.misses, 1)
-	return ByteView{}, false
+	// 更新命中计数
+	atomic.AddInt64(&c.hits, 1)
+	return bv, true
 }
 
 // AddWithExpiration 向缓存中添加一个带过期时间的 key-value 对
@@ -151,6 +207,19 @@ func (c *Cache) AddWithExpiration(key string, value ByteView, expirationTime tim
 	}
 
 	// 设置到底层存储
+	value.expire = expirationTime
+	if value.written.IsZero() {
+		value.written = time.Now()
+	}
+	if !c.cipher.usable() {
+		// 密钥不可用时不缓存，创建组时已记录错误
+		return
+	}
+	value, err := c.cipher.sealView(value)
+	if err != nil {
+		log.Printf("[Cache] WARN: Failed to encrypt key %s: %v", key, err)
+		return
+	}
 	if err := c.store.SetWithExpiration(key, value, expiration); err != nil {
 		log.Printf("[Cache] WARN: Failed to add key %s to cache with expiration: %v", key, err)
 	}
@@ -165,6 +234,10 @@ func (c *Cache) Delete(key string) bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	// 标记为主动删除，移除回调中不再视为淘汰
+	c.deleting.Store(key, struct{}{})
+	defer c.deleting.Delete(key)
+
 	return c.store.Delete(key)
 }
 
@@ -177,7 +250,9 @@ func (c *Cache) Clear() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	atomic.StoreInt32(&c.clearing, 1)
 	c.store.Clear()
+	atomic.StoreInt32(&c.clearing, 0)
 
 	// 重置统计信息
 	atomic.StoreInt64(&c.hits, 0)
@@ -196,6 +271,144 @@ func (c *Cache) Len() int {
 	return c.store.Len()
 }
 
+// Range 遍历缓存中所有未过期的项，fn 返回 false 时停止遍历
+// 底层存储不支持遍历时不做任何事
+func (c *Cache) Range(fn func(key string, value ByteView) bool) {
+	if atomic.LoadInt32(&c.closed) == 1 || atomic.LoadInt32(&c.initialized) == 0 {
+		return
+	}
+
+	c.mu.RLock()
+	ranger, ok := c.store.(store.Ranger)
+	c.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	ranger.Range(func(key string, value store.Value) bool {
+		view, ok := value.(ByteView)
+		if !ok {
+			return true
+		}
+		view, err := c.cipher.openView(view)
+		if err != nil {
+			return true
+		}
+		return fn(key, view)
+	})
+}
+
+// setCipher 设置值加密，必须在缓存初始化之前设置
+func (c *Cache) setCipher(cipher *valueCipher) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cipher = cipher
+}
+
+// PurgeExpired 立即清理所有已过期的项，返回清理的数量
+// 底层存储不支持时返回 0，过期项仍会在定期清理时移除
+func (c *Cache) PurgeExpired() int {
+	if atomic.LoadInt32(&c.closed) == 1 || atomic.LoadInt32(&c.initialized) == 0 {
+		return 0
+	}
+
+	c.mu.RLock()
+	purger, ok := c.store.(store.Purger)
+	c.mu.RUnlock()
+	if !ok {
+		return 0
+	}
+	return purger.PurgeExpired()
+}
+
+// setRemovalListener 设置被动移除监听器，在底层存储因清空、淘汰或过期移除缓存项时调用
+// 主动 Delete 不会触发该监听器，必须在缓存初始化之前设置
+func (c *Cache) setRemovalListener(fn removalListener) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onRemoved = fn
+}
+
+// handleEvicted 处理底层存储的移除回调
+// 先调用用户配置的 OnEvicted，再根据移除原因通知监听器
+func (c *Cache) handleEvicted(key string, value store.Value) {
+	// 回调收到的是明文，解密失败的值按原样传递给 OnEvicted，不通知监听器
+	if view, ok := value.(ByteView); ok && c.cipher != nil {
+		plain, err := c.cipher.openView(view)
+		if err != nil {
+			if c.opts.OnEvicted != nil {
+				c.opts.OnEvicted(key, value)
+			}
+			return
+		}
+		value = plain
+	}
+
+	if c.opts.OnEvicted != nil {
+		c.opts.OnEvicted(key, value)
+	}
+
+	if c.onRemoved == nil {
+		return
+	}
+
+	byteView, ok := value.(ByteView)
+	if !ok {
+		return
+	}
+
+	switch {
+	case atomic.LoadInt32(&c.clearing) == 1:
+		c.onRemoved(key, byteView, removeDeleted)
+	case c.isDeleting(key):
+		// 主动删除由调用方自行处理
+	case !byteView.expire.IsZero() && !time.Now().Before(byteView.expire):
+		c.onRemoved(key, byteView, removeExpired)
+	default:
+		c.onRemoved(key, byteView, removeEvicted)
+	}
+}
+
+// isDeleting 判断 key 是否正在被主动删除
+func (c *Cache) isDeleting(key string) bool {
+	_, ok := c.deleting.Load(key)
+	return ok
+}
+
+// UsedBytes 返回缓存当前占用的字节数
+func (c *Cache) UsedBytes() int64 {
+	if atomic.LoadInt32(&c.closed) == 1 || atomic.LoadInt32(&c.initialized) == 0 {
+		return 0
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.store.UsedBytes()
+}
+
+// entrySize 返回 key 在存储中占用的字节数（与 UsedBytes 的计算方式相同），不存在时返回 0
+// 不计入命中统计，用于覆盖写入前的配额检查；存储不支持 store.Peeker 时返回 0，即按新增计算
+func (c *Cache) entrySize(key string) int64 {
+	if atomic.LoadInt32(&c.closed) == 1 || atomic.LoadInt32(&c.initialized) == 0 {
+		return 0
+	}
+
+	peeker, ok := c.store.(store.Peeker)
+	if !ok {
+		return 0
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	val, ok := peeker.Peek(key)
+	if !ok {
+		return 0
+	}
+	return int64(len(key) + val.Len())
+}
+
 // Close 关闭缓存，释放资源
 func (c *Cache) Close() {
 	// 如果已经关闭，直接返回
@@ -231,6 +444,7 @@ func (c *Cache) Stats() map[string]interface{} {
 
 	if atomic.LoadInt32(&c.initialized) == 1 {
 		stats["size"] = c.Len()
+		stats["used_bytes"] = c.UsedBytes()
 
 		// 计算命中率
 		totalRequests := stats["hits"].(int64) + stats["misses"].(int64)
@@ -239,6 +453,15 @@ func (c *Cache) Stats() map[string]interface{} {
 		} else {
 			stats["hit_rate"] = 0.0
 		}
+
+		c.mu.RLock()
+		if tiered, ok := c.store.(*store.Tiered); ok {
+			stats["disk_size"] = tiered.DiskLen()
+			stats["disk_bytes"] = tiered.DiskBytes()
+			stats["disk_spills"] = tiered.Spills()
+			stats["disk_promotions"] = tiered.Promotions()
+		}
+		c.mu.RUnlock()
 	}
 
 	return stats
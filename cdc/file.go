@@ -0,0 +1,63 @@
+package cdc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// File 将变更以 JSON Lines（每行一条记录）追加到文件的 Sink
+type File struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+	sync bool
+}
+
+var _ Sink = (*File)(nil)
+
+// NewFile 打开或创建 path 并在末尾追加变更，sync 为 true 时每批写入后调用 fsync
+func NewFile(path string, sync bool) (*File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("cdc: failed to open %s: %w", path, err)
+	}
+	return &File{file: f, w: bufio.NewWriter(f), sync: sync}, nil
+}
+
+// Write 追加一批变更
+func (f *File) Write(ctx context.Context, records []Record) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	enc := json.NewEncoder(f.w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			f.w.Reset(f.file)
+			return fmt.Errorf("cdc: failed to encode record: %w", err)
+		}
+	}
+	if err := f.w.Flush(); err != nil {
+		// 丢弃缓冲区中的残留数据，重试时重新写入整批，已写入文件的部分可能重复
+		f.w.Reset(f.file)
+		return fmt.Errorf("cdc: failed to write %s: %w", f.file.Name(), err)
+	}
+	if f.sync {
+		return f.file.Sync()
+	}
+	return nil
+}
+
+// Close 关闭文件
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.w.Flush(); err != nil {
+		f.file.Close()
+		return err
+	}
+	return f.file.Close()
+}
@@ -0,0 +1,143 @@
+package cdc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// defaultHTTPTimeout HTTP Sink 默认的请求超时时间
+const defaultHTTPTimeout = 10 * time.Second
+
+// WebhookConfig 定义 webhook Sink 的配置
+type WebhookConfig struct {
+	URL        string            // 接收变更的地址，每批变更以 JSON 数组 POST 到该地址
+	Headers    map[string]string // 附加的请求头，如 Authorization
+	Timeout    time.Duration     // 每次请求的超时时间，0 使用默认值 10s
+	HTTPClient *http.Client      // 为空时使用按 Timeout 创建的客户端
+}
+
+// Webhook 将每批变更以 JSON 数组 POST 到指定地址的 Sink，非 2xx 响应视为失败并重试
+type Webhook struct {
+	config WebhookConfig
+	client *http.Client
+}
+
+var _ Sink = (*Webhook)(nil)
+
+// NewWebhook 创建 webhook Sink
+func NewWebhook(config WebhookConfig) *Webhook {
+	return &Webhook{config: config, client: httpClient(config.HTTPClient, config.Timeout)}
+}
+
+// Write 发送一批变更
+func (w *Webhook) Write(ctx context.Context, records []Record) error {
+	body, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("cdc: failed to encode records: %w", err)
+	}
+	return post(ctx, w.client, w.config.URL, "application/json", w.config.Headers, body)
+}
+
+// Close 没有需要释放的资源
+func (w *Webhook) Close() error {
+	return nil
+}
+
+// KafkaRESTConfig 定义通过 Kafka REST Proxy 写入 Kafka 的 Sink 配置
+type KafkaRESTConfig struct {
+	URL        string            // REST Proxy 地址，如 "http://kafka-rest:8082"
+	Topic      string            // 写入的 topic
+	Headers    map[string]string // 附加的请求头，如 Authorization
+	Timeout    time.Duration     // 每次请求的超时时间，0 使用默认值 10s
+	HTTPClient *http.Client      // 为空时使用按 Timeout 创建的客户端
+}
+
+// KafkaREST 通过 Kafka REST Proxy（v2 API）将变更写入 Kafka topic 的 Sink
+//
+// 每条变更是一条 JSON 消息，消息的 key 为 "组/缓存键"，同一个缓存键的变更进入同一个分区并保持顺序。
+// 不依赖 Kafka 客户端库；直接连接 broker 时可以用 Kafka 客户端库实现 Sink。
+type KafkaREST struct {
+	config   KafkaRESTConfig
+	client   *http.Client
+	endpoint string
+}
+
+var _ Sink = (*KafkaREST)(nil)
+
+// NewKafkaREST 创建 Kafka REST Proxy Sink
+func NewKafkaREST(config KafkaRESTConfig) *KafkaREST {
+	return &KafkaREST{
+		config:   config,
+		client:   httpClient(config.HTTPClient, config.Timeout),
+		endpoint: config.URL + "/topics/" + url.PathEscape(config.Topic),
+	}
+}
+
+// kafkaRecord REST Proxy 请求中的一条消息
+type kafkaRecord struct {
+	Key   string `json:"key"`
+	Value Record `json:"value"`
+}
+
+// Write 将一批变更作为一次请求写入 topic
+func (k *KafkaREST) Write(ctx context.Context, records []Record) error {
+	req := struct {
+		Records []kafkaRecord `json:"records"`
+	}{Records: make([]kafkaRecord, len(records))}
+	for i, r := range records {
+		req.Records[i] = kafkaRecord{Key: r.Group + "/" + r.Key, Value: r}
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("cdc: failed to encode records: %w", err)
+	}
+	return post(ctx, k.client, k.endpoint, "application/vnd.kafka.json.v2+json", k.config.Headers, body)
+}
+
+// Close 没有需要释放的资源
+func (k *KafkaREST) Close() error {
+	return nil
+}
+
+// httpClient 返回 client，为空时按 timeout 创建
+func httpClient(client *http.Client, timeout time.Duration) *http.Client {
+	if client != nil {
+		return client
+	}
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	return &http.Client{Timeout: timeout}
+}
+
+// post 发送 POST 请求，非 2xx 响应返回包含响应内容的错误
+func post(ctx context.Context, client *http.Client, url, contentType string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cdc: invalid request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cdc: request to %s failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("cdc: %s returned %s: %s", url, resp.Status, bytes.TrimSpace(msg))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
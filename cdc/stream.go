@@ -0,0 +1,199 @@
+// Package cdc 将缓存组的变更（写入、删除、淘汰、过期）按顺序输出到外部系统（文件、webhook、Kafka），
+// 下游可以据此构建物化视图或审计缓存行为。
+//
+// 每个组的变更带有组内单调递增的序号 Seq，按序号顺序成批写入 Sink；写入失败时退避重试同一批，
+// 不会乱序。变更在内存队列中等待写入，队列写满时丢弃新的变更并计数，缓存的读写不会被 Sink 拖慢，
+// 下游通过序号的间断可以发现丢失的变更。重试可能导致同一条变更被写入多次，下游应按序号去重。
+//
+// 序号在变更生效（写入本地缓存）之后分配，不与缓存的写入在同一把锁内，因此不保证同一个 key 的顺序：
+// 对同一个 key 并发的 set 和 delete，序号较大的不一定是缓存中最终生效的那一次。需要每个 key 最终状态的
+// 下游应在收到变更后以缓存中的当前值为准，或由应用保证同一个 key 不会被并发写入。
+package cdc
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultBufferSize 默认的队列容量
+	defaultBufferSize = 10000
+	// defaultBatchSize 默认每批写入的最大条数
+	defaultBatchSize = 100
+	// minRetryDelay 写入失败后重试的初始等待时间
+	minRetryDelay = 100 * time.Millisecond
+	// maxRetryDelay 写入失败后重试的最长等待时间
+	maxRetryDelay = 10 * time.Second
+	// closeTimeout 关闭时写出剩余变更的最长时间
+	closeTimeout = 5 * time.Second
+)
+
+// Record 一条变更记录
+type Record struct {
+	Seq    uint64     `json:"seq"`              // 组内从 1 开始单调递增的序号，同一个 key 的并发变更不保证按生效顺序编号
+	Group  string     `json:"group"`            // 缓存组
+	Type   string     `json:"type"`             // set、delete、evict 或 expire
+	Key    string     `json:"key"`              // 缓存键
+	Value  []byte     `json:"value,omitempty"`  // set 的新值，只在 Config.IncludeValues 时记录
+	Expire *time.Time `json:"expire,omitempty"` // set 的过期时间，nil 表示永不过期
+	Origin string     `json:"origin"`           // 变更来源：local、peer 或 external
+	Time   time.Time  `json:"time"`             // 变更发生的时间
+}
+
+// Sink 变更的输出目标，Write 在同一个协程中按顺序调用
+type Sink interface {
+	// Write 写入一批按序号排列的变更，返回错误时同一批会被重试；records 在返回后会被复用，不能继续持有
+	Write(ctx context.Context, records []Record) error
+	// Close 释放 Sink 持有的资源
+	Close() error
+}
+
+// Config 变更流配置，零值使用默认值
+type Config struct {
+	BufferSize    int  // 等待写入的变更数量上限，默认 10000
+	BatchSize     int  // 每次写入的最大条数，默认 100
+	IncludeValues bool // 是否记录 set 的值，值可能很大或包含敏感数据，默认不记录
+}
+
+// Stream 一个组的变更流，在后台协程中将变更写入 Sink
+type Stream struct {
+	sink   Sink
+	config Config
+
+	mu     sync.Mutex // 保证序号的分配顺序与入队顺序一致
+	seq    uint64
+	closed bool
+	queue  chan Record
+
+	written atomic.Int64 // 已写入 Sink 的变更数
+	dropped atomic.Int64 // 因队列已满或关闭时写入失败而丢弃的变更数
+
+	ctx    context.Context // 关闭后超过 closeTimeout 时取消，中止写入和重试
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewStream 创建变更流并启动写入协程
+func NewStream(sink Sink, config Config) *Stream {
+	if config.BufferSize <= 0 {
+		config.BufferSize = defaultBufferSize
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Stream{
+		sink:   sink,
+		config: config,
+		queue:  make(chan Record, config.BufferSize),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// IncludeValues 返回是否需要记录 set 的值
+func (s *Stream) IncludeValues() bool {
+	return s.config.IncludeValues
+}
+
+// Append 为变更分配序号并放入队列，队列已满或已关闭时丢弃，不会阻塞
+func (s *Stream) Append(r Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+
+	s.seq++
+	r.Seq = s.seq
+	select {
+	case s.queue <- r:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Written 返回已写入 Sink 的变更数
+func (s *Stream) Written() int64 {
+	return s.written.Load()
+}
+
+// Dropped 返回丢弃的变更数
+func (s *Stream) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Close 停止接收变更，写出队列中剩余的变更后关闭 Sink，最多等待 closeTimeout
+func (s *Stream) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+
+	timer := time.AfterFunc(closeTimeout, s.cancel)
+	<-s.done
+	timer.Stop()
+	s.cancel()
+	return s.sink.Close()
+}
+
+// run 按顺序成批写入队列中的变更，直到队列关闭并写完
+func (s *Stream) run() {
+	defer close(s.done)
+
+	batch := make([]Record, 0, s.config.BatchSize)
+	for r := range s.queue {
+		batch = append(batch[:0], r)
+		// 取出已经在队列中的变更组成一批，不等待新的变更
+	fill:
+		for len(batch) < s.config.BatchSize {
+			select {
+			case r, ok := <-s.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, r)
+			default:
+				break fill
+			}
+		}
+		s.write(batch)
+	}
+}
+
+// write 写入一批变更，失败时退避重试，关闭超时后放弃
+func (s *Stream) write(batch []Record) {
+	delay := minRetryDelay
+	for {
+		err := s.sink.Write(s.ctx, batch)
+		if err == nil {
+			s.written.Add(int64(len(batch)))
+			return
+		}
+		if s.ctx.Err() != nil {
+			s.dropped.Add(int64(len(batch)))
+			return
+		}
+		log.Printf("[CDC] failed to write %d records (seq %d-%d): %v, retrying in %v",
+			len(batch), batch[0].Seq, batch[len(batch)-1].Seq, err, delay)
+
+		select {
+		case <-s.ctx.Done():
+			s.dropped.Add(int64(len(batch)))
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
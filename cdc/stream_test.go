@@ -0,0 +1,170 @@
+package cdc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSink 记录每次写入的序号，前 failures 次写入返回错误，设置 block 时写入前等待其关闭
+type fakeSink struct {
+	mu       sync.Mutex
+	attempts [][]uint64 // 每次调用 Write 收到的序号，包括失败的调用
+	written  []uint64   // 写入成功的序号
+	failures int
+	block    chan struct{}
+	closed   bool
+}
+
+func (s *fakeSink) Write(ctx context.Context, records []Record) error {
+	if s.block != nil {
+		<-s.block
+	}
+	seqs := make([]uint64, len(records))
+	for i, r := range records {
+		seqs[i] = r.Seq
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts = append(s.attempts, seqs)
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("sink unavailable")
+	}
+	s.written = append(s.written, seqs...)
+	return nil
+}
+
+func (s *fakeSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *fakeSink) writtenSeqs() []uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]uint64(nil), s.written...)
+}
+
+// waitWritten 等待 Stream 写出 n 条变更
+func waitWritten(t *testing.T, s *Stream, n int64) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for s.Written() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("应写出 %d 条变更，实际为 %d 条", n, s.Written())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func equalSeqs(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestStream_SeqGapWhenQueueFull(t *testing.T) {
+	sink := &fakeSink{block: make(chan struct{})}
+	s := NewStream(sink, Config{BufferSize: 2, BatchSize: 1})
+
+	// 第 1 条被写入协程取出并阻塞在 Sink 中，第 2、3 条填满队列，第 4、5 条被丢弃
+	s.Append(Record{Key: "a"})
+	deadline := time.Now().Add(time.Second)
+	for len(s.queue) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("写入协程没有取出第 1 条变更")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for _, key := range []string{"b", "c", "d", "e"} {
+		s.Append(Record{Key: key})
+	}
+	if n := s.Dropped(); n != 2 {
+		t.Errorf("队列写满时应丢弃 2 条变更，实际为 %d 条", n)
+	}
+
+	close(sink.block)
+	waitWritten(t, s, 3)
+	s.Append(Record{Key: "f"})
+	s.Close()
+
+	// 丢弃的变更同样占用序号，下游通过序号的间断发现丢失
+	if got, want := sink.writtenSeqs(), []uint64{1, 2, 3, 6}; !equalSeqs(got, want) {
+		t.Errorf("写出的序号应为 %v，实际为 %v", want, got)
+	}
+}
+
+func TestStream_RetriesSameBatch(t *testing.T) {
+	sink := &fakeSink{failures: 2}
+	s := NewStream(sink, Config{BatchSize: 10})
+
+	for _, key := range []string{"a", "b", "c"} {
+		s.Append(Record{Key: key})
+	}
+	waitWritten(t, s, 3)
+	s.Close()
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.attempts) < 3 {
+		t.Fatalf("失败 2 次后应重试，实际只写入 %d 次", len(sink.attempts))
+	}
+	// 失败的一批原样重试，之后的变更不会插队
+	first := sink.attempts[0]
+	for i, attempt := range sink.attempts[:3] {
+		if !equalSeqs(attempt, first) {
+			t.Errorf("第 %d 次写入应重试同一批 %v，实际为 %v", i+1, first, attempt)
+		}
+	}
+	if !equalSeqs(sink.written, []uint64{1, 2, 3}) {
+		t.Errorf("写出的序号应为 [1 2 3]，实际为 %v", sink.written)
+	}
+	if n := s.Dropped(); n != 0 {
+		t.Errorf("重试成功时不应丢弃变更，实际丢弃 %d 条", n)
+	}
+}
+
+func TestStream_CloseFlushes(t *testing.T) {
+	sink := &fakeSink{}
+	s := NewStream(sink, Config{BatchSize: 2})
+
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		s.Append(Record{Key: key})
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close 失败: %v", err)
+	}
+
+	if got, want := sink.writtenSeqs(), []uint64{1, 2, 3, 4, 5}; !equalSeqs(got, want) {
+		t.Errorf("关闭时应按顺序写出队列中的全部变更 %v，实际为 %v", want, got)
+	}
+	sink.mu.Lock()
+	for i, attempt := range sink.attempts {
+		if len(attempt) > 2 {
+			t.Errorf("第 %d 批超过了 BatchSize: %v", i+1, attempt)
+		}
+	}
+	closed := sink.closed
+	sink.mu.Unlock()
+	if !closed {
+		t.Error("关闭时应关闭 Sink")
+	}
+
+	// 关闭后的变更被忽略，不会阻塞或写入
+	s.Append(Record{Key: "f"})
+	if got := sink.writtenSeqs(); len(got) != 5 {
+		t.Errorf("关闭后的变更不应写入，实际为 %v", got)
+	}
+}
@@ -0,0 +1,61 @@
+package mycache
+
+import (
+	"log"
+
+	"github.com/linhx1999/MyCache-Go/cdc"
+)
+
+// WithChangeStream 将组的变更（set、delete、evict、expire，含时间和来源）按顺序输出到 sink
+//
+// 变更带有组内递增的序号，在后台成批写入 sink，写入失败时重试；队列写满时丢弃并计入 Stats 的
+// changes_dropped。组关闭时写出剩余的变更并关闭 sink。每个组需要使用独立的 sink。
+// 序号在变更生效后分配，同一个 key 上并发的写入和删除不保证按生效顺序编号（见 cdc 包的说明）。
+//
+//	sink, err := cdc.NewFile("/var/log/mycache/users.cdc", false)
+//	group := mycache.NewGroup("users", 64<<20, source, mycache.WithChangeStream(sink, cdc.Config{}))
+func WithChangeStream(sink cdc.Sink, config cdc.Config) GroupOption {
+	return func(g *Group) {
+		g.changeSink = sink
+		g.changeConfig = config
+	}
+}
+
+// startChangeStream 启动变更流
+func (g *Group) startChangeStream() {
+	if g.changeSink == nil {
+		return
+	}
+	g.changes = cdc.NewStream(g.changeSink, g.changeConfig)
+}
+
+// stopChangeStream 写出剩余的变更并关闭 sink
+func (g *Group) stopChangeStream() {
+	if g.changes == nil {
+		return
+	}
+	if err := g.changes.Close(); err != nil {
+		log.Printf("[MyCache] failed to close change stream of group [%s]: %v", g.name, err)
+	}
+}
+
+// recordChange 将事件转换为变更记录放入变更流
+func (g *Group) recordChange(e Event) {
+	r := cdc.Record{
+		Group:  e.Group,
+		Type:   e.Type.String(),
+		Key:    e.Key,
+		Origin: e.Origin.String(),
+		Time:   e.Time,
+	}
+	if e.Type == EventSet {
+		if g.changes.IncludeValues() {
+			r.Value = e.Value.ByteSlice()
+		}
+		if !e.Value.expire.IsZero() {
+			expire := e.Value.expire
+			r.Expire = &expire
+		}
+	}
+	g.changes.Append(r)
+}
@@ -2,108 +2,611 @@ package mycache
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	pb "github.com/linhx1999/MyCache-Go/pb"
+	"github.com/linhx1999/MyCache-Go/registry"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+)
+
+// RPCTimeouts 各类节点操作的超时时间，0 表示不设置超时（只受调用方 ctx 约束）
+type RPCTimeouts struct {
+	Get    time.Duration
+	Set    time.Duration
+	Delete time.Duration
+}
+
+// DefaultRPCTimeouts 默认的节点操作超时时间
+var DefaultRPCTimeouts = RPCTimeouts{
+	Get:    3 * time.Second,
+	Set:    3 * time.Second,
+	Delete: 3 * time.Second,
+}
+
+const (
+	defaultPoolSize          = 2                // 每个节点默认的连接数
+	defaultConnectBaseDelay  = time.Second      // 重连的初始退避时间
+	defaultConnectMaxDelay   = 30 * time.Second // 重连的最大退避时间
+	defaultMinConnectTimeout = 5 * time.Second  // 单次建立连接的最短超时时间
 )
 
 type Client struct {
-	addr    string
-	svcName string
-	etcdCli *clientv3.Client
-	conn    *grpc.ClientConn
-	grpcCli pb.CacheServiceClient
+	addr     string
+	svcName  string
+	etcdCli  *clientv3.Client
+	opts     clientOptions
+	dialOpts []grpc.DialOption            // 建立连接使用的选项，轮换连接时复用
+	pool     atomic.Pointer[[]pooledConn] // 连接池，轮换连接时整体替换
+	next     atomic.Uint64                // 轮询选择连接的计数器
+	done     chan struct{}                // 关闭时停止连接轮换
+	mu       sync.Mutex                   // 保护连接池的替换与关闭
+	closed   bool                         // 是否已关闭
+	proto    atomic.Pointer[peerProtocol] // 对端的协议版本和功能，nil 表示未知
 }
 
 var _ Peer = (*Client)(nil)
 
-func NewClient(addr string, svcName string, etcdCli *clientv3.Client) (*Client, error) {
+// clientOptions 客户端配置
+type clientOptions struct {
+	poolSize         int                           // 连接池大小
+	connectBaseDelay time.Duration                 // 重连的初始退避时间
+	connectMaxDelay  time.Duration                 // 重连的最大退避时间
+	retry            RetryPolicy                   // RPC 重试策略，零值表示不重试
+	waitForReady     bool                          // 连接未就绪时是否等待而不是立即失败
+	timeouts         RPCTimeouts                   // 各类操作的超时时间
+	keepalive        keepalive.ClientParameters    // 客户端 keepalive 参数，零值表示不启用
+	dialOpts         []grpc.DialOption             // 用户追加的连接选项
+	interceptors     []grpc.UnaryClientInterceptor // 用户追加的客户端拦截器
+	compressor       string                        // 请求使用的压缩算法，为空表示不压缩
+	idleTimeout      time.Duration                 // 连接空闲多久后断开，0 使用 gRPC 默认值（30 分钟）
+	maxConnAge       time.Duration                 // 连接的最长使用时间，0 表示不轮换
+	maxRecvMsgSize   int                           // 接收消息的大小上限，0 使用 gRPC 默认值
+	maxSendMsgSize   int                           // 发送消息的大小上限，0 使用 gRPC 默认值
+	tokenSource      TokenSource                   // 请求携带的认证 token，nil 表示不携带
+	tls              *certReloader                 // TLS 证书，nil 表示使用明文连接
+	tlsConfig        *tls.Config                   // 自定义 TLS 配置，优先于 tls
+	tracing          *tracing                      // 链路追踪，nil 表示不启用
+}
+
+// ClientOption 定义客户端的配置选项
+type ClientOption func(*clientOptions)
+
+// WithPoolSize 设置与每个节点保持的连接数
+// 多个连接可以避免大量大体积的 Set 同步在同一个 HTTP/2 连接上排队
+func WithPoolSize(size int) ClientOption {
+	return func(o *clientOptions) {
+		if size > 0 {
+			o.poolSize = size
+		}
+	}
+}
+
+// WithConnectBackoff 设置连接断开后重连的退避时间，每次失败后翻倍直到 maxDelay
+func WithConnectBackoff(baseDelay, maxDelay time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.connectBaseDelay = baseDelay
+		o.connectMaxDelay = maxDelay
+	}
+}
+
+// WithClientRetry 设置 RPC 的重试策略，只有 UNAVAILABLE 等暂时性错误会被重试
+func WithClientRetry(policy RetryPolicy) ClientOption {
+	return func(o *clientOptions) {
+		o.retry = policy
+	}
+}
+
+// WithWaitForReady 设置连接处于故障状态时 RPC 是否等待重连，默认不等待
+//
+// 默认情况下，到已下线或正在重启的节点的请求立即以 ErrPeerUnavailable 失败，由重试策略、
+// 对冲读和副本读取接管；启用后请求会一直等待到连接建立或超时，适合只连接单个节点的运维工具。
+func WithWaitForReady(enabled bool) ClientOption {
+	return func(o *clientOptions) {
+		o.waitForReady = enabled
+	}
+}
+
+// WithRPCTimeouts 设置各类节点操作的超时时间
+// 实际的截止时间取该超时与调用方 ctx 截止时间中较早的一个，单个卡住的节点不会无限阻塞调用方
+func WithRPCTimeouts(timeouts RPCTimeouts) ClientOption {
+	return func(o *clientOptions) {
+		o.timeouts = timeouts
+	}
+}
+
+// WithClientKeepalive 设置客户端 keepalive 参数
+// 连接空闲超过 params.Time 后发送 ping，params.Timeout 内未收到响应则认为连接已断开并重连，
+// 避免半开连接使节点请求挂起数分钟。服务端需通过 WithServerKeepalive 允许相应的 ping 频率。
+func WithClientKeepalive(params keepalive.ClientParameters) ClientOption {
+	return func(o *clientOptions) {
+		o.keepalive = params
+	}
+}
+
+// WithDialOptions 追加建立 gRPC 连接时使用的选项，如 TLS 凭证、负载均衡配置等
+// 追加的选项在默认选项之后应用，可以覆盖默认的传输凭证等设置
+func WithDialOptions(opts ...grpc.DialOption) ClientOption {
+	return func(o *clientOptions) {
+		o.dialOpts = append(o.dialOpts, opts...)
+	}
+}
+
+// WithUnaryInterceptors 追加一元 RPC 的客户端拦截器，如注入认证 token、链路追踪等
+// 拦截器按添加顺序执行；通过 ClientPicker 使用时配合 WithClientOptions
+func WithUnaryInterceptors(interceptors ...grpc.UnaryClientInterceptor) ClientOption {
+	return func(o *clientOptions) {
+		o.interceptors = append(o.interceptors, interceptors...)
+	}
+}
+
+// CompressionGzip gzip 压缩算法的名称，可用于 WithCompression
+const CompressionGzip = gzip.Name
+
+// WithCompression 设置节点间请求使用的压缩算法，如 CompressionGzip
+//
+// 服务端会使用相同的算法压缩响应，适用于缓存值较大、节点间带宽成为瓶颈的场景。
+// 其他算法（如 snappy）需先通过 encoding.RegisterCompressor 在客户端和服务端注册。
+// 对端不支持压缩（旧版本节点或未注册该算法）时自动改为不压缩。
+func WithCompression(name string) ClientOption {
+	return func(o *clientOptions) {
+		o.compressor = name
+	}
+}
+
+// WithIdleTimeout 设置连接的空闲超时
+// 连接上超过 d 没有请求时断开，下次请求时再重新建立，避免长期运行的进程保持到已下线节点的连接
+func WithIdleTimeout(d time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.idleTimeout = d
+	}
+}
+
+// WithMaxConnectionAge 设置连接的最长使用时间
+//
+// 连接建立超过 d（加上最多 10% 的随机抖动）后，新建一个连接替换它，旧连接在正在进行的请求
+// 完成后关闭。节点位于负载均衡器之后时，可使请求逐步分散到新的后端实例。
+func WithMaxConnectionAge(d time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.maxConnAge = d
+	}
+}
+
+// newClientOptions 在默认配置上应用选项
+func newClientOptions(opts ...ClientOption) clientOptions {
+	options := clientOptions{
+		poolSize:         defaultPoolSize,
+		connectBaseDelay: defaultConnectBaseDelay,
+		connectMaxDelay:  defaultConnectMaxDelay,
+		timeouts:         DefaultRPCTimeouts,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// NewClient 创建到指定节点的客户端
+//
+// 连接在首次请求时才真正建立（lazy），断开后由 gRPC 按退避策略自动重连，
+// 因此节点暂时不可达时 NewClient 也会成功返回。
+func NewClient(addr string, svcName string, etcdCli *clientv3.Client, opts ...ClientOption) (*Client, error) {
+	options := newClientOptions(opts...)
+
 	var err error
 	if etcdCli == nil {
-		etcdCli, err = clientv3.New(clientv3.Config{
-			Endpoints:   []string{"localhost:2379"},
-			DialTimeout: 5 * time.Second,
-		})
+		etcdCli, err = registry.DefaultConfig.NewClient()
 		if err != nil {
 			return nil, fmt.Errorf("failed to create etcd client: %v", err)
 		}
 	}
 
-	conn, err := grpc.Dial(addr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithBlock(),
-		grpc.WithTimeout(10*time.Second),
-		grpc.WithDefaultCallOptions(grpc.WaitForReady(true)),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to dial server: %v", err)
-	}
+	return newClient(addr, svcName, etcdCli, options)
+}
 
-	grpcClient := pb.NewCacheServiceClient(conn)
+// DialNode 创建到指定节点的客户端，不连接 etcd，用于运维工具直接访问某个节点（如 Stats、Rebalance）
+func DialNode(addr string, opts ...ClientOption) (*Client, error) {
+	return newClient(addr, defaultSvcName, nil, newClientOptions(opts...))
+}
 
+// newClient 按已解析的配置创建客户端，etcdCli 可以为 nil（静态节点模式）
+func newClient(addr string, svcName string, etcdCli *clientv3.Client, options clientOptions) (*Client, error) {
 	client := &Client{
 		addr:    addr,
 		svcName: svcName,
 		etcdCli: etcdCli,
-		conn:    conn,
-		grpcCli: grpcClient,
+		opts:    options,
+		done:    make(chan struct{}),
+	}
+
+	backoffConfig := backoff.DefaultConfig
+	backoffConfig.BaseDelay = options.connectBaseDelay
+	backoffConfig.MaxDelay = options.connectMaxDelay
+
+	dialOpts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           backoffConfig,
+			MinConnectTimeout: defaultMinConnectTimeout,
+		}),
+	}
+	if options.waitForReady {
+		dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(grpc.WaitForReady(true)))
+	}
+	if options.maxRecvMsgSize > 0 {
+		dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(options.maxRecvMsgSize)))
+	}
+	if options.maxSendMsgSize > 0 {
+		dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(grpc.MaxCallSendMsgSize(options.maxSendMsgSize)))
+	}
+	if options.tlsConfig != nil {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(options.tlsConfig)))
+	} else if options.tls != nil {
+		if err := options.tls.load(); err != nil {
+			return nil, fmt.Errorf("failed to load TLS credentials: %v", err)
+		}
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(options.tls.clientConfig())))
+	}
+	if options.tokenSource != nil {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(tokenCredentials{source: options.tokenSource}))
+	}
+	if options.keepalive != (keepalive.ClientParameters{}) {
+		dialOpts = append(dialOpts, grpc.WithKeepaliveParams(options.keepalive))
 	}
+	// 追踪在最外层执行，span 覆盖重试和其他拦截器
+	if options.tracing != nil {
+		dialOpts = append(dialOpts,
+			grpc.WithChainUnaryInterceptor(options.tracing.unaryClientInterceptor),
+			grpc.WithChainStreamInterceptor(options.tracing.streamClientInterceptor),
+		)
+	}
+	// 携带协议版本，按对端支持的功能决定是否压缩
+	dialOpts = append(dialOpts,
+		grpc.WithChainUnaryInterceptor(client.versionUnaryClientInterceptor),
+		grpc.WithChainStreamInterceptor(client.versionStreamClientInterceptor),
+	)
+	// 附加请求优先级和剩余截止时间，在用户拦截器之前执行
+	dialOpts = append(dialOpts,
+		grpc.WithChainUnaryInterceptor(priorityUnaryClientInterceptor),
+		grpc.WithChainStreamInterceptor(priorityStreamClientInterceptor),
+	)
+	if len(options.interceptors) > 0 {
+		dialOpts = append(dialOpts, grpc.WithChainUnaryInterceptor(options.interceptors...))
+	}
+	if options.idleTimeout > 0 {
+		dialOpts = append(dialOpts, grpc.WithIdleTimeout(options.idleTimeout))
+	}
+	dialOpts = append(dialOpts, options.dialOpts...)
+	client.dialOpts = dialOpts
 
+	pool := make([]pooledConn, 0, options.poolSize)
+	for i := 0; i < options.poolSize; i++ {
+		pc, err := client.dial()
+		if err != nil {
+			for _, pc := range pool {
+				pc.conn.Close()
+			}
+			return nil, err
+		}
+		pool = append(pool, pc)
+	}
+	client.pool.Store(&pool)
+
+	if options.maxConnAge > 0 {
+		go client.rotateConns()
+	}
 	return client, nil
 }
 
-func (c *Client) Get(group, key string) ([]byte, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// conns 返回当前的连接池
+func (c *Client) conns() []pooledConn {
+	return *c.pool.Load()
+}
+
+// pick 轮询选择一个连接，优先选择未处于故障状态的连接
+// 空闲的连接会被触发建立连接；所有连接都故障时仍按轮询返回，由 gRPC 等待重连
+func (c *Client) pick() pb.CacheServiceClient {
+	conns := c.conns()
+	n := uint64(len(conns))
+	start := c.next.Add(1)
+
+	for i := uint64(0); i < n; i++ {
+		idx := (start + i) % n
+		switch conns[idx].conn.GetState() {
+		case connectivity.Ready, connectivity.Connecting:
+			return conns[idx].cli
+		case connectivity.Idle:
+			conns[idx].conn.Connect()
+			return conns[idx].cli
+		}
+	}
+	return conns[start%n].cli
+}
+
+// invoke 执行一次 RPC，失败时按重试策略退避重试，每次重试重新选择连接
+func (c *Client) invoke(ctx context.Context, idempotent bool, call func(ctx context.Context, cli pb.CacheServiceClient) error) error {
+	policy := c.opts.retry
+	if !idempotent && policy.RetryOnlyIdempotent {
+		policy.MaxAttempts = 1
+	}
+
+	// 节点间同步的写操作同样携带节点标记，对端据此接受租户命名空间下的 key
+	if ctx.Value("from_peer") != nil {
+		ctx = metadata.AppendToOutgoingContext(ctx, peerMetadataKey, "true")
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := call(ctx, c.pick())
+		if err == nil || attempt >= policy.MaxAttempts || !isRetryableRPCError(err) {
+			return fromStatusError(err)
+		}
+
+		// 等待退避时间，调用方取消时返回最后一次的错误
+		if sleepContext(ctx, policy.backoff(attempt)) != nil {
+			return fromStatusError(err)
+		}
+	}
+}
+
+// withTimeout 为 ctx 设置操作超时，d 为 0 时不设置
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+func (c *Client) Get(ctx context.Context, group, key string) ([]byte, error) {
+	view, err := c.getEntry(ctx, group, key, false)
+	return view.b, err
+}
+
+// localOnlyMetadataKey 标记请求只读取对端的本地缓存，未命中时不加载
+const localOnlyMetadataKey = "x-mycache-local-only"
+
+// getEntry 获取值及其在对端的过期时间和写入时间
+// localOnly 为 true 时只读取对端的本地缓存，未命中时返回 NotFound
+func (c *Client) getEntry(ctx context.Context, group, key string, localOnly bool) (ByteView, error) {
+	ctx, cancel := withTimeout(ctx, c.opts.timeouts.Get)
 	defer cancel()
 
-	resp, err := c.grpcCli.Get(ctx, &pb.Request{
-		Group: group,
-		Key:   key,
+	// 标记请求来自其他节点，对端直接在本地加载而不再转发
+	ctx = metadata.AppendToOutgoingContext(ctx, peerMetadataKey, "true")
+	if localOnly {
+		ctx = metadata.AppendToOutgoingContext(ctx, localOnlyMetadataKey, "true")
+	}
+
+	var resp *pb.ResponseForGet
+	err := c.invoke(ctx, true, func(ctx context.Context, cli pb.CacheServiceClient) (err error) {
+		resp, err = cli.Get(ctx, &pb.Request{
+			Group: group,
+			Key:   key,
+		})
+		return err
 	})
+	if isMessageTooLarge(err) && !localOnly && c.Supports(FeatureStream) {
+		// 值超过消息大小限制时改用流式获取
+		value, err := c.GetStream(ctx, group, key)
+		return ByteView{b: value}, err
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get value from cache: %v", err)
+		return ByteView{}, wrapSizeError("get value from cache", err)
 	}
 
-	return resp.GetValue(), nil
+	view := ByteView{b: resp.GetValue()}
+	if resp.GetExpireAt() != 0 {
+		view.expire = time.Unix(0, resp.GetExpireAt())
+	}
+	if resp.GetWrittenAt() != 0 {
+		view.written = time.Unix(0, resp.GetWrittenAt())
+	}
+	return view, nil
 }
 
-func (c *Client) Delete(group, key string) (bool, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// GetStream 以流式方式获取值，值按块接收，不受单条消息大小的限制
+// Get 遇到超过消息大小限制的值时会自动改用该方法
+func (c *Client) GetStream(ctx context.Context, group, key string) ([]byte, error) {
+	ctx, cancel := withTimeout(ctx, c.opts.timeouts.Get)
 	defer cancel()
 
-	resp, err := c.grpcCli.Delete(ctx, &pb.Request{
-		Group: group,
-		Key:   key,
+	ctx = metadata.AppendToOutgoingContext(ctx, peerMetadataKey, "true")
+
+	var value []byte
+	err := c.invoke(ctx, true, func(ctx context.Context, cli pb.CacheServiceClient) error {
+		stream, err := cli.GetStream(ctx, &pb.Request{
+			Group: group,
+			Key:   key,
+		})
+		if err != nil {
+			return err
+		}
+
+		value = nil
+		for {
+			chunk, err := stream.Recv()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if value == nil {
+				value = make([]byte, 0, chunk.GetTotalSize())
+			}
+			value = append(value, chunk.GetData()...)
+		}
 	})
 	if err != nil {
-		return false, fmt.Errorf("failed to delete value from cache: %v", err)
+		return nil, wrapSizeError("stream value from cache", err)
+	}
+
+	return value, nil
+}
+
+func (c *Client) Delete(ctx context.Context, group, key string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, c.opts.timeouts.Delete)
+	defer cancel()
+
+	var resp *pb.ResponseForDelete
+	err := c.invoke(ctx, true, func(ctx context.Context, cli pb.CacheServiceClient) (err error) {
+		resp, err = cli.Delete(ctx, &pb.Request{
+			Group: group,
+			Key:   key,
+		})
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to delete value from cache: %w", err)
 	}
 
 	return resp.GetValue(), nil
 }
 
 func (c *Client) Set(ctx context.Context, group, key string, value []byte) error {
-	resp, err := c.grpcCli.Set(ctx, &pb.Request{
-		Group: group,
-		Key:   key,
-		Value: value,
+	if err := c.checkValueSize(len(value)); err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx, c.opts.timeouts.Set)
+	defer cancel()
+
+	err := c.invoke(ctx, false, func(ctx context.Context, cli pb.CacheServiceClient) (err error) {
+		_, err = cli.Set(ctx, setRequest(ctx, group, key, value))
+		return err
 	})
 	if err != nil {
-		return fmt.Errorf("failed to set value to cache: %v", err)
+		return wrapSizeError("set value to cache", err)
 	}
-	log.Printf("[Client] grpc set request resp: %+v", resp)
-
 	return nil
 }
 
+// MGet 一次请求获取多个 key，对端获取失败的 key 不出现在结果中
+func (c *Client) MGet(ctx context.Context, group string, keys []string) (map[string][]byte, error) {
+	if !c.Supports(FeatureBatch) {
+		return c.mgetEach(ctx, group, keys)
+	}
+	ctx, cancel := withTimeout(ctx, c.opts.timeouts.Get)
+	defer cancel()
+
+	ctx = metadata.AppendToOutgoingContext(ctx, peerMetadataKey, "true")
+
+	var resp *pb.BatchResponse
+	err := c.invoke(ctx, true, func(ctx context.Context, cli pb.CacheServiceClient) (err error) {
+		resp, err = cli.MGet(ctx, &pb.BatchRequest{
+			Group: group,
+			Keys:  keys,
+		})
+		return err
+	})
+	if err != nil && !c.Supports(FeatureBatch) {
+		// 对端是不支持批量请求的旧版本节点
+		return c.mgetEach(ctx, group, keys)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to mget values from cache: %w", err)
+	}
+
+	values := make(map[string][]byte, len(resp.GetEntries()))
+	for _, entry := range resp.GetEntries() {
+		values[entry.GetKey()] = entry.GetValue()
+	}
+	return values, nil
+}
+
+// MSet 一次请求设置多个 key，部分 key 失败时返回包含各 key 错误的汇总错误
+func (c *Client) MSet(ctx context.Context, group string, entries map[string][]byte) error {
+	if !c.Supports(FeatureBatch) {
+		return c.msetEach(ctx, group, entries)
+	}
+	ctx, cancel := withTimeout(ctx, c.opts.timeouts.Set)
+	defer cancel()
+
+	req := &pb.BatchRequest{Group: group}
+	size := 0
+	for key, value := range entries {
+		size += len(key) + len(value)
+		req.Entries = append(req.Entries, &pb.KeyValue{Key: key, Value: value})
+	}
+
+	if err := c.checkValueSize(size); err != nil {
+		return err
+	}
+
+	var resp *pb.BatchResponse
+	err := c.invoke(ctx, false, func(ctx context.Context, cli pb.CacheServiceClient) (err error) {
+		resp, err = cli.MSet(ctx, req)
+		return err
+	})
+	if err != nil && !c.Supports(FeatureBatch) {
+		return c.msetEach(ctx, group, entries)
+	}
+	if err != nil {
+		return wrapSizeError("mset values to cache", err)
+	}
+	return batchErrors(resp)
+}
+
+// MDelete 一次请求删除多个 key，部分 key 失败时返回包含各 key 错误的汇总错误
+func (c *Client) MDelete(ctx context.Context, group string, keys []string) error {
+	if !c.Supports(FeatureBatch) {
+		return c.mdeleteEach(ctx, group, keys)
+	}
+	ctx, cancel := withTimeout(ctx, c.opts.timeouts.Delete)
+	defer cancel()
+
+	var resp *pb.BatchResponse
+	err := c.invoke(ctx, true, func(ctx context.Context, cli pb.CacheServiceClient) (err error) {
+		resp, err = cli.MDelete(ctx, &pb.BatchRequest{
+			Group: group,
+			Keys:  keys,
+		})
+		return err
+	})
+	if err != nil && !c.Supports(FeatureBatch) {
+		return c.mdeleteEach(ctx, group, keys)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to mdelete values from cache: %w", err)
+	}
+	return batchErrors(resp)
+}
+
+// batchErrors 将批量响应中各 key 的错误合并为一个错误
+func batchErrors(resp *pb.BatchResponse) error {
+	var errs []error
+	for key, msg := range resp.GetErrors() {
+		errs = append(errs, fmt.Errorf("key %s: %s", key, msg))
+	}
+	return errors.Join(errs...)
+}
+
+// Close 关闭连接池中的所有连接
 func (c *Client) Close() error {
-	if c.conn != nil {
-		return c.conn.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
 	}
-	return nil
+	c.closed = true
+	close(c.done)
+
+	var errs []error
+	for _, pc := range c.conns() {
+		if err := pc.conn.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,56 @@
+package mycache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestClient_UnavailablePeerFailsFast(t *testing.T) {
+	// 取得一个没有服务监听的地址
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+
+	var attempts atomic.Int32
+	countAttempts := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		attempts.Add(1)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	client, err := DialNode(addr,
+		WithClientRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond}),
+		WithUnaryInterceptors(countAttempts),
+	)
+	if err != nil {
+		t.Fatalf("DialNode 失败: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, err = client.Get(ctx, "group", "key")
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, ErrPeerUnavailable) {
+		t.Fatalf("节点不可达时应返回 ErrPeerUnavailable，实际为 %v", err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("节点不可达时不应等到超时: %v", err)
+	}
+	if elapsed > time.Second {
+		t.Errorf("节点不可达时应快速失败，实际耗时 %v", elapsed)
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Errorf("应按重试策略尝试 3 次，实际为 %d 次", n)
+	}
+}
@@ -0,0 +1,149 @@
+// mycache-bench 对运行中的集群施加可配置的负载，报告吞吐量、延迟分位数和命中率，用于容量规划
+//
+// 通过静态节点列表或 etcd 发现节点，按与节点相同的一致性哈希将请求直接发往 key 的 owner：
+//
+//	mycache-bench -peers 10.0.0.1:8001,10.0.0.2:8001 -group users \
+//	    -duration 1m -concurrency 64 -read-ratio 0.9 -keys 100000 -dist zipf -value-size 256B-4KB
+//
+//	mycache-bench -etcd localhost:2379 -service my-cache -group users -requests 1000000 -rate 20000
+//
+// 读取返回 NotFound 计为未命中，-preload 在压测前写入全部 key，使命中率反映容量和淘汰而不是冷启动。
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	mycache "github.com/linhx1999/MyCache-Go"
+)
+
+func main() {
+	var (
+		peers       = flag.String("peers", "", "节点地址，逗号分隔")
+		etcd        = flag.String("etcd", "", "etcd 地址，逗号分隔，与 -peers 二选一")
+		service     = flag.String("service", "", "etcd 中注册的服务名称")
+		group       = flag.String("group", "test", "压测的缓存组")
+		duration    = flag.Duration("duration", 30*time.Second, "压测时长，设置 -requests 时忽略")
+		requests    = flag.Int64("requests", 0, "请求总数，0 表示按 -duration 运行")
+		concurrency = flag.Int("concurrency", 16, "并发的 worker 数量")
+		rate        = flag.Float64("rate", 0, "目标总吞吐量（次/秒），0 表示不限制")
+		readRatio   = flag.Float64("read-ratio", 0.9, "读请求的比例，其余为写请求")
+		keys        = flag.Int("keys", 10000, "key 空间的大小")
+		keyPrefix   = flag.String("key-prefix", "bench:", "key 的前缀")
+		dist        = flag.String("dist", "uniform", "key 的分布：uniform 或 zipf")
+		zipfS       = flag.Float64("zipf-s", 1.1, "zipf 分布的参数 s，必须大于 1，越大越集中")
+		valueSize   = flag.String("value-size", "1KB", "写入的值大小，如 1KB 或范围 256B-4KB")
+		timeout     = flag.Duration("timeout", time.Second, "单个请求的超时时间")
+		preload     = flag.Bool("preload", false, "压测前写入全部 key")
+		interval    = flag.Duration("interval", 5*time.Second, "输出进度的间隔，0 表示不输出")
+		token       = flag.String("token", "", "节点启用认证时使用的 token")
+	)
+	flag.Parse()
+
+	wl, err := newWorkload(workloadConfig{
+		keys:      *keys,
+		keyPrefix: *keyPrefix,
+		dist:      *dist,
+		zipfS:     *zipfS,
+		valueSize: *valueSize,
+		readRatio: *readRatio,
+	})
+	if err != nil {
+		log.Fatalf("[Bench] %v", err)
+	}
+	if *concurrency <= 0 {
+		log.Fatalf("[Bench] -concurrency must be positive")
+	}
+
+	picker, err := newPicker(*peers, *etcd, *service, *token)
+	if err != nil {
+		log.Fatalf("[Bench] %v", err)
+	}
+	defer picker.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	if err := waitForPeers(ctx, picker); err != nil {
+		log.Fatalf("[Bench] %v", err)
+	}
+
+	r := &runner{
+		picker:      picker,
+		group:       *group,
+		workload:    wl,
+		concurrency: *concurrency,
+		rate:        *rate,
+		timeout:     *timeout,
+	}
+	if *preload {
+		start := time.Now()
+		n, err := r.preload(ctx)
+		if err != nil {
+			log.Fatalf("[Bench] preload: %v", err)
+		}
+		log.Printf("[Bench] preloaded %d keys in %v", n, time.Since(start).Round(time.Millisecond))
+	}
+
+	if *requests <= 0 {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeout(ctx, *duration)
+		defer stop()
+	}
+	log.Printf("[Bench] running against group [%s] with %d workers, read ratio %.2f, %d keys (%s), value size %s",
+		*group, *concurrency, *readRatio, *keys, *dist, *valueSize)
+
+	report := r.run(ctx, *requests, *interval)
+	report.print(os.Stdout)
+}
+
+// newPicker 按参数创建节点选择器，本进程不加入哈希环，所有 key 都发往远程节点
+func newPicker(peers, etcd, service, token string) (*mycache.ClientPicker, error) {
+	var opts []mycache.PickerOption
+	if service != "" {
+		opts = append(opts, mycache.WithServiceName(service))
+	}
+	if token != "" {
+		opts = append(opts, mycache.WithClientOptions(mycache.WithClientToken(token)))
+	}
+
+	switch {
+	case peers != "" && etcd != "":
+		return nil, errors.New("-peers and -etcd are mutually exclusive")
+	case peers != "":
+		return mycache.NewStaticPicker("", strings.Split(peers, ","), opts...)
+	case etcd != "":
+		opts = append(opts, mycache.WithPickerEtcdEndpoints(strings.Split(etcd, ",")...))
+		return mycache.NewClientPicker("", opts...)
+	default:
+		return nil, errors.New("either -peers or -etcd is required")
+	}
+}
+
+// waitForPeers 等待发现至少一个节点，etcd 发现是异步的
+func waitForPeers(ctx context.Context, picker *mycache.ClientPicker) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if n := len(picker.Peers()); n > 0 {
+			log.Printf("[Bench] discovered %d peers", n)
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("no peers discovered: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
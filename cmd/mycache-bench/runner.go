@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	mycache "github.com/linhx1999/MyCache-Go"
+)
+
+// errNoPeer key 没有可用的节点
+var errNoPeer = errors.New("no peer for key")
+
+// runner 驱动 worker 执行负载并汇总结果
+type runner struct {
+	picker      *mycache.ClientPicker
+	group       string
+	workload    *workload
+	concurrency int
+	rate        float64
+	timeout     time.Duration
+
+	completed atomic.Int64 // 已完成的请求数，用于输出进度
+}
+
+// workerStats 单个 worker 的结果，worker 之间不共享，结束后合并
+type workerStats struct {
+	reads  []time.Duration
+	writes []time.Duration
+	hits   int64
+	misses int64
+	errors map[string]int64 // 按类型统计的错误数
+}
+
+// preload 写入全部 key，返回成功写入的数量，全部失败时返回第一个错误
+func (r *runner) preload(ctx context.Context) (int64, error) {
+	var (
+		wg       sync.WaitGroup
+		loaded   atomic.Int64
+		firstErr error
+		errOnce  sync.Once
+	)
+	for w := 0; w < r.concurrency; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			gen := r.workload.generator(int64(w) + 1)
+			for i := w; i < r.workload.config.keys && ctx.Err() == nil; i += r.concurrency {
+				if err := r.set(ctx, r.workload.key(i), gen.value()); err != nil {
+					errOnce.Do(func() { firstErr = err })
+					continue
+				}
+				loaded.Add(1)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if loaded.Load() == 0 && firstErr != nil {
+		return 0, firstErr
+	}
+	return loaded.Load(), ctx.Err()
+}
+
+// run 执行负载直到 ctx 结束或完成 requests 个请求（requests 不大于 0 时不限制）
+func (r *runner) run(ctx context.Context, requests int64, interval time.Duration) *report {
+	var (
+		wg     sync.WaitGroup
+		issued atomic.Int64
+		stats  = make([]*workerStats, r.concurrency)
+	)
+
+	start := time.Now()
+	stopProgress := r.startProgress(interval)
+	for w := 0; w < r.concurrency; w++ {
+		stats[w] = &workerStats{errors: make(map[string]int64)}
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			r.work(ctx, r.workload.generator(time.Now().UnixNano()+int64(w)), stats[w], func() bool {
+				return requests <= 0 || issued.Add(1) <= requests
+			})
+		}(w)
+	}
+	wg.Wait()
+	stopProgress()
+
+	return newReport(time.Since(start), stats)
+}
+
+// work 单个 worker 的循环，next 返回 false 时结束
+//
+// 限制吞吐量时按固定间隔调度请求，延迟从计划发出的时间算起，服务变慢导致的排队也计入延迟，
+// 避免只统计实际发出的请求而低估尾延迟。
+func (r *runner) work(ctx context.Context, gen *generator, stats *workerStats, next func() bool) {
+	var period time.Duration
+	if r.rate > 0 {
+		period = time.Duration(float64(time.Second) * float64(r.concurrency) / r.rate)
+	}
+	scheduled := time.Now()
+
+	for ctx.Err() == nil && next() {
+		begin := time.Now()
+		if period > 0 {
+			scheduled = scheduled.Add(period)
+			if wait := time.Until(scheduled); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(wait):
+				}
+			}
+			begin = scheduled
+		}
+
+		key, read := gen.next()
+		var err error
+		if read {
+			_, err = r.get(ctx, key)
+		} else {
+			err = r.set(ctx, key, gen.value())
+		}
+		latency := time.Since(begin)
+		if ctx.Err() != nil {
+			// 压测结束时被取消的请求不计入结果
+			return
+		}
+		r.completed.Add(1)
+
+		switch {
+		case read && err == nil:
+			stats.hits++
+		case read && errors.Is(err, mycache.ErrNotFound) && !errors.Is(err, mycache.ErrGroupNotFound):
+			stats.misses++
+		case err != nil:
+			stats.errors[errorKind(err)]++
+			continue
+		}
+		if read {
+			stats.reads = append(stats.reads, latency)
+		} else {
+			stats.writes = append(stats.writes, latency)
+		}
+	}
+}
+
+// get 从 key 的 owner 读取
+func (r *runner) get(ctx context.Context, key string) ([]byte, error) {
+	peer, ok, _ := r.picker.PickPeer(key)
+	if !ok {
+		return nil, errNoPeer
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return peer.Get(ctx, r.group, key)
+}
+
+// set 写入 key 的 owner
+func (r *runner) set(ctx context.Context, key string, value []byte) error {
+	peer, ok, _ := r.picker.PickPeer(key)
+	if !ok {
+		return errNoPeer
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return peer.Set(ctx, r.group, key, value)
+}
+
+// startProgress 每隔 interval 输出一次期间的吞吐量，返回停止输出的函数
+func (r *runner) startProgress(interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		last, lastTime := int64(0), time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				completed := r.completed.Load()
+				log.Printf("[Bench] %d requests, %.0f ops/s", completed, float64(completed-last)/now.Sub(lastTime).Seconds())
+				last, lastTime = completed, now
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// errorKind 返回错误的类型，用于汇总错误
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, errNoPeer):
+		return "no_peer"
+	case errors.Is(err, mycache.ErrGroupNotFound):
+		return "group_not_found"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, mycache.ErrPeerUnavailable):
+		return "unavailable"
+	case errors.Is(err, mycache.ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, mycache.ErrQuotaExceeded):
+		return "quota_exceeded"
+	case errors.Is(err, mycache.ErrUnauthenticated):
+		return "unauthenticated"
+	default:
+		return "other"
+	}
+}
+
+// report 压测结果
+type report struct {
+	elapsed time.Duration
+	reads   []time.Duration // 已排序
+	writes  []time.Duration // 已排序
+	hits    int64
+	misses  int64
+	errors  map[string]int64
+}
+
+// newReport 合并各 worker 的结果
+func newReport(elapsed time.Duration, stats []*workerStats) *report {
+	rep := &report{elapsed: elapsed, errors: make(map[string]int64)}
+	for _, s := range stats {
+		rep.reads = append(rep.reads, s.reads...)
+		rep.writes = append(rep.writes, s.writes...)
+		rep.hits += s.hits
+		rep.misses += s.misses
+		for kind, n := range s.errors {
+			rep.errors[kind] += n
+		}
+	}
+	sort.Slice(rep.reads, func(i, j int) bool { return rep.reads[i] < rep.reads[j] })
+	sort.Slice(rep.writes, func(i, j int) bool { return rep.writes[i] < rep.writes[j] })
+	return rep
+}
+
+// print 输出结果
+func (rep *report) print(w io.Writer) {
+	var errorCount int64
+	for _, n := range rep.errors {
+		errorCount += n
+	}
+	total := int64(len(rep.reads)+len(rep.writes)) + errorCount
+
+	fmt.Fprintf(w, "\nduration:    %v\n", rep.elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "requests:    %d (%d errors)\n", total, errorCount)
+	fmt.Fprintf(w, "throughput:  %.0f ops/s\n", float64(total)/rep.elapsed.Seconds())
+	if lookups := rep.hits + rep.misses; lookups > 0 {
+		fmt.Fprintf(w, "hit ratio:   %.2f%% (%d hits, %d misses)\n", 100*float64(rep.hits)/float64(lookups), rep.hits, rep.misses)
+	}
+
+	if len(rep.reads)+len(rep.writes) > 0 {
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "op\tcount\tavg\tp50\tp90\tp99\tp99.9\tmax\t")
+		printLatency(tw, "get", rep.reads)
+		printLatency(tw, "set", rep.writes)
+		tw.Flush()
+	}
+
+	if errorCount > 0 {
+		kinds := make([]string, 0, len(rep.errors))
+		for kind := range rep.errors {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		fmt.Fprintln(w, "\nerrors:")
+		for _, kind := range kinds {
+			fmt.Fprintf(w, "  %-16s %d\n", kind, rep.errors[kind])
+		}
+	}
+}
+
+// printLatency 输出一种操作的延迟分布，latencies 已排序
+func printLatency(w io.Writer, op string, latencies []time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+	var sum time.Duration
+	for _, l := range latencies {
+		sum += l
+	}
+	fmt.Fprintf(w, "%s\t%d\t%v\t%v\t%v\t%v\t%v\t%v\t\n", op, len(latencies),
+		round(sum/time.Duration(len(latencies))),
+		round(percentile(latencies, 0.50)),
+		round(percentile(latencies, 0.90)),
+		round(percentile(latencies, 0.99)),
+		round(percentile(latencies, 0.999)),
+		round(latencies[len(latencies)-1]))
+}
+
+// percentile 返回已排序的 latencies 的 p 分位数
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	i := int(float64(len(latencies))*p+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(latencies) {
+		i = len(latencies) - 1
+	}
+	return latencies[i]
+}
+
+// round 按量级保留精度，便于阅读
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/linhx1999/MyCache-Go/config"
+)
+
+// workloadConfig 负载的参数
+type workloadConfig struct {
+	keys      int
+	keyPrefix string
+	dist      string
+	zipfS     float64
+	valueSize string
+	readRatio float64
+}
+
+// workload 生成请求的 key、值和读写类型，多个 worker 共享，每个 worker 使用自己的 generator
+type workload struct {
+	config   workloadConfig
+	minValue int
+	maxValue int
+	payload  []byte // 随机字节，值从中截取，避免每次写入都生成
+}
+
+// newWorkload 校验参数并创建负载
+func newWorkload(c workloadConfig) (*workload, error) {
+	if c.keys <= 0 {
+		return nil, fmt.Errorf("-keys must be positive")
+	}
+	if c.readRatio < 0 || c.readRatio > 1 {
+		return nil, fmt.Errorf("-read-ratio must be between 0 and 1")
+	}
+	switch c.dist {
+	case "uniform":
+	case "zipf":
+		if c.zipfS <= 1 {
+			return nil, fmt.Errorf("-zipf-s must be greater than 1")
+		}
+	default:
+		return nil, fmt.Errorf("unknown -dist %q, want uniform or zipf", c.dist)
+	}
+
+	minValue, maxValue, err := parseValueSize(c.valueSize)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := make([]byte, maxValue)
+	rand.New(rand.NewSource(1)).Read(payload)
+	return &workload{config: c, minValue: minValue, maxValue: maxValue, payload: payload}, nil
+}
+
+// parseValueSize 解析 1KB 或 256B-4KB 格式的值大小
+func parseValueSize(s string) (minSize, maxSize int, err error) {
+	lo, hi, isRange := strings.Cut(s, "-")
+	if !isRange {
+		hi = lo
+	}
+	minBytes, err := config.ParseByteSize(lo)
+	if err != nil {
+		return 0, 0, fmt.Errorf("-value-size %q: %v", s, err)
+	}
+	maxBytes, err := config.ParseByteSize(hi)
+	if err != nil {
+		return 0, 0, fmt.Errorf("-value-size %q: %v", s, err)
+	}
+	if minBytes > maxBytes {
+		return 0, 0, fmt.Errorf("-value-size %q: min is larger than max", s)
+	}
+	return int(minBytes), int(maxBytes), nil
+}
+
+// key 返回第 i 个 key
+func (w *workload) key(i int) string {
+	return w.config.keyPrefix + strconv.Itoa(i)
+}
+
+// generator 单个 worker 使用的随机源，不能并发使用
+type generator struct {
+	w    *workload
+	rnd  *rand.Rand
+	zipf *rand.Zipf
+}
+
+// generator 创建使用 seed 的 generator
+func (w *workload) generator(seed int64) *generator {
+	g := &generator{w: w, rnd: rand.New(rand.NewSource(seed))}
+	if w.config.dist == "zipf" {
+		g.zipf = rand.NewZipf(g.rnd, w.config.zipfS, 1, uint64(w.config.keys-1))
+	}
+	return g
+}
+
+// next 返回下一个请求的 key 和是否为读请求
+// zipf 分布下编号越小的 key 越热
+func (g *generator) next() (key string, read bool) {
+	var i int
+	if g.zipf != nil {
+		i = int(g.zipf.Uint64())
+	} else {
+		i = g.rnd.Intn(g.w.config.keys)
+	}
+	return g.w.key(i), g.rnd.Float64() < g.w.config.readRatio
+}
+
+// value 返回随机大小的值，引用共享的 payload，调用方不能修改
+func (g *generator) value() []byte {
+	size := g.w.minValue
+	if g.w.maxValue > g.w.minValue {
+		size += g.rnd.Intn(g.w.maxValue - g.w.minValue + 1)
+	}
+	offset := 0
+	if size < len(g.w.payload) {
+		offset = g.rnd.Intn(len(g.w.payload) - size + 1)
+	}
+	return g.w.payload[offset : offset+size]
+}
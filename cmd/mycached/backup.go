@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	mycache "github.com/linhx1999/MyCache-Go"
+)
+
+// runBackup 执行 backup 子命令：连接一个节点，由它协调整个集群的备份并输出各节点的结果
+// 有节点或组失败时以状态码 1 退出
+func runBackup(args []string) {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	var (
+		addr  = fs.String("addr", "127.0.0.1:8001", "作为协调者的节点地址")
+		dir   = fs.String("dir", "", "备份目录，相对于各节点的备份根目录（backup_root），为空时直接写入根目录")
+		group = fs.String("group", "", "备份的组，为空时备份所有组")
+		token = fs.String("token", "", "节点启用认证时使用的 token")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: mycached backup [-addr host:port] [-dir path] [-group name] [-token token]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var opts []mycache.ClientOption
+	if *token != "" {
+		opts = append(opts, mycache.WithClientToken(*token))
+	}
+	client, err := mycache.DialNode(*addr, opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backup: %v\n", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	var (
+		nodes   = make(map[string]bool)
+		entries int
+		bytes   int64
+		failed  int
+	)
+	manifest, err := client.Backup(ctx, *dir, *group, func(f mycache.BackupFile) {
+		nodes[f.Node] = true
+		if f.Error != "" {
+			failed++
+			fmt.Printf("%-21s %-16s failed: %s\n", f.Node, f.Group, f.Error)
+			return
+		}
+		entries += f.Entries
+		bytes += f.Bytes
+		fmt.Printf("%-21s %-16s %d entries, %d bytes -> %s\n", f.Node, f.Group, f.Entries, f.Bytes, f.Path)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backup: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\n%d nodes: %d entries, %d bytes, %d failed\nmanifest: %s\n", len(nodes), entries, bytes, failed, manifest)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
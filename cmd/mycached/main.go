@@ -0,0 +1,211 @@
+// mycached 独立运行的缓存节点，通过配置文件或命令行参数启动，无需编写 Go 代码
+//
+// 使用配置文件（格式见 config 包）：
+//
+//	mycached -config mycached.yaml
+//
+// 只使用命令行参数：
+//
+//	mycached -addr :8001 -discovery static -peers 10.0.0.1:8001,10.0.0.2:8001 \
+//	    -group users:64MB:10m -group sessions:16MB -origin users=http://api.internal/users
+//
+// 命令行参数会覆盖配置文件中的同名设置。收到 SIGINT 或 SIGTERM 时优雅关闭。
+//
+// 扩缩容后让集群把 key 迁移到新的 owner，并输出各节点的进度：
+//
+//	mycached rebalance -addr 10.0.0.1:8001 [-group users] [-drop]
+//
+// 把各节点负责的缓存项备份到所有节点共享的目录（各节点以 -backup-root 或 backup_root 指定），
+// 新集群通过 restore_dir 在启动时恢复：
+//
+//	mycached backup -addr 10.0.0.1:8001 [-dir daily] [-group users]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/linhx1999/MyCache-Go/config"
+)
+
+// listFlag 可重复指定的命令行参数
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "rebalance":
+			runRebalance(os.Args[2:])
+			return
+		case "backup":
+			runBackup(os.Args[2:])
+			return
+		}
+	}
+
+	var (
+		configPath      = flag.String("config", "", "配置文件路径（YAML）")
+		addr            = flag.String("addr", "", "gRPC 监听地址，如 :8001")
+		advertise       = flag.String("advertise", "", "注册到服务发现的地址")
+		service         = flag.String("service", "", "服务名称")
+		discovery       = flag.String("discovery", "", "服务发现方式：etcd、static、dns 或 none")
+		etcd            = flag.String("etcd", "", "etcd 地址，逗号分隔")
+		peers           = flag.String("peers", "", "static 方式的节点地址，逗号分隔")
+		dnsName         = flag.String("dns", "", "dns 方式解析的名称")
+		httpAddr        = flag.String("http", "", "REST 接口的监听地址")
+		adminAddr       = flag.String("admin", "", "管理接口的监听地址")
+		adminToken      = flag.String("admin-token", "", "访问管理接口需要的 token")
+		metricsAddr     = flag.String("metrics", "", "/metrics 接口的监听地址")
+		backupRoot      = flag.String("backup-root", "", "集群备份写入的根目录，为空时拒绝备份请求")
+		shutdownTimeout = flag.Duration("shutdown-timeout", 30*time.Second, "优雅关闭的最长等待时间")
+		groups          listFlag
+		origins         listFlag
+	)
+	flag.Var(&groups, "group", "缓存组，格式 name[:max_bytes[:ttl]]，可重复指定")
+	flag.Var(&origins, "origin", "组的 HTTP 源站，格式 group=url，可重复指定")
+	flag.Parse()
+
+	cfg := &config.Config{}
+	if *configPath != "" {
+		var err error
+		if cfg, err = config.Load(*configPath); err != nil {
+			log.Fatalf("[MyCache] %v", err)
+		}
+	}
+
+	// 命令行参数覆盖配置文件
+	setString(&cfg.Addr, *addr)
+	setString(&cfg.AdvertiseAddr, *advertise)
+	setString(&cfg.ServiceName, *service)
+	setString(&cfg.Discovery.Type, *discovery)
+	setString(&cfg.Discovery.DNSName, *dnsName)
+	setString(&cfg.HTTPAddr, *httpAddr)
+	setString(&cfg.AdminAddr, *adminAddr)
+	setString(&cfg.AdminToken, *adminToken)
+	setString(&cfg.MetricsAddr, *metricsAddr)
+	setString(&cfg.BackupRoot, *backupRoot)
+	if *etcd != "" {
+		cfg.Etcd.Endpoints = strings.Split(*etcd, ",")
+	}
+	if *peers != "" {
+		cfg.Discovery.Peers = strings.Split(*peers, ",")
+	}
+	if err := applyGroupFlags(cfg, groups, origins); err != nil {
+		log.Fatalf("[MyCache] %v", err)
+	}
+	if len(cfg.Groups) == 0 {
+		log.Fatalf("[MyCache] no groups configured, use -config or -group")
+	}
+
+	node, err := config.Build(cfg, nil)
+	if err != nil {
+		log.Fatalf("[MyCache] %v", err)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- node.Start()
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-errCh:
+		log.Printf("[MyCache] server stopped: %v", err)
+		node.Shutdown(context.Background())
+		os.Exit(1)
+	case sig := <-sigCh:
+		log.Printf("[MyCache] received %v, shutting down", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := node.Shutdown(ctx); err != nil {
+		log.Printf("[MyCache] shutdown: %v", err)
+	}
+}
+
+// setString 参数不为空时覆盖 dst
+func setString(dst *string, v string) {
+	if v != "" {
+		*dst = v
+	}
+}
+
+// applyGroupFlags 将 -group 和 -origin 参数合并到配置中，同名的组覆盖配置文件中的设置
+func applyGroupFlags(cfg *config.Config, groups, origins []string) error {
+	for _, spec := range groups {
+		gc, err := parseGroup(spec)
+		if err != nil {
+			return err
+		}
+		if i := findGroup(cfg, gc.Name); i >= 0 {
+			cfg.Groups[i] = gc
+		} else {
+			cfg.Groups = append(cfg.Groups, gc)
+		}
+	}
+
+	for _, spec := range origins {
+		name, origin, ok := strings.Cut(spec, "=")
+		if !ok || name == "" || origin == "" {
+			return fmt.Errorf("invalid -origin %q, want group=url", spec)
+		}
+		i := findGroup(cfg, name)
+		if i < 0 {
+			return fmt.Errorf("-origin %q: unknown group %q", spec, name)
+		}
+		cfg.Groups[i].Origin = origin
+	}
+	return nil
+}
+
+// parseGroup 解析 name[:max_bytes[:ttl]] 格式的组定义
+func parseGroup(spec string) (config.GroupConfig, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) > 3 || parts[0] == "" {
+		return config.GroupConfig{}, fmt.Errorf("invalid -group %q, want name[:max_bytes[:ttl]]", spec)
+	}
+
+	gc := config.GroupConfig{Name: parts[0]}
+	if len(parts) > 1 && parts[1] != "" {
+		size, err := config.ParseByteSize(parts[1])
+		if err != nil {
+			return gc, fmt.Errorf("-group %q: %v", spec, err)
+		}
+		gc.MaxBytes = size
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		ttl, err := time.ParseDuration(parts[2])
+		if err != nil {
+			return gc, fmt.Errorf("-group %q: %v", spec, err)
+		}
+		gc.TTL = ttl
+	}
+	return gc, nil
+}
+
+// findGroup 返回组在配置中的下标，不存在时返回 -1
+func findGroup(cfg *config.Config, name string) int {
+	for i, gc := range cfg.Groups {
+		if gc.Name == name {
+			return i
+		}
+	}
+	return -1
+}
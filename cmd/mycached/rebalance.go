@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	mycache "github.com/linhx1999/MyCache-Go"
+)
+
+// runRebalance 执行 rebalance 子命令：连接一个节点，由它协调整个集群的再平衡并输出进度
+// 有节点或组失败时以状态码 1 退出
+func runRebalance(args []string) {
+	fs := flag.NewFlagSet("rebalance", flag.ExitOnError)
+	var (
+		addr  = fs.String("addr", "127.0.0.1:8001", "作为协调者的节点地址")
+		group = fs.String("group", "", "再平衡的组，为空时处理所有组")
+		drop  = fs.Bool("drop", false, "迁移成功后从原节点删除")
+		token = fs.String("token", "", "节点启用认证时使用的 token")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: mycached rebalance [-addr host:port] [-group name] [-drop] [-token token]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var opts []mycache.ClientOption
+	if *token != "" {
+		opts = append(opts, mycache.WithClientToken(*token))
+	}
+	client, err := mycache.DialNode(*addr, opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rebalance: %v\n", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	summary, err := client.Rebalance(ctx, *group, *drop, func(p mycache.RebalanceProgress) {
+		switch {
+		case p.Error != "":
+			fmt.Printf("%-21s %-16s failed: %s\n", p.Node, p.Group, p.Error)
+		case p.Done:
+			fmt.Printf("%-21s %-16s done: %d keys, %d stored, %d failed, %d dropped\n", p.Node, p.Group, p.Keys, p.Stored, p.Failed, p.Dropped)
+		default:
+			fmt.Printf("%-21s %-16s %d/%d keys\n", p.Node, p.Group, p.Stored+p.Failed, p.Keys)
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rebalance: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\n%d nodes: %d keys, %d stored, %d failed, %d dropped\n",
+		summary.Nodes, summary.Keys, summary.Stored, summary.Failed, summary.Dropped)
+	if len(summary.Errors) > 0 || summary.Failed > 0 {
+		os.Exit(1)
+	}
+}
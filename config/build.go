@@ -0,0 +1,304 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	mycache "github.com/linhx1999/MyCache-Go"
+	"github.com/linhx1999/MyCache-Go/registry"
+	"github.com/linhx1999/MyCache-Go/store"
+)
+
+// ErrNoDataSource 组在配置中创建且没有提供数据源时，未命中的 key 返回该错误
+// 可通过 errors.Is 与 mycache.ErrNotFound 比较
+var ErrNoDataSource = fmt.Errorf("config: group has no data source: %w", mycache.ErrNotFound)
+
+// Node 由配置创建的缓存节点
+type Node struct {
+	Server *mycache.Server
+	Picker *mycache.ClientPicker     // 服务发现方式为 none 时为 nil
+	Groups map[string]*mycache.Group // 组名到组
+}
+
+// ServerOptions 返回配置对应的服务器选项
+func (c *Config) ServerOptions() []mycache.ServerOption {
+	var opts []mycache.ServerOption
+	if len(c.Etcd.Endpoints) > 0 {
+		opts = append(opts, mycache.WithEtcdEndpoints(c.Etcd.Endpoints))
+	}
+	if c.Etcd.DialTimeout > 0 {
+		opts = append(opts, mycache.WithDialTimeout(c.Etcd.DialTimeout))
+	}
+	if c.Etcd.LeaseTTL > 0 {
+		opts = append(opts, mycache.WithRegisterOptions(registry.WithLeaseTTL(c.Etcd.LeaseTTL)))
+	}
+	if c.Zone != "" {
+		opts = append(opts, mycache.WithRegisterOptions(registry.WithZone(c.Zone)))
+	}
+	if c.Etcd.LoadReportInterval > 0 {
+		opts = append(opts, mycache.WithLoadReport(c.Etcd.LoadReportInterval))
+	}
+	if c.AdvertiseAddr != "" {
+		opts = append(opts, mycache.WithAdvertiseAddr(c.AdvertiseAddr))
+	}
+	switch c.discoveryType() {
+	case DiscoveryEtcd:
+	case DiscoveryFile:
+		opts = append(opts, mycache.WithDiscovery(registry.NewFile(c.fileConfig())))
+	default:
+		opts = append(opts, mycache.WithoutRegistry())
+	}
+	if c.TLS.CertFile != "" {
+		opts = append(opts, mycache.WithTLS(c.TLS.CertFile, c.TLS.KeyFile))
+		if c.TLS.ClientCAFile != "" {
+			opts = append(opts, mycache.WithClientCA(c.TLS.ClientCAFile))
+		}
+	}
+	if c.HTTPAddr != "" {
+		opts = append(opts, mycache.WithHTTPAddr(c.HTTPAddr))
+	}
+	if c.AdminAddr != "" {
+		opts = append(opts, mycache.WithAdminAddr(c.AdminAddr, c.AdminToken))
+	}
+	if c.MetricsAddr != "" {
+		opts = append(opts, mycache.WithMetricsAddr(c.MetricsAddr))
+	}
+	if c.BackupRoot != "" {
+		opts = append(opts, mycache.WithBackupRoot(c.BackupRoot))
+	}
+	if c.MaxMsgSize > 0 {
+		opts = append(opts, mycache.WithMaxMsgSize(int(c.MaxMsgSize), int(c.MaxMsgSize)))
+	}
+	if policy, ok := keyPolicy(c.MaxKeyLength, c.MaxValueSize, c.KeyCharset); ok {
+		opts = append(opts, mycache.WithServerKeyPolicy(policy))
+	}
+	if len(c.AllowedNetworks) > 0 {
+		opts = append(opts, mycache.WithAllowedNetworks(c.AllowedNetworks...))
+	}
+	if len(c.DeniedNetworks) > 0 {
+		opts = append(opts, mycache.WithDeniedNetworks(c.DeniedNetworks...))
+	}
+	return opts
+}
+
+// NewPicker 按服务发现配置创建 ClientPicker，服务发现方式为 none 时返回 nil
+func (c *Config) NewPicker(opts ...mycache.PickerOption) (*mycache.ClientPicker, error) {
+	self := c.selfAddr()
+	if c.ServiceName != "" {
+		opts = append([]mycache.PickerOption{mycache.WithServiceName(c.ServiceName)}, opts...)
+	}
+	if c.TLS.CertFile != "" {
+		// 其他节点同样启用了 TLS，节点间请求出示本节点的证书，满足对端的双向认证
+		caFile := c.TLS.CAFile
+		if caFile == "" {
+			caFile = c.TLS.ClientCAFile
+		}
+		opts = append(opts, mycache.WithClientOptions(
+			mycache.WithClientTLS(c.TLS.CertFile, c.TLS.KeyFile, caFile)))
+	}
+
+	if c.Zone != "" {
+		opts = append(opts, mycache.WithLocalZone(c.Zone, mycache.ZonePolicy{
+			PreferLocalReads: c.ZonePolicy.PreferLocalReads,
+			SpreadReplicas:   c.ZonePolicy.SpreadReplicas,
+		}))
+	}
+	if len(c.Discovery.PeerZones) > 0 {
+		opts = append(opts, mycache.WithPeerZones(c.Discovery.PeerZones))
+	}
+
+	switch c.discoveryType() {
+	case DiscoveryStatic:
+		return mycache.NewStaticPicker(self, c.Discovery.Peers, opts...)
+	case DiscoveryDNS:
+		if c.Discovery.DNSInterval > 0 {
+			opts = append(opts, mycache.WithDNSInterval(c.Discovery.DNSInterval))
+		}
+		return mycache.NewDNSPicker(self, c.Discovery.DNSName, opts...)
+	case DiscoveryFile:
+		return mycache.NewDiscoveryPicker(self, registry.NewFile(c.fileConfig()), opts...)
+	case DiscoveryNone:
+		return nil, nil
+	default:
+		if err := c.applyEtcdConfig(); err != nil {
+			return nil, err
+		}
+		return mycache.NewClientPicker(self, opts...)
+	}
+}
+
+// fileConfig 返回 file 方式的注册配置
+func (c *Config) fileConfig() *registry.FileConfig {
+	config := *registry.DefaultFileConfig
+	if c.Discovery.Dir != "" {
+		config.Dir = c.Discovery.Dir
+	}
+	return &config
+}
+
+// applyEtcdConfig 将 etcd 配置写入 registry.DefaultConfig，NewClientPicker 和服务注册都使用该配置
+func (c *Config) applyEtcdConfig() error {
+	if len(c.Etcd.Endpoints) > 0 {
+		registry.DefaultConfig.Endpoints = c.Etcd.Endpoints
+	}
+	if c.Etcd.DialTimeout > 0 {
+		registry.DefaultConfig.DialTimeout = c.Etcd.DialTimeout
+	}
+	if c.Etcd.KeyPrefix != "" {
+		registry.DefaultConfig.KeyPrefix = c.Etcd.KeyPrefix
+	}
+	registry.DefaultConfig.Username = c.Etcd.Username
+	registry.DefaultConfig.Password = c.Etcd.Password
+	registry.DefaultConfig.Token = c.Etcd.Token
+
+	if t := c.Etcd.TLS; t.CAFile != "" || t.CertFile != "" {
+		config, err := registry.LoadTLSConfig(t.CertFile, t.KeyFile, t.CAFile)
+		if err != nil {
+			return fmt.Errorf("config: %v", err)
+		}
+		registry.DefaultConfig.TLS = config
+	}
+	return nil
+}
+
+// selfAddr 返回本节点在哈希环上的地址
+func (c *Config) selfAddr() string {
+	if c.AdvertiseAddr != "" {
+		return c.AdvertiseAddr
+	}
+	return c.Addr
+}
+
+// GroupOptions 返回组配置对应的组选项
+func (g GroupConfig) GroupOptions() []mycache.GroupOption {
+	cacheOpts := mycache.DefaultCacheOptions()
+	if g.MaxBytes > 0 {
+		cacheOpts.MaxBytes = int64(g.MaxBytes)
+	}
+	if g.Store != "" {
+		cacheOpts.CacheType = store.CacheType(g.Store)
+	}
+	if g.CleanupInterval > 0 {
+		cacheOpts.CleanupTime = g.CleanupInterval
+	}
+	cacheOpts.DiskDir = g.DiskDir
+	cacheOpts.DiskMaxBytes = int64(g.DiskMaxBytes)
+
+	opts := []mycache.GroupOption{mycache.WithCacheOptions(cacheOpts)}
+	if g.TTL > 0 {
+		opts = append(opts, mycache.WithExpiration(g.TTL))
+	}
+	if g.MemoryQuota > 0 {
+		opts = append(opts, mycache.WithMemoryQuota(int64(g.MemoryQuota)))
+	}
+	if g.Replicas > 1 {
+		opts = append(opts, mycache.WithReplicationFactor(g.Replicas))
+	}
+	if policy, ok := keyPolicy(g.MaxKeyLength, g.MaxValueSize, g.KeyCharset); ok {
+		opts = append(opts, mycache.WithKeyPolicy(policy))
+	}
+	if g.SnapshotDir != "" {
+		opts = append(opts, mycache.WithSnapshot(g.SnapshotDir, g.SnapshotInterval))
+	}
+	if g.DoorkeeperWindow > 0 {
+		opts = append(opts, mycache.WithDoorkeeper(g.DoorkeeperWindow, g.DoorkeeperKeys))
+	}
+	if g.TopKeys > 0 {
+		opts = append(opts, mycache.WithTopKeys(g.TopKeys))
+	}
+	if g.RestoreDir != "" {
+		opts = append(opts, mycache.WithRestore(g.RestoreDir))
+	}
+	if g.EncryptionKeyEnv != "" {
+		opts = append(opts, mycache.WithEncryption(mycache.EnvKey(g.EncryptionKeyEnv)))
+	}
+	return opts
+}
+
+// keyCharsets key_charset 可选的取值
+var keyCharsets = map[string]func(rune) bool{
+	"":          nil,
+	"printable": mycache.PrintableASCII,
+}
+
+// keyPolicy 由配置生成 KeyPolicy，没有任何限制时返回 false
+func keyPolicy(maxKeyLength int, maxValueSize ByteSize, charset string) (mycache.KeyPolicy, bool) {
+	policy := mycache.KeyPolicy{
+		MaxKeyLength: maxKeyLength,
+		MaxValueSize: int(maxValueSize),
+		KeyCharset:   keyCharsets[charset],
+	}
+	return policy, maxKeyLength > 0 || maxValueSize > 0 || policy.KeyCharset != nil
+}
+
+// Build 按配置创建服务器、节点选择器和所有组，组注册到服务器上对外提供
+//
+// sources 为组名到数据源的映射；不在其中的组使用配置的 origin 作为数据源，
+// 也没有配置 origin 的组只作为缓存使用，未命中时返回 ErrNoDataSource。
+// extra 追加在配置生成的选项之后，可覆盖配置中的设置。返回的节点需调用 Start 启动。
+func Build(c *Config, sources map[string]mycache.DataSource, extra ...mycache.ServerOption) (*Node, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	picker, err := c.NewPicker()
+	if err != nil {
+		return nil, fmt.Errorf("config: failed to create picker: %w", err)
+	}
+
+	server, err := mycache.NewServer(c.Addr, c.ServiceName, append(c.ServerOptions(), extra...)...)
+	if err != nil {
+		if picker != nil {
+			picker.Close()
+		}
+		return nil, fmt.Errorf("config: failed to create server: %w", err)
+	}
+
+	node := &Node{Server: server, Picker: picker, Groups: make(map[string]*mycache.Group, len(c.Groups))}
+	for _, gc := range c.Groups {
+		source, ok := sources[gc.Name]
+		switch {
+		case ok:
+		case gc.Origin != "":
+			if source, err = NewHTTPOrigin(gc.Origin, gc.OriginTimeout); err != nil {
+				node.Shutdown(context.Background())
+				return nil, err
+			}
+		default:
+			source = noDataSource
+		}
+
+		opts := gc.GroupOptions()
+		if picker != nil {
+			opts = append(opts, mycache.WithPeers(picker))
+		}
+		group := mycache.NewGroup(gc.Name, int64(gc.MaxBytes), source, opts...)
+		server.RegisterGroup(group)
+		node.Groups[gc.Name] = group
+	}
+	return node, nil
+}
+
+// noDataSource 没有数据源的组使用的 DataSource
+var noDataSource = mycache.DataSourceFunc(func(ctx context.Context, key string) ([]byte, error) {
+	return nil, ErrNoDataSource
+})
+
+// Start 启动服务器，阻塞直到服务器停止
+func (n *Node) Start() error {
+	log.Printf("[MyCache] starting node with %d groups", len(n.Groups))
+	return n.Server.Start()
+}
+
+// Shutdown 优雅关闭服务器，然后关闭节点选择器和所有组
+func (n *Node) Shutdown(ctx context.Context) error {
+	err := n.Server.Shutdown(ctx)
+	if n.Picker != nil {
+		n.Picker.Close()
+	}
+	for _, group := range n.Groups {
+		group.Close()
+	}
+	return err
+}
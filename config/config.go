@@ -0,0 +1,308 @@
+// Package config 从 YAML 配置文件创建缓存节点（Server、PeerPicker 和 Group），
+// 部署时无需编写 Go 代码即可启动节点。JSON 是 YAML 的子集，同样可以使用。
+//
+// 配置示例：
+//
+//	addr: ":8001"
+//	service_name: my-cache
+//	etcd:
+//	  endpoints: ["etcd1:2379", "etcd2:2379"]
+//	  dial_timeout: 5s
+//	discovery:
+//	  type: etcd          # etcd（默认）、static、dns 或 none
+//	tls:
+//	  cert_file: server.pem
+//	  key_file: server-key.pem
+//	http_addr: ":9001"
+//	groups:
+//	  - name: users
+//	    max_bytes: 64MB
+//	    ttl: 10m
+//	    store: lru2
+//	    origin: http://api.internal/users   # 可选，未命中时透传到 HTTP 源站
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linhx1999/MyCache-Go/store"
+	"gopkg.in/yaml.v3"
+)
+
+// 服务发现方式
+const (
+	DiscoveryEtcd   = "etcd"   // 通过 etcd 注册和发现节点
+	DiscoveryStatic = "static" // 使用 discovery.peers 中的固定节点列表
+	DiscoveryDNS    = "dns"    // 通过 discovery.dns_name 的 DNS 记录发现节点
+	DiscoveryNone   = "none"   // 单节点，不与其他节点通信
+	DiscoveryFile   = "file"   // 通过 discovery.dir 目录中的文件注册和发现节点，用于本地开发
+)
+
+// Config 节点配置
+type Config struct {
+	Addr          string `yaml:"addr"`           // gRPC 监听地址
+	AdvertiseAddr string `yaml:"advertise_addr"` // 注册到服务发现的地址，为空时使用 addr
+	ServiceName   string `yaml:"service_name"`   // 服务名称
+
+	Zone       string           `yaml:"zone"`        // 本节点所在的机房（可用区），注册到 etcd 并用于就近读取
+	ZonePolicy ZonePolicyConfig `yaml:"zone_policy"` // 多机房部署时选择副本的策略
+
+	Etcd      EtcdConfig      `yaml:"etcd"`
+	Discovery DiscoveryConfig `yaml:"discovery"`
+	TLS       TLSConfig       `yaml:"tls"`
+
+	HTTPAddr    string `yaml:"http_addr"`    // REST 接口的监听地址，为空表示不启用
+	AdminAddr   string `yaml:"admin_addr"`   // 管理接口的监听地址，为空表示不启用
+	AdminToken  string `yaml:"admin_token"`  // 访问管理接口需要的 token
+	MetricsAddr string `yaml:"metrics_addr"` // /metrics 接口的监听地址，为空表示不启用
+	BackupRoot  string `yaml:"backup_root"`  // 集群备份写入的根目录，为空时拒绝备份请求，见 mycache.WithBackupRoot
+
+	MaxMsgSize ByteSize `yaml:"max_msg_size"` // 接收消息的大小上限，0 使用默认值
+
+	// 所有组共用的 key 和 value 限制，见 mycache.WithServerKeyPolicy
+	MaxKeyLength int      `yaml:"max_key_length"` // key 的最大字节数，0 表示不限制
+	MaxValueSize ByteSize `yaml:"max_value_size"` // value 的最大字节数，0 表示不限制
+	KeyCharset   string   `yaml:"key_charset"`    // key 允许的字符：printable 或为空（不限制）
+
+	AllowedNetworks []string `yaml:"allowed_networks"` // 只接受来自这些网段的连接，为空表示不限制
+	DeniedNetworks  []string `yaml:"denied_networks"`  // 拒绝来自这些网段的连接
+
+	Groups []GroupConfig `yaml:"groups"`
+}
+
+// EtcdConfig etcd 连接配置
+type EtcdConfig struct {
+	Endpoints   []string      `yaml:"endpoints"`
+	DialTimeout time.Duration `yaml:"dial_timeout"`
+	LeaseTTL    time.Duration `yaml:"lease_ttl"`  // 注册租约时间，0 使用默认值 10s
+	KeyPrefix   string        `yaml:"key_prefix"` // 服务实例 key 的前缀，如 /prod/services，为空使用 /services
+	Username    string        `yaml:"username"`   // 启用认证的集群需要，与 password 一起使用
+	Password    string        `yaml:"password"`
+	Token       string        `yaml:"token"` // 预先签发的认证 token，与用户名密码二选一
+	TLS         EtcdTLSConfig `yaml:"tls"`
+
+	// LoadReportInterval 发布本节点负载的间隔，其他节点读取副本时避开过载的节点，0 表示不发布
+	LoadReportInterval time.Duration `yaml:"load_report_interval"`
+}
+
+// EtcdTLSConfig 连接 etcd 的 TLS 配置，ca_file 和 cert_file 都为空表示明文连接
+type EtcdTLSConfig struct {
+	CertFile string `yaml:"cert_file"` // 客户端证书，etcd 要求双向认证时需要
+	KeyFile  string `yaml:"key_file"`
+	CAFile   string `yaml:"ca_file"` // 校验 etcd 证书的 CA，为空时使用系统根证书
+}
+
+// ZonePolicyConfig 副本选择策略，见 mycache.ZonePolicy
+type ZonePolicyConfig struct {
+	PreferLocalReads bool `yaml:"prefer_local_reads"` // 优先从同机房的副本读取
+	SpreadReplicas   bool `yaml:"spread_replicas"`    // 副本尽量分布在不同机房，所有节点需一致
+}
+
+// DiscoveryConfig 服务发现配置
+type DiscoveryConfig struct {
+	Type        string        `yaml:"type"`         // etcd、static、dns、file 或 none，为空时使用 etcd
+	Peers       []string      `yaml:"peers"`        // static 方式的节点地址列表
+	DNSName     string        `yaml:"dns_name"`     // dns 方式解析的名称，见 mycache.NewDNSPicker
+	DNSInterval time.Duration `yaml:"dns_interval"` // dns 方式的解析间隔
+	Dir         string        `yaml:"dir"`          // file 方式的注册目录，为空时使用 registry.DefaultFileConfig.Dir
+
+	// PeerZones 节点地址到所在机房的映射，用于 static 和 dns 方式；etcd 方式从注册信息中获取
+	PeerZones map[string]string `yaml:"peer_zones"`
+}
+
+// TLSConfig TLS 配置，cert_file 为空表示不启用
+type TLSConfig struct {
+	CertFile     string `yaml:"cert_file"`
+	KeyFile      string `yaml:"key_file"`
+	ClientCAFile string `yaml:"client_ca_file"` // 设置后要求客户端出示证书
+	CAFile       string `yaml:"ca_file"`        // 校验其他节点证书的 CA，为空时使用 client_ca_file，都为空时使用系统根证书
+}
+
+// GroupConfig 缓存组配置
+type GroupConfig struct {
+	Name            string        `yaml:"name"`
+	MaxBytes        ByteSize      `yaml:"max_bytes"`        // 本地缓存的容量
+	TTL             time.Duration `yaml:"ttl"`              // 过期时间，0 表示永不过期
+	Store           string        `yaml:"store"`            // 存储类型：lru、lru2 或 arena，为空时使用 lru2
+	CleanupInterval time.Duration `yaml:"cleanup_interval"` // 过期项的清理间隔
+	MemoryQuota     ByteSize      `yaml:"memory_quota"`     // 组内存配额，0 表示不限制
+	Replicas        int           `yaml:"replicas"`         // 副本数量（包含 owner），不大于 1 表示不复制
+	Origin          string        `yaml:"origin"`           // 未命中时透传的 HTTP 源站，见 NewHTTPOrigin
+	OriginTimeout   time.Duration `yaml:"origin_timeout"`   // 访问源站的超时时间
+	MaxKeyLength    int           `yaml:"max_key_length"`   // key 的最大字节数，0 表示不限制
+	MaxValueSize    ByteSize      `yaml:"max_value_size"`   // value 的最大字节数，0 表示不限制
+	KeyCharset      string        `yaml:"key_charset"`      // key 允许的字符：printable 或为空（不限制）
+
+	DiskDir          string        `yaml:"disk_dir"`          // 磁盘层目录，设置后内存淘汰的缓存项写入磁盘，见 mycache.CacheOptions.DiskDir
+	DiskMaxBytes     ByteSize      `yaml:"disk_max_bytes"`    // 磁盘层的容量，0 使用 1GB
+	SnapshotDir      string        `yaml:"snapshot_dir"`      // 快照目录，设置后启动时从快照恢复并定期写入，见 mycache.WithSnapshot
+	SnapshotInterval time.Duration `yaml:"snapshot_interval"` // 定期写入快照的周期，0 表示只在关闭时写入
+	RestoreDir       string        `yaml:"restore_dir"`       // 集群备份目录（包含 manifest.json），设置后启动时恢复本节点负责的缓存项，见 mycache.WithRestore
+
+	DoorkeeperWindow time.Duration `yaml:"doorkeeper_window"` // 准入过滤的窗口，设置后加载的值在窗口内第二次访问时才缓存，见 mycache.WithDoorkeeper
+	DoorkeeperKeys   int           `yaml:"doorkeeper_keys"`   // 每个窗口预期的不同 key 数量，0 使用 100000
+	TopKeys          int           `yaml:"top_keys"`          // 热点 key 统计跟踪的 key 数量，0 表示不统计，见 mycache.WithTopKeys
+
+	EncryptionKeyEnv string `yaml:"encryption_key_env"` // 保存 AES 密钥（hex 或 base64）的环境变量名，设置后加密本地缓存和快照中的值，见 mycache.WithEncryption
+}
+
+// Load 读取并解析配置文件
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	cfg, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w (%s)", err, path)
+	}
+	return cfg, nil
+}
+
+// Parse 解析 YAML 配置，未知的字段视为错误，并检查配置是否有效
+func Parse(data []byte) (*Config, error) {
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("config: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate 检查配置是否有效
+func (c *Config) Validate() error {
+	if c.Addr == "" {
+		return fmt.Errorf("config: addr is required")
+	}
+
+	switch c.Discovery.Type {
+	case "", DiscoveryEtcd, DiscoveryNone, DiscoveryFile:
+	case DiscoveryStatic:
+		if len(c.Discovery.Peers) == 0 {
+			return fmt.Errorf("config: discovery.peers is required for static discovery")
+		}
+	case DiscoveryDNS:
+		if c.Discovery.DNSName == "" {
+			return fmt.Errorf("config: discovery.dns_name is required for dns discovery")
+		}
+	default:
+		return fmt.Errorf("config: unknown discovery type %q", c.Discovery.Type)
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return fmt.Errorf("config: tls.cert_file and tls.key_file must be set together")
+	}
+	if (c.Etcd.TLS.CertFile == "") != (c.Etcd.TLS.KeyFile == "") {
+		return fmt.Errorf("config: etcd.tls.cert_file and etcd.tls.key_file must be set together")
+	}
+	if c.Etcd.Token != "" && c.Etcd.Username != "" {
+		return fmt.Errorf("config: etcd.token and etcd.username are mutually exclusive")
+	}
+	if err := validateKeyPolicy(c.MaxKeyLength, c.MaxValueSize, c.KeyCharset); err != nil {
+		return fmt.Errorf("config: %v", err)
+	}
+	if c.Zone == "" && (c.ZonePolicy.PreferLocalReads || c.ZonePolicy.SpreadReplicas) {
+		return fmt.Errorf("config: zone_policy requires zone")
+	}
+
+	seen := make(map[string]bool)
+	for i, g := range c.Groups {
+		if g.Name == "" {
+			return fmt.Errorf("config: groups[%d].name is required", i)
+		}
+		if seen[g.Name] {
+			return fmt.Errorf("config: duplicate group %q", g.Name)
+		}
+		seen[g.Name] = true
+
+		switch store.CacheType(g.Store) {
+		case "", store.LRU, store.LRU2, store.Arena:
+		default:
+			return fmt.Errorf("config: group %q: unknown store %q", g.Name, g.Store)
+		}
+		if err := validateKeyPolicy(g.MaxKeyLength, g.MaxValueSize, g.KeyCharset); err != nil {
+			return fmt.Errorf("config: group %q: %v", g.Name, err)
+		}
+		if g.MaxBytes < 0 || g.MemoryQuota < 0 || g.TTL < 0 || g.Replicas < 0 ||
+			g.DoorkeeperWindow < 0 || g.DoorkeeperKeys < 0 || g.TopKeys < 0 {
+			return fmt.Errorf("config: group %q: sizes, durations and counts must not be negative", g.Name)
+		}
+		if g.Origin != "" {
+			if u, err := url.Parse(g.Origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return fmt.Errorf("config: group %q: invalid origin %q", g.Name, g.Origin)
+			}
+		}
+	}
+	return nil
+}
+
+// discoveryType 返回服务发现方式，未设置时为 etcd
+func (c *Config) discoveryType() string {
+	if c.Discovery.Type == "" {
+		return DiscoveryEtcd
+	}
+	return c.Discovery.Type
+}
+
+// ByteSize 字节数，配置中可以写整数或带单位的字符串，如 "512KB"、"64MB"、"1GB"（按 1024 进制）
+type ByteSize int64
+
+// byteUnits 按后缀长度从长到短排列，避免 "MB" 被当作 "B" 匹配
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30},
+	{"B", 1},
+}
+
+// UnmarshalYAML 解析整数或带单位的字节数
+func (b *ByteSize) UnmarshalYAML(value *yaml.Node) error {
+	n, err := ParseByteSize(value.Value)
+	if err != nil {
+		return err
+	}
+	*b = n
+	return nil
+}
+
+// ParseByteSize 解析整数或带单位的字节数，如 "1048576"、"64MB"
+func ParseByteSize(s string) (ByteSize, error) {
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	for _, unit := range byteUnits {
+		if num, ok := strings.CutSuffix(upper, unit.suffix); ok {
+			n, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid byte size %q", s)
+			}
+			return ByteSize(n * float64(unit.size)), nil
+		}
+	}
+	n, err := strconv.ParseInt(upper, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	return ByteSize(n), nil
+}
+
+// validateKeyPolicy 检查 key 和 value 限制的配置
+func validateKeyPolicy(maxKeyLength int, maxValueSize ByteSize, charset string) error {
+	if maxKeyLength < 0 || maxValueSize < 0 {
+		return fmt.Errorf("max_key_length and max_value_size must not be negative")
+	}
+	if _, ok := keyCharsets[charset]; !ok {
+		return fmt.Errorf("unknown key_charset %q", charset)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParse 测试解析完整的配置
+func TestParse(t *testing.T) {
+	data := `
+addr: ":8001"
+service_name: my-cache
+etcd:
+  endpoints: ["etcd1:2379", "etcd2:2379"]
+  dial_timeout: 3s
+discovery:
+  type: static
+  peers: ["10.0.0.1:8001", "10.0.0.2:8001"]
+max_msg_size: 16MB
+groups:
+  - name: users
+    max_bytes: 64MB
+    ttl: 10m
+    store: lru
+  - name: sessions
+    max_bytes: 1048576
+`
+	cfg, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	if cfg.Addr != ":8001" || cfg.ServiceName != "my-cache" {
+		t.Errorf("addr/service = %q/%q", cfg.Addr, cfg.ServiceName)
+	}
+	if len(cfg.Etcd.Endpoints) != 2 || cfg.Etcd.DialTimeout != 3*time.Second {
+		t.Errorf("etcd = %+v", cfg.Etcd)
+	}
+	if cfg.Discovery.Type != DiscoveryStatic || len(cfg.Discovery.Peers) != 2 {
+		t.Errorf("discovery = %+v", cfg.Discovery)
+	}
+	if cfg.MaxMsgSize != 16<<20 {
+		t.Errorf("max_msg_size = %d; want %d", cfg.MaxMsgSize, 16<<20)
+	}
+	if len(cfg.Groups) != 2 {
+		t.Fatalf("groups = %d; want 2", len(cfg.Groups))
+	}
+	if g := cfg.Groups[0]; g.MaxBytes != 64<<20 || g.TTL != 10*time.Minute || g.Store != "lru" {
+		t.Errorf("groups[0] = %+v", g)
+	}
+	if g := cfg.Groups[1]; g.MaxBytes != 1<<20 {
+		t.Errorf("groups[1].max_bytes = %d; want %d", g.MaxBytes, 1<<20)
+	}
+}
+
+// TestParseInvalid 测试无效的配置返回错误
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"missing addr", `groups: [{name: a}]`, "addr is required"},
+		{"unknown field", "addr: \":8001\"\nport: 8001", "port"},
+		{"static without peers", "addr: \":8001\"\ndiscovery: {type: static}", "discovery.peers"},
+		{"unknown discovery", "addr: \":8001\"\ndiscovery: {type: zk}", "unknown discovery"},
+		{"duplicate group", "addr: \":8001\"\ngroups: [{name: a}, {name: a}]", "duplicate group"},
+		{"unknown store", "addr: \":8001\"\ngroups: [{name: a, store: arc}]", "unknown store"},
+		{"unknown charset", "addr: \":8001\"\nkey_charset: utf8", "unknown key_charset"},
+		{"etcd token and username", "addr: \":8001\"\netcd: {username: a, token: t}", "mutually exclusive"},
+		{"bad size", "addr: \":8001\"\ngroups: [{name: a, max_bytes: 12XB}]", "invalid byte size"},
+		{"tls without key", "addr: \":8001\"\ntls: {cert_file: a.pem}", "must be set together"},
+		{"zone policy without zone", "addr: \":8001\"\nzone_policy: {prefer_local_reads: true}", "requires zone"},
+		{"negative doorkeeper keys", "addr: \":8001\"\ngroups: [{name: a, doorkeeper_window: 1m, doorkeeper_keys: -1}]", "must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Parse error = %v; want containing %q", err, tt.want)
+			}
+		})
+	}
+}
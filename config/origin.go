@@ -0,0 +1,77 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	mycache "github.com/linhx1999/MyCache-Go"
+)
+
+const (
+	// defaultOriginTimeout 访问 HTTP 源站的默认超时时间
+	defaultOriginTimeout = 10 * time.Second
+	// maxOriginBodySize 源站响应体的大小上限
+	maxOriginBodySize = 64 << 20 // 64MB
+)
+
+// ErrOriginNotFound HTTP 源站返回 404，可通过 errors.Is 与 mycache.ErrNotFound 比较
+var ErrOriginNotFound = fmt.Errorf("config: origin returned 404: %w", mycache.ErrNotFound)
+
+// NewHTTPOrigin 返回从 HTTP 源站加载数据的 DataSource，缓存未命中时透传到源站
+//
+// origin 中包含 "{key}" 时替换为转义后的 key，否则在末尾追加 "/<key>"，
+// 如 "http://api.internal/users" 加载 key "42" 时请求 "http://api.internal/users/42"。
+// 源站返回 200 时使用响应体作为值，404 时返回 ErrOriginNotFound，其他状态码返回错误。
+// timeout 为 0 时使用 10s。
+func NewHTTPOrigin(origin string, timeout time.Duration) (mycache.DataSource, error) {
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("config: invalid origin %q", origin)
+	}
+	if timeout <= 0 {
+		timeout = defaultOriginTimeout
+	}
+
+	client := &http.Client{Timeout: timeout}
+	return mycache.DataSourceFunc(func(ctx context.Context, key string) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, originURL(origin, key), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("config: origin request failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusNotFound:
+			return nil, ErrOriginNotFound
+		case resp.StatusCode != http.StatusOK:
+			return nil, fmt.Errorf("config: origin returned %s", resp.Status)
+		}
+
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxOriginBodySize+1))
+		if err != nil {
+			return nil, fmt.Errorf("config: failed to read origin response: %w", err)
+		}
+		if len(body) > maxOriginBodySize {
+			return nil, fmt.Errorf("config: origin response exceeds %d bytes", maxOriginBodySize)
+		}
+		return body, nil
+	}), nil
+}
+
+// originURL 返回加载 key 时请求的地址
+func originURL(origin, key string) string {
+	escaped := url.PathEscape(key)
+	if strings.Contains(origin, "{key}") {
+		return strings.ReplaceAll(origin, "{key}", escaped)
+	}
+	return strings.TrimSuffix(origin, "/") + "/" + escaped
+}
@@ -0,0 +1,104 @@
+package mycache
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	pb "github.com/linhx1999/MyCache-Go/pb"
+	"google.golang.org/grpc"
+)
+
+const (
+	connDrainTimeout   = 30 * time.Second // 轮换后旧连接等待正在进行的请求完成的时间
+	minRotateInterval  = time.Second      // 检查连接是否需要轮换的最短间隔
+	maxConnAgeJitterPc = 10               // 连接最长使用时间的随机抖动比例（百分比）
+)
+
+// pooledConn 连接池中的一个连接
+type pooledConn struct {
+	conn    *grpc.ClientConn
+	cli     pb.CacheServiceClient
+	expires time.Time // 需要轮换的时间，未启用轮换时为零值
+}
+
+// dial 建立一个新的连接（lazy，首次请求时才真正连接）
+func (c *Client) dial() (pooledConn, error) {
+	conn, err := grpc.NewClient(c.addr, c.dialOpts...)
+	if err != nil {
+		return pooledConn{}, fmt.Errorf("failed to dial server: %v", err)
+	}
+
+	pc := pooledConn{conn: conn, cli: pb.NewCacheServiceClient(conn)}
+	if age := c.opts.maxConnAge; age > 0 {
+		// 加入随机抖动，避免同时建立的连接同时轮换
+		jitter := time.Duration(rand.Int63n(int64(age)*maxConnAgeJitterPc/100 + 1))
+		pc.expires = time.Now().Add(age + jitter)
+	}
+	return pc, nil
+}
+
+// rotateConns 定期用新连接替换超过最长使用时间的连接，直到客户端关闭
+func (c *Client) rotateConns() {
+	interval := c.opts.maxConnAge / 4
+	if interval < minRotateInterval {
+		interval = minRotateInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.rotateExpired()
+		}
+	}
+}
+
+// rotateExpired 替换已到期的连接，旧连接延迟关闭以便正在进行的请求完成
+func (c *Client) rotateExpired() {
+	now := time.Now()
+	old := c.conns()
+	pool := make([]pooledConn, len(old))
+	copy(pool, old)
+
+	var retired []*grpc.ClientConn
+	for i, pc := range pool {
+		if now.Before(pc.expires) {
+			continue
+		}
+		fresh, err := c.dial()
+		if err != nil {
+			log.Printf("[Client] failed to rotate connection to %s: %v", c.addr, err)
+			continue
+		}
+		pool[i] = fresh
+		retired = append(retired, pc.conn)
+	}
+	if len(retired) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		// 客户端已关闭，新建的连接不再使用
+		for i, pc := range pool {
+			if pc.conn != old[i].conn {
+				pc.conn.Close()
+			}
+		}
+		return
+	}
+	c.pool.Store(&pool)
+	c.mu.Unlock()
+
+	time.AfterFunc(connDrainTimeout, func() {
+		for _, conn := range retired {
+			conn.Close()
+		}
+	})
+}
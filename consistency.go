@@ -0,0 +1,189 @@
+package mycache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrConsistencyNotMet 成功响应的副本数未达到要求的一致性级别
+var ErrConsistencyNotMet = errors.New("cache: consistency level not met")
+
+// ConsistencyLevel 读写操作需要成功响应的副本数量
+type ConsistencyLevel int
+
+const (
+	ConsistencyOne    ConsistencyLevel = iota // 一个副本成功即可（默认）
+	ConsistencyQuorum                         // 超过半数副本成功
+	ConsistencyAll                            // 所有副本都成功
+)
+
+// String 返回一致性级别的名称
+func (l ConsistencyLevel) String() string {
+	switch l {
+	case ConsistencyOne:
+		return "ONE"
+	case ConsistencyQuorum:
+		return "QUORUM"
+	case ConsistencyAll:
+		return "ALL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// required 返回 total 个副本中需要成功的数量
+func (l ConsistencyLevel) required(total int) int {
+	switch l {
+	case ConsistencyQuorum:
+		return total/2 + 1
+	case ConsistencyAll:
+		return total
+	default:
+		return 1
+	}
+}
+
+// WithConsistency 设置组的副本数和读写一致性级别
+//
+// 副本为哈希环上 key 的前 replicas 个节点（见 ReplicaPicker）。读一致性高于 ONE 时，
+// Get 不使用本地缓存，而是并发读取各副本，收到足够的成功响应后返回写入时间最新的值；
+// 写一致性高于 ONE 时，Set/Delete 同步写入各副本，成功数不足时返回 ErrConsistencyNotMet
+// （本节点已写入的数据不会回滚）。ONE 保持默认行为：读本地缓存，写操作异步同步到 owner。
+func WithConsistency(replicas int, read, write ConsistencyLevel) GroupOption {
+	return func(g *Group) {
+		g.replicas = replicas
+		g.readConsistency = read
+		g.writeConsistency = write
+	}
+}
+
+// replicaSet 返回 key 的远程副本节点，以及本节点是否也是副本之一
+// 远程副本不足 replicas 个时，说明本节点在副本范围内或集群节点数不足，本节点计为一个副本
+func (g *Group) replicaSet(key string) ([]Peer, bool, bool) {
+	replicaPicker, ok := g.peers.(ReplicaPicker)
+	if !ok || g.replicas < 1 {
+		return nil, false, false
+	}
+	remote := replicaPicker.PickPeers(key, g.replicas)
+	return remote, len(remote) < g.replicas, true
+}
+
+// getConsistent 按读一致性级别从各副本读取，返回写入时间最新的值
+func (g *Group) getConsistent(ctx context.Context, key string) (loadResult, bool, error) {
+	remote, selfReplica, ok := g.replicaSet(key)
+	if !ok {
+		return loadResult{}, false, nil
+	}
+
+	total := len(remote)
+	if selfReplica {
+		total++
+	}
+	required := g.readConsistency.required(total)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan loadResult, total)
+	errs := make(chan error, total)
+	for _, peer := range remote {
+		go func(peer Peer) {
+			view, err := g.fetchFromPeer(ctx, peer, key)
+			if err != nil {
+				errs <- err
+				return
+			}
+			results <- loadResult{view: view, source: SourcePeer}
+		}(peer)
+	}
+	if selfReplica {
+		go func() {
+			if view, ok := g.localCache.Get(ctx, key); ok {
+				results <- loadResult{view: view, source: SourceLocal}
+				return
+			}
+			result, err := g.load(ctx, key, g.singleFlightLoader, func(ctx context.Context) (interface{}, error) {
+				return g.loadFromDataSource(ctx, key)
+			})
+			if err != nil {
+				errs <- err
+				return
+			}
+			results <- result
+		}()
+	}
+
+	var (
+		newest    loadResult
+		succeeded int
+		lastErr   error
+	)
+	for received := 0; received < total; received++ {
+		select {
+		case result := <-results:
+			if succeeded == 0 || result.view.written.After(newest.view.written) {
+				newest = result
+			}
+			succeeded++
+			if succeeded >= required {
+				return newest, true, nil
+			}
+		case err := <-errs:
+			lastErr = err
+		case <-ctx.Done():
+			return loadResult{}, true, ctx.Err()
+		}
+	}
+
+	g.stats.consistencyErrors.Add(1)
+	return loadResult{}, true, fmt.Errorf("%w: read %s got %d/%d: %v", ErrConsistencyNotMet, g.readConsistency, succeeded, required, lastErr)
+}
+
+// writeConsistent 按写一致性级别同步写入各远程副本，本节点的写入由调用方完成
+func (g *Group) writeConsistent(ctx context.Context, op string, key string, value []byte) (bool, error) {
+	remote, selfReplica, ok := g.replicaSet(key)
+	if !ok {
+		return false, nil
+	}
+
+	total := len(remote)
+	succeeded := 0
+	if selfReplica {
+		total++
+		succeeded++
+	}
+	required := g.writeConsistency.required(total)
+
+	// 标记为节点间同步，对端不会再次转发
+	syncCtx := context.WithValue(ctx, "from_peer", true)
+	errs := make(chan error, len(remote))
+	for _, peer := range remote {
+		go func(peer Peer) {
+			var err error
+			switch op {
+			case "set":
+				err = peer.Set(syncCtx, g.name, key, value)
+			case "delete":
+				_, err = peer.Delete(syncCtx, g.name, key)
+			}
+			errs <- err
+		}(peer)
+	}
+
+	// 等待所有副本响应，保证返回后达到要求的副本都已写入
+	var lastErr error
+	for range remote {
+		if err := <-errs; err != nil {
+			lastErr = err
+			continue
+		}
+		succeeded++
+	}
+
+	if succeeded < required {
+		g.stats.consistencyErrors.Add(1)
+		return true, fmt.Errorf("%w: %s %s got %d/%d: %v", ErrConsistencyNotMet, op, g.writeConsistency, succeeded, required, lastErr)
+	}
+	return true, nil
+}
@@ -1,7 +1,9 @@
 package consistenthash
 
 import (
+	"errors"
 	"math"
+	"sort"
 	"sync/atomic"
 	"time"
 )
@@ -10,6 +12,31 @@ import (
 // 避免在样本量不足时做出错误的调整决策
 const minSampleSize = 1000
 
+// defaultBalanceInterval 后台负载均衡器的默认检查间隔
+const defaultBalanceInterval = time.Second
+
+// ErrRingChanged 计算重平衡方案期间哈希环被修改，方案已失效
+var ErrRingChanged = errors.New("consistenthash: ring changed during rebalance")
+
+// ReplicaChange 单个节点的虚拟节点数量调整
+type ReplicaChange struct {
+	Node     string  // 节点
+	LoadRate float64 // 实际负载与平均负载的比值
+	From     int     // 调整前的虚拟节点数量
+	To       int     // 调整后的虚拟节点数量
+}
+
+// RebalancePlan 一次重平衡的调整方案
+type RebalancePlan struct {
+	TotalRequests int64           // 方案所依据的请求样本数
+	MaxDeviation  float64         // 各节点与平均负载的最大偏差比例
+	Changes       []ReplicaChange // 需要调整的节点，按节点名排序
+	Applied       bool            // 方案是否已生效
+	Time          time.Time       // 方案生成时间
+
+	version uint64 // 方案所依据的哈希环版本
+}
+
 // checkAndRebalance 检查负载分布并在必要时重新平衡虚拟节点
 //
 // 算法逻辑：
@@ -19,84 +46,173 @@ const minSampleSize = 1000
 // 4. 重平衡策略：高负载节点减少虚拟节点，低负载节点增加虚拟节点
 func (r *HashRing) checkAndRebalance() {
 	// 样本量不足时不进行调整，避免误差过大
-	totalRequests := atomic.LoadInt64(&r.totalRequests)
-	if totalRequests < minSampleSize {
+	if r.totalRequests.Load() < minSampleSize {
 		return
 	}
 
-	// 计算平均每个节点应该处理的请求数
-	avgLoad := float64(totalRequests) / float64(len(r.nodeReplicas))
-
-	// 计算最大负载偏差比例
-	maxDeviationRatio := r.calculateMaxDeviation(avgLoad)
-
-	// 当最大偏差超过配置的阈值时，触发重平衡
-	if maxDeviationRatio > r.config.LoadBalanceThreshold {
-		r.rebalanceNodes()
+	plan := r.PlanRebalance()
+	if plan.MaxDeviation <= r.config.LoadBalanceThreshold {
+		return
 	}
+
+	// 方案计算期间环被修改时放弃本轮，下一轮会基于新的布局重新计算
+	r.applyPlan(plan)
 }
 
-// calculateMaxDeviation 计算所有节点中与平均负载的最大偏差比例
-// deviation = |actual - expected| / expected
-func (r *HashRing) calculateMaxDeviation(avgLoad float64) float64 {
-	var maxDeviation float64
+// PlanRebalance 根据当前负载统计计算重平衡方案，但不修改哈希环（dry-run）
+//
+// 手动固定的节点不会出现在方案中。
+func (r *HashRing) PlanRebalance() RebalancePlan {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
-	for _, count := range r.nodeCounts {
-		// 计算当前节点与平均负载的偏差比例
-		deviation := math.Abs(float64(count)-avgLoad) / avgLoad
-		if deviation > maxDeviation {
-			maxDeviation = deviation
-		}
+	plan := RebalancePlan{
+		TotalRequests: r.totalRequests.Load(),
+		Time:          time.Now(),
+		version:       r.version,
+	}
+	if len(r.nodeReplicas) == 0 || plan.TotalRequests == 0 {
+		return plan
 	}
 
-	return maxDeviation
-}
-
-// rebalanceNodes 重新平衡节点
-func (r *HashRing) rebalanceNodes() {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	// 计算平均每个节点应该处理的请求数
+	avgLoad := float64(plan.TotalRequests) / float64(len(r.nodeReplicas))
 
-	avgLoad := float64(r.totalRequests) / float64(len(r.nodeReplicas))
+	nodes := make([]string, 0, len(r.nodeReplicas))
+	for node := range r.nodeReplicas {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
 
-	// 调整每个节点的虚拟节点数量
-	for node, count := range r.nodeCounts {
-		currentReplicas := r.nodeReplicas[node]
+	for _, node := range nodes {
+		var count int64
+		if c, ok := r.nodeCounts[node]; ok {
+			count = c.Load()
+		}
 		loadRatio := float64(count) / avgLoad
 
-		var newReplicas int
-		if loadRatio > 1 {
-			// 负载过高，减少虚拟节点
-			newReplicas = int(float64(currentReplicas) / loadRatio)
-		} else {
-			// 负载过低，增加虚拟节点
-			newReplicas = int(float64(currentReplicas) * (2 - loadRatio))
+		// 记录最大负载偏差比例：deviation = |actual - expected| / expected
+		if deviation := math.Abs(loadRatio - 1); deviation > plan.MaxDeviation {
+			plan.MaxDeviation = deviation
 		}
 
-		// 确保在限制范围内
-		if newReplicas < r.config.MinReplicas {
-			newReplicas = r.config.MinReplicas
-		}
-		if newReplicas > r.config.MaxReplicas {
-			newReplicas = r.config.MaxReplicas
+		if r.pinned[node] {
+			continue
 		}
 
-		if newReplicas != currentReplicas {
-			// 重新添加节点的虚拟节点
-			if err := r.removeNodeUnlocked(node); err != nil {
-				continue // 如果移除失败，跳过这个节点
-			}
-			r.addNode(node, newReplicas)
+		currentReplicas := r.nodeReplicas[node]
+		if newReplicas := r.targetReplicas(currentReplicas, loadRatio); newReplicas != currentReplicas {
+			plan.Changes = append(plan.Changes, ReplicaChange{
+				Node:     node,
+				LoadRate: loadRatio,
+				From:     currentReplicas,
+				To:       newReplicas,
+			})
 		}
 	}
+	return plan
+}
 
-	// 重置计数器
-	for node := range r.nodeCounts {
-		r.nodeCounts[node] = 0
+// targetReplicas 根据负载比例计算节点新的虚拟节点数量
+func (r *HashRing) targetReplicas(currentReplicas int, loadRatio float64) int {
+	var newReplicas int
+	if loadRatio > 1 {
+		// 负载过高，减少虚拟节点
+		newReplicas = int(float64(currentReplicas) / loadRatio)
+	} else {
+		// 负载过低，增加虚拟节点
+		newReplicas = int(float64(currentReplicas) * (2 - loadRatio))
 	}
-	atomic.StoreInt64(&r.totalRequests, 0)
 
-	r.sortKeys()
+	// 确保在限制范围内
+	if newReplicas < r.config.MinReplicas {
+		newReplicas = r.config.MinReplicas
+	}
+	if newReplicas > r.config.MaxReplicas {
+		newReplicas = r.config.MaxReplicas
+	}
+	return newReplicas
+}
+
+// Rebalance 立即按当前负载统计重新平衡虚拟节点，不检查阈值
+// 未启用后台负载均衡器时，调用方可以在合适的时机手动调用
+// 计算期间哈希环被其他操作修改时返回 ErrRingChanged，环保持不变
+func (r *HashRing) Rebalance() (RebalancePlan, error) {
+	return r.applyPlan(r.PlanRebalance())
+}
+
+// applyPlan 在锁外按方案构建新的布局，再在写锁内原子替换
+func (r *HashRing) applyPlan(plan RebalancePlan) (RebalancePlan, error) {
+	if plan.TotalRequests == 0 {
+		return plan, nil
+	}
+
+	// 在快照上构建新布局，不阻塞 Get
+	next := r.layoutSnapshot(plan.version)
+	if next == nil {
+		return plan, ErrRingChanged
+	}
+	for _, change := range plan.Changes {
+		next.removeNodeUnlocked(change.Node)
+		next.addNode(change.Node, change.To)
+	}
+	next.sortKeys()
+
+	r.mu.Lock()
+	if r.version != plan.version {
+		r.mu.Unlock()
+		return plan, ErrRingChanged
+	}
+	r.keys = next.keys
+	r.hashMap = next.hashMap
+	r.nodeReplicas = next.nodeReplicas
+	r.nodeHashes = next.nodeHashes
+	r.collisions.Add(next.collisions.Load())
+	r.version++
+
+	// 重置计数器，新的布局重新开始统计
+	for _, count := range r.nodeCounts {
+		count.Store(0)
+	}
+	r.totalRequests.Store(0)
+	r.mu.Unlock()
+
+	plan.Applied = true
+	if r.config.OnRebalance != nil {
+		r.config.OnRebalance(plan)
+	}
+	return plan, nil
+}
+
+// layoutSnapshot 复制哈希环的布局用于在锁外修改，版本与 version 不一致时返回 nil
+func (r *HashRing) layoutSnapshot(version uint64) *HashRing {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.version != version {
+		return nil
+	}
+
+	next := &HashRing{
+		config:       r.config,
+		keys:         append([]uint64(nil), r.keys...),
+		hashMap:      make(map[uint64]string, len(r.hashMap)),
+		nodeReplicas: make(map[string]int, len(r.nodeReplicas)),
+		nodeHashes:   make(map[string][]uint64, len(r.nodeHashes)),
+		pinned:       make(map[string]bool),
+		nodeCounts:   make(map[string]*atomic.Int64),
+	}
+	for hash, node := range r.hashMap {
+		next.hashMap[hash] = node
+	}
+	for node, n := range r.nodeReplicas {
+		next.nodeReplicas[node] = n
+		next.nodeCounts[node] = new(atomic.Int64)
+	}
+	for node, hashes := range r.nodeHashes {
+		next.nodeHashes[node] = hashes
+	}
+	return next
 }
 
 // GetStats 获取负载统计信息
@@ -105,25 +221,35 @@ func (r *HashRing) GetStats() map[string]float64 {
 	defer r.mu.RUnlock()
 
 	stats := make(map[string]float64)
-	total := atomic.LoadInt64(&r.totalRequests)
+	total := r.totalRequests.Load()
 	if total == 0 {
 		return stats
 	}
 
 	for node, count := range r.nodeCounts {
-		stats[node] = float64(count) / float64(total)
+		stats[node] = float64(count.Load()) / float64(total)
 	}
 	return stats
 }
 
-// 将checkAndRebalance移到单独的goroutine中
+// startBalancer 在单独的 goroutine 中定期执行 checkAndRebalance，直到 Close 被调用
 func (r *HashRing) startBalancer() {
+	interval := r.config.BalanceInterval
+	if interval <= 0 {
+		interval = defaultBalanceInterval
+	}
+
 	go func() {
-		ticker := time.NewTicker(time.Second)
+		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 
-		for range ticker.C {
-			r.checkAndRebalance()
+		for {
+			select {
+			case <-r.stopCh:
+				return
+			case <-ticker.C:
+				r.checkAndRebalance()
+			}
 		}
 	}()
 }
@@ -1,6 +1,10 @@
 package consistenthash
 
-import "hash/crc32"
+import (
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+)
 
 // Config 一致性哈希配置
 type Config struct {
@@ -10,10 +14,24 @@ type Config struct {
 	MinReplicas int
 	// 最大虚拟节点数
 	MaxReplicas int
-	// 哈希函数
-	HashFunc func(data []byte) uint32
+	// 哈希函数，输出 64 位哈希值，降低虚拟节点之间的冲突概率
+	HashFunc func(data []byte) uint64
 	// 负载均衡阈值，超过此值触发虚拟节点调整
 	LoadBalanceThreshold float64
+	// 是否启动后台负载均衡器，关闭时可通过 Rebalance 手动触发
+	EnableBalancer bool
+	// 后台负载均衡器的检查间隔，0 表示使用默认值（1 秒）
+	BalanceInterval time.Duration
+	// 是否启用 Redis 风格的哈希标签，启用后只对 key 中 {} 内的部分计算哈希
+	EnableHashTags bool
+	// 是否启用机房感知，启用后 GetN 返回的节点尽量分布在不同机房
+	ZoneAware bool
+	// 是否使用 Maglev 查找表选择 key 的主节点，Get 为 O(1)；主节点不再受虚拟节点数量和负载均衡的影响
+	EnableMaglev bool
+	// Maglev 查找表大小，0 表示使用 DefaultMaglevTableSize
+	MaglevTableSize uint64
+	// 重平衡方案生效后的回调，可用于记录审计日志，在负载均衡 goroutine 中同步调用
+	OnRebalance func(plan RebalancePlan)
 }
 
 // DefaultConfig 默认配置
@@ -21,6 +39,6 @@ var DefaultConfig = &Config{
 	DefaultReplicas:      50,
 	MinReplicas:          10,
 	MaxReplicas:          200,
-	HashFunc:             crc32.ChecksumIEEE,
+	HashFunc:             xxhash.Sum64,
 	LoadBalanceThreshold: 0.25, // 25% 的负载不均衡度触发调整
 }
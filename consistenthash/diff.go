@@ -0,0 +1,135 @@
+package consistenthash
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrMaglevDiff 使用 Maglev 查找表的哈希环无法按区间比较归属
+var ErrMaglevDiff = errors.New("consistenthash: Diff does not support rings using Maglev")
+
+// KeyRangeMove 描述一段哈希区间的归属变化
+//
+// 区间为左开右闭的 (Start, End]；Start >= End 时表示跨越 0 点的回绕区间，
+// 即 (Start, MaxUint64] 与 [0, End] 的并集。
+type KeyRangeMove struct {
+	Start uint64 // 区间起点（不含）
+	End   uint64 // 区间终点（含）
+	From  string // 变化前的归属节点
+	To    string // 变化后的归属节点
+}
+
+// Contains 判断哈希值是否落在该区间内
+func (m KeyRangeMove) Contains(hash uint64) bool {
+	if m.Start < m.End {
+		return hash > m.Start && hash <= m.End
+	}
+	return hash > m.Start || hash <= m.End
+}
+
+// ringSnapshot 哈希环布局的只读快照
+type ringSnapshot struct {
+	keys    []uint64
+	hashMap map[uint64]string
+}
+
+// snapshot 复制当前哈希环布局
+func (r *HashRing) snapshot() ringSnapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hashMap := make(map[uint64]string, len(r.hashMap))
+	for hash, node := range r.hashMap {
+		hashMap[hash] = node
+	}
+	return ringSnapshot{
+		keys:    append([]uint64(nil), r.keys...),
+		hashMap: hashMap,
+	}
+}
+
+// owner 返回哈希值 hash 在快照中的归属节点
+func (s ringSnapshot) owner(hash uint64) string {
+	if len(s.keys) == 0 {
+		return ""
+	}
+	idx := sort.Search(len(s.keys), func(i int) bool {
+		return s.keys[i] >= hash
+	})
+	if idx == len(s.keys) {
+		idx = 0
+	}
+	return s.hashMap[s.keys[idx]]
+}
+
+// Hash 返回 key 在哈希环上的位置，与 Get 使用的哈希值相同（启用哈希标签时只计算标签部分）
+// 迁移时可用 KeyRangeMove.Contains(r.Hash(key)) 判断 key 是否需要迁移
+func (r *HashRing) Hash(key string) uint64 {
+	return r.hashKey(key)
+}
+
+// Diff 计算从 oldRing 变为 newRing 时归属发生变化的哈希区间
+//
+// 两个环上的所有虚拟节点位置把哈希空间切分为若干区间，每个区间在两个环上都只有
+// 唯一的归属节点；比较各区间前后的归属即可得到需要迁移的区间，相邻且迁移方向相同的
+// 区间会被合并。两个环应使用相同的哈希函数，否则结果没有意义。
+// 使用 Maglev（WithMaglev）时主节点由查找表决定，与环上的区间无关，返回 ErrMaglevDiff。
+func Diff(oldRing, newRing *HashRing) ([]KeyRangeMove, error) {
+	if oldRing.maglev != nil || newRing.maglev != nil {
+		return nil, ErrMaglevDiff
+	}
+
+	before := oldRing.snapshot()
+	after := newRing.snapshot()
+
+	// 合并两个环上的所有边界点
+	points := make([]uint64, 0, len(before.keys)+len(after.keys))
+	points = append(points, before.keys...)
+	points = append(points, after.keys...)
+	sort.Slice(points, func(i, j int) bool {
+		return points[i] < points[j]
+	})
+	points = dedupSorted(points)
+	if len(points) == 0 {
+		return nil, nil
+	}
+
+	var moves []KeyRangeMove
+	prev := points[len(points)-1] // 第一个区间从最后一个边界点回绕
+	for _, point := range points {
+		from, to := before.owner(point), after.owner(point)
+		if from != to {
+			// 与上一个迁移方向相同且首尾相接时合并
+			if n := len(moves); n > 0 && moves[n-1].End == prev && moves[n-1].From == from && moves[n-1].To == to {
+				moves[n-1].End = point
+			} else {
+				moves = append(moves, KeyRangeMove{Start: prev, End: point, From: from, To: to})
+			}
+		}
+		prev = point
+	}
+
+	// 首尾两段在回绕处相接时合并
+	if n := len(moves); n > 1 {
+		first, last := moves[0], moves[n-1]
+		if last.End == first.Start && last.From == first.From && last.To == first.To {
+			moves[0].Start = last.Start
+			moves = moves[:n-1]
+		}
+	}
+	return moves, nil
+}
+
+// dedupSorted 移除有序切片中的重复元素
+func dedupSorted(s []uint64) []uint64 {
+	if len(s) == 0 {
+		return s
+	}
+	out := s[:1]
+	for _, v := range s[1:] {
+		if v != out[len(out)-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
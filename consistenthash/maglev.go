@@ -0,0 +1,249 @@
+package consistenthash
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultMaglevTableSize 默认查找表大小，必须为质数
+// 表大小应远大于节点数（建议至少 100 倍），以保证分布均匀
+const DefaultMaglevTableSize = 65537
+
+// Maglev Maglev 一致性哈希实现
+//
+// 与哈希环在有序切片上二分查找不同，Maglev 预先计算一张固定大小的查找表，
+// key 的查找只需一次哈希和一次数组访问（O(1)），且各节点分到的表项数量几乎完全相同。
+// 代价是节点变更时需要重建整张表（O(M·N)），适合节点变更少、查询极多的场景。
+//
+// 查找表以不可变快照的形式原子替换，Get 不需要加锁。
+type Maglev struct {
+	mu        sync.Mutex                  // 串行化节点变更
+	tableSize uint64                      // 查找表大小（质数）
+	hashFunc  func(data []byte) uint64    // key 的哈希函数
+	state     atomic.Pointer[maglevState] // 当前查找表快照
+}
+
+// maglevState 查找表快照，创建后不再修改
+type maglevState struct {
+	nodes []string // 节点列表，按名称排序，保证各进程生成相同的表
+	table []int    // 查找表，表项为 nodes 的下标
+}
+
+// MaglevOption Maglev 配置选项
+type MaglevOption func(*Maglev)
+
+// WithTableSize 设置查找表大小，非质数时向上取最近的质数
+func WithTableSize(size uint64) MaglevOption {
+	return func(m *Maglev) {
+		m.tableSize = nextPrime(size)
+	}
+}
+
+// WithMaglevHashFunc 设置 key 的哈希函数，默认为 FNV-1a 64 位
+func WithMaglevHashFunc(fn func(data []byte) uint64) MaglevOption {
+	return func(m *Maglev) {
+		m.hashFunc = fn
+	}
+}
+
+// NewMaglev 创建 Maglev 实例
+func NewMaglev(opts ...MaglevOption) *Maglev {
+	m := &Maglev{
+		tableSize: DefaultMaglevTableSize,
+		hashFunc:  fnv64a,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	m.state.Store(&maglevState{})
+	return m
+}
+
+// Add 添加节点并重建查找表
+func (m *Maglev) Add(nodes ...string) error {
+	if len(nodes) == 0 {
+		return errors.New("no nodes provided")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current := m.state.Load().nodes
+	seen := make(map[string]bool, len(current)+len(nodes))
+	merged := make([]string, 0, len(current)+len(nodes))
+	for _, node := range append(append([]string{}, current...), nodes...) {
+		if node == "" || seen[node] {
+			continue
+		}
+		seen[node] = true
+		merged = append(merged, node)
+	}
+
+	m.rebuild(merged)
+	return nil
+}
+
+// Remove 移除节点并重建查找表
+func (m *Maglev) Remove(node string) error {
+	if node == "" {
+		return errors.New("invalid node")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current := m.state.Load().nodes
+	remaining := make([]string, 0, len(current))
+	for _, n := range current {
+		if n != node {
+			remaining = append(remaining, n)
+		}
+	}
+	if len(remaining) == len(current) {
+		return fmt.Errorf("node %s not found", node)
+	}
+
+	m.rebuild(remaining)
+	return nil
+}
+
+// Get 获取 key 对应的节点，没有节点时返回空字符串
+func (m *Maglev) Get(key string) string {
+	if key == "" {
+		return ""
+	}
+
+	state := m.state.Load()
+	if len(state.nodes) == 0 {
+		return ""
+	}
+
+	idx := m.hashFunc([]byte(key)) % uint64(len(state.table))
+	return state.nodes[state.table[idx]]
+}
+
+// Nodes 返回当前所有节点
+func (m *Maglev) Nodes() []string {
+	return append([]string(nil), m.state.Load().nodes...)
+}
+
+// setNodes 将节点替换为 nodes，节点没有变化时不重建查找表
+func (m *Maglev) setNodes(nodes []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current := m.state.Load().nodes
+	if len(current) == len(nodes) {
+		seen := make(map[string]bool, len(current))
+		for _, node := range current {
+			seen[node] = true
+		}
+		same := true
+		for _, node := range nodes {
+			if !seen[node] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return
+		}
+	}
+	m.rebuild(append([]string(nil), nodes...))
+}
+
+// rebuild 根据节点列表生成新的查找表并原子替换，调用者必须持有 mu
+func (m *Maglev) rebuild(nodes []string) {
+	sort.Strings(nodes)
+	m.state.Store(&maglevState{
+		nodes: nodes,
+		table: populateMaglevTable(nodes, m.tableSize),
+	})
+}
+
+// populateMaglevTable 按 Maglev 论文的算法填充查找表
+//
+// 每个节点根据自身名称生成一个 [0, M) 的排列（offset + j*skip），
+// 各节点轮流按自己的排列认领第一个空位，直到表被填满。
+func populateMaglevTable(nodes []string, size uint64) []int {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	offsets := make([]uint64, len(nodes))
+	skips := make([]uint64, len(nodes))
+	for i, node := range nodes {
+		offsets[i] = fnv64a([]byte(node)) % size
+		skips[i] = fnv64([]byte(node))%(size-1) + 1
+	}
+
+	table := make([]int, size)
+	for i := range table {
+		table[i] = -1
+	}
+
+	next := make([]uint64, len(nodes))
+	var filled uint64
+	for {
+		for i := range nodes {
+			// 找到节点 i 排列中下一个空位
+			pos := (offsets[i] + next[i]*skips[i]) % size
+			for table[pos] >= 0 {
+				next[i]++
+				pos = (offsets[i] + next[i]*skips[i]) % size
+			}
+			table[pos] = i
+			next[i]++
+
+			filled++
+			if filled == size {
+				return table
+			}
+		}
+	}
+}
+
+// fnv64a 计算 FNV-1a 64 位哈希
+func fnv64a(data []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(data)
+	return h.Sum64()
+}
+
+// fnv64 计算 FNV-1 64 位哈希，与 fnv64a 配合生成相互独立的 offset 和 skip
+func fnv64(data []byte) uint64 {
+	h := fnv.New64()
+	h.Write(data)
+	return h.Sum64()
+}
+
+// nextPrime 返回大于等于 n 的最小质数
+func nextPrime(n uint64) uint64 {
+	if n <= 2 {
+		return 2
+	}
+	for ; ; n++ {
+		if isPrime(n) {
+			return n
+		}
+	}
+}
+
+// isPrime 判断 n 是否为质数
+func isPrime(n uint64) bool {
+	if n < 2 {
+		return false
+	}
+	for i := uint64(2); i*i <= n; i++ {
+		if n%i == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -9,3 +9,33 @@ func WithConfig(config *Config) Option {
 		r.config = config
 	}
 }
+
+// WithZoneAware 启用机房感知的副本放置，需放在 WithConfig 之后
+func WithZoneAware() Option {
+	return func(r *HashRing) {
+		config := *r.config
+		config.ZoneAware = true
+		r.config = &config
+	}
+}
+
+// WithMaglev 使用 Maglev 查找表选择 key 的主节点，需放在 WithConfig 之后
+//
+// Get 只需一次哈希和一次数组访问，适合 Get 在 CPU 采样中占比明显的场景；
+// GetN 的第一个节点与 Get 相同，其余副本仍按环上顺序选择。
+func WithMaglev() Option {
+	return func(r *HashRing) {
+		config := *r.config
+		config.EnableMaglev = true
+		r.config = &config
+	}
+}
+
+// WithHashTags 启用哈希标签，需放在 WithConfig 之后
+func WithHashTags() Option {
+	return func(r *HashRing) {
+		config := *r.config
+		config.EnableHashTags = true
+		r.config = &config
+	}
+}
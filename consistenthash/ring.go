@@ -3,45 +3,86 @@ package consistenthash
 import (
 	"errors"
 	"fmt"
+	"math"
 	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 )
 
+// maxCollisionProbes 虚拟节点哈希冲突时的最大探测次数
+const maxCollisionProbes = 16
+
 // HashRing 一致性哈希实现
 type HashRing struct {
 	mu sync.RWMutex
 	// 配置信息
 	config *Config
 	// 哈希环
-	keys []int
+	keys []uint64
 	// 哈希环到节点的映射
-	hashMap map[int]string
+	hashMap map[uint64]string
 	// 节点到虚拟节点数量的映射
 	nodeReplicas map[string]int
-	// 节点负载统计
-	nodeCounts map[string]int64
+	// 节点到其虚拟节点哈希值的映射，移除节点时使用
+	nodeHashes map[string][]uint64
+	// 手动指定了虚拟节点数量的节点，自动负载均衡不会调整这些节点
+	pinned map[string]bool
+	// 节点所在的机房（可用区）标签
+	zones map[string]string
+	// 虚拟节点哈希冲突次数
+	collisions atomic.Int64
+	// 选择主节点的 Maglev 查找表，nil 表示在环上二分查找
+	maglev *Maglev
+	// 布局版本，每次虚拟节点变化时递增，用于检测重平衡期间的并发修改
+	version uint64
+	// 节点负载统计，计数器在节点加入时创建，Get 只需读锁即可原子累加
+	nodeCounts map[string]*atomic.Int64
 	// 总请求数
-	totalRequests int64
+	totalRequests atomic.Int64
+	// 关闭后台负载均衡器
+	stopCh    chan struct{}
+	closeOnce sync.Once
 }
 
 // New 创建一致性哈希实例
 func New(opts ...Option) *HashRing {
 	r := &HashRing{
 		config:       DefaultConfig,
-		hashMap:      make(map[int]string),
+		hashMap:      make(map[uint64]string),
 		nodeReplicas: make(map[string]int),
-		nodeCounts:   make(map[string]int64),
+		nodeHashes:   make(map[string][]uint64),
+		pinned:       make(map[string]bool),
+		zones:        make(map[string]string),
+		nodeCounts:   make(map[string]*atomic.Int64),
+		stopCh:       make(chan struct{}),
 	}
 
 	for _, opt := range opts {
 		opt(r)
 	}
 
-	r.startBalancer() // 启动负载均衡器
+	if r.config.EnableMaglev {
+		size := r.config.MaglevTableSize
+		if size == 0 {
+			size = DefaultMaglevTableSize
+		}
+		r.maglev = NewMaglev(WithTableSize(size), WithMaglevHashFunc(r.config.HashFunc))
+	}
+	if r.config.EnableBalancer {
+		r.startBalancer() // 启动负载均衡器
+	}
 	return r
 }
 
+// Close 停止后台负载均衡器，可以重复调用
+func (r *HashRing) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.stopCh)
+	})
+	return nil
+}
+
 // Add 添加节点
 func (r *HashRing) Add(nodes ...string) error {
 	if len(nodes) == 0 {
@@ -52,7 +93,8 @@ func (r *HashRing) Add(nodes ...string) error {
 	defer r.mu.Unlock()
 
 	for _, node := range nodes {
-		if node == "" {
+		// 已存在的节点不重复添加，否则会产生重复的虚拟节点
+		if _, exists := r.nodeReplicas[node]; node == "" || exists {
 			continue
 		}
 
@@ -60,10 +102,95 @@ func (r *HashRing) Add(nodes ...string) error {
 		r.addNode(node, r.config.DefaultReplicas)
 	}
 
+	r.sortKeys()
+	r.syncMaglev()
+	return nil
+}
+
+// AddWithReplicas 按指定的虚拟节点数量添加节点
+// 这些节点被视为手动固定，自动负载均衡不会调整其虚拟节点数量
+func (r *HashRing) AddWithReplicas(replicas map[string]int) error {
+	if len(replicas) == 0 {
+		return errors.New("no nodes provided")
+	}
+	for node, n := range replicas {
+		if node == "" || n <= 0 {
+			return fmt.Errorf("invalid replicas %d for node %q", n, node)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 按节点名顺序添加，使结果与 map 的遍历顺序无关
+	nodes := make([]string, 0, len(replicas))
+	for node := range replicas {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	for _, node := range nodes {
+		if _, exists := r.nodeReplicas[node]; exists {
+			continue
+		}
+		r.addNode(node, replicas[node])
+		r.pinned[node] = true
+	}
+
+	r.sortKeys()
+	r.syncMaglev()
+	return nil
+}
+
+// SetReplicas 调整已有节点的虚拟节点数量，并将其固定，自动负载均衡不再调整该节点
+func (r *HashRing) SetReplicas(node string, replicas int) error {
+	if replicas <= 0 {
+		return fmt.Errorf("invalid replicas %d", replicas)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, exists := r.nodeReplicas[node]
+	if !exists {
+		return fmt.Errorf("node %s not found", node)
+	}
+
+	r.pinned[node] = true
+	if current == replicas {
+		return nil
+	}
+
+	// 重建虚拟节点时保留该节点的负载计数
+	count := r.nodeCounts[node]
+	r.removeNodeUnlocked(node)
+	r.addNode(node, replicas)
+	r.nodeCounts[node] = count
+	r.pinned[node] = true
 	r.sortKeys()
 	return nil
 }
 
+// Unpin 取消节点的固定，之后自动负载均衡可以再次调整其虚拟节点数量
+func (r *HashRing) Unpin(node string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.pinned, node)
+}
+
+// Replicas 返回各节点当前的虚拟节点数量
+func (r *HashRing) Replicas() map[string]int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	replicas := make(map[string]int, len(r.nodeReplicas))
+	for node, n := range r.nodeReplicas {
+		replicas[node] = n
+	}
+	return replicas
+}
+
 // Remove 移除节点
 func (r *HashRing) Remove(node string) error {
 	if node == "" {
@@ -73,29 +200,37 @@ func (r *HashRing) Remove(node string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	return r.removeNodeUnlocked(node)
+	if err := r.removeNodeUnlocked(node); err != nil {
+		return err
+	}
+	r.syncMaglev()
+	return nil
 }
 
 // removeNodeUnlocked 无锁版本，调用者必须已持有写锁
 func (r *HashRing) removeNodeUnlocked(node string) error {
-	replicas := r.nodeReplicas[node]
-	if replicas == 0 {
+	if _, exists := r.nodeReplicas[node]; !exists {
 		return fmt.Errorf("node %s not found", node)
 	}
 
 	// 移除节点的所有虚拟节点
-	for replicaIdx := 0; replicaIdx < replicas; replicaIdx++ {
-		hash := r.hashVirtualNode(node, replicaIdx)
+	for _, hash := range r.nodeHashes[node] {
 		delete(r.hashMap, hash)
-		for j, key := range r.keys {
-			if key == hash {
-				r.keys = append(r.keys[:j], r.keys[j+1:]...)
-				break
-			}
+	}
+
+	// 一次遍历过滤掉已移除的虚拟节点，保持有序
+	keys := r.keys[:0]
+	for _, key := range r.keys {
+		if _, ok := r.hashMap[key]; ok {
+			keys = append(keys, key)
 		}
 	}
+	r.keys = keys
 
 	delete(r.nodeReplicas, node)
+	delete(r.nodeHashes, node)
+	delete(r.pinned, node)
+	r.version++
 	delete(r.nodeCounts, node)
 	return nil
 }
@@ -113,7 +248,50 @@ func (r *HashRing) Get(key string) string {
 		return ""
 	}
 
-	hash := r.hash(key)
+	node := r.owner(key)
+	if count, ok := r.nodeCounts[node]; ok {
+		count.Add(1)
+	}
+	r.totalRequests.Add(1)
+
+	return node
+}
+
+// GetN 获取 key 对应的 n 个不同节点
+//
+// 从 key 在环上的位置开始顺时针遍历，跳过已选中真实节点的虚拟节点，
+// 第一个节点与 Get 的结果相同，其余节点可作为副本、对冲读或故障转移的目标。
+// 节点总数不足 n 时返回所有节点。GetN 不计入负载统计。
+// 启用机房感知时，返回的节点会尽量分布在不同的机房（见 SetZone）。
+func (r *HashRing) GetN(key string, n int) []string {
+	if key == "" || n <= 0 {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.keys) == 0 {
+		return nil
+	}
+	if n > len(r.nodeReplicas) {
+		n = len(r.nodeReplicas)
+	}
+
+	// 机房感知模式下需要按环上顺序取得全部节点，再从中挑选跨机房的节点
+	if r.config.ZoneAware && len(r.zones) > 0 {
+		return r.spreadZones(r.ordered(key, len(r.nodeReplicas)), n)
+	}
+	return r.ordered(key, n)
+}
+
+// owner 返回 key 的主节点，调用者必须持有读锁且环上至少有一个节点
+func (r *HashRing) owner(key string) string {
+	if r.maglev != nil {
+		return r.maglev.Get(r.lookupKey(key))
+	}
+
+	hash := r.hashKey(key)
 	// 二分查找
 	idx := sort.Search(len(r.keys), func(i int) bool {
 		return r.keys[i] >= hash
@@ -126,40 +304,178 @@ func (r *HashRing) Get(key string) string {
 	if idx == len(r.keys) {
 		idx = 0
 	}
+	return r.hashMap[r.keys[idx]]
+}
 
-	node := r.hashMap[r.keys[idx]]
-	r.nodeCounts[node]++
-	atomic.AddInt64(&r.totalRequests, 1)
+// ordered 按副本顺序返回 key 的 n 个不同节点，调用者必须持有读锁
+// 使用 Maglev 时第一个节点为查找表选出的主节点，其余节点按环上顺序排列
+func (r *HashRing) ordered(key string, n int) []string {
+	if r.maglev == nil {
+		return r.walk(key, n)
+	}
 
-	return node
+	owner := r.owner(key)
+	nodes := append(make([]string, 0, n), owner)
+	for _, node := range r.walk(key, n) {
+		if len(nodes) == n {
+			break
+		}
+		if node != owner {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// walk 从 key 在环上的位置开始顺时针遍历，按顺序返回 n 个不同的真实节点，调用者必须持有读锁
+func (r *HashRing) walk(key string, n int) []string {
+	hash := r.hashKey(key)
+	start := sort.Search(len(r.keys), func(i int) bool {
+		return r.keys[i] >= hash
+	})
+
+	nodes := make([]string, 0, n)
+	seen := make(map[string]struct{}, n)
+	for i := 0; i < len(r.keys) && len(nodes) < n; i++ {
+		node := r.hashMap[r.keys[(start+i)%len(r.keys)]]
+		if _, ok := seen[node]; ok {
+			continue
+		}
+		seen[node] = struct{}{}
+		nodes = append(nodes, node)
+	}
+	return nodes
 }
 
 // addNode 为指定节点创建指定数量的虚拟节点（replicas）
 // 每个虚拟节点通过在节点名后添加索引（如 "node-0", "node-1"）生成唯一哈希值
 // 这些虚拟节点均匀分布在哈希环上，实现负载均衡
+//
+// 虚拟节点的哈希值与环上已有的值冲突时，会在名称后追加探测序号重新计算，
+// 而不是覆盖已有虚拟节点；冲突次数通过 Collisions 暴露。
 func (r *HashRing) addNode(node string, replicas int) {
+	hashes := make([]uint64, 0, replicas)
 	for replicaIdx := 0; replicaIdx < replicas; replicaIdx++ {
 		hash := r.hashVirtualNode(node, replicaIdx)
+		probe := 1
+		for ; r.occupied(hash) && probe <= maxCollisionProbes; probe++ {
+			r.collisions.Add(1)
+			hash = r.hash(fmt.Sprintf("%s-%d#%d", node, replicaIdx, probe))
+		}
+		if probe > maxCollisionProbes && r.occupied(hash) {
+			continue // 哈希空间过于拥挤，放弃该虚拟节点
+		}
+
 		r.keys = append(r.keys, hash)
 		r.hashMap[hash] = node
+		hashes = append(hashes, hash)
 	}
 	r.nodeReplicas[node] = replicas
+	r.nodeHashes[node] = hashes
+	r.version++
+	if _, ok := r.nodeCounts[node]; !ok {
+		r.nodeCounts[node] = new(atomic.Int64)
+	}
+}
+
+// occupied 判断哈希值是否已被环上的虚拟节点占用
+func (r *HashRing) occupied(hash uint64) bool {
+	_, ok := r.hashMap[hash]
+	return ok
 }
 
 // hashVirtualNode 计算虚拟节点的哈希值
 // 虚拟节点命名格式："{node}-{replicaIdx}"，如 "192.168.1.1:8001-0"
-func (r *HashRing) hashVirtualNode(node string, replicaIdx int) int {
+func (r *HashRing) hashVirtualNode(node string, replicaIdx int) uint64 {
 	virtualKey := fmt.Sprintf("%s-%d", node, replicaIdx)
 	return r.hash(virtualKey)
 }
 
 // hash 计算给定 key 的哈希值
-func (r *HashRing) hash(key string) int {
-	return int(r.config.HashFunc([]byte(key)))
+func (r *HashRing) hash(key string) uint64 {
+	return r.config.HashFunc([]byte(key))
+}
+
+// hashKey 计算查找 key 时使用的哈希值，启用哈希标签时只对标签部分计算
+func (r *HashRing) hashKey(key string) uint64 {
+	return r.hash(r.lookupKey(key))
+}
+
+// lookupKey 返回 key 中参与哈希的部分
+func (r *HashRing) lookupKey(key string) string {
+	if r.config.EnableHashTags {
+		return HashTag(key)
+	}
+	return key
+}
+
+// syncMaglev 使 Maglev 查找表的节点与环上的节点一致，调用者必须持有写锁
+func (r *HashRing) syncMaglev() {
+	if r.maglev == nil {
+		return
+	}
+	nodes := make([]string, 0, len(r.nodeReplicas))
+	for node := range r.nodeReplicas {
+		nodes = append(nodes, node)
+	}
+	r.maglev.setNodes(nodes)
+}
+
+// HashTag 返回 key 中用于计算哈希的部分
+//
+// 与 Redis Cluster 的规则一致：key 中第一个 '{' 与其后第一个 '}' 之间的内容非空时，
+// 只使用该内容计算哈希，例如 "{user:123}:profile" 与 "{user:123}:settings"
+// 会被分配到同一个节点；否则使用整个 key。
+func HashTag(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return key
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return key
+	}
+	return key[start+1 : start+1+end]
 }
 
 // sortKeys 对哈希环的键进行排序
 // 在添加或删除虚拟节点后调用，确保二分查找的前提条件
 func (r *HashRing) sortKeys() {
-	sort.Ints(r.keys)
+	sort.Slice(r.keys, func(i, j int) bool {
+		return r.keys[i] < r.keys[j]
+	})
+}
+
+// Collisions 返回添加虚拟节点时发生的哈希冲突次数
+func (r *HashRing) Collisions() int64 {
+	return r.collisions.Load()
+}
+
+// Distribution 返回各节点在哈希空间中所占的比例
+//
+// 每个虚拟节点负责从前一个虚拟节点（不含）到自身（含）的区间，
+// 比例越接近 1/节点数，分布越均匀。与 GetStats 基于实际请求的统计不同，
+// 该指标只取决于环的布局，可用于评估哈希函数和副本数的选择。
+func (r *HashRing) Distribution() map[string]float64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	dist := make(map[string]float64, len(r.nodeReplicas))
+	if len(r.keys) == 0 {
+		return dist
+	}
+
+	const space = float64(math.MaxUint64)
+	prev := r.keys[len(r.keys)-1]
+	for _, key := range r.keys {
+		// 无符号减法在回绕处自然得到跨越 0 点的区间长度
+		dist[r.hashMap[key]] += float64(key-prev) / space
+		prev = key
+	}
+
+	// 只有一个虚拟节点时区间长度为 0，实际上它负责整个哈希空间
+	if len(r.keys) == 1 {
+		dist[r.hashMap[r.keys[0]]] = 1
+	}
+	return dist
 }
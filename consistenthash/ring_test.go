@@ -0,0 +1,467 @@
+package consistenthash
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+// newTestRing 创建使用指定哈希函数的哈希环
+func newTestRing(hashFunc func([]byte) uint64) *HashRing {
+	config := *DefaultConfig
+	if hashFunc != nil {
+		config.HashFunc = hashFunc
+	}
+	return New(WithConfig(&config))
+}
+
+func TestHashRing_Distribution(t *testing.T) {
+	r := newTestRing(nil)
+	nodes := []string{"10.0.0.1:8001", "10.0.0.2:8001", "10.0.0.3:8001", "10.0.0.4:8001"}
+	if err := r.Add(nodes...); err != nil {
+		t.Fatalf("Add 失败: %v", err)
+	}
+
+	t.Run("哈希空间占比", func(t *testing.T) {
+		dist := r.Distribution()
+		var total float64
+		for _, node := range nodes {
+			share := dist[node]
+			total += share
+			// 每个节点 50 个虚拟节点时，占比应在理想值 25% 的 ±40% 以内
+			if math.Abs(share-0.25)/0.25 > 0.4 {
+				t.Errorf("节点 %s 占比 %.3f 偏离理想值过多", node, share)
+			}
+		}
+		if math.Abs(total-1) > 1e-9 {
+			t.Errorf("占比之和应为 1，实际为 %f", total)
+		}
+	})
+
+	t.Run("实际请求分布", func(t *testing.T) {
+		counts := make(map[string]int)
+		const n = 100000
+		for i := 0; i < n; i++ {
+			counts[r.Get(fmt.Sprintf("key-%d", i))]++
+		}
+		for _, node := range nodes {
+			ratio := float64(counts[node]) / n
+			if math.Abs(ratio-0.25)/0.25 > 0.4 {
+				t.Errorf("节点 %s 请求占比 %.3f 偏离理想值过多", node, ratio)
+			}
+		}
+	})
+}
+
+func TestHashRing_Collision(t *testing.T) {
+	// 只有 16 个取值的哈希函数，必然产生大量冲突
+	r := newTestRing(func(data []byte) uint64 {
+		return DefaultConfig.HashFunc(data) % 16
+	})
+	config := *r.config
+	config.DefaultReplicas = 4
+	r.config = &config
+
+	if err := r.Add("a", "b", "c"); err != nil {
+		t.Fatalf("Add 失败: %v", err)
+	}
+
+	if len(r.keys) != 12 || len(r.hashMap) != 12 {
+		t.Fatalf("冲突的虚拟节点不应被覆盖: keys=%d, hashMap=%d", len(r.keys), len(r.hashMap))
+	}
+	if r.Collisions() == 0 {
+		t.Error("应记录到哈希冲突")
+	}
+
+	if err := r.Remove("b"); err != nil {
+		t.Fatalf("Remove 失败: %v", err)
+	}
+	for _, key := range r.keys {
+		if r.hashMap[key] == "b" {
+			t.Fatal("移除后环上不应再有节点 b 的虚拟节点")
+		}
+	}
+	if len(r.keys) != 8 {
+		t.Errorf("移除后应剩余 8 个虚拟节点，实际为 %d", len(r.keys))
+	}
+}
+
+func TestHashRing_GetN(t *testing.T) {
+	r := newTestRing(nil)
+	r.Add("a", "b", "c")
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		nodes := r.GetN(key, 2)
+		if len(nodes) != 2 || nodes[0] == nodes[1] {
+			t.Fatalf("GetN(%s, 2) = %v，应返回 2 个不同节点", key, nodes)
+		}
+		if nodes[0] != r.Get(key) {
+			t.Fatalf("GetN 的第一个节点 %s 应与 Get 的结果 %s 相同", nodes[0], r.Get(key))
+		}
+	}
+
+	if nodes := r.GetN("key", 5); len(nodes) != 3 {
+		t.Errorf("节点不足时应返回所有节点，实际为 %v", nodes)
+	}
+}
+
+func TestHashRing_MarshalUnmarshal(t *testing.T) {
+	r := newTestRing(nil)
+	r.Add("a", "b", "c")
+
+	data, err := r.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal 失败: %v", err)
+	}
+
+	restored := newTestRing(nil)
+	if err := restored.Unmarshal(data); err != nil {
+		t.Fatalf("Unmarshal 失败: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if got, want := restored.Get(key), r.Get(key); got != want {
+			t.Fatalf("恢复后 %s 的归属为 %s，期望 %s", key, got, want)
+		}
+	}
+
+	// 哈希函数不同时保存的虚拟节点无法由本地哈希函数得到
+	other := newTestRing(func(data []byte) uint64 {
+		return DefaultConfig.HashFunc(data) + 1
+	})
+	if err := other.Unmarshal(data); err == nil {
+		t.Error("哈希函数不同时 Unmarshal 应返回错误")
+	}
+
+	// 只接受当前版本的格式
+	if err := restored.Unmarshal([]byte(`{"version":1,"replicas":{"a":50}}`)); err == nil {
+		t.Error("不支持的版本 Unmarshal 应返回错误")
+	}
+	if got := restored.Replicas(); len(got) != 3 {
+		t.Errorf("Unmarshal 失败时不应修改哈希环，实际节点为 %v", got)
+	}
+}
+
+func TestHashRing_UnmarshalAfterCollision(t *testing.T) {
+	collide := func(data []byte) uint64 {
+		return DefaultConfig.HashFunc(data) % 64
+	}
+	r := newTestRing(collide)
+	// 不按节点名顺序加入，冲突探测的结果取决于加入顺序
+	for _, node := range []string{"c", "a", "b"} {
+		if err := r.Add(node); err != nil {
+			t.Fatalf("Add 失败: %v", err)
+		}
+	}
+	if r.Collisions() == 0 {
+		t.Fatal("应记录到哈希冲突")
+	}
+
+	data, err := r.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal 失败: %v", err)
+	}
+	restored := newTestRing(collide)
+	if err := restored.Unmarshal(data); err != nil {
+		t.Fatalf("Unmarshal 失败: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if got, want := restored.Get(key), r.Get(key); got != want {
+			t.Fatalf("恢复后 %s 的归属为 %s，期望 %s", key, got, want)
+		}
+	}
+}
+
+func TestMaglev_Distribution(t *testing.T) {
+	m := NewMaglev()
+	nodes := []string{"a", "b", "c", "d"}
+	m.Add(nodes...)
+
+	counts := make(map[string]int)
+	const n = 100000
+	for i := 0; i < n; i++ {
+		counts[m.Get(fmt.Sprintf("key-%d", i))]++
+	}
+	for _, node := range nodes {
+		ratio := float64(counts[node]) / n
+		if math.Abs(ratio-0.25)/0.25 > 0.05 {
+			t.Errorf("节点 %s 请求占比 %.3f 偏离理想值过多", node, ratio)
+		}
+	}
+
+	// 移除节点后，原本不属于该节点的 key 绝大多数不应迁移
+	before := make(map[string]string)
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		before[key] = m.Get(key)
+	}
+	m.Remove("d")
+
+	var moved int
+	for key, node := range before {
+		if node != "d" && m.Get(key) != node {
+			moved++
+		}
+	}
+	if moved > len(before)/50 {
+		t.Errorf("移除节点后有 %d 个 key 发生了不必要的迁移", moved)
+	}
+}
+
+func TestHashRing_HashTags(t *testing.T) {
+	tests := map[string]string{
+		"{user:123}:profile": "user:123",
+		"prefix{tag}suffix":  "tag",
+		"{}empty":            "{}empty",
+		"no-tag":             "no-tag",
+		"{unclosed":          "{unclosed",
+		"a{b}{c}":            "b",
+	}
+	for key, want := range tests {
+		if got := HashTag(key); got != want {
+			t.Errorf("HashTag(%q) = %q，期望 %q", key, got, want)
+		}
+	}
+
+	r := New(WithHashTags())
+	r.Add("a", "b", "c", "d")
+	owner := r.Get("{user:123}:profile")
+	for i := 0; i < 20; i++ {
+		if got := r.Get(fmt.Sprintf("{user:123}:field-%d", i)); got != owner {
+			t.Fatalf("相同哈希标签的 key 应分配到同一节点，期望 %s，实际 %s", owner, got)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := New()
+	old.Add("a", "b", "c")
+	updated := New()
+	updated.Add("a", "b", "c", "d")
+
+	moves, err := Diff(old, updated)
+	if err != nil {
+		t.Fatalf("Diff 失败: %v", err)
+	}
+	if len(moves) == 0 {
+		t.Fatal("新增节点后应有区间发生迁移")
+	}
+	for _, m := range moves {
+		if m.To != "d" {
+			t.Fatalf("新增节点时区间只应迁移到新节点，实际为 %+v", m)
+		}
+	}
+
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		from, to := old.Get(key), updated.Get(key)
+
+		var found *KeyRangeMove
+		for j := range moves {
+			if moves[j].Contains(old.Hash(key)) {
+				found = &moves[j]
+				break
+			}
+		}
+
+		switch {
+		case from == to && found != nil:
+			t.Fatalf("%s 的归属未变化，却落在迁移区间 %+v 中", key, *found)
+		case from != to && (found == nil || found.From != from || found.To != to):
+			t.Fatalf("%s 从 %s 迁移到 %s，但迁移区间为 %v", key, from, to, found)
+		}
+	}
+
+	if moves, _ := Diff(old, old); len(moves) != 0 {
+		t.Errorf("相同的环不应有迁移，实际为 %v", moves)
+	}
+}
+
+func TestDiff_Maglev(t *testing.T) {
+	old := New()
+	old.Add("a", "b")
+	updated := New(WithMaglev())
+	updated.Add("a", "b", "c")
+	defer updated.Close()
+
+	// Maglev 的主节点不由环上的区间决定，按区间比较的结果是错误的
+	if moves, err := Diff(old, updated); !errors.Is(err, ErrMaglevDiff) || moves != nil {
+		t.Errorf("使用 Maglev 时应返回 ErrMaglevDiff，实际为 %v, %v", moves, err)
+	}
+	if _, err := Diff(updated, old); !errors.Is(err, ErrMaglevDiff) {
+		t.Errorf("旧环使用 Maglev 时应返回 ErrMaglevDiff，实际为 %v", err)
+	}
+}
+
+func TestHashRing_SetReplicas(t *testing.T) {
+	r := New()
+	r.Add("a", "b")
+	if err := r.AddWithReplicas(map[string]int{"c": 20}); err != nil {
+		t.Fatalf("AddWithReplicas 失败: %v", err)
+	}
+	if err := r.SetReplicas("a", 120); err != nil {
+		t.Fatalf("SetReplicas 失败: %v", err)
+	}
+	if err := r.SetReplicas("missing", 10); err == nil {
+		t.Error("不存在的节点应返回错误")
+	}
+	if len(r.keys) != 120+50+20 {
+		t.Fatalf("虚拟节点总数应为 190，实际为 %d", len(r.keys))
+	}
+
+	// 制造负载不均后手动重平衡，只有未固定的节点会被调整
+	for i := 0; i < 10000; i++ {
+		r.Get(fmt.Sprintf("key-%d", i))
+	}
+	r.Rebalance()
+
+	replicas := r.Replicas()
+	if replicas["a"] != 120 || replicas["c"] != 20 {
+		t.Errorf("固定节点的虚拟节点数不应被调整，实际为 %v", replicas)
+	}
+	if replicas["b"] == 50 {
+		t.Errorf("未固定节点的虚拟节点数应被调整，实际为 %v", replicas)
+	}
+}
+
+func TestHashRing_ZoneAware(t *testing.T) {
+	r := New(WithZoneAware())
+	zones := map[string]string{
+		"a1": "az-1", "a2": "az-1", "a3": "az-1",
+		"b1": "az-2", "b2": "az-2",
+		"c1": "az-3",
+	}
+	for node, zone := range zones {
+		r.SetZone(node, zone)
+		r.Add(node)
+	}
+
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		nodes := r.GetN(key, 3)
+		if len(nodes) != 3 || nodes[0] != r.Get(key) {
+			t.Fatalf("GetN(%s, 3) = %v，主节点应为 %s", key, nodes, r.Get(key))
+		}
+
+		seen := make(map[string]bool)
+		for _, node := range nodes {
+			seen[zones[node]] = true
+		}
+		if len(seen) != 3 {
+			t.Fatalf("GetN(%s, 3) = %v 应覆盖 3 个机房", key, nodes)
+		}
+
+		if node := r.GetInZone(key, "az-3", 3); node != "c1" {
+			t.Fatalf("GetInZone(%s, az-3) = %s，期望 c1", key, node)
+		}
+	}
+}
+
+func TestHashRing_PlanRebalance(t *testing.T) {
+	config := *DefaultConfig
+	var audited []RebalancePlan
+	config.OnRebalance = func(plan RebalancePlan) {
+		audited = append(audited, plan)
+	}
+	r := New(WithConfig(&config))
+	r.Add("a", "b", "c")
+
+	// 制造严重的负载不均：节点 a 承担了 90% 的请求
+	loads := map[string]int64{"a": 900, "b": 50, "c": 50}
+	for node, n := range loads {
+		r.nodeCounts[node].Add(n)
+		r.totalRequests.Add(n)
+	}
+
+	before := r.Replicas()
+	plan := r.PlanRebalance()
+	if len(plan.Changes) == 0 || plan.MaxDeviation <= config.LoadBalanceThreshold {
+		t.Fatalf("负载不均时应生成调整方案，实际为 %+v", plan)
+	}
+	if after := r.Replicas(); fmt.Sprint(after) != fmt.Sprint(before) {
+		t.Fatalf("dry-run 不应修改哈希环: %v -> %v", before, after)
+	}
+
+	t.Run("环被修改后方案失效", func(t *testing.T) {
+		r.Add("d")
+		if _, err := r.applyPlan(plan); err != ErrRingChanged {
+			t.Fatalf("applyPlan 应返回 ErrRingChanged，实际为 %v", err)
+		}
+		r.Remove("d")
+	})
+
+	t.Run("应用方案", func(t *testing.T) {
+		plan, err := r.Rebalance()
+		if err != nil || !plan.Applied {
+			t.Fatalf("Rebalance 失败: %+v, %v", plan, err)
+		}
+		if len(audited) != 1 {
+			t.Errorf("方案生效后应触发一次审计回调，实际为 %d 次", len(audited))
+		}
+		if replicas := r.Replicas(); replicas["a"] >= before["a"] {
+			t.Errorf("高负载节点的虚拟节点数应减少: %v -> %v", before, replicas)
+		}
+		if r.totalRequests.Load() != 0 {
+			t.Error("方案生效后应重置负载统计")
+		}
+	})
+}
+
+func TestHashRing_Maglev(t *testing.T) {
+	config := *DefaultConfig
+	r := New(WithConfig(&config), WithMaglev(), WithHashTags())
+	nodes := []string{"a", "b", "c", "d"}
+	r.Add(nodes...)
+
+	counts := make(map[string]int)
+	const n = 100000
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		owner := r.Get(key)
+		counts[owner]++
+		if i < 1000 {
+			replicas := r.GetN(key, 3)
+			if len(replicas) != 3 || replicas[0] != owner {
+				t.Fatalf("GetN(%s, 3) = %v，第一个节点应为 Get 的结果 %s", key, replicas, owner)
+			}
+			if replicas[1] == owner || replicas[2] == owner || replicas[1] == replicas[2] {
+				t.Fatalf("GetN(%s, 3) = %v，应返回不同节点", key, replicas)
+			}
+		}
+	}
+	for _, node := range nodes {
+		// Maglev 查找表的分布应明显比 50 个虚拟节点的哈希环均匀
+		if ratio := float64(counts[node]) / n; math.Abs(ratio-0.25)/0.25 > 0.05 {
+			t.Errorf("节点 %s 请求占比 %.3f 偏离理想值过多", node, ratio)
+		}
+	}
+
+	if r.Get("{user:1}:profile") != r.Get("{user:1}:settings") {
+		t.Error("相同哈希标签的 key 应路由到同一个节点")
+	}
+
+	r.Remove("d")
+	for i := 0; i < 100; i++ {
+		if owner := r.Get(fmt.Sprintf("key-%d", i)); owner == "d" || owner == "" {
+			t.Fatalf("移除节点后 key-%d 的归属为 %q", i, owner)
+		}
+	}
+
+	data, err := r.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal 失败: %v", err)
+	}
+	restored := New(WithConfig(&config), WithMaglev(), WithHashTags())
+	if err := restored.Unmarshal(data); err != nil {
+		t.Fatalf("Unmarshal 失败: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if got, want := restored.Get(key), r.Get(key); got != want {
+			t.Fatalf("恢复后 %s 的归属为 %s，期望 %s", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package consistenthash
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"sync/atomic"
+)
+
+// ringStateVersion 持久化格式版本，格式变化时递增
+const ringStateVersion = 2
+
+// ringState 哈希环的持久化格式
+//
+// 发生哈希冲突时虚拟节点的位置取决于节点的加入顺序，因此保存各节点虚拟节点的实际哈希值，
+// 恢复时直接使用而不是重新计算；加载方会检查这些哈希值能否由本地的哈希函数得到，
+// 以发现使用了不同的哈希函数。Checksum 为所有虚拟节点哈希值的校验和，用于发现数据损坏。
+type ringState struct {
+	Version  int                 `json:"version"`
+	Replicas map[string]int      `json:"replicas"`
+	Hashes   map[string][]uint64 `json:"hashes,omitempty"`
+	Pinned   []string            `json:"pinned,omitempty"`
+	Zones    map[string]string   `json:"zones,omitempty"`
+	Checksum uint32              `json:"checksum"`
+}
+
+// Marshal 将哈希环的节点及副本数序列化为 JSON
+// 可用于进程重启后恢复，或让所有节点以完全相同的哈希环启动，避免滚动重启期间对 key 归属产生分歧
+func (r *HashRing) Marshal() ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	replicas := make(map[string]int, len(r.nodeReplicas))
+	hashes := make(map[string][]uint64, len(r.nodeHashes))
+	for node, n := range r.nodeReplicas {
+		replicas[node] = n
+		hashes[node] = append([]uint64(nil), r.nodeHashes[node]...)
+	}
+	pinned := make([]string, 0, len(r.pinned))
+	for node := range r.pinned {
+		pinned = append(pinned, node)
+	}
+	sort.Strings(pinned)
+	zones := make(map[string]string, len(r.zones))
+	for node, zone := range r.zones {
+		zones[node] = zone
+	}
+
+	return json.Marshal(ringState{
+		Version:  ringStateVersion,
+		Replicas: replicas,
+		Hashes:   hashes,
+		Pinned:   pinned,
+		Zones:    zones,
+		Checksum: ringChecksum(r.keys),
+	})
+}
+
+// Unmarshal 从 Marshal 的结果恢复哈希环，替换当前所有节点并清空负载统计
+func (r *HashRing) Unmarshal(data []byte) error {
+	var state ringState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to decode ring state: %w", err)
+	}
+	if state.Version != ringStateVersion {
+		return fmt.Errorf("unsupported ring state version %d", state.Version)
+	}
+	for node, n := range state.Replicas {
+		if node == "" || n <= 0 {
+			return fmt.Errorf("invalid replicas %d for node %q", n, node)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 先在新的结构上重建，校验通过后再替换，失败时不影响当前哈希环
+	restored := &HashRing{
+		config:       r.config,
+		hashMap:      make(map[uint64]string),
+		nodeReplicas: make(map[string]int),
+		nodeHashes:   make(map[string][]uint64),
+		pinned:       make(map[string]bool),
+		zones:        make(map[string]string),
+		nodeCounts:   make(map[string]*atomic.Int64),
+	}
+	nodes := make([]string, 0, len(state.Replicas))
+	for node := range state.Replicas {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		if err := restored.restoreNode(node, state.Replicas[node], state.Hashes[node]); err != nil {
+			return err
+		}
+	}
+	restored.sortKeys()
+	for _, node := range state.Pinned {
+		if _, exists := restored.nodeReplicas[node]; exists {
+			restored.pinned[node] = true
+		}
+	}
+
+	if checksum := ringChecksum(restored.keys); checksum != state.Checksum {
+		return fmt.Errorf("ring checksum mismatch: got %d, want %d (different hash function?)", checksum, state.Checksum)
+	}
+
+	r.keys = restored.keys
+	r.hashMap = restored.hashMap
+	r.nodeReplicas = restored.nodeReplicas
+	r.nodeHashes = restored.nodeHashes
+	r.pinned = restored.pinned
+	if state.Zones != nil {
+		r.zones = state.Zones
+	}
+	r.nodeCounts = restored.nodeCounts
+	r.totalRequests.Store(0)
+	r.version++
+	r.syncMaglev()
+	return nil
+}
+
+// restoreNode 按保存的哈希值恢复节点的虚拟节点，哈希值必须是本地哈希函数为该节点计算的候选值之一
+func (r *HashRing) restoreNode(node string, replicas int, hashes []uint64) error {
+	if len(hashes) > replicas {
+		return fmt.Errorf("node %q has %d virtual nodes, more than its %d replicas", node, len(hashes), replicas)
+	}
+
+	// addNode 为每个虚拟节点依次尝试的所有哈希值
+	candidates := make(map[uint64]bool, replicas*(maxCollisionProbes+1))
+	for replicaIdx := 0; replicaIdx < replicas; replicaIdx++ {
+		candidates[r.hashVirtualNode(node, replicaIdx)] = true
+		for probe := 1; probe <= maxCollisionProbes; probe++ {
+			candidates[r.hash(fmt.Sprintf("%s-%d#%d", node, replicaIdx, probe))] = true
+		}
+	}
+
+	for _, hash := range hashes {
+		if !candidates[hash] {
+			return fmt.Errorf("virtual node %d of %q does not match the local hash function", hash, node)
+		}
+		if owner, ok := r.hashMap[hash]; ok {
+			return fmt.Errorf("virtual node %d is shared by %q and %q", hash, owner, node)
+		}
+		r.keys = append(r.keys, hash)
+		r.hashMap[hash] = node
+	}
+	r.nodeReplicas[node] = replicas
+	r.nodeHashes[node] = append([]uint64(nil), hashes...)
+	r.nodeCounts[node] = new(atomic.Int64)
+	return nil
+}
+
+// ringChecksum 计算有序虚拟节点哈希值的校验和，调用者需保证 keys 已排序
+func ringChecksum(keys []uint64) uint32 {
+	h := crc32.NewIEEE()
+	buf := make([]byte, 0, 20)
+	for _, key := range keys {
+		buf = strconv.AppendUint(buf[:0], key, 10)
+		buf = append(buf, ',')
+		h.Write(buf)
+	}
+	return h.Sum32()
+}
@@ -0,0 +1,76 @@
+package consistenthash
+
+// SetZone 设置节点所在的机房（可用区），zone 为空表示清除标签
+// 可以在节点加入之前设置，标签与节点是否在环上无关
+func (r *HashRing) SetZone(node, zone string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if zone == "" {
+		delete(r.zones, node)
+		return
+	}
+	r.zones[node] = zone
+}
+
+// Zone 返回节点所在的机房，未设置时返回空字符串
+func (r *HashRing) Zone(node string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.zones[node]
+}
+
+// GetInZone 返回 key 的 n 个副本节点中位于指定机房的第一个节点，用于就近读取
+//
+// 副本中没有位于该机房的节点时返回主节点（与 Get 相同），因此读取总是落在副本集合内。
+// 与 Get 不同，GetInZone 不计入负载统计。
+func (r *HashRing) GetInZone(key, zone string, n int) string {
+	nodes := r.GetN(key, n)
+	if len(nodes) == 0 {
+		return ""
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, node := range nodes {
+		if r.zones[node] == zone {
+			return node
+		}
+	}
+	return nodes[0]
+}
+
+// spreadZones 从按环上顺序排列的节点中选出 n 个，优先选择尚未覆盖的机房，调用者必须持有读锁
+//
+// 第一个节点始终是主节点，之后依次选择机房与已选节点都不同的节点；
+// 机房数量不足 n 时，再按环上顺序补齐剩余节点。未设置机房的节点视为同一个机房。
+func (r *HashRing) spreadZones(ordered []string, n int) []string {
+	selected := make([]string, 0, n)
+	picked := make(map[string]bool, n)
+	usedZones := make(map[string]bool, n)
+
+	for _, node := range ordered {
+		if len(selected) == n {
+			return selected
+		}
+		zone := r.zones[node]
+		if usedZones[zone] {
+			continue
+		}
+		usedZones[zone] = true
+		picked[node] = true
+		selected = append(selected, node)
+	}
+
+	for _, node := range ordered {
+		if len(selected) == n {
+			break
+		}
+		if !picked[node] {
+			selected = append(selected, node)
+		}
+	}
+	return selected
+}
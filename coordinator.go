@@ -0,0 +1,52 @@
+package mycache
+
+import (
+	"context"
+	"errors"
+
+	"github.com/linhx1999/MyCache-Go/registry"
+)
+
+// errCoordinatorNeedsEtcd 配置了协调者任务但没有使用 etcd 注册
+var errCoordinatorNeedsEtcd = errors.New("cache: coordinator tasks require etcd registry")
+
+// coordinatorTask 只在协调者上运行的后台任务
+type coordinatorTask struct {
+	name string
+	fn   func(ctx context.Context)
+}
+
+// WithCoordinatorTask 添加只在集群协调者上运行的后台任务，如反熵调度、全局统计汇总和迁移编排
+//
+// 服务器启动后各节点通过 etcd 选举出一个协调者（见 registry.Coordinator），只有协调者运行这些任务；
+// 协调者宕机或与 etcd 断开时 ctx 被取消，其他节点在选举租约过期后接替并重新启动任务。
+// 需要使用 etcd 注册，与 WithoutRegistry 和 WithDiscovery 一起使用时 NewServer 返回错误。
+func WithCoordinatorTask(name string, task func(ctx context.Context)) ServerOption {
+	return func(o *ServerOptions) {
+		o.CoordinatorTasks = append(o.CoordinatorTasks, coordinatorTask{name: name, fn: task})
+	}
+}
+
+// WithCoordinatorOptions 设置协调者选举的选项，如 registry.WithElectionTTL
+func WithCoordinatorOptions(opts ...registry.CoordinatorOption) ServerOption {
+	return func(o *ServerOptions) {
+		o.CoordinatorOptions = append(o.CoordinatorOptions, opts...)
+	}
+}
+
+// newCoordinator 配置了协调者任务时创建选举，否则返回 nil
+func (s *Server) newCoordinator() *registry.Coordinator {
+	if len(s.opts.CoordinatorTasks) == 0 {
+		return nil
+	}
+	c := registry.NewCoordinator(s.etcdCli, s.svcName, s.AdvertiseAddr(), s.opts.CoordinatorOptions...)
+	for _, task := range s.opts.CoordinatorTasks {
+		c.Go(task.name, task.fn)
+	}
+	return c
+}
+
+// IsCoordinator 判断本节点当前是否是集群协调者，没有配置协调者任务时返回 false
+func (s *Server) IsCoordinator() bool {
+	return s.coordinator != nil && s.coordinator.IsLeader()
+}
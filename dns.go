@@ -0,0 +1,109 @@
+package mycache
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultDNSInterval DNS 服务发现的默认解析间隔
+const defaultDNSInterval = 10 * time.Second
+
+// WithDNSInterval 设置 DNS 服务发现重新解析记录的间隔
+func WithDNSInterval(interval time.Duration) PickerOption {
+	return func(p *ClientPicker) {
+		p.dnsInterval = interval
+	}
+}
+
+// NewDNSPicker 创建通过 DNS 发现节点的 ClientPicker，适用于 Kubernetes headless service 等云原生部署
+//
+// name 带端口时（如 "cache.default.svc.cluster.local:8001"）解析 A/AAAA 记录，
+// 每个 IP 加上该端口作为节点地址；不带端口时（如 "_grpc._tcp.cache.default.svc.cluster.local"）
+// 解析 SRV 记录，使用记录中的目标主机和端口。之后按 WithDNSInterval 设置的间隔重新解析，
+// 节点列表变化时更新哈希环。
+//
+// self 需与解析结果的形式一致（A 记录时通常为 "PodIP:端口"），否则本节点会被当作远程节点。
+// 首次解析失败不会返回错误（例如启动时还没有就绪的 Pod），会在后台继续重试。
+func NewDNSPicker(self, name string, opts ...PickerOption) (*ClientPicker, error) {
+	if name == "" {
+		return nil, fmt.Errorf("cache: dns name is required")
+	}
+
+	picker := newPicker(self, opts)
+
+	picker.refreshDNS(name)
+	go picker.watchDNS(name)
+
+	return picker, nil
+}
+
+// watchDNS 定期重新解析 DNS 记录，直到 picker 关闭
+func (p *ClientPicker) watchDNS(name string) {
+	interval := p.dnsInterval
+	if interval <= 0 {
+		interval = defaultDNSInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			p.refreshDNS(name)
+		}
+	}
+}
+
+// refreshDNS 解析一次 DNS 记录并更新节点列表，解析失败时保留当前节点
+func (p *ClientPicker) refreshDNS(name string) {
+	ctx, cancel := context.WithTimeout(p.ctx, 3*time.Second)
+	defer cancel()
+
+	addrs, err := resolvePeers(ctx, net.DefaultResolver, name)
+	if err != nil {
+		log.Printf("[PeerPicker] failed to resolve %s: %v", name, err)
+		return
+	}
+	p.SetPeers(addrs...)
+}
+
+// resolvePeers 解析 name 对应的节点地址，结果已排序去重
+func resolvePeers(ctx context.Context, resolver *net.Resolver, name string) ([]string, error) {
+	var addrs []string
+	if host, port, err := net.SplitHostPort(name); err == nil {
+		ips, err := resolver.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			addrs = append(addrs, net.JoinHostPort(ip, port))
+		}
+	} else {
+		_, records, err := resolver.LookupSRV(ctx, "", "", name)
+		if err != nil {
+			return nil, err
+		}
+		for _, srv := range records {
+			target := strings.TrimSuffix(srv.Target, ".")
+			addrs = append(addrs, net.JoinHostPort(target, strconv.Itoa(int(srv.Port))))
+		}
+	}
+
+	sort.Strings(addrs)
+	out := addrs[:0]
+	for i, addr := range addrs {
+		if i == 0 || addr != addrs[i-1] {
+			out = append(out, addr)
+		}
+	}
+	return out, nil
+}
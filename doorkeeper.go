@@ -0,0 +1,105 @@
+package mycache
+
+import (
+	"hash/maphash"
+	"math"
+	"sync"
+	"time"
+)
+
+// defaultDoorkeeperKeys 未指定时每个窗口预期的不同 key 数量
+const defaultDoorkeeperKeys = 100000
+
+// doorkeeperFalsePositive 布隆过滤器的目标误判率，误判只会让少数首次访问的值被缓存
+const doorkeeperFalsePositive = 0.01
+
+// WithDoorkeeper 启用准入过滤，数据源或其他节点加载的值在窗口内第二次访问时才写入本地缓存
+//
+// 扫描类流量（如遍历全部 key 的离线任务）中的 key 大多只访问一次，直接缓存会把真正的热点挤出缓存。
+// 启用后首次未命中的 key 只记录在布隆过滤器中，加载结果照常返回但不缓存，window 内再次未命中时才缓存。
+// 过滤器每个 window 轮换一次并保留上一代，两次访问间隔不超过 window 时一定会被缓存。
+// expectedKeys 为每个窗口预期的不同 key 数量，用于确定过滤器大小，不大于 0 时使用 100000；
+// 超出后误判率上升，更多一次性的 key 会被缓存。Set 和 Refresh 写入的值不经过准入过滤。
+func WithDoorkeeper(window time.Duration, expectedKeys int) GroupOption {
+	return func(g *Group) {
+		if window <= 0 {
+			g.doorkeeper = nil
+			return
+		}
+		g.doorkeeper = newDoorkeeper(window, expectedKeys)
+	}
+}
+
+// doorkeeper 两代轮换的布隆过滤器，记录窗口内访问过的 key
+type doorkeeper struct {
+	mu       sync.Mutex
+	window   time.Duration
+	seed     maphash.Seed
+	hashes   int // 每个 key 设置的位数
+	current  []uint64
+	previous []uint64
+	rotated  time.Time // 上次轮换的时间
+}
+
+// newDoorkeeper 按预期的 key 数量和目标误判率确定过滤器大小
+func newDoorkeeper(window time.Duration, expectedKeys int) *doorkeeper {
+	if expectedKeys <= 0 {
+		expectedKeys = defaultDoorkeeperKeys
+	}
+	// m = -n·ln(p)/ln(2)²，k = m/n·ln(2)
+	bits := int(math.Ceil(-float64(expectedKeys) * math.Log(doorkeeperFalsePositive) / (math.Ln2 * math.Ln2)))
+	words := (bits + 63) / 64
+	hashes := int(math.Round(float64(words*64) / float64(expectedKeys) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+	return &doorkeeper{
+		window:   window,
+		seed:     maphash.MakeSeed(),
+		hashes:   hashes,
+		current:  make([]uint64, words),
+		previous: make([]uint64, words),
+		rotated:  time.Now(),
+	}
+}
+
+// admit 记录一次访问，返回 key 在本窗口或上一个窗口中是否已经访问过
+func (d *doorkeeper) admit(key string) bool {
+	h := maphash.String(d.seed, key)
+	// 双重哈希：第 i 个位置为 h1 + i·h2
+	h1, h2 := uint32(h), uint32(h>>32)|1
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.rotate(time.Now())
+	n := uint32(len(d.current) * 64)
+	seen, seenBefore := true, true
+	for i := 0; i < d.hashes; i++ {
+		pos := (h1 + uint32(i)*h2) % n
+		word, bit := pos/64, uint64(1)<<(pos%64)
+		if d.current[word]&bit == 0 {
+			seen = false
+			d.current[word] |= bit
+		}
+		if d.previous[word]&bit == 0 {
+			seenBefore = false
+		}
+	}
+	return seen || seenBefore
+}
+
+// rotate 超过窗口时将当前过滤器转为上一代，长时间没有访问时两代都清空
+func (d *doorkeeper) rotate(now time.Time) {
+	elapsed := now.Sub(d.rotated)
+	if elapsed < d.window {
+		return
+	}
+	if elapsed >= 2*d.window {
+		clear(d.previous)
+	} else {
+		d.previous, d.current = d.current, d.previous
+	}
+	clear(d.current)
+	d.rotated = now
+}
@@ -0,0 +1,140 @@
+package mycache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// encryptionVersion 密文格式的版本，密文为：版本(1 字节) | nonce | AES-GCM 密文和认证标签
+const encryptionVersion = 1
+
+// ErrDecrypt 缓存值解密失败，如密钥已更换或数据被篡改
+var ErrDecrypt = errors.New("cache: failed to decrypt value")
+
+// KeyProvider 返回加密缓存值使用的 AES 密钥（16、24 或 32 字节），可从环境变量、文件或 KMS 获取
+type KeyProvider func() ([]byte, error)
+
+// StaticKey 返回固定密钥的 KeyProvider
+func StaticKey(key []byte) KeyProvider {
+	return func() ([]byte, error) {
+		return key, nil
+	}
+}
+
+// EnvKey 返回从环境变量读取密钥的 KeyProvider，变量值为 base64 或 hex 编码的密钥
+func EnvKey(name string) KeyProvider {
+	return func() ([]byte, error) {
+		value := strings.TrimSpace(os.Getenv(name))
+		if value == "" {
+			return nil, fmt.Errorf("cache: encryption key env %s is not set", name)
+		}
+		if key, err := hex.DecodeString(value); err == nil {
+			return key, nil
+		}
+		if key, err := base64.StdEncoding.DecodeString(value); err == nil {
+			return key, nil
+		}
+		return nil, fmt.Errorf("cache: encryption key env %s is neither hex nor base64", name)
+	}
+}
+
+// WithEncryption 使用 AES-GCM 加密本地缓存中的值，包括内存、磁盘层和快照中的副本
+//
+// 值在写入本地缓存时加密、读取时解密，内存转储或磁盘快照中不会出现明文。
+// 节点间传输的值是明文，应配合 WithTLS 和 WithClientTLS 加密传输。
+// provider 在创建组时调用一次；获取密钥失败时组不再缓存任何值（所有读取都回源），不会退化为明文存储。
+func WithEncryption(provider KeyProvider) GroupOption {
+	return func(g *Group) {
+		g.cipher = newValueCipher(g.name, provider)
+	}
+}
+
+// valueCipher 加解密缓存值
+type valueCipher struct {
+	aead cipher.AEAD // 为 nil 表示获取密钥失败，拒绝缓存任何值
+}
+
+// newValueCipher 获取密钥并创建 AES-GCM，失败时返回拒绝缓存的 valueCipher
+func newValueCipher(group string, provider KeyProvider) *valueCipher {
+	key, err := provider()
+	if err == nil {
+		var block cipher.Block
+		if block, err = aes.NewCipher(key); err == nil {
+			var aead cipher.AEAD
+			if aead, err = cipher.NewGCM(block); err == nil {
+				return &valueCipher{aead: aead}
+			}
+		}
+	}
+	log.Printf("[MyCache] ERROR: encryption disabled caching for group [%s]: %v", group, err)
+	return &valueCipher{}
+}
+
+// usable 判断是否可以写入缓存：未启用加密或密钥可用
+func (c *valueCipher) usable() bool {
+	return c == nil || c.aead != nil
+}
+
+// seal 加密 plaintext
+func (c *valueCipher) seal(plaintext []byte) ([]byte, error) {
+	if c.aead == nil {
+		return nil, errors.New("cache: encryption key unavailable")
+	}
+	nonceSize := c.aead.NonceSize()
+	out := make([]byte, 1+nonceSize, 1+nonceSize+len(plaintext)+c.aead.Overhead())
+	out[0] = encryptionVersion
+	if _, err := rand.Read(out[1:]); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(out, out[1:], plaintext, nil), nil
+}
+
+// open 解密 seal 返回的密文
+func (c *valueCipher) open(ciphertext []byte) ([]byte, error) {
+	if c.aead == nil {
+		return nil, ErrDecrypt
+	}
+	nonceSize := c.aead.NonceSize()
+	if len(ciphertext) < 1+nonceSize || ciphertext[0] != encryptionVersion {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := c.aead.Open(nil, ciphertext[1:1+nonceSize], ciphertext[1+nonceSize:], nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// sealView 返回存储用的加密视图，c 为 nil 时原样返回
+func (c *valueCipher) sealView(view ByteView) (ByteView, error) {
+	if c == nil {
+		return view, nil
+	}
+	b, err := c.seal(view.b)
+	if err != nil {
+		return ByteView{}, err
+	}
+	view.b = b
+	return view, nil
+}
+
+// openView 解密存储的视图，c 为 nil 时原样返回
+func (c *valueCipher) openView(view ByteView) (ByteView, error) {
+	if c == nil {
+		return view, nil
+	}
+	b, err := c.open(view.b)
+	if err != nil {
+		return ByteView{}, err
+	}
+	view.b = b
+	return view, nil
+}
@@ -0,0 +1,57 @@
+package mycache
+
+import "time"
+
+// EntrySource 一次读取的数据来源
+type EntrySource int
+
+const (
+	SourceLocal  EntrySource = iota // 本地缓存命中
+	SourcePeer                      // 从远程节点获取
+	SourceLoader                    // 调用 DataSource 从数据源加载
+)
+
+// String 返回数据来源的可读名称
+func (s EntrySource) String() string {
+	switch s {
+	case SourceLocal:
+		return "local"
+	case SourcePeer:
+		return "peer"
+	case SourceLoader:
+		return "loader"
+	default:
+		return "unknown"
+	}
+}
+
+// EntryInfo 缓存项的元信息
+type EntryInfo struct {
+	Source    EntrySource   // 本次读取的数据来源
+	TTL       time.Duration // 剩余存活时间，仅当 ExpiresAt 非零时有意义
+	ExpiresAt time.Time     // 过期时间，零值表示永不过期
+	WrittenAt time.Time     // 最近一次写入本地缓存的时间
+}
+
+// loadResult 一次读取的结果及其数据来源
+type loadResult struct {
+	view   ByteView
+	source EntrySource
+}
+
+// newEntryInfo 根据读取结果构造元信息
+func newEntryInfo(result loadResult) EntryInfo {
+	info := EntryInfo{
+		Source:    result.source,
+		ExpiresAt: result.view.expire,
+		WrittenAt: result.view.written,
+	}
+
+	if !info.ExpiresAt.IsZero() {
+		if ttl := time.Until(info.ExpiresAt); ttl > 0 {
+			info.TTL = ttl
+		}
+	}
+
+	return info
+}
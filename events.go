@@ -0,0 +1,148 @@
+package mycache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultEventBufferSize 每个订阅者通道的默认缓冲大小
+const defaultEventBufferSize = 256
+
+// EventType 缓存变更事件类型
+type EventType int
+
+const (
+	EventSet    EventType = iota // 写入缓存
+	EventDelete                  // 主动删除（包括 Clear）
+	EventEvict                   // 容量不足被淘汰
+	EventExpire                  // 过期被移除
+)
+
+// String 返回事件类型的可读名称
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+	case EventDelete:
+		return "delete"
+	case EventEvict:
+		return "evict"
+	case EventExpire:
+		return "expire"
+	default:
+		return "unknown"
+	}
+}
+
+// EventOrigin 事件来源
+type EventOrigin int
+
+const (
+	OriginLocal    EventOrigin = iota // 本节点发起的操作，或由本地存储触发的淘汰/过期
+	OriginPeer                        // 其他节点同步过来的操作
+	OriginExternal                    // 外部失效总线广播的删除，见 WithInvalidation
+)
+
+// String 返回事件来源的可读名称
+func (o EventOrigin) String() string {
+	switch o {
+	case OriginPeer:
+		return "peer"
+	case OriginExternal:
+		return "external"
+	default:
+		return "local"
+	}
+}
+
+// Event 描述一次缓存变更
+type Event struct {
+	Type   EventType   // 事件类型
+	Group  string      // 所属缓存组
+	Key    string      // 缓存键
+	Value  ByteView    // Set 时为新值，Evict/Expire 时为被移除的值，Delete 时为空
+	Origin EventOrigin // 事件来源
+	Time   time.Time   // 事件发生时间
+}
+
+// eventBus 管理事件订阅者，向所有订阅者广播缓存变更事件
+//
+// 广播采用非阻塞发送：订阅者消费过慢导致通道写满时，事件会被丢弃并计数，
+// 保证缓存读写路径不会被订阅者拖慢。
+type eventBus struct {
+	mu         sync.RWMutex
+	subs       map[uint64]chan Event
+	nextID     uint64
+	bufferSize int
+	closed     bool
+	dropped    atomic.Int64 // 因订阅者通道已满而丢弃的事件数
+}
+
+// subscribe 注册一个订阅者，返回事件通道和取消函数
+func (b *eventBus) subscribe() (<-chan Event, func()) {
+	size := b.bufferSize
+	if size <= 0 {
+		size = defaultEventBufferSize
+	}
+	ch := make(chan Event, size)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// 总线已关闭时返回一个已关闭的通道，订阅者可以立即感知
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	if b.subs == nil {
+		b.subs = make(map[uint64]chan Event)
+	}
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = ch
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if sub, ok := b.subs[id]; ok {
+				delete(b.subs, id)
+				close(sub)
+			}
+		})
+	}
+	return ch, cancel
+}
+
+// publish 向所有订阅者广播事件
+func (b *eventBus) publish(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if len(b.subs) == 0 {
+		return
+	}
+
+	for _, ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			b.dropped.Add(1)
+		}
+	}
+}
+
+// close 关闭所有订阅者通道，之后的订阅会立即得到已关闭的通道
+func (b *eventBus) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for id, ch := range b.subs {
+		delete(b.subs, id)
+		close(ch)
+	}
+}
@@ -40,6 +40,7 @@ func main() {
 	picker := createPeerPicker(addr, nodeID)
 	group := createCacheGroup(nodeID)
 	group.RegisterPeers(picker)
+	node.RegisterGroup(group)
 
 	startServer(node, nodeID)
 	waitForRegistry(nodeID)
@@ -9,6 +9,8 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/linhx1999/MyCache-Go/cdc"
+	"github.com/linhx1999/MyCache-Go/invalidation"
 	"github.com/linhx1999/MyCache-Go/singleflight"
 )
 
@@ -26,6 +28,27 @@ var ErrValueRequired = errors.New("cache: value is required")
 // ErrGroupClosed 组已关闭错误
 var ErrGroupClosed = errors.New("cache: group is closed")
 
+// ErrQuotaExceeded 超出组内存配额错误，可与 errors.Is 配合使用
+var ErrQuotaExceeded = errors.New("cache: group memory quota exceeded")
+
+// QuotaExceededError 写入会导致组占用的字节数超过配额时返回
+type QuotaExceededError struct {
+	Group     string // 组名
+	Used      int64  // 当前已使用的字节数
+	Requested int64  // 本次写入新增的字节数
+	Quota     int64  // 配额
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("cache: group %s memory quota exceeded: used=%d, requested=%d, quota=%d",
+		e.Group, e.Used, e.Requested, e.Quota)
+}
+
+// Is 使 errors.Is(err, ErrQuotaExceeded) 成立
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
 // DataSource 数据源接口，用于从外部数据源加载数据
 type DataSource interface {
 	Get(ctx context.Context, key string) ([]byte, error)
@@ -75,21 +98,76 @@ type Group struct {
 	localCache         *Cache              // 本地缓存实例，存储实际数据
 	peers              PeerPicker          // 节点选择器，用于分布式缓存中的节点路由
 	singleFlightLoader *singleflight.Group // SingleFlight 加载器，防止缓存击穿
+	refreshLoader      *singleflight.Group // 强制刷新专用的 SingleFlight 加载器，避免与普通加载共享结果
 	expiration         time.Duration       // 缓存过期时间，0 表示永不过期
+	memoryQuota        int64               // 组内存配额（字节），0 表示不限制
+	policy             KeyPolicy           // key 长度、value 大小和 key 字符的限制
+	loadRetry          loadRetryPolicy     // 数据源加载的重试策略
+	ownerOnlyLoad      bool                // owner 节点可达时只由 owner 回源，本节点不再自行加载
+	hedgeDelay         time.Duration       // 对冲读的延迟，0 表示不启用
+	migrateDelay       time.Duration       // 拓扑变化后自动迁移前的等待时间，0 表示不启用
+	migrateDrop        bool                // 迁移成功后是否从本地删除
+	migrateStop        chan struct{}       // 关闭时停止自动迁移
+	readRepairReplicas int                 // 读修复检查的副本数量，不大于 1 表示不启用
+	replicas           int                 // 副本数量（包含 owner），用于多副本写入和一致性级别
+	readConsistency    ConsistencyLevel    // 读一致性级别
+	writeConsistency   ConsistencyLevel    // 写一致性级别
+	hints              *hintQueue          // 同步失败等待重放的写操作，nil 表示不启用提示移交
+	hintInterval       time.Duration       // 提示重放周期
+	hintStop           chan struct{}       // 关闭时停止提示重放
+	hintDone           chan struct{}       // 提示重放已停止
+	snapshotPath       string              // 快照文件路径，为空表示不启用
+	snapshotInterval   time.Duration       // 定期写入快照的周期，0 表示只在关闭时写入
+	snapshotStop       chan struct{}       // 关闭时停止定期快照
+	snapshotDone       chan struct{}       // 定期快照已停止
+	restoreDir         string              // 创建组时从该备份恢复，为空表示不恢复
+	cipher             *valueCipher        // 本地缓存和快照中值的加密，nil 表示不加密
+	doorkeeper         *doorkeeper         // 加载结果的准入过滤，nil 表示不启用
+	topKeys            *spaceSaving        // 热点 key 统计，nil 表示不启用
+	locks              lockTable           // 本节点作为 owner 管理的分布式锁
+	invalidation       *invalidation.Bus   // 外部失效总线，nil 表示不订阅
+	invalidationStop   func()              // 取消失效消息的订阅
+	changeSink         cdc.Sink            // 变更流的输出目标，nil 表示不输出
+	changeConfig       cdc.Config          // 变更流配置
+	changes            *cdc.Stream         // 变更流，组创建时启动
+	syncInterval       time.Duration       // 批量同步的发送间隔，0 表示每次写入单独同步
+	syncMaxBatch       int                 // 每个节点每批最多同步的 key 数量
+	syncer             *syncBatcher        // 按节点合并同步的写操作，nil 表示不启用批量同步
 	closed             atomic.Int32        // 原子变量，标记组是否已关闭（0=运行中，1=已关闭）
 	stats              groupStats          // 统计信息，记录命中率、加载次数等指标
+	events             eventBus            // 事件总线，向订阅者广播缓存变更
 }
 
 // groupStats 保存组的统计信息
 type groupStats struct {
-	loads        atomic.Int64 // 加载次数
-	localHits    atomic.Int64 // 本地缓存命中次数
-	localMisses  atomic.Int64 // 本地缓存未命中次数
-	peerHits     atomic.Int64 // 从对等节点获取成功次数
-	peerMisses   atomic.Int64 // 从对等节点获取失败次数
-	loaderHits   atomic.Int64 // 从加载器获取成功次数
-	loaderErrors atomic.Int64 // 从加载器获取失败次数
-	loadDuration atomic.Int64 // 加载总耗时（纳秒）
+	loads             atomic.Int64 // 加载次数
+	localHits         atomic.Int64 // 本地缓存命中次数
+	localMisses       atomic.Int64 // 本地缓存未命中次数
+	peerHits          atomic.Int64 // 从对等节点获取成功次数
+	peerMisses        atomic.Int64 // 从对等节点获取失败次数
+	loaderHits        atomic.Int64 // 从加载器获取成功次数
+	loaderErrors      atomic.Int64 // 从加载器获取失败次数
+	loadRetries       atomic.Int64 // 数据源加载重试次数
+	loadDuration      atomic.Int64 // 加载总耗时（纳秒）
+	quotaRejects      atomic.Int64 // 因超出内存配额被拒绝的写入次数
+	ownerErrors       atomic.Int64 // owner 已处理但返回错误、未在本节点回源的加载次数
+	hedgedReads       atomic.Int64 // 发出的对冲读请求次数
+	hedgeWins         atomic.Int64 // 对冲读请求先于 owner 返回的次数
+	migratedKeys      atomic.Int64 // 迁移到新 owner 的 key 数量
+	readRepairs       atomic.Int64 // 读修复写回的副本数量
+	consistencyErrors atomic.Int64 // 未达到一致性级别的读写次数
+	hintsQueued       atomic.Int64 // 同步失败后保存的提示数量
+	hintsReplayed     atomic.Int64 // 重放成功的提示数量
+	hintsDropped      atomic.Int64 // 因队列已满或已过期被丢弃的提示数量
+	replicaReads      atomic.Int64 // owner 不可达时从副本节点读取成功的次数
+	zoneReads         atomic.Int64 // 从同机房副本而不是 owner 读取的次数
+	snapshotSaves     atomic.Int64 // 写入快照的次数
+	snapshotLoads     atomic.Int64 // 从快照恢复的缓存项数量
+	backupRestores    atomic.Int64 // 从集群备份恢复的缓存项数量
+	admissionRejects  atomic.Int64 // 首次访问、被准入过滤拒绝缓存的加载次数
+	invalidations     atomic.Int64 // 收到外部失效消息后删除的本地副本数量
+	syncBatches       atomic.Int64 // 批量同步发出的批次数
+	syncCoalesced     atomic.Int64 // 发送前被同一 key 的后续写操作覆盖的同步数量
 }
 
 // GroupOption 定义Group的配置选项
@@ -116,6 +194,41 @@ func WithCacheOptions(opts CacheOptions) GroupOption {
 	}
 }
 
+// WithMemoryQuota 设置组的内存配额（字节）
+// 超出配额的 Set 会返回 *QuotaExceededError，从数据源加载的数据在超出配额时不会写入本地缓存
+func WithMemoryQuota(bytes int64) GroupOption {
+	return func(g *Group) {
+		g.memoryQuota = bytes
+	}
+}
+
+// WithSingleFlightOptions 设置加载使用的 SingleFlight 选项，如结果缓存窗口
+func WithSingleFlightOptions(opts ...singleflight.Option) GroupOption {
+	return func(g *Group) {
+		g.singleFlightLoader = singleflight.New(opts...)
+		g.refreshLoader = singleflight.New(opts...)
+	}
+}
+
+// WithOwnerOnlyLoad 启用跨节点的加载去重
+//
+// 默认情况下，从 owner 节点获取失败后本节点会自行回源，owner 返回的业务错误（如数据
+// 不存在）也会导致每个未命中的节点各查询一次数据源。启用后只有 owner 不可达（连接失败、
+// 超时）时才在本节点回源，其余错误直接返回，使 N 个节点在同一 key 上的未命中只产生
+// 一次数据源查询（由 owner 节点的 SingleFlight 合并）。
+func WithOwnerOnlyLoad() GroupOption {
+	return func(g *Group) {
+		g.ownerOnlyLoad = true
+	}
+}
+
+// WithEventBufferSize 设置每个事件订阅者通道的缓冲大小
+func WithEventBufferSize(size int) GroupOption {
+	return func(g *Group) {
+		g.events.bufferSize = size
+	}
+}
+
 // NewGroup 创建一个新的 Group 实例
 func NewGroup(name string, cacheBytes int64, dataSource DataSource, opts ...GroupOption) *Group {
 	if dataSource == nil {
@@ -130,7 +243,10 @@ func NewGroup(name string, cacheBytes int64, dataSource DataSource, opts ...Grou
 		name:               name,
 		dataSource:         dataSource,
 		localCache:         NewCache(cacheOpts),
-		singleFlightLoader: &singleflight.Group{},
+		singleFlightLoader: singleflight.New(),
+		refreshLoader:      singleflight.New(),
+		syncInterval:       defaultSyncInterval,
+		syncMaxBatch:       defaultSyncMaxBatch,
 	}
 
 	// 应用选项
@@ -138,6 +254,20 @@ func NewGroup(name string, cacheBytes int64, dataSource DataSource, opts ...Grou
 		opt(g)
 	}
 
+	// 监听本地存储的淘汰和过期，转换为事件
+	g.localCache.setRemovalListener(g.onCacheRemoved)
+	if g.cipher != nil {
+		g.localCache.setCipher(g.cipher)
+	}
+
+	g.startRestore()
+	g.startSnapshots()
+	g.startMigration()
+	g.startHandoff()
+	g.startInvalidation()
+	g.startChangeStream()
+	g.startSyncBatching()
+
 	// 注册到全局组映射
 	groupsMu.Lock()
 	defer groupsMu.Unlock()
@@ -161,20 +291,51 @@ func GetGroup(name string) *Group {
 
 // Get 从缓存获取数据
 func (g *Group) Get(ctx context.Context, key string) (ByteView, error) {
+	result, err := g.get(ctx, key)
+	return result.view, err
+}
+
+// GetWithInfo 从缓存获取数据，同时返回缓存项的元信息（数据来源、剩余 TTL、写入时间）
+// 可用于生成缓存年龄相关的响应头，或排查数据陈旧问题
+func (g *Group) GetWithInfo(ctx context.Context, key string) (ByteView, EntryInfo, error) {
+	result, err := g.get(ctx, key)
+	if err != nil {
+		return ByteView{}, EntryInfo{}, err
+	}
+	return result.view, newEntryInfo(result), nil
+}
+
+// get 依次尝试本地缓存、远程节点和数据源，并记录数据来源
+func (g *Group) get(ctx context.Context, key string) (loadResult, error) {
 	// 检查组是否已关闭
 	if g.closed.Load() == 1 {
-		return ByteView{}, ErrGroupClosed
+		return loadResult{}, ErrGroupClosed
 	}
 
 	if key == "" {
-		return ByteView{}, ErrKeyRequired
+		return loadResult{}, ErrKeyRequired
+	}
+	if err := g.policy.checkKey(ctx, key); err != nil {
+		return loadResult{}, err
+	}
+	// 调用方已经放弃的请求不再读取缓存或加载
+	if err := ctx.Err(); err != nil {
+		return loadResult{}, err
+	}
+	g.recordAccess(key)
+
+	// 读一致性高于 ONE 时从多个副本读取，其他节点转发过来的请求只读本地
+	if g.readConsistency > ConsistencyOne && g.peers != nil && ctx.Value("from_peer") == nil {
+		if result, handled, err := g.getConsistent(ctx, key); handled {
+			return result, err
+		}
 	}
 
 	// 从本地缓存获取
 	byteView, ok := g.localCache.Get(ctx, key)
 	if ok {
 		g.stats.localHits.Add(1)
-		return byteView, nil
+		return loadResult{view: byteView, source: SourceLocal}, nil
 	}
 
 	g.stats.localMisses.Add(1)
@@ -198,20 +359,42 @@ func (g *Group) Set(ctx context.Context, key string, value []byte) error {
 		return ErrValueRequired
 	}
 
-	// 创建缓存视图
-	byteView := ByteView{b: cloneBytes(value)}
+	if err := g.policy.checkEntry(ctx, key, value); err != nil {
+		return err
+	}
 
-	// 设置到本地缓存
-	if g.expiration > 0 {
-		g.localCache.AddWithExpiration(key, byteView, time.Now().Add(g.expiration))
+	// 指定了过期时间（SetWithTTL 或其他节点同步）且已过期的值不再写入
+	expire := writeExpireFromContext(ctx)
+	if !expire.IsZero() && !time.Now().Before(expire) {
+		return nil
+	}
+
+	if err := g.checkQuota(key, len(value)); err != nil {
+		g.stats.quotaRejects.Add(1)
+		return err
+	}
+
+	// 创建缓存视图并设置到本地缓存
+	var byteView ByteView
+	if expire.IsZero() {
+		byteView = g.saveToLocal(key, ByteView{b: cloneBytes(value)})
 	} else {
-		g.localCache.Add(key, byteView)
+		byteView = g.saveWithExpire(key, ByteView{b: cloneBytes(value)}, expire)
 	}
+	g.forgetLoads(key)
+
+	g.publish(EventSet, key, byteView, originFromContext(ctx))
 
 	// 如果不是从其他节点同步过来的请求，且启用了分布式模式，同步到其他节点
+	// 同步时携带本地的过期时间，其他节点上的副本与本地同时过期
 	isPeerRequest := ctx.Value("from_peer") != nil
 	if !isPeerRequest && g.peers != nil {
-		go g.syncToPeers("set", key, value)
+		if g.writeConsistency > ConsistencyOne {
+			if handled, err := g.writeConsistent(withWriteExpire(ctx, byteView.expire), "set", key, value); handled {
+				return err
+			}
+		}
+		g.syncAsync("set", key, value, byteView.expire)
 	}
 
 	return nil
@@ -230,24 +413,35 @@ func (g *Group) Delete(ctx context.Context, key string) error {
 
 	// 从本地缓存删除
 	g.localCache.Delete(key)
+	g.forgetLoads(key)
+	g.publish(EventDelete, key, ByteView{}, originFromContext(ctx))
 
 	// 检查是否是从其他节点同步过来的请求
 	isPeerRequest := ctx.Value("from_peer") != nil
 
 	// 如果不是从其他节点同步过来的请求，且启用了分布式模式，同步到其他节点
 	if !isPeerRequest && g.peers != nil {
-		go g.syncToPeers("delete", key, nil)
+		if g.writeConsistency > ConsistencyOne {
+			if handled, err := g.writeConsistent(ctx, "delete", key, nil); handled {
+				return err
+			}
+		}
+		g.syncAsync("delete", key, nil, time.Time{})
 	}
 
 	return nil
 }
 
-// syncToPeers 同步操作到其他节点
-func (g *Group) syncToPeers(op string, key string, value []byte) {
+// syncToPeers 同步操作到其他节点，expire 为 set 的过期时间，零值表示永不过期
+func (g *Group) syncToPeers(op string, key string, value []byte, expire time.Time) {
 	if g.peers == nil {
 		return
 	}
 
+	if g.replicas > 1 && g.syncToReplicas(op, key, value, expire) {
+		return
+	}
+
 	// 选择对等节点
 	peer, ok, isSelf := g.peers.PickPeer(key)
 	if !ok || isSelf {
@@ -255,19 +449,26 @@ func (g *Group) syncToPeers(op string, key string, value []byte) {
 	}
 
 	// 创建同步请求上下文
-	syncCtx := context.WithValue(context.Background(), "from_peer", true)
+	syncCtx := withWriteExpire(context.WithValue(context.Background(), "from_peer", true), expire)
 
 	var err error
 	switch op {
 	case "set":
 		err = peer.Set(syncCtx, g.name, key, value)
 	case "delete":
-		_, err = peer.Delete(g.name, key)
+		_, err = peer.Delete(syncCtx, g.name, key)
 	}
 
 	if err != nil {
+		// 超过大小限制的值重放也不会成功
+		if !errors.Is(err, ErrValueTooLarge) && g.addHint(op, key, value, expire) {
+			log.Printf("[MyCache] failed to sync %s to peer, queued for handoff: %v", op, err)
+			return
+		}
 		log.Printf("[MyCache] failed to sync %s to peer: %v", op, err)
+		return
 	}
+	g.clearHint(key)
 }
 
 // Clear 清空缓存
@@ -281,6 +482,14 @@ func (g *Group) Clear() {
 	log.Printf("[MyCache] cleared cache for group [%s]", g.name)
 }
 
+// PurgeExpired 立即清理本地缓存中所有已过期的项，返回清理的数量
+func (g *Group) PurgeExpired() int {
+	if g.closed.Load() == 1 {
+		return 0
+	}
+	return g.localCache.PurgeExpired()
+}
+
 // Close 关闭组并释放资源
 func (g *Group) Close() error {
 	// 如果已经关闭，直接返回
@@ -288,11 +497,26 @@ func (g *Group) Close() error {
 		return nil
 	}
 
+	// 写入最后一次快照后再关闭本地缓存
+	g.stopSnapshots()
+	g.stopInvalidation()
+	// 发出尚未同步的写操作，失败的写操作在停止提示移交前保存为提示
+	g.stopSyncBatching()
+
 	// 关闭本地缓存
 	if g.localCache != nil {
 		g.localCache.Close()
 	}
 
+	// 关闭所有事件订阅
+	g.events.close()
+	g.stopChangeStream()
+
+	if g.migrateStop != nil {
+		close(g.migrateStop)
+	}
+	g.stopHandoff()
+
 	// 从全局组映射中移除
 	groupsMu.Lock()
 	delete(groups, g.name)
@@ -302,18 +526,96 @@ func (g *Group) Close() error {
 	return nil
 }
 
+// Subscribe 订阅缓存变更事件（Set/Delete/Evict/Expire）
+//
+// 返回的通道在调用 cancel 或 Group 关闭后被关闭。事件以非阻塞方式投递，
+// 订阅者消费过慢导致通道写满时后续事件会被丢弃，丢弃数量记录在 Stats 的 events_dropped 中。
+func (g *Group) Subscribe() (<-chan Event, func()) {
+	return g.events.subscribe()
+}
+
+// publish 构造并广播事件
+func (g *Group) publish(eventType EventType, key string, value ByteView, origin EventOrigin) {
+	e := Event{
+		Type:   eventType,
+		Group:  g.name,
+		Key:    key,
+		Value:  value,
+		Origin: origin,
+		Time:   time.Now(),
+	}
+	g.events.publish(e)
+	if g.changes != nil {
+		g.recordChange(e)
+	}
+}
+
+// onCacheRemoved 将本地存储的被动移除转换为事件
+func (g *Group) onCacheRemoved(key string, value ByteView, reason removeReason) {
+	switch reason {
+	case removeExpired:
+		g.publish(EventExpire, key, value, OriginLocal)
+	case removeEvicted:
+		g.publish(EventEvict, key, value, OriginLocal)
+	default:
+		g.publish(EventDelete, key, ByteView{}, OriginLocal)
+	}
+}
+
+// originFromContext 根据 from_peer 标记判断操作来源
+func originFromContext(ctx context.Context) EventOrigin {
+	if ctx.Value("from_peer") != nil {
+		return OriginPeer
+	}
+	return OriginLocal
+}
+
+// Refresh 绕过本地缓存，强制从数据源重新加载数据并更新本地缓存
+//
+// 适用于数据库被带外修改后需要立即刷新缓存的场景。刷新不会尝试从远程节点获取
+// （远程节点上的副本同样可能是旧值），相同 key 的并发刷新通过 SingleFlight 合并。
+func (g *Group) Refresh(ctx context.Context, key string) (ByteView, error) {
+	// 检查组是否已关闭
+	if g.closed.Load() == 1 {
+		return ByteView{}, ErrGroupClosed
+	}
+
+	if key == "" {
+		return ByteView{}, ErrKeyRequired
+	}
+	if err := g.policy.checkKey(ctx, key); err != nil {
+		return ByteView{}, err
+	}
+
+	result, err := g.load(ctx, key, g.refreshLoader, func(ctx context.Context) (interface{}, error) {
+		return g.loadFromDataSource(ctx, key)
+	})
+	return result.view, err
+}
+
+// forgetLoads 使 key 正在进行的加载失效，写操作之后的读取不会再复用写之前发起的加载结果
+func (g *Group) forgetLoads(key string) {
+	g.singleFlightLoader.Forget(key)
+	g.refreshLoader.Forget(key)
+}
+
 // loadOnce 使用 SingleFlight 机制加载数据，防止缓存击穿
 // 该方法确保相同 key 的并发请求只会执行一次加载操作
 // 加载完成后会将数据存入本地缓存
-func (g *Group) loadOnce(ctx context.Context, key string) (ByteView, error) {
-	startTime := time.Now()
-
-	// 使用 SingleFlight.Do 确保并发请求只执行一次加载
-	// Do 方法会阻塞所有相同 key 的请求，直到第一个请求完成
-	// 所有等待的请求将共享同一个结果
-	result, err := g.singleFlightLoader.Do(key, func() (interface{}, error) {
+func (g *Group) loadOnce(ctx context.Context, key string) (loadResult, error) {
+	return g.load(ctx, key, g.singleFlightLoader, func(ctx context.Context) (interface{}, error) {
 		return g.fetchData(ctx, key)
 	})
+}
+
+// load 通过指定的 SingleFlight 加载器执行 fn，记录统计信息并将结果存入本地缓存
+func (g *Group) load(ctx context.Context, key string, loader *singleflight.Group, fn func(ctx context.Context) (interface{}, error)) (loadResult, error) {
+	startTime := time.Now()
+
+	// 使用 SingleFlight.DoCtx 确保并发请求只执行一次加载
+	// 相同 key 的请求等待第一个请求完成并共享同一个结果
+	// 调用方的 ctx 取消时可以提前返回，只有所有等待者都放弃时加载才会被取消
+	result, err := loader.DoCtx(ctx, key, fn)
 
 	// 记录加载统计信息
 	duration := time.Since(startTime).Nanoseconds()
@@ -322,63 +624,184 @@ func (g *Group) loadOnce(ctx context.Context, key string) (ByteView, error) {
 
 	if err != nil {
 		g.stats.loaderErrors.Add(1)
-		return ByteView{}, err
+		return loadResult{}, err
 	}
 
-	// 类型断言：将 interface{} 转换为 ByteView
-	byteView, ok := result.(ByteView)
+	// 类型断言：将 interface{} 转换为 loadResult
+	loaded, ok := result.(loadResult)
 	if !ok {
 		g.stats.loaderErrors.Add(1)
-		return ByteView{}, fmt.Errorf("unexpected type: %T", result)
+		return loadResult{}, fmt.Errorf("unexpected type: %T", result)
+	}
+
+	// 将加载的数据存入本地缓存，便于下次快速访问；超出配额时只返回数据不缓存
+	if err := g.checkQuota(key, loaded.view.Len()); err != nil {
+		g.stats.quotaRejects.Add(1)
+		return loaded, nil
 	}
+	// 准入过滤只作用于读穿透的加载，强制刷新必须覆盖本地的旧值
+	if g.doorkeeper != nil && loader != g.refreshLoader && !g.doorkeeper.admit(key) {
+		g.stats.admissionRejects.Add(1)
+		return loaded, nil
+	}
+	if loaded.source == SourcePeer && !loaded.view.expire.IsZero() {
+		// 保留 owner 上的剩余 TTL，本地副本不会比 owner 存活更久
+		if loaded.view.expire.After(time.Now()) {
+			loaded.view = g.saveWithExpire(key, loaded.view, loaded.view.expire)
+		}
+		return loaded, nil
+	}
+	loaded.view = g.saveToLocal(key, loaded.view)
+
+	return loaded, nil
+}
 
-	// 将加载的数据存入本地缓存，便于下次快速访问
-	g.saveToLocal(key, byteView)
+// checkQuota 检查写入 key/value 后是否会超出组内存配额
+// 覆盖已存在的 key 时只计入新旧值的差额，不大于原值的更新总是允许
+func (g *Group) checkQuota(key string, valueLen int) error {
+	if g.memoryQuota <= 0 {
+		return nil
+	}
 
-	return byteView, nil
+	used := g.localCache.UsedBytes()
+	requested := int64(len(key)+valueLen) - g.localCache.entrySize(key)
+	if requested > 0 && used+requested > g.memoryQuota {
+		return &QuotaExceededError{
+			Group:     g.name,
+			Used:      used,
+			Requested: requested,
+			Quota:     g.memoryQuota,
+		}
+	}
+	return nil
 }
 
-// saveToLocal 将数据存入本地缓存
-func (g *Group) saveToLocal(key string, byteView ByteView) {
+// saveToLocal 将数据存入本地缓存，返回带有写入时间和过期时间的视图
+func (g *Group) saveToLocal(key string, byteView ByteView) ByteView {
+	byteView.written = time.Now()
 	if g.expiration > 0 {
-		expirationTime := time.Now().Add(g.expiration)
-		g.localCache.AddWithExpiration(key, byteView, expirationTime)
+		byteView.expire = byteView.written.Add(g.expiration)
+		g.localCache.AddWithExpiration(key, byteView, byteView.expire)
 	} else {
+		byteView.expire = time.Time{}
 		g.localCache.Add(key, byteView)
 	}
+	return byteView
+}
+
+// saveWithExpire 按指定的过期时间将数据存入本地缓存，用于保留来自其他节点的剩余 TTL
+func (g *Group) saveWithExpire(key string, byteView ByteView, expire time.Time) ByteView {
+	byteView.written = time.Now()
+	byteView.expire = expire
+	g.localCache.AddWithExpiration(key, byteView, expire)
+	return byteView
 }
 
 // fetchData 从远程节点或数据源获取数据
 // 首先尝试从远程节点获取，失败则从本地数据源加载
-func (g *Group) fetchData(ctx context.Context, key string) (value ByteView, err error) {
+// 其他节点转发过来的请求直接在本节点加载，避免节点视图不一致时请求在节点间来回转发
+func (g *Group) fetchData(ctx context.Context, key string) (loadResult, error) {
 	// 尝试从远程节点获取
-	if g.peers != nil {
+	if g.peers != nil && ctx.Value("from_peer") == nil {
 		peer, ok, isSelf := g.peers.PickPeer(key)
 		if ok && !isSelf {
-			value, err := g.fetchFromPeer(ctx, peer, key)
+			peer = g.pickReadPeer(key, peer)
+			value, err := g.fetchFromOwner(ctx, peer, key)
+			if err != nil && ctx.Err() != nil {
+				// 超时或取消导致的失败不能说明节点不可用，剩余时间也不够再访问副本或数据源
+				g.stats.peerMisses.Add(1)
+				return loadResult{}, err
+			}
+			if err != nil && g.replicas > 1 && isPeerUnavailable(err) {
+				value, err = g.fetchFromReplicas(ctx, peer, key, err)
+			}
 			if err == nil {
 				g.stats.peerHits.Add(1)
-				return value, nil
+				if g.readRepairReplicas > 1 {
+					go g.readRepair(key, value)
+				}
+				return loadResult{view: value, source: SourcePeer}, nil
 			}
 
 			g.stats.peerMisses.Add(1)
+			if ctx.Err() != nil {
+				return loadResult{}, err
+			}
+			if g.ownerOnlyLoad && !isPeerUnavailable(err) {
+				// owner 已经查询过数据源，直接返回其结果，不再重复回源
+				g.stats.ownerErrors.Add(1)
+				return loadResult{}, err
+			}
 			log.Printf("[MyCache] failed to get from peer: %v", err)
 		}
 	}
 
 	// 从数据源加载
-	bytes, err := g.dataSource.Get(ctx, key)
+	return g.loadFromDataSource(ctx, key)
+}
+
+// loadFromDataSource 调用 DataSource 从数据源加载数据
+func (g *Group) loadFromDataSource(ctx context.Context, key string) (loadResult, error) {
+	bytes, err := g.getFromDataSource(ctx, key)
 	if err != nil {
-		return ByteView{}, fmt.Errorf("failed to get data: %w", err)
+		return loadResult{}, fmt.Errorf("failed to get data: %w", err)
 	}
 
 	g.stats.loaderHits.Add(1)
-	return ByteView{b: cloneBytes(bytes)}, nil
+	return loadResult{view: ByteView{b: cloneBytes(bytes)}, source: SourceLoader}, nil
+}
+
+// getFromDataSource 调用 DataSource 获取数据，失败时按重试策略退避重试
+// 请求被追踪时为数据源调用创建子 span，DataSource 通过 ctx 可以继续传播 trace 上下文
+func (g *Group) getFromDataSource(ctx context.Context, key string) (_ []byte, err error) {
+	ctx, span := startSpan(ctx, "mycache.DataSource.Get", g.name)
+	defer func() { finishSpan(span, err) }()
+
+	// 等待节点响应时调用方可能已经超时，不再调用数据源
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// 租户的 key 以原始 key 交给数据源，租户通过 TenantFromContext 获取
+	if tenant, userKey := splitTenantKey(key); tenant != "" {
+		ctx = WithTenant(ctx, tenant)
+		key = userKey
+	}
+
+	backoff := g.loadRetry.backoff
+
+	for attempt := 1; ; attempt++ {
+		bytes, err := g.dataSource.Get(ctx, key)
+		if err == nil {
+			return bytes, nil
+		}
+
+		if !g.loadRetry.shouldRetry(attempt, err) {
+			return nil, err
+		}
+		span.AddEvent("retry")
+
+		// 等待退避时间，调用方取消时返回最后一次的错误
+		if sleepContext(ctx, backoff) != nil {
+			return nil, err
+		}
+		backoff = min(backoff*2, g.loadRetry.maxBackoff)
+		g.stats.loadRetries.Add(1)
+	}
 }
 
 // fetchFromPeer 从其他节点获取数据
-func (g *Group) fetchFromPeer(_ context.Context, peer Peer, key string) (ByteView, error) {
-	bytes, err := peer.Get(g.name, key)
+func (g *Group) fetchFromPeer(ctx context.Context, peer Peer, key string) (ByteView, error) {
+	// 支持返回元信息的节点同时返回剩余 TTL 和写入时间
+	if ep, ok := peer.(entryPeer); ok {
+		view, err := ep.getEntry(ctx, g.name, key, false)
+		if err != nil {
+			return ByteView{}, fmt.Errorf("failed to get from peer: %w", err)
+		}
+		return view, nil
+	}
+
+	bytes, err := peer.Get(ctx, g.name, key)
 	if err != nil {
 		return ByteView{}, fmt.Errorf("failed to get from peer: %w", err)
 	}
@@ -391,22 +814,45 @@ func (g *Group) RegisterPeers(peers PeerPicker) {
 		panic("RegisterPeers called more than once")
 	}
 	g.peers = peers
+	g.startMigration()
 	log.Printf("[MyCache] registered peers for group [%s]", g.name)
 }
 
 // Stats 返回缓存统计信息
 func (g *Group) Stats() map[string]interface{} {
 	stats := map[string]interface{}{
-		"name":          g.name,
-		"closed":        g.closed.Load() == 1,
-		"expiration":    g.expiration,
-		"loads":         g.stats.loads.Load(),
-		"local_hits":    g.stats.localHits.Load(),
-		"local_misses":  g.stats.localMisses.Load(),
-		"peer_hits":     g.stats.peerHits.Load(),
-		"peer_misses":   g.stats.peerMisses.Load(),
-		"loader_hits":   g.stats.loaderHits.Load(),
-		"loader_errors": g.stats.loaderErrors.Load(),
+		"name":               g.name,
+		"closed":             g.closed.Load() == 1,
+		"expiration":         g.expiration,
+		"loads":              g.stats.loads.Load(),
+		"local_hits":         g.stats.localHits.Load(),
+		"local_misses":       g.stats.localMisses.Load(),
+		"peer_hits":          g.stats.peerHits.Load(),
+		"peer_misses":        g.stats.peerMisses.Load(),
+		"loader_hits":        g.stats.loaderHits.Load(),
+		"loader_errors":      g.stats.loaderErrors.Load(),
+		"load_retries":       g.stats.loadRetries.Load(),
+		"events_dropped":     g.events.dropped.Load(),
+		"memory_quota":       g.memoryQuota,
+		"quota_rejects":      g.stats.quotaRejects.Load(),
+		"owner_errors":       g.stats.ownerErrors.Load(),
+		"hedged_reads":       g.stats.hedgedReads.Load(),
+		"hedge_wins":         g.stats.hedgeWins.Load(),
+		"migrated_keys":      g.stats.migratedKeys.Load(),
+		"read_repairs":       g.stats.readRepairs.Load(),
+		"consistency_errors": g.stats.consistencyErrors.Load(),
+		"hints_queued":       g.stats.hintsQueued.Load(),
+		"hints_replayed":     g.stats.hintsReplayed.Load(),
+		"hints_dropped":      g.stats.hintsDropped.Load(),
+		"replica_reads":      g.stats.replicaReads.Load(),
+		"zone_reads":         g.stats.zoneReads.Load(),
+		"snapshot_saves":     g.stats.snapshotSaves.Load(),
+		"snapshot_loads":     g.stats.snapshotLoads.Load(),
+		"backup_restored":    g.stats.backupRestores.Load(),
+		"admission_rejects":  g.stats.admissionRejects.Load(),
+		"invalidations":      g.stats.invalidations.Load(),
+		"sync_batches":       g.stats.syncBatches.Load(),
+		"sync_coalesced":     g.stats.syncCoalesced.Load(),
 	}
 
 	// 计算各种命中率
@@ -420,8 +866,27 @@ func (g *Group) Stats() map[string]interface{} {
 		stats["avg_load_time_ms"] = float64(g.stats.loadDuration.Load()) / float64(totalLoads) / float64(time.Millisecond)
 	}
 
+	// 添加 SingleFlight 统计
+	sf := g.singleFlightLoader.Stats()
+	stats["singleflight_executions"] = sf.Executions
+	stats["singleflight_shared"] = sf.Shared
+	stats["singleflight_error_shares"] = sf.ErrorShares
+	stats["singleflight_abandoned"] = sf.Abandoned
+	stats["singleflight_rejected"] = sf.Rejected
+	stats["singleflight_in_flight"] = sf.InFlight
+
+	if g.hints != nil {
+		stats["hints_pending"] = g.hints.len()
+	}
+	stats["locks_held"] = g.locks.len()
+	if g.changes != nil {
+		stats["changes_written"] = g.changes.Written()
+		stats["changes_dropped"] = g.changes.Dropped()
+	}
+
 	// 添加缓存大小
 	if g.localCache != nil {
+		stats["used_bytes"] = g.localCache.UsedBytes()
 		cacheStats := g.localCache.Stats()
 		for k, v := range cacheStats {
 			stats["cache_"+k] = v
@@ -0,0 +1,285 @@
+package mycache
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHintInterval = 5 * time.Second // 默认的提示重放周期
+	hintReplayTimeout   = 3 * time.Second // 重放单个提示的超时时间
+)
+
+// hint 同步到 owner 失败、等待重放的写操作
+type hint struct {
+	Op       string    `json:"op"` // "set" 或 "delete"
+	Key      string    `json:"key"`
+	Value    []byte    `json:"value,omitempty"`
+	ExpireAt time.Time `json:"expire_at"` // set 的过期时间，零值表示永不过期
+	Created  time.Time `json:"created"`
+}
+
+// hintQueue 有界的提示队列，同一个 key 只保留最新的写操作
+type hintQueue struct {
+	mu    sync.Mutex
+	hints map[string]hint
+	order []string // 按入队顺序排列的 key，可能包含已被移除的 key
+	max   int
+	path  string // 持久化文件路径，为空时不持久化
+}
+
+// WithHintedHandoff 启用提示移交（hinted handoff）
+//
+// Set/Delete 同步到 owner 失败时（如 owner 暂时不可达），写操作会保存在本地的有界队列中，
+// 每隔 interval 重新计算 owner 并重放，直到成功，而不是只记录一条日志后丢弃。同一个 key 只保留
+// 最新的写操作；队列超过 maxHints 时丢弃最早的提示。已过期的写操作不再重放。
+// interval 为 0 时使用 5s。
+func WithHintedHandoff(maxHints int, interval time.Duration) GroupOption {
+	return func(g *Group) {
+		if interval <= 0 {
+			interval = defaultHintInterval
+		}
+		g.hintInterval = interval
+		if g.hints == nil {
+			g.hints = &hintQueue{hints: make(map[string]hint)}
+		}
+		g.hints.max = maxHints
+	}
+}
+
+// WithHintPersistence 将未重放的提示在组关闭时保存到 path，并在创建组时恢复
+// 需要同时启用 WithHintedHandoff
+func WithHintPersistence(path string) GroupOption {
+	return func(g *Group) {
+		if g.hints == nil {
+			g.hints = &hintQueue{hints: make(map[string]hint)}
+		}
+		g.hints.path = path
+	}
+}
+
+// add 加入提示，返回因队列已满被丢弃的提示数量
+func (q *hintQueue) add(h hint) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.hints[h.Key]; !ok {
+		q.order = append(q.order, h.Key)
+	}
+	q.hints[h.Key] = h
+
+	dropped := 0
+	for q.max > 0 && len(q.hints) > q.max {
+		key := q.order[0]
+		q.order = q.order[1:]
+		if _, ok := q.hints[key]; ok {
+			delete(q.hints, key)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// remove 移除 key 的提示，仅当提示仍是 h 时才移除（避免覆盖重放期间新加入的提示）
+func (q *hintQueue) remove(key string, h *hint) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	cur, ok := q.hints[key]
+	if !ok {
+		return
+	}
+	if h != nil && !cur.Created.Equal(h.Created) {
+		return
+	}
+	delete(q.hints, key)
+	// order 中残留的 key 在长度明显超过提示数时统一清理
+	if len(q.order) > 2*len(q.hints)+16 {
+		order := q.order[:0]
+		seen := make(map[string]bool, len(q.hints))
+		for _, k := range q.order {
+			if _, ok := q.hints[k]; ok && !seen[k] {
+				seen[k] = true
+				order = append(order, k)
+			}
+		}
+		q.order = order
+	}
+}
+
+// snapshot 按入队顺序返回所有提示
+func (q *hintQueue) snapshot() []hint {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	out := make([]hint, 0, len(q.hints))
+	seen := make(map[string]bool, len(q.hints))
+	for _, key := range q.order {
+		if h, ok := q.hints[key]; ok && !seen[key] {
+			seen[key] = true
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// len 返回待重放的提示数量
+func (q *hintQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.hints)
+}
+
+// save 将提示写入持久化文件，没有提示时删除文件
+func (q *hintQueue) save() error {
+	if q.path == "" {
+		return nil
+	}
+	hints := q.snapshot()
+	if len(hints) == 0 {
+		if err := os.Remove(q.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(hints)
+	if err != nil {
+		return err
+	}
+	tmp := q.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.path)
+}
+
+// load 从持久化文件恢复提示
+func (q *hintQueue) load() error {
+	if q.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(q.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var hints []hint
+	if err := json.Unmarshal(data, &hints); err != nil {
+		return err
+	}
+	for _, h := range hints {
+		q.add(h)
+	}
+	return nil
+}
+
+// startHandoff 恢复持久化的提示并在后台定期重放，未启用时不做任何事
+func (g *Group) startHandoff() {
+	if g.hints == nil || g.hintInterval <= 0 {
+		return
+	}
+	if err := g.hints.load(); err != nil {
+		log.Printf("[MyCache] failed to load hints for group [%s]: %v", g.name, err)
+	}
+
+	g.hintStop = make(chan struct{})
+	g.hintDone = make(chan struct{})
+	go func() {
+		defer close(g.hintDone)
+
+		ticker := time.NewTicker(g.hintInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-g.hintStop:
+				return
+			case <-ticker.C:
+				g.replayHints()
+			}
+		}
+	}()
+}
+
+// addHint 同步失败时保存写操作，未启用提示移交时返回 false
+func (g *Group) addHint(op, key string, value []byte, expire time.Time) bool {
+	if g.hintStop == nil {
+		return false
+	}
+	dropped := g.hints.add(hint{Op: op, Key: key, Value: value, ExpireAt: expire, Created: time.Now()})
+	g.stats.hintsQueued.Add(1)
+	g.stats.hintsDropped.Add(int64(dropped))
+	return true
+}
+
+// clearHint 写操作已同步成功，之前保存的提示已过时
+func (g *Group) clearHint(key string) {
+	if g.hintStop != nil {
+		g.hints.remove(key, nil)
+	}
+}
+
+// replayHints 重新计算 owner 并重放所有提示
+// 同一个节点在一轮中失败一次后，本轮不再向其重放
+func (g *Group) replayHints() {
+	if g.peers == nil {
+		return
+	}
+
+	failed := make(map[Peer]bool)
+	for _, h := range g.hints.snapshot() {
+		if !h.ExpireAt.IsZero() && time.Now().After(h.ExpireAt) {
+			g.hints.remove(h.Key, &h)
+			g.stats.hintsDropped.Add(1)
+			continue
+		}
+
+		peer, ok, isSelf := g.peers.PickPeer(h.Key)
+		if !ok || isSelf {
+			// 本节点已成为 owner，写操作已在本地生效
+			g.hints.remove(h.Key, &h)
+			continue
+		}
+		if failed[peer] {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(WithPriority(context.Background(), PriorityBackground), hintReplayTimeout)
+		ctx = withWriteExpire(context.WithValue(ctx, "from_peer", true), h.ExpireAt)
+		var err error
+		switch h.Op {
+		case "set":
+			err = peer.Set(ctx, g.name, h.Key, h.Value)
+		case "delete":
+			_, err = peer.Delete(ctx, g.name, h.Key)
+		}
+		cancel()
+
+		if err != nil {
+			failed[peer] = true
+			continue
+		}
+		g.hints.remove(h.Key, &h)
+		g.stats.hintsReplayed.Add(1)
+	}
+}
+
+// stopHandoff 停止重放并持久化剩余的提示
+func (g *Group) stopHandoff() {
+	if g.hintStop == nil {
+		return
+	}
+	close(g.hintStop)
+	<-g.hintDone
+	if err := g.hints.save(); err != nil {
+		log.Printf("[MyCache] failed to save hints for group [%s]: %v", g.name, err)
+	}
+}
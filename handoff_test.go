@@ -0,0 +1,149 @@
+package mycache
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyPeer 在 down 为 true 时拒绝所有写操作，恢复后记录收到的写操作
+type flakyPeer struct {
+	*recordingPeer
+	down atomic.Bool
+}
+
+func newFlakyPeer() *flakyPeer {
+	p := &flakyPeer{recordingPeer: newRecordingPeer()}
+	p.down.Store(true)
+	return p
+}
+
+func (p *flakyPeer) Set(ctx context.Context, group, key string, value []byte) error {
+	if p.down.Load() {
+		return ErrPeerUnavailable
+	}
+	return p.recordingPeer.Set(ctx, group, key, value)
+}
+
+func (p *flakyPeer) Delete(ctx context.Context, group, key string) (bool, error) {
+	if p.down.Load() {
+		return false, ErrPeerUnavailable
+	}
+	return p.recordingPeer.Delete(ctx, group, key)
+}
+
+// newHandoffGroup 创建启用提示移交的组，重放周期足够长，由测试直接调用 replayHints
+func newHandoffGroup(t *testing.T, name string, peer Peer, opts ...GroupOption) *Group {
+	t.Helper()
+	opts = append([]GroupOption{WithPeers(fixedPicker{peer: peer}), WithHintedHandoff(100, time.Hour)}, opts...)
+	return NewGroup(name, 1<<20, DataSourceFunc(func(ctx context.Context, key string) ([]byte, error) {
+		return nil, errors.New("not found")
+	}), opts...)
+}
+
+// waitHints 等待 n 个写操作同步失败并保存为提示
+func waitHints(t *testing.T, g *Group, n int64) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for g.stats.hintsQueued.Load() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("应保存 %d 个提示，实际为 %d 个", n, g.stats.hintsQueued.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHandoff_ReplayAfterOwnerRecovers(t *testing.T) {
+	peer := newFlakyPeer()
+	g := newHandoffGroup(t, "handoff-replay", peer)
+	defer g.Close()
+	ctx := context.Background()
+
+	g.SetWithTTL(ctx, "ttl", []byte("v1"), time.Hour)
+	view, _ := g.localCache.Get(ctx, "ttl")
+	g.Set(ctx, "k", []byte("old"))
+	waitHints(t, g, 2)
+	g.Set(ctx, "k", []byte("new"))
+	g.Set(ctx, "gone", []byte("v"))
+	waitHints(t, g, 4)
+	g.Delete(ctx, "gone")
+	waitHints(t, g, 5)
+
+	// owner 不可达时重放失败，提示保留
+	g.replayHints()
+	if n := g.hints.len(); n != 3 {
+		t.Fatalf("同一个 key 只保留最新的写操作，应有 3 个提示，实际为 %d 个", n)
+	}
+	if n := g.stats.hintsReplayed.Load(); n != 0 {
+		t.Errorf("owner 不可达时不应重放成功，实际为 %d", n)
+	}
+
+	peer.down.Store(false)
+	g.replayHints()
+
+	if n := g.hints.len(); n != 0 {
+		t.Errorf("owner 恢复后应重放全部提示，剩余 %d 个", n)
+	}
+	if n := g.stats.hintsReplayed.Load(); n != 3 {
+		t.Errorf("hints_replayed 应为 3，实际为 %d", n)
+	}
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+	if peer.sets["k"] != "new" || peer.sets["ttl"] != "v1" {
+		t.Errorf("应重放每个 key 最新的值，实际为 %v", peer.sets)
+	}
+	if _, ok := peer.sets["gone"]; ok || len(peer.deletes) != 1 || peer.deletes[0] != "gone" {
+		t.Errorf("gone 应只重放删除，实际 sets=%v deletes=%v", peer.sets, peer.deletes)
+	}
+	if got := peer.expires["ttl"]; !got.Equal(view.expire) {
+		t.Errorf("重放时应携带原来的过期时间 %v，实际为 %v", view.expire, got)
+	}
+}
+
+func TestHandoff_DropsExpiredHints(t *testing.T) {
+	peer := newFlakyPeer()
+	g := newHandoffGroup(t, "handoff-expired", peer)
+	defer g.Close()
+
+	g.SetWithTTL(context.Background(), "k", []byte("v"), 20*time.Millisecond)
+	waitHints(t, g, 1)
+	time.Sleep(30 * time.Millisecond)
+
+	peer.down.Store(false)
+	g.replayHints()
+	if n := g.hints.len(); n != 0 {
+		t.Errorf("已过期的提示应被丢弃，剩余 %d 个", n)
+	}
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+	if len(peer.sets) != 0 {
+		t.Errorf("已过期的写操作不应重放，实际为 %v", peer.sets)
+	}
+}
+
+func TestHandoff_PersistedAcrossRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hints.json")
+	peer := newFlakyPeer()
+
+	g := newHandoffGroup(t, "handoff-persist", peer, WithHintPersistence(path))
+	g.Set(context.Background(), "k", []byte("v"))
+	waitHints(t, g, 1)
+	g.Close() // 关闭时保存未重放的提示
+
+	peer.down.Store(false)
+	restarted := newHandoffGroup(t, "handoff-persist", peer, WithHintPersistence(path))
+	defer restarted.Close()
+	if n := restarted.hints.len(); n != 1 {
+		t.Fatalf("重启后应恢复 1 个提示，实际为 %d 个", n)
+	}
+	restarted.replayHints()
+
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+	if peer.sets["k"] != "v" {
+		t.Errorf("重启后应重放保存的提示，实际为 %v", peer.sets)
+	}
+}
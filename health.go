@@ -0,0 +1,184 @@
+package mycache
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	defaultHealthTimeout   = time.Second // 单次健康检查的默认超时时间
+	defaultHealthThreshold = 3           // 默认连续失败多少次后摘除节点
+)
+
+// PeerStatus 节点的健康状态
+type PeerStatus struct {
+	Addr                string    // 节点地址
+	Healthy             bool      // 是否健康，不健康的节点已从哈希环上摘除
+	ConsecutiveFailures int       // 连续健康检查失败次数
+	LastCheck           time.Time // 最近一次健康检查时间，零值表示尚未检查
+	LastError           string    // 最近一次健康检查的错误
+}
+
+// peerHealth 单个节点的健康检查状态
+type peerHealth struct {
+	healthy   bool
+	failures  int
+	lastCheck time.Time
+	lastErr   error
+}
+
+// WithHealthCheck 启用主动健康检查
+//
+// 每隔 interval 调用一次各节点的 gRPC 健康检查服务，连续 threshold 次失败（或超过 timeout
+// 未响应）的节点会从哈希环上摘除，请求不再路由到该节点；节点恢复后重新加入哈希环。
+// timeout 和 threshold 为 0 时分别使用 1s 和 3 次。
+func WithHealthCheck(interval, timeout time.Duration, threshold int) PickerOption {
+	return func(p *ClientPicker) {
+		p.healthInterval = interval
+		p.healthTimeout = timeout
+		p.healthThreshold = threshold
+	}
+}
+
+// HealthCheck 调用节点的 gRPC 健康检查服务，节点未处于 SERVING 状态时返回错误
+func (c *Client) HealthCheck(ctx context.Context) error {
+	conns := c.conns()
+	conn := conns[c.next.Add(1)%uint64(len(conns))].conn
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{
+		Service: c.svcName,
+	})
+	if err != nil {
+		return err
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("peer %s is %s", c.addr, resp.GetStatus())
+	}
+	return nil
+}
+
+// startHealthCheck 在后台定期检查所有节点的健康状态，直到 picker 关闭
+func (p *ClientPicker) startHealthCheck() {
+	if p.healthTimeout <= 0 {
+		p.healthTimeout = defaultHealthTimeout
+	}
+	if p.healthThreshold <= 0 {
+		p.healthThreshold = defaultHealthThreshold
+	}
+
+	go func() {
+		ticker := time.NewTicker(p.healthInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.ctx.Done():
+				return
+			case <-ticker.C:
+				p.checkPeers()
+			}
+		}
+	}()
+}
+
+// checkPeers 并发检查所有节点，并根据结果摘除或恢复节点
+func (p *ClientPicker) checkPeers() {
+	p.mu.RLock()
+	clients := make(map[string]*Client, len(p.clients))
+	for addr, client := range p.clients {
+		clients[addr] = client
+	}
+	p.mu.RUnlock()
+
+	var (
+		wg      sync.WaitGroup
+		resMu   sync.Mutex
+		results = make(map[string]error, len(clients))
+	)
+	for addr, client := range clients {
+		wg.Add(1)
+		go func(addr string, client *Client) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(p.ctx, p.healthTimeout)
+			err := client.HealthCheck(ctx)
+			cancel()
+
+			resMu.Lock()
+			results[addr] = err
+			resMu.Unlock()
+		}(addr, client)
+	}
+	wg.Wait()
+
+	if p.ctx.Err() != nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for addr, err := range results {
+		// 检查期间被服务发现移除或替换的节点不再处理
+		if p.clients[addr] != clients[addr] {
+			continue
+		}
+
+		h, ok := p.health[addr]
+		if !ok {
+			h = &peerHealth{healthy: true}
+			p.health[addr] = h
+		}
+		h.lastCheck = now
+		h.lastErr = err
+
+		if err != nil {
+			h.failures++
+			if h.healthy && h.failures >= p.healthThreshold {
+				h.healthy = false
+				p.consHash.Remove(addr)
+				p.notifyTopologyChange()
+				log.Printf("[PeerPicker] Peer %s ejected after %d failed health checks: %v", addr, h.failures, err)
+			}
+			continue
+		}
+
+		h.failures = 0
+		if !h.healthy {
+			h.healthy = true
+			p.consHash.Add(addr)
+			p.notifyTopologyChange()
+			log.Printf("[PeerPicker] Peer %s recovered", addr)
+		}
+	}
+}
+
+// PeerStatus 返回所有已发现节点的健康状态，按地址排序
+// 未启用健康检查时所有节点都视为健康
+func (p *ClientPicker) PeerStatus() []PeerStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	statuses := make([]PeerStatus, 0, len(p.clients))
+	for addr := range p.clients {
+		status := PeerStatus{Addr: addr, Healthy: true}
+		if h, ok := p.health[addr]; ok {
+			status.Healthy = h.healthy
+			status.ConsecutiveFailures = h.failures
+			status.LastCheck = h.lastCheck
+			if h.lastErr != nil {
+				status.LastError = h.lastErr.Error()
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Addr < statuses[j].Addr
+	})
+	return statuses
+}
@@ -0,0 +1,103 @@
+package mycache
+
+import (
+	"context"
+	"time"
+)
+
+// ReplicaPicker 是 PeerPicker 的可选扩展，能按顺序返回 key 的多个副本节点
+// 本节点不会出现在结果中；owner 不是本节点时，第一个节点与 PickPeer 选出的 owner 相同
+type ReplicaPicker interface {
+	PickPeers(key string, n int) []Peer
+}
+
+// WithHedgedReads 启用对冲读
+//
+// 从 owner 节点获取数据超过 delay 仍未返回时，向环上的下一个副本节点再发一次请求，
+// 使用先返回的结果，用于缓解部分节点变慢时的长尾延迟。owner 不可达时会立即请求副本。
+// 需要 PeerPicker 实现 ReplicaPicker 接口；副本节点未命中时会从其数据源加载。
+func WithHedgedReads(delay time.Duration) GroupOption {
+	return func(g *Group) {
+		g.hedgeDelay = delay
+	}
+}
+
+// peerResult 一次节点请求的结果
+type peerResult struct {
+	view  ByteView
+	err   error
+	hedge bool // 是否来自对冲请求
+}
+
+// fetchFromOwner 从 owner 节点获取数据，启用对冲读时在 owner 变慢后请求副本节点
+func (g *Group) fetchFromOwner(ctx context.Context, owner Peer, key string) (ByteView, error) {
+	if g.hedgeDelay <= 0 {
+		return g.fetchFromPeer(ctx, owner, key)
+	}
+
+	replicaPicker, ok := g.peers.(ReplicaPicker)
+	if !ok {
+		return g.fetchFromPeer(ctx, owner, key)
+	}
+	replicas := replicaPicker.PickPeers(key, 2)
+	if len(replicas) < 2 || replicas[0] != owner {
+		return g.fetchFromPeer(ctx, owner, key)
+	}
+
+	return g.fetchHedged(ctx, owner, replicas[1], key)
+}
+
+// fetchHedged 先请求 primary，超过对冲延迟或 primary 不可达时再请求 backup，返回先成功的结果
+func (g *Group) fetchHedged(ctx context.Context, primary, backup Peer, key string) (ByteView, error) {
+	// 返回时取消仍未完成的请求
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// 缓冲为 2，落后的请求返回时不会阻塞
+	results := make(chan peerResult, 2)
+	launch := func(peer Peer, hedge bool) {
+		go func() {
+			view, err := g.fetchFromPeer(ctx, peer, key)
+			results <- peerResult{view: view, err: err, hedge: hedge}
+		}()
+	}
+
+	launch(primary, false)
+	timer := time.NewTimer(g.hedgeDelay)
+	defer timer.Stop()
+
+	pending, hedged := 1, false
+	startHedge := func() {
+		hedged = true
+		pending++
+		g.stats.hedgedReads.Add(1)
+		launch(backup, true)
+	}
+
+	var lastErr error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if !hedged {
+				startHedge()
+			}
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				if res.hedge {
+					g.stats.hedgeWins.Add(1)
+				}
+				return res.view, nil
+			}
+			lastErr = res.err
+
+			// owner 不可达时不必等到对冲延迟，立即请求副本
+			if !hedged && isPeerUnavailable(res.err) {
+				startHedge()
+			}
+		case <-ctx.Done():
+			return ByteView{}, ctx.Err()
+		}
+	}
+	return ByteView{}, lastErr
+}
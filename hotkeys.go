@@ -0,0 +1,167 @@
+package mycache
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+	"time"
+)
+
+// topKeysDecayInterval 访问计数减半的周期，使排行反映近期而不是启动以来的热点
+const topKeysDecayInterval = time.Minute
+
+// HotKey 一个热点 key 的近似访问次数
+// 真实次数在 [Count-Error, Count] 之间；计数每分钟减半，只用于比较 key 之间的相对热度
+type HotKey struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+	Error int64  `json:"error"` // 计数的最大高估值
+}
+
+// WithTopKeys 启用热点 key 统计，最多跟踪 capacity 个 key，通过 TopKeys 或管理接口查看
+//
+// 使用 Space-Saving 算法：跟踪的 key 已满时，新 key 替换计数最小的 key 并继承其计数，
+// 访问次数超过总访问量 1/capacity 的 key 一定会出现在结果中。每次 Get 都会更新统计，
+// 有一次加锁的开销；capacity 不大于 0 时不启用。
+func WithTopKeys(capacity int) GroupOption {
+	return func(g *Group) {
+		if capacity <= 0 {
+			g.topKeys = nil
+			return
+		}
+		g.topKeys = newSpaceSaving(capacity)
+	}
+}
+
+// TopKeys 返回本节点上访问最多的 n 个 key，按计数从高到低排序；未启用 WithTopKeys 时返回 nil
+// 只统计本节点收到的 Get（包括其他节点转发来的），集群的热点需要汇总各节点的结果
+func (g *Group) TopKeys(n int) []HotKey {
+	if g.topKeys == nil {
+		return nil
+	}
+	return g.topKeys.top(n)
+}
+
+// recordAccess 记录一次 key 的访问
+func (g *Group) recordAccess(key string) {
+	if g.topKeys != nil {
+		g.topKeys.add(key)
+	}
+}
+
+// spaceSaving Space-Saving 算法的计数器，以计数为序的最小堆保存跟踪的 key
+type spaceSaving struct {
+	mu       sync.Mutex
+	capacity int
+	counters map[string]*hotCounter
+	heap     hotHeap
+	decayed  time.Time // 上次计数减半的时间
+}
+
+// hotCounter 一个被跟踪的 key
+type hotCounter struct {
+	key   string
+	count int64
+	err   int64
+	index int // 在堆中的位置
+}
+
+// newSpaceSaving 创建最多跟踪 capacity 个 key 的计数器
+func newSpaceSaving(capacity int) *spaceSaving {
+	return &spaceSaving{
+		capacity: capacity,
+		counters: make(map[string]*hotCounter, capacity),
+		heap:     make(hotHeap, 0, capacity),
+		decayed:  time.Now(),
+	}
+}
+
+// add 记录一次访问
+func (s *spaceSaving) add(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.decay(time.Now())
+	if c, ok := s.counters[key]; ok {
+		c.count++
+		heap.Fix(&s.heap, c.index)
+		return
+	}
+	if len(s.heap) < s.capacity {
+		c := &hotCounter{key: key, count: 1}
+		s.counters[key] = c
+		heap.Push(&s.heap, c)
+		return
+	}
+
+	// 替换计数最小的 key，新 key 继承其计数作为误差上界
+	c := s.heap[0]
+	delete(s.counters, c.key)
+	c.key, c.err = key, c.count
+	c.count++
+	s.counters[key] = c
+	heap.Fix(&s.heap, 0)
+}
+
+// decay 每经过一个周期将所有计数减半，减半不改变计数的相对顺序，堆无需调整
+func (s *spaceSaving) decay(now time.Time) {
+	periods := int64(now.Sub(s.decayed) / topKeysDecayInterval)
+	if periods <= 0 {
+		return
+	}
+	shift := uint(min(periods, 63))
+	for _, c := range s.heap {
+		c.count >>= shift
+		c.err >>= shift
+	}
+	s.decayed = s.decayed.Add(time.Duration(periods) * topKeysDecayInterval)
+}
+
+// top 返回计数最高的 n 个 key
+func (s *spaceSaving) top(n int) []HotKey {
+	s.mu.Lock()
+	s.decay(time.Now())
+	keys := make([]HotKey, 0, len(s.heap))
+	for _, c := range s.heap {
+		if c.count > 0 {
+			keys = append(keys, HotKey{Key: c.key, Count: c.count, Error: c.err})
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Count != keys[j].Count {
+			return keys[i].Count > keys[j].Count
+		}
+		return keys[i].Key < keys[j].Key
+	})
+	if n > 0 && len(keys) > n {
+		keys = keys[:n]
+	}
+	return keys
+}
+
+// hotHeap 以计数为序的最小堆，实现 heap.Interface
+type hotHeap []*hotCounter
+
+func (h hotHeap) Len() int           { return len(h) }
+func (h hotHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+
+func (h hotHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *hotHeap) Push(x any) {
+	c := x.(*hotCounter)
+	c.index = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *hotHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
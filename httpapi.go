@@ -0,0 +1,305 @@
+package mycache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// httpShutdownTimeout 停止服务器时等待 HTTP 请求完成的时间
+const httpShutdownTimeout = 5 * time.Second
+
+// WithHTTPAddr 在 addr 上额外提供 HTTP REST 接口，便于非 Go 服务和 curl 直接访问缓存
+//
+//	GET    /api/groups/{group}/keys/{key}   获取值，响应体为原始字节
+//	PUT    /api/groups/{group}/keys/{key}   设置值，请求体为原始字节，可通过 ?ttl=30s 指定存活时间
+//	DELETE /api/groups/{group}/keys/{key}   删除值
+//	POST   /api/groups/{group}/mget         {"keys": [...]}，返回 {"entries": {...}, "errors": {...}}
+//	POST   /api/groups/{group}/mset         {"entries": {...}}，返回 {"errors": {...}}
+//	POST   /api/groups/{group}/mdelete      {"keys": [...]}
+//
+// 批量接口中的值为 base64 编码（JSON 中 []byte 的默认编码）。
+func WithHTTPAddr(addr string) ServerOption {
+	return func(o *ServerOptions) {
+		o.HTTPAddr = addr
+	}
+}
+
+// batchBody 批量接口的请求和响应
+type batchBody struct {
+	Keys    []string          `json:"keys,omitempty"`
+	Entries map[string][]byte `json:"entries,omitempty"`
+	Errors  map[string]string `json:"errors,omitempty"`
+}
+
+// newHTTPHandler 创建 REST 接口的路由
+func (s *Server) newHTTPHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/groups/{group}/keys/{key}", s.httpGet)
+	mux.HandleFunc("PUT /api/groups/{group}/keys/{key}", s.httpSet)
+	mux.HandleFunc("DELETE /api/groups/{group}/keys/{key}", s.httpDelete)
+	mux.HandleFunc("POST /api/groups/{group}/mget", s.httpMGet)
+	mux.HandleFunc("POST /api/groups/{group}/mset", s.httpMSet)
+	mux.HandleFunc("POST /api/groups/{group}/mdelete", s.httpMDelete)
+	return mux
+}
+
+// startHTTP 在后台启动 HTTP 服务，未设置 HTTPAddr 时不做任何事
+func (s *Server) startHTTP() error {
+	if s.opts.HTTPAddr == "" {
+		return nil
+	}
+
+	s.httpServer = &http.Server{
+		Addr:              s.opts.HTTPAddr,
+		Handler:           s.newHTTPHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	lis, err := s.listen(s.opts.HTTPAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen http: %v", err)
+	}
+
+	go func() {
+		log.Printf("[Server] http api listening at %s", s.opts.HTTPAddr)
+		if err := s.httpServer.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("[Server] ERROR: http server stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// stopHTTP 停止 REST、管理和指标接口，等待正在处理的请求完成
+func (s *Server) stopHTTP() {
+	for _, srv := range []*http.Server{s.httpServer, s.adminServer, s.metricsServer} {
+		if srv == nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("[Server] ERROR: failed to shutdown http server %s: %v", srv.Addr, err)
+		}
+		cancel()
+	}
+}
+
+// httpGroup 返回请求路径中的缓存组，不存在或未注册时写入 404
+func (s *Server) httpGroup(w http.ResponseWriter, r *http.Request) *Group {
+	name := r.PathValue("group")
+	group := s.group(name)
+	if group == nil {
+		http.Error(w, fmt.Sprintf("group %s not found", name), http.StatusNotFound)
+	}
+	return group
+}
+
+// httpError 将缓存错误转换为 HTTP 状态码
+func httpError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrKeyRequired), errors.Is(err, ErrValueRequired),
+		errors.Is(err, ErrKeyTooLong), errors.Is(err, ErrInvalidKey):
+		code = http.StatusBadRequest
+	case errors.Is(err, ErrValueTooLarge):
+		code = http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrGroupNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, ErrRateLimited):
+		code = http.StatusTooManyRequests
+	case errors.Is(err, ErrQuotaExceeded):
+		code = http.StatusInsufficientStorage
+	case errors.Is(err, ErrGroupClosed), errors.Is(err, ErrConsistencyNotMet), errors.Is(err, ErrPeerUnavailable):
+		code = http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		code = http.StatusGatewayTimeout
+	}
+	http.Error(w, err.Error(), code)
+}
+
+// readBody 读取请求体，超过最大消息大小时返回错误
+func (s *Server) readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(s.opts.MaxMsgSize)))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return nil, false
+	}
+	return data, true
+}
+
+// readBatch 解析批量接口的 JSON 请求体
+func (s *Server) readBatch(w http.ResponseWriter, r *http.Request) (batchBody, bool) {
+	var body batchBody
+	data, ok := s.readBody(w, r)
+	if !ok {
+		return body, false
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return body, false
+	}
+	return body, true
+}
+
+// writeJSON 以 JSON 格式写入响应
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[Server] ERROR: failed to write http response: %v", err)
+	}
+}
+
+// httpGet 处理 GET /api/groups/{group}/keys/{key}
+func (s *Server) httpGet(w http.ResponseWriter, r *http.Request) {
+	group := s.httpGroup(w, r)
+	if group == nil {
+		return
+	}
+
+	if err := s.opts.KeyPolicy.checkKey(r.Context(), r.PathValue("key")); err != nil {
+		httpError(w, err)
+		return
+	}
+
+	view, err := group.Get(r.Context(), r.PathValue("key"))
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(view.ByteSlice())
+}
+
+// httpSet 处理 PUT /api/groups/{group}/keys/{key}
+func (s *Server) httpSet(w http.ResponseWriter, r *http.Request) {
+	group := s.httpGroup(w, r)
+	if group == nil {
+		return
+	}
+	var ttl time.Duration
+	if v := r.URL.Query().Get("ttl"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "invalid ttl", http.StatusBadRequest)
+			return
+		}
+		ttl = d
+	}
+	value, ok := s.readBody(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.opts.KeyPolicy.checkEntry(r.Context(), r.PathValue("key"), value); err != nil {
+		httpError(w, err)
+		return
+	}
+	if err := group.SetWithTTL(r.Context(), r.PathValue("key"), value, ttl); err != nil {
+		httpError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// httpDelete 处理 DELETE /api/groups/{group}/keys/{key}
+func (s *Server) httpDelete(w http.ResponseWriter, r *http.Request) {
+	group := s.httpGroup(w, r)
+	if group == nil {
+		return
+	}
+
+	if err := group.Delete(r.Context(), r.PathValue("key")); err != nil {
+		httpError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// httpMGet 处理 POST /api/groups/{group}/mget，部分 key 失败时仍返回 200
+func (s *Server) httpMGet(w http.ResponseWriter, r *http.Request) {
+	group := s.httpGroup(w, r)
+	if group == nil {
+		return
+	}
+	req, ok := s.readBatch(w, r)
+	if !ok {
+		return
+	}
+
+	for _, key := range req.Keys {
+		if err := s.opts.KeyPolicy.checkKey(r.Context(), key); err != nil {
+			httpError(w, err)
+			return
+		}
+	}
+
+	values, failed := group.getMulti(r.Context(), req.Keys)
+	resp := batchBody{Entries: make(map[string][]byte, len(values))}
+	for key, view := range values {
+		resp.Entries[key] = view.ByteSlice()
+	}
+	if len(failed) > 0 {
+		resp.Errors = make(map[string]string, len(failed))
+		for key, err := range failed {
+			resp.Errors[key] = err.Error()
+		}
+	}
+	writeJSON(w, resp)
+}
+
+// httpMSet 处理 POST /api/groups/{group}/mset，部分 key 失败时仍返回 200
+func (s *Server) httpMSet(w http.ResponseWriter, r *http.Request) {
+	group := s.httpGroup(w, r)
+	if group == nil {
+		return
+	}
+	req, ok := s.readBatch(w, r)
+	if !ok {
+		return
+	}
+
+	for key, value := range req.Entries {
+		if err := s.opts.KeyPolicy.checkEntry(r.Context(), key, value); err != nil {
+			httpError(w, err)
+			return
+		}
+	}
+
+	var resp batchBody
+	for key, value := range req.Entries {
+		if err := group.Set(r.Context(), key, value); err != nil {
+			if resp.Errors == nil {
+				resp.Errors = make(map[string]string)
+			}
+			resp.Errors[key] = err.Error()
+		}
+	}
+	writeJSON(w, resp)
+}
+
+// httpMDelete 处理 POST /api/groups/{group}/mdelete
+func (s *Server) httpMDelete(w http.ResponseWriter, r *http.Request) {
+	group := s.httpGroup(w, r)
+	if group == nil {
+		return
+	}
+	req, ok := s.readBatch(w, r)
+	if !ok {
+		return
+	}
+
+	if err := group.DeleteMulti(r.Context(), req.Keys); err != nil {
+		httpError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,55 @@
+package mycache
+
+import (
+	"context"
+
+	"github.com/linhx1999/MyCache-Go/invalidation"
+)
+
+// WithInvalidation 订阅外部失效总线，收到本组的失效消息时删除本地缓存中的副本
+//
+// 消息由不经过 MyCache 修改数据源的服务发布，每个节点都会收到，因此删除只作用于本地，不再同步给其他节点。
+// 删除产生的 EventDelete 事件来源为 OriginExternal。多个组可以共用同一个 Bus，组关闭时自动取消订阅，Bus 需要单独关闭。
+func WithInvalidation(bus *invalidation.Bus) GroupOption {
+	return func(g *Group) {
+		g.invalidation = bus
+	}
+}
+
+// startInvalidation 开始接收失效消息
+func (g *Group) startInvalidation() {
+	if g.invalidation == nil {
+		return
+	}
+	g.invalidationStop = g.invalidation.Subscribe(g.onInvalidation)
+}
+
+// stopInvalidation 停止接收失效消息
+func (g *Group) stopInvalidation() {
+	if g.invalidationStop != nil {
+		g.invalidationStop()
+	}
+}
+
+// onInvalidation 处理一条失效消息
+// OpSet 携带版本时，在该版本之后写入本地缓存的副本已经是新值，予以保留
+func (g *Group) onInvalidation(msg invalidation.Message) {
+	if msg.Group != g.name || g.closed.Load() == 1 {
+		return
+	}
+
+	for _, key := range msg.Keys {
+		if msg.Op == invalidation.OpSet && msg.Version > 0 {
+			view, ok := g.localCache.Get(context.Background(), key)
+			if ok && !view.written.IsZero() && view.written.UnixNano() >= msg.Version {
+				continue
+			}
+		}
+
+		g.forgetLoads(key)
+		if g.localCache.Delete(key) {
+			g.stats.invalidations.Add(1)
+			g.publish(EventDelete, key, ByteView{}, OriginExternal)
+		}
+	}
+}
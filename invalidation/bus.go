@@ -0,0 +1,222 @@
+// Package invalidation 通过外部消息系统（Redis pub/sub、NATS 等）向所有节点广播失效消息，
+// 不经过 MyCache 直接修改数据源的服务发布消息后，每个节点都会删除本地缓存中的旧副本。
+//
+// 消息以 JSON 编码发布到同一个频道，其他语言的服务可以直接发布：
+//
+//	{"op":"delete","group":"users","keys":["42","43"]}
+//	{"op":"set","group":"users","keys":["42"],"version":1760601600000000000}
+//
+// 发布订阅是至多一次投递，节点与消息系统断开期间发布的消息会丢失，缓存组仍应设置过期时间作为兜底。
+// Kafka 通过 REST Proxy 接入（见 NewKafka），其他消息系统可以通过实现 Transport 接入。
+package invalidation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultChannel 默认的频道（NATS 中为 subject）
+const DefaultChannel = "mycache.invalidation"
+
+const (
+	// minResubscribeDelay 订阅断开后重新订阅的初始等待时间
+	minResubscribeDelay = 100 * time.Millisecond
+	// maxResubscribeDelay 订阅断开后重新订阅的最长等待时间
+	maxResubscribeDelay = 10 * time.Second
+	// maxPayloadSize 接收的单条消息的最大字节数，服务端声明更大的长度时视为连接出错，避免按其分配内存
+	maxPayloadSize = 64 << 20
+)
+
+// Op 失效消息的类型
+type Op string
+
+const (
+	// OpDelete 数据源中的 key 被删除或修改，所有节点删除本地副本
+	OpDelete Op = "delete"
+	// OpSet 数据源中的 key 被写入新版本，早于 Version 写入本地缓存的副本被删除
+	OpSet Op = "set"
+)
+
+// Message 一条失效消息
+type Message struct {
+	Op    Op       `json:"op"`
+	Group string   `json:"group"`
+	Keys  []string `json:"keys"`
+	// Version 写入数据源的时间（Unix 纳秒），只用于 OpSet：在此之后重新加载到本地缓存的副本已经是新值，
+	// 不会被删除；为 0 时与 OpDelete 相同
+	Version int64 `json:"version,omitempty"`
+}
+
+// validate 检查消息是否完整
+func (m Message) validate() error {
+	if m.Op != OpDelete && m.Op != OpSet {
+		return fmt.Errorf("invalidation: unknown op %q", m.Op)
+	}
+	if m.Group == "" {
+		return errors.New("invalidation: group is required")
+	}
+	if len(m.Keys) == 0 {
+		return errors.New("invalidation: keys are required")
+	}
+	return nil
+}
+
+// Transport 失效消息的传输方式
+type Transport interface {
+	// Publish 向 channel 发布一条消息
+	Publish(ctx context.Context, channel string, payload []byte) error
+	// Subscribe 订阅 channel 并对收到的每条消息串行调用 handler，直到 ctx 结束或连接断开才返回，
+	// 断开后由 Bus 重新订阅
+	Subscribe(ctx context.Context, channel string, handler func(payload []byte)) error
+	// Close 关闭传输方式持有的连接
+	Close() error
+}
+
+// Bus 失效消息总线，在后台保持对频道的订阅，并将收到的消息分发给所有订阅者
+type Bus struct {
+	transport Transport
+	channel   string
+
+	mu       sync.RWMutex
+	handlers map[uint64]func(Message)
+	nextID   uint64
+
+	cancel    context.CancelFunc
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Option 配置 Bus
+type Option func(*Bus)
+
+// WithChannel 使用指定的频道，同一集群的所有节点和发布者需要一致
+func WithChannel(channel string) Option {
+	return func(b *Bus) {
+		b.channel = channel
+	}
+}
+
+// New 创建失效消息总线并开始订阅
+//
+//	bus := invalidation.New(invalidation.NewRedis(&invalidation.RedisConfig{Addr: "redis:6379"}))
+//	group := mycache.NewGroup("users", 64<<20, source, mycache.WithInvalidation(bus))
+func New(transport Transport, opts ...Option) *Bus {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &Bus{
+		transport: transport,
+		channel:   DefaultChannel,
+		handlers:  make(map[uint64]func(Message)),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	go b.run(ctx)
+	return b
+}
+
+// Publish 发布一条失效消息
+func (b *Bus) Publish(ctx context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("invalidation: failed to encode message: %w", err)
+	}
+	return b.transport.Publish(ctx, b.channel, payload)
+}
+
+// PublishDelete 通知所有节点删除 group 中的 keys
+func (b *Bus) PublishDelete(ctx context.Context, group string, keys ...string) error {
+	return b.Publish(ctx, Message{Op: OpDelete, Group: group, Keys: keys})
+}
+
+// PublishSet 通知所有节点 group 中的 keys 在 written 时写入了新版本
+func (b *Bus) PublishSet(ctx context.Context, group string, written time.Time, keys ...string) error {
+	return b.Publish(ctx, Message{Op: OpSet, Group: group, Keys: keys, Version: written.UnixNano()})
+}
+
+// Subscribe 注册 fn 接收所有失效消息，返回取消订阅的函数
+// fn 在订阅协程中串行调用，不应阻塞
+func (b *Bus) Subscribe(fn func(Message)) func() {
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = fn
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		delete(b.handlers, id)
+		b.mu.Unlock()
+	}
+}
+
+// Close 停止订阅并关闭传输方式
+func (b *Bus) Close() error {
+	var err error
+	b.closeOnce.Do(func() {
+		b.cancel()
+		<-b.done
+		err = b.transport.Close()
+	})
+	return err
+}
+
+// run 保持对频道的订阅，断开后退避重新订阅
+func (b *Bus) run(ctx context.Context) {
+	defer close(b.done)
+
+	delay := minResubscribeDelay
+	for {
+		start := time.Now()
+		err := b.transport.Subscribe(ctx, b.channel, b.dispatch)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) > maxResubscribeDelay {
+			// 订阅保持了一段时间后才断开，重新从最短的等待时间开始
+			delay = minResubscribeDelay
+		}
+		log.Printf("[Invalidation] subscription to %s lost: %v, retrying in %v", b.channel, err, delay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxResubscribeDelay)
+	}
+}
+
+// dispatch 解码收到的消息并分发给所有订阅者
+func (b *Bus) dispatch(payload []byte) {
+	var msg Message
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		log.Printf("[Invalidation] failed to decode message: %v", err)
+		return
+	}
+	if err := msg.validate(); err != nil {
+		log.Printf("[Invalidation] dropped message: %v", err)
+		return
+	}
+
+	b.mu.RLock()
+	handlers := make([]func(Message), 0, len(b.handlers))
+	for _, fn := range b.handlers {
+		handlers = append(handlers, fn)
+	}
+	b.mu.RUnlock()
+
+	for _, fn := range handlers {
+		fn(msg)
+	}
+}
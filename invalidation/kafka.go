@@ -0,0 +1,194 @@
+package invalidation
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// kafkaBinaryType REST Proxy v2 中以 base64 传递消息内容的格式
+	kafkaBinaryType = "application/vnd.kafka.binary.v2+json"
+	// kafkaV2Type REST Proxy v2 中管理消费者的请求格式
+	kafkaV2Type = "application/vnd.kafka.v2+json"
+	// defaultKafkaPollTimeout 每次拉取消息时等待新消息的默认时间
+	defaultKafkaPollTimeout = time.Second
+	// defaultKafkaTimeout 每次请求的默认超时时间，需要大于 PollTimeout
+	defaultKafkaTimeout = 10 * time.Second
+)
+
+// KafkaConfig 定义通过 Kafka REST Proxy 收发消息的传输方式的配置
+type KafkaConfig struct {
+	URL     string            // REST Proxy 地址，如 "http://kafka-rest:8082"
+	Headers map[string]string // 附加的请求头，如 Authorization
+	// ConsumerPrefix 消费组名的前缀，默认为 "mycache-invalidation"。每次订阅使用 前缀 + 随机后缀 的独立消费组，
+	// 使每个节点都收到所有消息
+	ConsumerPrefix string
+	PollTimeout    time.Duration // 每次拉取消息时等待新消息的时间，0 使用默认值 1s
+	Timeout        time.Duration // 每次请求的超时时间，0 使用默认值 10s
+	HTTPClient     *http.Client  // 为空时使用按 Timeout 创建的客户端
+}
+
+// Kafka 通过 Kafka REST Proxy（v2 API）收发消息的传输方式，频道即 topic
+//
+// 发布时向 topic 写入一条消息；订阅时创建独立的消费者实例，从订阅时的最新位置开始拉取，订阅结束时删除该实例。
+// 与 Redis、NATS 相同，节点订阅之前和断开期间发布的消息不会投递给该节点。不依赖 Kafka 客户端库。
+type Kafka struct {
+	config KafkaConfig
+	client *http.Client
+}
+
+var _ Transport = (*Kafka)(nil)
+
+// NewKafka 创建 Kafka REST Proxy 传输方式
+func NewKafka(config KafkaConfig) *Kafka {
+	if config.ConsumerPrefix == "" {
+		config.ConsumerPrefix = "mycache-invalidation"
+	}
+	if config.PollTimeout <= 0 {
+		config.PollTimeout = defaultKafkaPollTimeout
+	}
+	client := config.HTTPClient
+	if client == nil {
+		timeout := config.Timeout
+		if timeout <= 0 {
+			timeout = defaultKafkaTimeout
+		}
+		client = &http.Client{Timeout: timeout}
+	}
+	return &Kafka{config: config, client: client}
+}
+
+// kafkaMessage REST Proxy 中的一条消息，binary 格式下 Value 以 base64 编码
+type kafkaMessage struct {
+	Value []byte `json:"value"`
+}
+
+// Publish 向 topic 写入一条消息
+func (k *Kafka) Publish(ctx context.Context, channel string, payload []byte) error {
+	req := struct {
+		Records []kafkaMessage `json:"records"`
+	}{Records: []kafkaMessage{{Value: payload}}}
+	return k.do(ctx, http.MethodPost, k.config.URL+"/topics/"+url.PathEscape(channel), kafkaBinaryType, kafkaV2Type, req, nil)
+}
+
+// Subscribe 创建消费者实例并订阅 topic，持续拉取消息直到 ctx 结束或请求失败
+func (k *Kafka) Subscribe(ctx context.Context, channel string, handler func(payload []byte)) error {
+	suffix, err := randomSuffix()
+	if err != nil {
+		return err
+	}
+	group := k.config.ConsumerPrefix + "-" + suffix
+
+	var instance struct {
+		InstanceID string `json:"instance_id"`
+		BaseURI    string `json:"base_uri"`
+	}
+	err = k.do(ctx, http.MethodPost, k.config.URL+"/consumers/"+url.PathEscape(group), kafkaV2Type, kafkaV2Type, map[string]string{
+		"name":               suffix,
+		"format":             "binary",
+		"auto.offset.reset":  "latest",
+		"auto.commit.enable": "false",
+	}, &instance)
+	if err != nil {
+		return err
+	}
+	if instance.BaseURI == "" {
+		return errors.New("invalidation: kafka rest proxy returned no consumer base_uri")
+	}
+	defer func() {
+		// 订阅的 ctx 已经结束，单独限制删除消费者实例的时间
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		k.do(ctx, http.MethodDelete, instance.BaseURI, "", kafkaV2Type, nil, nil)
+	}()
+
+	err = k.do(ctx, http.MethodPost, instance.BaseURI+"/subscription", kafkaV2Type, kafkaV2Type, map[string][]string{
+		"topics": {channel},
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	records := instance.BaseURI + "/records?timeout=" + strconv.FormatInt(k.config.PollTimeout.Milliseconds(), 10) +
+		"&max_bytes=" + strconv.Itoa(maxPayloadSize)
+	for {
+		var batch []kafkaMessage
+		if err := k.do(ctx, http.MethodGet, records, "", kafkaBinaryType, nil, &batch); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		for _, msg := range batch {
+			handler(msg.Value)
+		}
+	}
+}
+
+// Close 没有需要释放的资源，消费者实例在各自的订阅结束时删除
+func (k *Kafka) Close() error {
+	return nil
+}
+
+// do 发送请求，body 不为 nil 时以 contentType 的 JSON 编码发送，out 不为 nil 时解码响应；
+// 非 2xx 响应返回包含响应内容的错误
+func (k *Kafka) do(ctx context.Context, method, endpoint, contentType, accept string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("invalidation: failed to encode kafka request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return fmt.Errorf("invalidation: invalid kafka request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", accept)
+	for name, value := range k.config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("invalidation: kafka request to %s failed: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("invalidation: %s %s returned %s: %s", method, endpoint, resp.Status, bytes.TrimSpace(msg))
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	// binary 格式的消息以 base64 编码，比原始内容大三分之一
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 2*maxPayloadSize)).Decode(out); err != nil {
+		return fmt.Errorf("invalidation: failed to decode kafka response from %s: %w", endpoint, err)
+	}
+	return nil
+}
+
+// randomSuffix 生成消费组和消费者实例名的随机后缀
+func randomSuffix() (string, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("invalidation: failed to generate consumer name: %w", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
@@ -0,0 +1,196 @@
+package invalidation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeKafkaREST 模拟 Kafka REST Proxy 的生产和消费接口，所有消费者共享一个 topic
+type fakeKafkaREST struct {
+	mu        sync.Mutex
+	produced  [][]byte
+	pending   [][]byte // 尚未被消费者拉取的消息
+	consumers map[string]bool
+	deleted   []string
+}
+
+func newFakeKafkaREST(t *testing.T) (*fakeKafkaREST, *httptest.Server) {
+	f := &fakeKafkaREST{consumers: make(map[string]bool)}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		path := r.URL.Path
+		switch {
+		case r.Method == http.MethodPost && path == "/topics/chan":
+			if ct := r.Header.Get("Content-Type"); ct != kafkaBinaryType {
+				http.Error(w, "unsupported content type "+ct, http.StatusUnsupportedMediaType)
+				return
+			}
+			var req struct {
+				Records []kafkaMessage `json:"records"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			for _, msg := range req.Records {
+				f.produced = append(f.produced, msg.Value)
+				f.pending = append(f.pending, msg.Value)
+			}
+			w.Write([]byte(`{"offsets":[{"partition":0,"offset":0}]}`))
+		case r.Method == http.MethodPost && strings.HasSuffix(path, "/subscription"):
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPost && strings.HasPrefix(path, "/consumers/"):
+			var req map[string]string
+			json.NewDecoder(r.Body).Decode(&req)
+			if req["format"] != "binary" {
+				http.Error(w, "unexpected format", http.StatusBadRequest)
+				return
+			}
+			base := path + "/instances/" + req["name"]
+			f.consumers[base] = true
+			json.NewEncoder(w).Encode(map[string]string{"instance_id": req["name"], "base_uri": srv.URL + base})
+		case r.Method == http.MethodGet && strings.HasSuffix(path, "/records"):
+			if !f.consumers[strings.TrimSuffix(path, "/records")] {
+				http.Error(w, `{"error_code":40403,"message":"Consumer instance not found."}`, http.StatusNotFound)
+				return
+			}
+			batch := make([]kafkaMessage, len(f.pending))
+			for i, value := range f.pending {
+				batch[i] = kafkaMessage{Value: value}
+			}
+			f.pending = nil
+			json.NewEncoder(w).Encode(batch)
+		case r.Method == http.MethodDelete:
+			delete(f.consumers, path)
+			f.deleted = append(f.deleted, path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func TestKafka_PublishAndSubscribe(t *testing.T) {
+	f, srv := newFakeKafkaREST(t)
+	k := NewKafka(KafkaConfig{URL: srv.URL, PollTimeout: 10 * time.Millisecond})
+	defer k.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	received := make(chan string, 1)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- k.Subscribe(ctx, "chan", func(payload []byte) { received <- string(payload) })
+	}()
+
+	// 消息中的任意字节都应原样送达
+	payload := "{\"op\":\"delete\"}\x00\xff"
+	if err := k.Publish(ctx, "chan", []byte(payload)); err != nil {
+		t.Fatalf("Publish 失败: %v", err)
+	}
+
+	select {
+	case got := <-received:
+		if got != payload {
+			t.Errorf("收到的消息应为 %q，实际为 %q", payload, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("没有收到消息")
+	}
+
+	cancel()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ctx 结束后应返回 context.Canceled，实际为 %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ctx 结束后 Subscribe 没有返回")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.deleted) != 1 || len(f.consumers) != 0 {
+		t.Errorf("订阅结束后应删除消费者实例，剩余 %v", f.consumers)
+	}
+}
+
+func TestKafka_SubscribeReturnsOnLostConsumer(t *testing.T) {
+	f, srv := newFakeKafkaREST(t)
+	k := NewKafka(KafkaConfig{URL: srv.URL, PollTimeout: 10 * time.Millisecond})
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- k.Subscribe(context.Background(), "chan", func([]byte) {})
+	}()
+
+	// 等待消费者实例创建后模拟 REST Proxy 重启丢失实例
+	deadline := time.Now().Add(time.Second)
+	for {
+		f.mu.Lock()
+		n := len(f.consumers)
+		if n > 0 {
+			f.consumers = make(map[string]bool)
+		}
+		f.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("没有创建消费者实例")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case err := <-errc:
+		if err == nil || !strings.Contains(err.Error(), "404") {
+			t.Errorf("消费者实例丢失时应返回错误以便 Bus 重新订阅，实际为 %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("消费者实例丢失后 Subscribe 没有返回")
+	}
+}
+
+func TestKafka_WithBus(t *testing.T) {
+	_, srv := newFakeKafkaREST(t)
+	bus := New(NewKafka(KafkaConfig{URL: srv.URL, PollTimeout: 10 * time.Millisecond}), WithChannel("chan"))
+	defer bus.Close()
+
+	received := make(chan Message, 1)
+	bus.Subscribe(func(msg Message) {
+		select {
+		case received <- msg:
+		default:
+		}
+	})
+
+	// 订阅在后台建立，之前发布的消息不会投递，重复发布直到收到
+	deadline := time.After(2 * time.Second)
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if err := bus.PublishDelete(context.Background(), "users", "42"); err != nil {
+			t.Fatalf("PublishDelete 失败: %v", err)
+		}
+		select {
+		case msg := <-received:
+			if msg.Op != OpDelete || msg.Group != "users" || len(msg.Keys) != 1 || msg.Keys[0] != "42" {
+				t.Errorf("收到的消息不正确: %+v", msg)
+			}
+			return
+		case <-ticker.C:
+		case <-deadline:
+			t.Fatal("没有收到消息")
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package invalidation
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Memory 进程内的传输方式，用于测试和单进程部署
+// 同一个 Memory 上的所有 Bus 都能收到彼此发布的消息，Publish 返回前消息已经交给所有订阅者
+type Memory struct {
+	mu     sync.RWMutex
+	subs   map[string]map[uint64]func([]byte) // 频道到订阅者
+	nextID uint64
+	closed bool
+}
+
+var _ Transport = (*Memory)(nil)
+
+// NewMemory 创建进程内的传输方式
+func NewMemory() *Memory {
+	return &Memory{subs: make(map[string]map[uint64]func([]byte))}
+}
+
+// Publish 将消息交给 channel 的所有订阅者
+func (m *Memory) Publish(ctx context.Context, channel string, payload []byte) error {
+	m.mu.RLock()
+	if m.closed {
+		m.mu.RUnlock()
+		return errors.New("invalidation: memory transport closed")
+	}
+	handlers := make([]func([]byte), 0, len(m.subs[channel]))
+	for _, fn := range m.subs[channel] {
+		handlers = append(handlers, fn)
+	}
+	m.mu.RUnlock()
+
+	for _, fn := range handlers {
+		// 每个订阅者拿到独立的副本
+		fn(append([]byte(nil), payload...))
+	}
+	return nil
+}
+
+// Subscribe 订阅 channel，直到 ctx 结束
+func (m *Memory) Subscribe(ctx context.Context, channel string, handler func(payload []byte)) error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return errors.New("invalidation: memory transport closed")
+	}
+	// Publish 可能在多个协程中并发调用，保证 handler 串行执行
+	var handlerMu sync.Mutex
+	id := m.nextID
+	m.nextID++
+	if m.subs[channel] == nil {
+		m.subs[channel] = make(map[uint64]func([]byte))
+	}
+	m.subs[channel][id] = func(payload []byte) {
+		handlerMu.Lock()
+		defer handlerMu.Unlock()
+		handler(payload)
+	}
+	m.mu.Unlock()
+
+	<-ctx.Done()
+
+	m.mu.Lock()
+	delete(m.subs[channel], id)
+	m.mu.Unlock()
+	return ctx.Err()
+}
+
+// Close 关闭传输方式，之后的发布和订阅返回错误
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	return nil
+}
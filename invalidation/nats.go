@@ -0,0 +1,284 @@
+package invalidation
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATSConfig 定义 NATS 传输方式的配置
+type NATSConfig struct {
+	Addr        string        // NATS 地址，如 "127.0.0.1:4222"
+	User        string        // 用户名，与 Password 一起使用
+	Password    string        // 密码
+	Token       string        // 认证 token，与用户名密码二选一
+	DialTimeout time.Duration // 建立连接和握手的超时时间
+	// Dial 自定义建立连接的方式，为空时使用 net.Dialer；服务端要求 TLS 时需要自行完成 TLS 握手
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// DefaultNATSConfig 提供默认的 NATS 配置
+var DefaultNATSConfig = &NATSConfig{
+	Addr:        "127.0.0.1:4222",
+	DialTimeout: 5 * time.Second,
+}
+
+// NATS 基于 NATS core（PUB / SUB）的传输方式，频道即 subject
+//
+// 发布复用一个连接，每次订阅使用独立的连接。只实现了 NATS 文本协议中发布订阅需要的部分，不依赖 NATS 客户端库。
+type NATS struct {
+	config NATSConfig
+
+	mu  sync.Mutex
+	pub *natsConn // 发布使用的连接，断开后在下次发布时重新建立
+}
+
+var _ Transport = (*NATS)(nil)
+
+// NewNATS 创建 NATS 传输方式，config 为 nil 时使用 DefaultNATSConfig
+func NewNATS(config *NATSConfig) *NATS {
+	if config == nil {
+		config = DefaultNATSConfig
+	}
+	return &NATS{config: *config}
+}
+
+// Publish 使用 PUB 命令发布消息，NATS core 不确认投递，写入连接即返回
+func (n *NATS) Publish(ctx context.Context, channel string, payload []byte) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.pub == nil || n.pub.isBroken() {
+		if n.pub != nil {
+			n.pub.conn.Close()
+		}
+		conn, err := n.dial(ctx)
+		if err != nil {
+			return err
+		}
+		n.pub = conn
+		// 发布连接同样需要响应服务端的 PING，否则会被服务端断开
+		go conn.serve(nil)
+	}
+
+	deadline, _ := ctx.Deadline()
+	err := n.pub.write(deadline, func(w *bufio.Writer) {
+		w.WriteString("PUB " + channel + " " + strconv.Itoa(len(payload)) + "\r\n")
+		w.Write(payload)
+		w.WriteString("\r\n")
+	})
+	if err != nil {
+		n.pub.conn.Close()
+		n.pub = nil
+	}
+	return err
+}
+
+// Subscribe 使用 SUB 命令订阅 subject，直到 ctx 结束或连接断开
+func (n *NATS) Subscribe(ctx context.Context, channel string, handler func(payload []byte)) error {
+	conn, err := n.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.conn.Close()
+
+	// ctx 结束时关闭连接，使阻塞的读取返回
+	stop := context.AfterFunc(ctx, func() { conn.conn.Close() })
+	defer stop()
+
+	err = conn.write(time.Time{}, func(w *bufio.Writer) {
+		w.WriteString("SUB " + channel + " 1\r\n")
+	})
+	if err == nil {
+		err = conn.serve(handler)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// Close 关闭发布使用的连接，订阅的连接在各自的 ctx 结束时关闭
+func (n *NATS) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.pub == nil {
+		return nil
+	}
+	err := n.pub.conn.Close()
+	n.pub = nil
+	return err
+}
+
+// natsConnect CONNECT 命令的参数
+type natsConnect struct {
+	Verbose  bool   `json:"verbose"`
+	Pedantic bool   `json:"pedantic"`
+	Name     string `json:"name"`
+	Lang     string `json:"lang"`
+	Version  string `json:"version"`
+	User     string `json:"user,omitempty"`
+	Pass     string `json:"pass,omitempty"`
+	Token    string `json:"auth_token,omitempty"`
+}
+
+// dial 建立连接并完成握手：读取 INFO，发送 CONNECT，用 PING/PONG 确认服务端接受了连接
+func (n *NATS) dial(ctx context.Context) (*natsConn, error) {
+	if n.config.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, n.config.DialTimeout)
+		defer cancel()
+	}
+
+	dial := n.config.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	nc, err := dial(ctx, "tcp", n.config.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalidation: failed to connect to nats %s: %w", n.config.Addr, err)
+	}
+	conn := &natsConn{conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+
+	if err := conn.handshake(ctx, natsConnect{
+		Name:    "mycache-invalidation",
+		Lang:    "go",
+		Version: "1.0.0",
+		User:    n.config.User,
+		Pass:    n.config.Password,
+		Token:   n.config.Token,
+	}); err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("invalidation: nats handshake with %s failed: %w", n.config.Addr, err)
+	}
+	return conn, nil
+}
+
+// natsConn 使用 NATS 文本协议通信的连接
+type natsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	wmu    sync.Mutex // 保护 w，读协程回复 PONG 时与发布并发写入
+	w      *bufio.Writer
+	broken bool // 读协程已经退出，由 wmu 保护
+}
+
+// handshake 完成连接握手
+func (c *natsConn) handshake(ctx context.Context, params natsConnect) error {
+	deadline, _ := ctx.Deadline()
+	c.conn.SetDeadline(deadline)
+	defer c.conn.SetDeadline(time.Time{})
+
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("unexpected greeting %q", line)
+	}
+
+	connect, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	c.w.WriteString("CONNECT ")
+	c.w.Write(connect)
+	c.w.WriteString("\r\nPING\r\n")
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+// serve 读取服务端的消息直到连接断开：回复 PING，将 MSG 的内容交给 handler，handler 为 nil 时丢弃
+func (c *natsConn) serve(handler func(payload []byte)) error {
+	err := c.readLoop(handler)
+	c.wmu.Lock()
+	c.broken = true
+	c.wmu.Unlock()
+	return err
+}
+
+func (c *natsConn) readLoop(handler func(payload []byte)) error {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		op, args, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(op) {
+		case "MSG":
+			// MSG <subject> <sid> [reply-to] <#bytes>
+			fields := strings.Fields(args)
+			if len(fields) < 3 {
+				return fmt.Errorf("invalidation: malformed nats message %q", line)
+			}
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil || size < 0 {
+				return fmt.Errorf("invalidation: malformed nats message %q", line)
+			}
+			if size > maxPayloadSize {
+				return fmt.Errorf("invalidation: nats message of %d bytes exceeds limit of %d", size, maxPayloadSize)
+			}
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(c.r, payload); err != nil {
+				return err
+			}
+			if handler != nil {
+				handler(payload[:size])
+			}
+		case "PING":
+			if err := c.write(time.Time{}, func(w *bufio.Writer) { w.WriteString("PONG\r\n") }); err != nil {
+				return err
+			}
+		case "-ERR":
+			return fmt.Errorf("nats: %s", strings.TrimSpace(args))
+		}
+	}
+}
+
+// write 在 wmu 保护下写入并发送
+func (c *natsConn) write(deadline time.Time, fn func(w *bufio.Writer)) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.conn.SetWriteDeadline(deadline)
+	fn(c.w)
+	return c.w.Flush()
+}
+
+// isBroken 返回读协程是否已经退出
+func (c *natsConn) isBroken() bool {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.broken
+}
+
+// readLine 读取一行并去掉行尾的 \r\n
+func (c *natsConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,163 @@
+package invalidation
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeNATS 服务端一侧的连接
+type fakeNATS struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// acceptNATS 等待下一个连接并完成握手，返回客户端发送的 CONNECT 参数
+func acceptNATS(t *testing.T, servers <-chan net.Conn) (*fakeNATS, natsConnect) {
+	t.Helper()
+	conn := acceptConn(t, servers)
+	s := &fakeNATS{t: t, conn: conn, r: bufio.NewReader(conn)}
+	s.send("INFO {\"server_id\":\"test\",\"max_payload\":1048576}\r\n")
+
+	var params natsConnect
+	line := s.readLine()
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "CONNECT ")), &params); err != nil {
+		t.Errorf("CONNECT 参数无法解析: %q", line)
+	}
+	s.expect("PING")
+	s.send("PONG\r\n")
+	return s, params
+}
+
+func (s *fakeNATS) send(data string) {
+	if _, err := io.WriteString(s.conn, data); err != nil {
+		s.t.Errorf("发送失败: %v", err)
+	}
+}
+
+func (s *fakeNATS) readLine() string {
+	line, err := s.r.ReadString('\n')
+	if err != nil {
+		s.t.Errorf("读取失败: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+func (s *fakeNATS) expect(want string) {
+	if line := s.readLine(); line != want {
+		s.t.Errorf("应收到 %q，实际为 %q", want, line)
+	}
+}
+
+func TestNATS_Publish(t *testing.T) {
+	dial, servers := pipeDialer(t)
+	n := NewNATS(&NATSConfig{Addr: "nats:4222", User: "app", Password: "secret", Dial: dial})
+	defer n.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s, params := acceptNATS(t, servers)
+		if params.User != "app" || params.Pass != "secret" {
+			t.Errorf("CONNECT 应携带用户名和密码，实际为 %+v", params)
+		}
+		s.expect("PUB chan 5")
+		s.expect("hello")
+		// 发布连接需要回复服务端的 PING
+		s.send("PING\r\n")
+		s.expect("PONG")
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := n.Publish(ctx, "chan", []byte("hello")); err != nil {
+		t.Fatalf("Publish 失败: %v", err)
+	}
+	<-done
+}
+
+func TestNATS_HandshakeError(t *testing.T) {
+	dial, servers := pipeDialer(t)
+	n := NewNATS(&NATSConfig{Addr: "nats:4222", Token: "bad", Dial: dial})
+	defer n.Close()
+
+	go func() {
+		conn := acceptConn(t, servers)
+		s := &fakeNATS{t: t, conn: conn, r: bufio.NewReader(conn)}
+		s.send("INFO {}\r\n")
+		s.readLine()
+		s.readLine()
+		s.send("-ERR 'Authorization Violation'\r\n")
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := n.Publish(ctx, "chan", []byte("hello"))
+	if err == nil || !strings.Contains(err.Error(), "Authorization Violation") {
+		t.Fatalf("认证失败时应返回服务端的错误，实际为 %v", err)
+	}
+}
+
+func TestNATS_Subscribe(t *testing.T) {
+	dial, servers := pipeDialer(t)
+	n := NewNATS(&NATSConfig{Addr: "nats:4222", Dial: dial})
+
+	go func() {
+		s, _ := acceptNATS(t, servers)
+		s.expect("SUB chan 1")
+		s.send("PING\r\n")
+		s.expect("PONG")
+		s.send("MSG chan 1 5\r\nhello\r\n")
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	received := make(chan string, 1)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- n.Subscribe(ctx, "chan", func(payload []byte) { received <- string(payload) })
+	}()
+
+	select {
+	case payload := <-received:
+		if payload != "hello" {
+			t.Errorf("收到的消息应为 hello，实际为 %q", payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("没有收到消息")
+	}
+
+	cancel()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ctx 结束后应返回 context.Canceled，实际为 %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ctx 结束后 Subscribe 没有返回")
+	}
+}
+
+func TestNATS_RejectsOversizedMessage(t *testing.T) {
+	dial, servers := pipeDialer(t)
+	n := NewNATS(&NATSConfig{Addr: "nats:4222", Dial: dial})
+
+	go func() {
+		s, _ := acceptNATS(t, servers)
+		s.expect("SUB chan 1")
+		s.send("MSG chan 1 9999999999\r\n")
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := n.Subscribe(ctx, "chan", func([]byte) { t.Error("不应收到超过限制的消息") })
+	if err == nil || !strings.Contains(err.Error(), "exceeds limit") {
+		t.Fatalf("应拒绝超过限制的消息，实际为 %v", err)
+	}
+}
@@ -0,0 +1,257 @@
+package invalidation
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxRESPArrayLen 回复中数组的最大元素个数，发布订阅的回复最多只有几个元素
+const maxRESPArrayLen = 1024
+
+// RedisConfig 定义 Redis 传输方式的配置
+type RedisConfig struct {
+	Addr        string        // Redis 地址，如 "127.0.0.1:6379"
+	Username    string        // Redis 6 ACL 用户名，为空时只使用 Password 认证
+	Password    string        // 密码，为空时不认证
+	DialTimeout time.Duration // 建立连接的超时时间
+	// Dial 自定义建立连接的方式（如 TLS），为空时使用 net.Dialer
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// DefaultRedisConfig 提供默认的 Redis 配置
+var DefaultRedisConfig = &RedisConfig{
+	Addr:        "127.0.0.1:6379",
+	DialTimeout: 5 * time.Second,
+}
+
+// Redis 基于 Redis pub/sub（PUBLISH / SUBSCRIBE）的传输方式
+//
+// 发布复用一个连接，每次订阅使用独立的连接。只实现了 RESP 协议中发布订阅需要的部分，不依赖 Redis 客户端库。
+type Redis struct {
+	config RedisConfig
+
+	mu  sync.Mutex
+	pub *respConn // 发布使用的连接，出错后关闭并在下次发布时重新建立
+}
+
+var _ Transport = (*Redis)(nil)
+
+// NewRedis 创建 Redis 传输方式，config 为 nil 时使用 DefaultRedisConfig
+func NewRedis(config *RedisConfig) *Redis {
+	if config == nil {
+		config = DefaultRedisConfig
+	}
+	return &Redis{config: *config}
+}
+
+// Publish 使用 PUBLISH 命令发布消息
+func (r *Redis) Publish(ctx context.Context, channel string, payload []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pub == nil {
+		conn, err := r.dial(ctx)
+		if err != nil {
+			return err
+		}
+		r.pub = conn
+	}
+
+	err := r.pub.withDeadline(ctx, func() error {
+		if err := r.pub.writeCommand("PUBLISH", channel, string(payload)); err != nil {
+			return err
+		}
+		_, err := r.pub.readReply()
+		return err
+	})
+	var redisErr respError
+	if err != nil && !errors.As(err, &redisErr) {
+		// 连接状态未知，下次发布重新建立连接
+		r.pub.conn.Close()
+		r.pub = nil
+	}
+	return err
+}
+
+// Subscribe 使用 SUBSCRIBE 命令订阅频道，直到 ctx 结束或连接断开
+func (r *Redis) Subscribe(ctx context.Context, channel string, handler func(payload []byte)) error {
+	conn, err := r.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.conn.Close()
+
+	// ctx 结束时关闭连接，使阻塞的读取返回
+	stop := context.AfterFunc(ctx, func() { conn.conn.Close() })
+	defer stop()
+
+	if err := conn.writeCommand("SUBSCRIBE", channel); err != nil {
+		return err
+	}
+	for {
+		reply, err := conn.readReply()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		// 推送消息的格式为 ["message", channel, payload]，订阅确认为 ["subscribe", channel, count]
+		items, ok := reply.([]interface{})
+		if !ok || len(items) != 3 {
+			continue
+		}
+		if kind, _ := items[0].([]byte); string(kind) != "message" {
+			continue
+		}
+		if payload, ok := items[2].([]byte); ok {
+			handler(payload)
+		}
+	}
+}
+
+// Close 关闭发布使用的连接，订阅的连接在各自的 ctx 结束时关闭
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pub == nil {
+		return nil
+	}
+	err := r.pub.conn.Close()
+	r.pub = nil
+	return err
+}
+
+// dial 建立连接并完成认证
+func (r *Redis) dial(ctx context.Context) (*respConn, error) {
+	if r.config.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.config.DialTimeout)
+		defer cancel()
+	}
+
+	dial := r.config.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	nc, err := dial(ctx, "tcp", r.config.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalidation: failed to connect to redis %s: %w", r.config.Addr, err)
+	}
+	conn := newRESPConn(nc)
+
+	if r.config.Password != "" {
+		args := []string{"AUTH", r.config.Password}
+		if r.config.Username != "" {
+			args = []string{"AUTH", r.config.Username, r.config.Password}
+		}
+		err := conn.withDeadline(ctx, func() error {
+			if err := conn.writeCommand(args...); err != nil {
+				return err
+			}
+			_, err := conn.readReply()
+			return err
+		})
+		if err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("invalidation: redis auth failed: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// respError Redis 返回的错误回复
+type respError string
+
+func (e respError) Error() string {
+	return "redis: " + string(e)
+}
+
+// respConn 使用 RESP 协议通信的连接
+type respConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+func newRESPConn(conn net.Conn) *respConn {
+	return &respConn{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+}
+
+// withDeadline 按 ctx 的截止时间设置连接的读写超时后执行 fn
+func (c *respConn) withDeadline(ctx context.Context, fn func() error) error {
+	deadline, _ := ctx.Deadline()
+	c.conn.SetDeadline(deadline)
+	defer c.conn.SetDeadline(time.Time{})
+	return fn()
+}
+
+// writeCommand 以 bulk string 数组的形式发送命令
+func (c *respConn) writeCommand(args ...string) error {
+	c.w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		c.w.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n")
+		c.w.WriteString(arg)
+		c.w.WriteString("\r\n")
+	}
+	return c.w.Flush()
+}
+
+// readReply 读取一个回复：简单字符串为 string，整数为 int64，bulk string 为 []byte，
+// 数组为 []interface{}，空值为 nil，错误回复返回 respError
+func (c *respConn) readReply() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("invalidation: malformed redis reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, respError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		if n > maxPayloadSize {
+			return nil, fmt.Errorf("invalidation: redis bulk string of %d bytes exceeds limit of %d", n, maxPayloadSize)
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		if n > maxRESPArrayLen {
+			return nil, fmt.Errorf("invalidation: redis array of %d elements exceeds limit of %d", n, maxRESPArrayLen)
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("invalidation: unexpected redis reply %q", line)
+	}
+}
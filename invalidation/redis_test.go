@@ -0,0 +1,177 @@
+package invalidation
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pipeDialer 返回使用 net.Pipe 建立连接的 Dial，服务端一侧的连接从返回的 channel 取得
+func pipeDialer(t *testing.T) (func(ctx context.Context, network, addr string) (net.Conn, error), <-chan net.Conn) {
+	t.Helper()
+	servers := make(chan net.Conn, 4)
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		client, server := net.Pipe()
+		t.Cleanup(func() { server.Close() })
+		servers <- server
+		return client, nil
+	}
+	return dial, servers
+}
+
+// acceptConn 等待 Dial 建立的下一个连接
+func acceptConn(t *testing.T, servers <-chan net.Conn) net.Conn {
+	t.Helper()
+	select {
+	case conn := <-servers:
+		return conn
+	case <-time.After(time.Second):
+		t.Fatal("没有建立连接")
+		return nil
+	}
+}
+
+// expectCommand 读取一条命令并检查参数
+func expectCommand(t *testing.T, conn *respConn, want ...string) {
+	t.Helper()
+	reply, err := conn.readReply()
+	if err != nil {
+		t.Errorf("读取命令失败: %v", err)
+		return
+	}
+	items, _ := reply.([]interface{})
+	got := make([]string, len(items))
+	for i, item := range items {
+		b, _ := item.([]byte)
+		got[i] = string(b)
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("命令应为 %q，实际为 %q", want, got)
+	}
+}
+
+func TestRedis_PublishWithAuth(t *testing.T) {
+	dial, servers := pipeDialer(t)
+	r := NewRedis(&RedisConfig{Addr: "redis:6379", Username: "app", Password: "secret", Dial: dial})
+	defer r.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server := newRESPConn(acceptConn(t, servers))
+		expectCommand(t, server, "AUTH", "app", "secret")
+		server.w.WriteString("+OK\r\n")
+		server.w.Flush()
+		expectCommand(t, server, "PUBLISH", "chan", `{"op":"delete"}`)
+		server.w.WriteString(":2\r\n")
+		server.w.Flush()
+		// 错误回复不影响连接，下一次发布复用同一个连接
+		expectCommand(t, server, "PUBLISH", "chan", "again")
+		server.w.WriteString("-ERR no permission\r\n")
+		server.w.Flush()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := r.Publish(ctx, "chan", []byte(`{"op":"delete"}`)); err != nil {
+		t.Fatalf("Publish 失败: %v", err)
+	}
+	var redisErr respError
+	if err := r.Publish(ctx, "chan", []byte("again")); !errors.As(err, &redisErr) {
+		t.Fatalf("错误回复应返回 respError，实际为 %v", err)
+	}
+	if r.pub == nil {
+		t.Error("错误回复后应保留发布连接")
+	}
+	<-done
+}
+
+func TestRedis_Subscribe(t *testing.T) {
+	dial, servers := pipeDialer(t)
+	r := NewRedis(&RedisConfig{Addr: "redis:6379", Dial: dial})
+
+	go func() {
+		server := newRESPConn(acceptConn(t, servers))
+		expectCommand(t, server, "SUBSCRIBE", "chan")
+		server.w.WriteString("*3\r\n$9\r\nsubscribe\r\n$4\r\nchan\r\n:1\r\n")
+		server.w.WriteString("*3\r\n$7\r\nmessage\r\n$4\r\nchan\r\n$5\r\nhello\r\n")
+		server.w.Flush()
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	received := make(chan string, 1)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- r.Subscribe(ctx, "chan", func(payload []byte) { received <- string(payload) })
+	}()
+
+	select {
+	case payload := <-received:
+		if payload != "hello" {
+			t.Errorf("收到的消息应为 hello，实际为 %q", payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("没有收到消息")
+	}
+
+	cancel()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ctx 结束后应返回 context.Canceled，实际为 %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ctx 结束后 Subscribe 没有返回")
+	}
+}
+
+func TestRESPConn_ReadReply(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  interface{}
+	}{
+		{"简单字符串", "+OK\r\n", "OK"},
+		{"整数", ":42\r\n", int64(42)},
+		{"bulk string", "$5\r\nhello\r\n", "hello"},
+		{"空值", "$-1\r\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &respConn{r: bufio.NewReader(strings.NewReader(tt.input))}
+			got, err := conn.readReply()
+			if err != nil {
+				t.Fatalf("readReply 失败: %v", err)
+			}
+			if b, ok := got.([]byte); ok {
+				got = string(b)
+			}
+			if got != tt.want {
+				t.Errorf("回复应为 %v，实际为 %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRESPConn_RejectsOversizedReply(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"bulk string", "$9999999999\r\n"},
+		{"数组", "*100000000\r\n"},
+		{"格式错误", "hello\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &respConn{r: bufio.NewReader(strings.NewReader(tt.input))}
+			if _, err := conn.readReply(); err == nil {
+				t.Error("应拒绝超过限制或格式错误的回复")
+			}
+		})
+	}
+}
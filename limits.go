@@ -0,0 +1,130 @@
+package mycache
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// WithMaxConcurrentStreams 限制每个连接上同时进行的 RPC 数量，超出的请求由客户端排队等待
+// 0 表示使用 gRPC 的默认值（不限制）
+func WithMaxConcurrentStreams(n uint32) ServerOption {
+	return func(o *ServerOptions) {
+		o.MaxConcurrentStreams = n
+	}
+}
+
+// WithMaxConnections 限制同时打开的连接数，达到上限后新连接在内核队列中等待，
+// 直到已有连接关闭；0 表示不限制
+func WithMaxConnections(n int) ServerOption {
+	return func(o *ServerOptions) {
+		o.MaxConnections = n
+	}
+}
+
+// WithRequestWorkers 使用 n 个常驻 goroutine 处理请求，并限制同时执行的请求数不超过 n
+//
+// 超出的请求排队等待空闲的 worker，直到请求的截止时间，超时返回 DeadlineExceeded；
+// 健康检查不受限制。与 WithLoadShedding 同时使用时，排队中的请求同样计入正在处理的请求数。
+// 0 表示不限制，每个请求使用独立的 goroutine。
+func WithRequestWorkers(n int) ServerOption {
+	return func(o *ServerOptions) {
+		o.RequestWorkers = n
+	}
+}
+
+// workerLimiter 限制同时执行的请求数
+type workerLimiter struct {
+	sem chan struct{}
+}
+
+// newWorkerLimiter 创建最多同时执行 n 个请求的限制器
+func newWorkerLimiter(n int) *workerLimiter {
+	return &workerLimiter{sem: make(chan struct{}, n)}
+}
+
+// acquire 等待空闲的 worker，ctx 结束时返回对应的 gRPC 状态
+func (w *workerLimiter) acquire(ctx context.Context, method string) (func(), error) {
+	if strings.HasPrefix(method, healthServicePrefix) {
+		return func() {}, nil
+	}
+	select {
+	case w.sem <- struct{}{}:
+		return func() { <-w.sem }, nil
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// unaryInterceptor 一元请求在空闲的 worker 上执行
+func (w *workerLimiter) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	release, err := w.acquire(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return handler(ctx, req)
+}
+
+// streamInterceptor 流式请求在空闲的 worker 上执行
+func (w *workerLimiter) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	release, err := w.acquire(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return handler(srv, ss)
+}
+
+// limitListener 限制同时打开的连接数
+type limitListener struct {
+	net.Listener
+	sem  chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// newLimitListener 返回最多同时接受 n 个连接的 Listener
+func newLimitListener(l net.Listener, n int) net.Listener {
+	return &limitListener{Listener: l, sem: make(chan struct{}, n), done: make(chan struct{})}
+}
+
+// Accept 等待有空闲的连接配额后再接受连接
+func (l *limitListener) Accept() (net.Conn, error) {
+	select {
+	case l.sem <- struct{}{}:
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		<-l.sem
+		return nil, err
+	}
+	return &limitConn{Conn: conn, release: func() { <-l.sem }}, nil
+}
+
+// Close 关闭 Listener，并唤醒等待连接配额的 Accept
+func (l *limitListener) Close() error {
+	err := l.Listener.Close()
+	l.once.Do(func() { close(l.done) })
+	return err
+}
+
+// limitConn 关闭时归还连接配额
+type limitConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
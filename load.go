@@ -0,0 +1,108 @@
+package mycache
+
+import (
+	"time"
+
+	"github.com/linhx1999/MyCache-Go/registry"
+)
+
+// WithLoadReport 每隔 interval 将本节点的 QPS、缓存内存使用率和健康状态发布到 etcd 的注册信息中，
+// 其他节点的 ClientPicker 据此在选择副本时避开过载的节点（见 WithLoadThresholds）
+// 只对 etcd 注册生效；最近一个周期内丢弃过请求或正在处理的请求数达到 WithShedThreshold 时标记为过载
+func WithLoadReport(interval time.Duration) ServerOption {
+	return func(o *ServerOptions) {
+		o.LoadReportInterval = interval
+	}
+}
+
+// WithLoadThresholds 设置判断节点过载的阈值，0 表示不按该项判断
+// 节点自身标记为过载或不健康时总是视为过载，与阈值无关
+func WithLoadThresholds(maxQPS, maxMemory float64) PickerOption {
+	return func(p *ClientPicker) {
+		p.maxQPS = maxQPS
+		p.maxMemory = maxMemory
+	}
+}
+
+// LoadAwarePicker 是 ReplicaPicker 的可选扩展，按节点发布的负载排列读取时尝试的副本
+type LoadAwarePicker interface {
+	// PickReadReplicas 与 PickPeers 返回相同的节点，过载的节点排在最后
+	PickReadReplicas(key string, n int) []Peer
+}
+
+// loadReporter 返回发布负载时调用的函数，QPS 按两次调用之间的请求数计算
+// 只在注册的后台 goroutine 中调用，不能获取 s.mu（注册时已持有）
+func (s *Server) loadReporter() func() registry.Load {
+	lastTotal := s.shedder.total.Load()
+	lastShed := s.shedder.shed.Load()
+	lastTime := time.Now()
+
+	return func() registry.Load {
+		now := time.Now()
+		total, shed := s.shedder.total.Load(), s.shedder.shed.Load()
+		load := registry.Load{
+			Memory:  s.memoryUsage(),
+			Healthy: s.serving.Load(),
+			Overloaded: shed > lastShed ||
+				(s.shedder.threshold > 0 && s.shedder.inFlight.Load() >= s.shedder.threshold),
+		}
+		if elapsed := now.Sub(lastTime).Seconds(); elapsed > 0 {
+			load.QPS = float64(total-lastTotal) / elapsed
+		}
+		lastTotal, lastShed, lastTime = total, shed, now
+		return load
+	}
+}
+
+// memoryUsage 返回所有组本地缓存的内存使用率
+func (s *Server) memoryUsage() float64 {
+	var used, capacity int64
+	for _, g := range s.servedGroups() {
+		used += g.localCache.UsedBytes()
+		capacity += g.localCache.opts.MaxBytes
+	}
+	if capacity <= 0 {
+		return 0
+	}
+	return float64(used) / float64(capacity)
+}
+
+// setLoad 记录节点发布的负载，load 为 nil 表示节点没有发布负载，调用者必须持有写锁
+func (p *ClientPicker) setLoad(addr string, load *registry.Load) {
+	if load == nil {
+		delete(p.loads, addr)
+		return
+	}
+	p.loads[addr] = *load
+}
+
+// overloaded 判断节点是否过载，调用者必须持有读锁
+func (p *ClientPicker) overloaded(addr string) bool {
+	load, ok := p.loads[addr]
+	if !ok {
+		return false
+	}
+	return load.Overloaded || !load.Healthy ||
+		(p.maxQPS > 0 && load.QPS > p.maxQPS) ||
+		(p.maxMemory > 0 && load.Memory > p.maxMemory)
+}
+
+// PickReadReplicas 实现 LoadAwarePicker
+func (p *ClientPicker) PickReadReplicas(key string, n int) []Peer {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var peers, busy []Peer
+	for _, addr := range p.consHash.GetN(key, n) {
+		client, ok := p.clients[addr]
+		if addr == p.selfAddr || !ok {
+			continue
+		}
+		if p.overloaded(addr) {
+			busy = append(busy, client)
+		} else {
+			peers = append(peers, client)
+		}
+	}
+	return append(peers, busy...)
+}
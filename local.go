@@ -0,0 +1,168 @@
+package mycache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ValueCodec 在 Local 的值类型与缓存中保存的字节之间转换
+type ValueCodec[V any] interface {
+	Marshal(value V) ([]byte, error)
+	Unmarshal(data []byte) (V, error)
+}
+
+// JSONCodec 返回以 JSON 编码值的 ValueCodec
+func JSONCodec[V any]() ValueCodec[V] {
+	return jsonCodec[V]{}
+}
+
+// LocalSource 缓存未命中时加载 key 的值，返回（或包装）ErrNotFound 表示数据源中没有该 key
+type LocalSource[V any] func(ctx context.Context, key string) (V, error)
+
+// Local 只在本进程内使用的缓存，值的类型为 V
+//
+// Local 包装一个没有节点、不连接 etcd、不启动 gRPC 服务的 Group，读写都只访问本地缓存和数据源，
+// 过期、SingleFlight 合并加载、配额、事件订阅等功能与 Group 相同。需要 Group 的其他功能
+// （如 Subscribe、Stats）时通过 Group 方法获取。
+type Local[V any] struct {
+	group *Group
+	codec ValueCodec[V]
+}
+
+// NewLocal 创建本进程内使用的缓存，值为 []byte 或 string 时直接保存，其他类型以 JSON 编码
+//
+//	users := mycache.NewLocal("users", 64<<20, func(ctx context.Context, id string) (User, error) {
+//		return db.LoadUser(ctx, id)
+//	}, mycache.WithExpiration(10*time.Minute))
+//	user, err := users.Get(ctx, "42")
+//
+// source 为 nil 时缓存只保存 Set 写入的值，未命中返回 ErrNotFound。opts 中的 WithPeers 会被忽略。
+func NewLocal[V any](name string, cacheBytes int64, source LocalSource[V], opts ...GroupOption) *Local[V] {
+	return NewLocalWithCodec(name, cacheBytes, source, defaultCodec[V]{}, opts...)
+}
+
+// NewLocalWithCodec 与 NewLocal 相同，使用 codec 编解码值
+func NewLocalWithCodec[V any](name string, cacheBytes int64, source LocalSource[V], codec ValueCodec[V], opts ...GroupOption) *Local[V] {
+	l := &Local[V]{codec: codec}
+
+	dataSource := DataSourceFunc(func(ctx context.Context, key string) ([]byte, error) {
+		if source == nil {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+		value, err := source(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		return codec.Marshal(value)
+	})
+
+	// 最后应用，保证不会使用其他节点
+	opts = append(opts, func(g *Group) { g.peers = nil })
+	l.group = NewGroup(name, cacheBytes, dataSource, opts...)
+	return l
+}
+
+// Get 返回 key 的值，未命中时从数据源加载
+func (l *Local[V]) Get(ctx context.Context, key string) (V, error) {
+	view, err := l.group.Get(ctx, key)
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	return l.decode(view)
+}
+
+// Set 写入 key 的值，组设置了过期时间时按组的过期时间过期
+func (l *Local[V]) Set(ctx context.Context, key string, value V) error {
+	data, err := l.codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("cache: failed to encode value: %w", err)
+	}
+	return l.group.Set(ctx, key, data)
+}
+
+// SetWithTTL 写入 key 的值，ttl 后过期
+func (l *Local[V]) SetWithTTL(ctx context.Context, key string, value V, ttl time.Duration) error {
+	data, err := l.codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("cache: failed to encode value: %w", err)
+	}
+	return l.group.SetWithTTL(ctx, key, data, ttl)
+}
+
+// Delete 删除 key
+func (l *Local[V]) Delete(ctx context.Context, key string) error {
+	return l.group.Delete(ctx, key)
+}
+
+// Refresh 绕过缓存从数据源重新加载 key 并更新缓存
+func (l *Local[V]) Refresh(ctx context.Context, key string) (V, error) {
+	view, err := l.group.Refresh(ctx, key)
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	return l.decode(view)
+}
+
+// Group 返回底层的 Group
+func (l *Local[V]) Group() *Group {
+	return l.group
+}
+
+// Close 关闭缓存
+func (l *Local[V]) Close() error {
+	return l.group.Close()
+}
+
+// decode 解码缓存中的值
+func (l *Local[V]) decode(view ByteView) (V, error) {
+	value, err := l.codec.Unmarshal(view.b)
+	if err != nil {
+		return value, fmt.Errorf("cache: failed to decode value: %w", err)
+	}
+	return value, nil
+}
+
+// jsonCodec 以 JSON 编码值
+type jsonCodec[V any] struct{}
+
+func (jsonCodec[V]) Marshal(value V) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (jsonCodec[V]) Unmarshal(data []byte) (V, error) {
+	var value V
+	err := json.Unmarshal(data, &value)
+	return value, err
+}
+
+// defaultCodec []byte 和 string 直接保存，其他类型以 JSON 编码
+type defaultCodec[V any] struct{}
+
+func (defaultCodec[V]) Marshal(value V) ([]byte, error) {
+	switch v := any(value).(type) {
+	case []byte:
+		return cloneBytes(v), nil
+	case string:
+		return []byte(v), nil
+	default:
+		return json.Marshal(value)
+	}
+}
+
+func (defaultCodec[V]) Unmarshal(data []byte) (V, error) {
+	var value V
+	switch v := any(&value).(type) {
+	case *[]byte:
+		*v = cloneBytes(data)
+	case *string:
+		*v = string(data)
+	default:
+		err := json.Unmarshal(data, &value)
+		return value, err
+	}
+	return value, nil
+}
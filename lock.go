@@ -0,0 +1,326 @@
+package mycache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	pb "github.com/linhx1999/MyCache-Go/pb"
+	"google.golang.org/grpc/metadata"
+)
+
+var (
+	// ErrLockHeld 锁已被其他持有者获取且未过期
+	ErrLockHeld = errors.New("cache: lock is held by another owner")
+	// ErrLockLost 租约已过期、已释放，或 owner 节点重启后丢失了锁
+	ErrLockLost = errors.New("cache: lock lease lost")
+)
+
+const (
+	// lockRetryMin Lock 等待锁释放时的初始轮询间隔
+	lockRetryMin = 10 * time.Millisecond
+	// lockRetryMax Lock 等待锁释放时的最大轮询间隔
+	lockRetryMax = 500 * time.Millisecond
+)
+
+// Lease 分布式锁的租约，由 Group.Lock 或 Group.TryLock 返回
+//
+// 锁保存在 key 的 owner 节点的内存中，租约到期前需要调用 Renew 续期，用完后调用 Release 释放。
+// owner 节点重启或下线时锁随之丢失，之后 Renew 返回 ErrLockLost，其他调用方可能在新的 owner 上
+// 获取到同一个锁。因此锁只适合协调缓存重建、定时任务等允许偶尔重复执行的场景，需要严格互斥时应使用 etcd 等
+// 共识系统。
+type Lease struct {
+	group *Group
+	key   string
+	token string
+	ttl   time.Duration
+	peer  lockPeer // 锁所在的 owner 节点，nil 表示本节点
+
+	mu       sync.Mutex
+	expire   time.Time
+	released bool
+}
+
+// Key 返回锁的 key
+func (l *Lease) Key() string {
+	return l.key
+}
+
+// Token 返回标识持有者的随机令牌
+func (l *Lease) Token() string {
+	return l.token
+}
+
+// Expire 返回本地估计的租约到期时间，按发出请求的时间计算，不会晚于 owner 上的到期时间
+func (l *Lease) Expire() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.expire
+}
+
+// Renew 将租约延长为从现在起的 ttl，锁已过期或已被释放时返回 ErrLockLost
+func (l *Lease) Renew(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.released {
+		return ErrLockLost
+	}
+
+	start := time.Now()
+	if err := l.group.lockOp(ctx, l.peer, l.key, l.token, l.ttl, pb.LockOp_LOCK_RENEW); err != nil {
+		return err
+	}
+	l.expire = start.Add(l.ttl)
+	return nil
+}
+
+// Release 释放锁，锁已过期或已被其他持有者获取时不做任何事，可以重复调用
+func (l *Lease) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.released {
+		return nil
+	}
+
+	if err := l.group.lockOp(ctx, l.peer, l.key, l.token, 0, pb.LockOp_LOCK_RELEASE); err != nil {
+		return err
+	}
+	l.released = true
+	return nil
+}
+
+// Lock 获取 key 上的锁，锁被其他持有者占用时等待，直到获取成功或 ctx 结束
+//
+//	lease, err := group.Lock(ctx, "rebuild:users", 30*time.Second)
+//	if err != nil {
+//		return err
+//	}
+//	defer lease.Release(context.Background())
+//
+// 锁与缓存的值相互独立，同名的 key 不影响缓存中的数据。
+func (g *Group) Lock(ctx context.Context, key string, ttl time.Duration) (*Lease, error) {
+	backoff := lockRetryMin
+	for {
+		lease, err := g.TryLock(ctx, key, ttl)
+		if !errors.Is(err, ErrLockHeld) {
+			return lease, err
+		}
+		if err := sleepContext(ctx, backoff); err != nil {
+			return nil, err
+		}
+		backoff = min(backoff*2, lockRetryMax)
+	}
+}
+
+// TryLock 尝试获取 key 上的锁，锁被其他持有者占用时立即返回 ErrLockHeld
+// 锁由 key 的 owner 节点管理，ttl 后未续期自动释放
+func (g *Group) TryLock(ctx context.Context, key string, ttl time.Duration) (*Lease, error) {
+	if g.closed.Load() == 1 {
+		return nil, ErrGroupClosed
+	}
+	if key == "" {
+		return nil, ErrKeyRequired
+	}
+	if ttl <= 0 {
+		return nil, errors.New("cache: lock ttl must be positive")
+	}
+	if err := g.policy.checkKey(ctx, key); err != nil {
+		return nil, err
+	}
+
+	peer, err := g.lockOwner(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	if err := g.lockOp(ctx, peer, key, token, ttl, pb.LockOp_LOCK_ACQUIRE); err != nil {
+		return nil, err
+	}
+	return &Lease{
+		group:  g,
+		key:    key,
+		token:  token,
+		ttl:    ttl,
+		peer:   peer,
+		expire: start.Add(ttl),
+	}, nil
+}
+
+// lockOwner 返回管理 key 上的锁的节点，nil 表示由本节点管理
+func (g *Group) lockOwner(ctx context.Context, key string) (lockPeer, error) {
+	if g.peers == nil || ctx.Value("from_peer") != nil {
+		return nil, nil
+	}
+	peer, ok, isSelf := g.peers.PickPeer(key)
+	if !ok || isSelf {
+		return nil, nil
+	}
+	lp, ok := peer.(lockPeer)
+	if !ok {
+		return nil, fmt.Errorf("cache: peer %T does not support locks", peer)
+	}
+	return lp, nil
+}
+
+// lockOp 在本节点或 peer 上执行锁操作
+func (g *Group) lockOp(ctx context.Context, peer lockPeer, key, token string, ttl time.Duration, op pb.LockOp) error {
+	if peer == nil {
+		return g.locks.do(key, token, ttl, op)
+	}
+	return peer.lock(ctx, g.name, key, token, ttl, op)
+}
+
+// newLockToken 生成标识锁持有者的随机令牌
+func newLockToken() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("cache: failed to generate lock token: %w", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// lockTable 本节点作为 owner 管理的锁，过期的锁由定时器删除
+type lockTable struct {
+	mu    sync.Mutex
+	locks map[string]*lockEntry
+}
+
+// lockEntry 一个被持有的锁
+type lockEntry struct {
+	token  string
+	expire time.Time
+	timer  *time.Timer
+}
+
+// do 执行锁操作，获取和续期时 ttl 必须为正数
+// 同一个 token 重复获取视为续期，请求重试时不会因为已经持有锁而失败
+func (t *lockTable) do(key, token string, ttl time.Duration, op pb.LockOp) error {
+	if op != pb.LockOp_LOCK_RELEASE && ttl <= 0 {
+		return errors.New("cache: lock ttl must be positive")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	e := t.locks[key]
+	if e != nil && !now.Before(e.expire) {
+		t.remove(key, e)
+		e = nil
+	}
+
+	switch op {
+	case pb.LockOp_LOCK_ACQUIRE:
+		if e != nil && e.token != token {
+			return ErrLockHeld
+		}
+		if e == nil {
+			if t.locks == nil {
+				t.locks = make(map[string]*lockEntry)
+			}
+			e = &lockEntry{token: token}
+			e.timer = time.AfterFunc(ttl, func() { t.expire(key, e) })
+			t.locks[key] = e
+		} else {
+			e.timer.Reset(ttl)
+		}
+		e.expire = now.Add(ttl)
+	case pb.LockOp_LOCK_RENEW:
+		if e == nil || e.token != token {
+			return ErrLockLost
+		}
+		e.expire = now.Add(ttl)
+		e.timer.Reset(ttl)
+	case pb.LockOp_LOCK_RELEASE:
+		if e != nil && e.token == token {
+			t.remove(key, e)
+		}
+	default:
+		return fmt.Errorf("cache: unknown lock op %v", op)
+	}
+	return nil
+}
+
+// expire 定时器到期时删除 e，期间被续期或替换的锁保留
+func (t *lockTable) expire(key string, e *lockEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.locks[key] == e && !time.Now().Before(e.expire) {
+		delete(t.locks, key)
+	}
+}
+
+// remove 删除 key 上的锁，调用时持有 mu
+func (t *lockTable) remove(key string, e *lockEntry) {
+	e.timer.Stop()
+	delete(t.locks, key)
+}
+
+// len 返回当前持有的锁的数量
+func (t *lockTable) len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.locks)
+}
+
+// lockPeer 由 Client 实现，在 owner 节点上执行锁操作
+type lockPeer interface {
+	lock(ctx context.Context, group, key, token string, ttl time.Duration, op pb.LockOp) error
+}
+
+// lock 调用 Lock RPC，锁操作对同一个 token 是幂等的，失败时可以重试
+func (c *Client) lock(ctx context.Context, group, key, token string, ttl time.Duration, op pb.LockOp) error {
+	if !c.Supports(FeatureLock) {
+		return fmt.Errorf("cache: peer %s does not support locks", c.addr)
+	}
+	ctx, cancel := withTimeout(ctx, c.opts.timeouts.Set)
+	defer cancel()
+
+	ctx = metadata.AppendToOutgoingContext(ctx, peerMetadataKey, "true")
+	req := &pb.LockRequest{
+		Group: group,
+		Key:   key,
+		Token: token,
+		Op:    op,
+	}
+	if ttl > 0 {
+		req.TtlMs = max(ttl.Milliseconds(), 1)
+	}
+	return c.invoke(ctx, true, func(ctx context.Context, cli pb.CacheServiceClient) error {
+		_, err := cli.Lock(ctx, req)
+		return err
+	})
+}
+
+// Lock 在本节点上执行锁操作，请求方已经按 key 选择了本节点作为 owner
+func (s *Server) Lock(ctx context.Context, req *pb.LockRequest) (*pb.LockResponse, error) {
+	group := s.group(req.Group)
+	if group == nil {
+		return nil, errGroupNotFound(req.Group)
+	}
+	if req.Key == "" {
+		return nil, ErrKeyRequired
+	}
+	if req.Token == "" {
+		return nil, badRequest(errors.New("cache: lock token is required"), "token")
+	}
+
+	if req.Op != pb.LockOp_LOCK_RELEASE && req.TtlMs <= 0 {
+		return nil, badRequest(errors.New("cache: lock ttl must be positive"), "ttl_ms")
+	}
+
+	ttl := time.Duration(req.TtlMs) * time.Millisecond
+	if err := group.locks.do(req.Key, req.Token, ttl, req.Op); err != nil {
+		return nil, err
+	}
+	return &pb.LockResponse{}, nil
+}
@@ -0,0 +1,177 @@
+package mycache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fixedPicker 将所有 key 交给同一个节点
+type fixedPicker struct {
+	peer Peer
+}
+
+func (p fixedPicker) PickPeer(key string) (Peer, bool, bool) {
+	return p.peer, true, false
+}
+
+func (p fixedPicker) Close() error {
+	return nil
+}
+
+func newLockGroup(t *testing.T, name string, opts ...GroupOption) *Group {
+	t.Helper()
+	g := NewGroup(name, 1<<20, DataSourceFunc(func(ctx context.Context, key string) ([]byte, error) {
+		return nil, errors.New("not found")
+	}), opts...)
+	t.Cleanup(func() { g.Close() })
+	return g
+}
+
+func TestLock_TryLockContention(t *testing.T) {
+	g := newLockGroup(t, "lock-contention")
+	ctx := context.Background()
+
+	lease, err := g.TryLock(ctx, "job", time.Minute)
+	if err != nil {
+		t.Fatalf("TryLock 失败: %v", err)
+	}
+	if _, err := g.TryLock(ctx, "job", time.Minute); !errors.Is(err, ErrLockHeld) {
+		t.Fatalf("锁被占用时应返回 ErrLockHeld，实际为 %v", err)
+	}
+	if _, err := g.TryLock(ctx, "other", time.Minute); err != nil {
+		t.Errorf("不同的 key 互不影响，实际为 %v", err)
+	}
+
+	if err := lease.Release(ctx); err != nil {
+		t.Fatalf("Release 失败: %v", err)
+	}
+	if _, err := g.TryLock(ctx, "job", time.Minute); err != nil {
+		t.Errorf("释放后应能再次获取锁，实际为 %v", err)
+	}
+}
+
+func TestLock_ExpiryReleasesLock(t *testing.T) {
+	g := newLockGroup(t, "lock-expiry")
+	ctx := context.Background()
+
+	lease, err := g.TryLock(ctx, "job", 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("TryLock 失败: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if n := g.locks.len(); n != 0 {
+		t.Errorf("过期的锁应由定时器删除，实际还有 %d 个", n)
+	}
+	if _, err := g.TryLock(ctx, "job", time.Minute); err != nil {
+		t.Fatalf("锁过期后应能被其他持有者获取，实际为 %v", err)
+	}
+	if err := lease.Renew(ctx); !errors.Is(err, ErrLockLost) {
+		t.Errorf("过期后续期应返回 ErrLockLost，实际为 %v", err)
+	}
+}
+
+func TestLock_RenewAfterExpiry(t *testing.T) {
+	g := newLockGroup(t, "lock-renew")
+	ctx := context.Background()
+
+	lease, err := g.TryLock(ctx, "job", 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("TryLock 失败: %v", err)
+	}
+	if err := lease.Renew(ctx); err != nil {
+		t.Fatalf("未过期时续期应成功，实际为 %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if err := lease.Renew(ctx); !errors.Is(err, ErrLockLost) {
+		t.Errorf("过期后续期应返回 ErrLockLost，实际为 %v", err)
+	}
+	if err := lease.Release(ctx); err != nil {
+		t.Errorf("释放已过期的锁应不做任何事，实际为 %v", err)
+	}
+	if err := lease.Renew(ctx); !errors.Is(err, ErrLockLost) {
+		t.Errorf("释放后续期应返回 ErrLockLost，实际为 %v", err)
+	}
+}
+
+func TestLock_StaleReleaseRejected(t *testing.T) {
+	g := newLockGroup(t, "lock-stale")
+	ctx := context.Background()
+
+	stale, err := g.TryLock(ctx, "job", 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("TryLock 失败: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	current, err := g.TryLock(ctx, "job", time.Minute)
+	if err != nil {
+		t.Fatalf("锁过期后 TryLock 失败: %v", err)
+	}
+
+	// 旧租约的令牌不能释放新持有者的锁
+	if err := stale.Release(ctx); err != nil {
+		t.Fatalf("旧租约 Release 应不做任何事，实际为 %v", err)
+	}
+	if _, err := g.TryLock(ctx, "job", time.Minute); !errors.Is(err, ErrLockHeld) {
+		t.Fatalf("旧租约释放后锁应仍由新持有者持有，实际为 %v", err)
+	}
+	if err := current.Renew(ctx); err != nil {
+		t.Errorf("新持有者续期应成功，实际为 %v", err)
+	}
+}
+
+func TestLock_RemoteOwner(t *testing.T) {
+	owner := newLockGroup(t, "lock-remote")
+
+	addr := freeAddr(t)
+	srv, err := NewServer(addr, "lock-test", WithoutRegistry())
+	if err != nil {
+		t.Fatalf("创建服务器失败: %v", err)
+	}
+	srv.RegisterGroup(owner)
+	go srv.Start()
+	defer srv.Stop()
+
+	client, err := DialNode(addr, WithWaitForReady(true))
+	if err != nil {
+		t.Fatalf("DialNode 失败: %v", err)
+	}
+	defer client.Close()
+
+	// 另一个节点上的同名组，key 的 owner 是 srv
+	caller := &Group{name: owner.name, peers: fixedPicker{peer: client}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	lease, err := caller.TryLock(ctx, "job", 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("TryLock 失败: %v", err)
+	}
+	if owner.locks.len() != 1 {
+		t.Fatalf("锁应保存在 owner 节点上")
+	}
+	if _, err := caller.TryLock(ctx, "job", time.Minute); !errors.Is(err, ErrLockHeld) {
+		t.Fatalf("锁被占用时应返回 ErrLockHeld，实际为 %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if err := lease.Renew(ctx); !errors.Is(err, ErrLockLost) {
+		t.Errorf("过期后续期应返回 ErrLockLost，实际为 %v", err)
+	}
+
+	current, err := caller.TryLock(ctx, "job", time.Minute)
+	if err != nil {
+		t.Fatalf("锁过期后 TryLock 失败: %v", err)
+	}
+	if err := lease.Release(ctx); err != nil {
+		t.Fatalf("旧租约 Release 应不做任何事，实际为 %v", err)
+	}
+	if err := current.Renew(ctx); err != nil {
+		t.Errorf("旧租约释放后新持有者续期应成功，实际为 %v", err)
+	}
+}
@@ -0,0 +1,275 @@
+package mycache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// rpcLatencyBuckets RPC 耗时直方图的桶上界（秒）
+var rpcLatencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// WithMetricsAddr 在 addr 上提供 Prometheus 格式的 /metrics 接口
+// 导出 RPC 请求数和耗时、各组的命中率和加载统计、本地存储占用以及节点健康状态
+func WithMetricsAddr(addr string) ServerOption {
+	return func(o *ServerOptions) {
+		o.MetricsAddr = addr
+	}
+}
+
+// methodMetrics 单个 RPC 方法的统计
+type methodMetrics struct {
+	codes   map[string]int64 // 状态码到请求数
+	buckets []int64          // 与 rpcLatencyBuckets 对应的累计计数
+	sum     float64          // 总耗时（秒）
+	count   int64
+}
+
+// rpcMetrics 按方法统计的 RPC 请求数和耗时
+type rpcMetrics struct {
+	mu      sync.Mutex
+	methods map[string]*methodMetrics
+}
+
+// newRPCMetrics 创建 RPC 统计
+func newRPCMetrics() *rpcMetrics {
+	return &rpcMetrics{methods: make(map[string]*methodMetrics)}
+}
+
+// observe 记录一次 RPC 的结果和耗时
+func (m *rpcMetrics) observe(fullMethod string, err error, elapsed time.Duration) {
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	code := status.Code(err).String()
+	seconds := elapsed.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mm, ok := m.methods[method]
+	if !ok {
+		mm = &methodMetrics{codes: make(map[string]int64), buckets: make([]int64, len(rpcLatencyBuckets))}
+		m.methods[method] = mm
+	}
+	mm.codes[code]++
+	mm.sum += seconds
+	mm.count++
+	for i, le := range rpcLatencyBuckets {
+		if seconds <= le {
+			mm.buckets[i]++
+		}
+	}
+}
+
+// unaryInterceptor 统计一元请求
+func (m *rpcMetrics) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	m.observe(info.FullMethod, err, time.Since(start))
+	return resp, err
+}
+
+// streamInterceptor 统计流式请求，耗时为整个流的持续时间
+func (m *rpcMetrics) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	m.observe(info.FullMethod, err, time.Since(start))
+	return err
+}
+
+// startMetrics 在后台启动 /metrics 接口，未设置 MetricsAddr 时不做任何事
+func (s *Server) startMetrics() error {
+	if s.opts.MetricsAddr == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", s.serveMetrics)
+	s.metricsServer = &http.Server{
+		Addr:              s.opts.MetricsAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	lis, err := s.listen(s.opts.MetricsAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen metrics: %v", err)
+	}
+
+	go func() {
+		log.Printf("[Server] metrics listening at %s", s.opts.MetricsAddr)
+		if err := s.metricsServer.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("[Server] ERROR: metrics server stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// metricsWriter 按 Prometheus 文本格式写入指标，同名指标只写一次 HELP 和 TYPE
+type metricsWriter struct {
+	w       *bufio.Writer
+	written map[string]bool
+}
+
+// header 写入指标的 HELP 和 TYPE
+func (m *metricsWriter) header(name, typ, help string) {
+	if m.written[name] {
+		return
+	}
+	m.written[name] = true
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample 写入一个样本，labels 为键值对
+func (m *metricsWriter) sample(name string, value float64, labels ...string) {
+	m.w.WriteString(name)
+	if len(labels) > 0 {
+		m.w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				m.w.WriteByte(',')
+			}
+			fmt.Fprintf(m.w, "%s=%q", labels[i], labels[i+1])
+		}
+		m.w.WriteByte('}')
+	}
+	m.w.WriteByte(' ')
+	m.w.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	m.w.WriteByte('\n')
+}
+
+// serveMetrics 处理 GET /metrics
+func (s *Server) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	mw := &metricsWriter{w: bufio.NewWriter(w), written: make(map[string]bool)}
+	defer mw.w.Flush()
+
+	s.writeRPCMetrics(mw)
+
+	mw.header("mycache_rpc_shed_total", "counter", "Requests shed due to overload.")
+	mw.sample("mycache_rpc_shed_total", float64(s.ShedRequests()))
+
+	s.writeGroupMetrics(mw)
+	s.writePeerMetrics(mw)
+}
+
+// writeRPCMetrics 写入 RPC 请求数和耗时直方图
+func (s *Server) writeRPCMetrics(mw *metricsWriter) {
+	m := s.rpcMetrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	methods := make([]string, 0, len(m.methods))
+	for method := range m.methods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	mw.header("mycache_rpc_requests_total", "counter", "RPC requests handled, by method and status code.")
+	for _, method := range methods {
+		mm := m.methods[method]
+		codes := make([]string, 0, len(mm.codes))
+		for code := range mm.codes {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		for _, code := range codes {
+			mw.sample("mycache_rpc_requests_total", float64(mm.codes[code]), "method", method, "code", code)
+		}
+	}
+
+	mw.header("mycache_rpc_duration_seconds", "histogram", "RPC handling latency in seconds.")
+	for _, method := range methods {
+		mm := m.methods[method]
+		for i, le := range rpcLatencyBuckets {
+			mw.sample("mycache_rpc_duration_seconds_bucket", float64(mm.buckets[i]),
+				"method", method, "le", strconv.FormatFloat(le, 'g', -1, 64))
+		}
+		mw.sample("mycache_rpc_duration_seconds_bucket", float64(mm.count), "method", method, "le", "+Inf")
+		mw.sample("mycache_rpc_duration_seconds_sum", mm.sum, "method", method)
+		mw.sample("mycache_rpc_duration_seconds_count", float64(mm.count), "method", method)
+	}
+}
+
+// writeGroupMetrics 将各组 Stats 中的数值项导出为 mycache_group_<name>{group="..."}
+func (s *Server) writeGroupMetrics(mw *metricsWriter) {
+	type groupSample struct {
+		group string
+		value float64
+	}
+	samples := make(map[string][]groupSample)
+	for _, group := range s.servedGroups() {
+		for key, v := range group.Stats() {
+			value, ok := metricValue(v)
+			if !ok {
+				continue
+			}
+			samples[key] = append(samples[key], groupSample{group: group.name, value: value})
+		}
+	}
+
+	keys := make([]string, 0, len(samples))
+	for key := range samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		metric := "mycache_group_" + key
+		mw.header(metric, "untyped", "Group statistic "+key+".")
+		for _, sample := range samples[key] {
+			mw.sample(metric, sample.value, "group", sample.group)
+		}
+	}
+}
+
+// writePeerMetrics 写入各组使用的 ClientPicker 中节点的健康状态
+func (s *Server) writePeerMetrics(mw *metricsWriter) {
+	statuses := s.groupPeerStatus()
+
+	mw.header("mycache_peer_up", "gauge", "Whether the peer is healthy and on the hash ring.")
+	for _, status := range statuses {
+		up := 0.0
+		if status.Healthy {
+			up = 1
+		}
+		mw.sample("mycache_peer_up", up, "peer", status.Addr)
+	}
+	mw.header("mycache_peer_consecutive_failures", "gauge", "Consecutive failed health checks of the peer.")
+	for _, status := range statuses {
+		mw.sample("mycache_peer_consecutive_failures", float64(status.ConsecutiveFailures), "peer", status.Addr)
+	}
+}
+
+// metricValue 将统计值转换为指标值，非数值类型返回 false
+func metricValue(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case time.Duration:
+		return v.Seconds(), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,273 @@
+package mycache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/linhx1999/MyCache-Go/consistenthash"
+)
+
+// topologyNotifier 由 PeerPicker 可选实现，节点加入、离开或被摘除时调用注册的回调
+// 回调可能在 picker 持有锁时被调用，不能阻塞
+type topologyNotifier interface {
+	onTopologyChange(fn func())
+}
+
+// MigrationResult 一次迁移的结果
+type MigrationResult struct {
+	Keys    int // 需要迁移的 key 数量（owner 已不是本节点）
+	Stored  int // 新 owner 成功写入的数量
+	Failed  int // 发送或写入失败的数量
+	Dropped int // 迁移后从本地删除的数量
+}
+
+// WithMigration 启用拓扑变化时的自动迁移
+//
+// 哈希环变化（节点加入、离开）后等待 delay 让成员变化稳定，再把本节点缓存中 owner
+// 已变为其他节点的 key 通过 Transfer 流式发送给新 owner，避免扩缩容时整个集群缓存未命中。
+// 只有位于从本节点迁出的哈希区间（见 consistenthash.Diff）中的 key 需要重新计算 owner；
+// 哈希环使用 Maglev 时无法按区间比较，检查全部 key。
+// drop 为 true 时，迁移全部成功后从本地删除这些 key。需要 PeerPicker 为 ClientPicker。
+func WithMigration(delay time.Duration, drop bool) GroupOption {
+	return func(g *Group) {
+		g.migrateDelay = delay
+		g.migrateDrop = drop
+	}
+}
+
+// startMigration 订阅节点变化并在后台自动迁移，未启用迁移或 picker 不支持时不做任何事
+func (g *Group) startMigration() {
+	if g.migrateDelay <= 0 || g.peers == nil || g.migrateStop != nil {
+		return
+	}
+	notifier, ok := g.peers.(topologyNotifier)
+	if !ok {
+		return
+	}
+
+	// 在订阅之前记录当前的哈希环，作为第一次迁移比较的基准
+	picker, _ := g.peers.(*ClientPicker)
+	prev := picker.ringCopy()
+
+	notify := make(chan struct{}, 1)
+	g.migrateStop = make(chan struct{})
+	notifier.onTopologyChange(func() {
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	})
+	go g.runMigrations(notify, g.migrateStop, picker, prev)
+}
+
+// runMigrations 等待节点变化稳定后执行迁移，直到组关闭
+// prev 为上一次迁移成功时的哈希环，为 nil 时检查全部 key
+func (g *Group) runMigrations(notify <-chan struct{}, stop <-chan struct{}, picker *ClientPicker, prev *consistenthash.HashRing) {
+	defer func() {
+		if prev != nil {
+			prev.Close()
+		}
+	}()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-notify:
+		}
+
+		// 延迟期间的后续变化重新计时，合并为一次迁移
+		timer := time.NewTimer(g.migrateDelay)
+	debounce:
+		for {
+			select {
+			case <-stop:
+				timer.Stop()
+				return
+			case <-notify:
+				timer.Reset(g.migrateDelay)
+			case <-timer.C:
+				break debounce
+			}
+		}
+
+		ctx, cancel := context.WithCancel(WithPriority(context.Background(), PriorityBackground))
+		go func() {
+			select {
+			case <-stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		cur := picker.ringCopy()
+		result, err := g.migrate(ctx, g.migrateDrop, movedKeys(prev, cur, picker), nil)
+		cancel()
+
+		if err != nil {
+			// 保留上一次的基准，下次迁移时重新检查这次变化涉及的区间
+			log.Printf("[MyCache] migration for group [%s] failed: %v", g.name, err)
+			if cur != nil {
+				cur.Close()
+			}
+			continue
+		}
+		if result.Keys > 0 {
+			log.Printf("[MyCache] migrated group [%s]: %+v", g.name, result)
+		}
+		if prev != nil {
+			prev.Close()
+		}
+		prev = cur
+	}
+}
+
+// ringCopy 复制当前的哈希环，picker 为 nil 或复制失败时返回 nil
+func (p *ClientPicker) ringCopy() *consistenthash.HashRing {
+	if p == nil {
+		return nil
+	}
+	p.mu.RLock()
+	state, err := p.consHash.Marshal()
+	p.mu.RUnlock()
+	if err != nil {
+		return nil
+	}
+	ring := consistenthash.New(p.ringOpts...)
+	if err := ring.Unmarshal(state); err != nil {
+		ring.Close()
+		return nil
+	}
+	return ring
+}
+
+// movedKeys 返回判断 key 是否位于从本节点迁出的哈希区间的函数
+// 无法比较两个哈希环时（任一为 nil、使用 Maglev 或本节点不在环上）返回 nil，此时需要检查全部 key
+func movedKeys(prev, cur *consistenthash.HashRing, picker *ClientPicker) func(key string) bool {
+	if prev == nil || cur == nil || picker.selfAddr == "" {
+		return nil
+	}
+	moves, err := consistenthash.Diff(prev, cur)
+	if err != nil {
+		return nil
+	}
+
+	var out []consistenthash.KeyRangeMove
+	for _, m := range moves {
+		if m.From == picker.selfAddr {
+			out = append(out, m)
+		}
+	}
+	return func(key string) bool {
+		hash := cur.Hash(key)
+		for _, m := range out {
+			if m.Contains(hash) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Migrate 将本节点缓存中 owner 为其他节点的 key 迁移到各自的 owner
+// 每个 owner 使用一个 Transfer 流，各 owner 并发迁移
+func (g *Group) Migrate(ctx context.Context) (MigrationResult, error) {
+	return g.migrate(ctx, g.migrateDrop, nil, nil)
+}
+
+// migrate 执行迁移，drop 为 true 时迁移成功后从本地删除
+// moved 不为 nil 时只检查 moved 返回 true 的 key，为 nil 时检查全部 key
+// progress 不为 nil 时在确定需要迁移的 key 之后以及每个 owner 迁移完成后以累计结果调用，调用是串行的
+func (g *Group) migrate(ctx context.Context, drop bool, moved func(key string) bool, progress func(MigrationResult)) (MigrationResult, error) {
+	var result MigrationResult
+	if g.closed.Load() == 1 {
+		return result, ErrGroupClosed
+	}
+	if g.peers == nil {
+		return result, nil
+	}
+
+	byPeer := make(map[Peer][]TransferEntry)
+	g.localCache.Range(func(key string, view ByteView) bool {
+		if moved != nil && !moved(key) {
+			return ctx.Err() == nil
+		}
+		peer, ok, isSelf := g.peers.PickPeer(key)
+		if ok && !isSelf {
+			byPeer[peer] = append(byPeer[peer], TransferEntry{
+				Key:      key,
+				Value:    view.b,
+				ExpireAt: view.expire,
+			})
+		}
+		return ctx.Err() == nil
+	})
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs []error
+	)
+	for _, entries := range byPeer {
+		result.Keys += len(entries)
+	}
+	if progress != nil {
+		progress(result)
+	}
+
+	for peer, entries := range byPeer {
+		wg.Add(1)
+		go func(peer Peer, entries []TransferEntry) {
+			defer wg.Done()
+			res, err := g.transferTo(ctx, peer, entries)
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.Stored += res.Stored
+			result.Failed += len(entries) - res.Stored
+			if progress != nil {
+				// 在 mu 释放之前调用，删除计入本次结果
+				defer func() { progress(result) }()
+			}
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+
+			// 全部写入成功后才从本地删除，部分失败时保留本地副本
+			if drop && res.Failed == 0 && res.Sent == len(entries) {
+				for _, entry := range entries {
+					if g.localCache.Delete(entry.Key) {
+						result.Dropped++
+					}
+				}
+			}
+		}(peer, entries)
+	}
+	wg.Wait()
+
+	g.stats.migratedKeys.Add(int64(result.Stored))
+	return result, errors.Join(errs...)
+}
+
+// transferTo 通过一个 Transfer 流把 entries 发送给 peer
+func (g *Group) transferTo(ctx context.Context, peer Peer, entries []TransferEntry) (TransferResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ch := make(chan TransferEntry)
+	go func() {
+		defer close(ch)
+		for _, entry := range entries {
+			select {
+			case ch <- entry:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return peer.Transfer(ctx, g.name, ch)
+}
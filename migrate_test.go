@@ -0,0 +1,54 @@
+package mycache
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/linhx1999/MyCache-Go/consistenthash"
+)
+
+func TestMigration_MovedKeys(t *testing.T) {
+	const self = "127.0.0.1:1"
+	picker, err := NewStaticPicker(self, []string{"127.0.0.1:2"})
+	if err != nil {
+		t.Fatalf("NewStaticPicker 失败: %v", err)
+	}
+	defer picker.Close()
+
+	prev := picker.ringCopy()
+	defer prev.Close()
+	picker.SetPeers("127.0.0.1:2", "127.0.0.1:3")
+	cur := picker.ringCopy()
+	defer cur.Close()
+
+	moved := movedKeys(prev, cur, picker)
+	if moved == nil {
+		t.Fatal("两个哈希环都可以比较时不应检查全部 key")
+	}
+	count := 0
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		// 只有原来属于本节点、现在属于其他节点的 key 需要迁移
+		want := prev.Get(key) == self && cur.Get(key) != self
+		if got := moved(key); got != want {
+			t.Fatalf("%s 从 %s 变为 %s，moved 应为 %v，实际为 %v", key, prev.Get(key), cur.Get(key), want, got)
+		}
+		if want {
+			count++
+		}
+	}
+	if count == 0 {
+		t.Fatal("新增节点后应有 key 从本节点迁出")
+	}
+
+	// 使用 Maglev 时无法按区间比较，检查全部 key
+	maglev := consistenthash.New(consistenthash.WithMaglev())
+	defer maglev.Close()
+	maglev.Add(self, "127.0.0.1:2", "127.0.0.1:3")
+	if movedKeys(prev, maglev, picker) != nil {
+		t.Error("使用 Maglev 时应返回 nil，检查全部 key")
+	}
+	if movedKeys(nil, cur, picker) != nil {
+		t.Error("没有上一次的哈希环时应返回 nil，检查全部 key")
+	}
+}
@@ -0,0 +1,64 @@
+package mycache
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrValueTooLarge 值超过节点间消息大小限制时返回的错误
+var ErrValueTooLarge = errors.New("cache: value too large")
+
+// WithMaxMsgSize 设置服务端接收和发送消息的大小上限（字节），0 表示保持默认值
+// 接收上限默认为 4MB，同时限制 REST 接口的请求体大小；超过发送上限的值仍可通过 GetStream 获取
+func WithMaxMsgSize(recv, send int) ServerOption {
+	return func(o *ServerOptions) {
+		if recv > 0 {
+			o.MaxMsgSize = recv
+		}
+		if send > 0 {
+			o.MaxSendMsgSize = send
+		}
+	}
+}
+
+// WithClientMaxMsgSize 设置客户端接收和发送消息的大小上限（字节），0 表示使用 gRPC 默认值
+// 接收上限的默认值为 4MB；Set 的值超过发送上限时直接返回 ErrValueTooLarge，不再发送请求
+func WithClientMaxMsgSize(recv, send int) ClientOption {
+	return func(o *clientOptions) {
+		o.maxRecvMsgSize = recv
+		o.maxSendMsgSize = send
+	}
+}
+
+// isMessageTooLarge 判断 RPC 错误是否由消息或值超过大小限制导致
+func isMessageTooLarge(err error) bool {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.ResourceExhausted {
+		return false
+	}
+	return strings.Contains(st.Message(), "larger than max") || statusReason(err) == "VALUE_TOO_LARGE"
+}
+
+// checkValueSize 值超过客户端发送上限时返回 ErrValueTooLarge
+func (c *Client) checkValueSize(size int) error {
+	if c.opts.maxSendMsgSize > 0 && size > c.opts.maxSendMsgSize {
+		return fmt.Errorf("%w: %d bytes exceeds limit of %d bytes", ErrValueTooLarge, size, c.opts.maxSendMsgSize)
+	}
+	return nil
+}
+
+// wrapSizeError 将消息超过大小限制的 RPC 错误转换为 ErrValueTooLarge，
+// 违反对端 KeyPolicy 的错误还原为 *PolicyViolationError
+func wrapSizeError(op string, err error) error {
+	if policyErr := policyErrorFromStatus(err); policyErr != nil {
+		return fmt.Errorf("failed to %s: %w", op, policyErr)
+	}
+	if isMessageTooLarge(err) {
+		return fmt.Errorf("failed to %s: %w: %v", op, ErrValueTooLarge, err)
+	}
+	return fmt.Errorf("failed to %s: %w", op, err)
+}
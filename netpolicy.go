@@ -0,0 +1,141 @@
+package mycache
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+)
+
+// WithAllowedNetworks 只接受来自指定网段的连接，如 "10.0.0.0/8"、"192.168.1.10"
+// 对 gRPC、REST、管理和 /metrics 端口同时生效，在认证之前的连接层面执行，可多次调用追加
+func WithAllowedNetworks(cidrs ...string) ServerOption {
+	return func(o *ServerOptions) {
+		o.AllowedNetworks = append(o.AllowedNetworks, cidrs...)
+	}
+}
+
+// WithDeniedNetworks 拒绝来自指定网段的连接，优先于 WithAllowedNetworks，可多次调用追加
+func WithDeniedNetworks(cidrs ...string) ServerOption {
+	return func(o *ServerOptions) {
+		o.DeniedNetworks = append(o.DeniedNetworks, cidrs...)
+	}
+}
+
+// netPolicy 按网段过滤连接
+type netPolicy struct {
+	allow    []netip.Prefix
+	deny     []netip.Prefix
+	rejected atomic.Int64 // 被拒绝的连接数
+}
+
+// newNetPolicy 解析网段，没有配置任何网段时返回 nil
+func newNetPolicy(allow, deny []string) (*netPolicy, error) {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, nil
+	}
+
+	p := &netPolicy{}
+	var err error
+	if p.allow, err = parsePrefixes(allow); err != nil {
+		return nil, err
+	}
+	if p.deny, err = parsePrefixes(deny); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// parsePrefixes 解析 CIDR，单个 IP 视为只包含该地址的网段
+func parsePrefixes(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if !strings.Contains(cidr, "/") {
+			addr, err := netip.ParseAddr(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("cache: invalid network %q: %v", cidr, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("cache: invalid network %q: %v", cidr, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// allowed 判断是否接受来自 addr 的连接，非 TCP 地址（如 Unix socket）总是接受
+func (p *netPolicy) allowed(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return true
+	}
+	ip, ok := netip.AddrFromSlice(tcpAddr.IP)
+	if !ok {
+		return false
+	}
+	ip = ip.Unmap()
+
+	for _, prefix := range p.deny {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	if len(p.allow) == 0 {
+		return true
+	}
+	for _, prefix := range p.allow {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// policyListener 关闭不符合网段策略的连接
+type policyListener struct {
+	net.Listener
+	policy *netPolicy
+}
+
+// Accept 返回下一个符合策略的连接，不符合的连接直接关闭
+func (l *policyListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.policy.allowed(conn.RemoteAddr()) {
+			return conn, nil
+		}
+		l.policy.rejected.Add(1)
+		log.Printf("[Server] WARN: rejected connection from %s to %s", conn.RemoteAddr(), l.Addr())
+		conn.Close()
+	}
+}
+
+// listen 监听 addr，配置了网段策略时过滤连接
+func (s *Server) listen(addr string) (net.Listener, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if s.netPolicy != nil {
+		lis = &policyListener{Listener: lis, policy: s.netPolicy}
+	}
+	return lis, nil
+}
+
+// RejectedConns 返回因不符合网段策略被拒绝的连接数
+func (s *Server) RejectedConns() int64 {
+	if s.netPolicy == nil {
+		return 0
+	}
+	return s.netPolicy.rejected.Load()
+}
@@ -0,0 +1,142 @@
+package mycache
+
+import (
+	"sort"
+	"sync"
+)
+
+// PeerInfo 已发现节点的信息
+type PeerInfo struct {
+	Addr    string // 节点地址
+	Healthy bool   // 是否健康，不健康的节点已从哈希环上摘除；未启用健康检查时总为 true
+}
+
+// peerChange 一次节点变化及变化发生时的订阅者
+type peerChange struct {
+	added     []string
+	removed   []string
+	listeners []func(added, removed []string)
+}
+
+// peerNotifier 按顺序向订阅者投递节点变化，回调在独立的 goroutine 中执行，不持有 picker 的锁
+type peerNotifier struct {
+	mu        sync.Mutex
+	listeners map[int]func(added, removed []string)
+	nextID    int
+	queue     []peerChange
+	signal    chan struct{}
+
+	// 以下字段由 ClientPicker.mu 保护，记录本次修改中尚未提交的变化
+	added   []string
+	removed []string
+}
+
+// Peers 返回当前已发现的节点（不含本节点），按地址排序
+func (p *ClientPicker) Peers() []PeerInfo {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	peers := make([]PeerInfo, 0, len(p.clients))
+	for addr := range p.clients {
+		info := PeerInfo{Addr: addr, Healthy: true}
+		if h, ok := p.health[addr]; ok {
+			info.Healthy = h.healthy
+		}
+		peers = append(peers, info)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].Addr < peers[j].Addr
+	})
+	return peers
+}
+
+// OnPeerChange 订阅节点加入和离开，返回取消订阅的函数
+//
+// 每次服务发现更新后以本次新增和移除的节点地址调用 fn，同一个 picker 的回调按变化顺序
+// 依次执行，fn 执行较慢时后续变化会排队等待。只包含成员变化，健康检查摘除和恢复节点不会触发；
+// 订阅前已存在的节点可通过 Peers 获取。
+func (p *ClientPicker) OnPeerChange(fn func(added, removed []string)) (cancel func()) {
+	n := &p.peerChanges
+	n.mu.Lock()
+	id := n.nextID
+	n.nextID++
+	n.listeners[id] = fn
+	n.mu.Unlock()
+
+	return func() {
+		n.mu.Lock()
+		delete(n.listeners, id)
+		n.mu.Unlock()
+	}
+}
+
+// recordPeerAdded 记录新增节点，调用者必须已持有写锁
+func (p *ClientPicker) recordPeerAdded(addr string) {
+	p.peerChanges.added = append(p.peerChanges.added, addr)
+}
+
+// recordPeerRemoved 记录移除节点，调用者必须已持有写锁
+func (p *ClientPicker) recordPeerRemoved(addr string) {
+	p.peerChanges.removed = append(p.peerChanges.removed, addr)
+}
+
+// flushPeerChanges 提交本次修改中记录的节点变化，调用者必须已持有写锁
+func (p *ClientPicker) flushPeerChanges() {
+	n := &p.peerChanges
+	if len(n.added) == 0 && len(n.removed) == 0 {
+		return
+	}
+	change := peerChange{added: n.added, removed: n.removed}
+	sort.Strings(change.added)
+	sort.Strings(change.removed)
+	n.added, n.removed = nil, nil
+
+	n.mu.Lock()
+	if len(n.listeners) == 0 {
+		n.mu.Unlock()
+		return
+	}
+	// 只投递给变化发生时已订阅的回调，之后订阅的回调应通过 Peers 获取当前节点
+	ids := make([]int, 0, len(n.listeners))
+	for id := range n.listeners {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		change.listeners = append(change.listeners, n.listeners[id])
+	}
+	n.queue = append(n.queue, change)
+	n.mu.Unlock()
+
+	select {
+	case n.signal <- struct{}{}:
+	default:
+	}
+}
+
+// dispatchPeerChanges 依次投递排队的节点变化，直到 picker 关闭
+func (p *ClientPicker) dispatchPeerChanges() {
+	n := &p.peerChanges
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-n.signal:
+		}
+
+		for {
+			n.mu.Lock()
+			if len(n.queue) == 0 {
+				n.mu.Unlock()
+				break
+			}
+			change := n.queue[0]
+			n.queue = n.queue[1:]
+			n.mu.Unlock()
+
+			for _, fn := range change.listeners {
+				fn(change.added, change.removed)
+			}
+		}
+	}
+}
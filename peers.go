@@ -4,16 +4,32 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/linhx1999/MyCache-Go/consistenthash"
 	"github.com/linhx1999/MyCache-Go/registry"
 	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 const defaultSvcName = "kama-cache"
 
+// peerMetadataKey 节点间 gRPC 请求携带的元数据键，用于标记请求来自其他缓存节点
+const peerMetadataKey = "x-mycache-from-peer"
+
+// isPeerRequest 判断请求是否来自其他缓存节点：进程内带有 from_peer 标记，或 gRPC 元数据中带有节点标记
+func isPeerRequest(ctx context.Context) bool {
+	if ctx.Value("from_peer") != nil {
+		return true
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	return ok && len(md.Get(peerMetadataKey)) > 0
+}
+
 // PeerPicker 定义了peer选择器的接口
 type PeerPicker interface {
 	PickPeer(key string) (peer Peer, ok bool, self bool)
@@ -21,23 +37,58 @@ type PeerPicker interface {
 }
 
 // Peer 定义了缓存节点的接口
+// 所有操作都接收调用方的 ctx，调用方取消或超时时请求随之结束
 type Peer interface {
-	Get(group string, key string) ([]byte, error)
+	Get(ctx context.Context, group string, key string) ([]byte, error)
 	Set(ctx context.Context, group string, key string, value []byte) error
-	Delete(group string, key string) (bool, error)
+	Delete(ctx context.Context, group string, key string) (bool, error)
+
+	// 批量操作，一次往返处理多个 key
+	// MGet 只返回对端成功获取的 key，获取失败的 key 不出现在结果中
+	MGet(ctx context.Context, group string, keys []string) (map[string][]byte, error)
+	MSet(ctx context.Context, group string, entries map[string][]byte) error
+	MDelete(ctx context.Context, group string, keys []string) error
+
+	// Transfer 将 entries 中的缓存项流式迁移到该节点，用于拓扑变化时的数据迁移
+	Transfer(ctx context.Context, group string, entries <-chan TransferEntry) (TransferResult, error)
 	Close() error
 }
 
 // ClientPicker 实现了PeerPicker接口
 type ClientPicker struct {
-	selfAddr string                   // 本节点地址，用于识别自身，避免将请求路由到自己
+	selfAddr string                   // 本节点地址，与其他节点一样加入哈希环，用于识别本节点负责的 key
 	svcName  string                   // 服务名称，用于etcd中区分不同的缓存服务
+	prefix   string                   // etcd 中服务实例 key 的前缀，默认为 registry.DefaultConfig.KeyPrefix
 	mu       sync.RWMutex             // 保护一致性哈希环和客户端映射的并发访问
 	consHash *consistenthash.HashRing // 一致性哈希环，用于根据key选择目标节点
+	ringOpts []consistenthash.Option  // 创建一致性哈希环的选项
+	cliOpts  []ClientOption           // 创建节点客户端的选项
 	clients  map[string]*Client       // 地址到gRPC客户端的映射，存储与其他节点的连接
-	etcdCli  *clientv3.Client         // etcd客户端，用于服务发现和监听节点变化
+	etcdCli  *clientv3.Client         // etcd客户端，用于服务发现和监听节点变化；静态节点模式下为 nil
 	ctx      context.Context          // 上下文，用于控制服务发现goroutine的生命周期
 	cancel   context.CancelFunc       // 取消函数，用于优雅关闭服务发现
+
+	etcdConfig    *clientv3.Config // 连接 etcd 的完整配置，设置后不使用 registry.DefaultConfig
+	etcdEndpoints []string         // 覆盖 registry.DefaultConfig 中的 etcd 地址
+	sharedEtcd    bool             // etcdCli 由调用方提供，Close 时不关闭
+
+	dnsInterval time.Duration // DNS 服务发现的解析间隔
+
+	zone       string            // 本节点所在的机房，为空表示不区分机房
+	zonePolicy ZonePolicy        // 多机房部署时选择副本的策略
+	peerZones  map[string]string // WithPeerZones 指定的节点机房
+
+	loads     map[string]registry.Load // 节点发布的负载
+	maxQPS    float64                  // QPS 超过该值的节点视为过载，0 表示不按 QPS 判断
+	maxMemory float64                  // 内存使用率超过该值的节点视为过载，0 表示不按内存判断
+
+	healthInterval  time.Duration          // 健康检查间隔，0 表示不启用
+	healthTimeout   time.Duration          // 单次健康检查的超时时间
+	healthThreshold int                    // 连续失败多少次后摘除节点
+	health          map[string]*peerHealth // 节点的健康检查状态
+
+	changeHooks []func()     // 哈希环变化时调用的回调
+	peerChanges peerNotifier // 节点加入和离开的订阅者
 }
 
 // PickerOption 定义配置选项
@@ -50,7 +101,73 @@ func WithServiceName(name string) PickerOption {
 	}
 }
 
-// PrintPeers 打印当前已发现的节点（仅用于调试）
+// WithRegistryPrefix 设置在 etcd 中发现节点使用的 key 前缀，如 "/prod/services"
+// 需与节点注册时使用的前缀（registry.DefaultConfig.KeyPrefix 或 registry.WithKeyPrefix）一致
+func WithRegistryPrefix(prefix string) PickerOption {
+	return func(p *ClientPicker) {
+		p.prefix = prefix
+	}
+}
+
+// WithPickerEtcdConfig 使用指定的配置连接 etcd，而不是 registry.DefaultConfig
+// 用于发现与本进程其他组件不同的 etcd 集群，TLS 和认证需在 config 中一并设置
+func WithPickerEtcdConfig(config clientv3.Config) PickerOption {
+	return func(p *ClientPicker) {
+		p.etcdConfig = &config
+	}
+}
+
+// WithPickerEtcdEndpoints 设置 etcd 地址，TLS、认证和超时等其他配置仍取自 registry.DefaultConfig
+func WithPickerEtcdEndpoints(endpoints ...string) PickerOption {
+	return func(p *ClientPicker) {
+		p.etcdEndpoints = endpoints
+	}
+}
+
+// WithEtcdClient 使用已有的 etcd 客户端发现节点，避免与服务注册等组件重复建立连接
+// cli 由调用方负责关闭，ClientPicker.Close 不会关闭它；设置后忽略 WithPickerEtcdConfig 和 WithPickerEtcdEndpoints
+func WithEtcdClient(cli *clientv3.Client) PickerOption {
+	return func(p *ClientPicker) {
+		p.etcdCli = cli
+		p.sharedEtcd = cli != nil
+	}
+}
+
+// WithHashTags 启用哈希标签，key 中 {} 内的部分相同的 key 会被路由到同一个节点
+// 例如 "{user:123}:profile" 和 "{user:123}:settings"，便于相关 key 在一个节点上批量获取
+func WithHashTags() PickerOption {
+	return func(p *ClientPicker) {
+		p.ringOpts = append(p.ringOpts, consistenthash.WithHashTags())
+	}
+}
+
+// WithMaglev 使用 Maglev 查找表选择 key 的 owner，PickPeer 为 O(1)，不再在哈希环上二分查找
+// 集群中所有节点需要一致地启用，否则各节点对 key 归属的计算结果不同
+func WithMaglev() PickerOption {
+	return func(p *ClientPicker) {
+		p.ringOpts = append(p.ringOpts, consistenthash.WithMaglev())
+	}
+}
+
+// WithClientOptions 设置创建节点客户端时使用的选项，如连接池大小和重连退避
+func WithClientOptions(opts ...ClientOption) PickerOption {
+	return func(p *ClientPicker) {
+		p.cliOpts = append(p.cliOpts, opts...)
+	}
+}
+
+// WithPeerCompression 设置访问其他节点时使用的压缩算法，如 CompressionGzip
+func WithPeerCompression(name string) PickerOption {
+	return WithClientOptions(WithCompression(name))
+}
+
+// WithRetryPolicy 设置访问其他节点时的重试策略
+// 节点重启期间的 UNAVAILABLE 错误会被重试，而不是直接表现为缓存未命中
+func WithRetryPolicy(policy RetryPolicy) PickerOption {
+	return WithClientOptions(WithClientRetry(policy))
+}
+
+// PrintPeers 打印当前已发现的节点（仅用于调试），程序中请使用 Peers 和 OnPeerChange
 func (p *ClientPicker) PrintPeers() {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
@@ -61,87 +178,245 @@ func (p *ClientPicker) PrintPeers() {
 	}
 }
 
-// NewClientPicker 创建新的ClientPicker实例
-func NewClientPicker(addr string, opts ...PickerOption) (*ClientPicker, error) {
+// newPicker 创建 ClientPicker 并应用选项，节点发现由调用方负责启动
+func newPicker(self string, opts []PickerOption) *ClientPicker {
 	ctx, cancel := context.WithCancel(context.Background())
 	picker := &ClientPicker{
-		selfAddr: addr,
-		svcName:  defaultSvcName,
-		clients:  make(map[string]*Client),
-		consHash: consistenthash.New(),
-		ctx:      ctx,
-		cancel:   cancel,
+		selfAddr:    self,
+		svcName:     defaultSvcName,
+		prefix:      registry.DefaultConfig.Prefix(),
+		clients:     make(map[string]*Client),
+		health:      make(map[string]*peerHealth),
+		loads:       make(map[string]registry.Load),
+		ctx:         ctx,
+		cancel:      cancel,
+		dnsInterval: defaultDNSInterval,
+		peerChanges: peerNotifier{
+			listeners: make(map[int]func(added, removed []string)),
+			signal:    make(chan struct{}, 1),
+		},
 	}
 
 	for _, opt := range opts {
 		opt(picker)
 	}
+	picker.consHash = consistenthash.New(picker.ringOpts...)
+	// 本节点也加入哈希环，使各节点对 key 归属的计算结果一致
+	if self != "" {
+		picker.consHash.Add(self)
+	}
+	picker.applyZones()
+	go picker.dispatchPeerChanges()
 
-	cli, err := clientv3.New(clientv3.Config{
-		Endpoints:   registry.DefaultConfig.Endpoints,
-		DialTimeout: registry.DefaultConfig.DialTimeout,
-	})
-	if err != nil {
-		cancel()
-		return nil, fmt.Errorf("failed to create etcd client: %v", err)
+	if picker.healthInterval > 0 {
+		picker.startHealthCheck()
+	}
+	return picker
+}
+
+// NewClientPicker 创建新的ClientPicker实例，通过 etcd 发现节点
+// 默认使用 registry.DefaultConfig 中的地址、TLS 和认证配置连接 etcd，
+// 可通过 WithPickerEtcdConfig、WithPickerEtcdEndpoints 或 WithEtcdClient 指定。
+// addr 为本节点地址，需与注册到 etcd 的地址一致，否则各节点计算出的 key 归属不同
+func NewClientPicker(addr string, opts ...PickerOption) (*ClientPicker, error) {
+	picker := newPicker(addr, opts)
+
+	if picker.etcdCli == nil {
+		cli, err := picker.newEtcdClient()
+		if err != nil {
+			picker.cancel()
+			return nil, fmt.Errorf("failed to create etcd client: %v", err)
+		}
+		picker.etcdCli = cli
 	}
-	picker.etcdCli = cli
 
 	// 启动服务发现
 	if err := picker.startServiceDiscovery(); err != nil {
-		cancel()
-		cli.Close()
+		picker.cancel()
+		if !picker.sharedEtcd {
+			picker.etcdCli.Close()
+		}
 		return nil, err
 	}
 
 	return picker, nil
 }
 
+// newEtcdClient 按选项创建 etcd 客户端
+func (p *ClientPicker) newEtcdClient() (*clientv3.Client, error) {
+	if p.etcdConfig != nil {
+		return clientv3.New(*p.etcdConfig)
+	}
+	config := *registry.DefaultConfig
+	if len(p.etcdEndpoints) > 0 {
+		config.Endpoints = p.etcdEndpoints
+	}
+	return config.NewClient()
+}
+
+// NewStaticPicker 使用固定的节点列表创建 ClientPicker，不依赖 etcd
+//
+// 适用于没有 etcd 的环境，如单机房部署、docker-compose 和测试。peers 可以包含本节点地址，
+// 会被自动跳过。节点列表之后可通过 SetPeers 整体替换。
+func NewStaticPicker(self string, peers []string, opts ...PickerOption) (*ClientPicker, error) {
+	picker := newPicker(self, opts)
+
+	picker.SetPeers(peers...)
+	return picker, nil
+}
+
+// NewDiscoveryPicker 使用指定的服务发现后端（如 registry.NewConsul）创建 ClientPicker
+//
+// 后台持续通过 d.Watch 监听节点变化并更新哈希环。d 由调用方负责关闭，
+// 可以与通过 WithDiscovery 注册本节点的 Server 共用同一个实例。
+func NewDiscoveryPicker(self string, d registry.Discovery, opts ...PickerOption) (*ClientPicker, error) {
+	if d == nil {
+		return nil, fmt.Errorf("cache: discovery is required")
+	}
+
+	picker := newPicker(self, opts)
+
+	go func() {
+		err := d.Watch(picker.ctx, picker.svcName, func(addrs []string) {
+			picker.SetPeers(addrs...)
+		})
+		if err != nil && picker.ctx.Err() == nil {
+			log.Printf("[PeerPicker] ERROR: discovery watch stopped: %v", err)
+		}
+	}()
+
+	return picker, nil
+}
+
+// SetPeers 用给定的节点列表替换当前节点，新增的节点建立连接，不再存在的节点关闭连接
+// 用于静态节点模式、DNS 及其他服务发现后端；使用 etcd 服务发现时节点变化会被下一次 watch 事件覆盖
+func (p *ClientPicker) SetPeers(addrs ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	want := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		if addr == "" || addr == p.selfAddr {
+			continue
+		}
+		want[addr] = true
+		if _, exists := p.clients[addr]; !exists {
+			p.set(addr)
+		}
+	}
+
+	for addr, client := range p.clients {
+		if !want[addr] {
+			client.Close()
+			p.remove(addr)
+			log.Printf("[PeerPicker] Service removed at %s", addr)
+		}
+	}
+	p.flushPeerChanges()
+}
+
 // startServiceDiscovery 启动服务发现
 func (p *ClientPicker) startServiceDiscovery() error {
 	// 先进行全量更新
-	if err := p.fetchAllServices(); err != nil {
+	rev, err := p.fetchAllServices()
+	if err != nil {
 		return err
 	}
 
 	// 启动增量更新
-	go p.watchServiceChanges()
+	go p.watchServiceChanges(rev)
 	return nil
 }
 
-// watchServiceChanges 监听服务实例变化
-func (p *ClientPicker) watchServiceChanges() {
-	watcher := clientv3.NewWatcher(p.etcdCli)
-	watchChan := watcher.Watch(p.ctx, "/services/"+p.svcName, clientv3.WithPrefix())
+const (
+	// watchRetryBackoff 监听中断后重新列出节点的初始等待时间
+	watchRetryBackoff = 500 * time.Millisecond
+	// watchRetryMaxBackoff 重新列出节点的最大等待时间
+	watchRetryMaxBackoff = 30 * time.Second
+)
 
+// watchServiceChanges 从 rev 之后监听服务实例变化
+//
+// 监听通道关闭、被取消或请求的版本已被压缩（etcd 重启、leader 切换、长时间断连）时，
+// 按退避时间重新列出全部实例并从新的版本继续监听，避免丢失中断期间的节点变化。
+func (p *ClientPicker) watchServiceChanges(rev int64) {
+	backoff := watchRetryBackoff
 	for {
-		select {
-		case <-p.ctx.Done():
-			watcher.Close()
+		received, err := p.watchFrom(rev)
+		if p.ctx.Err() != nil {
 			return
-		case resp := <-watchChan:
-			p.handleWatchEvents(resp.Events)
 		}
+		if received {
+			// 监听正常工作过，从最小退避重新开始
+			backoff = watchRetryBackoff
+		}
+		log.Printf("[PeerPicker] WARN: watch interrupted: %v, resyncing", err)
+
+		for {
+			select {
+			case <-p.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > watchRetryMaxBackoff {
+				backoff = watchRetryMaxBackoff
+			}
+
+			var err error
+			if rev, err = p.fetchAllServices(); err == nil {
+				break
+			}
+			log.Printf("[PeerPicker] WARN: resync failed: %v, retrying in %v", err, backoff)
+		}
+		log.Printf("[PeerPicker] Resynced services at revision %d", rev)
 	}
 }
 
+// watchFrom 从 rev 之后监听服务实例变化，直到监听中断，返回是否收到过事件和中断的原因
+func (p *ClientPicker) watchFrom(rev int64) (received bool, err error) {
+	ctx, cancel := context.WithCancel(clientv3.WithRequireLeader(p.ctx))
+	defer cancel()
+
+	watchChan := p.etcdCli.Watch(ctx, registry.ServicePrefix(p.prefix, p.svcName),
+		clientv3.WithPrefix(), clientv3.WithRev(rev+1))
+	for resp := range watchChan {
+		if resp.CompactRevision != 0 {
+			return received, fmt.Errorf("revision %d compacted", resp.CompactRevision)
+		}
+		if err := resp.Err(); err != nil {
+			return received, err
+		}
+		if resp.Canceled {
+			return received, fmt.Errorf("watch canceled")
+		}
+		received = true
+		p.handleWatchEvents(resp.Events)
+	}
+	return received, fmt.Errorf("watch channel closed")
+}
+
 // handleWatchEvents 处理监听到的事件
 func (p *ClientPicker) handleWatchEvents(events []*clientv3.Event) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	for _, event := range events {
-		addr := string(event.Kv.Value)
-		if addr == p.selfAddr {
+		// 删除事件不包含值，地址从 key 中解析
+		addr := p.addrFromKey(string(event.Kv.Key))
+		if addr == "" || addr == p.selfAddr {
 			continue
 		}
 
 		switch event.Type {
 		case clientv3.EventTypePut:
+			inst := registry.ParseInstance(addr, event.Kv.Value)
+			p.consHash.SetZone(addr, inst.Zone)
+			p.setLoad(addr, inst.Load)
 			if _, exists := p.clients[addr]; !exists {
 				p.set(addr)
 				log.Printf("[PeerPicker] New service discovered at %s", addr)
 			}
+			p.setProtocol(inst)
 		case clientv3.EventTypeDelete:
 			if client, exists := p.clients[addr]; exists {
 				client.Close()
@@ -150,36 +425,49 @@ func (p *ClientPicker) handleWatchEvents(events []*clientv3.Event) {
 			}
 		}
 	}
+	p.flushPeerChanges()
 }
 
-// fetchAllServices 获取所有服务实例
-func (p *ClientPicker) fetchAllServices() error {
+// fetchAllServices 获取所有服务实例并替换当前节点，返回列出时的版本，之后从该版本继续监听
+func (p *ClientPicker) fetchAllServices() (int64, error) {
 	ctx, cancel := context.WithTimeout(p.ctx, 3*time.Second)
 	defer cancel()
 
-	resp, err := p.etcdCli.Get(ctx, "/services/"+p.svcName, clientv3.WithPrefix())
+	resp, err := p.etcdCli.Get(ctx, registry.ServicePrefix(p.prefix, p.svcName), clientv3.WithPrefix())
 	if err != nil {
-		return fmt.Errorf("failed to get all services: %v", err)
+		return 0, fmt.Errorf("failed to get all services: %v", err)
 	}
 
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
+	addrs := make([]string, 0, len(resp.Kvs))
+	instances := make([]registry.Instance, 0, len(resp.Kvs))
 	for _, kv := range resp.Kvs {
-		addr := string(kv.Value)
-		if addr != "" && addr != p.selfAddr {
-			p.set(addr)
-			log.Printf("[PeerPicker] Discovered service at %s", addr)
+		addr := p.addrFromKey(string(kv.Key))
+		if addr == "" || addr == p.selfAddr {
+			continue
 		}
+		inst := registry.ParseInstance(addr, kv.Value)
+		p.consHash.SetZone(addr, inst.Zone)
+		addrs = append(addrs, addr)
+		instances = append(instances, inst)
 	}
-	return nil
+	p.SetPeers(addrs...)
+
+	p.mu.Lock()
+	for _, inst := range instances {
+		p.setLoad(inst.Addr, inst.Load)
+		p.setProtocol(inst)
+	}
+	p.mu.Unlock()
+	return resp.Header.Revision, nil
 }
 
 // set 添加服务实例
 func (p *ClientPicker) set(addr string) {
-	if client, err := NewClient(addr, p.svcName, p.etcdCli); err == nil {
+	if client, err := newClient(addr, p.svcName, p.etcdCli, newClientOptions(p.cliOpts...)); err == nil {
 		p.consHash.Add(addr)
 		p.clients[addr] = client
+		p.recordPeerAdded(addr)
+		p.notifyTopologyChange()
 		log.Printf("[PeerPicker] Successfully created client for %s", addr)
 	} else {
 		log.Printf("[PeerPicker] ERROR: Failed to create client for %s: %v", addr, err)
@@ -190,24 +478,81 @@ func (p *ClientPicker) set(addr string) {
 func (p *ClientPicker) remove(addr string) {
 	p.consHash.Remove(addr)
 	delete(p.clients, addr)
+	delete(p.health, addr)
+	delete(p.loads, addr)
+	p.recordPeerRemoved(addr)
+	p.notifyTopologyChange()
 }
 
-// PickPeer 选择peer节点
+// onTopologyChange 注册哈希环变化时的回调
+func (p *ClientPicker) onTopologyChange(fn func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.changeHooks = append(p.changeHooks, fn)
+}
+
+// notifyTopologyChange 通知哈希环已变化，调用者必须已持有写锁
+func (p *ClientPicker) notifyTopologyChange() {
+	for _, fn := range p.changeHooks {
+		fn()
+	}
+}
+
+// PickPeer 选择 key 的 owner 节点
+// owner 为本节点时返回 (nil, true, true)，调用方应在本地处理
 func (p *ClientPicker) PickPeer(key string) (Peer, bool, bool) {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	if addr := p.consHash.Get(key); addr != "" {
+	addr := p.consHash.Get(key)
+	if addr == "" {
+		return nil, false, false
+	}
+	if addr == p.selfAddr {
+		return nil, true, true
+	}
+	if client, ok := p.clients[addr]; ok {
+		return client, true, false
+	}
+	return nil, false, false
+}
+
+// isPeerUnavailable 判断从节点获取失败是否由于节点不可达
+// 非 gRPC 错误无法区分原因，按不可达处理
+func isPeerUnavailable(err error) bool {
+	st, ok := status.FromError(err)
+	if !ok {
+		return true
+	}
+	switch st.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled:
+		return true
+	default:
+		return false
+	}
+}
+
+// PickPeers 按环上顺序返回 key 的 n 个副本节点，本节点不包含在内
+func (p *ClientPicker) PickPeers(key string, n int) []Peer {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var peers []Peer
+	for _, addr := range p.consHash.GetN(key, n) {
+		if addr == p.selfAddr {
+			continue
+		}
 		if client, ok := p.clients[addr]; ok {
-			return client, true, addr == p.selfAddr
+			peers = append(peers, client)
 		}
 	}
-	return nil, false, false
+	return peers
 }
 
 // Close 关闭所有资源
 func (p *ClientPicker) Close() error {
 	p.cancel()
+	p.consHash.Close()
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -218,8 +563,11 @@ func (p *ClientPicker) Close() error {
 		}
 	}
 
-	if err := p.etcdCli.Close(); err != nil {
-		errs = append(errs, fmt.Errorf("failed to close etcd client: %v", err))
+	// 静态节点模式下没有 etcd 客户端，调用方提供的客户端由调用方关闭
+	if p.etcdCli != nil && !p.sharedEtcd {
+		if err := p.etcdCli.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close etcd client: %v", err))
+		}
 	}
 
 	if len(errs) > 0 {
@@ -228,11 +576,11 @@ func (p *ClientPicker) Close() error {
 	return nil
 }
 
-// parseAddrFromKey 从etcd key中解析地址
-// func parseAddrFromKey(key, svcName string) string {
-// 	prefix := fmt.Sprintf("/services/%s/", svcName)
-// 	if strings.HasPrefix(key, prefix) {
-// 		return strings.TrimPrefix(key, prefix)
-// 	}
-// 	return ""
-// }
+// addrFromKey 从etcd key中解析地址，不是本服务的 key 时返回空字符串
+func (p *ClientPicker) addrFromKey(key string) string {
+	prefix := registry.ServicePrefix(p.prefix, p.svcName)
+	if strings.HasPrefix(key, prefix) {
+		return strings.TrimPrefix(key, prefix)
+	}
+	return ""
+}
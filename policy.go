@@ -0,0 +1,220 @@
+package mycache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	pb "github.com/linhx1999/MyCache-Go/pb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrKeyTooLong key 超过 KeyPolicy.MaxKeyLength
+	ErrKeyTooLong = errors.New("cache: key too long")
+	// ErrInvalidKey key 中包含 KeyPolicy.KeyCharset 不允许的字符
+	ErrInvalidKey = errors.New("cache: key contains invalid character")
+)
+
+// KeyPolicy 限制 key 的长度、value 的大小和 key 允许使用的字符，零值表示不限制
+// 违反策略的请求返回 *PolicyViolationError，对应 gRPC InvalidArgument 状态
+type KeyPolicy struct {
+	MaxKeyLength int             // key 的最大字节数
+	MaxValueSize int             // value 的最大字节数
+	KeyCharset   func(rune) bool // 返回 false 的字符不能出现在 key 中，nil 表示不限制
+}
+
+// PrintableASCII 只允许可打印的 ASCII 字符（不含空格），可用作 KeyPolicy.KeyCharset
+func PrintableASCII(r rune) bool {
+	return r > ' ' && r <= '~'
+}
+
+// PolicyViolationError key 或 value 违反 KeyPolicy 时返回
+// 可通过 errors.Is 与 ErrKeyTooLong、ErrValueTooLarge 或 ErrInvalidKey 比较，
+// 客户端从 RPC 错误中还原出同样的类型。
+type PolicyViolationError struct {
+	Field string // 违反策略的字段，"key" 或 "value"
+	Size  int    // key 或 value 的字节数
+	Limit int    // 大小上限，字符不合法时为 0
+	Char  rune   // 不允许的字符，仅在 ErrInvalidKey 时有效
+	Err   error  // ErrKeyTooLong、ErrValueTooLarge 或 ErrInvalidKey
+}
+
+func (e *PolicyViolationError) Error() string {
+	if e.Err == ErrInvalidKey {
+		return fmt.Sprintf("%v: %q", e.Err, e.Char)
+	}
+	return fmt.Sprintf("%v: %d bytes exceeds limit of %d bytes", e.Err, e.Size, e.Limit)
+}
+
+func (e *PolicyViolationError) Unwrap() error {
+	return e.Err
+}
+
+// policyReasons 违反策略的错误在 ErrorInfo 中的原因
+var policyReasons = map[error]string{
+	ErrKeyTooLong:    "KEY_TOO_LONG",
+	ErrValueTooLarge: "VALUE_TOO_LARGE",
+	ErrInvalidKey:    "INVALID_KEY",
+}
+
+// WithKeyPolicy 限制组内 key 的长度、value 的大小和 key 允许的字符
+// 读写时校验，Delete 不校验，以便删除设置策略之前写入的 key
+func WithKeyPolicy(p KeyPolicy) GroupOption {
+	return func(g *Group) {
+		g.policy = p
+	}
+}
+
+// WithServerKeyPolicy 对节点上所有组的 gRPC 和 REST 请求执行 KeyPolicy，在各组自己的策略之前校验
+// 在认证之后、租户命名空间之前执行，key 长度不包含租户前缀
+func WithServerKeyPolicy(p KeyPolicy) ServerOption {
+	return func(o *ServerOptions) {
+		o.KeyPolicy = &p
+	}
+}
+
+// checkKey 校验 key 的长度和字符，p 为 nil 时只拒绝没有租户的普通客户端使用租户命名空间前缀（见 checkReservedKey）；
+// 租户的 key 只校验命名空间之后的部分
+func (p *KeyPolicy) checkKey(ctx context.Context, key string) error {
+	if err := checkReservedKey(ctx, key); err != nil {
+		return err
+	}
+	if p == nil {
+		return nil
+	}
+	_, key = splitTenantKey(key)
+	if p.MaxKeyLength > 0 && len(key) > p.MaxKeyLength {
+		return &PolicyViolationError{Field: "key", Size: len(key), Limit: p.MaxKeyLength, Err: ErrKeyTooLong}
+	}
+	if p.KeyCharset != nil {
+		for _, r := range key {
+			if !p.KeyCharset(r) {
+				return &PolicyViolationError{Field: "key", Size: len(key), Char: r, Err: ErrInvalidKey}
+			}
+		}
+	}
+	return nil
+}
+
+// checkValue 校验 value 的大小，p 为 nil 时不校验
+func (p *KeyPolicy) checkValue(value []byte) error {
+	if p != nil && p.MaxValueSize > 0 && len(value) > p.MaxValueSize {
+		return &PolicyViolationError{Field: "value", Size: len(value), Limit: p.MaxValueSize, Err: ErrValueTooLarge}
+	}
+	return nil
+}
+
+// checkEntry 校验 key 和 value
+func (p *KeyPolicy) checkEntry(ctx context.Context, key string, value []byte) error {
+	if err := p.checkKey(ctx, key); err != nil {
+		return err
+	}
+	return p.checkValue(value)
+}
+
+// checkRequest 校验请求中的 key 和 value，Delete 和 MDelete 只执行 checkReservedKey
+func (p *KeyPolicy) checkRequest(ctx context.Context, method string, req interface{}) error {
+	if strings.HasSuffix(method, "/Delete") || strings.HasSuffix(method, "/MDelete") {
+		return checkReservedRequest(ctx, req)
+	}
+	switch r := req.(type) {
+	case *pb.Request:
+		return p.checkEntry(ctx, r.Key, r.Value)
+	case *pb.BatchRequest:
+		for _, key := range r.Keys {
+			if err := p.checkKey(ctx, key); err != nil {
+				return err
+			}
+		}
+		for _, entry := range r.Entries {
+			if err := p.checkEntry(ctx, entry.Key, entry.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unaryInterceptor 拒绝违反策略的一元请求
+func (p *KeyPolicy) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := p.checkRequest(ctx, info.FullMethod, req); err != nil {
+		return nil, toStatusError(err)
+	}
+	return handler(ctx, req)
+}
+
+// streamInterceptor 拒绝违反策略的流式请求（GetStream）
+func (p *KeyPolicy) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &policyServerStream{ServerStream: ss, policy: p, method: info.FullMethod})
+}
+
+// policyServerStream 校验流中收到的请求
+type policyServerStream struct {
+	grpc.ServerStream
+	policy *KeyPolicy
+	method string
+}
+
+func (s *policyServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return toStatusError(s.policy.checkRequest(s.Context(), s.method, m))
+}
+
+// policyStatus 返回 InvalidArgument 状态，详情中包含出错的字段和机器可读的原因
+func policyStatus(e *PolicyViolationError) error {
+	metadata := map[string]string{"size": strconv.Itoa(e.Size)}
+	if e.Limit > 0 {
+		metadata["limit"] = strconv.Itoa(e.Limit)
+	}
+	if e.Err == ErrInvalidKey {
+		metadata["char"] = string(e.Char)
+	}
+	st, err := status.New(codes.InvalidArgument, e.Error()).WithDetails(
+		&errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: e.Field, Description: e.Error()}},
+		},
+		&errdetails.ErrorInfo{Reason: policyReasons[e.Err], Domain: errorDomain, Metadata: metadata},
+	)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, e.Error())
+	}
+	return st.Err()
+}
+
+// policyErrorFromStatus 从 RPC 错误中还原 *PolicyViolationError，不是违反策略的错误时返回 nil
+func policyErrorFromStatus(err error) *PolicyViolationError {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.InvalidArgument {
+		return nil
+	}
+	for _, detail := range st.Details() {
+		info, ok := detail.(*errdetails.ErrorInfo)
+		if !ok || info.GetDomain() != errorDomain {
+			continue
+		}
+		for sentinel, reason := range policyReasons {
+			if info.GetReason() != reason {
+				continue
+			}
+			e := &PolicyViolationError{Field: "key", Err: sentinel}
+			if sentinel == ErrValueTooLarge {
+				e.Field = "value"
+			}
+			e.Size, _ = strconv.Atoi(info.GetMetadata()["size"])
+			e.Limit, _ = strconv.Atoi(info.GetMetadata()["limit"])
+			if char := []rune(info.GetMetadata()["char"]); len(char) > 0 {
+				e.Char = char[0]
+			}
+			return e
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package mycache
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	priorityMetadataKey = "x-mycache-priority"    // 请求的优先级
+	deadlineMetadataKey = "x-mycache-deadline-ms" // 请求剩余的截止时间（毫秒）
+)
+
+// errRequestShed 过载时被丢弃的低优先级请求返回的错误信息
+const errRequestShed = "cache: request shed due to overload"
+
+// Priority 节点间请求的优先级，过载的节点优先丢弃低优先级的请求
+type Priority int
+
+const (
+	PriorityInteractive Priority = iota // 交互式请求，如用户触发的 Get（默认）
+	PriorityBackground                  // 后台请求，如迁移、预热、读修复和提示重放
+)
+
+// String 返回优先级的名称
+func (p Priority) String() string {
+	switch p {
+	case PriorityInteractive:
+		return "interactive"
+	case PriorityBackground:
+		return "background"
+	default:
+		return "unknown"
+	}
+}
+
+// priorityKey 优先级在 context 中的键
+type priorityKey struct{}
+
+// WithPriority 返回携带请求优先级的 ctx，经由该 ctx 发出的节点间请求会带上优先级
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFromContext 返回 ctx 中的请求优先级，未设置时为 PriorityInteractive
+func PriorityFromContext(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return p
+	}
+	return PriorityInteractive
+}
+
+// withRequestMetadata 将优先级和剩余截止时间写入请求元数据
+// 截止时间单独传递，经过会丢弃 grpc-timeout 的代理时对端仍能得知剩余时间
+func withRequestMetadata(ctx context.Context) context.Context {
+	pairs := make([]string, 0, 4)
+	if p := PriorityFromContext(ctx); p != PriorityInteractive {
+		pairs = append(pairs, priorityMetadataKey, strconv.Itoa(int(p)))
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline).Milliseconds()
+		if remaining < 0 {
+			remaining = 0
+		}
+		pairs = append(pairs, deadlineMetadataKey, strconv.FormatInt(remaining, 10))
+	}
+	if len(pairs) == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, pairs...)
+}
+
+// priorityUnaryClientInterceptor 为一元请求附加优先级和截止时间
+func priorityUnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(withRequestMetadata(ctx), method, req, reply, cc, opts...)
+}
+
+// priorityStreamClientInterceptor 为流式请求附加优先级和截止时间
+func priorityStreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(withRequestMetadata(ctx), desc, cc, method, opts...)
+}
+
+// requestMetadata 从请求元数据中解析优先级和剩余截止时间
+func requestMetadata(ctx context.Context) (Priority, time.Duration, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return PriorityInteractive, 0, false
+	}
+
+	priority := PriorityInteractive
+	if v := md.Get(priorityMetadataKey); len(v) > 0 {
+		if n, err := strconv.Atoi(v[0]); err == nil {
+			priority = Priority(n)
+		}
+	}
+	if v := md.Get(deadlineMetadataKey); len(v) > 0 {
+		if ms, err := strconv.ParseInt(v[0], 10, 64); err == nil {
+			return priority, time.Duration(ms) * time.Millisecond, true
+		}
+	}
+	return priority, 0, false
+}
+
+// WithLoadShedding 设置过载保护
+// 正在处理的请求数达到 maxInFlight 时，直接拒绝后台优先级的请求（迁移、预热、读修复等），
+// 优先保证交互式请求；0 表示不限制
+func WithLoadShedding(maxInFlight int) ServerOption {
+	return func(o *ServerOptions) {
+		o.ShedThreshold = maxInFlight
+	}
+}
+
+// loadShedder 统计正在处理的请求数，过载时丢弃低优先级请求
+type loadShedder struct {
+	threshold int64
+	inFlight  atomic.Int64
+	shed      atomic.Int64
+	total     atomic.Int64 // 已接受的请求总数，用于计算发布的 QPS
+}
+
+// admit 开始处理一个请求，返回结束处理时调用的函数；请求被丢弃时返回错误
+func (l *loadShedder) admit(ctx context.Context) (context.Context, func(), error) {
+	priority, remaining, hasDeadline := requestMetadata(ctx)
+	ctx = WithPriority(ctx, priority)
+
+	if l.threshold > 0 && priority != PriorityInteractive && l.inFlight.Load() >= l.threshold {
+		l.shed.Add(1)
+		return ctx, nil, status.Error(codes.ResourceExhausted, errRequestShed)
+	}
+
+	// gRPC 截止时间被代理丢弃时，使用元数据中的剩余时间
+	cancel := func() {}
+	if _, ok := ctx.Deadline(); !ok && hasDeadline {
+		ctx, cancel = context.WithTimeout(ctx, remaining)
+	}
+
+	l.inFlight.Add(1)
+	l.total.Add(1)
+	return ctx, func() {
+		l.inFlight.Add(-1)
+		cancel()
+	}, nil
+}
+
+// unaryInterceptor 对一元请求应用过载保护
+func (l *loadShedder) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, done, err := l.admit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	return handler(ctx, req)
+}
+
+// streamInterceptor 对流式请求应用过载保护
+func (l *loadShedder) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, done, err := l.admit(ss.Context())
+	if err != nil {
+		return err
+	}
+	defer done()
+	return handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+}
+
+// contextServerStream 替换 ServerStream 的 context
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// isRequestShed 判断错误是否由对端过载丢弃请求导致
+func isRequestShed(err error) bool {
+	st, ok := status.FromError(err)
+	return ok && st.Code() == codes.ResourceExhausted && st.Message() == errRequestShed
+}
+
+// ShedRequests 返回因过载被丢弃的请求数
+func (s *Server) ShedRequests() int64 {
+	return s.shedder.shed.Load()
+}
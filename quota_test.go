@@ -0,0 +1,46 @@
+package mycache
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestQuota_OverwriteAtLimit(t *testing.T) {
+	g := NewGroup("quota-overwrite", 1<<20, DataSourceFunc(func(ctx context.Context, key string) ([]byte, error) {
+		return nil, errors.New("not found")
+	}), WithMemoryQuota(64))
+	defer g.Close()
+	ctx := context.Background()
+
+	// 每项占 len(key)+len(value) = 16 字节，4 项写满配额
+	for _, key := range []string{"k1", "k2", "k3", "k4"} {
+		if err := g.Set(ctx, key, []byte("value-14-bytes")); err != nil {
+			t.Fatalf("配额内的写入失败: %v", err)
+		}
+	}
+	if used := g.localCache.UsedBytes(); used != 64 {
+		t.Fatalf("已使用的字节数应为 64，实际为 %d", used)
+	}
+	if err := g.Set(ctx, "k5", []byte("value-14-bytes")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("新增 key 应超出配额，实际为 %v", err)
+	}
+
+	// 覆盖已有的 key 只计入新旧值的差额
+	if err := g.Set(ctx, "k1", []byte("other-14-bytes")); err != nil {
+		t.Errorf("相同大小的覆盖写入不应超出配额: %v", err)
+	}
+	if err := g.Set(ctx, "k2", []byte("short")); err != nil {
+		t.Errorf("更小的覆盖写入不应超出配额: %v", err)
+	}
+	if err := g.Set(ctx, "k3", []byte("value-14-bytes+++++++++")); err != nil {
+		t.Errorf("k2 变小后腾出的空间应允许 k3 变大: %v", err)
+	}
+	var quotaErr *QuotaExceededError
+	if err := g.Set(ctx, "k4", []byte("value-14-bytes-and-more")); !errors.As(err, &quotaErr) {
+		t.Fatalf("超出剩余空间的覆盖写入应返回 *QuotaExceededError，实际为 %v", err)
+	}
+	if quotaErr.Requested != 9 {
+		t.Errorf("Requested 应为新旧值的差额 9，实际为 %d", quotaErr.Requested)
+	}
+}
@@ -0,0 +1,118 @@
+package mycache
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// defaultReadinessInterval 默认的就绪状态检查间隔
+const defaultReadinessInterval = time.Second
+
+var (
+	errNotReady   = errors.New("cache: server is not ready")
+	errOverloaded = errors.New("cache: server is overloaded")
+)
+
+// ReadinessFunc 检查节点是否可以接收请求，返回错误时健康检查服务报告 NOT_SERVING
+type ReadinessFunc func() error
+
+// WithReadinessCheck 设置就绪检查，每隔 interval 调用一次 fn 并更新健康检查服务的状态
+//
+// 除 fn 外，以下情况同样报告 NOT_SERVING：通过 WithStartNotReady 启动且尚未调用 SetReady(true)、
+// 正在处理的请求数达到 WithLoadShedding 的阈值、Shutdown 已开始。启用了 WithHealthCheck 的
+// ClientPicker 会把连续报告 NOT_SERVING 的节点从哈希环上摘除，恢复后重新加入。
+// fn 可以为 nil，此时只根据上述内置信号更新状态；interval 为 0 时使用 1s。
+func WithReadinessCheck(fn ReadinessFunc, interval time.Duration) ServerOption {
+	return func(o *ServerOptions) {
+		if interval <= 0 {
+			interval = defaultReadinessInterval
+		}
+		o.Readiness = fn
+		o.ReadinessInterval = interval
+	}
+}
+
+// WithStartNotReady 服务器启动后报告 NOT_SERVING，直到调用 SetReady(true)
+// 用于预热本地缓存期间不接收其他节点路由过来的流量
+func WithStartNotReady() ServerOption {
+	return func(o *ServerOptions) {
+		o.StartNotReady = true
+	}
+}
+
+// SetReady 标记节点是否已就绪（如预热完成），并立即更新健康检查服务的状态
+func (s *Server) SetReady(ready bool) {
+	s.ready.Store(ready)
+	s.updateHealth()
+}
+
+// Ready 返回节点当前是否就绪，即健康检查服务是否报告 SERVING
+func (s *Server) Ready() bool {
+	return s.readinessError() == nil
+}
+
+// readinessError 返回节点未就绪的原因，就绪时返回 nil
+func (s *Server) readinessError() error {
+	s.mu.Lock()
+	shutdown := s.shutdown
+	s.mu.Unlock()
+
+	switch {
+	case shutdown:
+		return errServerShutdown
+	case !s.ready.Load():
+		return errNotReady
+	case s.shedder.threshold > 0 && s.shedder.inFlight.Load() >= s.shedder.threshold:
+		return errOverloaded
+	}
+	if s.opts.Readiness != nil {
+		return s.opts.Readiness()
+	}
+	return nil
+}
+
+// updateHealth 根据就绪状态更新健康检查服务，状态变化时记录日志
+func (s *Server) updateHealth() {
+	err := s.readinessError()
+	status := healthpb.HealthCheckResponse_SERVING
+	if err != nil {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	if s.serving.Swap(err == nil) != (err == nil) {
+		if err != nil {
+			log.Printf("[Server] reporting NOT_SERVING: %v", err)
+		} else {
+			log.Printf("[Server] reporting SERVING")
+		}
+	}
+	s.setServingStatus(status)
+}
+
+// setServingStatus 同时设置本服务和整体（空服务名）的健康状态
+func (s *Server) setServingStatus(status healthpb.HealthCheckResponse_ServingStatus) {
+	s.health.SetServingStatus(s.svcName, status)
+	s.health.SetServingStatus("", status)
+}
+
+// startReadiness 在后台定期更新健康状态，直到服务器关闭；未启用就绪检查时不做任何事
+func (s *Server) startReadiness() {
+	if s.opts.ReadinessInterval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(s.opts.ReadinessInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case <-ticker.C:
+				s.updateHealth()
+			}
+		}
+	}()
+}
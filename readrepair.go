@@ -0,0 +1,100 @@
+package mycache
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// readRepairTimeout 一次读修复（探测副本与写回）的超时时间
+const readRepairTimeout = 3 * time.Second
+
+// entryPeer 由 Client 实现，获取值时同时返回对端记录的过期时间和写入时间
+// localOnly 为 true 时只读取对端本地缓存，未命中时返回错误
+type entryPeer interface {
+	getEntry(ctx context.Context, group, key string, localOnly bool) (ByteView, error)
+}
+
+// WithReadRepair 启用读修复
+//
+// 从 owner 获取数据后，异步读取环上前 replicas 个副本节点的本地缓存（不触发加载），
+// 以写入时间较新的值为准，写回值不一致的副本和本节点，使分区恢复后各副本尽快收敛。
+// 写回保留原有的过期时间。需要 PeerPicker 实现 ReplicaPicker 接口。
+func WithReadRepair(replicas int) GroupOption {
+	return func(g *Group) {
+		g.readRepairReplicas = replicas
+	}
+}
+
+// replicaValue 副本节点上的值
+type replicaValue struct {
+	peer  Peer
+	view  ByteView
+	found bool
+}
+
+// readRepair 比较各副本上的值并写回最新值，fetched 为从 owner 获取到的值
+func (g *Group) readRepair(key string, fetched ByteView) {
+	replicaPicker, ok := g.peers.(ReplicaPicker)
+	if !ok {
+		return
+	}
+	replicas := replicaPicker.PickPeers(key, g.readRepairReplicas)
+	if len(replicas) < 2 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(WithPriority(context.Background(), PriorityBackground), readRepairTimeout)
+	defer cancel()
+
+	// 第一个副本为 owner，其值即为本次获取到的值
+	values := make([]replicaValue, len(replicas))
+	values[0] = replicaValue{peer: replicas[0], view: fetched, found: true}
+
+	var wg sync.WaitGroup
+	for i := 1; i < len(replicas); i++ {
+		ep, ok := replicas[i].(entryPeer)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, ep entryPeer) {
+			defer wg.Done()
+			view, err := ep.getEntry(ctx, g.name, key, true)
+			values[i] = replicaValue{peer: replicas[i], view: view, found: err == nil}
+		}(i, ep)
+	}
+	wg.Wait()
+
+	newest := fetched
+	for _, v := range values {
+		if v.found && v.view.written.After(newest.written) {
+			newest = v.view
+		}
+	}
+
+	// 只写回已有副本且值不一致的节点，缺少副本的节点在下次访问时自行加载
+	for _, v := range values {
+		if !v.found || bytes.Equal(v.view.b, newest.b) {
+			continue
+		}
+
+		entries := make(chan TransferEntry, 1)
+		entries <- TransferEntry{Key: key, Value: newest.b, ExpireAt: newest.expire}
+		close(entries)
+		if _, err := v.peer.Transfer(ctx, g.name, entries); err != nil {
+			log.Printf("[MyCache] read repair for key %s failed: %v", key, err)
+			continue
+		}
+		g.stats.readRepairs.Add(1)
+	}
+
+	// owner 上的值已过时，更新本节点保存的副本
+	if !bytes.Equal(newest.b, fetched.b) {
+		if err := g.storeTransferred(ctx, key, newest.b, newest.expire); err == nil {
+			g.stats.readRepairs.Add(1)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package mycache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+
+	pb "github.com/linhx1999/MyCache-Go/pb"
+	"google.golang.org/grpc"
+)
+
+// RebalanceProgress 一个节点上一个组的再平衡进度
+// 同一个节点和组会依次报告多次累计进度，Done 为 true 的是最终结果
+type RebalanceProgress struct {
+	Node    string `json:"node"`
+	Group   string `json:"group"`
+	Keys    int    `json:"keys"`    // 需要迁移的 key 数量
+	Stored  int    `json:"stored"`  // 新 owner 成功写入的数量
+	Failed  int    `json:"failed"`  // 发送或写入失败的数量
+	Dropped int    `json:"dropped"` // 迁移后从本地删除的数量
+	Done    bool   `json:"done"`
+	Error   string `json:"error,omitempty"`
+}
+
+// RebalanceSummary 汇总各节点各组的最终结果
+type RebalanceSummary struct {
+	Nodes   int // 报告了结果的节点数
+	Keys    int
+	Stored  int
+	Failed  int
+	Dropped int
+	Errors  []string // 出错的节点和组，格式为 "node/group: error"
+}
+
+// add 计入一条进度，只统计最终结果
+func (s *RebalanceSummary) add(p RebalanceProgress, nodes map[string]bool) {
+	if !p.Done {
+		return
+	}
+	if !nodes[p.Node] {
+		nodes[p.Node] = true
+		s.Nodes++
+	}
+	s.Keys += p.Keys
+	s.Stored += p.Stored
+	s.Failed += p.Failed
+	s.Dropped += p.Dropped
+	if p.Error != "" {
+		if p.Group != "" {
+			s.Errors = append(s.Errors, fmt.Sprintf("%s/%s: %s", p.Node, p.Group, p.Error))
+		} else {
+			s.Errors = append(s.Errors, fmt.Sprintf("%s: %s", p.Node, p.Error))
+		}
+	}
+}
+
+// Rebalance 实现 Cache 服务的 Rebalance 方法，以流的形式返回再平衡进度
+func (s *Server) Rebalance(req *pb.RebalanceRequest, stream grpc.ServerStreamingServer[pb.RebalanceProgress]) error {
+	return s.rebalance(stream.Context(), req.Group, req.Drop, req.Local, func(p RebalanceProgress) error {
+		return stream.Send(progressToPB(p))
+	})
+}
+
+// rebalance 在本节点执行再平衡，local 为 false 时同时让所有已发现的节点执行，send 串行调用
+//
+// 节点加入后，新 owner 上没有数据，而旧 owner 仍持有这些 key；各节点按当前的哈希环重新计算归属，
+// 通过 Transfer 流把不再属于自己的 key 发送给新 owner。未启用 WithMigration 的集群可以在扩缩容后
+// 手动触发，避免新节点接管的 key 全部未命中。
+func (s *Server) rebalance(ctx context.Context, group string, drop, local bool, send func(RebalanceProgress) error) error {
+	groups := s.servedGroups()
+	if group != "" {
+		g := s.group(group)
+		if g == nil {
+			return errGroupNotFound(group)
+		}
+		groups = []*Group{g}
+	}
+
+	ctx, cancel := context.WithCancel(WithPriority(ctx, PriorityBackground))
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		sendErr error
+	)
+	report := func(p RebalanceProgress) {
+		mu.Lock()
+		defer mu.Unlock()
+		if sendErr != nil {
+			return
+		}
+		if sendErr = send(p); sendErr != nil {
+			// 调用方已断开，停止迁移
+			cancel()
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.rebalanceGroups(ctx, groups, drop, report)
+	}()
+
+	if !local {
+		for _, client := range clusterPeers(groups) {
+			wg.Add(1)
+			go func(client *Client) {
+				defer wg.Done()
+				err := client.rebalance(ctx, &pb.RebalanceRequest{Group: group, Drop: drop, Local: true}, report)
+				if err != nil && ctx.Err() == nil {
+					report(RebalanceProgress{Node: client.addr, Done: true, Error: err.Error()})
+				}
+			}(client)
+		}
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	return sendErr
+}
+
+// rebalanceGroups 依次迁移本节点的各个组
+func (s *Server) rebalanceGroups(ctx context.Context, groups []*Group, drop bool, report func(RebalanceProgress)) {
+	node := s.AdvertiseAddr()
+	for _, g := range groups {
+		if ctx.Err() != nil {
+			return
+		}
+		progress := func(r MigrationResult) {
+			if r.Keys == 0 {
+				// 没有需要迁移的 key，只报告最终结果
+				return
+			}
+			report(RebalanceProgress{Node: node, Group: g.name, Keys: r.Keys, Stored: r.Stored, Failed: r.Failed, Dropped: r.Dropped})
+		}
+		result, err := g.migrate(ctx, drop, nil, progress)
+
+		final := RebalanceProgress{Node: node, Group: g.name, Keys: result.Keys, Stored: result.Stored,
+			Failed: result.Failed, Dropped: result.Dropped, Done: true}
+		if err != nil {
+			final.Error = err.Error()
+		}
+		report(final)
+		if err != nil || result.Keys > 0 {
+			log.Printf("[Server] rebalanced group [%s]: %+v, err: %v", g.name, result, err)
+		}
+	}
+}
+
+// clusterPeers 返回各组使用的 ClientPicker 中的节点客户端，按地址去重并排序
+func clusterPeers(groups []*Group) []*Client {
+	seen := make(map[string]*Client)
+	for _, g := range groups {
+		picker, ok := g.peers.(*ClientPicker)
+		if !ok {
+			continue
+		}
+		picker.mu.RLock()
+		for addr, client := range picker.clients {
+			if _, ok := seen[addr]; !ok {
+				seen[addr] = client
+			}
+		}
+		picker.mu.RUnlock()
+	}
+
+	clients := make([]*Client, 0, len(seen))
+	for _, client := range seen {
+		clients = append(clients, client)
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].addr < clients[j].addr
+	})
+	return clients
+}
+
+// Rebalance 让该节点协调整个集群的再平衡，fn 依次收到各节点各组的进度，返回汇总结果
+//
+// 该节点及其发现的所有节点各自把 owner 已变为其他节点的 key 迁移到新 owner。group 为空时处理所有组，
+// drop 为 true 时迁移成功后从原节点删除。单个节点或组失败不影响其他节点，失败记录在 RebalanceSummary.Errors 中；
+// 返回的错误只表示与协调节点的通信失败。
+func (c *Client) Rebalance(ctx context.Context, group string, drop bool, fn func(RebalanceProgress)) (RebalanceSummary, error) {
+	var summary RebalanceSummary
+	nodes := make(map[string]bool)
+	err := c.rebalance(ctx, &pb.RebalanceRequest{Group: group, Drop: drop}, func(p RebalanceProgress) {
+		summary.add(p, nodes)
+		if fn != nil {
+			fn(p)
+		}
+	})
+	return summary, err
+}
+
+// rebalance 调用 Rebalance RPC 并对收到的每条进度调用 fn
+// 再平衡可能持续较长时间，不使用请求超时，也不重试（已迁移的 key 会被重复发送）
+func (c *Client) rebalance(ctx context.Context, req *pb.RebalanceRequest, fn func(RebalanceProgress)) error {
+	if !c.Supports(FeatureRebalance) {
+		return fmt.Errorf("cache: peer %s does not support rebalance", c.addr)
+	}
+
+	stream, err := c.pick().Rebalance(ctx, req)
+	if err != nil {
+		return fromStatusError(err)
+	}
+	for {
+		p, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fromStatusError(err)
+		}
+		fn(progressFromPB(p))
+	}
+}
+
+// progressToPB 将进度转换为 RPC 消息
+func progressToPB(p RebalanceProgress) *pb.RebalanceProgress {
+	return &pb.RebalanceProgress{
+		Node:    p.Node,
+		Group:   p.Group,
+		Keys:    int32(p.Keys),
+		Stored:  int32(p.Stored),
+		Failed:  int32(p.Failed),
+		Dropped: int32(p.Dropped),
+		Done:    p.Done,
+		Error:   p.Error,
+	}
+}
+
+// progressFromPB 将 RPC 消息转换为进度
+func progressFromPB(p *pb.RebalanceProgress) RebalanceProgress {
+	return RebalanceProgress{
+		Node:    p.GetNode(),
+		Group:   p.GetGroup(),
+		Keys:    int(p.GetKeys()),
+		Stored:  int(p.GetStored()),
+		Failed:  int(p.GetFailed()),
+		Dropped: int(p.GetDropped()),
+		Done:    p.GetDone(),
+		Error:   p.GetError(),
+	}
+}
@@ -0,0 +1,116 @@
+package mycache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRebalance_StreamsProgress(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 新节点 b 上没有数据，也没有其他节点
+	b := newBatchGroup("rebalance")
+	defer b.Close()
+	clientB := startBatchServer(t, b)
+	clientB.Get(ctx, b.name, "ready") // 等待 b 开始监听
+
+	// 旧节点 a 按包含 b 的哈希环计算，部分 key 的 owner 已变为 b
+	addrA := freeAddr(t)
+	picker, err := NewStaticPicker(addrA, []string{clientB.addr})
+	if err != nil {
+		t.Fatalf("NewStaticPicker 失败: %v", err)
+	}
+	defer picker.Close()
+	a := NewGroup("rebalance", 1<<20, DataSourceFunc(func(ctx context.Context, key string) ([]byte, error) {
+		return nil, errors.New("not found")
+	}), WithPeers(picker))
+	defer a.Close()
+
+	moved := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		a.localCache.Add(key, ByteView{b: []byte(key)})
+		if _, ok, isSelf := picker.PickPeer(key); ok && !isSelf {
+			moved[key] = true
+		}
+	}
+	if len(moved) == 0 || len(moved) == 100 {
+		t.Fatalf("测试数据应有一部分 key 归属 b，实际为 %d 个", len(moved))
+	}
+
+	srvA, err := NewServer(addrA, "rebalance-test", WithoutRegistry())
+	if err != nil {
+		t.Fatalf("创建服务器失败: %v", err)
+	}
+	srvA.RegisterGroup(a)
+	go srvA.Start()
+	defer srvA.Stop()
+	clientA, err := DialNode(addrA, WithWaitForReady(true))
+	if err != nil {
+		t.Fatalf("DialNode 失败: %v", err)
+	}
+	defer clientA.Close()
+
+	var (
+		mu       sync.Mutex
+		progress []RebalanceProgress
+	)
+	summary, err := clientA.Rebalance(ctx, "rebalance", true, func(p RebalanceProgress) {
+		mu.Lock()
+		progress = append(progress, p)
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatalf("Rebalance 失败: %v", err)
+	}
+
+	// a 先报告需要迁移的 key 数量，再报告最终结果；b 没有需要迁移的 key，只报告最终结果
+	var sawPartial, sawFinalA, sawFinalB bool
+	for _, p := range progress {
+		switch {
+		case p.Node == addrA && !p.Done:
+			if sawFinalA {
+				t.Errorf("最终结果之后不应再有进度: %+v", p)
+			}
+			if p.Keys != len(moved) {
+				t.Errorf("进度中需要迁移的 key 应为 %d 个，实际为 %+v", len(moved), p)
+			}
+			sawPartial = true
+		case p.Node == addrA:
+			sawFinalA = true
+		case p.Node == clientB.addr && p.Done:
+			if p.Keys != 0 || p.Error != "" {
+				t.Errorf("b 不应有需要迁移的 key，实际为 %+v", p)
+			}
+			sawFinalB = true
+		}
+	}
+	if !sawPartial || !sawFinalA || !sawFinalB {
+		t.Errorf("应收到 a 的进度和最终结果以及 b 的最终结果，实际为 %+v", progress)
+	}
+
+	if summary.Nodes != 2 || len(summary.Errors) != 0 {
+		t.Errorf("应有 2 个节点报告结果且没有错误，实际为 %+v", summary)
+	}
+	if summary.Keys != len(moved) || summary.Stored != len(moved) || summary.Dropped != len(moved) || summary.Failed != 0 {
+		t.Errorf("应迁移并删除 %d 个 key，实际为 %+v", len(moved), summary)
+	}
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		_, onA := a.localCache.Get(ctx, key)
+		view, onB := b.localCache.Get(ctx, key)
+		if moved[key] {
+			if onA || !onB || view.String() != key {
+				t.Errorf("%s 应迁移到 b 并从 a 删除，a=%v b=%v", key, onA, onB)
+			}
+		} else if !onA || onB {
+			t.Errorf("%s 仍归属 a，不应迁移，a=%v b=%v", key, onA, onB)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ConsulConfig 定义 Consul 后端配置
+type ConsulConfig struct {
+	Address         string        // Consul agent 地址，如 "http://127.0.0.1:8500"
+	Token           string        // ACL token，为空时不携带
+	CheckTTL        time.Duration // TTL 健康检查的周期，节点需在该时间内上报心跳
+	DeregisterAfter time.Duration // 健康检查持续失败多久后由 Consul 自动注销实例
+	WaitTime        time.Duration // 阻塞查询的最长等待时间
+	HTTPClient      *http.Client  // 访问 Consul 使用的 HTTP 客户端，为空时使用默认客户端
+}
+
+// DefaultConsulConfig 提供默认的 Consul 配置
+var DefaultConsulConfig = &ConsulConfig{
+	Address:         "http://127.0.0.1:8500",
+	CheckTTL:        10 * time.Second,
+	DeregisterAfter: time.Minute,
+	WaitTime:        5 * time.Minute,
+}
+
+// Consul 基于 Consul HTTP API 的服务注册与发现实现
+//
+// 注册时为实例创建 TTL 健康检查并在后台定期上报心跳；Watch 使用阻塞查询
+// 只获取健康的实例，实例变化时立即返回。
+type Consul struct {
+	config ConsulConfig
+	client *http.Client
+
+	mu         sync.Mutex
+	heartbeats map[string]context.CancelFunc // 实例 ID 到心跳任务的取消函数
+}
+
+var _ Discovery = (*Consul)(nil)
+
+// NewConsul 创建 Consul 后端，config 为 nil 时使用 DefaultConsulConfig
+func NewConsul(config *ConsulConfig) *Consul {
+	if config == nil {
+		config = DefaultConsulConfig
+	}
+	c := &Consul{
+		config:     *config,
+		client:     config.HTTPClient,
+		heartbeats: make(map[string]context.CancelFunc),
+	}
+	if c.client == nil {
+		// 阻塞查询会长时间挂起，超时需大于 WaitTime
+		c.client = &http.Client{Timeout: c.config.WaitTime + 30*time.Second}
+	}
+	return c
+}
+
+// consulService 注册请求中的服务定义
+type consulService struct {
+	ID      string      `json:"ID"`
+	Name    string      `json:"Name"`
+	Address string      `json:"Address"`
+	Port    int         `json:"Port"`
+	Check   consulCheck `json:"Check"`
+}
+
+// consulCheck 服务的 TTL 健康检查
+type consulCheck struct {
+	TTL                            string `json:"TTL"`
+	DeregisterCriticalServiceAfter string `json:"DeregisterCriticalServiceAfter,omitempty"`
+}
+
+// consulHealthEntry 健康查询结果中的单个实例
+type consulHealthEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		Address string `json:"Address"`
+		Port    int    `json:"Port"`
+	} `json:"Service"`
+}
+
+// serviceID 生成实例在 Consul 中的唯一 ID
+func serviceID(svcName, addr string) string {
+	return svcName + "-" + addr
+}
+
+// Register 注册服务实例并启动 TTL 心跳
+func (c *Consul) Register(ctx context.Context, svcName, addr string) error {
+	if addr != "" && addr[0] == ':' {
+		localIP, err := getLocalIP()
+		if err != nil {
+			return fmt.Errorf("failed to get local IP: %v", err)
+		}
+		addr = localIP + addr
+	}
+
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid service address %s: %v", addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return fmt.Errorf("invalid service port %s: %v", portStr, err)
+	}
+
+	id := serviceID(svcName, addr)
+	service := consulService{
+		ID:      id,
+		Name:    svcName,
+		Address: host,
+		Port:    port,
+		Check: consulCheck{
+			TTL: c.config.CheckTTL.String(),
+		},
+	}
+	if c.config.DeregisterAfter > 0 {
+		service.Check.DeregisterCriticalServiceAfter = c.config.DeregisterAfter.String()
+	}
+
+	if err := c.do(ctx, http.MethodPut, "/v1/agent/service/register", nil, service, nil); err != nil {
+		return fmt.Errorf("failed to register service: %v", err)
+	}
+	// 注册后立即上报一次，使实例尽快变为健康状态
+	if err := c.pass(ctx, id); err != nil {
+		log.Printf("[Registry] WARN: failed to pass ttl check: %v", err)
+	}
+
+	hbCtx, cancel := context.WithCancel(context.Background())
+	c.mu.Lock()
+	if prev, ok := c.heartbeats[id]; ok {
+		prev()
+	}
+	c.heartbeats[id] = cancel
+	c.mu.Unlock()
+	go c.heartbeat(hbCtx, id)
+
+	log.Printf("[Registry] Service registered to consul: %s at %s", svcName, addr)
+	return nil
+}
+
+// heartbeat 按 TTL 的一半周期上报心跳
+func (c *Consul) heartbeat(ctx context.Context, id string) {
+	interval := c.config.CheckTTL / 2
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.pass(ctx, id); err != nil && ctx.Err() == nil {
+				log.Printf("[Registry] WARN: failed to pass ttl check: %v", err)
+			}
+		}
+	}
+}
+
+// pass 上报一次 TTL 检查通过
+func (c *Consul) pass(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPut, "/v1/agent/check/pass/service:"+url.PathEscape(id), nil, nil, nil)
+}
+
+// Deregister 停止心跳并注销服务实例
+func (c *Consul) Deregister(ctx context.Context, svcName, addr string) error {
+	if addr != "" && addr[0] == ':' {
+		if localIP, err := getLocalIP(); err == nil {
+			addr = localIP + addr
+		}
+	}
+	id := serviceID(svcName, addr)
+
+	c.mu.Lock()
+	if cancel, ok := c.heartbeats[id]; ok {
+		cancel()
+		delete(c.heartbeats, id)
+	}
+	c.mu.Unlock()
+
+	if err := c.do(ctx, http.MethodPut, "/v1/agent/service/deregister/"+url.PathEscape(id), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to deregister service: %v", err)
+	}
+	log.Printf("[Registry] Service deregistered from consul: %s at %s", svcName, addr)
+	return nil
+}
+
+// Watch 通过阻塞查询监听健康实例的变化
+func (c *Consul) Watch(ctx context.Context, svcName string, onChange func(addrs []string)) error {
+	var (
+		index uint64
+		last  []string
+		first = true
+	)
+
+	for {
+		query := url.Values{}
+		query.Set("passing", "true")
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", c.config.WaitTime.String())
+
+		var entries []consulHealthEntry
+		var header http.Header
+		err := c.do(ctx, http.MethodGet, "/v1/health/service/"+url.PathEscape(svcName), query, nil, func(resp *http.Response) error {
+			header = resp.Header
+			return json.NewDecoder(resp.Body).Decode(&entries)
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("[Registry] WARN: failed to query consul: %v", err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		// 索引回退时（如 Consul 重启）重新从头查询
+		newIndex, _ := strconv.ParseUint(header.Get("X-Consul-Index"), 10, 64)
+		if newIndex < index {
+			newIndex = 0
+		}
+		index = newIndex
+
+		addrs := consulAddrs(entries)
+		if first || !equalStrings(addrs, last) {
+			first = false
+			last = addrs
+			onChange(addrs)
+		}
+	}
+}
+
+// consulAddrs 从健康查询结果中提取实例地址，结果已排序
+func consulAddrs(entries []consulHealthEntry) []string {
+	addrs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		host := entry.Service.Address
+		if host == "" {
+			host = entry.Node.Address
+		}
+		addrs = append(addrs, net.JoinHostPort(host, strconv.Itoa(entry.Service.Port)))
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// equalStrings 判断两个切片是否相同
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Close 停止所有心跳任务，已注册的实例会在 TTL 过期后变为不健康
+func (c *Consul) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, cancel := range c.heartbeats {
+		cancel()
+		delete(c.heartbeats, id)
+	}
+	return nil
+}
+
+// do 发送请求到 Consul，body 不为 nil 时编码为 JSON，decode 不为 nil 时用于解析响应
+func (c *Consul) do(ctx context.Context, method, path string, query url.Values, body interface{}, decode func(*http.Response) error) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	u := c.config.Address + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return err
+	}
+	if c.config.Token != "" {
+		req.Header.Set("X-Consul-Token", c.config.Token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("consul returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	if decode != nil {
+		return decode(resp)
+	}
+	return nil
+}
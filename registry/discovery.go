@@ -0,0 +1,20 @@
+package registry
+
+import "context"
+
+// Discovery 服务注册与发现后端的通用接口
+//
+// 缓存节点通过 Register 注册自身地址，ClientPicker 通过 Watch 获取全部节点地址并更新哈希环。
+// 实现需保证并发安全。已有的实现：Etcd、Consul、Gossip、Static，
+// 以及不依赖外部组件、用于测试和本地开发的 Memory 和 File。新的后端（如 Kubernetes）
+// 只需实现该接口，通过 WithDiscovery 和 NewDiscoveryPicker 接入，无需修改服务端和 ClientPicker。
+type Discovery interface {
+	// Register 注册服务实例，并在后台保持注册有效，直到 Deregister 或 Close 被调用
+	Register(ctx context.Context, svcName, addr string) error
+	// Deregister 注销服务实例
+	Deregister(ctx context.Context, svcName, addr string) error
+	// Watch 监听服务实例变化，每次变化时以当前全部实例地址调用 onChange，直到 ctx 取消
+	Watch(ctx context.Context, svcName string, onChange func(addrs []string)) error
+	// Close 停止所有后台任务并释放资源
+	Close() error
+}
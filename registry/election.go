@@ -0,0 +1,262 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+// defaultElectionTTL 选举会话的默认租约时间，协调者异常退出后最多经过该时间由其他节点接替
+const defaultElectionTTL = 10 * time.Second
+
+// ElectionPrefix 返回服务选举使用的 key 前缀，与实例 key 位于同一前缀下但不会被 ClientPicker 发现
+func ElectionPrefix(prefix, svcName string) string {
+	return fmt.Sprintf("%s/_election/%s", strings.TrimSuffix(prefix, "/"), svcName)
+}
+
+// CoordinatorOption 定义 Coordinator 的配置选项
+type CoordinatorOption func(*Coordinator)
+
+// WithElectionTTL 设置选举会话的租约时间，默认 10s，不足 1s 按 1s 计算
+// 时间越短故障切换越快，但网络抖动时越容易误判协调者失效
+func WithElectionTTL(ttl time.Duration) CoordinatorOption {
+	return func(c *Coordinator) {
+		c.ttl = ttl
+	}
+}
+
+// WithElectionPrefix 设置选举 key 的前缀，默认使用 DefaultConfig.KeyPrefix
+func WithElectionPrefix(prefix string) CoordinatorOption {
+	return func(c *Coordinator) {
+		c.keyPrefix = prefix
+	}
+}
+
+// WithLeaderChange 设置本节点成为或不再是协调者时的回调
+func WithLeaderChange(fn func(leader bool)) CoordinatorOption {
+	return func(c *Coordinator) {
+		c.onChange = fn
+	}
+}
+
+// coordinatorTask 只在协调者上运行的后台任务
+type coordinatorTask struct {
+	name string
+	fn   func(ctx context.Context)
+}
+
+// Coordinator 通过 etcd 选举保证集群中同一时间只有一个节点运行全局后台任务，
+// 如反熵调度、全局统计汇总和数据迁移编排
+//
+// 各节点以相同的服务名参与选举，当选的节点启动通过 Go 添加的任务。协调者的会话租约过期
+// （进程崩溃、与 etcd 断开）时任务的 ctx 被取消，其他节点在租约过期后接替并重新启动任务。
+// 由于租约过期的判断存在延迟，切换期间新旧协调者的任务可能短暂重叠，任务应能容忍这种情况。
+type Coordinator struct {
+	cli       *clientv3.Client
+	svcName   string
+	id        string
+	keyPrefix string
+	ttl       time.Duration
+	onChange  func(leader bool)
+
+	mu      sync.Mutex
+	tasks   []coordinatorTask
+	lead    context.Context // 本节点是协调者时任务使用的上下文，否则为 nil
+	wg      sync.WaitGroup  // 正在运行的任务
+	leader  atomic.Bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+	started bool
+}
+
+// NewCoordinator 创建协调者选举，id 为本节点的唯一标识（通常为节点地址），cli 由调用方负责关闭
+func NewCoordinator(cli *clientv3.Client, svcName, id string, opts ...CoordinatorOption) *Coordinator {
+	c := &Coordinator{
+		cli:       cli,
+		svcName:   svcName,
+		id:        id,
+		keyPrefix: DefaultConfig.Prefix(),
+		ttl:       defaultElectionTTL,
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Go 添加只在协调者上运行的任务，本节点当前是协调者时立即启动
+// fn 应在 ctx 取消（失去协调者身份或 Close）后尽快返回
+func (c *Coordinator) Go(name string, fn func(ctx context.Context)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	task := coordinatorTask{name: name, fn: fn}
+	c.tasks = append(c.tasks, task)
+	if c.lead != nil {
+		c.startTask(c.lead, task)
+	}
+}
+
+// startTask 启动任务，调用者必须持有 c.mu
+func (c *Coordinator) startTask(ctx context.Context, task coordinatorTask) {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		log.Printf("[Registry] coordinator task %s started", task.name)
+		task.fn(ctx)
+		log.Printf("[Registry] coordinator task %s stopped", task.name)
+	}()
+}
+
+// Start 在后台参与选举，重复调用无效
+func (c *Coordinator) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.started {
+		return
+	}
+	c.started = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	go c.run(ctx)
+}
+
+// IsLeader 判断本节点当前是否是协调者
+func (c *Coordinator) IsLeader() bool {
+	return c.leader.Load()
+}
+
+// Leader 返回当前协调者的标识，没有协调者时返回 concurrency.ErrElectionNoLeader
+func (c *Coordinator) Leader(ctx context.Context) (string, error) {
+	resp, err := c.cli.Get(ctx, c.prefix()+"/", clientv3.WithFirstCreate()...)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Kvs) == 0 {
+		return "", concurrency.ErrElectionNoLeader
+	}
+	return string(resp.Kvs[0].Value), nil
+}
+
+// Close 停止所有任务并退出选举，本节点是协调者时立即让出，其他节点无需等待租约过期
+func (c *Coordinator) Close() error {
+	c.mu.Lock()
+	started := c.started
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.mu.Unlock()
+
+	if started {
+		<-c.done
+	}
+	return nil
+}
+
+// prefix 返回选举 key 的前缀
+func (c *Coordinator) prefix() string {
+	return ElectionPrefix(c.keyPrefix, c.svcName)
+}
+
+// run 循环参与选举，当选后运行任务直到失去协调者身份
+func (c *Coordinator) run(ctx context.Context) {
+	defer close(c.done)
+
+	ttl := int((c.ttl + time.Second - 1) / time.Second)
+	if ttl < 1 {
+		ttl = 1
+	}
+	backoff := time.Second
+	for ctx.Err() == nil {
+		// 会话使用独立的上下文，退出时仍能撤销租约
+		session, err := concurrency.NewSession(c.cli, concurrency.WithTTL(ttl))
+		if err != nil {
+			log.Printf("[Registry] WARN: failed to create election session: %v", err)
+			if !sleepContext(ctx, backoff) {
+				return
+			}
+			backoff = min(backoff*2, 30*time.Second)
+			continue
+		}
+
+		election := concurrency.NewElection(session, c.prefix())
+		if err := election.Campaign(ctx, c.id); err != nil {
+			session.Close()
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("[Registry] WARN: election campaign failed: %v", err)
+			if !sleepContext(ctx, backoff) {
+				return
+			}
+			backoff = min(backoff*2, 30*time.Second)
+			continue
+		}
+		backoff = time.Second
+
+		c.leadUntilLost(ctx, session, election)
+		session.Close()
+	}
+}
+
+// leadUntilLost 以协调者身份运行任务，直到会话过期或 ctx 取消
+func (c *Coordinator) leadUntilLost(ctx context.Context, session *concurrency.Session, election *concurrency.Election) {
+	lead, cancel := context.WithCancel(ctx)
+	c.mu.Lock()
+	c.lead = lead
+	c.leader.Store(true)
+	for _, task := range c.tasks {
+		c.startTask(lead, task)
+	}
+	c.mu.Unlock()
+	log.Printf("[Registry] %s elected as coordinator of %s", c.id, c.svcName)
+	if c.onChange != nil {
+		c.onChange(true)
+	}
+
+	lost := false
+	select {
+	case <-session.Done():
+		lost = true
+		log.Printf("[Registry] WARN: %s lost coordinator session of %s", c.id, c.svcName)
+	case <-ctx.Done():
+	}
+
+	// 先停止任务再让出，避免与下一任协调者的任务重叠
+	c.mu.Lock()
+	c.lead = nil
+	c.leader.Store(false)
+	c.mu.Unlock()
+	cancel()
+	c.wg.Wait()
+	if c.onChange != nil {
+		c.onChange(false)
+	}
+
+	if !lost {
+		resignCtx, resignCancel := context.WithTimeout(context.Background(), 3*time.Second)
+		if err := election.Resign(resignCtx); err != nil {
+			log.Printf("[Registry] WARN: failed to resign coordinator: %v", err)
+		}
+		resignCancel()
+	}
+}
+
+// sleepContext 等待 d，ctx 取消时返回 false
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
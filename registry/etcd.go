@@ -0,0 +1,205 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Etcd 基于 etcd 的 Discovery 实现，与 Register 和 ClientPicker 使用相同的 key 格式
+//
+// 注册时为实例创建租约并在后台续约，租约丢失后自动重新注册；Watch 先列出全部实例，
+// 再从该版本开始监听变化，监听中断时重新列出，不会遗漏中断期间的变化。
+// 所有注册和监听共用一个 etcd 客户端，由 Close 关闭。
+type Etcd struct {
+	config Config
+	cli    *clientv3.Client
+	opts   []RegisterOption
+
+	mu   sync.Mutex
+	regs map[string]*registration // 实例 key 到注册
+}
+
+var _ Discovery = (*Etcd)(nil)
+
+// NewEtcd 创建 etcd 后端，config 为 nil 时使用 DefaultConfig，opts 应用于每次 Register
+func NewEtcd(config *Config, opts ...RegisterOption) (*Etcd, error) {
+	if config == nil {
+		config = DefaultConfig
+	}
+	cli, err := config.NewClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create etcd client: %v", err)
+	}
+	return &Etcd{
+		config: *config,
+		cli:    cli,
+		opts:   opts,
+		regs:   make(map[string]*registration),
+	}, nil
+}
+
+// Register 注册服务实例并在后台续约，同一实例重复注册时直接返回
+func (e *Etcd) Register(ctx context.Context, svcName, addr string) error {
+	addr, err := resolveAddr(addr)
+	if err != nil {
+		return err
+	}
+
+	o := newRegisterOptions(e.config.Prefix(), e.opts)
+	key := ServiceKey(o.keyPrefix, svcName, addr)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.regs[key]; ok {
+		return nil
+	}
+
+	r := newRegistration(e.cli, svcName, addr, o, e.config.DialTimeout)
+	r.sharedClient = true
+	keepAliveCh, err := r.register()
+	if err != nil {
+		return err
+	}
+	e.regs[key] = r
+	go r.run(keepAliveCh)
+
+	log.Printf("[Registry] Service registered: %s at %s", svcName, addr)
+	return nil
+}
+
+// Deregister 删除实例 key 并撤销租约，停止后台续约
+func (e *Etcd) Deregister(ctx context.Context, svcName, addr string) error {
+	addr, err := resolveAddr(addr)
+	if err != nil {
+		return err
+	}
+	key := ServiceKey(newRegisterOptions(e.config.Prefix(), e.opts).keyPrefix, svcName, addr)
+
+	e.mu.Lock()
+	r, ok := e.regs[key]
+	delete(e.regs, key)
+	e.mu.Unlock()
+
+	if !ok {
+		// 不是本实例注册的，直接删除 key
+		if _, err := e.cli.Delete(ctx, key); err != nil {
+			return fmt.Errorf("failed to delete service key: %v", err)
+		}
+		return nil
+	}
+	if err := r.stop(); err != nil {
+		return err
+	}
+	log.Printf("[Registry] Service deregistered: %s at %s", svcName, addr)
+	return nil
+}
+
+// Watch 监听服务实例变化，实例集合变化时以排序后的全部地址调用 onChange
+func (e *Etcd) Watch(ctx context.Context, svcName string, onChange func(addrs []string)) error {
+	prefix := ServicePrefix(newRegisterOptions(e.config.Prefix(), e.opts).keyPrefix, svcName)
+
+	var (
+		last    []string
+		first   = true
+		backoff = time.Second
+	)
+	notify := func(addrs map[string]struct{}) {
+		list := make([]string, 0, len(addrs))
+		for addr := range addrs {
+			list = append(list, addr)
+		}
+		sort.Strings(list)
+		if first || !equalStrings(list, last) {
+			first = false
+			last = list
+			onChange(list)
+		}
+	}
+
+	for {
+		listed, err := e.watchOnce(ctx, prefix, notify)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if listed {
+			// 列出成功后监听才中断，从最小退避重新开始
+			backoff = time.Second
+		}
+		log.Printf("[Registry] WARN: watch %s interrupted: %v, re-listing in %v", prefix, err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+	}
+}
+
+// watchOnce 列出 prefix 下的全部实例，再从列出时的版本开始监听，直到监听中断
+// listed 表示是否列出成功，返回的错误说明中断的原因
+func (e *Etcd) watchOnce(ctx context.Context, prefix string, notify func(map[string]struct{})) (listed bool, err error) {
+	resp, err := e.cli.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return false, fmt.Errorf("failed to list services: %v", err)
+	}
+	addrs := make(map[string]struct{}, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		if addr := strings.TrimPrefix(string(kv.Key), prefix); addr != "" {
+			addrs[addr] = struct{}{}
+		}
+	}
+	notify(addrs)
+
+	watchCtx, cancel := context.WithCancel(clientv3.WithRequireLeader(ctx))
+	defer cancel()
+	watchCh := e.cli.Watch(watchCtx, prefix, clientv3.WithPrefix(), clientv3.WithRev(resp.Header.Revision+1))
+	for wresp := range watchCh {
+		if err := wresp.Err(); err != nil {
+			return true, err
+		}
+		for _, event := range wresp.Events {
+			addr := strings.TrimPrefix(string(event.Kv.Key), prefix)
+			if addr == "" {
+				continue
+			}
+			switch event.Type {
+			case clientv3.EventTypePut:
+				addrs[addr] = struct{}{}
+			case clientv3.EventTypeDelete:
+				delete(addrs, addr)
+			}
+		}
+		notify(addrs)
+	}
+	return true, errors.New("watch channel closed")
+}
+
+// Close 注销本实例注册的所有服务并关闭 etcd 客户端
+func (e *Etcd) Close() error {
+	e.mu.Lock()
+	regs := e.regs
+	e.regs = make(map[string]*registration)
+	e.mu.Unlock()
+
+	var errs []error
+	for _, r := range regs {
+		if err := r.stop(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := e.cli.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,226 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileConfig 定义基于本地目录的服务注册配置
+type FileConfig struct {
+	Dir      string        // 保存注册信息的目录，同一台机器上的所有节点使用同一个目录
+	Interval time.Duration // Watch 检查目录和 Register 刷新心跳的周期
+	TTL      time.Duration // 超过该时间未刷新的实例视为下线，0 表示不过期（节点崩溃后需手动删除文件）
+}
+
+// DefaultFileConfig 提供默认的本地目录配置
+var DefaultFileConfig = &FileConfig{
+	Dir:      filepath.Join(os.TempDir(), "mycache-registry"),
+	Interval: time.Second,
+	TTL:      10 * time.Second,
+}
+
+// File 基于本地目录的 Discovery 实现，不依赖 etcd，适用于本地开发时在一台机器上运行多个进程
+//
+// 每个实例对应文件 {Dir}/{svcName}/{转义后的地址}，内容为地址本身。Register 创建文件并
+// 周期性更新修改时间作为心跳，Deregister 删除文件，Watch 周期性列出目录。
+// 也可以手动在目录中创建文件作为静态节点列表，此时应将 TTL 设为 0。
+type File struct {
+	config FileConfig
+
+	mu         sync.Mutex
+	heartbeats map[string]context.CancelFunc // 实例文件路径到心跳协程
+	wg         sync.WaitGroup
+	closed     bool
+}
+
+var _ Discovery = (*File)(nil)
+
+// NewFile 创建基于本地目录的后端，config 为 nil 时使用 DefaultFileConfig
+func NewFile(config *FileConfig) *File {
+	if config == nil {
+		config = DefaultFileConfig
+	}
+	f := &File{
+		config:     *config,
+		heartbeats: make(map[string]context.CancelFunc),
+	}
+	if f.config.Interval <= 0 {
+		f.config.Interval = time.Second
+	}
+	return f
+}
+
+// instancePath 返回实例文件的路径，地址中的 ":" 等字符被转义
+func (f *File) instancePath(svcName, addr string) string {
+	return filepath.Join(f.config.Dir, url.PathEscape(svcName), url.QueryEscape(addr))
+}
+
+// Register 创建实例文件，启用 TTL 时在后台周期性刷新其修改时间
+func (f *File) Register(ctx context.Context, svcName, addr string) error {
+	addr, err := resolveAddr(addr)
+	if err != nil {
+		return err
+	}
+	path := f.instancePath(svcName, addr)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return errDiscoveryClosed
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create registry dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(addr), 0o644); err != nil {
+		return fmt.Errorf("failed to write instance file: %v", err)
+	}
+
+	if _, ok := f.heartbeats[path]; !ok && f.config.TTL > 0 {
+		hbCtx, cancel := context.WithCancel(context.Background())
+		f.heartbeats[path] = cancel
+		f.wg.Add(1)
+		go f.heartbeat(hbCtx, path, addr)
+	}
+	log.Printf("[Registry] Service registered to %s: %s at %s", f.config.Dir, svcName, addr)
+	return nil
+}
+
+// heartbeat 周期性刷新实例文件的修改时间，文件被删除时重新创建
+func (f *File) heartbeat(ctx context.Context, path, addr string) {
+	defer f.wg.Done()
+	ticker := time.NewTicker(f.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		if err := os.Chtimes(path, now, now); err != nil {
+			if err := os.WriteFile(path, []byte(addr), 0o644); err != nil {
+				log.Printf("[Registry] WARN: failed to refresh instance file %s: %v", path, err)
+			}
+		}
+	}
+}
+
+// Deregister 停止心跳并删除实例文件
+func (f *File) Deregister(ctx context.Context, svcName, addr string) error {
+	addr, err := resolveAddr(addr)
+	if err != nil {
+		return err
+	}
+	path := f.instancePath(svcName, addr)
+
+	f.mu.Lock()
+	cancel, ok := f.heartbeats[path]
+	delete(f.heartbeats, path)
+	f.mu.Unlock()
+	if ok {
+		cancel()
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove instance file: %v", err)
+	}
+	log.Printf("[Registry] Service deregistered from %s: %s at %s", f.config.Dir, svcName, addr)
+	return nil
+}
+
+// Watch 每个周期列出一次服务目录，实例集合变化时调用 onChange
+func (f *File) Watch(ctx context.Context, svcName string, onChange func(addrs []string)) error {
+	ticker := time.NewTicker(f.config.Interval)
+	defer ticker.Stop()
+
+	var (
+		last  []string
+		first = true
+	)
+	for {
+		addrs, err := f.list(svcName)
+		if err != nil {
+			log.Printf("[Registry] WARN: failed to list %s: %v", svcName, err)
+		} else if first || !equalStrings(addrs, last) {
+			first = false
+			last = addrs
+			onChange(addrs)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// list 返回服务目录中未过期的实例地址，结果已排序，目录不存在时返回空列表
+func (f *File) list(svcName string) ([]string, error) {
+	dir := filepath.Join(f.config.Dir, url.PathEscape(svcName))
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	addrs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if f.config.TTL > 0 && now.Sub(info.ModTime()) > f.config.TTL {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		addr := strings.TrimSpace(string(data))
+		if addr == "" {
+			// 手动创建的空文件，地址取自文件名
+			if addr, err = url.QueryUnescape(entry.Name()); err != nil {
+				continue
+			}
+		}
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+// Close 停止心跳并删除本实例注册的所有文件
+func (f *File) Close() error {
+	f.mu.Lock()
+	f.closed = true
+	heartbeats := f.heartbeats
+	f.heartbeats = make(map[string]context.CancelFunc)
+	f.mu.Unlock()
+
+	var errs []error
+	for path, cancel := range heartbeats {
+		cancel()
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	f.wg.Wait()
+	return errors.Join(errs...)
+}
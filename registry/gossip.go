@@ -0,0 +1,411 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxGossipPacket 单个 gossip 报文的最大长度，成员表需能放入一个 UDP 报文
+const maxGossipPacket = 64 * 1024
+
+// GossipConfig 定义 gossip 成员管理配置
+type GossipConfig struct {
+	BindAddr      string        // gossip 监听的 UDP 地址，如 ":7946"
+	AdvertiseAddr string        // 通告给其他节点的 gossip 地址，为空时使用 BindAddr（":port" 形式时补全本机 IP）
+	Seeds         []string      // 种子节点的 gossip 地址，用于加入集群
+	Interval      time.Duration // gossip 周期
+	Fanout        int           // 每个周期发送成员表的节点数
+	SuspectAfter  time.Duration // 超过该时间未收到心跳的成员视为下线
+	RemoveAfter   time.Duration // 下线或离开的成员在成员表中保留的时间，期间用于阻止旧消息使其复活
+}
+
+// DefaultGossipConfig 提供默认的 gossip 配置
+var DefaultGossipConfig = &GossipConfig{
+	BindAddr:     ":7946",
+	Interval:     time.Second,
+	Fanout:       3,
+	SuspectAfter: 5 * time.Second,
+	RemoveAfter:  30 * time.Second,
+}
+
+// gossipMember 成员表中的一个服务实例
+type gossipMember struct {
+	Node      string `json:"node"`      // 实例所在节点的 gossip 地址
+	Service   string `json:"service"`   // 服务名
+	Addr      string `json:"addr"`      // 服务地址
+	Heartbeat uint64 `json:"heartbeat"` // 心跳计数，只有所在节点会递增
+	Left      bool   `json:"left"`      // 实例已主动注销
+}
+
+// gossipMessage 节点间交换的报文
+type gossipMessage struct {
+	From    string         `json:"from"`
+	Members []gossipMember `json:"members"`
+}
+
+// memberState 成员及本地观察到的最近更新时间
+type memberState struct {
+	gossipMember
+	updated time.Time
+}
+
+// Gossip 基于 gossip 协议的服务注册与发现实现，不依赖 etcd 等外部组件，适合小规模集群
+//
+// 每个节点周期性递增本地实例的心跳计数，并把完整成员表通过 UDP 发送给随机选取的
+// 若干节点；收到的成员表按心跳计数合并，心跳长时间未增长的实例视为下线。
+// 新节点通过 Seeds 中任意一个在线节点即可加入集群。
+type Gossip struct {
+	config GossipConfig
+	self   string // 本节点通告的 gossip 地址
+	conn   net.PacketConn
+
+	mu      sync.Mutex
+	members map[string]*memberState // serviceID 到成员状态
+	local   map[string]bool         // 本节点注册的实例
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+var _ Discovery = (*Gossip)(nil)
+
+// NewGossip 创建 gossip 后端并开始监听，config 为 nil 时使用 DefaultGossipConfig
+func NewGossip(config *GossipConfig) (*Gossip, error) {
+	if config == nil {
+		config = DefaultGossipConfig
+	}
+	g := &Gossip{
+		config:  *config,
+		members: make(map[string]*memberState),
+		local:   make(map[string]bool),
+	}
+	if g.config.Interval <= 0 {
+		g.config.Interval = DefaultGossipConfig.Interval
+	}
+	if g.config.Fanout <= 0 {
+		g.config.Fanout = DefaultGossipConfig.Fanout
+	}
+	if g.config.SuspectAfter <= 0 {
+		g.config.SuspectAfter = DefaultGossipConfig.SuspectAfter
+	}
+	if g.config.RemoveAfter <= 0 {
+		g.config.RemoveAfter = DefaultGossipConfig.RemoveAfter
+	}
+
+	conn, err := net.ListenPacket("udp", g.config.BindAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", g.config.BindAddr, err)
+	}
+	g.conn = conn
+
+	g.self = g.config.AdvertiseAddr
+	if g.self == "" {
+		g.self = conn.LocalAddr().String()
+		if host, port, err := net.SplitHostPort(g.self); err == nil {
+			if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+				localIP, err := getLocalIP()
+				if err != nil {
+					conn.Close()
+					return nil, fmt.Errorf("failed to get local IP: %v", err)
+				}
+				g.self = net.JoinHostPort(localIP, port)
+			}
+		}
+	}
+
+	g.ctx, g.cancel = context.WithCancel(context.Background())
+	g.wg.Add(2)
+	go g.receive()
+	go g.run()
+
+	log.Printf("[Registry] Gossip listening on %s (advertise %s)", g.config.BindAddr, g.self)
+	return g, nil
+}
+
+// Register 在成员表中加入本节点的服务实例
+func (g *Gossip) Register(ctx context.Context, svcName, addr string) error {
+	if addr != "" && addr[0] == ':' {
+		localIP, err := getLocalIP()
+		if err != nil {
+			return fmt.Errorf("failed to get local IP: %v", err)
+		}
+		addr = localIP + addr
+	}
+
+	id := serviceID(svcName, addr)
+	g.mu.Lock()
+	var heartbeat uint64
+	if m, ok := g.members[id]; ok {
+		// 重新注册时心跳需大于之前的记录，否则其他节点会忽略
+		heartbeat = m.Heartbeat + 1
+	}
+	g.members[id] = &memberState{
+		gossipMember: gossipMember{Node: g.self, Service: svcName, Addr: addr, Heartbeat: heartbeat},
+		updated:      time.Now(),
+	}
+	g.local[id] = true
+	g.mu.Unlock()
+
+	// 立即广播，缩短其他节点发现新实例的时间
+	g.gossip()
+	log.Printf("[Registry] Service registered to gossip: %s at %s", svcName, addr)
+	return nil
+}
+
+// Deregister 将本节点的服务实例标记为已离开并广播
+func (g *Gossip) Deregister(ctx context.Context, svcName, addr string) error {
+	if addr != "" && addr[0] == ':' {
+		if localIP, err := getLocalIP(); err == nil {
+			addr = localIP + addr
+		}
+	}
+	id := serviceID(svcName, addr)
+
+	g.mu.Lock()
+	m, ok := g.members[id]
+	if !ok || !g.local[id] {
+		g.mu.Unlock()
+		return fmt.Errorf("service %s at %s is not registered on this node", svcName, addr)
+	}
+	m.Heartbeat++
+	m.Left = true
+	m.updated = time.Now()
+	delete(g.local, id)
+	g.mu.Unlock()
+
+	g.gossip()
+	log.Printf("[Registry] Service deregistered from gossip: %s at %s", svcName, addr)
+	return nil
+}
+
+// Watch 每个 gossip 周期检查一次在线实例，变化时调用 onChange
+func (g *Gossip) Watch(ctx context.Context, svcName string, onChange func(addrs []string)) error {
+	ticker := time.NewTicker(g.config.Interval)
+	defer ticker.Stop()
+
+	var (
+		last  []string
+		first = true
+	)
+	for {
+		addrs := g.alive(svcName)
+		if first || !equalStrings(addrs, last) {
+			first = false
+			last = addrs
+			onChange(addrs)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-g.ctx.Done():
+			return errors.New("gossip closed")
+		case <-ticker.C:
+		}
+	}
+}
+
+// alive 返回服务的在线实例地址，结果已排序
+func (g *Gossip) alive(svcName string) []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	var addrs []string
+	for id, m := range g.members {
+		if m.Service != svcName || m.Left {
+			continue
+		}
+		if !g.local[id] && now.Sub(m.updated) > g.config.SuspectAfter {
+			continue
+		}
+		addrs = append(addrs, m.Addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// Members 返回成员表中所有在线实例的 serviceID
+func (g *Gossip) Members() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	ids := make([]string, 0, len(g.members))
+	for id, m := range g.members {
+		if m.Left || (!g.local[id] && now.Sub(m.updated) > g.config.SuspectAfter) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// run 周期性递增本地心跳、清理过期成员并发送成员表
+func (g *Gossip) run() {
+	defer g.wg.Done()
+
+	ticker := time.NewTicker(g.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-g.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		g.mu.Lock()
+		for id, m := range g.members {
+			if g.local[id] {
+				m.Heartbeat++
+				m.updated = now
+				continue
+			}
+			// 下线的成员保留一段时间，防止延迟到达的旧消息使其复活
+			if now.Sub(m.updated) > g.config.SuspectAfter+g.config.RemoveAfter {
+				delete(g.members, id)
+			}
+		}
+		g.mu.Unlock()
+
+		g.gossip()
+	}
+}
+
+// gossip 将成员表发送给随机选取的 Fanout 个节点，没有已知节点时发送给种子节点
+func (g *Gossip) gossip() {
+	g.mu.Lock()
+	now := time.Now()
+	msg := gossipMessage{From: g.self, Members: make([]gossipMember, 0, len(g.members))}
+	nodes := make(map[string]bool)
+	for id, m := range g.members {
+		msg.Members = append(msg.Members, m.gossipMember)
+		if m.Node != g.self && !m.Left && (g.local[id] || now.Sub(m.updated) <= g.config.SuspectAfter) {
+			nodes[m.Node] = true
+		}
+	}
+	g.mu.Unlock()
+
+	targets := make([]string, 0, len(nodes))
+	for node := range nodes {
+		targets = append(targets, node)
+	}
+	rand.Shuffle(len(targets), func(i, j int) {
+		targets[i], targets[j] = targets[j], targets[i]
+	})
+	if len(targets) > g.config.Fanout {
+		targets = targets[:g.config.Fanout]
+	}
+	// 种子节点始终参与，使分区恢复后的节点能重新合并成员表
+	for _, seed := range g.config.Seeds {
+		if seed != g.self && !nodes[seed] {
+			targets = append(targets, seed)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("[Registry] WARN: failed to encode gossip message: %v", err)
+		return
+	}
+	if len(data) > maxGossipPacket {
+		log.Printf("[Registry] WARN: gossip message too large: %d bytes", len(data))
+		return
+	}
+
+	for _, target := range targets {
+		addr, err := net.ResolveUDPAddr("udp", target)
+		if err != nil {
+			log.Printf("[Registry] WARN: failed to resolve gossip node %s: %v", target, err)
+			continue
+		}
+		if _, err := g.conn.WriteTo(data, addr); err != nil && g.ctx.Err() == nil {
+			log.Printf("[Registry] WARN: failed to gossip to %s: %v", target, err)
+		}
+	}
+}
+
+// receive 接收并合并其他节点发来的成员表
+func (g *Gossip) receive() {
+	defer g.wg.Done()
+
+	buf := make([]byte, maxGossipPacket)
+	for {
+		n, _, err := g.conn.ReadFrom(buf)
+		if err != nil {
+			if g.ctx.Err() != nil {
+				return
+			}
+			log.Printf("[Registry] WARN: failed to read gossip message: %v", err)
+			continue
+		}
+
+		var msg gossipMessage
+		if err := json.Unmarshal(buf[:n], &msg); err != nil {
+			log.Printf("[Registry] WARN: invalid gossip message: %v", err)
+			continue
+		}
+		g.merge(msg.Members)
+	}
+}
+
+// merge 合并收到的成员表，心跳计数更大的记录覆盖本地记录
+// 本节点注册的实例只由本节点更新
+func (g *Gossip) merge(members []gossipMember) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	for _, remote := range members {
+		id := serviceID(remote.Service, remote.Addr)
+		if g.local[id] {
+			// 其他节点记录的心跳更大时（如本节点重启），跳过这些心跳使本地记录重新生效
+			if m := g.members[id]; remote.Heartbeat >= m.Heartbeat {
+				m.Heartbeat = remote.Heartbeat + 1
+			}
+			continue
+		}
+
+		m, ok := g.members[id]
+		if ok && remote.Heartbeat <= m.Heartbeat {
+			continue
+		}
+		if !ok && remote.Left {
+			continue
+		}
+		g.members[id] = &memberState{gossipMember: remote, updated: now}
+	}
+}
+
+// Close 广播本节点实例离开，停止后台任务并关闭监听
+func (g *Gossip) Close() error {
+	g.mu.Lock()
+	for id := range g.local {
+		m := g.members[id]
+		m.Heartbeat++
+		m.Left = true
+		delete(g.local, id)
+	}
+	g.mu.Unlock()
+
+	g.gossip()
+	g.cancel()
+	err := g.conn.Close()
+	g.wg.Wait()
+	return err
+}
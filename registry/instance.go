@@ -0,0 +1,77 @@
+package registry
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Instance 实例注册时写入 etcd 的信息，保存在实例 key 的值中
+//
+// 只有地址时值为地址本身，与旧版本的格式相同；带有元数据时编码为 JSON。
+type Instance struct {
+	Addr string `json:"addr"`
+	Zone string `json:"zone,omitempty"` // 实例所在的机房（可用区），用于就近读取和跨机房放置副本
+	Load *Load  `json:"load,omitempty"` // 最近一次发布的负载，未启用 WithLoadReport 时为 nil
+
+	Version  int      `json:"version,omitempty"`  // 节点间协议的版本，0 表示未知（旧版本节点）
+	Features []string `json:"features,omitempty"` // 节点支持的可选功能，如批量请求、流式获取和压缩
+}
+
+// Load 节点定期发布到注册信息中的负载和健康状态，ClientPicker 据此避开过载的节点
+type Load struct {
+	QPS        float64 `json:"qps"`                  // 最近一个发布周期内每秒处理的请求数
+	Memory     float64 `json:"memory"`               // 缓存内存使用率，0 到 1 之间
+	Healthy    bool    `json:"healthy"`              // 是否正在正常提供服务
+	Overloaded bool    `json:"overloaded,omitempty"` // 节点自身判断已过载，如最近丢弃过请求
+}
+
+// WithLoadReport 每隔 interval 调用 fn 获取负载，随注册信息一起写入 etcd
+// 写入使用注册的租约，不会延长实例的存活时间；interval 不大于 0 时不发布
+func WithLoadReport(interval time.Duration, fn func() Load) RegisterOption {
+	return func(o *registerOptions) {
+		o.loadInterval = interval
+		o.loadFn = fn
+	}
+}
+
+// WithVersion 设置实例的协议版本和支持的功能，随注册信息写入 etcd
+// 滚动升级期间 ClientPicker 据此在调用新增的 RPC 前判断对端是否支持，不支持时降级
+func WithVersion(version int, features ...string) RegisterOption {
+	return func(o *registerOptions) {
+		o.version = version
+		o.features = features
+	}
+}
+
+// WithZone 设置实例所在的机房（可用区），随注册信息写入 etcd，ClientPicker 据此就近选择副本
+func WithZone(zone string) RegisterOption {
+	return func(o *registerOptions) {
+		o.zone = zone
+	}
+}
+
+// ParseInstance 解析实例 key 的值，addr 为从 key 中解析出的地址
+// 值不是 JSON（旧版本只写入地址）或解析失败时只返回地址
+func ParseInstance(addr string, value []byte) Instance {
+	inst := Instance{Addr: addr}
+	if len(value) == 0 || value[0] != '{' {
+		return inst
+	}
+	if err := json.Unmarshal(value, &inst); err != nil {
+		return Instance{Addr: addr}
+	}
+	inst.Addr = addr
+	return inst
+}
+
+// encode 返回写入 etcd 的值
+func (i Instance) encode() string {
+	if i.Zone == "" && i.Load == nil && i.Version == 0 {
+		return i.Addr
+	}
+	data, err := json.Marshal(i)
+	if err != nil {
+		return i.Addr
+	}
+	return string(data)
+}
@@ -0,0 +1,147 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// errDiscoveryClosed 后端已关闭
+var errDiscoveryClosed = errors.New("registry: discovery closed")
+
+// Memory 进程内的 Discovery 实现，不依赖任何外部组件，适用于单元测试、集成测试和本地开发
+//
+// 同一进程中的多个 Server 和 ClientPicker 共用一个 Memory 即可组成集群，
+// Register 和 Deregister 立即通知所有 Watch。
+type Memory struct {
+	mu       sync.Mutex
+	services map[string]map[string]struct{} // 服务名到实例地址集合
+	watchers map[string]map[chan struct{}]struct{}
+	done     chan struct{}
+	closed   bool
+}
+
+var _ Discovery = (*Memory)(nil)
+
+// NewMemory 创建进程内的注册中心
+func NewMemory() *Memory {
+	return &Memory{
+		services: make(map[string]map[string]struct{}),
+		watchers: make(map[string]map[chan struct{}]struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Register 添加服务实例，重复注册时直接返回
+func (m *Memory) Register(ctx context.Context, svcName, addr string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return errDiscoveryClosed
+	}
+
+	addrs, ok := m.services[svcName]
+	if !ok {
+		addrs = make(map[string]struct{})
+		m.services[svcName] = addrs
+	}
+	if _, ok := addrs[addr]; ok {
+		return nil
+	}
+	addrs[addr] = struct{}{}
+	m.notify(svcName)
+	return nil
+}
+
+// Deregister 删除服务实例
+func (m *Memory) Deregister(ctx context.Context, svcName, addr string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return errDiscoveryClosed
+	}
+
+	if _, ok := m.services[svcName][addr]; !ok {
+		return nil
+	}
+	delete(m.services[svcName], addr)
+	m.notify(svcName)
+	return nil
+}
+
+// Addrs 返回服务当前的全部实例地址，已排序
+func (m *Memory) Addrs(svcName string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.addrs(svcName)
+}
+
+// Watch 以当前实例地址调用 onChange，之后每次变化时再次调用，直到 ctx 取消或 Close
+// 短时间内的多次变化可能合并为一次回调，回调的总是最新的地址列表
+func (m *Memory) Watch(ctx context.Context, svcName string, onChange func(addrs []string)) error {
+	ch := make(chan struct{}, 1)
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return errDiscoveryClosed
+	}
+	if m.watchers[svcName] == nil {
+		m.watchers[svcName] = make(map[chan struct{}]struct{})
+	}
+	m.watchers[svcName][ch] = struct{}{}
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		delete(m.watchers[svcName], ch)
+		m.mu.Unlock()
+	}()
+
+	last := m.Addrs(svcName)
+	onChange(last)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-m.done:
+			return errDiscoveryClosed
+		case <-ch:
+			if addrs := m.Addrs(svcName); !equalStrings(addrs, last) {
+				last = addrs
+				onChange(addrs)
+			}
+		}
+	}
+}
+
+// Close 停止所有 Watch，之后的 Register 和 Watch 返回错误
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.closed {
+		m.closed = true
+		close(m.done)
+	}
+	return nil
+}
+
+// addrs 返回排序后的实例地址，调用者必须持有 m.mu
+func (m *Memory) addrs(svcName string) []string {
+	list := make([]string, 0, len(m.services[svcName]))
+	for addr := range m.services[svcName] {
+		list = append(list, addr)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// notify 通知服务的所有 Watch，已有未处理的通知时不再重复发送，调用者必须持有 m.mu
+func (m *Memory) notify(svcName string) {
+	for ch := range m.watchers[svcName] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -2,92 +2,590 @@ package registry
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc"
 )
 
 // Config 定义etcd客户端配置
 type Config struct {
 	Endpoints   []string      // 集群地址
 	DialTimeout time.Duration // 连接超时时间
+
+	TLS      *tls.Config // 连接 etcd 使用的 TLS 配置，nil 表示明文连接，可由 LoadTLSConfig 创建
+	Username string      // etcd 用户名，启用认证的集群需要，与 Password 一起使用
+	Password string      // etcd 密码
+	Token    string      // 预先签发的认证 token（如 JWT），每个请求都会携带，与用户名密码二选一
+
+	// KeyPrefix 服务实例 key 的前缀，为空时使用 DefaultKeyPrefix
+	// 多个集群共用一个 etcd 时按环境区分，如 "/prod/services" 和 "/staging/services"，
+	// 注册和 ClientPicker 的发现都使用该前缀，不同前缀下的节点互不可见
+	KeyPrefix string
 }
 
 // DefaultConfig 提供默认配置
 var DefaultConfig = &Config{
 	Endpoints:   []string{"localhost:2379"},
 	DialTimeout: 5 * time.Second,
+	KeyPrefix:   DefaultKeyPrefix,
+}
+
+// Prefix 返回服务实例 key 的前缀
+func (c *Config) Prefix() string {
+	if c.KeyPrefix == "" {
+		return DefaultKeyPrefix
+	}
+	return c.KeyPrefix
+}
+
+// NewClient 按配置创建 etcd 客户端
+func (c *Config) NewClient() (*clientv3.Client, error) {
+	config := clientv3.Config{
+		Endpoints:   c.Endpoints,
+		DialTimeout: c.DialTimeout,
+		TLS:         c.TLS,
+		Username:    c.Username,
+		Password:    c.Password,
+	}
+	if c.Token != "" {
+		config.DialOptions = append(config.DialOptions,
+			grpc.WithPerRPCCredentials(etcdToken{token: c.Token, secure: c.TLS != nil}))
+	}
+	return clientv3.New(config)
+}
+
+// etcdToken 在每个请求的元数据中携带 etcd 认证 token
+type etcdToken struct {
+	token  string
+	secure bool
 }
 
-// Register 注册服务到etcd
-func Register(svcName, addr string, stopCh <-chan error) error {
-	cli, err := clientv3.New(clientv3.Config{
-		Endpoints:   DefaultConfig.Endpoints,
-		DialTimeout: DefaultConfig.DialTimeout,
-	})
+func (t etcdToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"token": t.token}, nil
+}
+
+func (t etcdToken) RequireTransportSecurity() bool {
+	return t.secure
+}
+
+// LoadTLSConfig 从文件加载连接 etcd 的 TLS 配置
+// caFile 为空时使用系统根证书；certFile 和 keyFile 为空时不出示客户端证书
+func LoadTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load etcd client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read etcd CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no valid certificates in %s", caFile)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// DefaultKeyPrefix 服务实例在 etcd 中的默认 key 前缀，完整的 key 为 "<prefix>/<svcName>/<addr>"
+const DefaultKeyPrefix = "/services"
+
+const (
+	// defaultLeaseTTL 默认的租约时间
+	defaultLeaseTTL = 10 * time.Second
+	// defaultKeepAliveRetries keepalive 中断后默认的重试次数
+	defaultKeepAliveRetries = 3
+	// defaultKeepAliveBackoff keepalive 重试的默认间隔
+	defaultKeepAliveBackoff = time.Second
+	// defaultReregisterBackoff 重新注册的初始退避时间
+	defaultReregisterBackoff = time.Second
+	// defaultReregisterMaxBackoff 重新注册的最大退避时间
+	defaultReregisterMaxBackoff = 30 * time.Second
+)
+
+// ErrLeaseLost 租约已过期或无法续约，服务实例已从 etcd 中消失
+var ErrLeaseLost = errors.New("registry: lease lost")
+
+// errRegistrationStopped 重试期间 stopCh 被关闭
+var errRegistrationStopped = errors.New("registry: registration stopped")
+
+// RegisterOption 定义 Register 的配置选项
+type RegisterOption func(*registerOptions)
+
+type registerOptions struct {
+	leaseTTL             time.Duration
+	keyPrefix            string
+	retries              int
+	retryBackoff         time.Duration
+	reregisterBackoff    time.Duration
+	reregisterMaxBackoff time.Duration
+	onLost               func(error)
+	onEvent              func(RegistrationEvent)
+	zone                 string
+	loadInterval         time.Duration
+	loadFn               func() Load
+	version              int
+	features             []string
+}
+
+// WithLeaseTTL 设置租约时间，节点异常退出后最多经过该时间从 etcd 中消失，默认 10s，不足 1s 按 1s 计算
+func WithLeaseTTL(ttl time.Duration) RegisterOption {
+	return func(o *registerOptions) {
+		o.leaseTTL = ttl
+	}
+}
+
+// WithKeyPrefix 设置注册使用的 key 前缀，默认为 DefaultConfig.KeyPrefix
+// 需与 ClientPicker 的 WithRegistryPrefix 一致，否则其他节点发现不了本节点
+func WithKeyPrefix(prefix string) RegisterOption {
+	return func(o *registerOptions) {
+		o.keyPrefix = prefix
+	}
+}
+
+// WithKeepAliveRetry 设置 keepalive 流中断（如 etcd 重启、网络抖动）后重新建立的次数和间隔
+// 租约仍然有效时沿用原租约；默认重试 3 次，间隔 1s
+func WithKeepAliveRetry(retries int, backoff time.Duration) RegisterOption {
+	return func(o *registerOptions) {
+		o.retries = retries
+		o.retryBackoff = backoff
+	}
+}
+
+// WithOnLost 设置租约丢失时的回调，err 可通过 errors.Is 与 ErrLeaseLost 比较
+// 回调在后台协程中调用，之后按 WithReregisterBackoff 自动重新注册
+func WithOnLost(fn func(err error)) RegisterOption {
+	return func(o *registerOptions) {
+		o.onLost = fn
+	}
+}
+
+// ServiceKey 返回服务实例在 etcd 中的 key
+func ServiceKey(prefix, svcName, addr string) string {
+	return ServicePrefix(prefix, svcName) + addr
+}
+
+// ServicePrefix 返回服务所有实例 key 的公共前缀，以 "/" 结尾，不会匹配到名称相同前缀的其他服务
+func ServicePrefix(prefix, svcName string) string {
+	return fmt.Sprintf("%s/%s/", strings.TrimSuffix(prefix, "/"), svcName)
+}
+
+// Register 注册服务到etcd，使用 DefaultConfig 中的地址、TLS 和认证配置
+// 关闭或向 stopCh 发送时撤销租约；租约丢失（如 etcd 重启、长时间网络中断）后自动以新租约重新注册
+func Register(svcName, addr string, stopCh <-chan error, opts ...RegisterOption) error {
+	o := newRegisterOptions(DefaultConfig.Prefix(), opts)
+
+	addr, err := resolveAddr(addr)
+	if err != nil {
+		return err
+	}
+
+	cli, err := DefaultConfig.NewClient()
 	if err != nil {
 		return fmt.Errorf("failed to create etcd client: %v", err)
 	}
 
-	localIP, err := getLocalIP()
+	r := newRegistration(cli, svcName, addr, o, DefaultConfig.DialTimeout)
+	r.stopCh = stopCh
+	keepAliveCh, err := r.register()
 	if err != nil {
 		cli.Close()
-		return fmt.Errorf("failed to get local IP: %v", err)
+		return err
+	}
+
+	activeMu.Lock()
+	active[r] = struct{}{}
+	activeMu.Unlock()
+	go r.run(keepAliveCh)
+
+	log.Printf("[Registry] Service registered: %s at %s", svcName, addr)
+	return nil
+}
+
+// newRegisterOptions 返回默认的注册选项并应用 opts
+func newRegisterOptions(prefix string, opts []RegisterOption) registerOptions {
+	o := registerOptions{
+		leaseTTL:     defaultLeaseTTL,
+		keyPrefix:    prefix,
+		retries:      defaultKeepAliveRetries,
+		retryBackoff: defaultKeepAliveBackoff,
+
+		reregisterBackoff:    defaultReregisterBackoff,
+		reregisterMaxBackoff: defaultReregisterMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// resolveAddr 地址只有端口（如 ":8001"）时补上本机 IP
+func resolveAddr(addr string) (string, error) {
+	if addr == "" || addr[0] != ':' {
+		return addr, nil
+	}
+	localIP, err := getLocalIP()
+	if err != nil {
+		return "", fmt.Errorf("failed to get local IP: %v", err)
+	}
+	return localIP + addr, nil
+}
+
+// RegistrationEventType 注册状态变化的类型
+type RegistrationEventType int
+
+const (
+	// EventLeaseLost 租约过期或无法续约，实例已从 etcd 中消失
+	EventLeaseLost RegistrationEventType = iota
+	// EventReregistered 租约丢失后重新注册成功
+	EventReregistered
+	// EventReregisterFailed 一次重新注册失败，之后继续按退避重试
+	EventReregisterFailed
+)
+
+func (t RegistrationEventType) String() string {
+	switch t {
+	case EventLeaseLost:
+		return "lease_lost"
+	case EventReregistered:
+		return "reregistered"
+	case EventReregisterFailed:
+		return "reregister_failed"
+	default:
+		return "unknown"
+	}
+}
+
+// RegistrationEvent 注册状态变化事件，可用于对注册反复丢失（flapping）告警
+type RegistrationEvent struct {
+	Type    RegistrationEventType
+	Key     string           // 实例在 etcd 中的 key
+	LeaseID clientv3.LeaseID // 重新注册成功时为新租约，其他情况为丢失的租约
+	Attempt int              // 本轮重新注册的第几次尝试，EventLeaseLost 时为 0
+	Err     error            // 失败原因，EventReregistered 时为 nil
+}
+
+// WithRegistrationEvents 设置注册状态变化的回调，在后台协程中同步调用，不应阻塞
+func WithRegistrationEvents(fn func(RegistrationEvent)) RegisterOption {
+	return func(o *registerOptions) {
+		o.onEvent = fn
 	}
-	if addr[0] == ':' {
-		addr = fmt.Sprintf("%s%s", localIP, addr)
+}
+
+// WithReregisterBackoff 设置租约丢失后重新注册的退避时间，每次失败后翻倍直到 max，默认 1s 到 30s
+func WithReregisterBackoff(initial, max time.Duration) RegisterOption {
+	return func(o *registerOptions) {
+		o.reregisterBackoff = initial
+		o.reregisterMaxBackoff = max
+	}
+}
+
+// registration 一个已注册的服务实例，负责续约、租约丢失后的重新注册和注销
+type registration struct {
+	cli     *clientv3.Client
+	svcName string
+	addr    string
+	key     string
+	opts    registerOptions
+	stopCh  <-chan error
+	leaseID clientv3.LeaseID
+
+	timeout      time.Duration // 单次注册（创建租约、写入 key）的超时时间
+	sharedClient bool          // cli 由调用方管理，退出时不关闭
+
+	quit      chan struct{} // Deregister 关闭，与 stopCh 作用相同
+	quitOnce  sync.Once
+	done      chan struct{} // 后台协程退出后关闭
+	revokeErr error         // 注销时删除 key 或撤销租约的错误，done 关闭后可读
+}
+
+var (
+	activeMu sync.Mutex
+	active   = make(map[*registration]struct{}) // 本进程中仍在续约的注册
+)
+
+// newRegistration 创建尚未注册的实例，dialTimeout 为 etcd 的连接超时时间
+func newRegistration(cli *clientv3.Client, svcName, addr string, o registerOptions, dialTimeout time.Duration) *registration {
+	return &registration{
+		cli:     cli,
+		svcName: svcName,
+		addr:    addr,
+		key:     ServiceKey(o.keyPrefix, svcName, addr),
+		opts:    o,
+		timeout: dialTimeout + 3*time.Second,
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
 	}
+}
+
+// register 创建租约、写入实例 key 并开始续约
+func (r *registration) register() (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
 
 	// 创建租约
-	lease, err := cli.Grant(context.Background(), 10) // 增加租约时间到10秒
+	ttl := int64((r.opts.leaseTTL + time.Second - 1) / time.Second)
+	if ttl < 1 {
+		ttl = 1
+	}
+	lease, err := r.cli.Grant(ctx, ttl)
 	if err != nil {
-		cli.Close()
-		return fmt.Errorf("failed to create lease: %v", err)
+		return nil, fmt.Errorf("failed to create lease: %v", err)
 	}
 
 	// 注册服务，使用完整的key路径
-	key := fmt.Sprintf("/services/%s/%s", svcName, addr)
-	_, err = cli.Put(context.Background(), key, addr, clientv3.WithLease(lease.ID))
-	if err != nil {
-		cli.Close()
-		return fmt.Errorf("failed to put key-value to etcd: %v", err)
+	if _, err := r.cli.Put(ctx, r.key, r.instance().encode(), clientv3.WithLease(lease.ID)); err != nil {
+		r.cli.Revoke(ctx, lease.ID)
+		return nil, fmt.Errorf("failed to put key-value to etcd: %v", err)
 	}
 
 	// 保持租约
-	keepAliveCh, err := cli.KeepAlive(context.Background(), lease.ID)
+	keepAliveCh, err := r.cli.KeepAlive(context.Background(), lease.ID)
 	if err != nil {
-		cli.Close()
-		return fmt.Errorf("failed to keep lease alive: %v", err)
+		r.cli.Revoke(ctx, lease.ID)
+		return nil, fmt.Errorf("failed to keep lease alive: %v", err)
 	}
+	r.leaseID = lease.ID
+	return keepAliveCh, nil
+}
 
-	// 处理租约续期和服务注销
-	go func() {
-		defer cli.Close()
-		for {
-			select {
-			case <-stopCh:
-				// 服务注销，撤销租约
-				ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-				cli.Revoke(ctx, lease.ID)
-				cancel()
-				return
-			case resp, ok := <-keepAliveCh:
-				if !ok {
-					log.Printf("[Registry] WARN: keep alive channel closed")
-					return
-				}
+// run 处理租约续期和服务注销，租约丢失时自动重新注册
+func (r *registration) run(keepAliveCh <-chan *clientv3.LeaseKeepAliveResponse) {
+	defer func() {
+		activeMu.Lock()
+		delete(active, r)
+		activeMu.Unlock()
+		if !r.sharedClient {
+			r.cli.Close()
+		}
+		close(r.done)
+	}()
+
+	var loadTick <-chan time.Time
+	if r.opts.loadFn != nil && r.opts.loadInterval > 0 {
+		ticker := time.NewTicker(r.opts.loadInterval)
+		defer ticker.Stop()
+		loadTick = ticker.C
+	}
+
+	for {
+		select {
+		case <-r.stopCh:
+			r.revokeErr = r.revoke()
+			return
+		case <-r.quit:
+			r.revokeErr = r.revoke()
+			return
+		case <-loadTick:
+			r.publishLoad()
+			continue
+		case resp, ok := <-keepAliveCh:
+			if ok {
 				log.Printf("[Registry] DEBUG: successfully renewed lease: %d", resp.ID)
+				continue
 			}
 		}
-	}()
 
-	log.Printf("[Registry] Service registered: %s at %s", svcName, addr)
+		log.Printf("[Registry] WARN: keep alive channel closed, retrying")
+		var err error
+		keepAliveCh, err = r.keepAliveAgain()
+		if errors.Is(err, errRegistrationStopped) {
+			r.revokeErr = r.revoke()
+			return
+		}
+		if err == nil {
+			continue
+		}
+
+		log.Printf("[Registry] ERROR: %s at %s is no longer registered: %v", r.svcName, r.addr, err)
+		r.emit(RegistrationEvent{Type: EventLeaseLost, Err: err})
+		if r.opts.onLost != nil {
+			r.opts.onLost(err)
+		}
+		if keepAliveCh, err = r.reregister(); err != nil {
+			// 重新注册期间 stopCh 被关闭，此时没有有效的租约
+			return
+		}
+	}
+}
+
+// instance 返回写入 etcd 的实例信息，启用负载发布时包含当前负载
+func (r *registration) instance() Instance {
+	inst := Instance{Addr: r.addr, Zone: r.opts.zone, Version: r.opts.version, Features: r.opts.features}
+	if r.opts.loadFn != nil && r.opts.loadInterval > 0 {
+		load := r.opts.loadFn()
+		inst.Load = &load
+	}
+	return inst
+}
+
+// publishLoad 使用当前租约重新写入实例信息，更新其中的负载
+func (r *registration) publishLoad() {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if _, err := r.cli.Put(ctx, r.key, r.instance().encode(), clientv3.WithLease(r.leaseID)); err != nil {
+		log.Printf("[Registry] WARN: failed to publish load for %s: %v", r.addr, err)
+	}
+}
+
+// reregister 按退避时间重新注册，直到成功或 stopCh 关闭
+func (r *registration) reregister() (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+	backoff := r.opts.reregisterBackoff
+	for attempt := 1; ; attempt++ {
+		if !r.wait(backoff) {
+			return nil, errRegistrationStopped
+		}
+
+		ch, err := r.register()
+		if err == nil {
+			log.Printf("[Registry] Service re-registered: %s at %s (attempt %d)", r.svcName, r.addr, attempt)
+			r.emit(RegistrationEvent{Type: EventReregistered, Attempt: attempt})
+			return ch, nil
+		}
+
+		log.Printf("[Registry] WARN: failed to re-register %s at %s (attempt %d): %v", r.svcName, r.addr, attempt, err)
+		r.emit(RegistrationEvent{Type: EventReregisterFailed, Attempt: attempt, Err: err})
+		if backoff *= 2; backoff > r.opts.reregisterMaxBackoff {
+			backoff = r.opts.reregisterMaxBackoff
+		}
+	}
+}
+
+// emit 调用注册事件回调
+func (r *registration) emit(event RegistrationEvent) {
+	if r.opts.onEvent == nil {
+		return
+	}
+	event.Key = r.key
+	event.LeaseID = r.leaseID
+	r.opts.onEvent(event)
+}
+
+// stop 停止续约并等待注销完成，返回删除 key 或撤销租约的错误
+func (r *registration) stop() error {
+	r.quitOnce.Do(func() { close(r.quit) })
+	<-r.done
+	return r.revokeErr
+}
+
+// wait 等待 d，期间注册被停止时返回 false
+func (r *registration) wait(d time.Duration) bool {
+	select {
+	case <-r.stopCh:
+		return false
+	case <-r.quit:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// revoke 删除实例 key 并撤销租约，实例立即从 etcd 中消失
+func (r *registration) revoke() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if _, err := r.cli.Delete(ctx, r.key); err != nil {
+		return fmt.Errorf("failed to delete %s: %v", r.key, err)
+	}
+	if _, err := r.cli.Revoke(ctx, r.leaseID); err != nil {
+		return fmt.Errorf("failed to revoke lease %d: %v", r.leaseID, err)
+	}
+	return nil
+}
+
+// Deregister 立即注销服务实例：删除实例 key 并撤销租约，停止后台续约，
+// 使正在下线的节点马上不再接收流量，而不必等待租约过期
+//
+// 本进程中没有通过 Register 注册该实例时，直接删除 DefaultConfig.KeyPrefix 下的 key，可用于清理残留的注册。
+func Deregister(svcName, addr string) error {
+	addr, err := resolveAddr(addr)
+	if err != nil {
+		return err
+	}
+
+	activeMu.Lock()
+	var regs []*registration
+	for r := range active {
+		if r.svcName == svcName && r.addr == addr {
+			regs = append(regs, r)
+		}
+	}
+	activeMu.Unlock()
+
+	if len(regs) == 0 {
+		cli, err := DefaultConfig.NewClient()
+		if err != nil {
+			return fmt.Errorf("failed to create etcd client: %v", err)
+		}
+		defer cli.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		if _, err := cli.Delete(ctx, ServiceKey(DefaultConfig.Prefix(), svcName, addr)); err != nil {
+			return fmt.Errorf("failed to delete service key: %v", err)
+		}
+		return nil
+	}
+
+	var errs []error
+	for _, r := range regs {
+		if err := r.stop(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	log.Printf("[Registry] Service deregistered: %s at %s", svcName, addr)
 	return nil
 }
 
+// keepAliveAgain 在租约仍然有效时重新建立 keepalive 流，重试用尽或租约已过期时返回 ErrLeaseLost，
+// stopCh 关闭时返回 errRegistrationStopped
+func (r *registration) keepAliveAgain() (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+	lastErr := errors.New("keep alive stream closed")
+	for attempt := 1; attempt <= r.opts.retries; attempt++ {
+		if !r.wait(r.opts.retryBackoff) {
+			return nil, errRegistrationStopped
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), r.opts.retryBackoff+3*time.Second)
+		resp, err := r.cli.TimeToLive(ctx, r.leaseID)
+		cancel()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.TTL <= 0 {
+			return nil, fmt.Errorf("%w: lease %d expired", ErrLeaseLost, r.leaseID)
+		}
+
+		ch, err := r.cli.KeepAlive(context.Background(), r.leaseID)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		log.Printf("[Registry] keep alive re-established for lease %d", r.leaseID)
+		return ch, nil
+	}
+	return nil, fmt.Errorf("%w: %v", ErrLeaseLost, lastErr)
+}
+
 func getLocalIP() (string, error) {
 	addrs, err := net.InterfaceAddrs()
 	if err != nil {
@@ -0,0 +1,43 @@
+package registry
+
+import (
+	"context"
+	"sort"
+)
+
+// Static 固定节点列表的 Discovery 实现，适用于测试和节点不变的部署
+// Register 和 Deregister 不做任何事，Watch 只回调一次固定的地址列表
+type Static struct {
+	addrs []string
+}
+
+var _ Discovery = (*Static)(nil)
+
+// NewStatic 创建使用固定节点列表的后端
+func NewStatic(addrs ...string) *Static {
+	sorted := append([]string(nil), addrs...)
+	sort.Strings(sorted)
+	return &Static{addrs: sorted}
+}
+
+// Register 固定节点列表不需要注册
+func (s *Static) Register(ctx context.Context, svcName, addr string) error {
+	return nil
+}
+
+// Deregister 固定节点列表不需要注销
+func (s *Static) Deregister(ctx context.Context, svcName, addr string) error {
+	return nil
+}
+
+// Watch 以固定的地址列表调用一次 onChange，然后阻塞到 ctx 取消
+func (s *Static) Watch(ctx context.Context, svcName string, onChange func(addrs []string)) error {
+	onChange(append([]string(nil), s.addrs...))
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// Close 没有需要释放的资源
+func (s *Static) Close() error {
+	return nil
+}
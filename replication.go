@@ -0,0 +1,95 @@
+package mycache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// WithReplicationFactor 设置副本数量
+//
+// Set/Delete 会异步同步到 owner 以及环上后续的 n-1 个副本节点；从 owner 获取数据时
+// 如果 owner 不可达，依次尝试各副本节点，使单个节点故障期间仍能读到数据。
+// 与 WithConsistency 共用同一个副本数量。需要 PeerPicker 实现 ReplicaPicker 接口。
+func WithReplicationFactor(n int) GroupOption {
+	return func(g *Group) {
+		g.replicas = n
+	}
+}
+
+// syncToReplicas 将写操作并发同步到 key 的所有远程副本，不支持多副本时返回 false
+// 只有 owner 同步失败时才保存提示，其他副本在下次写入或读修复时收敛
+func (g *Group) syncToReplicas(op string, key string, value []byte, expire time.Time) bool {
+	replicaPicker, ok := g.peers.(ReplicaPicker)
+	if !ok {
+		return false
+	}
+	replicas := replicaPicker.PickPeers(key, g.replicas)
+	if len(replicas) == 0 {
+		return true
+	}
+
+	syncCtx := withWriteExpire(context.WithValue(context.Background(), "from_peer", true), expire)
+	var wg sync.WaitGroup
+	for i, peer := range replicas {
+		wg.Add(1)
+		go func(i int, peer Peer) {
+			defer wg.Done()
+
+			var err error
+			switch op {
+			case "set":
+				err = peer.Set(syncCtx, g.name, key, value)
+			case "delete":
+				_, err = peer.Delete(syncCtx, g.name, key)
+			}
+			if err == nil {
+				if i == 0 {
+					g.clearHint(key)
+				}
+				return
+			}
+
+			if i == 0 && !errors.Is(err, ErrValueTooLarge) && g.addHint(op, key, value, expire) {
+				log.Printf("[MyCache] failed to sync %s to owner, queued for handoff: %v", op, err)
+				return
+			}
+			log.Printf("[MyCache] failed to sync %s to replica %d: %v", op, i, err)
+		}(i, peer)
+	}
+	wg.Wait()
+	return true
+}
+
+// fetchFromReplicas owner 不可达时依次从其他副本节点获取数据，全部失败时返回最后一个错误
+func (g *Group) fetchFromReplicas(ctx context.Context, owner Peer, key string, ownerErr error) (ByteView, error) {
+	replicaPicker, ok := g.peers.(ReplicaPicker)
+	if !ok {
+		return ByteView{}, ownerErr
+	}
+
+	replicas := replicaPicker.PickPeers
+	if loadAware, ok := g.peers.(LoadAwarePicker); ok {
+		// 过载的副本放在最后尝试
+		replicas = loadAware.PickReadReplicas
+	}
+
+	lastErr := ownerErr
+	for _, peer := range replicas(key, g.replicas) {
+		if peer == owner {
+			continue
+		}
+		view, err := g.fetchFromPeer(ctx, peer, key)
+		if err == nil {
+			g.stats.replicaReads.Add(1)
+			return view, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return ByteView{}, lastErr
+}
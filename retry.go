@@ -0,0 +1,99 @@
+package mycache
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// loadRetryMaxBackoff 数据源加载重试的最大等待时间，首次等待时间更长时以首次为准
+const loadRetryMaxBackoff = 2 * time.Second
+
+// loadRetryPolicy 数据源加载的重试策略
+type loadRetryPolicy struct {
+	attempts   int              // 最大尝试次数（包含首次），小于等于 1 表示不重试
+	backoff    time.Duration    // 首次重试前的等待时间，之后每次翻倍
+	maxBackoff time.Duration    // 重试等待时间的上限
+	retryable  func(error) bool // 判断错误是否可重试，nil 表示所有错误都可重试
+}
+
+// shouldRetry 判断第 attempt 次尝试失败后是否继续重试
+func (p loadRetryPolicy) shouldRetry(attempt int, err error) bool {
+	if attempt >= p.attempts {
+		return false
+	}
+	return p.retryable == nil || p.retryable(err)
+}
+
+// WithLoadRetry 设置数据源加载的重试策略
+//
+// 重试发生在 SingleFlight 保护的加载过程内部，数据源的短暂故障不会让所有合并等待的
+// 请求同时失败。backoff 为首次重试前的等待时间，之后每次翻倍，最多为 2s 和 backoff 中较大者；
+// retryable 为 nil 时所有错误都会重试。
+func WithLoadRetry(attempts int, backoff time.Duration, retryable func(error) bool) GroupOption {
+	return func(g *Group) {
+		g.loadRetry = loadRetryPolicy{
+			attempts:   attempts,
+			backoff:    backoff,
+			maxBackoff: max(backoff, loadRetryMaxBackoff),
+			retryable:  retryable,
+		}
+	}
+}
+
+// RetryPolicy 节点间 RPC 的重试策略
+type RetryPolicy struct {
+	MaxAttempts         int           // 最大尝试次数（包含首次），小于等于 1 表示不重试
+	InitialBackoff      time.Duration // 首次重试前的等待时间，之后每次翻倍
+	MaxBackoff          time.Duration // 重试等待时间的上限，0 表示不限制
+	RetryOnlyIdempotent bool          // 只重试幂等操作（Get/Delete），Set 不重试
+}
+
+// DefaultRetryPolicy 默认的重试策略，用于节点重启期间的短暂不可用
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:         3,
+	InitialBackoff:      50 * time.Millisecond,
+	MaxBackoff:          time.Second,
+	RetryOnlyIdempotent: true,
+}
+
+// backoff 返回第 attempt 次失败后的等待时间
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return d
+}
+
+// isRetryableRPCError 判断 RPC 错误是否为可重试的暂时性错误
+func isRetryableRPCError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleepContext 等待 d 时长，ctx 被取消时提前返回 ctx 的错误
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -5,8 +5,9 @@ import (
 	"crypto/tls"
 	"fmt"
 	"log"
-	"net"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	pb "github.com/linhx1999/MyCache-Go/pb"
@@ -16,28 +17,101 @@ import (
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/health"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
 )
 
 // Server 定义缓存服务器
 type Server struct {
 	pb.UnimplementedCacheServiceServer
-	addr       string           // 服务地址
-	svcName    string           // 服务名称
-	groups     *sync.Map        // 缓存组
-	grpcServer *grpc.Server     // gRPC服务器
-	etcdCli    *clientv3.Client // etcd客户端
-	stopCh     chan error       // 停止信号
-	opts       *ServerOptions   // 服务器选项
+	addr        string           // 服务地址
+	svcName     string           // 服务名称
+	groups      *sync.Map        // 对外提供的缓存组，见 RegisterGroup
+	grpcServer  *grpc.Server     // gRPC服务器
+	etcdCli     *clientv3.Client // etcd客户端
+	stopCh      chan error       // 停止信号，关闭时撤销 etcd 租约
+	health      *health.Server   // 健康检查服务
+	startedAt   time.Time        // 开始提供服务的时间
+	ready       atomic.Bool      // 是否已就绪，见 SetReady
+	serving     atomic.Bool      // 健康检查服务当前是否报告 SERVING
+	opts        *ServerOptions   // 服务器选项
+	shedder     *loadShedder     // 过载保护
+	tenants     *tenantManager   // 多租户命名空间和配额，未启用时为 nil
+	netPolicy   *netPolicy       // 连接的网段策略，未配置时为 nil
+	httpServer  *http.Server     // REST 接口，未设置 HTTPAddr 时为 nil
+	adminServer *http.Server     // 管理接口，未设置 AdminAddr 时为 nil
+
+	metricsServer *http.Server // /metrics 接口，未设置 MetricsAddr 时为 nil
+	rpcMetrics    *rpcMetrics  // RPC 请求统计，未设置 MetricsAddr 时为 nil
+
+	coordinator *registry.Coordinator // 协调者选举，没有协调者任务时为 nil
+
+	mu         sync.Mutex // 保护注册和关闭，避免关闭与 Start 中的注册交错
+	registered bool       // 是否已注册到服务发现（Discovery 或 etcd）
+	shutdown   bool       // 是否已开始关闭
 }
 
 // ServerOptions 服务器配置选项
 type ServerOptions struct {
 	EtcdEndpoints []string      // etcd端点
 	DialTimeout   time.Duration // 连接超时
-	MaxMsgSize    int           // 最大消息大小
+	MaxMsgSize    int           // 接收消息的大小上限
 	TLS           bool          // 是否启用TLS
 	CertFile      string        // 证书文件
 	KeyFile       string        // 密钥文件
+	ClientCAFile  string        // 校验客户端证书的 CA，设置后要求客户端出示证书
+	TLSConfig     *tls.Config   // 自定义 TLS 配置，设置后忽略 TLS、CertFile、KeyFile 和 ClientCAFile
+
+	MaxSendMsgSize int // 发送消息的大小上限，0 使用 gRPC 默认值
+
+	Auth      AuthFunc         // 请求认证函数，nil 表示不认证
+	Tenants   *tenantConfig    // 多租户配额，nil 表示不启用
+	Tracing   *tracing         // 链路追踪，nil 表示不启用
+	AccessLog *AccessLogConfig // 访问日志配置，nil 表示不记录
+	KeyPolicy *KeyPolicy       // 所有组共用的 key 和 value 限制，nil 表示不限制
+
+	UnaryInterceptors  []grpc.UnaryServerInterceptor  // 用户追加的一元请求拦截器
+	StreamInterceptors []grpc.StreamServerInterceptor // 用户追加的流式请求拦截器
+	GRPCOptions        []grpc.ServerOption            // 用户追加的 gRPC 服务器选项
+
+	AdvertiseAddr   string             // 注册到服务发现、供其他节点访问的地址，为空时使用监听地址
+	DisableRegistry bool               // 不连接 etcd、不注册服务，配合 NewStaticPicker 使用
+	Discovery       registry.Discovery // 服务注册后端，设置后替代 etcd 注册
+
+	RegisterOptions []registry.RegisterOption // 注册到 etcd 的选项，如租约时间和 keepalive 重试策略
+
+	LoadReportInterval time.Duration // 发布负载到 etcd 注册信息的间隔，0 表示不发布
+
+	CoordinatorTasks   []coordinatorTask            // 只在集群协调者上运行的后台任务
+	CoordinatorOptions []registry.CoordinatorOption // 协调者选举的选项
+
+	Keepalive       keepalive.ServerParameters  // 服务端 keepalive 参数，零值使用 gRPC 默认值
+	KeepalivePolicy keepalive.EnforcementPolicy // 客户端 keepalive 的约束策略，零值使用 gRPC 默认值
+
+	ShedThreshold int // 正在处理的请求数达到该值时丢弃后台优先级的请求，0 表示不限制
+
+	MaxConcurrentStreams uint32 // 每个连接上同时进行的 RPC 数量上限，0 使用 gRPC 默认值
+	MaxConnections       int    // 同时打开的连接数上限，0 表示不限制
+	RequestWorkers       int    // 处理请求的 worker 数量，同时也是并发执行的请求数上限，0 表示不限制
+
+	AllowedNetworks []string // 只接受来自这些网段的连接，为空表示不限制
+	DeniedNetworks  []string // 拒绝来自这些网段的连接，优先于 AllowedNetworks
+
+	HTTPAddr   string // REST 接口的监听地址，为空表示不启用
+	AdminAddr  string // 管理接口的监听地址，为空表示不启用
+	AdminToken string // 访问管理接口需要的 token
+	AdminDebug bool   // 在管理接口上启用 pprof 和 expvar
+
+	MetricsAddr string // Prometheus /metrics 接口的监听地址，为空表示不启用
+
+	ShutdownTransferKeys int // 优雅关闭时每个组最多发送给下一个 owner 的 key 数量，0 表示不发送
+
+	BackupRoot string // 集群备份写入的根目录，Backup 请求中的目录在其下解析，为空时拒绝备份
+
+	Readiness         ReadinessFunc // 就绪检查，返回错误时报告 NOT_SERVING
+	ReadinessInterval time.Duration // 就绪状态的检查间隔，0 表示不定期检查
+	StartNotReady     bool          // 启动后报告 NOT_SERVING，直到调用 SetReady(true)
 }
 
 // DefaultServerOptions 默认配置
@@ -65,6 +139,7 @@ func WithDialTimeout(timeout time.Duration) ServerOption {
 }
 
 // WithTLS 设置TLS配置
+// 证书文件修改后会自动重新加载；配合 WithClientCA 可要求客户端出示证书（双向认证）
 func WithTLS(certFile, keyFile string) ServerOption {
 	return func(o *ServerOptions) {
 		o.TLS = true
@@ -73,10 +148,77 @@ func WithTLS(certFile, keyFile string) ServerOption {
 	}
 }
 
+// WithAdvertiseAddr 设置注册到服务发现的地址（"host:port"），与监听地址分离
+//
+// 监听 "0.0.0.0:8001" 或运行在容器、NAT 之后时，其他节点需要通过另一个地址访问本节点。
+// 创建 ClientPicker 时应使用同一个地址（见 Server.AdvertiseAddr），使本节点在哈希环上的标识一致。
+func WithAdvertiseAddr(addr string) ServerOption {
+	return func(o *ServerOptions) {
+		o.AdvertiseAddr = addr
+	}
+}
+
+// WithRegisterOptions 设置注册到 etcd 的选项，如 registry.WithLeaseTTL、registry.WithOnLost
+func WithRegisterOptions(opts ...registry.RegisterOption) ServerOption {
+	return func(o *ServerOptions) {
+		o.RegisterOptions = append(o.RegisterOptions, opts...)
+	}
+}
+
+// WithoutRegistry 不向 etcd 注册服务，用于没有 etcd 的静态节点部署
+func WithoutRegistry() ServerOption {
+	return func(o *ServerOptions) {
+		o.DisableRegistry = true
+	}
+}
+
+// WithDiscovery 使用指定的服务发现后端（如 registry.NewConsul）注册服务，替代默认的 etcd
+func WithDiscovery(d registry.Discovery) ServerOption {
+	return func(o *ServerOptions) {
+		o.Discovery = d
+	}
+}
+
+// WithServerKeepalive 设置服务端 keepalive 参数和客户端 keepalive 的约束策略
+//
+// 服务端定期 ping 空闲连接，超时未响应时关闭连接，使经过 NAT/负载均衡器的半开连接
+// 能被及时发现。policy.MinTime 需不大于客户端的 keepalive 间隔（见 WithClientKeepalive），
+// 否则客户端的 ping 会被视为滥用而断开连接。
+func WithServerKeepalive(params keepalive.ServerParameters, policy keepalive.EnforcementPolicy) ServerOption {
+	return func(o *ServerOptions) {
+		o.Keepalive = params
+		o.KeepalivePolicy = policy
+	}
+}
+
+// WithServerUnaryInterceptors 追加一元请求的服务端拦截器，如 panic 恢复、链路追踪等
+// 拦截器按添加顺序执行，在内置的指标、访问日志、认证和过载保护之后、业务处理之前调用
+func WithServerUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) ServerOption {
+	return func(o *ServerOptions) {
+		o.UnaryInterceptors = append(o.UnaryInterceptors, interceptors...)
+	}
+}
+
+// WithServerStreamInterceptors 追加流式请求的服务端拦截器，执行顺序同 WithServerUnaryInterceptors
+func WithServerStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) ServerOption {
+	return func(o *ServerOptions) {
+		o.StreamInterceptors = append(o.StreamInterceptors, interceptors...)
+	}
+}
+
+// WithGRPCServerOptions 追加创建 gRPC 服务器时使用的选项，如并发流数量、连接窗口大小等
+// 追加的选项在内置选项之后应用，可以覆盖内置的设置
+func WithGRPCServerOptions(opts ...grpc.ServerOption) ServerOption {
+	return func(o *ServerOptions) {
+		o.GRPCOptions = append(o.GRPCOptions, opts...)
+	}
+}
+
 // NewServer 创建一个新的缓存服务器实例。
 //
 // 参数：
-//   - addr: 监听地址，格式为 "host:port"，如 ":8001" 或 "0.0.0.0:8001"
+//   - addr: 监听地址，格式为 "host:port"，如 ":8001" 或 "0.0.0.0:8001"；
+//     注册地址与监听地址不同时使用 WithAdvertiseAddr
 //   - svcName: 服务名称，用于 etcd 注册和集群标识
 //   - opts: 可选的配置选项，如 WithEtcdEndpoints、WithTLS 等
 //
@@ -99,23 +241,40 @@ func WithTLS(certFile, keyFile string) ServerOption {
 //	if err != nil {
 //	    log.Fatal(err)
 //	}
+//	srv.RegisterGroup(group)
+//
+// 服务器只对外提供通过 RegisterGroup 注册的组
 func NewServer(addr, svcName string, opts ...ServerOption) (*Server, error) {
 	// 从默认配置开始，应用用户传入的选项函数
 	// 这种 Functional Options 模式允许用户只设置需要的选项，其余使用默认值
-	options := DefaultServerOptions
+	// 复制默认配置，避免修改全局的 DefaultServerOptions 影响之后创建的服务器
+	options := *DefaultServerOptions
 	for _, opt := range opts {
-		opt(options)
+		opt(&options)
+	}
+
+	policy, err := newNetPolicy(options.AllowedNetworks, options.DeniedNetworks)
+	if err != nil {
+		return nil, err
 	}
 
 	// 创建 etcd 客户端，用于服务注册和发现
 	// Endpoints: etcd 集群的节点地址列表
 	// DialTimeout: 连接超时时间，防止无限等待
-	etcdCli, err := clientv3.New(clientv3.Config{
-		Endpoints:   options.EtcdEndpoints,
-		DialTimeout: options.DialTimeout,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to create etcd client: %v", err)
+	// 静态节点模式下不需要 etcd
+	if len(options.CoordinatorTasks) > 0 && (options.DisableRegistry || options.Discovery != nil) {
+		return nil, errCoordinatorNeedsEtcd
+	}
+	var etcdCli *clientv3.Client
+	if !options.DisableRegistry && options.Discovery == nil {
+		// TLS 和认证配置与服务注册一样取自 registry.DefaultConfig
+		etcdConfig := *registry.DefaultConfig
+		etcdConfig.Endpoints = options.EtcdEndpoints
+		etcdConfig.DialTimeout = options.DialTimeout
+		etcdCli, err = etcdConfig.NewClient()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create etcd client: %v", err)
+		}
 	}
 
 	// 配置 gRPC 服务器选项
@@ -123,17 +282,133 @@ func NewServer(addr, svcName string, opts ...ServerOption) (*Server, error) {
 	// 设置最大接收消息大小，防止缓存值过大导致请求失败
 	// 默认值 4MB，可通过 WithMaxMsgSize 选项调整
 	serverOpts = append(serverOpts, grpc.MaxRecvMsgSize(options.MaxMsgSize))
+	if options.MaxSendMsgSize > 0 {
+		serverOpts = append(serverOpts, grpc.MaxSendMsgSize(options.MaxSendMsgSize))
+	}
 
 	// 如果启用 TLS，加载证书并配置加密传输
 	// TLS 配置确保节点间通信的安全性，防止数据被窃听或篡改
-	if options.TLS {
-		creds, err := loadTLSCredentials(options.CertFile, options.KeyFile)
+	if options.TLSConfig != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(options.TLSConfig)))
+	} else if options.TLS {
+		creds, err := loadTLSCredentials(options.CertFile, options.KeyFile, options.ClientCAFile)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load TLS credentials: %v", err)
 		}
 		serverOpts = append(serverOpts, grpc.Creds(creds))
 	}
 
+	// 配置 keepalive，及时发现并关闭半开连接
+	if options.Keepalive != (keepalive.ServerParameters{}) {
+		serverOpts = append(serverOpts, grpc.KeepaliveParams(options.Keepalive))
+	}
+	if options.KeepalivePolicy != (keepalive.EnforcementPolicy{}) {
+		serverOpts = append(serverOpts, grpc.KeepaliveEnforcementPolicy(options.KeepalivePolicy))
+	}
+
+	// 限制并发，连接风暴下排队等待而不是无限制地创建 goroutine
+	if options.MaxConcurrentStreams > 0 {
+		serverOpts = append(serverOpts, grpc.MaxConcurrentStreams(options.MaxConcurrentStreams))
+	}
+	if options.RequestWorkers > 0 {
+		serverOpts = append(serverOpts, grpc.NumStreamWorkers(uint32(options.RequestWorkers)))
+	}
+
+	// 在响应头中返回协议版本和支持的功能，被拒绝的请求也携带，供客户端协商
+	serverOpts = append(serverOpts,
+		grpc.ChainUnaryInterceptor(versionUnaryServerInterceptor),
+		grpc.ChainStreamInterceptor(versionStreamServerInterceptor),
+	)
+
+	// 创建服务端 span，在所有拦截器之前执行，使被拒绝的请求也出现在链路中
+	if options.Tracing != nil {
+		serverOpts = append(serverOpts,
+			grpc.ChainUnaryInterceptor(options.Tracing.unaryServerInterceptor),
+			grpc.ChainStreamInterceptor(options.Tracing.streamServerInterceptor),
+		)
+	}
+
+	// 统计 RPC 请求数和耗时，在过载保护之前执行以便统计被丢弃的请求
+	var metrics *rpcMetrics
+	if options.MetricsAddr != "" {
+		metrics = newRPCMetrics()
+		serverOpts = append(serverOpts,
+			grpc.ChainUnaryInterceptor(metrics.unaryInterceptor),
+			grpc.ChainStreamInterceptor(metrics.streamInterceptor),
+		)
+	}
+
+	// 记录访问日志，包括未通过认证和被丢弃的请求
+	if options.AccessLog != nil {
+		accessLog := newAccessLogger(*options.AccessLog)
+		serverOpts = append(serverOpts,
+			grpc.ChainUnaryInterceptor(accessLog.unaryInterceptor),
+			grpc.ChainStreamInterceptor(accessLog.streamInterceptor),
+		)
+	}
+
+	// 认证请求，未通过认证的请求不占用过载保护的配额
+	if options.Auth != nil {
+		serverOpts = append(serverOpts,
+			grpc.ChainUnaryInterceptor(authUnaryInterceptor(options.Auth)),
+			grpc.ChainStreamInterceptor(authStreamInterceptor(options.Auth)),
+		)
+	}
+
+	// 拒绝违反 key 和 value 限制的请求，在加上租户前缀之前校验
+	if options.KeyPolicy != nil {
+		serverOpts = append(serverOpts,
+			grpc.ChainUnaryInterceptor(options.KeyPolicy.unaryInterceptor),
+			grpc.ChainStreamInterceptor(options.KeyPolicy.streamInterceptor),
+		)
+	}
+
+	// 为租户的请求加上命名空间并检查配额，依赖认证时附加的租户标识
+	var tenants *tenantManager
+	if options.Tenants != nil {
+		tenants = newTenantManager(*options.Tenants)
+		serverOpts = append(serverOpts,
+			grpc.ChainUnaryInterceptor(tenants.unaryInterceptor),
+			grpc.ChainStreamInterceptor(tenants.streamInterceptor),
+		)
+	} else {
+		// 未启用多租户时同样不允许普通客户端使用租户命名空间前缀的 key
+		serverOpts = append(serverOpts,
+			grpc.ChainUnaryInterceptor(reservedKeyUnaryInterceptor),
+			grpc.ChainStreamInterceptor(reservedKeyStreamInterceptor),
+		)
+	}
+
+	// 解析请求优先级和截止时间，过载时丢弃后台请求
+	shedder := &loadShedder{threshold: int64(options.ShedThreshold)}
+	serverOpts = append(serverOpts,
+		grpc.ChainUnaryInterceptor(shedder.unaryInterceptor),
+		grpc.ChainStreamInterceptor(shedder.streamInterceptor),
+	)
+
+	// 在过载保护之后排队等待空闲的 worker，使排队的请求也计入正在处理的请求数
+	if options.RequestWorkers > 0 {
+		workers := newWorkerLimiter(options.RequestWorkers)
+		serverOpts = append(serverOpts,
+			grpc.ChainUnaryInterceptor(workers.unaryInterceptor),
+			grpc.ChainStreamInterceptor(workers.streamInterceptor),
+		)
+	}
+
+	// 用户追加的拦截器和选项
+	if len(options.UnaryInterceptors) > 0 {
+		serverOpts = append(serverOpts, grpc.ChainUnaryInterceptor(options.UnaryInterceptors...))
+	}
+	if len(options.StreamInterceptors) > 0 {
+		serverOpts = append(serverOpts, grpc.ChainStreamInterceptor(options.StreamInterceptors...))
+	}
+	// 将处理函数返回的错误转换为带错误详情的 gRPC 状态，在所有拦截器之后执行
+	serverOpts = append(serverOpts,
+		grpc.ChainUnaryInterceptor(statusUnaryInterceptor),
+		grpc.ChainStreamInterceptor(statusStreamInterceptor),
+	)
+	serverOpts = append(serverOpts, options.GRPCOptions...)
+
 	// 创建 Server 实例，初始化所有字段
 	// addr 和 svcName 用于服务注册，groups 使用 sync.Map 保证并发安全
 	srv := &Server{
@@ -143,8 +418,17 @@ func NewServer(addr, svcName string, opts ...ServerOption) (*Server, error) {
 		grpcServer: grpc.NewServer(serverOpts...),
 		etcdCli:    etcdCli,
 		stopCh:     make(chan error),
-		opts:       options,
+		opts:       &options,
+		shedder:    shedder,
+		tenants:    tenants,
+		netPolicy:  policy,
+		rpcMetrics: metrics,
 	}
+	if tenants != nil {
+		tenants.groups = srv.servedGroups
+		tenants.group = srv.group
+	}
+	srv.coordinator = srv.newCoordinator()
 
 	// 将 Server 实例注册为 gRPC 服务的实现
 	// 这样其他节点可以通过 gRPC 调用 Get、Set、Delete 方法
@@ -153,10 +437,15 @@ func NewServer(addr, svcName string, opts ...ServerOption) (*Server, error) {
 	// 注册 gRPC 健康检查服务
 	// 健康检查用于负载均衡器或服务发现组件检测节点是否可用
 	// 当节点不健康时，可以从服务发现中剔除，避免流量路由到故障节点
-	healthServer := health.NewServer()
-	healthpb.RegisterHealthServer(srv.grpcServer, healthServer)
-	// 设置服务状态为 SERVING，表示节点已准备好接收请求
-	healthServer.SetServingStatus(svcName, healthpb.HealthCheckResponse_SERVING)
+	srv.health = health.NewServer()
+	healthpb.RegisterHealthServer(srv.grpcServer, srv.health)
+	// 设置服务状态，就绪时为 SERVING，表示节点已准备好接收请求
+	srv.ready.Store(!options.StartNotReady)
+	srv.serving.Store(true)
+	srv.updateHealth()
+
+	// 注册反射服务，便于使用 grpcurl 等工具调试
+	reflection.Register(srv.grpcServer)
 
 	return srv, nil
 }
@@ -164,61 +453,137 @@ func NewServer(addr, svcName string, opts ...ServerOption) (*Server, error) {
 // Start 启动服务器
 func (s *Server) Start() error {
 	// 启动gRPC服务器
-	lis, err := net.Listen("tcp", s.addr)
+	lis, err := s.listen(s.addr)
 	if err != nil {
 		return fmt.Errorf("failed to listen: %v", err)
 	}
+	if s.opts.MaxConnections > 0 {
+		lis = newLimitListener(lis, s.opts.MaxConnections)
+	}
 
-	// 注册到etcd
-	stopCh := make(chan error)
-	go func() {
-		if err := registry.Register(s.svcName, s.addr, stopCh); err != nil {
-			log.Printf("[Server] ERROR: failed to register service: %v", err)
-			close(stopCh)
-			return
-		}
-	}()
+	if err := s.startHTTP(); err != nil {
+		lis.Close()
+		return err
+	}
+	if err := s.startAdmin(); err != nil {
+		lis.Close()
+		s.stopHTTP()
+		return err
+	}
+	if err := s.startMetrics(); err != nil {
+		lis.Close()
+		s.stopHTTP()
+		return err
+	}
+
+	if err := s.register(); err != nil {
+		lis.Close()
+		s.stopHTTP()
+		return err
+	}
+	s.startedAt = time.Now()
+	s.startReadiness()
+	if s.coordinator != nil {
+		s.coordinator.Start()
+	}
 
-	log.Printf("[Server] starting at %s", s.addr)
+	log.Printf("[Server] starting at %s (advertise %s)", s.addr, s.AdvertiseAddr())
 	return s.grpcServer.Serve(lis)
 }
 
-// Stop 停止服务器
-func (s *Server) Stop() {
-	close(s.stopCh)
-	s.grpcServer.GracefulStop()
-	if s.etcdCli != nil {
-		s.etcdCli.Close()
+// register 注册到服务发现，与 Shutdown 互斥，已关闭时返回错误
+func (s *Server) register() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shutdown {
+		return errServerShutdown
 	}
+	switch {
+	case s.opts.Discovery != nil:
+		ctx, cancel := context.WithTimeout(context.Background(), s.opts.DialTimeout)
+		err := s.opts.Discovery.Register(ctx, s.svcName, s.AdvertiseAddr())
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to register service: %v", err)
+		}
+		s.registered = true
+	case !s.opts.DisableRegistry:
+		// 注册到etcd，关闭 stopCh 时撤销租约
+		opts := append(s.opts.RegisterOptions[:len(s.opts.RegisterOptions):len(s.opts.RegisterOptions)],
+			registry.WithVersion(ProtocolVersion, Features()...))
+		if s.opts.LoadReportInterval > 0 {
+			opts = append(opts, registry.WithLoadReport(s.opts.LoadReportInterval, s.loadReporter()))
+		}
+		if err := registry.Register(s.svcName, s.AdvertiseAddr(), s.stopCh, opts...); err != nil {
+			return fmt.Errorf("failed to register service: %v", err)
+		}
+		s.registered = true
+	}
+	return nil
+}
+
+// AdvertiseAddr 返回注册到服务发现的地址，未设置 WithAdvertiseAddr 时为监听地址
+func (s *Server) AdvertiseAddr() string {
+	if s.opts.AdvertiseAddr != "" {
+		return s.opts.AdvertiseAddr
+	}
+	return s.addr
+}
+
+// Stop 停止服务器，等待所有请求处理完成，相当于不设置截止时间的 Shutdown
+func (s *Server) Stop() {
+	s.Shutdown(context.Background())
 }
 
 // Get 实现Cache服务的Get方法
 func (s *Server) Get(ctx context.Context, req *pb.Request) (*pb.ResponseForGet, error) {
-	group := GetGroup(req.Group)
+	group := s.group(req.Group)
 	if group == nil {
-		return nil, fmt.Errorf("group %s not found", req.Group)
+		return nil, errGroupNotFound(req.Group)
 	}
 
-	view, err := group.Get(ctx, req.Key)
-	if err != nil {
-		return nil, err
+	// 其他节点转发过来的请求在本节点加载，不再继续转发
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(peerMetadataKey)) > 0 {
+		ctx = context.WithValue(ctx, "from_peer", true)
+	}
+
+	var view ByteView
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(localOnlyMetadataKey)) > 0 {
+		// 读修复的探测请求只读取本地缓存，不触发加载
+		var found bool
+		if view, found = group.localCache.Get(ctx, req.Key); !found {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, req.Key)
+		}
+	} else {
+		var err error
+		if view, err = group.Get(ctx, req.Key); err != nil {
+			return nil, err
+		}
 	}
 
-	return &pb.ResponseForGet{Value: view.ByteSLice()}, nil
+	resp := &pb.ResponseForGet{Value: view.ByteSlice()}
+	if !view.expire.IsZero() {
+		resp.ExpireAt = view.expire.UnixNano()
+	}
+	if !view.written.IsZero() {
+		resp.WrittenAt = view.written.UnixNano()
+	}
+	return resp, nil
 }
 
 // Set 实现Cache服务的Set方法
 func (s *Server) Set(ctx context.Context, req *pb.Request) (*pb.ResponseForGet, error) {
-	group := GetGroup(req.Group)
+	group := s.group(req.Group)
 	if group == nil {
-		return nil, fmt.Errorf("group %s not found", req.Group)
+		return nil, errGroupNotFound(req.Group)
 	}
 
-	// 从 context 中获取标记，如果没有则创建新的 context
-	fromPeer := ctx.Value("from_peer")
-	if fromPeer == nil {
+	// 其他节点同步过来的写操作只写入本地，不再转发
+	if isFromPeer(req) {
 		ctx = context.WithValue(ctx, "from_peer", true)
 	}
+	ctx = withWriteExpire(ctx, requestExpire(req, time.Now()))
 
 	if err := group.Set(ctx, req.Key, req.Value); err != nil {
 		return nil, err
@@ -229,22 +594,119 @@ func (s *Server) Set(ctx context.Context, req *pb.Request) (*pb.ResponseForGet,
 
 // Delete 实现Cache服务的Delete方法
 func (s *Server) Delete(ctx context.Context, req *pb.Request) (*pb.ResponseForDelete, error) {
-	group := GetGroup(req.Group)
+	group := s.group(req.Group)
 	if group == nil {
-		return nil, fmt.Errorf("group %s not found", req.Group)
+		return nil, errGroupNotFound(req.Group)
 	}
 
 	err := group.Delete(ctx, req.Key)
 	return &pb.ResponseForDelete{Value: err == nil}, err
 }
 
-// loadTLSCredentials 加载TLS证书
-func loadTLSCredentials(certFile, keyFile string) (credentials.TransportCredentials, error) {
-	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+// streamChunkSize GetStream 每个数据块的大小
+const streamChunkSize = 256 << 10 // 256KB
+
+// GetStream 实现Cache服务的GetStream方法，将值按块发送
+// 超过 MaxMsgSize 的值也能在节点间传输，且不需要为整条消息再复制一份
+func (s *Server) GetStream(req *pb.Request, stream pb.CacheService_GetStreamServer) error {
+	group := s.group(req.Group)
+	if group == nil {
+		return errGroupNotFound(req.Group)
+	}
+
+	ctx := stream.Context()
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(peerMetadataKey)) > 0 {
+		ctx = context.WithValue(ctx, "from_peer", true)
+	}
+
+	view, err := group.Get(ctx, req.Key)
+	if err != nil {
+		return err
+	}
+
+	// ByteView 不可变，直接按块切分底层数据发送
+	data := view.b
+	total := int64(len(data))
+	for first := true; first || len(data) > 0; first = false {
+		n := len(data)
+		if n > streamChunkSize {
+			n = streamChunkSize
+		}
+
+		chunk := &pb.Chunk{Data: data[:n]}
+		if first {
+			chunk.TotalSize = total
+		}
+		if err := stream.Send(chunk); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// MGet 实现Cache服务的MGet方法，获取失败的 key 记录在响应的 errors 中
+func (s *Server) MGet(ctx context.Context, req *pb.BatchRequest) (*pb.BatchResponse, error) {
+	group := s.group(req.Group)
+	if group == nil {
+		return nil, errGroupNotFound(req.Group)
+	}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(peerMetadataKey)) > 0 {
+		ctx = context.WithValue(ctx, "from_peer", true)
+	}
+
+	values, failed := group.getMulti(ctx, req.Keys)
+	resp := &pb.BatchResponse{Errors: make(map[string]string, len(failed))}
+	for key, view := range values {
+		resp.Entries = append(resp.Entries, &pb.KeyValue{Key: key, Value: view.ByteSlice()})
+	}
+	for key, err := range failed {
+		resp.Errors[key] = err.Error()
+	}
+	return resp, nil
+}
+
+// MSet 实现Cache服务的MSet方法，设置失败的 key 记录在响应的 errors 中
+func (s *Server) MSet(ctx context.Context, req *pb.BatchRequest) (*pb.BatchResponse, error) {
+	group := s.group(req.Group)
+	if group == nil {
+		return nil, errGroupNotFound(req.Group)
+	}
+
+	ctx = context.WithValue(ctx, "from_peer", true)
+
+	now := time.Now()
+	resp := &pb.BatchResponse{Errors: make(map[string]string)}
+	for _, entry := range req.Entries {
+		// 每个 key 可以携带各自的过期时间，未携带时使用组的默认过期时间
+		entryCtx := withWriteExpire(ctx, expireFromFields(entry.TtlMs, entry.ExpireAt, now))
+		if err := group.Set(entryCtx, entry.Key, entry.Value); err != nil {
+			resp.Errors[entry.Key] = err.Error()
+		}
+	}
+	return resp, nil
+}
+
+// MDelete 实现Cache服务的MDelete方法
+func (s *Server) MDelete(ctx context.Context, req *pb.BatchRequest) (*pb.BatchResponse, error) {
+	group := s.group(req.Group)
+	if group == nil {
+		return nil, errGroupNotFound(req.Group)
+	}
+
+	ctx = context.WithValue(ctx, "from_peer", true)
+	if err := group.DeleteMulti(ctx, req.Keys); err != nil {
+		return nil, err
+	}
+	return &pb.BatchResponse{}, nil
+}
+
+// loadTLSCredentials 加载TLS证书，clientCAFile 不为空时要求并校验客户端证书
+func loadTLSCredentials(certFile, keyFile, clientCAFile string) (credentials.TransportCredentials, error) {
+	reloader, err := newCertReloader(certFile, keyFile, clientCAFile)
 	if err != nil {
 		return nil, err
 	}
-	return credentials.NewTLS(&tls.Config{
-		Certificates: []tls.Certificate{cert},
-	}), nil
+	return credentials.NewTLS(reloader.serverConfig()), nil
 }
@@ -0,0 +1,48 @@
+package mycache
+
+import (
+	"log"
+	"sort"
+)
+
+// RegisterGroup 让服务器对外提供 g 的读写（gRPC、REST、管理和统计接口）
+//
+// 服务器只提供显式注册的组，同一进程中的其他组（如仅供内部使用的组）不会被其他节点或
+// 外部客户端访问。同名的组会被替换。
+func (s *Server) RegisterGroup(g *Group) {
+	if _, loaded := s.groups.Swap(g.name, g); loaded {
+		log.Printf("[Server] group [%s] re-registered", g.name)
+		return
+	}
+	log.Printf("[Server] serving group [%s]", g.name)
+}
+
+// UnregisterGroup 停止对外提供名为 name 的组，组本身不会被关闭；组未注册时返回 false
+func (s *Server) UnregisterGroup(name string) bool {
+	if _, ok := s.groups.LoadAndDelete(name); !ok {
+		return false
+	}
+	log.Printf("[Server] stopped serving group [%s]", name)
+	return true
+}
+
+// group 返回已注册的组，未注册时返回 nil
+func (s *Server) group(name string) *Group {
+	if g, ok := s.groups.Load(name); ok {
+		return g.(*Group)
+	}
+	return nil
+}
+
+// servedGroups 返回所有已注册的组，按组名排序
+func (s *Server) servedGroups() []*Group {
+	var groups []*Group
+	s.groups.Range(func(_, g any) bool {
+		groups = append(groups, g.(*Group))
+		return true
+	})
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].name < groups[j].name
+	})
+	return groups
+}
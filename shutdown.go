@@ -0,0 +1,177 @@
+package mycache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/linhx1999/MyCache-Go/registry"
+)
+
+// drainPollInterval 等待正在处理的请求完成时的检查间隔
+const drainPollInterval = 50 * time.Millisecond
+
+// errServerShutdown 服务器已关闭后再启动时返回的错误
+var errServerShutdown = errors.New("cache: server is shut down")
+
+// WithShutdownTransfer 在优雅关闭时，把每个组中本节点负责的最多 maxKeys 个 key
+// 发送给本节点离开后接管它们的节点，减少下线带来的缓存未命中
+//
+// key 按本地缓存的遍历顺序选取（LRU 存储中最近访问的优先）。需要组的 PeerPicker 实现 ReplicaPicker。
+func WithShutdownTransfer(maxKeys int) ServerOption {
+	return func(o *ServerOptions) {
+		o.ShutdownTransferKeys = maxKeys
+	}
+}
+
+// Shutdown 优雅关闭服务器
+//
+// 关闭流程：
+//  1. 从服务发现中注销并将健康状态设为 NOT_SERVING，其他节点不再向本节点路由请求
+//  2. 等待正在处理的 RPC 完成
+//  3. 启用 WithShutdownTransfer 时，把本节点负责的热点 key 发送给下一个 owner
+//  4. 停止 HTTP 接口和 gRPC 服务
+//
+// ctx 到期时不再等待，强制关闭剩余的连接并返回 ctx 的错误。重复调用时直接返回 nil。
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if s.shutdown {
+		s.mu.Unlock()
+		return nil
+	}
+	s.shutdown = true
+	s.deregister()
+	s.mu.Unlock()
+
+	// 协调者立即让出，其他节点接替时无需等待选举租约过期
+	if s.coordinator != nil {
+		s.coordinator.Close()
+	}
+
+	s.updateHealth()
+
+	if err := s.drain(ctx); err != nil {
+		log.Printf("[Server] WARN: %d requests still in flight at shutdown: %v", s.shedder.inFlight.Load(), err)
+	}
+
+	if s.opts.ShutdownTransferKeys > 0 && ctx.Err() == nil {
+		s.transferOwned(ctx)
+	}
+
+	s.stopHTTP()
+	s.stopGRPC(ctx)
+	if s.etcdCli != nil {
+		s.etcdCli.Close()
+	}
+	return ctx.Err()
+}
+
+// deregister 从服务发现中注销，调用方需持有 s.mu
+// etcd 注册同步删除 key 并撤销租约，返回后其他节点的 watch 很快就会移除本节点
+func (s *Server) deregister() {
+	switch {
+	case !s.registered:
+	case s.opts.Discovery != nil:
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		if err := s.opts.Discovery.Deregister(ctx, s.svcName, s.AdvertiseAddr()); err != nil {
+			log.Printf("[Server] ERROR: failed to deregister service: %v", err)
+		}
+		cancel()
+	default:
+		if err := registry.Deregister(s.svcName, s.AdvertiseAddr()); err != nil {
+			log.Printf("[Server] ERROR: failed to deregister service: %v", err)
+		}
+	}
+	// 通知 etcd 注册协程撤销租约
+	close(s.stopCh)
+}
+
+// drain 等待正在处理的 RPC 完成，直到 ctx 到期
+func (s *Server) drain(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for s.shedder.inFlight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// stopGRPC 优雅停止 gRPC 服务，ctx 到期时强制关闭
+func (s *Server) stopGRPC(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.grpcServer.Stop()
+		<-done
+	}
+}
+
+// transferOwned 将各组中本节点负责的 key 发送给下一个 owner
+func (s *Server) transferOwned(ctx context.Context) {
+	ctx = WithPriority(ctx, PriorityBackground)
+	for _, group := range s.servedGroups() {
+		name := group.name
+		result, err := group.transferOwned(ctx, s.opts.ShutdownTransferKeys)
+		if err != nil {
+			log.Printf("[Server] ERROR: shutdown transfer for group [%s] failed: %v", name, err)
+		}
+		if result.Keys > 0 {
+			log.Printf("[Server] shutdown transfer for group [%s]: %+v", name, result)
+		}
+	}
+}
+
+// transferOwned 将本节点负责的最多 limit 个 key 发送给环上的下一个节点
+// 本节点离开后，这些 key 将由下一个节点负责
+func (g *Group) transferOwned(ctx context.Context, limit int) (MigrationResult, error) {
+	var result MigrationResult
+	if g.closed.Load() == 1 || g.peers == nil {
+		return result, nil
+	}
+	replicaPicker, ok := g.peers.(ReplicaPicker)
+	if !ok {
+		return result, nil
+	}
+
+	byPeer := make(map[Peer][]TransferEntry)
+	g.localCache.Range(func(key string, view ByteView) bool {
+		if _, ok, isSelf := g.peers.PickPeer(key); !ok || !isSelf {
+			return true
+		}
+		next := replicaPicker.PickPeers(key, 2)
+		if len(next) == 0 {
+			return true
+		}
+		byPeer[next[0]] = append(byPeer[next[0]], TransferEntry{
+			Key:      key,
+			Value:    view.b,
+			ExpireAt: view.expire,
+		})
+		result.Keys++
+		return result.Keys < limit && ctx.Err() == nil
+	})
+
+	var errs []error
+	for peer, entries := range byPeer {
+		res, err := g.transferTo(ctx, peer, entries)
+		result.Stored += res.Stored
+		result.Failed += len(entries) - res.Stored
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	g.stats.migratedKeys.Add(int64(result.Stored))
+	return result, errors.Join(errs...)
+}
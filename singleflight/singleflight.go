@@ -1,42 +1,382 @@
 package singleflight
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// ErrTooManyWaiters 等待同一个 key 的调用方数量已达上限
+var ErrTooManyWaiters = errors.New("singleflight: too many waiters")
+
 // call 代表一个正在执行或已完成的请求
 type call struct {
-	waitGroup sync.WaitGroup // 用于阻塞等待相同 key 的并发请求
-	value     interface{}    // 请求返回的结果值
-	err       error          // 请求执行过程中发生的错误
+	done  chan struct{} // 请求完成时关闭，用于通知所有等待相同 key 的并发请求
+	value interface{}   // 请求返回的结果值
+	err   error         // 请求执行过程中发生的错误
+
+	expiresAt time.Time // 结果缓存窗口的截止时间，在 done 关闭前写入
+	startedAt time.Time // 请求开始执行的时间
+
+	mu        sync.Mutex
+	waiters   int                // 仍在等待结果的调用方数量（包含执行者）
+	joined    int                // 累计加入等待的调用方数量（不含执行者）
+	abandoned bool               // 所有等待者都已放弃，fn 的 context 已被取消
+	cancel    context.CancelFunc // 取消 fn 的 context，仅 DoCtx 发起的请求非空
+
+	deadline time.Time // fn 的 context 继承的发起者截止时间，零值表示没有截止时间
+	timedOut bool      // fn 因 deadline 到期而失败，在 done 关闭前写入
+}
+
+// newCall 创建一个新的请求，创建者自身计为一个等待者
+func newCall() *call {
+	return &call{
+		done:      make(chan struct{}),
+		waiters:   1,
+		startedAt: time.Now(),
+	}
+}
+
+// join 加入等待，请求已被放弃时返回 false
+// maxWaiters 大于 0 时，正在等待的调用方（不含执行者）已达上限则返回 ErrTooManyWaiters
+func (c *call) join(maxWaiters int) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.abandoned {
+		return false, nil
+	}
+	if maxWaiters > 0 && c.waiters-1 >= maxWaiters {
+		select {
+		case <-c.done:
+			// 已完成的请求（结果缓存窗口内）无需排队，不受上限约束
+		default:
+			return false, ErrTooManyWaiters
+		}
+	}
+	c.waiters++
+	c.joined++
+	return true, nil
+}
+
+// expired 判断请求是否已完成且超出结果缓存窗口
+func (c *call) expired() bool {
+	select {
+	case <-c.done:
+		return !time.Now().Before(c.expiresAt)
+	default:
+		return false
+	}
+}
+
+// leave 放弃等待，最后一个等待者离开且请求尚未完成时取消 fn
+func (c *call) leave() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.waiters--
+	if c.waiters > 0 || c.cancel == nil {
+		return
+	}
+
+	select {
+	case <-c.done:
+	default:
+		c.abandoned = true
+		c.cancel()
+	}
 }
 
 // Group 用于管理并发请求，确保相同 key 的请求只执行一次
+// 零值可以直接使用，需要额外配置时使用 New 创建
 type Group struct {
-	callsMap sync.Map // key -> *call，存储正在执行的请求
+	callsMap   sync.Map       // key -> *call，存储正在执行（或处于结果缓存窗口内）的请求
+	resultTTL  time.Duration  // 成功结果在请求完成后继续共享的时长，0 表示完成即移除
+	maxWaiters int            // 单个 key 上允许的最大等待者数量，0 表示不限制
+	onDone     func(CallInfo) // 每次 fn 执行完成后的回调
+
+	executions  atomic.Int64 // fn 实际执行次数
+	shared      atomic.Int64 // 复用其他请求结果的调用次数
+	errorShares atomic.Int64 // 复用到错误结果的调用次数
+	abandoned   atomic.Int64 // 放弃等待的调用次数
+	rejected    atomic.Int64 // 因等待者过多被拒绝的调用次数
+	inFlight    atomic.Int64 // 当前正在执行的请求数量
+}
+
+// Stats SingleFlight 统计信息
+type Stats struct {
+	Executions  int64 // fn 实际执行次数
+	Shared      int64 // 被合并、复用其他请求结果的调用次数
+	ErrorShares int64 // 复用到错误结果的调用次数
+	Abandoned   int64 // 因 ctx 取消而放弃等待的调用次数
+	Rejected    int64 // 因等待者过多被拒绝的调用次数
+	InFlight    int64 // 当前正在执行的请求数量
+}
+
+// CallInfo 一次 fn 执行的信息，用于回调和在途请求查询
+type CallInfo struct {
+	Key       string        // 请求的 key
+	StartedAt time.Time     // 开始执行的时间
+	Duration  time.Duration // 执行耗时（在途请求为已执行的时长）
+	Waiters   int           // 合并到该请求的调用方数量（不含执行者）
+	Err       error         // 执行结果的错误（在途请求为 nil）
+}
+
+// Option 定义 Group 的配置选项
+type Option func(*Group)
+
+// WithResultTTL 设置结果缓存窗口
+//
+// 请求成功完成后，其结果会在 d 时长内继续被相同 key 的新请求复用，
+// 避免请求刚完成时紧接着到达的一波请求再次执行 fn。失败的结果不会被缓存。
+func WithResultTTL(d time.Duration) Option {
+	return func(g *Group) {
+		g.resultTTL = d
+	}
+}
+
+// WithMaxWaiters 设置单个 key 上允许的最大等待者数量（不含执行者）
+//
+// 已有 n 个调用方在等待同一个 key 时，新的调用方立即返回 ErrTooManyWaiters，
+// 避免慢加载时在其后堆积无限多的 goroutine。n 小于等于 0 表示不限制。
+func WithMaxWaiters(n int) Option {
+	return func(g *Group) {
+		g.maxWaiters = n
+	}
+}
+
+// WithCallback 设置每次 fn 执行完成后的回调，可用于上报耗时、合并数量等指标
+// 回调在执行 fn 的 goroutine 中同步调用，不应阻塞
+func WithCallback(fn func(CallInfo)) Option {
+	return func(g *Group) {
+		g.onDone = fn
+	}
+}
+
+// New 创建一个 Group 实例
+func New(opts ...Option) *Group {
+	g := &Group{}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
 }
 
 // Do 执行给定函数 fn，并确保对于相同的 key，在任意时刻只有一个 fn 正在执行
 // 如果已有相同 key 的请求正在执行，则等待其完成并共享结果
 func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
-	// 检查是否已有正在执行的请求
-	if existingCall, ok := g.callsMap.Load(key); ok {
-		c := existingCall.(*call)
-		c.waitGroup.Wait()    // 等待正在执行的请求完成
+	c, isOwner, err := g.acquire(key, newCall())
+	if err != nil {
+		return nil, err
+	}
+	if !isOwner {
+		<-c.done // 等待正在执行的请求完成
+		g.recordShared(c)
 		return c.value, c.err // 复用已完成的请求结果
 	}
 
-	// 没有正在执行的请求，创建新的请求
-	c := &call{}
-	c.waitGroup.Add(1)
-	g.callsMap.Store(key, c) // 存储到 map 中，让其他相同 key 的请求能够发现
-
 	// 执行函数并记录结果
+	g.start()
 	c.value, c.err = fn()
-	c.waitGroup.Done() // 通知所有等待的请求，当前请求已完成
+	g.finish(key, c)
+
+	return c.value, c.err
+}
+
+// DoCtx 与 Do 相同，但每个调用方都可以通过 ctx 放弃等待
+//
+// 调用方的 ctx 被取消时立即返回 ctx.Err()，不再等待结果；fn 在一个与调用方解耦的
+// context 中执行（保留发起者 ctx 中的值和截止时间），只有当所有等待者都放弃后才会被取消，
+// 因此单个调用方超时不会影响其他仍在等待的调用方。截止时间随 fn 中的 RPC 传递给下游，
+// 使调用方的时间预算在远程节点上同样生效；加入的调用方截止时间晚于发起者时，如果 fn 因
+// 发起者的截止时间到期而失败，该调用方会重新执行 fn，而不是得到超时错误。
+func (g *Group) DoCtx(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	for {
+		value, retry, err := g.doCtx(ctx, key, fn)
+		if !retry {
+			return value, err
+		}
+	}
+}
+
+// doCtx 执行或加入一次请求，retry 为 true 表示请求因发起者的截止时间失败，调用方应重新执行
+func (g *Group) doCtx(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (value interface{}, retry bool, err error) {
+	c := newCall()
+	var fnCtx context.Context
+	if deadline, ok := ctx.Deadline(); ok {
+		fnCtx, c.cancel = context.WithDeadline(context.WithoutCancel(ctx), deadline)
+		c.deadline = deadline
+	} else {
+		fnCtx, c.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	}
+	cancel := c.cancel
+
+	c, isOwner, err := g.acquire(key, c)
+	if err != nil {
+		cancel()
+		return nil, false, err
+	}
+	if isOwner {
+		// 在独立的 goroutine 中执行，使发起者自身也能放弃等待
+		g.start()
+		go func() {
+			// fn 不在调用方的 goroutine 中执行，panic 无法被调用方的 recover 捕获，
+			// 转换为错误返回给所有等待者，并保证 finish 总会执行
+			defer func() {
+				if r := recover(); r != nil {
+					c.value, c.err = nil, fmt.Errorf("singleflight: panic: %v", r)
+				}
+				cancel()
+				g.finish(key, c)
+			}()
+			c.value, c.err = fn(fnCtx)
+			c.timedOut = c.err != nil && errors.Is(fnCtx.Err(), context.DeadlineExceeded)
+		}()
+	} else {
+		cancel() // 加入了已有请求，预先创建的 context 不再需要
+	}
+
+	select {
+	case <-c.done:
+		if !isOwner {
+			if c.timedOut && ctx.Err() == nil && outlives(ctx, c.deadline) {
+				return nil, true, nil
+			}
+			g.recordShared(c)
+		}
+		return c.value, false, c.err
+	case <-ctx.Done():
+		c.leave()
+		g.abandoned.Add(1)
+		return nil, false, ctx.Err()
+	}
+}
+
+// outlives 判断 ctx 的截止时间是否晚于 deadline，ctx 没有截止时间时为 true
+func outlives(ctx context.Context, deadline time.Time) bool {
+	d, ok := ctx.Deadline()
+	return !ok || d.After(deadline)
+}
+
+// acquire 登记或加入 key 对应的请求，返回实际的请求以及当前调用方是否为执行者
+// 等待者数量已达上限时返回 ErrTooManyWaiters
+func (g *Group) acquire(key string, c *call) (*call, bool, error) {
+	for {
+		// 原子地检查并登记请求，避免两个并发请求同时成为执行者
+		actual, loaded := g.callsMap.LoadOrStore(key, c)
+		if !loaded {
+			return c, true, nil
+		}
+
+		// 已超出结果缓存窗口或已被所有等待者放弃的请求不能再复用，移除后重试
+		existingCall := actual.(*call)
+		if !existingCall.expired() {
+			joined, err := existingCall.join(g.maxWaiters)
+			if err != nil {
+				g.rejected.Add(1)
+				return nil, false, err
+			}
+			if joined {
+				return existingCall, false, nil
+			}
+		}
+
+		g.callsMap.CompareAndDelete(key, existingCall)
+	}
+}
+
+// start 记录一次 fn 执行的开始
+func (g *Group) start() {
+	g.executions.Add(1)
+	g.inFlight.Add(1)
+}
+
+// recordShared 记录一次复用其他请求结果的调用
+func (g *Group) recordShared(c *call) {
+	g.shared.Add(1)
+	if c.err != nil {
+		g.errorShares.Add(1)
+	}
+}
+
+// finish 通知所有等待者请求已完成，并从 map 中移除请求
+// 启用结果缓存窗口时，成功的请求会在窗口结束后才被移除
+func (g *Group) finish(key string, c *call) {
+	g.inFlight.Add(-1)
+	if g.onDone != nil {
+		c.mu.Lock()
+		joined := c.joined
+		c.mu.Unlock()
+		g.onDone(CallInfo{
+			Key:       key,
+			StartedAt: c.startedAt,
+			Duration:  time.Since(c.startedAt),
+			Waiters:   joined,
+			Err:       c.err,
+		})
+	}
+
+	keep := g.resultTTL > 0 && c.err == nil
+	if keep {
+		c.expiresAt = time.Now().Add(g.resultTTL)
+	}
+	close(c.done) // 通知所有等待的请求，当前请求已完成
 
 	// 请求完成后从 map 中移除，释放内存
-	g.callsMap.Delete(key)
+	// 只删除自己登记的请求：执行期间若调用了 Forget，map 中可能已是新的请求
+	if keep {
+		time.AfterFunc(g.resultTTL, func() {
+			g.callsMap.CompareAndDelete(key, c)
+		})
+		return
+	}
+	g.callsMap.CompareAndDelete(key, c)
+}
 
-	return c.value, c.err
+// Stats 返回统计信息
+func (g *Group) Stats() Stats {
+	return Stats{
+		Executions:  g.executions.Load(),
+		Shared:      g.shared.Load(),
+		ErrorShares: g.errorShares.Load(),
+		Abandoned:   g.abandoned.Load(),
+		Rejected:    g.rejected.Load(),
+		InFlight:    g.inFlight.Load(),
+	}
+}
+
+// InFlight 返回当前正在执行的请求，可用于发现长时间卡住的 key
+func (g *Group) InFlight() []CallInfo {
+	var calls []CallInfo
+	g.callsMap.Range(func(k, v interface{}) bool {
+		c := v.(*call)
+		select {
+		case <-c.done:
+			return true
+		default:
+		}
+
+		c.mu.Lock()
+		joined := c.joined
+		c.mu.Unlock()
+		calls = append(calls, CallInfo{
+			Key:       k.(string),
+			StartedAt: c.startedAt,
+			Duration:  time.Since(c.startedAt),
+			Waiters:   joined,
+		})
+		return true
+	})
+	return calls
+}
+
+// Forget 使 key 对应的正在执行的请求（或缓存的结果）失效
+//
+// 调用后，新的 Do 不再等待当前正在执行的请求，而是重新执行 fn；
+// 已经在等待的调用方仍会拿到原请求的结果。适用于写操作使正在加载的值过期的场景。
+func (g *Group) Forget(key string) {
+	g.callsMap.Delete(key)
 }
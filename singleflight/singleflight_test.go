@@ -0,0 +1,356 @@
+package singleflight
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestDo 测试基本调用
+func TestDo(t *testing.T) {
+	var g Group
+
+	v, err := g.Do("key", func() (interface{}, error) {
+		return "bar", nil
+	})
+	if err != nil || v.(string) != "bar" {
+		t.Fatalf("Do = %v, %v; want bar, nil", v, err)
+	}
+
+	wantErr := errors.New("boom")
+	_, err = g.Do("key", func() (interface{}, error) {
+		return nil, wantErr
+	})
+	if err != wantErr {
+		t.Fatalf("Do error = %v; want %v", err, wantErr)
+	}
+}
+
+// TestDoDedup 测试并发请求只执行一次
+func TestDoDedup(t *testing.T) {
+	var g Group
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	fn := func() (interface{}, error) {
+		calls.Add(1)
+		<-release
+		return "v", nil
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := g.Do("key", fn); err != nil || v.(string) != "v" {
+				t.Errorf("Do = %v, %v; want v, nil", v, err)
+			}
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("fn called %d times; want 1", got)
+	}
+}
+
+// TestForget 测试 Forget 后新的请求会重新执行
+func TestForget(t *testing.T) {
+	var g Group
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	go g.Do("key", func() (interface{}, error) {
+		close(started)
+		<-release
+		return 1, nil
+	})
+	<-started
+
+	g.Forget("key")
+
+	v, err := g.Do("key", func() (interface{}, error) {
+		return 2, nil
+	})
+	if err != nil || v.(int) != 2 {
+		t.Fatalf("Do after Forget = %v, %v; want 2, nil", v, err)
+	}
+
+	close(release)
+}
+
+// TestDoCtxWaiterCancel 测试等待者取消后立即返回，且不影响其他等待者
+func TestDoCtxWaiterCancel(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	var fnCanceled atomic.Bool
+
+	fn := func(ctx context.Context) (interface{}, error) {
+		select {
+		case <-release:
+			return "v", nil
+		case <-ctx.Done():
+			fnCanceled.Store(true)
+			return nil, ctx.Err()
+		}
+	}
+
+	// 第一个调用方保持等待
+	resultCh := make(chan interface{}, 1)
+	go func() {
+		v, _ := g.DoCtx(context.Background(), "key", fn)
+		resultCh <- v
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// 第二个调用方超时放弃
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := g.DoCtx(ctx, "key", fn); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("DoCtx error = %v; want DeadlineExceeded", err)
+	}
+
+	close(release)
+	if v := <-resultCh; v != "v" {
+		t.Fatalf("remaining waiter got %v; want v", v)
+	}
+	if fnCanceled.Load() {
+		t.Fatal("fn should not be canceled while a waiter remains")
+	}
+}
+
+// TestDoCtxAllWaitersCancel 测试所有等待者都放弃后 fn 被取消，新的请求重新执行
+func TestDoCtxAllWaitersCancel(t *testing.T) {
+	var g Group
+	canceled := make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := g.DoCtx(ctx, "key", func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("DoCtx error = %v; want Canceled", err)
+	}
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("fn was not canceled after all waiters left")
+	}
+
+	v, err := g.DoCtx(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+		return "fresh", nil
+	})
+	if err != nil || v != "fresh" {
+		t.Fatalf("DoCtx after abandon = %v, %v; want fresh, nil", v, err)
+	}
+}
+
+// TestDoCtxDeadline 测试 fn 继承发起者的截止时间，截止时间更晚的等待者在发起者超时后重新执行
+func TestDoCtxDeadline(t *testing.T) {
+	var g Group
+	var calls atomic.Int32
+	fn := func(ctx context.Context) (interface{}, error) {
+		n := calls.Add(1)
+		if _, ok := ctx.Deadline(); !ok {
+			return nil, errors.New("fn context has no deadline")
+		}
+		if n == 1 {
+			// 第一次执行超过发起者的截止时间
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return "v", nil
+	}
+
+	shortCtx, cancelShort := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancelShort()
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := g.DoCtx(shortCtx, "key", fn)
+		errCh <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	longCtx, cancelLong := context.WithTimeout(context.Background(), time.Second)
+	defer cancelLong()
+	v, err := g.DoCtx(longCtx, "key", fn)
+	if err != nil || v != "v" {
+		t.Fatalf("DoCtx with longer deadline = %v, %v; want v, nil", v, err)
+	}
+	if err := <-errCh; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("DoCtx with shorter deadline error = %v; want DeadlineExceeded", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("fn called %d times; want 2", n)
+	}
+}
+
+// TestResultTTL 测试结果缓存窗口内复用结果，窗口结束后重新执行
+func TestResultTTL(t *testing.T) {
+	g := New(WithResultTTL(50 * time.Millisecond))
+	var calls atomic.Int32
+	fn := func() (interface{}, error) {
+		return calls.Add(1), nil
+	}
+
+	g.Do("key", fn)
+	if v, _ := g.Do("key", fn); v.(int32) != 1 {
+		t.Fatalf("Do within TTL = %v; want cached 1", v)
+	}
+
+	time.Sleep(80 * time.Millisecond)
+	if v, _ := g.Do("key", fn); v.(int32) != 2 {
+		t.Fatalf("Do after TTL = %v; want 2", v)
+	}
+
+	g.Forget("key")
+	if v, _ := g.Do("key", fn); v.(int32) != 3 {
+		t.Fatalf("Do after Forget = %v; want 3", v)
+	}
+}
+
+// TestStats 测试统计信息、在途请求查询和完成回调
+func TestStats(t *testing.T) {
+	var infos []CallInfo
+	var mu sync.Mutex
+	g := New(WithCallback(func(info CallInfo) {
+		mu.Lock()
+		infos = append(infos, info)
+		mu.Unlock()
+	}))
+
+	errBoom := errors.New("boom")
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		return nil, errBoom
+	}
+
+	const n = 5
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.Do("key", fn)
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if inFlight := g.InFlight(); len(inFlight) != 1 || inFlight[0].Key != "key" || inFlight[0].Waiters != n-1 {
+		t.Fatalf("InFlight = %+v; want key with %d waiters", inFlight, n-1)
+	}
+
+	close(release)
+	wg.Wait()
+
+	want := Stats{Executions: 1, Shared: n - 1, ErrorShares: n - 1}
+	if got := g.Stats(); got != want {
+		t.Fatalf("Stats = %+v; want %+v", got, want)
+	}
+	if len(g.InFlight()) != 0 {
+		t.Fatal("InFlight not empty after completion")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(infos) != 1 || infos[0].Waiters != n-1 || !errors.Is(infos[0].Err, errBoom) {
+		t.Fatalf("callback infos = %+v; want one call with %d waiters and errBoom", infos, n-1)
+	}
+}
+
+// TestMaxWaiters 测试等待者达到上限后新的调用方立即失败
+func TestMaxWaiters(t *testing.T) {
+	g := New(WithMaxWaiters(2))
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		return "v", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := g.Do("key", fn); err != nil || v != "v" {
+				t.Errorf("Do = %v, %v; want v, nil", v, err)
+			}
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if _, err := g.Do("key", fn); !errors.Is(err, ErrTooManyWaiters) {
+		t.Fatalf("Do over limit error = %v; want ErrTooManyWaiters", err)
+	}
+	if _, err := g.DoCtx(context.Background(), "key", func(context.Context) (interface{}, error) {
+		return fn()
+	}); !errors.Is(err, ErrTooManyWaiters) {
+		t.Fatalf("DoCtx over limit error = %v; want ErrTooManyWaiters", err)
+	}
+
+	close(release)
+	wg.Wait()
+
+	if got := g.Stats().Rejected; got != 2 {
+		t.Fatalf("Rejected = %d; want 2", got)
+	}
+	if v, err := g.Do("key", fn); err != nil || v != "v" {
+		t.Fatalf("Do after completion = %v, %v; want v, nil", v, err)
+	}
+}
+
+// TestDoCtxPanic 测试 fn panic 时转换为错误返回给所有等待者，且 key 可以重新执行
+func TestDoCtxPanic(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+
+	fn := func(ctx context.Context) (interface{}, error) {
+		<-release
+		panic("boom")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	errCh := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := g.DoCtx(ctx, "key", fn)
+			errCh <- err
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	for i := 0; i < 2; i++ {
+		err := <-errCh
+		if err == nil || !strings.Contains(err.Error(), "panic: boom") {
+			t.Fatalf("DoCtx error = %v; want panic error", err)
+		}
+	}
+
+	v, err := g.DoCtx(ctx, "key", func(ctx context.Context) (interface{}, error) {
+		return "v", nil
+	})
+	if err != nil || v != "v" {
+		t.Fatalf("DoCtx after panic = %v, %v; want v", v, err)
+	}
+}
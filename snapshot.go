@@ -0,0 +1,361 @@
+package mycache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// snapshotMagic 快照文件的魔数
+	snapshotMagic = "MYCSNAP\x00"
+	// snapshotVersion 快照格式版本，格式变化时递增
+	snapshotVersion = 2
+	// snapshotHeaderSize 魔数、版本号、标志位和创建时间
+	snapshotHeaderSize = len(snapshotMagic) + 2 + 2 + 8
+	// snapshotFlagEncrypted 快照中的值已加密，见 WithEncryption
+	snapshotFlagEncrypted = 1 << 0
+	// snapshotTrailerSize 缓存项数量和校验和
+	snapshotTrailerSize = 8 + 4
+)
+
+var (
+	// ErrSnapshotCorrupt 快照文件损坏或被截断，校验和不匹配
+	ErrSnapshotCorrupt = errors.New("cache: snapshot corrupt")
+	// ErrSnapshotVersion 快照文件的格式版本与当前程序不同，无法读取
+	ErrSnapshotVersion = errors.New("cache: unsupported snapshot version")
+	// ErrSnapshotEncrypted 快照中的值已加密，但组没有启用加密
+	ErrSnapshotEncrypted = errors.New("cache: snapshot is encrypted but group has no encryption key")
+)
+
+// crcTable 快照校验和使用的 CRC-32C 表
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// WithSnapshot 启用磁盘快照：创建组时从 dir 中的快照恢复本地缓存，之后每隔 interval
+// 将本地缓存写入快照，组关闭时再写入一次，使重启后的节点不必全部回源
+//
+// 快照先写入临时文件再原子替换，进程在写入过程中崩溃不会损坏已有的快照。
+// 恢复时保留各缓存项剩余的过期时间，已过期的缓存项被跳过。interval 为 0 时只在关闭时写入。
+// 快照文件为 dir 下的 "<组名>.snapshot"，多个组可以共用一个目录。
+// 组启用 WithEncryption 时快照中的值同样加密，恢复时无法解密的缓存项被跳过。
+func WithSnapshot(dir string, interval time.Duration) GroupOption {
+	return func(g *Group) {
+		g.snapshotPath = filepath.Join(dir, url.PathEscape(g.name)+".snapshot")
+		g.snapshotInterval = interval
+	}
+}
+
+// SnapshotPath 返回快照文件的路径，未启用快照时返回空字符串
+func (g *Group) SnapshotPath() string {
+	return g.snapshotPath
+}
+
+// snapshotEntry 快照中的一个缓存项
+type snapshotEntry struct {
+	key  string
+	view ByteView
+}
+
+// SaveSnapshot 将本地缓存中未过期的缓存项写入快照文件，返回写入的数量
+func (g *Group) SaveSnapshot() (int, error) {
+	if g.snapshotPath == "" {
+		return 0, errors.New("cache: snapshot not enabled")
+	}
+	if !g.cipher.usable() {
+		// 此时本地缓存为空，写入会覆盖密钥可用时保存的快照
+		return 0, errors.New("cache: encryption key unavailable, snapshot not saved")
+	}
+
+	// 先复制出缓存项，写磁盘时不持有存储的锁
+	var entries []snapshotEntry
+	now := time.Now()
+	g.localCache.Range(func(key string, view ByteView) bool {
+		if view.expire.IsZero() || view.expire.After(now) {
+			entries = append(entries, snapshotEntry{key: key, view: view})
+		}
+		return true
+	})
+
+	if err := writeSnapshot(g.snapshotPath, entries, g.cipher); err != nil {
+		return 0, fmt.Errorf("cache: failed to save snapshot: %w", err)
+	}
+	g.stats.snapshotSaves.Add(1)
+	return len(entries), nil
+}
+
+// LoadSnapshot 从快照文件恢复本地缓存，返回恢复的数量，快照文件不存在时返回 0
+// 已过期、超出内存配额或无法解密的缓存项被跳过，不会触发事件，也不会同步到其他节点
+// 未加密的快照可以恢复到启用加密的组，下次写入快照时加密
+func (g *Group) LoadSnapshot() (int, error) {
+	if g.snapshotPath == "" {
+		return 0, errors.New("cache: snapshot not enabled")
+	}
+
+	data, err := os.ReadFile(g.snapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("cache: failed to read snapshot: %w", err)
+	}
+
+	now := time.Now()
+	loaded := 0
+	err = readSnapshot(data, g.cipher, func(key string, view ByteView) {
+		if g.restoreEntry(key, view, now) {
+			loaded++
+		}
+	})
+	if err != nil {
+		return loaded, fmt.Errorf("cache: failed to load snapshot %s: %w", g.snapshotPath, err)
+	}
+	g.stats.snapshotLoads.Add(int64(loaded))
+	return loaded, nil
+}
+
+// restoreEntry 将快照或备份中的缓存项写入本地缓存，保留过期时间，返回是否写入
+// 已过期或超出内存配额的缓存项被跳过
+func (g *Group) restoreEntry(key string, view ByteView, now time.Time) bool {
+	if !view.expire.IsZero() && !view.expire.After(now) {
+		return false
+	}
+	if g.checkQuota(key, view.Len()) != nil {
+		return false
+	}
+	if view.expire.IsZero() {
+		g.localCache.Add(key, view)
+	} else {
+		g.localCache.AddWithExpiration(key, view, view.expire)
+	}
+	return true
+}
+
+// startSnapshots 从快照恢复本地缓存并在后台定期写入快照，未启用时不做任何事
+func (g *Group) startSnapshots() {
+	if g.snapshotPath == "" {
+		return
+	}
+	start := time.Now()
+	if n, err := g.LoadSnapshot(); err != nil {
+		log.Printf("[MyCache] %v", err)
+	} else if n > 0 {
+		log.Printf("[MyCache] restored %d entries for group [%s] from snapshot in %v", n, g.name, time.Since(start))
+	}
+
+	if g.snapshotInterval <= 0 {
+		return
+	}
+	g.snapshotStop = make(chan struct{})
+	g.snapshotDone = make(chan struct{})
+	go func() {
+		defer close(g.snapshotDone)
+
+		ticker := time.NewTicker(g.snapshotInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-g.snapshotStop:
+				return
+			case <-ticker.C:
+				if _, err := g.SaveSnapshot(); err != nil {
+					log.Printf("[MyCache] %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// stopSnapshots 停止定期快照并写入最后一次快照，必须在关闭本地缓存之前调用
+func (g *Group) stopSnapshots() {
+	if g.snapshotPath == "" {
+		return
+	}
+	if g.snapshotStop != nil {
+		close(g.snapshotStop)
+		<-g.snapshotDone
+	}
+	if n, err := g.SaveSnapshot(); err != nil {
+		log.Printf("[MyCache] %v", err)
+	} else {
+		log.Printf("[MyCache] saved %d entries for group [%s] to snapshot", n, g.name)
+	}
+}
+
+// writeSnapshot 将缓存项写入临时文件，同步到磁盘后原子替换 path
+//
+// 文件格式：魔数 | 版本号(uint16) | 标志位(uint16) | 创建时间(int64) | 缓存项... | 缓存项数量(uint64) | CRC-32C(uint32)
+// 每个缓存项为：key 长度(uvarint) | key | value 长度(uvarint) | value | 过期时间(varint) | 写入时间(varint)，
+// 时间均为 Unix 纳秒，0 表示未设置。整数使用大端序，校验和覆盖之前的全部内容。
+// cipher 不为 nil 时 value 为密文，并设置 snapshotFlagEncrypted。
+func writeSnapshot(path string, entries []snapshotEntry, cipher *valueCipher) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // 替换成功后文件已不存在
+
+	crc := crc32.New(crcTable)
+	w := bufio.NewWriter(io.MultiWriter(tmp, crc))
+
+	header := make([]byte, 0, snapshotHeaderSize)
+	header = append(header, snapshotMagic...)
+	header = binary.BigEndian.AppendUint16(header, snapshotVersion)
+	var flags uint16
+	if cipher != nil {
+		flags |= snapshotFlagEncrypted
+	}
+	header = binary.BigEndian.AppendUint16(header, flags)
+	header = binary.BigEndian.AppendUint64(header, uint64(time.Now().UnixNano()))
+	w.Write(header)
+
+	buf := make([]byte, 0, 4*binary.MaxVarintLen64)
+	for _, e := range entries {
+		view, err := cipher.sealView(e.view)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		e.view = view
+		buf = binary.AppendUvarint(buf[:0], uint64(len(e.key)))
+		w.Write(buf)
+		w.WriteString(e.key)
+		buf = binary.AppendUvarint(buf[:0], uint64(len(e.view.b)))
+		w.Write(buf)
+		w.Write(e.view.b)
+		buf = binary.AppendVarint(buf[:0], unixNano(e.view.expire))
+		buf = binary.AppendVarint(buf, unixNano(e.view.written))
+		w.Write(buf)
+	}
+	w.Write(binary.BigEndian.AppendUint64(buf[:0], uint64(len(entries))))
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	// 校验和不计入自身
+	if _, err := tmp.Write(crc.Sum(nil)); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	// 同步目录，确保替换本身在断电后仍然有效
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+// readSnapshot 校验快照并按写入顺序对每个缓存项调用 fn
+// 校验和在解析之前检查，损坏的快照不会恢复任何缓存项。加密的快照用 cipher 解密，
+// 无法解密的缓存项被跳过，cipher 为 nil 时返回 ErrSnapshotEncrypted
+func readSnapshot(data []byte, cipher *valueCipher, fn func(key string, view ByteView)) error {
+	if len(data) < snapshotHeaderSize+snapshotTrailerSize || string(data[:len(snapshotMagic)]) != snapshotMagic {
+		return ErrSnapshotCorrupt
+	}
+	body, sum := data[:len(data)-4], data[len(data)-4:]
+	if crc32.Checksum(body, crcTable) != binary.BigEndian.Uint32(sum) {
+		return ErrSnapshotCorrupt
+	}
+	version := binary.BigEndian.Uint16(data[len(snapshotMagic):])
+	if version != snapshotVersion {
+		return fmt.Errorf("%w: %d", ErrSnapshotVersion, version)
+	}
+
+	flags := binary.BigEndian.Uint16(data[len(snapshotMagic)+2:])
+	encrypted := flags&snapshotFlagEncrypted != 0
+	if encrypted && cipher == nil {
+		return ErrSnapshotEncrypted
+	}
+
+	count := binary.BigEndian.Uint64(body[len(body)-8:])
+	p := body[snapshotHeaderSize : len(body)-8]
+	var (
+		read uint64
+		err  error
+	)
+	for len(p) > 0 {
+		key, rest, ok := readSnapshotBytes(p)
+		if !ok {
+			return ErrSnapshotCorrupt
+		}
+		value, rest, ok := readSnapshotBytes(rest)
+		if !ok {
+			return ErrSnapshotCorrupt
+		}
+		expire, n := binary.Varint(rest)
+		if n <= 0 {
+			return ErrSnapshotCorrupt
+		}
+		rest = rest[n:]
+		written, n := binary.Varint(rest)
+		if n <= 0 {
+			return ErrSnapshotCorrupt
+		}
+		p = rest[n:]
+
+		read++
+		view := ByteView{expire: fromUnixNano(expire), written: fromUnixNano(written)}
+		if encrypted {
+			// 解密得到新分配的明文
+			if view.b, err = cipher.open(value); err != nil {
+				continue
+			}
+		} else {
+			// value 引用整个文件的内容，复制一份，避免少量缓存项使整个文件无法回收
+			view.b = cloneBytes(value)
+		}
+		fn(string(key), view)
+	}
+	if read != count {
+		return ErrSnapshotCorrupt
+	}
+	return nil
+}
+
+// readSnapshotBytes 读取带 uvarint 长度前缀的字节串
+func readSnapshotBytes(p []byte) (b, rest []byte, ok bool) {
+	size, n := binary.Uvarint(p)
+	if n <= 0 || size > uint64(len(p)-n) {
+		return nil, nil, false
+	}
+	p = p[n:]
+	return p[:size], p[size:], true
+}
+
+// unixNano 返回 Unix 纳秒时间戳，零值返回 0
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// fromUnixNano 将 Unix 纳秒时间戳转换为时间，0 返回零值
+func fromUnixNano(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
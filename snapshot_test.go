@@ -0,0 +1,233 @@
+package mycache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newSnapshotGroup 创建启用快照、只在关闭时写入快照的组
+func newSnapshotGroup(t *testing.T, name, dir string, opts ...GroupOption) *Group {
+	t.Helper()
+	opts = append([]GroupOption{WithSnapshot(dir, 0)}, opts...)
+	return NewGroup(name, 1<<20, DataSourceFunc(func(ctx context.Context, key string) ([]byte, error) {
+		return nil, errors.New("not found")
+	}), opts...)
+}
+
+// writeTestSnapshot 写入包含 a、b 两个缓存项的快照并返回文件内容
+func writeTestSnapshot(t *testing.T, cipher *valueCipher) []byte {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.snapshot")
+	entries := []snapshotEntry{
+		{key: "a", view: ByteView{b: []byte("value-a")}},
+		{key: "b", view: ByteView{b: []byte("value-b"), expire: time.Now().Add(time.Hour)}},
+	}
+	if err := writeSnapshot(path, entries, cipher); err != nil {
+		t.Fatalf("writeSnapshot 失败: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取快照失败: %v", err)
+	}
+	return data
+}
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	g := newSnapshotGroup(t, "snapshot-roundtrip", dir)
+	g.Set(ctx, "plain", []byte("v1"))
+	g.SetWithTTL(ctx, "ttl", []byte("v2"), time.Hour)
+	want, _ := g.localCache.Get(ctx, "ttl")
+	g.Close() // 关闭时写入快照
+
+	if _, err := os.Stat(g.SnapshotPath()); err != nil {
+		t.Fatalf("关闭时应写入快照: %v", err)
+	}
+
+	// 同名的组在创建时从快照恢复
+	restored := newSnapshotGroup(t, "snapshot-roundtrip", dir)
+	defer restored.Close()
+
+	if view, ok := restored.localCache.Get(ctx, "plain"); !ok || view.String() != "v1" || !view.expire.IsZero() {
+		t.Errorf("应恢复永不过期的 plain=v1，实际为 %q（found=%v，expire=%v）", view.String(), ok, view.expire)
+	}
+	view, ok := restored.localCache.Get(ctx, "ttl")
+	if !ok || view.String() != "v2" {
+		t.Fatalf("应恢复 ttl=v2，实际为 %q（found=%v）", view.String(), ok)
+	}
+	if !view.expire.Equal(want.expire) {
+		t.Errorf("恢复后应保留过期时间 %v，实际为 %v", want.expire, view.expire)
+	}
+	if n := restored.stats.snapshotLoads.Load(); n != 2 {
+		t.Errorf("snapshot_loads 应为 2，实际为 %d", n)
+	}
+}
+
+func TestSnapshot_SkipsExpired(t *testing.T) {
+	dir := t.TempDir()
+	g := newSnapshotGroup(t, "snapshot-expired", dir)
+	defer g.Close()
+
+	now := time.Now()
+	err := writeSnapshot(g.SnapshotPath(), []snapshotEntry{
+		{key: "live", view: ByteView{b: []byte("v"), expire: now.Add(time.Hour)}},
+		{key: "expired", view: ByteView{b: []byte("v"), expire: now.Add(-time.Second)}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("writeSnapshot 失败: %v", err)
+	}
+
+	n, err := g.LoadSnapshot()
+	if err != nil {
+		t.Fatalf("LoadSnapshot 失败: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("应只恢复 1 个未过期的缓存项，实际为 %d 个", n)
+	}
+	if _, ok := g.localCache.Get(context.Background(), "expired"); ok {
+		t.Error("已过期的缓存项不应恢复")
+	}
+}
+
+func TestSnapshot_Corrupt(t *testing.T) {
+	data := writeTestSnapshot(t, nil)
+
+	flipped := append([]byte(nil), data...)
+	flipped[snapshotHeaderSize+3] ^= 0xff
+	// 缓存项数量与实际不符，但校验和正确
+	miscounted := append([]byte(nil), data[:len(data)-4]...)
+	binary.BigEndian.PutUint64(miscounted[len(miscounted)-8:], 3)
+	miscounted = binary.BigEndian.AppendUint32(miscounted, crc32.Checksum(miscounted, crcTable))
+
+	cases := map[string][]byte{
+		"翻转一个字节":   flipped,
+		"截断末尾":     data[:len(data)-1],
+		"只有文件头":    data[:snapshotHeaderSize],
+		"魔数错误":     append([]byte("NOTSNAP\x00"), data[len(snapshotMagic):]...),
+		"缓存项数量不匹配": miscounted,
+	}
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			called := 0
+			err := readSnapshot(data, nil, func(key string, view ByteView) { called++ })
+			if !errors.Is(err, ErrSnapshotCorrupt) {
+				t.Errorf("应返回 ErrSnapshotCorrupt，实际为 %v", err)
+			}
+			if name != "缓存项数量不匹配" && called != 0 {
+				t.Errorf("校验和不匹配时不应恢复任何缓存项，实际恢复 %d 个", called)
+			}
+		})
+	}
+}
+
+func TestSnapshot_CorruptFileLoadsNothing(t *testing.T) {
+	dir := t.TempDir()
+	g := newSnapshotGroup(t, "snapshot-corrupt", dir)
+	defer g.Close()
+
+	data := writeTestSnapshot(t, nil)
+	data[len(data)-5] ^= 0xff
+	if err := os.WriteFile(g.SnapshotPath(), data, 0o644); err != nil {
+		t.Fatalf("写入快照失败: %v", err)
+	}
+
+	n, err := g.LoadSnapshot()
+	if !errors.Is(err, ErrSnapshotCorrupt) {
+		t.Errorf("应返回 ErrSnapshotCorrupt，实际为 %v", err)
+	}
+	if n != 0 || g.localCache.Len() != 0 {
+		t.Errorf("损坏的快照不应恢复任何缓存项，实际恢复 %d 个", n)
+	}
+}
+
+func TestSnapshot_UnsupportedVersion(t *testing.T) {
+	for _, version := range []uint16{1, snapshotVersion + 1} {
+		data := writeTestSnapshot(t, nil)
+		body := data[:len(data)-4]
+		binary.BigEndian.PutUint16(body[len(snapshotMagic):], version)
+		data = binary.BigEndian.AppendUint32(body, crc32.Checksum(body, crcTable))
+
+		err := readSnapshot(data, nil, func(key string, view ByteView) {
+			t.Errorf("版本 %d 不应恢复缓存项 %s", version, key)
+		})
+		if !errors.Is(err, ErrSnapshotVersion) {
+			t.Errorf("版本 %d 应返回 ErrSnapshotVersion，实际为 %v", version, err)
+		}
+	}
+}
+
+func TestSnapshot_Encrypted(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	key := StaticKey([]byte("0123456789abcdef"))
+
+	g := newSnapshotGroup(t, "snapshot-encrypted", dir, WithEncryption(key))
+	g.Set(ctx, "k", []byte("secret-value"))
+	g.Close()
+
+	data, err := os.ReadFile(g.SnapshotPath())
+	if err != nil {
+		t.Fatalf("读取快照失败: %v", err)
+	}
+	if flags := binary.BigEndian.Uint16(data[len(snapshotMagic)+2:]); flags&snapshotFlagEncrypted == 0 {
+		t.Error("启用加密时快照应设置 snapshotFlagEncrypted")
+	}
+	if bytes.Contains(data, []byte("secret-value")) {
+		t.Error("加密的快照中不应出现明文")
+	}
+
+	// 使用同一密钥的组可以恢复
+	restored := newSnapshotGroup(t, "snapshot-encrypted", dir, WithEncryption(key))
+	view, ok := restored.localCache.Get(ctx, "k")
+	if !ok || view.String() != "secret-value" {
+		t.Errorf("应解密恢复 k，实际为 %q（found=%v）", view.String(), ok)
+	}
+	restored.Close()
+}
+
+func TestSnapshot_EncryptedWithoutCipher(t *testing.T) {
+	data := writeTestSnapshot(t, newValueCipher("test", StaticKey([]byte("0123456789abcdef"))))
+
+	err := readSnapshot(data, nil, func(key string, view ByteView) {
+		t.Errorf("没有密钥时不应恢复缓存项 %s", key)
+	})
+	if !errors.Is(err, ErrSnapshotEncrypted) {
+		t.Errorf("应返回 ErrSnapshotEncrypted，实际为 %v", err)
+	}
+
+	// 未启用加密的组读取加密的快照时不恢复任何缓存项
+	g := newSnapshotGroup(t, "snapshot-no-cipher", t.TempDir())
+	defer g.Close()
+	if err := os.WriteFile(g.SnapshotPath(), data, 0o644); err != nil {
+		t.Fatalf("写入快照失败: %v", err)
+	}
+	n, err := g.LoadSnapshot()
+	if !errors.Is(err, ErrSnapshotEncrypted) || n != 0 {
+		t.Errorf("应返回 ErrSnapshotEncrypted 且不恢复缓存项，实际恢复 %d 个，错误为 %v", n, err)
+	}
+}
+
+func TestSnapshot_EncryptedWithWrongKey(t *testing.T) {
+	data := writeTestSnapshot(t, newValueCipher("test", StaticKey([]byte("0123456789abcdef"))))
+
+	// 无法解密的缓存项被跳过，快照本身没有损坏
+	called := 0
+	err := readSnapshot(data, newValueCipher("test", StaticKey([]byte("fedcba9876543210"))), func(key string, view ByteView) {
+		called++
+	})
+	if err != nil {
+		t.Errorf("密钥错误时应跳过缓存项而不是返回错误，实际为 %v", err)
+	}
+	if called != 0 {
+		t.Errorf("密钥错误时不应恢复缓存项，实际恢复 %d 个", called)
+	}
+}
@@ -0,0 +1,134 @@
+package mycache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "github.com/linhx1999/MyCache-Go/pb"
+)
+
+// NodeStats 通过 Stats RPC 查询到的节点统计信息
+type NodeStats struct {
+	Node         string                // 节点注册的地址
+	Uptime       time.Duration         // 服务器启动以来的时间
+	InFlight     int64                 // 正在处理的请求数
+	ShedRequests int64                 // 因过载被丢弃的请求数
+	Groups       map[string]GroupStats // 组名到组统计信息
+	Peers        []PeerStatus          // 节点看到的其他节点及其健康状态
+	Tenants      []TenantUsage         // 租户在节点上的用量，未启用 WithTenants 时为空
+}
+
+// GroupStats 组的统计信息，数值项（命中数、占用字节数等）在 Metrics 中，其他项在 Info 中
+type GroupStats struct {
+	Metrics map[string]float64
+	Info    map[string]string
+}
+
+// Stats 实现Cache服务的Stats方法，返回节点和组的统计信息
+func (s *Server) Stats(ctx context.Context, req *pb.StatsRequest) (*pb.StatsResponse, error) {
+	groups := s.servedGroups()
+	if req.Group != "" {
+		group := s.group(req.Group)
+		if group == nil {
+			return nil, errGroupNotFound(req.Group)
+		}
+		groups = []*Group{group}
+	}
+
+	resp := &pb.StatsResponse{
+		Node:         s.AdvertiseAddr(),
+		InFlight:     s.shedder.inFlight.Load(),
+		ShedRequests: s.ShedRequests(),
+	}
+	if !s.startedAt.IsZero() {
+		resp.UptimeMs = time.Since(s.startedAt).Milliseconds()
+	}
+
+	for _, group := range groups {
+		stats := &pb.GroupStats{
+			Name:    group.name,
+			Metrics: make(map[string]float64),
+			Info:    make(map[string]string),
+		}
+		for key, v := range group.Stats() {
+			if value, ok := metricValue(v); ok {
+				stats.Metrics[key] = value
+			} else {
+				stats.Info[key] = fmt.Sprint(v)
+			}
+		}
+		resp.Groups = append(resp.Groups, stats)
+	}
+
+	for _, status := range s.groupPeerStatus() {
+		resp.Peers = append(resp.Peers, &pb.PeerStats{
+			Addr:                status.Addr,
+			Healthy:             status.Healthy,
+			ConsecutiveFailures: int32(status.ConsecutiveFailures),
+			LastError:           status.LastError,
+		})
+	}
+
+	if s.tenants != nil {
+		// 租户只能看到自己的用量
+		for _, usage := range s.tenants.usage(TenantFromContext(ctx)) {
+			resp.Tenants = append(resp.Tenants, &pb.TenantStats{
+				Name:     usage.Tenant,
+				Bytes:    usage.Bytes,
+				Keys:     usage.Keys,
+				Requests: usage.Requests,
+				Rejected: usage.Rejected,
+				MaxBytes: usage.Quota.MaxBytes,
+				MaxKeys:  usage.Quota.MaxKeys,
+				Qps:      usage.Quota.QPS,
+			})
+		}
+	}
+	return resp, nil
+}
+
+// Stats 查询节点的统计信息，group 为空时返回所有组
+func (c *Client) Stats(ctx context.Context, group string) (NodeStats, error) {
+	ctx, cancel := withTimeout(ctx, c.opts.timeouts.Get)
+	defer cancel()
+
+	var resp *pb.StatsResponse
+	err := c.invoke(ctx, true, func(ctx context.Context, cli pb.CacheServiceClient) (err error) {
+		resp, err = cli.Stats(ctx, &pb.StatsRequest{Group: group})
+		return err
+	})
+	if err != nil {
+		return NodeStats{}, fmt.Errorf("failed to get stats from cache: %w", err)
+	}
+
+	stats := NodeStats{
+		Node:         resp.GetNode(),
+		Uptime:       time.Duration(resp.GetUptimeMs()) * time.Millisecond,
+		InFlight:     resp.GetInFlight(),
+		ShedRequests: resp.GetShedRequests(),
+		Groups:       make(map[string]GroupStats, len(resp.GetGroups())),
+	}
+	for _, g := range resp.GetGroups() {
+		stats.Groups[g.GetName()] = GroupStats{Metrics: g.GetMetrics(), Info: g.GetInfo()}
+	}
+	for _, p := range resp.GetPeers() {
+		stats.Peers = append(stats.Peers, PeerStatus{
+			Addr:                p.GetAddr(),
+			Healthy:             p.GetHealthy(),
+			ConsecutiveFailures: int(p.GetConsecutiveFailures()),
+			LastError:           p.GetLastError(),
+		})
+	}
+	for _, t := range resp.GetTenants() {
+		stats.Tenants = append(stats.Tenants, TenantUsage{
+			Tenant:   t.GetName(),
+			Bytes:    t.GetBytes(),
+			Keys:     t.GetKeys(),
+			Requests: t.GetRequests(),
+			Rejected: t.GetRejected(),
+			Quota:    TenantQuota{MaxBytes: t.GetMaxBytes(), MaxKeys: t.GetMaxKeys(), QPS: t.GetQps()},
+		})
+	}
+	return stats, nil
+}
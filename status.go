@@ -0,0 +1,223 @@
+package mycache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain 错误详情中 ErrorInfo 的域
+const errorDomain = "mycache"
+
+var (
+	// ErrGroupNotFound 请求的缓存组不存在
+	ErrGroupNotFound = errors.New("cache: group not found")
+	// ErrNotFound key 不存在，DataSource 可以返回该错误（或包装它）表示数据源中没有该 key，
+	// 客户端收到 NotFound 状态时同样返回该错误
+	ErrNotFound = errors.New("cache: key not found")
+	// ErrPeerUnavailable 节点不可达、正在关闭或暂时无法处理请求
+	ErrPeerUnavailable = errors.New("cache: peer unavailable")
+)
+
+// errGroupNotFound 返回组不存在的 NotFound 状态，详情中包含组名
+func errGroupNotFound(name string) error {
+	msg := fmt.Sprintf("%v: %s", ErrGroupNotFound, name)
+	st, err := status.New(codes.NotFound, msg).WithDetails(&errdetails.ResourceInfo{
+		ResourceType: "group",
+		ResourceName: name,
+	})
+	if err != nil {
+		return status.Error(codes.NotFound, msg)
+	}
+	return st.Err()
+}
+
+// toStatusError 将缓存错误转换为带有错误详情的 gRPC 状态
+// 已经是 gRPC 状态的错误原样返回
+func toStatusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var policyErr *PolicyViolationError
+	switch {
+	case errors.As(err, &policyErr):
+		return policyStatus(policyErr)
+	case errors.Is(err, ErrNotFound):
+		return errorInfo(codes.NotFound, err, "KEY_NOT_FOUND")
+	case errors.Is(err, ErrKeyRequired):
+		return badRequest(err, "key")
+	case errors.Is(err, ErrValueRequired):
+		return badRequest(err, "value")
+	case errors.Is(err, ErrValueTooLarge):
+		return errorInfo(codes.ResourceExhausted, err, "VALUE_TOO_LARGE")
+	case errors.Is(err, ErrQuotaExceeded):
+		return errorInfo(codes.ResourceExhausted, err, "QUOTA_EXCEEDED")
+	case errors.Is(err, ErrRateLimited):
+		return errorInfo(codes.ResourceExhausted, err, "RATE_LIMITED")
+	case errors.Is(err, ErrPeerUnavailable):
+		return errorInfo(codes.Unavailable, err, "PEER_UNAVAILABLE")
+	case errors.Is(err, ErrGroupClosed):
+		return errorInfo(codes.Unavailable, err, "GROUP_CLOSED")
+	case errors.Is(err, ErrConsistencyNotMet):
+		return errorInfo(codes.Unavailable, err, "CONSISTENCY_NOT_MET")
+	case errors.Is(err, ErrLockHeld):
+		return errorInfo(codes.FailedPrecondition, err, "LOCK_HELD")
+	case errors.Is(err, ErrLockLost):
+		return errorInfo(codes.FailedPrecondition, err, "LOCK_LOST")
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	default:
+		return status.Error(codes.Unknown, err.Error())
+	}
+}
+
+// statusError 客户端收到的 RPC 错误，可通过 errors.Is 与对应的哨兵错误比较，
+// 同时保留原始的 gRPC 状态，status.FromError 仍然可用
+type statusError struct {
+	err   error   // 原始的 RPC 错误
+	kinds []error // 对应的哨兵错误
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() []error {
+	return append(e.kinds, e.err)
+}
+
+// GRPCStatus 返回原始的 gRPC 状态
+func (e *statusError) GRPCStatus() *status.Status {
+	return status.Convert(e.err)
+}
+
+// fromStatusError 将 RPC 错误转换为可与 errors.Is 比较的错误，与 toStatusError 对应
+//
+//   - NotFound：ErrGroupNotFound 或 ErrNotFound
+//   - ResourceExhausted：ErrValueTooLarge、ErrQuotaExceeded 或 ErrRateLimited
+//   - Unavailable：ErrGroupClosed、ErrConsistencyNotMet 或 ErrPeerUnavailable
+//   - FailedPrecondition：ErrLockHeld 或 ErrLockLost
+//   - Unauthenticated：ErrUnauthenticated
+//   - DeadlineExceeded、Canceled：context.DeadlineExceeded、context.Canceled，
+//     因一直连接不上节点而超时的同时也是 ErrPeerUnavailable
+//
+// 违反 KeyPolicy 的 InvalidArgument 状态由 wrapSizeError 还原为 *PolicyViolationError，
+// 无法识别的错误原样返回。
+func fromStatusError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok || err == nil {
+		return err
+	}
+
+	var kind error
+	switch st.Code() {
+	case codes.NotFound:
+		kind = ErrNotFound
+		for _, detail := range st.Details() {
+			if info, ok := detail.(*errdetails.ResourceInfo); ok && info.GetResourceType() == "group" {
+				kind = ErrGroupNotFound
+			}
+		}
+	case codes.ResourceExhausted:
+		switch statusReason(err) {
+		case "QUOTA_EXCEEDED":
+			kind = ErrQuotaExceeded
+		case "RATE_LIMITED":
+			kind = ErrRateLimited
+		default:
+			if isMessageTooLarge(err) {
+				kind = ErrValueTooLarge
+			}
+		}
+	case codes.Unavailable:
+		switch statusReason(err) {
+		case "GROUP_CLOSED":
+			kind = ErrGroupClosed
+		case "CONSISTENCY_NOT_MET":
+			kind = ErrConsistencyNotMet
+		default:
+			kind = ErrPeerUnavailable
+		}
+	case codes.FailedPrecondition:
+		switch statusReason(err) {
+		case "LOCK_HELD":
+			kind = ErrLockHeld
+		case "LOCK_LOST":
+			kind = ErrLockLost
+		}
+	case codes.Unauthenticated:
+		kind = ErrUnauthenticated
+	case codes.DeadlineExceeded:
+		kind = context.DeadlineExceeded
+		if strings.Contains(st.Message(), "connection error") {
+			// WaitForReady 的请求在连接建立之前超时
+			return &statusError{err: err, kinds: []error{kind, ErrPeerUnavailable}}
+		}
+	case codes.Canceled:
+		kind = context.Canceled
+	}
+	if kind == nil {
+		return err
+	}
+	return &statusError{err: err, kinds: []error{kind}}
+}
+
+// badRequest 返回 InvalidArgument 状态，详情中包含出错的字段
+func badRequest(err error, field string) error {
+	st, detailErr := status.New(codes.InvalidArgument, err.Error()).WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: field, Description: err.Error()}},
+	})
+	if detailErr != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return st.Err()
+}
+
+// errorInfo 返回指定状态码的状态，详情中包含机器可读的错误原因
+func errorInfo(code codes.Code, err error, reason string) error {
+	st, detailErr := status.New(code, err.Error()).WithDetails(&errdetails.ErrorInfo{
+		Reason: reason,
+		Domain: errorDomain,
+	})
+	if detailErr != nil {
+		return status.Error(code, err.Error())
+	}
+	return st.Err()
+}
+
+// statusReason 返回状态详情中 ErrorInfo 的原因，没有时返回空字符串
+func statusReason(err error) string {
+	st, ok := status.FromError(err)
+	if !ok {
+		return ""
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.GetDomain() == errorDomain {
+			return info.GetReason()
+		}
+	}
+	return ""
+}
+
+// statusUnaryInterceptor 将一元请求处理函数返回的错误转换为 gRPC 状态
+func statusUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	return resp, toStatusError(err)
+}
+
+// statusStreamInterceptor 将流式请求处理函数返回的错误转换为 gRPC 状态
+func statusStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return toStatusError(handler(srv, ss))
+}
@@ -0,0 +1,204 @@
+package arena
+
+import (
+	"errors"
+	"hash/maphash"
+	"time"
+
+	"github.com/linhx1999/MyCache-Go/store/common"
+)
+
+// ErrTooLarge 值超过了单个分片的容量，没有写入
+var ErrTooLarge = errors.New("arena: value too large")
+
+// ArenaCache 将 key 和值保存在预先分配的大块字节数组（段）中的缓存
+//
+// 每个缓存项在段中顺序写入为 过期时间 | key 长度 | 值长度 | key | 值，索引只保存 key 的哈希到
+// 段和偏移的映射，不含指针。缓存数百万个值时 GC 只需要扫描少量的段，而不是每个值各自的 []byte。
+// 读取时 Codec.Decode 直接引用段中的字节，不发生拷贝。
+//
+// 淘汰以段为单位：容量用尽时丢弃最早的段，其中写入后被访问过的缓存项重新写入最新的段（second chance），
+// 其余的被淘汰。段的内存从不复用，丢弃的段在不再被读取到的值引用后由 GC 回收，已返回的值始终有效。
+// 覆盖和删除只更新索引，旧数据在所在的段被丢弃时才释放，因此 maxBytes 限制的是段的总大小，
+// UsedBytes 返回的是有效 key 和值的字节数。
+type ArenaCache struct {
+	seed      maphash.Seed
+	shards    []*shard
+	codec     common.Codec
+	onEvicted func(key string, value common.Value) // 淘汰回调函数，调用时持有分片的锁
+
+	cleanupTicker *time.Ticker  // 定时器，用于触发定期清理任务
+	doneCh        chan struct{} // 用于优雅关闭清理协程
+}
+
+// shardOf 返回 key 的哈希及其所在的分片
+func (c *ArenaCache) shardOf(key string) (uint64, *shard) {
+	h := maphash.String(c.seed, key)
+	return h, c.shards[h%uint64(len(c.shards))]
+}
+
+// Get 获取缓存项，已过期时删除并返回未命中
+func (c *ArenaCache) Get(key string) (common.Value, bool) {
+	h, s := c.shardOf(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.get(h, key, time.Now().UnixNano())
+	if !ok {
+		return nil, false
+	}
+	value, err := c.codec.Decode(data)
+	if err != nil {
+		s.delete(h, key, false)
+		return nil, false
+	}
+	return value, true
+}
+
+// Set 添加或更新缓存项
+func (c *ArenaCache) Set(key string, value common.Value) error {
+	return c.SetWithExpiration(key, value, 0)
+}
+
+// SetWithExpiration 添加或更新缓存项，并设置过期时间
+func (c *ArenaCache) SetWithExpiration(key string, value common.Value, expiration time.Duration) error {
+	if value == nil {
+		c.Delete(key)
+		return nil
+	}
+	data, _, err := c.codec.Encode(value)
+	if err != nil {
+		return err
+	}
+
+	var expire int64
+	if expiration > 0 {
+		expire = time.Now().Add(expiration).UnixNano()
+	}
+
+	h, s := c.shardOf(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set(h, key, data, expire)
+}
+
+// Delete 从缓存中删除指定键的项
+func (c *ArenaCache) Delete(key string) bool {
+	h, s := c.shardOf(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.delete(h, key, true)
+}
+
+// Clear 清空缓存
+func (c *ArenaCache) Clear() {
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.clear()
+		s.mu.Unlock()
+	}
+}
+
+// Range 遍历缓存中所有未过期的项，各分片内按写入顺序
+// 遍历的是每个分片在访问时的快照，fn 在锁外调用
+func (c *ArenaCache) Range(fn func(key string, value common.Value) bool) {
+	type rangeEntry struct {
+		key   string
+		value common.Value
+	}
+
+	for _, s := range c.shards {
+		s.mu.Lock()
+		now := time.Now().UnixNano()
+		entries := make([]rangeEntry, 0, len(s.index))
+		s.scan(func(_ uint64, _ ref, e entry) {
+			if e.expired(now) {
+				return
+			}
+			if value, err := c.codec.Decode(e.value); err == nil {
+				entries = append(entries, rangeEntry{key: string(e.key), value: value})
+			}
+		})
+		s.mu.Unlock()
+
+		for _, e := range entries {
+			if !fn(e.key, e.value) {
+				return
+			}
+		}
+	}
+}
+
+// Len 返回缓存中的项数
+func (c *ArenaCache) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		n += len(s.index)
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// UsedBytes 返回有效的 key 和值占用的字节数
+func (c *ArenaCache) UsedBytes() int64 {
+	var n int64
+	for _, s := range c.shards {
+		s.mu.Lock()
+		n += s.usedBytes
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// ArenaBytes 返回已分配的段的总字节数，包括已失效但尚未释放的数据
+func (c *ArenaCache) ArenaBytes() int64 {
+	var n int64
+	for _, s := range c.shards {
+		s.mu.Lock()
+		n += s.allocated
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// PurgeExpired 立即清理所有已过期的项，返回清理的数量
+func (c *ArenaCache) PurgeExpired() int {
+	purged := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		purged += s.purgeExpired(time.Now().UnixNano())
+		s.mu.Unlock()
+	}
+	return purged
+}
+
+// Close 关闭缓存，停止清理协程
+func (c *ArenaCache) Close() {
+	if c.cleanupTicker != nil {
+		c.cleanupTicker.Stop()
+		close(c.doneCh)
+	}
+}
+
+// notify 解码值并调用淘汰回调
+func (c *ArenaCache) notify(key string, data []byte) {
+	if c.onEvicted == nil {
+		return
+	}
+	if value, err := c.codec.Decode(data); err == nil {
+		c.onEvicted(key, value)
+	}
+}
+
+// cleanupLoop 定期清理过期缓存的协程
+func (c *ArenaCache) cleanupLoop() {
+	for {
+		select {
+		case <-c.cleanupTicker.C:
+			c.PurgeExpired()
+		case <-c.doneCh:
+			return
+		}
+	}
+}
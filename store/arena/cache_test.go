@@ -0,0 +1,223 @@
+package arena
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/linhx1999/MyCache-Go/store/common"
+)
+
+// ============================================================================
+// 测试辅助类型和函数
+// ============================================================================
+
+// testValue 为测试定义一个简单的 Value 类型
+type testValue []byte
+
+func (v testValue) Len() int {
+	return len(v)
+}
+
+// testCodec 直接以值的字节作为编码，解码时引用 arena 中的字节
+type testCodec struct{}
+
+func (testCodec) Encode(value common.Value) ([]byte, time.Time, error) {
+	v, ok := value.(testValue)
+	if !ok {
+		return nil, time.Time{}, fmt.Errorf("unexpected value type %T", value)
+	}
+	return []byte(v), time.Time{}, nil
+}
+
+func (testCodec) Decode(data []byte) (common.Value, error) {
+	return testValue(data), nil
+}
+
+// newTestCache 创建测试用的缓存，记录被淘汰的 key
+func newTestCache(maxBytes int64) (*ArenaCache, *[]string) {
+	var evicted []string
+	c := New(maxBytes, time.Hour, testCodec{}, func(key string, value common.Value) {
+		evicted = append(evicted, key)
+	})
+	return c, &evicted
+}
+
+// ============================================================================
+// ArenaCache 测试
+// ============================================================================
+
+// TestArenaCache_BasicOperations 测试基本的读写删除
+func TestArenaCache_BasicOperations(t *testing.T) {
+	c, evicted := newTestCache(1 << 20)
+	defer c.Close()
+
+	if err := c.Set("a", testValue("1")); err != nil {
+		t.Fatalf("Set 失败: %v", err)
+	}
+	c.Set("b", testValue("22"))
+
+	if v, ok := c.Get("a"); !ok || string(v.(testValue)) != "1" {
+		t.Fatalf("Get(a) = %v, %v", v, ok)
+	}
+	if _, ok := c.Get("missing"); ok {
+		t.Fatal("不存在的 key 应未命中")
+	}
+	if c.Len() != 2 || c.UsedBytes() != 5 {
+		t.Fatalf("Len/UsedBytes = %d/%d，期望 2/5", c.Len(), c.UsedBytes())
+	}
+
+	// 覆盖同名 key 不触发淘汰回调
+	c.Set("a", testValue("333"))
+	if v, _ := c.Get("a"); string(v.(testValue)) != "333" {
+		t.Fatalf("覆盖后 Get(a) = %s", v)
+	}
+	if len(*evicted) != 0 || c.UsedBytes() != 7 {
+		t.Fatalf("覆盖后 evicted=%v UsedBytes=%d", *evicted, c.UsedBytes())
+	}
+
+	if !c.Delete("a") || c.Delete("a") {
+		t.Fatal("Delete 应只成功一次")
+	}
+	if len(*evicted) != 1 || (*evicted)[0] != "a" {
+		t.Fatalf("删除应触发回调，evicted=%v", *evicted)
+	}
+
+	c.Clear()
+	if c.Len() != 0 || c.UsedBytes() != 0 || c.ArenaBytes() != 0 {
+		t.Fatalf("Clear 后 Len/UsedBytes/ArenaBytes = %d/%d/%d", c.Len(), c.UsedBytes(), c.ArenaBytes())
+	}
+}
+
+// TestArenaCache_ValueStableAfterEviction 测试段被丢弃后，之前读到的值仍然有效
+func TestArenaCache_ValueStableAfterEviction(t *testing.T) {
+	c, _ := newTestCache(64 * 1024)
+	defer c.Close()
+
+	c.Set("keep", testValue("original"))
+	v, _ := c.Get("keep")
+	c.Delete("keep")
+
+	for i := 0; i < 2000; i++ {
+		c.Set(strconv.Itoa(i), testValue(make([]byte, 100)))
+	}
+	if string(v.(testValue)) != "original" {
+		t.Fatalf("段被丢弃后值被修改: %q", v)
+	}
+}
+
+// TestArenaCache_Eviction 测试容量用尽时按段淘汰，访问过的缓存项获得第二次机会
+func TestArenaCache_Eviction(t *testing.T) {
+	c, evicted := newTestCache(64 * 1024)
+	defer c.Close()
+
+	value := testValue(make([]byte, 200))
+	c.Set("hot", value)
+	for i := 0; i < 5000; i++ {
+		c.Set(strconv.Itoa(i), value)
+		if i%50 == 0 {
+			c.Get("hot")
+		}
+	}
+
+	if _, ok := c.Get("hot"); !ok {
+		t.Fatal("经常访问的 key 不应被淘汰")
+	}
+	if len(*evicted) == 0 {
+		t.Fatal("超出容量时应淘汰缓存项")
+	}
+	if c.ArenaBytes() > 64*1024 {
+		t.Fatalf("ArenaBytes = %d，超过了容量", c.ArenaBytes())
+	}
+}
+
+// TestArenaCache_LargeValue 测试超过段大小的值单独占用一个段，超过分片容量的值被拒绝
+func TestArenaCache_LargeValue(t *testing.T) {
+	c, _ := newTestCache(64 * 1024)
+	defer c.Close()
+
+	large := testValue(make([]byte, 20*1024))
+	if err := c.Set("large", large); err != nil {
+		t.Fatalf("Set 失败: %v", err)
+	}
+	if v, ok := c.Get("large"); !ok || v.Len() != len(large) {
+		t.Fatal("大于段的值应能读取")
+	}
+	if err := c.Set("huge", testValue(make([]byte, 128*1024))); err != ErrTooLarge {
+		t.Fatalf("超过容量的值应返回 ErrTooLarge，实际为 %v", err)
+	}
+}
+
+// TestArenaCache_Expiration 测试过期
+func TestArenaCache_Expiration(t *testing.T) {
+	c, evicted := newTestCache(1 << 20)
+	defer c.Close()
+
+	c.SetWithExpiration("short", testValue("1"), 10*time.Millisecond)
+	c.SetWithExpiration("short2", testValue("1"), 10*time.Millisecond)
+	c.SetWithExpiration("long", testValue("2"), time.Hour)
+	time.Sleep(20 * time.Millisecond)
+
+	if _, ok := c.Get("short"); ok {
+		t.Fatal("已过期的 key 应未命中")
+	}
+	if _, ok := c.Get("long"); !ok {
+		t.Fatal("未过期的 key 应命中")
+	}
+	if n := c.PurgeExpired(); n != 1 {
+		t.Fatalf("PurgeExpired = %d，期望 1", n)
+	}
+	if len(*evicted) != 2 || c.Len() != 1 {
+		t.Fatalf("evicted=%v Len=%d", *evicted, c.Len())
+	}
+}
+
+// TestArenaCache_Range 测试遍历跳过已删除和已过期的项
+func TestArenaCache_Range(t *testing.T) {
+	c, _ := newTestCache(1 << 20)
+	defer c.Close()
+
+	for i := 0; i < 10; i++ {
+		c.Set(strconv.Itoa(i), testValue(strconv.Itoa(i)))
+	}
+	c.Delete("3")
+	c.SetWithExpiration("4", testValue("4"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	seen := make(map[string]bool)
+	c.Range(func(key string, value common.Value) bool {
+		if string(value.(testValue)) != key {
+			t.Errorf("key %s 的值为 %s", key, value)
+		}
+		seen[key] = true
+		return true
+	})
+	if len(seen) != 8 || seen["3"] || seen["4"] {
+		t.Fatalf("Range 遍历到 %v", seen)
+	}
+}
+
+// TestArenaCache_Concurrent 测试并发读写
+func TestArenaCache_Concurrent(t *testing.T) {
+	c, _ := newTestCache(256 * 1024)
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				key := strconv.Itoa((w*2000 + i) % 1000)
+				if i%3 == 0 {
+					c.Set(key, testValue(key))
+				} else if v, ok := c.Get(key); ok && string(v.(testValue)) != key {
+					t.Errorf("key %s 的值为 %s", key, v)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
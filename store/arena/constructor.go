@@ -0,0 +1,63 @@
+package arena
+
+import (
+	"hash/maphash"
+	"time"
+
+	"github.com/linhx1999/MyCache-Go/store/common"
+)
+
+const (
+	// defaultMaxBytes 默认的最大字节数
+	defaultMaxBytes = 8 * 1024 * 1024 // 8MB
+	// maxShards 分片数量的上限，容量较小时按每 1MB 一个分片减少，避免单个分片过小
+	maxShards = 16
+	// segmentsPerShard 每个分片划分的段数，淘汰以段为单位，段越多淘汰粒度越细
+	segmentsPerShard = 8
+	// minSegmentSize 段的最小字节数
+	minSegmentSize = 4 * 1024
+	// maxSegmentSize 段的最大字节数，超过的值单独占用一个段
+	maxSegmentSize = 64 * 1024 * 1024
+)
+
+// New 创建一个新的 arena 缓存实例，codec 负责值与 arena 中字节之间的转换，不能为 nil
+func New(maxBytes int64, cleanupInterval time.Duration, codec common.Codec, onEvicted func(string, common.Value)) *ArenaCache {
+	if codec == nil {
+		panic("arena: nil codec")
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBytes
+	}
+	if cleanupInterval <= 0 {
+		cleanupInterval = time.Minute
+	}
+
+	shardCount := int64(maxShards)
+	if n := maxBytes >> 20; n < shardCount {
+		shardCount = max(n, 1)
+	}
+	shardBytes := maxBytes / shardCount
+	segmentSize := min(max(shardBytes/segmentsPerShard, minSegmentSize), maxSegmentSize)
+
+	c := &ArenaCache{
+		seed:      maphash.MakeSeed(),
+		shards:    make([]*shard, shardCount),
+		codec:     codec,
+		onEvicted: onEvicted,
+		doneCh:    make(chan struct{}),
+	}
+	for i := range c.shards {
+		c.shards[i] = &shard{
+			cache:       c,
+			index:       make(map[uint64]ref),
+			maxBytes:    shardBytes,
+			segmentSize: int(segmentSize),
+		}
+	}
+
+	// 启动定期清理协程
+	c.cleanupTicker = time.NewTicker(cleanupInterval)
+	go c.cleanupLoop()
+
+	return c
+}
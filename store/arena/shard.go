@@ -0,0 +1,262 @@
+package arena
+
+import (
+	"encoding/binary"
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// headerSize 段中每个缓存项的头部：过期时间(8) | key 长度(4) | 值长度(4)
+const headerSize = 16
+
+// ref 缓存项在分片中的位置，不含指针，索引不需要被 GC 扫描
+type ref struct {
+	segment  uint64 // 段的编号
+	offset   uint32 // 缓存项在段中的偏移
+	accessed bool   // 写入后是否被访问过，决定段被丢弃时是否重新写入
+}
+
+// segment 一块连续的字节数组，缓存项从头到尾顺序写入
+type segment struct {
+	id   uint64
+	data []byte
+	used int // 已写入的字节数
+}
+
+// entry 段中的一个缓存项，key 和 value 引用段的内存
+type entry struct {
+	expire int64 // Unix 纳秒，0 表示永不过期
+	key    []byte
+	value  []byte
+	size   int // 在段中占用的字节数
+}
+
+// expired 判断缓存项在 now 时是否已过期
+func (e entry) expired(now int64) bool {
+	return e.expire > 0 && now >= e.expire
+}
+
+// readEntry 读取 seg 中 offset 处的缓存项
+func (seg *segment) readEntry(offset int) entry {
+	data := seg.data[offset:]
+	keyLen := int(binary.LittleEndian.Uint32(data[8:12]))
+	valueLen := int(binary.LittleEndian.Uint32(data[12:16]))
+	keyEnd := headerSize + keyLen
+	valueEnd := keyEnd + valueLen
+	return entry{
+		expire: int64(binary.LittleEndian.Uint64(data[0:8])),
+		key:    data[headerSize:keyEnd:keyEnd],
+		value:  data[keyEnd:valueEnd:valueEnd],
+		size:   valueEnd,
+	}
+}
+
+// pendingEntry 被丢弃的段中等待重新写入的缓存项，value 引用旧段的内存
+type pendingEntry struct {
+	hash   uint64
+	key    string
+	value  []byte
+	expire int64
+}
+
+// shard 一个分片，拥有独立的锁、索引和段
+// 除 cache 外的字段都由 mu 保护
+type shard struct {
+	mu    sync.Mutex
+	cache *ArenaCache
+
+	index       map[uint64]ref // key 的哈希到位置，哈希冲突时新写入的 key 覆盖旧的
+	segments    []*segment     // 按编号从旧到新排列，编号连续
+	nextID      uint64
+	allocated   int64 // 所有段的字节数
+	maxBytes    int64
+	segmentSize int
+	usedBytes   int64 // 有效的 key 和值的字节数
+
+	pending []pendingEntry // 丢弃段时等待重新写入的缓存项
+}
+
+// lookup 返回 r 指向的缓存项
+func (s *shard) lookup(r ref) (*segment, entry) {
+	seg := s.segments[r.segment-s.segments[0].id]
+	return seg, seg.readEntry(int(r.offset))
+}
+
+// get 返回 key 的值，已过期时删除
+func (s *shard) get(h uint64, key string, now int64) ([]byte, bool) {
+	r, ok := s.index[h]
+	if !ok {
+		return nil, false
+	}
+	_, e := s.lookup(r)
+	if string(e.key) != key {
+		return nil, false
+	}
+	if e.expired(now) {
+		s.remove(h, e, true)
+		return nil, false
+	}
+	if !r.accessed {
+		r.accessed = true
+		s.index[h] = r
+	}
+	return e.value, true
+}
+
+// set 写入 key，覆盖同名的旧值；与其他 key 哈希冲突时淘汰旧的 key
+func (s *shard) set(h uint64, key string, value []byte, expire int64) error {
+	if r, ok := s.index[h]; ok {
+		_, e := s.lookup(r)
+		s.remove(h, e, string(e.key) != key)
+	}
+	if int64(headerSize+len(key)+len(value)) > s.maxBytes {
+		return ErrTooLarge
+	}
+
+	s.write(h, key, value, expire, false)
+	s.drainPending()
+	return nil
+}
+
+// delete 删除 key，notify 为 true 时调用淘汰回调
+func (s *shard) delete(h uint64, key string, notify bool) bool {
+	r, ok := s.index[h]
+	if !ok {
+		return false
+	}
+	_, e := s.lookup(r)
+	if string(e.key) != key {
+		return false
+	}
+	s.remove(h, e, notify)
+	return true
+}
+
+// remove 从索引中移除缓存项，段中的数据在段被丢弃时释放
+func (s *shard) remove(h uint64, e entry, notify bool) {
+	delete(s.index, h)
+	s.usedBytes -= int64(len(e.key) + len(e.value))
+	if notify {
+		s.cache.notify(string(e.key), e.value)
+	}
+}
+
+// write 将缓存项追加到最新的段并更新索引
+func (s *shard) write(h uint64, key string, value []byte, expire int64, accessed bool) {
+	size := headerSize + len(key) + len(value)
+	seg := s.alloc(size)
+	offset := seg.used
+
+	data := seg.data[offset : offset+size]
+	binary.LittleEndian.PutUint64(data[0:8], uint64(expire))
+	binary.LittleEndian.PutUint32(data[8:12], uint32(len(key)))
+	binary.LittleEndian.PutUint32(data[12:16], uint32(len(value)))
+	copy(data[headerSize:], key)
+	copy(data[headerSize+len(key):], value)
+	seg.used += size
+
+	s.index[h] = ref{segment: seg.id, offset: uint32(offset), accessed: accessed}
+	s.usedBytes += int64(len(key) + len(value))
+}
+
+// alloc 返回剩余空间不少于 size 的最新段，需要时分配新段并丢弃最早的段
+// 超过段大小的缓存项单独占用一个段
+func (s *shard) alloc(size int) *segment {
+	for {
+		if n := len(s.segments); n > 0 {
+			if cur := s.segments[n-1]; len(cur.data)-cur.used >= size {
+				return cur
+			}
+		}
+
+		segmentSize := max(s.segmentSize, size)
+		if s.allocated+int64(segmentSize) <= s.maxBytes || len(s.segments) == 0 {
+			seg := &segment{id: s.nextID, data: make([]byte, segmentSize)}
+			s.nextID++
+			s.segments = append(s.segments, seg)
+			s.allocated += int64(segmentSize)
+			return seg
+		}
+		s.evictOldest()
+	}
+}
+
+// evictOldest 丢弃最早的段，其中写入后被访问过且未过期的缓存项放入 pending 等待重新写入，其余的被淘汰
+// 段的内存不会复用，pending 和已返回的值可以继续引用
+func (s *shard) evictOldest() {
+	seg := s.segments[0]
+	s.segments[0] = nil
+	s.segments = s.segments[1:]
+	s.allocated -= int64(len(seg.data))
+
+	now := time.Now().UnixNano()
+	for offset := 0; offset < seg.used; {
+		e := seg.readEntry(offset)
+		h := maphash.Bytes(s.cache.seed, e.key)
+		if r, ok := s.index[h]; ok && r.segment == seg.id && int(r.offset) == offset {
+			if r.accessed && !e.expired(now) {
+				s.remove(h, e, false)
+				s.pending = append(s.pending, pendingEntry{hash: h, key: string(e.key), value: e.value, expire: e.expire})
+			} else {
+				s.remove(h, e, true)
+			}
+		}
+		offset += e.size
+	}
+}
+
+// drainPending 重新写入 pending 中的缓存项，写入时可能丢弃更多的段并产生新的 pending
+// 重新写入的缓存项清除访问标记，下次所在的段被丢弃时如果没有再被访问就会被淘汰，因此一定会结束
+func (s *shard) drainPending() {
+	for len(s.pending) > 0 {
+		p := s.pending[0]
+		s.pending[0] = pendingEntry{}
+		s.pending = s.pending[1:]
+
+		if _, taken := s.index[p.hash]; taken {
+			// 等待期间有哈希冲突的 key 写入
+			s.cache.notify(p.key, p.value)
+			continue
+		}
+		s.write(p.hash, p.key, p.value, p.expire, false)
+	}
+	s.pending = nil
+}
+
+// scan 按写入顺序遍历所有有效的缓存项
+func (s *shard) scan(fn func(h uint64, r ref, e entry)) {
+	for _, seg := range s.segments {
+		for offset := 0; offset < seg.used; {
+			e := seg.readEntry(offset)
+			h := maphash.Bytes(s.cache.seed, e.key)
+			if r, ok := s.index[h]; ok && r.segment == seg.id && int(r.offset) == offset {
+				fn(h, r, e)
+			}
+			offset += e.size
+		}
+	}
+}
+
+// purgeExpired 清理已过期的项，返回清理的数量
+func (s *shard) purgeExpired(now int64) int {
+	purged := 0
+	for h, r := range s.index {
+		if _, e := s.lookup(r); e.expired(now) {
+			s.remove(h, e, true)
+			purged++
+		}
+	}
+	return purged
+}
+
+// clear 淘汰所有缓存项并释放所有段
+func (s *shard) clear() {
+	s.scan(func(_ uint64, _ ref, e entry) {
+		s.cache.notify(string(e.key), e.value)
+	})
+	s.index = make(map[uint64]ref)
+	s.segments = nil
+	s.allocated = 0
+	s.usedBytes = 0
+}
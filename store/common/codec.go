@@ -0,0 +1,11 @@
+package common
+
+import "time"
+
+// Codec 在值与写入磁盘层或 arena 的字节之间转换
+type Codec interface {
+	// Encode 编码值，同时返回值自身记录的过期时间，零值表示永不过期
+	Encode(value Value) (data []byte, expireAt time.Time, err error)
+	// Decode 解码 Encode 返回的字节，data 在返回后不会被修改，可以直接引用而不拷贝
+	Decode(data []byte) (Value, error)
+}
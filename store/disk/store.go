@@ -0,0 +1,445 @@
+package disk
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/linhx1999/MyCache-Go/store/common"
+)
+
+const (
+	// defaultMaxBytes 默认的磁盘容量
+	defaultMaxBytes = 1 << 30 // 1GB
+	// compactMinGarbage 触发整理的最小无效数据量，避免频繁整理小文件
+	compactMinGarbage = 16 << 20 // 16MB
+)
+
+// ErrExpired 写入的值已经过期，没有写入
+var ErrExpired = errors.New("disk: value already expired")
+
+// Codec 在值与写入磁盘的字节之间转换（类型别名），Decode 收到的 data 为新分配的缓冲区
+type Codec = common.Codec
+
+// record 缓存项在数据文件中的位置
+type record struct {
+	key    string
+	offset int64
+	size   int
+	expire time.Time // 零值表示永不过期
+}
+
+// Store 基于本地磁盘的缓存存储，容量超过内存时作为内存缓存之后的第三级
+//
+// 值追加写入数据文件，内存中只保存 key 到文件位置的索引，按 LRU 淘汰。删除和覆盖只更新索引，
+// 文件中的无效数据超过有效数据时在后台整理（重写）数据文件，复制期间不持有锁。数据文件在创建时新建、关闭时删除，
+// 不用于重启后的恢复（重启恢复见 mycache.WithSnapshot）。
+type Store struct {
+	mu        sync.Mutex
+	codec     Codec
+	file      *os.File
+	size      int64 // 数据文件的长度，新的值追加在此处
+	garbage   int64 // 数据文件中已失效的字节数
+	usedBytes int64 // 有效的 key 和值的字节数
+	maxBytes  int64
+
+	lru   *list.List               // 最近访问的在前
+	index map[string]*list.Element // key 到 *record
+
+	onEvicted  func(key string, value common.Value)
+	closed     bool
+	compacting bool   // 后台整理正在进行
+	generation uint64 // 数据文件被清空时递增，整理期间文件被清空则放弃整理结果
+}
+
+// New 在 dir 中创建数据文件，maxBytes 不大于 0 时使用 1GB
+// onEvicted 在缓存项因容量或过期被移除、以及被删除时调用，值从磁盘读出；调用时持有内部锁，不能再访问 Store
+func New(dir string, maxBytes int64, codec Codec, onEvicted func(string, common.Value)) (*Store, error) {
+	if codec == nil {
+		return nil, errors.New("disk: codec is required")
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBytes
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("disk: failed to create dir: %v", err)
+	}
+	file, err := os.CreateTemp(dir, "mycache-*.data")
+	if err != nil {
+		return nil, fmt.Errorf("disk: failed to create data file: %v", err)
+	}
+	return &Store{
+		codec:     codec,
+		file:      file,
+		maxBytes:  maxBytes,
+		lru:       list.New(),
+		index:     make(map[string]*list.Element),
+		onEvicted: onEvicted,
+	}, nil
+}
+
+// Get 读取缓存项，已过期时删除并返回未命中
+func (s *Store) Get(key string) (common.Value, bool) {
+	value, _, ok := s.GetWithExpiration(key)
+	return value, ok
+}
+
+// GetWithExpiration 读取缓存项及其过期时间，过期时间为零值表示永不过期
+func (s *Store) GetWithExpiration(key string) (common.Value, time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, time.Time{}, false
+	}
+
+	elem, ok := s.index[key]
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	rec := elem.Value.(*record)
+	if rec.expired(time.Now()) {
+		s.remove(elem, true)
+		return nil, time.Time{}, false
+	}
+
+	value, err := s.read(rec)
+	if err != nil {
+		// 读取失败的缓存项视为丢失
+		s.remove(elem, false)
+		return nil, time.Time{}, false
+	}
+	s.lru.MoveToFront(elem)
+	return value, rec.expire, true
+}
+
+// Set 写入永不过期（或由值自身记录过期时间）的缓存项
+func (s *Store) Set(key string, value common.Value) error {
+	return s.SetWithExpiration(key, value, 0)
+}
+
+// SetWithExpiration 写入缓存项，expiration 大于 0 时在该时间后过期
+// 值自身记录了更早的过期时间时以值的为准
+func (s *Store) SetWithExpiration(key string, value common.Value, expiration time.Duration) error {
+	if value == nil {
+		s.Delete(key)
+		return nil
+	}
+	data, expire, err := s.codec.Encode(value)
+	if err != nil {
+		return fmt.Errorf("disk: failed to encode value: %v", err)
+	}
+	if expiration > 0 {
+		if deadline := time.Now().Add(expiration); expire.IsZero() || deadline.Before(expire) {
+			expire = deadline
+		}
+	}
+	if !expire.IsZero() && !time.Now().Before(expire) {
+		return ErrExpired
+	}
+	if size := int64(len(key) + len(data)); size > s.maxBytes {
+		return fmt.Errorf("disk: entry of %d bytes exceeds capacity", size)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("disk: store closed")
+	}
+
+	if _, err := s.file.WriteAt(data, s.size); err != nil {
+		return fmt.Errorf("disk: failed to write value: %v", err)
+	}
+	rec := &record{key: key, offset: s.size, size: len(data), expire: expire}
+	s.size += int64(len(data))
+
+	if elem, ok := s.index[key]; ok {
+		old := elem.Value.(*record)
+		s.garbage += int64(old.size)
+		s.usedBytes += int64(rec.size - old.size)
+		elem.Value = rec
+		s.lru.MoveToFront(elem)
+	} else {
+		s.index[key] = s.lru.PushFront(rec)
+		s.usedBytes += int64(len(key) + rec.size)
+	}
+
+	for s.usedBytes > s.maxBytes && s.lru.Len() > 0 {
+		s.remove(s.lru.Back(), true)
+	}
+	s.maybeCompact()
+	return nil
+}
+
+// Delete 删除缓存项
+func (s *Store) Delete(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	elem, ok := s.index[key]
+	if !ok {
+		return false
+	}
+	s.remove(elem, true)
+	s.maybeCompact()
+	return true
+}
+
+// Clear 删除所有缓存项并清空数据文件
+func (s *Store) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	for elem := s.lru.Front(); elem != nil; elem = s.lru.Front() {
+		s.remove(elem, true)
+	}
+	s.truncate()
+}
+
+// Len 返回缓存项的数量，包含尚未清理的过期项
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// UsedBytes 返回有效的 key 和值占用的磁盘字节数
+func (s *Store) UsedBytes() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.usedBytes
+}
+
+// FileBytes 返回数据文件的长度，包含尚未整理的无效数据
+func (s *Store) FileBytes() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// Range 遍历所有未过期的缓存项，按最近使用到最久未使用的顺序
+// 遍历的是调用时的快照，值在锁外逐个读取，期间被删除或覆盖的缓存项会被跳过
+func (s *Store) Range(fn func(key string, value common.Value) bool) {
+	s.mu.Lock()
+	now := time.Now()
+	keys := make([]string, 0, s.lru.Len())
+	for elem := s.lru.Front(); elem != nil; elem = elem.Next() {
+		if rec := elem.Value.(*record); !rec.expired(now) {
+			keys = append(keys, rec.key)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, key := range keys {
+		value, ok := s.peek(key)
+		if !ok {
+			continue
+		}
+		if !fn(key, value) {
+			return
+		}
+	}
+}
+
+// peek 读取缓存项但不更新访问顺序
+func (s *Store) peek(key string) (common.Value, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, false
+	}
+	elem, ok := s.index[key]
+	if !ok {
+		return nil, false
+	}
+	value, err := s.read(elem.Value.(*record))
+	return value, err == nil
+}
+
+// PurgeExpired 删除所有已过期的缓存项，返回删除的数量
+func (s *Store) PurgeExpired() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0
+	}
+	now := time.Now()
+	purged := 0
+	for elem := s.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(*record).expired(now) {
+			s.remove(elem, true)
+			purged++
+		}
+		elem = next
+	}
+	s.maybeCompact()
+	return purged
+}
+
+// Close 关闭并删除数据文件，不调用 onEvicted
+func (s *Store) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.file.Close()
+	os.Remove(s.file.Name())
+	s.lru.Init()
+	s.index = nil
+}
+
+// expired 判断缓存项是否已过期
+func (r *record) expired(now time.Time) bool {
+	return !r.expire.IsZero() && !now.Before(r.expire)
+}
+
+// read 从数据文件读取并解码值，调用者必须持有 s.mu
+func (s *Store) read(rec *record) (common.Value, error) {
+	data := make([]byte, rec.size)
+	if _, err := s.file.ReadAt(data, rec.offset); err != nil {
+		return nil, err
+	}
+	return s.codec.Decode(data)
+}
+
+// remove 从索引中移除缓存项，notify 为 true 时读出值调用 onEvicted，调用者必须持有 s.mu
+func (s *Store) remove(elem *list.Element, notify bool) {
+	rec := elem.Value.(*record)
+	s.lru.Remove(elem)
+	delete(s.index, rec.key)
+	s.usedBytes -= int64(len(rec.key) + rec.size)
+	s.garbage += int64(rec.size)
+
+	if notify && s.onEvicted != nil {
+		if value, err := s.read(rec); err == nil {
+			s.onEvicted(rec.key, value)
+		}
+	}
+}
+
+// maybeCompact 无效数据超过有效数据时在后台整理数据文件，调用者必须持有 s.mu
+func (s *Store) maybeCompact() {
+	if s.lru.Len() == 0 {
+		s.truncate()
+		return
+	}
+	if s.compacting || s.garbage < compactMinGarbage || s.garbage < s.size-s.garbage {
+		return
+	}
+	s.compacting = true
+	go s.compact()
+}
+
+// compactEntry 整理开始时有效的缓存项及其在旧文件中的位置
+type compactEntry struct {
+	rec    *record
+	offset int64
+}
+
+// compact 将有效的值按访问顺序复制到新的数据文件并替换旧文件
+//
+// 复制在锁外进行，期间的读写照常访问旧文件（只会追加，不会改写已有的数据）；替换时在锁内补写复制期间
+// 追加的值。整理期间数据文件被清空或 Store 被关闭时放弃结果。整理失败不影响读写，下次触发时重试。
+func (s *Store) compact() {
+	s.mu.Lock()
+	src, generation := s.file, s.generation
+	entries := make([]compactEntry, 0, s.lru.Len())
+	for elem := s.lru.Front(); elem != nil; elem = elem.Next() {
+		rec := elem.Value.(*record)
+		entries = append(entries, compactEntry{rec: rec, offset: rec.offset})
+	}
+	s.mu.Unlock()
+
+	file, err := os.CreateTemp(filepath.Dir(src.Name()), "mycache-*.data")
+	if err != nil {
+		s.mu.Lock()
+		s.compacting = false
+		s.mu.Unlock()
+		return
+	}
+	discard := func() {
+		file.Close()
+		os.Remove(file.Name())
+	}
+
+	var size int64
+	offsets := make(map[*record]int64, len(entries))
+	buf := make([]byte, 0, 64<<10)
+	for _, e := range entries {
+		if err = copyData(src, file, e.offset, size, e.rec.size, &buf); err != nil {
+			break
+		}
+		offsets[e.rec] = size
+		size += int64(e.rec.size)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.compacting = false
+	if err != nil || s.closed || s.generation != generation {
+		discard()
+		return
+	}
+
+	// 补写复制期间新写入的值，全部成功后才更新索引
+	var live int64
+	for elem := s.lru.Front(); elem != nil; elem = elem.Next() {
+		rec := elem.Value.(*record)
+		live += int64(rec.size)
+		if _, ok := offsets[rec]; ok {
+			continue
+		}
+		if err := copyData(src, file, rec.offset, size, rec.size, &buf); err != nil {
+			discard()
+			return
+		}
+		offsets[rec] = size
+		size += int64(rec.size)
+	}
+	for elem := s.lru.Front(); elem != nil; elem = elem.Next() {
+		rec := elem.Value.(*record)
+		rec.offset = offsets[rec]
+	}
+
+	src.Close()
+	os.Remove(src.Name())
+	s.file = file
+	s.size = size
+	// 复制期间被删除或覆盖的值已写入新文件，计为无效数据
+	s.garbage = size - live
+}
+
+// copyData 将 src 中 offset 处的 size 字节复制到 dst 的 at 处，buf 在调用之间复用
+func copyData(src, dst *os.File, offset, at int64, size int, buf *[]byte) error {
+	if cap(*buf) < size {
+		*buf = make([]byte, size)
+	}
+	data := (*buf)[:size]
+	if _, err := src.ReadAt(data, offset); err != nil {
+		return err
+	}
+	_, err := dst.WriteAt(data, at)
+	return err
+}
+
+// truncate 没有有效数据时清空数据文件，调用者必须持有 s.mu
+func (s *Store) truncate() {
+	if s.size == 0 {
+		return
+	}
+	if err := s.file.Truncate(0); err == nil {
+		s.size = 0
+		s.garbage = 0
+		s.generation++
+	}
+}
@@ -0,0 +1,102 @@
+package disk
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/linhx1999/MyCache-Go/store/common"
+)
+
+type testValue string
+
+func (v testValue) Len() int {
+	return len(v)
+}
+
+// testCodec 直接以值的字节作为编码，永不过期
+type testCodec struct{}
+
+func (testCodec) Encode(value common.Value) ([]byte, time.Time, error) {
+	return []byte(value.(testValue)), time.Time{}, nil
+}
+
+func (testCodec) Decode(data []byte) (common.Value, error) {
+	return testValue(data), nil
+}
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := New(t.TempDir(), 1<<20, testCodec{}, nil)
+	if err != nil {
+		t.Fatalf("创建磁盘存储失败: %v", err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestStore_CompactConcurrentWithWrites(t *testing.T) {
+	s := newTestStore(t)
+
+	for i := 0; i < 100; i++ {
+		s.Set(fmt.Sprintf("key-%d", i), testValue(fmt.Sprintf("old-%d", i)))
+	}
+	for i := 0; i < 50; i++ {
+		s.Set(fmt.Sprintf("key-%d", i), testValue(fmt.Sprintf("new-%d", i)))
+	}
+	before := s.FileBytes()
+
+	// 整理在锁外复制，期间的写入和删除照常进行并在替换文件时补写
+	s.mu.Lock()
+	s.compacting = true
+	s.mu.Unlock()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.compact()
+	}()
+	for i := 100; i < 120; i++ {
+		s.Set(fmt.Sprintf("key-%d", i), testValue(fmt.Sprintf("added-%d", i)))
+	}
+	for i := 50; i < 60; i++ {
+		s.Delete(fmt.Sprintf("key-%d", i))
+	}
+	wg.Wait()
+
+	s.mu.Lock()
+	compacting := s.compacting
+	s.mu.Unlock()
+	if compacting {
+		t.Error("整理结束后应清除 compacting 标记")
+	}
+	if after := s.FileBytes(); after >= before {
+		t.Errorf("整理后数据文件应变小，整理前 %d 字节，整理后 %d 字节", before, after)
+	}
+
+	for i := 0; i < 120; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		var want string
+		switch {
+		case i < 50:
+			want = fmt.Sprintf("new-%d", i)
+		case i < 60:
+			want = ""
+		case i < 100:
+			want = fmt.Sprintf("old-%d", i)
+		default:
+			want = fmt.Sprintf("added-%d", i)
+		}
+		value, ok := s.Get(key)
+		if want == "" {
+			if ok {
+				t.Errorf("%s 已删除，不应读到 %v", key, value)
+			}
+			continue
+		}
+		if !ok || string(value.(testValue)) != want {
+			t.Errorf("%s 应为 %s，实际为 %v（found=%v）", key, want, value, ok)
+		}
+	}
+}
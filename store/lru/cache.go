@@ -68,6 +68,21 @@ func (l *LRUCache) Get(key string) (common.Value, bool) {
 	return value, true
 }
 
+// Peek 获取未过期的缓存项，不更新 LRU 位置
+func (l *LRUCache) Peek(key string) (common.Value, bool) {
+	l.rwMutex.RLock()
+	defer l.rwMutex.RUnlock()
+
+	elem, ok := l.elementMap[key]
+	if !ok {
+		return nil, false
+	}
+	if expTime, hasExp := l.expirationMap[key]; hasExp && time.Now().After(expTime) {
+		return nil, false
+	}
+	return elem.Value.(*cacheEntry).value, true
+}
+
 // Set 添加或更新缓存项
 func (l *LRUCache) Set(key string, value common.Value) error {
 	return l.SetWithExpiration(key, value, 0)
@@ -144,6 +159,28 @@ func (c *LRUCache) Clear() {
 	c.usedBytes = 0
 }
 
+// Range 遍历缓存中所有未过期的项，按最近使用到最久未使用的顺序
+// 遍历的是调用时的快照，fn 在锁外调用
+func (c *LRUCache) Range(fn func(key string, value common.Value) bool) {
+	c.rwMutex.RLock()
+	now := time.Now()
+	entries := make([]*cacheEntry, 0, c.lruList.Len())
+	for elem := c.lruList.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*cacheEntry)
+		if expTime, hasExp := c.expirationMap[entry.key]; hasExp && now.After(expTime) {
+			continue
+		}
+		entries = append(entries, &cacheEntry{key: entry.key, value: entry.value})
+	}
+	c.rwMutex.RUnlock()
+
+	for _, entry := range entries {
+		if !fn(entry.key, entry.value) {
+			return
+		}
+	}
+}
+
 // Len 返回缓存中的项数
 func (c *LRUCache) Len() int {
 	c.rwMutex.RLock()
@@ -151,6 +188,13 @@ func (c *LRUCache) Len() int {
 	return c.lruList.Len()
 }
 
+// UsedBytes 返回缓存当前占用的字节数（键和值的长度之和）
+func (c *LRUCache) UsedBytes() int64 {
+	c.rwMutex.RLock()
+	defer c.rwMutex.RUnlock()
+	return c.usedBytes
+}
+
 // Close 关闭缓存，停止清理协程
 func (c *LRUCache) Close() {
 	if c.cleanupTicker != nil {
@@ -173,17 +217,32 @@ func (c *LRUCache) removeElement(elem *list.Element) {
 	}
 }
 
-// evict 清理过期和超出内存限制的缓存，调用此方法前必须持有锁
-func (c *LRUCache) evict() {
-	// 先清理过期项
+// PurgeExpired 立即清理所有已过期的项，返回清理的数量
+func (c *LRUCache) PurgeExpired() int {
+	c.rwMutex.Lock()
+	defer c.rwMutex.Unlock()
+	return c.purgeExpired()
+}
+
+// purgeExpired 清理过期项，调用此方法前必须持有锁
+func (c *LRUCache) purgeExpired() int {
+	purged := 0
 	now := time.Now()
 	for key, expTime := range c.expirationMap {
 		if now.After(expTime) {
 			if elem, ok := c.elementMap[key]; ok {
 				c.removeElement(elem)
+				purged++
 			}
 		}
 	}
+	return purged
+}
+
+// evict 清理过期和超出内存限制的缓存，调用此方法前必须持有锁
+func (c *LRUCache) evict() {
+	// 先清理过期项
+	c.purgeExpired()
 
 	// 再根据内存限制清理最久未使用的项（链表尾部）
 	for c.maxBytes > 0 && c.usedBytes > c.maxBytes && c.lruList.Len() > 0 {
@@ -16,6 +16,7 @@ type cacheBucket struct {
 	entries    []cacheEntry      // 预分配的缓存条目数组，存储实际的键值对数据
 	keyToIndex map[string]uint16 // 键到 entries 索引的映射（+1 后的值，0 表示不存在），用于 O(1) 查找
 	size       uint16            // 当前已使用的条目数量，也是 entries 中的下一个可用位置
+	usedBytes  int64             // 有效条目（未标记删除）占用的字节数
 }
 
 func createCache(cap uint16) *cacheBucket {
@@ -30,15 +31,24 @@ func createCache(cap uint16) *cacheBucket {
 // put 向缓存中添加项，如果是新增返回 1，更新返回 0
 func (b *cacheBucket) put(key string, val common.Value, deadline int64, onEvicted func(string, common.Value)) int {
 	if idx, ok := b.keyToIndex[key]; ok {
+		if b.entries[idx-1].deadline != 0 {
+			b.usedBytes -= entrySize(key, b.entries[idx-1].value)
+		}
 		b.entries[idx-1].value, b.entries[idx-1].deadline = val, deadline
+		b.usedBytes += entrySize(key, val)
 		b.adjust(idx, head) // 刷新到链表头部
 		return 0
 	}
 
+	b.usedBytes += entrySize(key, val)
+
 	if b.size == uint16(cap(b.entries)) {
 		tail := &b.entries[b.links[0][prev]-1]
-		// 调用淘汰回调函数
-		if onEvicted != nil && (*tail).deadline > 0 {
+		if (*tail).deadline != 0 {
+			b.usedBytes -= entrySize((*tail).key, (*tail).value)
+		}
+		// 调用淘汰回调函数（已删除的条目 deadline 为 0，无需回调；永不过期的条目同样需要回调）
+		if onEvicted != nil && (*tail).deadline != 0 {
 			onEvicted((*tail).key, (*tail).value)
 		}
 
@@ -81,6 +91,7 @@ func (b *cacheBucket) get(key string) *cacheEntry {
 func (b *cacheBucket) del(key string) (*cacheEntry, bool, int64) {
 	if idx, ok := b.keyToIndex[key]; ok && b.entries[idx-1].deadline != 0 {
 		d := b.entries[idx-1].deadline
+		b.usedBytes -= entrySize(key, b.entries[idx-1].value)
 		b.entries[idx-1].deadline = 0 // 标记为已删除
 		b.adjust(idx, tail)           // 移动到链表尾部
 		return &b.entries[idx-1], true, d
@@ -89,6 +100,14 @@ func (b *cacheBucket) del(key string) (*cacheEntry, bool, int64) {
 	return nil, false, 0
 }
 
+// entrySize 计算条目占用的字节数
+func entrySize(key string, val common.Value) int64 {
+	if val == nil {
+		return int64(len(key))
+	}
+	return int64(len(key) + val.Len())
+}
+
 // walk 遍历缓存中的所有有效项（按访问顺序：从最近使用到最久未使用）
 // walker 返回 false 表示停止遍历
 func (b *cacheBucket) walk(walker func(key string, value common.Value, deadline int64) bool) {
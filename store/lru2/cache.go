@@ -75,6 +75,20 @@ func (l *LRU2Cache) Get(key string) (common.Value, bool) {
 	return nil, false
 }
 
+// Peek 获取缓存项，不在两级缓存之间移动；一级缓存中的值比二级缓存中的新
+func (l *LRU2Cache) Peek(key string) (common.Value, bool) {
+	idx := l.keyToBucketIndex(key)
+	l.bucketLocks[idx].Lock()
+	defer l.bucketLocks[idx].Unlock()
+
+	for level := int32(0); level < 2; level++ {
+		if entry := l.getFromLevel(key, idx, level); entry != nil {
+			return entry.value, true
+		}
+	}
+	return nil, false
+}
+
 // Set 添加或更新缓存项（永不过期）
 func (l *LRU2Cache) Set(key string, value common.Value) error {
 	// 直接调用 SetWithExpiration，传入 0 表示永不过期
@@ -142,6 +156,40 @@ func (l *LRU2Cache) Clear() {
 	}
 }
 
+// Range 遍历缓存中所有未过期的项
+// 按桶逐个复制后在锁外调用 fn，同时存在于两级缓存中的 key 只遍历一次
+func (l *LRU2Cache) Range(fn func(key string, value common.Value) bool) {
+	type item struct {
+		key   string
+		value common.Value
+	}
+
+	for i := range l.buckets {
+		currentTime := now()
+		seen := make(map[string]bool)
+		var items []item
+
+		l.bucketLocks[i].Lock()
+		for level := 0; level < 2; level++ {
+			l.buckets[i][level].walk(func(key string, value common.Value, deadline int64) bool {
+				if seen[key] || (deadline > 0 && currentTime >= deadline) {
+					return true
+				}
+				seen[key] = true
+				items = append(items, item{key: key, value: value})
+				return true
+			})
+		}
+		l.bucketLocks[i].Unlock()
+
+		for _, it := range items {
+			if !fn(it.key, it.value) {
+				return
+			}
+		}
+	}
+}
+
 // Len 返回缓存中的项数
 func (l *LRU2Cache) Len() int {
 	count := 0
@@ -164,6 +212,19 @@ func (l *LRU2Cache) Len() int {
 	return count
 }
 
+// UsedBytes 返回缓存当前占用的字节数（两级缓存中有效条目的键和值长度之和）
+func (l *LRU2Cache) UsedBytes() int64 {
+	var used int64
+
+	for i := range l.buckets {
+		l.bucketLocks[i].Lock()
+		used += l.buckets[i][0].usedBytes + l.buckets[i][1].usedBytes
+		l.bucketLocks[i].Unlock()
+	}
+
+	return used
+}
+
 // Close 关闭缓存，停止清理协程
 func (l *LRU2Cache) Close() {
 	if l.cleanupTicker != nil {
@@ -209,39 +270,49 @@ func (l *LRU2Cache) delete(key string, idx int32) bool {
 // cleanupLoop 定期清理过期缓存的协程
 func (l *LRU2Cache) cleanupLoop() {
 	for range l.cleanupTicker.C {
-		currentTime := now()
+		l.PurgeExpired()
+	}
+}
 
-		for i := range l.buckets {
-			l.bucketLocks[i].Lock()
+// PurgeExpired 立即清理所有已过期的项，返回清理的数量
+func (l *LRU2Cache) PurgeExpired() int {
+	purged := 0
+	currentTime := now()
 
-			// 检查并清理过期项目
-			var expiredKeys []string
+	for i := range l.buckets {
+		l.bucketLocks[i].Lock()
 
-			l.buckets[i][0].walk(func(key string, value common.Value, deadline int64) bool {
-				if deadline > 0 && currentTime >= deadline {
-					expiredKeys = append(expiredKeys, key)
-				}
-				return true
-			})
+		// 检查并清理过期项目
+		var expiredKeys []string
 
-			l.buckets[i][1].walk(func(key string, value common.Value, deadline int64) bool {
-				if deadline > 0 && currentTime >= deadline {
-					for _, k := range expiredKeys {
-						if key == k {
-							// 避免重复
-							return true
-						}
+		l.buckets[i][0].walk(func(key string, value common.Value, deadline int64) bool {
+			if deadline > 0 && currentTime >= deadline {
+				expiredKeys = append(expiredKeys, key)
+			}
+			return true
+		})
+
+		l.buckets[i][1].walk(func(key string, value common.Value, deadline int64) bool {
+			if deadline > 0 && currentTime >= deadline {
+				for _, k := range expiredKeys {
+					if key == k {
+						// 避免重复
+						return true
 					}
-					expiredKeys = append(expiredKeys, key)
 				}
-				return true
-			})
-
-			for _, key := range expiredKeys {
-				l.delete(key, int32(i))
+				expiredKeys = append(expiredKeys, key)
 			}
+			return true
+		})
 
-			l.bucketLocks[i].Unlock()
+		for _, key := range expiredKeys {
+			if l.delete(key, int32(i)) {
+				purged++
+			}
 		}
+
+		l.bucketLocks[i].Unlock()
 	}
+
+	return purged
 }
@@ -696,6 +696,117 @@ func TestLRU2Cache_Clear(t *testing.T) {
 	}
 }
 
+// TestLRU2Cache_UsedBytes 测试占用字节数统计
+func TestLRU2Cache_UsedBytes(t *testing.T) {
+	t.Run("新增、更新与删除", func(t *testing.T) {
+		cache := New(1, 5, 5, time.Minute, nil)
+		defer cache.Close()
+
+		cache.Set("key1", testValue("value1"))
+		cache.Set("key2", testValue("v2"))
+		if used := cache.UsedBytes(); used != 16 {
+			t.Fatalf("Expected 16 used bytes, got %d", used)
+		}
+
+		// 更新为更长的值
+		cache.Set("key2", testValue("value2"))
+		if used := cache.UsedBytes(); used != 20 {
+			t.Fatalf("Expected 20 used bytes after update, got %d", used)
+		}
+
+		cache.Delete("key1")
+		if used := cache.UsedBytes(); used != 10 {
+			t.Fatalf("Expected 10 used bytes after delete, got %d", used)
+		}
+
+		cache.Clear()
+		if used := cache.UsedBytes(); used != 0 {
+			t.Fatalf("Expected 0 used bytes after Clear, got %d", used)
+		}
+	})
+
+	t.Run("淘汰后扣减", func(t *testing.T) {
+		var evicted int
+		cache := New(1, 2, 2, time.Minute, func(key string, value common.Value) {
+			evicted++
+		})
+		defer cache.Close()
+
+		// 一级缓存容量为 2，写入第三个永不过期的项会淘汰最久未使用的项
+		cache.Set("a", testValue("1"))
+		cache.Set("b", testValue("2"))
+		cache.Set("c", testValue("3"))
+
+		if evicted != 1 {
+			t.Fatalf("Expected 1 eviction, got %d", evicted)
+		}
+		if used := cache.UsedBytes(); used != 4 {
+			t.Fatalf("Expected 4 used bytes after eviction, got %d", used)
+		}
+	})
+}
+
+// TestLRU2Cache_Peek 测试读取缓存项而不在两级缓存之间移动
+func TestLRU2Cache_Peek(t *testing.T) {
+	cache := New(1, 5, 5, time.Minute, nil)
+	defer cache.Close()
+
+	cache.Set("key1", testValue("value1"))
+	if value, ok := cache.Peek("key1"); !ok || value.(testValue) != "value1" {
+		t.Fatalf("Expected value1, got %v (found=%v)", value, ok)
+	}
+	if _, ok := cache.Peek("missing"); ok {
+		t.Error("Expected missing key not to be found")
+	}
+
+	// Peek 后覆盖写入不应在二级缓存中留下旧值
+	cache.Set("key1", testValue("v1"))
+	if used := cache.UsedBytes(); used != 6 {
+		t.Errorf("Expected 6 used bytes after overwrite, got %d", used)
+	}
+	if cache.buckets[0][1].get("key1") != nil {
+		t.Error("Expected Peek not to move key1 into level 2")
+	}
+}
+
+// TestLRU2Cache_Range 测试遍历缓存项
+func TestLRU2Cache_Range(t *testing.T) {
+	cache := New(4, 10, 10, time.Minute, nil)
+	defer cache.Close()
+
+	for i := 0; i < 10; i++ {
+		cache.Set(fmt.Sprintf("key%d", i), testValue(fmt.Sprintf("value%d", i)))
+	}
+	// 访问后 key0 同时出现在二级缓存中，遍历时只应出现一次
+	cache.Get("key0")
+	// 内部时钟精度为 100ms，等待足够长的时间确保已过期
+	cache.SetWithExpiration("expired", testValue("x"), 10*time.Millisecond)
+	time.Sleep(250 * time.Millisecond)
+
+	seen := make(map[string]int)
+	cache.Range(func(key string, value common.Value) bool {
+		seen[key]++
+		return true
+	})
+	if len(seen) != 10 {
+		t.Fatalf("Expected 10 keys, got %d: %v", len(seen), seen)
+	}
+	for key, n := range seen {
+		if n != 1 {
+			t.Errorf("Key %s visited %d times", key, n)
+		}
+	}
+
+	var visited int
+	cache.Range(func(key string, value common.Value) bool {
+		visited++
+		return false
+	})
+	if visited != 1 {
+		t.Errorf("Range should stop after fn returns false, visited %d", visited)
+	}
+}
+
 // TestLRU2Cache_Concurrent 测试并发操作
 func TestLRU2Cache_Concurrent(t *testing.T) {
 	cache := New(8, 100, 200, time.Minute, nil)
@@ -0,0 +1,334 @@
+package store
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/linhx1999/MyCache-Go/store/common"
+	"github.com/linhx1999/MyCache-Go/store/disk"
+)
+
+// tieredStripes 串行化同一个 key 的提升与写入使用的锁数量
+const tieredStripes = 64
+
+// Codec 在值与写入磁盘层或 arena 的字节之间转换（类型别名）
+type Codec = common.Codec
+
+// DiskOptions 磁盘层配置
+type DiskOptions struct {
+	Dir      string // 数据文件所在的目录
+	MaxBytes int64  // 磁盘层的容量，不大于 0 时使用 1GB
+	Codec    Codec  // 值的编解码方式
+}
+
+// Tiered 内存存储之后带有磁盘层的分层存储
+//
+// 内存层因容量淘汰的缓存项写入磁盘层而不是丢弃，内存未命中时从磁盘层读取并提升回内存层，
+// 使节点能缓存远超内存容量的数据。过期、删除和清空的缓存项不会写入磁盘层。
+// 只有磁盘层也淘汰时才调用 Options.OnEvicted，被提升的缓存项不会触发回调。
+//
+// 内存层在持有锁时回调淘汰，淘汰的缓存项先放入待写队列，由触发淘汰的操作在释放内存层的锁之后写入磁盘层，
+// 磁盘 I/O 不会阻塞内存层的读写。
+type Tiered struct {
+	memory    Store
+	disk      *disk.Store
+	onEvicted func(key string, value Value)
+
+	stripes  [tieredStripes]sync.Mutex
+	deleting sync.Map // 正在主动删除的 key，内存层的移除回调中不写入磁盘层
+	clearing atomic.Bool
+
+	spillMu sync.Mutex
+	pending map[string]Value // 已从内存层淘汰、尚未写入磁盘层的缓存项，由 spillMu 保护
+
+	spills     atomic.Int64 // 写入磁盘层的次数
+	promotions atomic.Int64 // 从磁盘层提升回内存层的次数
+}
+
+var (
+	_ Store  = (*Tiered)(nil)
+	_ Ranger = (*Tiered)(nil)
+	_ Purger = (*Tiered)(nil)
+	_ Peeker = (*Tiered)(nil)
+)
+
+// NewTieredStore 创建内存层类型为 cacheType、带有磁盘层的分层存储
+func NewTieredStore(cacheType CacheType, opts Options, diskOpts DiskOptions) (*Tiered, error) {
+	t := &Tiered{onEvicted: opts.OnEvicted, pending: make(map[string]Value)}
+	d, err := disk.New(diskOpts.Dir, diskOpts.MaxBytes, diskOpts.Codec, t.diskEvicted)
+	if err != nil {
+		return nil, err
+	}
+	t.disk = d
+
+	memOpts := opts
+	memOpts.OnEvicted = t.memoryEvicted
+	t.memory = NewStore(cacheType, memOpts)
+	return t, nil
+}
+
+// stripe 返回 key 对应的锁
+func (t *Tiered) stripe(key string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &t.stripes[h.Sum32()%tieredStripes]
+}
+
+// memoryEvicted 内存层移除缓存项时调用，容量淘汰的放入待写队列，其余的转给 OnEvicted
+// 调用时内存层持有锁，不能访问内存层，也不做磁盘 I/O
+func (t *Tiered) memoryEvicted(key string, value Value) {
+	if t.clearing.Load() {
+		t.notify(key, value)
+		return
+	}
+	if _, ok := t.deleting.Load(key); ok {
+		t.notify(key, value)
+		return
+	}
+	t.spillMu.Lock()
+	t.pending[key] = value
+	t.spillMu.Unlock()
+}
+
+// flushSpills 将待写队列中的缓存项写入磁盘层，调用者不能持有内存层的锁或任何 key 的锁
+func (t *Tiered) flushSpills() {
+	t.spillMu.Lock()
+	if len(t.pending) == 0 {
+		t.spillMu.Unlock()
+		return
+	}
+	keys := make([]string, 0, len(t.pending))
+	for key := range t.pending {
+		keys = append(keys, key)
+	}
+	t.spillMu.Unlock()
+
+	for _, key := range keys {
+		mu := t.stripe(key)
+		mu.Lock()
+		t.spillLocked(key)
+		mu.Unlock()
+	}
+}
+
+// spillLocked 将 key 的待写缓存项写入磁盘层，调用者必须持有 key 的锁
+// 期间已被覆盖、删除或其他协程写入的 key 不在队列中，不会写入旧值
+func (t *Tiered) spillLocked(key string) {
+	t.spillMu.Lock()
+	value, ok := t.pending[key]
+	delete(t.pending, key)
+	t.spillMu.Unlock()
+	if !ok {
+		return
+	}
+	// 已过期（disk.ErrExpired）或写入失败时视为淘汰
+	if err := t.disk.Set(key, value); err != nil {
+		t.notify(key, value)
+		return
+	}
+	t.spills.Add(1)
+}
+
+// dropPending 从待写队列中移除 key，调用者必须持有 key 的锁
+func (t *Tiered) dropPending(key string) bool {
+	t.spillMu.Lock()
+	defer t.spillMu.Unlock()
+	_, ok := t.pending[key]
+	delete(t.pending, key)
+	return ok
+}
+
+// diskEvicted 磁盘层移除缓存项时调用
+func (t *Tiered) diskEvicted(key string, value Value) {
+	if _, ok := t.deleting.Load(key); ok && !t.clearing.Load() {
+		// 主动删除已经由内存层回调
+		return
+	}
+	t.notify(key, value)
+}
+
+// notify 调用 OnEvicted
+func (t *Tiered) notify(key string, value Value) {
+	if t.onEvicted != nil {
+		t.onEvicted(key, value)
+	}
+}
+
+// Get 先查内存层，未命中时从磁盘层读取并提升回内存层，保留剩余的过期时间
+func (t *Tiered) Get(key string) (Value, bool) {
+	if value, ok := t.memory.Get(key); ok {
+		return value, true
+	}
+	value, ok := t.promote(key)
+	t.flushSpills()
+	return value, ok
+}
+
+// promote 从磁盘层读取 key 并提升回内存层
+func (t *Tiered) promote(key string) (Value, bool) {
+	mu := t.stripe(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	// 等待锁期间可能已被其他请求提升
+	if value, ok := t.memory.Get(key); ok {
+		return value, true
+	}
+	// 刚被淘汰、尚未写入磁盘层的先写入，再按磁盘层的过期时间提升
+	t.spillLocked(key)
+	value, expire, ok := t.disk.GetWithExpiration(key)
+	if !ok {
+		return nil, false
+	}
+
+	var ttl time.Duration
+	if !expire.IsZero() {
+		if ttl = time.Until(expire); ttl <= 0 {
+			return nil, false
+		}
+	}
+	// 先写入内存层再从磁盘层删除，提升期间的并发读不会未命中
+	if err := t.memory.SetWithExpiration(key, value, ttl); err != nil {
+		return value, true
+	}
+	t.deleting.Store(key, struct{}{})
+	t.disk.Delete(key)
+	t.deleting.Delete(key)
+	t.promotions.Add(1)
+	return value, true
+}
+
+// Peek 只读取内存层，与 UsedBytes 一致；内存层不支持 Peeker 时视为不存在
+func (t *Tiered) Peek(key string) (Value, bool) {
+	if p, ok := t.memory.(Peeker); ok {
+		return p.Peek(key)
+	}
+	return nil, false
+}
+
+// Set 写入内存层，磁盘层中的旧值随之失效
+func (t *Tiered) Set(key string, value Value) error {
+	return t.SetWithExpiration(key, value, 0)
+}
+
+// SetWithExpiration 写入内存层，磁盘层和待写队列中的旧值随之失效
+func (t *Tiered) SetWithExpiration(key string, value Value, expiration time.Duration) error {
+	err := t.set(key, value, expiration)
+	t.flushSpills()
+	return err
+}
+
+// set 在 key 的锁内写入内存层
+func (t *Tiered) set(key string, value Value, expiration time.Duration) error {
+	mu := t.stripe(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := t.memory.SetWithExpiration(key, value, expiration); err != nil {
+		return err
+	}
+	t.dropPending(key)
+	t.deleting.Store(key, struct{}{})
+	t.disk.Delete(key)
+	t.deleting.Delete(key)
+	return nil
+}
+
+// Delete 从两层和待写队列中删除
+func (t *Tiered) Delete(key string) bool {
+	mu := t.stripe(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	t.deleting.Store(key, struct{}{})
+	defer t.deleting.Delete(key)
+
+	inMemory := t.memory.Delete(key)
+	pending := t.dropPending(key)
+	onDisk := t.disk.Delete(key)
+	return inMemory || pending || onDisk
+}
+
+// Clear 清空两层和待写队列
+func (t *Tiered) Clear() {
+	t.clearing.Store(true)
+	defer t.clearing.Store(false)
+	t.memory.Clear()
+
+	t.spillMu.Lock()
+	pending := t.pending
+	t.pending = make(map[string]Value)
+	t.spillMu.Unlock()
+	for key, value := range pending {
+		t.notify(key, value)
+	}
+	t.disk.Clear()
+}
+
+// Len 返回两层的缓存项数量之和
+func (t *Tiered) Len() int {
+	t.flushSpills()
+	return t.memory.Len() + t.disk.Len()
+}
+
+// UsedBytes 返回内存层占用的字节数，磁盘层见 DiskBytes
+// 内存配额和负载统计只关心内存占用
+func (t *Tiered) UsedBytes() int64 {
+	return t.memory.UsedBytes()
+}
+
+// DiskLen 返回磁盘层的缓存项数量
+func (t *Tiered) DiskLen() int {
+	return t.disk.Len()
+}
+
+// DiskBytes 返回磁盘层有效数据占用的字节数
+func (t *Tiered) DiskBytes() int64 {
+	return t.disk.UsedBytes()
+}
+
+// Spills 返回写入磁盘层的次数
+func (t *Tiered) Spills() int64 {
+	return t.spills.Load()
+}
+
+// Promotions 返回从磁盘层提升回内存层的次数
+func (t *Tiered) Promotions() int64 {
+	return t.promotions.Load()
+}
+
+// Range 先遍历内存层再遍历磁盘层，内存层不支持遍历时只遍历磁盘层
+func (t *Tiered) Range(fn func(key string, value Value) bool) {
+	t.flushSpills()
+	stopped := false
+	if ranger, ok := t.memory.(Ranger); ok {
+		ranger.Range(func(key string, value Value) bool {
+			if !fn(key, value) {
+				stopped = true
+			}
+			return !stopped
+		})
+	}
+	if !stopped {
+		t.disk.Range(fn)
+	}
+}
+
+// PurgeExpired 清理两层中已过期的缓存项
+func (t *Tiered) PurgeExpired() int {
+	purged := 0
+	if purger, ok := t.memory.(Purger); ok {
+		purged = purger.PurgeExpired()
+	}
+	// 内存层清理的过期项进入待写队列，写入磁盘层时因已过期转给 OnEvicted
+	t.flushSpills()
+	return purged + t.disk.PurgeExpired()
+}
+
+// Close 关闭内存层并删除磁盘层的数据文件
+func (t *Tiered) Close() {
+	t.memory.Close()
+	t.disk.Close()
+}
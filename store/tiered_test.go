@@ -0,0 +1,186 @@
+package store
+
+import (
+	"encoding/binary"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/linhx1999/MyCache-Go/store/common"
+)
+
+// testValue 带有过期时间的测试值，零值表示永不过期
+type testValue struct {
+	data   string
+	expire time.Time
+}
+
+func (v testValue) Len() int {
+	return len(v.data)
+}
+
+// testCodec 以 8 字节的过期时间（UnixNano，0 表示永不过期）加上值的内容编码
+type testCodec struct{}
+
+func (testCodec) Encode(value common.Value) ([]byte, time.Time, error) {
+	v := value.(testValue)
+	data := make([]byte, 8+len(v.data))
+	if !v.expire.IsZero() {
+		binary.BigEndian.PutUint64(data, uint64(v.expire.UnixNano()))
+	}
+	copy(data[8:], v.data)
+	return data, v.expire, nil
+}
+
+func (testCodec) Decode(data []byte) (common.Value, error) {
+	v := testValue{data: string(data[8:])}
+	if nanos := binary.BigEndian.Uint64(data); nanos != 0 {
+		v.expire = time.Unix(0, int64(nanos))
+	}
+	return v, nil
+}
+
+// evictions 记录 OnEvicted 收到的 key
+type evictions struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (e *evictions) add(key string, value Value) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.keys = append(e.keys, key)
+}
+
+func (e *evictions) snapshot() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.keys...)
+}
+
+// newTestTiered 创建内存层只能容纳两个 10 字节缓存项的分层存储
+func newTestTiered(t *testing.T) (*Tiered, *evictions) {
+	t.Helper()
+	evicted := &evictions{}
+	tiered, err := NewTieredStore(LRU, Options{MaxBytes: 20, OnEvicted: evicted.add}, DiskOptions{
+		Dir:   t.TempDir(),
+		Codec: testCodec{},
+	})
+	if err != nil {
+		t.Fatalf("创建分层存储失败: %v", err)
+	}
+	t.Cleanup(tiered.Close)
+	return tiered, evicted
+}
+
+func TestTiered_SpillOnEviction(t *testing.T) {
+	tiered, evicted := newTestTiered(t)
+
+	for _, key := range []string{"a", "b", "c"} {
+		if err := tiered.Set(key, testValue{data: key + "-value-"}); err != nil {
+			t.Fatalf("Set 失败: %v", err)
+		}
+	}
+
+	// 内存层淘汰的 a 在 Set 返回前写入磁盘层，不触发 OnEvicted
+	if n := tiered.DiskLen(); n != 1 {
+		t.Fatalf("磁盘层应有 1 个缓存项，实际为 %d 个", n)
+	}
+	if n := tiered.Spills(); n != 1 {
+		t.Errorf("Spills 应为 1，实际为 %d", n)
+	}
+	if keys := evicted.snapshot(); len(keys) != 0 {
+		t.Errorf("写入磁盘层的缓存项不应触发 OnEvicted，实际为 %v", keys)
+	}
+
+	value, ok := tiered.Get("a")
+	if !ok || value.(testValue).data != "a-value-" {
+		t.Fatalf("应从磁盘层读到 a，实际为 %v（found=%v）", value, ok)
+	}
+	if n := tiered.Promotions(); n != 1 {
+		t.Errorf("Promotions 应为 1，实际为 %d", n)
+	}
+	// 提升 a 时内存层淘汰了 b，a 已从磁盘层移除
+	if n := tiered.DiskLen(); n != 1 {
+		t.Errorf("提升后磁盘层应只有被淘汰的 1 个缓存项，实际为 %d 个", n)
+	}
+	if n := tiered.Len(); n != 3 {
+		t.Errorf("两层共应有 3 个缓存项，实际为 %d 个", n)
+	}
+}
+
+func TestTiered_EvictionQueuedUntilFlush(t *testing.T) {
+	tiered, _ := newTestTiered(t)
+
+	// 内存层持有锁时回调，只放入待写队列，不访问磁盘层
+	tiered.memoryEvicted("a", testValue{data: "a-value-"})
+	if n := tiered.DiskLen(); n != 0 {
+		t.Fatalf("回调中不应写入磁盘层，实际有 %d 个缓存项", n)
+	}
+
+	// 待写的缓存项在写入磁盘层之前仍然可以读到
+	value, ok := tiered.Get("a")
+	if !ok || value.(testValue).data != "a-value-" {
+		t.Fatalf("应读到待写的 a，实际为 %v（found=%v）", value, ok)
+	}
+	if n := tiered.Spills(); n != 1 {
+		t.Errorf("Spills 应为 1，实际为 %d", n)
+	}
+}
+
+func TestTiered_PromotionKeepsRemainingTTL(t *testing.T) {
+	tiered, _ := newTestTiered(t)
+
+	ttl := 200 * time.Millisecond
+	if err := tiered.SetWithExpiration("a", testValue{data: "a-value-", expire: time.Now().Add(ttl)}, ttl); err != nil {
+		t.Fatalf("SetWithExpiration 失败: %v", err)
+	}
+	tiered.Set("b", testValue{data: "b-value-"})
+	tiered.Set("c", testValue{data: "c-value-"})
+	if n := tiered.DiskLen(); n != 1 {
+		t.Fatalf("a 应被写入磁盘层，磁盘层实际有 %d 个缓存项", n)
+	}
+
+	if _, ok := tiered.Get("a"); !ok {
+		t.Fatal("过期之前应能从磁盘层提升 a")
+	}
+	if n := tiered.Promotions(); n != 1 {
+		t.Fatalf("Promotions 应为 1，实际为 %d", n)
+	}
+
+	// 提升回内存层后按剩余的过期时间过期，而不是永不过期
+	time.Sleep(ttl + 50*time.Millisecond)
+	if _, ok := tiered.Get("a"); ok {
+		t.Error("提升后的 a 应在原来的过期时间过期")
+	}
+}
+
+func TestTiered_DeleteAndClearDoNotSpill(t *testing.T) {
+	tiered, evicted := newTestTiered(t)
+
+	tiered.Set("a", testValue{data: "a-value-"})
+	if !tiered.Delete("a") {
+		t.Fatal("Delete 应返回 true")
+	}
+	if n := tiered.DiskLen(); n != 0 {
+		t.Errorf("删除的缓存项不应写入磁盘层，实际有 %d 个", n)
+	}
+	if _, ok := tiered.Get("a"); ok {
+		t.Error("删除后不应读到 a")
+	}
+
+	tiered.Set("b", testValue{data: "b-value-"})
+	tiered.Set("c", testValue{data: "c-value-"})
+	tiered.Set("d", testValue{data: "d-value-"}) // b 被写入磁盘层
+	spills := tiered.Spills()
+	tiered.Clear()
+	if n := tiered.Len(); n != 0 {
+		t.Errorf("Clear 后两层都应为空，实际有 %d 个缓存项", n)
+	}
+	if n := tiered.Spills(); n != spills {
+		t.Errorf("Clear 不应写入磁盘层，Spills 从 %d 变为 %d", spills, n)
+	}
+	if keys := evicted.snapshot(); len(keys) < 3 {
+		t.Errorf("Clear 应对两层的缓存项调用 OnEvicted，实际为 %v", keys)
+	}
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"time"
 
+	"github.com/linhx1999/MyCache-Go/store/arena"
 	"github.com/linhx1999/MyCache-Go/store/common"
 	"github.com/linhx1999/MyCache-Go/store/lru"
 	"github.com/linhx1999/MyCache-Go/store/lru2"
@@ -19,25 +20,44 @@ type Store interface {
 	Delete(key string) bool
 	Clear()
 	Len() int
+	UsedBytes() int64
 	Close()
 }
 
+// Ranger 可选接口，支持遍历缓存中所有未过期的项
+// fn 在存储内部锁之外调用，可以安全地访问缓存；返回 false 时停止遍历
+type Ranger interface {
+	Range(fn func(key string, value Value) bool)
+}
+
+// Purger 可选接口，支持立即清理所有已过期的项，返回清理的数量
+type Purger interface {
+	PurgeExpired() int
+}
+
+// Peeker 可选接口，读取未过期的项而不改变淘汰顺序，如覆盖写入前查询旧值的大小
+type Peeker interface {
+	Peek(key string) (Value, bool)
+}
+
 // CacheType 缓存类型
 type CacheType string
 
 const (
-	LRU  CacheType = "lru"
-	LRU2 CacheType = "lru2"
+	LRU   CacheType = "lru"
+	LRU2  CacheType = "lru2"
+	Arena CacheType = "arena" // 值保存在预先分配的大块字节数组中，减少缓存大量值时的 GC 扫描
 )
 
 // Options 通用缓存配置选项
 type Options struct {
-	MaxBytes        int64                        // 最大的缓存字节数（用于 lru）
-	BucketCount     uint16                       // 缓存的桶数量（用于 lru-2）
-	CapPerBucket    uint16                       // 每个桶的容量（用于 lru-2）
-	Level2Cap       uint16                       // lru-2 中二级缓存的容量（用于 lru-2）
+	MaxBytes        int64  // 最大的缓存字节数（用于 lru 和 arena）
+	BucketCount     uint16 // 缓存的桶数量（用于 lru-2）
+	CapPerBucket    uint16 // 每个桶的容量（用于 lru-2）
+	Level2Cap       uint16 // lru-2 中二级缓存的容量（用于 lru-2）
 	CleanupInterval time.Duration
 	OnEvicted       func(key string, value Value)
+	Codec           Codec // 值与字节之间的编解码（用于 arena，必须设置）
 }
 
 // NewStore 根据选项创建缓存实例
@@ -47,6 +67,8 @@ func NewStore(cacheType CacheType, opts Options) Store {
 		return lru.New(opts.MaxBytes, opts.CleanupInterval, opts.OnEvicted)
 	case LRU2:
 		return lru2.New(opts.BucketCount, opts.CapPerBucket, opts.Level2Cap, opts.CleanupInterval, opts.OnEvicted)
+	case Arena:
+		return arena.New(opts.MaxBytes, opts.CleanupInterval, opts.Codec, opts.OnEvicted)
 	default:
 		return lru2.New(opts.BucketCount, opts.CapPerBucket, opts.Level2Cap, opts.CleanupInterval, opts.OnEvicted)
 	}
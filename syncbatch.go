@@ -0,0 +1,362 @@
+package mycache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	pb "github.com/linhx1999/MyCache-Go/pb"
+)
+
+const (
+	// defaultSyncInterval 同步队列默认的发送间隔
+	defaultSyncInterval = 2 * time.Millisecond
+	// defaultSyncMaxBatch 每个节点每批默认最多同步的 key 数量
+	defaultSyncMaxBatch = 256
+)
+
+// WithSyncBatching 设置 Set/Delete 同步到其他节点的批量发送
+//
+// 写操作按目标节点放入队列，同一个 key 只保留最后一次写入，每隔 interval 将各节点的队列
+// 用一次 MSet 和一次 MDelete 发出，队列中的 key 达到 maxBatch 时立即发送，每批最多 maxBatch 个 key。同一节点同时只有一批在发送，
+// 后一批在前一批完成后发出，保证同一个 key 的写入顺序。默认 interval 为 2ms、maxBatch 为 256；
+// interval 不大于 0 时关闭批量发送，每次写入单独启动协程同步。对端不支持 sync_batch 时逐个 key 发送。
+func WithSyncBatching(interval time.Duration, maxBatch int) GroupOption {
+	return func(g *Group) {
+		g.syncInterval = interval
+		g.syncMaxBatch = maxBatch
+	}
+}
+
+// syncOp 等待同步的写操作
+type syncOp struct {
+	op     string    // set 或 delete
+	value  []byte    // set 的值
+	expire time.Time // set 的过期时间，零值表示永不过期
+	hint   bool      // 同步失败时是否保存提示，只有发往 owner 的写操作保存
+}
+
+// syncBatcher 按节点合并等待同步的写操作并成批发送
+type syncBatcher struct {
+	g        *Group
+	interval time.Duration
+	maxBatch int
+
+	mu       sync.Mutex
+	pending  map[Peer]map[string]syncOp // 各节点等待发送的写操作，同一个 key 只保留最后一次
+	inflight map[Peer]bool              // 正在发送的节点
+	timer    *time.Timer                // 有等待发送的写操作时启动，空闲时不占用资源
+	closed   bool
+	wg       sync.WaitGroup // 正在发送的批次
+}
+
+// startSyncBatching 创建同步队列，未启用批量发送时不做任何事
+func (g *Group) startSyncBatching() {
+	if g.syncInterval <= 0 {
+		return
+	}
+	if g.syncMaxBatch <= 0 {
+		g.syncMaxBatch = defaultSyncMaxBatch
+	}
+	g.syncer = &syncBatcher{
+		g:        g,
+		interval: g.syncInterval,
+		maxBatch: g.syncMaxBatch,
+		pending:  make(map[Peer]map[string]syncOp),
+		inflight: make(map[Peer]bool),
+	}
+}
+
+// stopSyncBatching 立即发出队列中剩余的写操作并等待发送完成
+func (g *Group) stopSyncBatching() {
+	if g.syncer != nil {
+		g.syncer.close()
+	}
+}
+
+// syncAsync 异步同步写操作到其他节点，启用批量发送时放入同步队列
+func (g *Group) syncAsync(op, key string, value []byte, expire time.Time) {
+	if g.syncer == nil {
+		go g.syncToPeers(op, key, value, expire)
+		return
+	}
+	g.syncer.add(op, key, value, expire)
+}
+
+// syncTarget 写操作需要同步到的节点
+type syncTarget struct {
+	peer Peer
+	hint bool
+}
+
+// syncTargets 返回 key 的写操作需要同步到的节点，与 syncToPeers 的选择一致
+func (g *Group) syncTargets(key string) []syncTarget {
+	if g.peers == nil {
+		return nil
+	}
+	if g.replicas > 1 {
+		if replicaPicker, ok := g.peers.(ReplicaPicker); ok {
+			replicas := replicaPicker.PickPeers(key, g.replicas)
+			targets := make([]syncTarget, len(replicas))
+			for i, peer := range replicas {
+				targets[i] = syncTarget{peer: peer, hint: i == 0}
+			}
+			return targets
+		}
+	}
+	peer, ok, isSelf := g.peers.PickPeer(key)
+	if !ok || isSelf {
+		return nil
+	}
+	return []syncTarget{{peer: peer, hint: true}}
+}
+
+// add 将写操作放入目标节点的队列，覆盖同一个 key 尚未发送的写操作
+func (b *syncBatcher) add(op, key string, value []byte, expire time.Time) {
+	targets := b.g.syncTargets(key)
+	if len(targets) == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		// 组正在关闭，队列已经发出，单独同步
+		go b.g.syncToPeers(op, key, value, expire)
+		return
+	}
+
+	for _, t := range targets {
+		ops := b.pending[t.peer]
+		if ops == nil {
+			ops = make(map[string]syncOp)
+			b.pending[t.peer] = ops
+		}
+		if _, ok := ops[key]; ok {
+			b.g.stats.syncCoalesced.Add(1)
+		}
+		ops[key] = syncOp{op: op, value: value, expire: expire, hint: t.hint}
+		if len(ops) >= b.maxBatch {
+			b.sendLocked(t.peer)
+		}
+	}
+	b.scheduleLocked()
+}
+
+// scheduleLocked 有等待发送的写操作时启动定时器
+func (b *syncBatcher) scheduleLocked() {
+	if b.timer == nil && !b.closed && len(b.pending) > 0 {
+		b.timer = time.AfterFunc(b.interval, b.flush)
+	}
+}
+
+// flush 发出所有不在发送中的节点的队列
+func (b *syncBatcher) flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.timer = nil
+	for peer := range b.pending {
+		b.sendLocked(peer)
+	}
+}
+
+// sendLocked 在后台发出节点的队列，该节点上一批尚未完成时等待其完成后再发
+// 每批最多 maxBatch 个 key，其余留在队列中由下一批发出
+func (b *syncBatcher) sendLocked(peer Peer) {
+	if b.inflight[peer] {
+		return
+	}
+	ops := b.pending[peer]
+	if len(ops) == 0 {
+		return
+	}
+	batch := ops
+	if len(ops) > b.maxBatch {
+		batch = make(map[string]syncOp, b.maxBatch)
+		for key, op := range ops {
+			if len(batch) == b.maxBatch {
+				break
+			}
+			batch[key] = op
+			delete(ops, key)
+		}
+	} else {
+		delete(b.pending, peer)
+	}
+	b.inflight[peer] = true
+	b.wg.Add(1)
+	go b.send(peer, batch)
+}
+
+// send 发送一批写操作，完成后发出该节点在发送期间积累的写操作
+func (b *syncBatcher) send(peer Peer, ops map[string]syncOp) {
+	defer b.wg.Done()
+
+	b.g.sendSyncBatch(peer, ops)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.inflight, peer)
+	if b.closed || len(b.pending[peer]) >= b.maxBatch {
+		b.sendLocked(peer)
+	}
+	b.scheduleLocked()
+}
+
+// close 发出队列中剩余的写操作并等待所有批次完成
+func (b *syncBatcher) close() {
+	b.mu.Lock()
+	b.closed = true
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	for peer := range b.pending {
+		b.sendLocked(peer)
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+}
+
+// sendSyncBatch 将一批写操作同步到节点，owner 上失败的写操作保存为提示
+func (g *Group) sendSyncBatch(peer Peer, ops map[string]syncOp) {
+	ctx := context.WithValue(context.Background(), "from_peer", true)
+
+	var failed map[string]error
+	if batchPeer, ok := peer.(syncBatchPeer); ok {
+		failed = batchPeer.syncBatch(ctx, g.name, ops)
+	} else {
+		failed = syncEach(ctx, peer, g.name, ops)
+	}
+	g.stats.syncBatches.Add(1)
+
+	var lastErr error
+	queued := 0
+	for key, op := range ops {
+		err, ok := failed[key]
+		if !ok {
+			if op.hint {
+				g.clearHint(key)
+			}
+			continue
+		}
+		lastErr = err
+		// 超过大小限制的值重放也不会成功
+		if op.hint && !errors.Is(err, ErrValueTooLarge) && g.addHint(op.op, key, op.value, op.expire) {
+			queued++
+		}
+	}
+	if lastErr != nil {
+		log.Printf("[MyCache] failed to sync %d of %d writes to peer (%d queued for handoff): %v",
+			len(failed), len(ops), queued, lastErr)
+	}
+}
+
+// syncBatchPeer 由 Client 实现，用一次 MSet 和一次 MDelete 同步一批写操作
+type syncBatchPeer interface {
+	syncBatch(ctx context.Context, group string, ops map[string]syncOp) map[string]error
+}
+
+// syncEach 逐个 key 同步写操作，返回失败的 key 及其错误
+func syncEach(ctx context.Context, peer Peer, group string, ops map[string]syncOp) map[string]error {
+	failed := make(map[string]error)
+	for key, op := range ops {
+		var err error
+		switch op.op {
+		case "set":
+			err = peer.Set(withWriteExpire(ctx, op.expire), group, key, op.value)
+		case "delete":
+			_, err = peer.Delete(ctx, group, key)
+		}
+		if err != nil {
+			failed[key] = err
+		}
+	}
+	return failed
+}
+
+// syncBatch 用一次 MSet 同步所有 set、一次 MDelete 同步所有 delete，返回失败的 key 及其错误
+// 对端未声明支持 sync_batch 时逐个 set：旧版本节点会忽略 MSet 中每个 key 的过期时间
+func (c *Client) syncBatch(ctx context.Context, group string, ops map[string]syncOp) map[string]error {
+	sets := make(map[string]syncOp)
+	var deletes []string
+	for key, op := range ops {
+		switch op.op {
+		case "set":
+			sets[key] = op
+		case "delete":
+			deletes = append(deletes, key)
+		}
+	}
+
+	failed := make(map[string]error)
+	if len(sets) > 0 {
+		var setFailed map[string]error
+		if c.confirmsFeature(FeatureSyncBatch) && c.Supports(FeatureBatch) {
+			setFailed = c.msetEntries(ctx, group, sets)
+		} else {
+			setFailed = syncEach(ctx, c, group, sets)
+		}
+		for key, err := range setFailed {
+			failed[key] = err
+		}
+	}
+	if len(deletes) > 0 {
+		if err := c.MDelete(ctx, group, deletes); err != nil {
+			for _, key := range deletes {
+				failed[key] = err
+			}
+		}
+	}
+	return failed
+}
+
+// msetEntries 用一次 MSet 设置多个带过期时间的 key，超过大小限制时逐个设置
+func (c *Client) msetEntries(ctx context.Context, group string, sets map[string]syncOp) map[string]error {
+	req := &pb.BatchRequest{Group: group}
+	size := 0
+	for key, op := range sets {
+		size += len(key) + len(op.value)
+		entry := &pb.KeyValue{Key: key, Value: op.value}
+		entry.TtlMs, entry.ExpireAt = expireFields(op.expire)
+		req.Entries = append(req.Entries, entry)
+	}
+	if c.checkValueSize(size) != nil {
+		// 单个 key 未超过限制时仍能逐个设置
+		return syncEach(ctx, c, group, sets)
+	}
+
+	ctx, cancel := withTimeout(ctx, c.opts.timeouts.Set)
+	defer cancel()
+
+	var resp *pb.BatchResponse
+	err := c.invoke(ctx, false, func(ctx context.Context, cli pb.CacheServiceClient) (err error) {
+		resp, err = cli.MSet(ctx, req)
+		return err
+	})
+	if err != nil && !c.Supports(FeatureBatch) {
+		return syncEach(ctx, c, group, sets)
+	}
+
+	failed := make(map[string]error)
+	if err != nil {
+		err = wrapSizeError("mset values to cache", err)
+		for key := range sets {
+			failed[key] = err
+		}
+		return failed
+	}
+	for key, msg := range resp.GetErrors() {
+		failed[key] = fmt.Errorf("key %s: %s", key, msg)
+	}
+	return failed
+}
+
+// confirmsFeature 判断对端是否已声明支持某项功能，与 Supports 不同，尚未得知对端的协议版本时返回 false
+func (c *Client) confirmsFeature(feature string) bool {
+	p := c.proto.Load()
+	return p != nil && p.version > 0 && p.features[feature]
+}
@@ -0,0 +1,262 @@
+package mycache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/linhx1999/MyCache-Go/pb"
+	"google.golang.org/grpc"
+)
+
+// recordingPeer 记录收到的逐个 key 写操作，不支持批量同步
+type recordingPeer struct {
+	mu      sync.Mutex
+	sets    map[string]string
+	expires map[string]time.Time
+	deletes []string
+}
+
+func newRecordingPeer() *recordingPeer {
+	return &recordingPeer{sets: make(map[string]string), expires: make(map[string]time.Time)}
+}
+
+func (p *recordingPeer) Get(ctx context.Context, group, key string) ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (p *recordingPeer) Set(ctx context.Context, group, key string, value []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sets[key] = string(value)
+	p.expires[key] = writeExpireFromContext(ctx)
+	return nil
+}
+
+func (p *recordingPeer) Delete(ctx context.Context, group, key string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.deletes = append(p.deletes, key)
+	return true, nil
+}
+
+func (p *recordingPeer) MGet(ctx context.Context, group string, keys []string) (map[string][]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (p *recordingPeer) MSet(ctx context.Context, group string, entries map[string][]byte) error {
+	return errors.New("not implemented")
+}
+
+func (p *recordingPeer) MDelete(ctx context.Context, group string, keys []string) error {
+	return errors.New("not implemented")
+}
+
+func (p *recordingPeer) Transfer(ctx context.Context, group string, entries <-chan TransferEntry) (TransferResult, error) {
+	return TransferResult{}, errors.New("not implemented")
+}
+
+func (p *recordingPeer) Close() error {
+	return nil
+}
+
+// batchingPeer 记录收到的每一批写操作
+type batchingPeer struct {
+	recordingPeer
+
+	mu      sync.Mutex
+	batches []map[string]syncOp
+}
+
+func (p *batchingPeer) syncBatch(ctx context.Context, group string, ops map[string]syncOp) map[string]error {
+	batch := make(map[string]syncOp, len(ops))
+	for key, op := range ops {
+		batch[key] = op
+	}
+	p.mu.Lock()
+	p.batches = append(p.batches, batch)
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *batchingPeer) snapshot() []map[string]syncOp {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]map[string]syncOp(nil), p.batches...)
+}
+
+// newSyncGroup 创建将所有写操作同步到 peer 的组，interval 足够长时只有队列写满和关闭时发送
+func newSyncGroup(t *testing.T, name string, peer Peer, interval time.Duration, maxBatch int) *Group {
+	t.Helper()
+	return NewGroup(name, 1<<20, DataSourceFunc(func(ctx context.Context, key string) ([]byte, error) {
+		return nil, errors.New("not found")
+	}), WithPeers(fixedPicker{peer: peer}), WithSyncBatching(interval, maxBatch))
+}
+
+func TestSyncBatch_CoalescesSameKey(t *testing.T) {
+	peer := &batchingPeer{}
+	g := newSyncGroup(t, "sync-coalesce", peer, time.Hour, 100)
+	ctx := context.Background()
+
+	g.Set(ctx, "k", []byte("v1"))
+	g.Delete(ctx, "k")
+	g.Set(ctx, "k", []byte("v2"))
+	g.Set(ctx, "other", []byte("x"))
+	g.Close()
+
+	batches := peer.snapshot()
+	if len(batches) != 1 {
+		t.Fatalf("应只发送一批，实际为 %d 批", len(batches))
+	}
+	op, ok := batches[0]["k"]
+	if !ok || op.op != "set" || string(op.value) != "v2" {
+		t.Errorf("同一个 key 应只保留最后一次写入 set v2，实际为 %+v", op)
+	}
+	if len(batches[0]) != 2 {
+		t.Errorf("批次应包含 2 个 key，实际为 %d 个", len(batches[0]))
+	}
+	if n := g.stats.syncCoalesced.Load(); n != 2 {
+		t.Errorf("sync_coalesced 应为 2，实际为 %d", n)
+	}
+}
+
+func TestSyncBatch_SplitsAtMaxBatch(t *testing.T) {
+	peer := &batchingPeer{}
+	g := newSyncGroup(t, "sync-split", peer, time.Hour, 2)
+	ctx := context.Background()
+
+	keys := []string{"a", "b", "c", "d", "e"}
+	for _, key := range keys {
+		g.Set(ctx, key, []byte(key))
+	}
+	g.Close()
+
+	seen := make(map[string]bool)
+	for _, batch := range peer.snapshot() {
+		if len(batch) > 2 {
+			t.Errorf("每批不应超过 maxBatch 个 key，实际为 %d 个", len(batch))
+		}
+		for key := range batch {
+			if seen[key] {
+				t.Errorf("key %s 被发送了多次", key)
+			}
+			seen[key] = true
+		}
+	}
+	if len(seen) != len(keys) {
+		t.Errorf("应发送全部 %d 个 key，实际为 %d 个", len(keys), len(seen))
+	}
+	if n := g.stats.syncBatches.Load(); n < 3 {
+		t.Errorf("5 个 key 按每批 2 个应至少发送 3 批，实际为 %d 批", n)
+	}
+}
+
+func TestSyncBatch_CloseFlushes(t *testing.T) {
+	peer := &batchingPeer{}
+	g := newSyncGroup(t, "sync-close", peer, time.Hour, 100)
+
+	g.Set(context.Background(), "k", []byte("v"))
+	if len(peer.snapshot()) != 0 {
+		t.Fatal("interval 到期前不应发送")
+	}
+	g.Close()
+
+	batches := peer.snapshot()
+	if len(batches) != 1 || string(batches[0]["k"].value) != "v" {
+		t.Errorf("关闭时应发出队列中的写操作，实际为 %+v", batches)
+	}
+}
+
+func TestSyncBatch_FallsBackToSingleWrites(t *testing.T) {
+	peer := newRecordingPeer()
+	g := newSyncGroup(t, "sync-fallback", peer, time.Hour, 100)
+	ctx := context.Background()
+
+	g.SetWithTTL(ctx, "ttl", []byte("v1"), time.Hour)
+	view, _ := g.localCache.Get(ctx, "ttl")
+	g.Set(ctx, "plain", []byte("v2"))
+	g.Set(ctx, "gone", []byte("v3"))
+	g.Delete(ctx, "gone")
+	g.Close()
+
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+	if peer.sets["ttl"] != "v1" || peer.sets["plain"] != "v2" || len(peer.sets) != 2 {
+		t.Errorf("应逐个 key 调用 Set，实际为 %v", peer.sets)
+	}
+	if got := peer.expires["ttl"]; got.IsZero() || !got.Equal(view.expire) {
+		t.Errorf("逐个同步时应携带本地的过期时间 %v，实际为 %v", view.expire, got)
+	}
+	if len(peer.deletes) != 1 || peer.deletes[0] != "gone" {
+		t.Errorf("应逐个 key 调用 Delete，实际为 %v", peer.deletes)
+	}
+}
+
+func TestSyncBatch_ClientWithoutSyncBatchFeature(t *testing.T) {
+	owner := NewGroup("sync-client", 1<<20, DataSourceFunc(func(ctx context.Context, key string) ([]byte, error) {
+		return nil, errors.New("not found")
+	}))
+	defer owner.Close()
+
+	addr := freeAddr(t)
+	srv, err := NewServer(addr, "sync-test", WithoutRegistry())
+	if err != nil {
+		t.Fatalf("创建服务器失败: %v", err)
+	}
+	srv.RegisterGroup(owner)
+	go srv.Start()
+	defer srv.Stop()
+
+	var (
+		mu      sync.Mutex
+		methods []string
+	)
+	record := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		mu.Lock()
+		methods = append(methods, method)
+		mu.Unlock()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	client, err := DialNode(addr, WithWaitForReady(true), WithUnaryInterceptors(record))
+	if err != nil {
+		t.Fatalf("DialNode 失败: %v", err)
+	}
+	defer client.Close()
+	// 对端是同一协议版本但未声明 sync_batch 的节点
+	client.proto.Store(&peerProtocol{version: ProtocolVersion, features: map[string]bool{FeatureBatch: true}})
+
+	expire := time.Now().Add(time.Hour)
+	failed := client.syncBatch(context.WithValue(context.Background(), "from_peer", true), owner.name, map[string]syncOp{
+		"a": {op: "set", value: []byte("1"), expire: expire},
+		"b": {op: "set", value: []byte("2")},
+	})
+	if len(failed) != 0 {
+		t.Fatalf("同步失败: %v", failed)
+	}
+
+	mu.Lock()
+	sets := 0
+	for _, method := range methods {
+		switch method {
+		case pb.CacheService_MSet_FullMethodName:
+			t.Errorf("对端未声明 sync_batch 时不应使用 MSet")
+		case pb.CacheService_Set_FullMethodName:
+			sets++
+		}
+	}
+	if sets != 2 {
+		t.Errorf("应逐个 key 调用 Set 2 次，实际为 %d 次（%v）", sets, methods)
+	}
+	mu.Unlock()
+
+	view, ok := owner.localCache.Get(context.Background(), "a")
+	if !ok || view.String() != "1" {
+		t.Fatalf("owner 上应有 key a")
+	}
+	// 过期时间按剩余毫秒数和绝对时间中较早者计算
+	if view.expire.After(expire) || expire.Sub(view.expire) > time.Second {
+		t.Errorf("逐个同步时应保留过期时间 %v，实际为 %v", expire, view.expire)
+	}
+}
@@ -0,0 +1,517 @@
+package mycache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/linhx1999/MyCache-Go/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// tenantUsageRefresh 重新扫描本地缓存统计租户用量的最小间隔
+// 两次扫描之间接受的写入会累加到用量中，避免短时间内的突发写入超出配额
+const tenantUsageRefresh = time.Second
+
+// ErrRateLimited 租户的请求速率超过配额
+var ErrRateLimited = errors.New("cache: tenant rate limit exceeded")
+
+var (
+	// errTenantForbidden 租户调用了仅供节点间使用的接口
+	errTenantForbidden = errors.New("cache: method not available to tenants")
+	// errInvalidTenant 租户名包含命名空间分隔符或哈希标签使用的括号
+	errInvalidTenant = errors.New("cache: invalid tenant name")
+)
+
+// TenantQuota 租户在每个节点上的配额，各项为 0 表示不限制
+type TenantQuota struct {
+	MaxBytes int64   // 租户的 key 和 value 在本节点占用的字节数上限
+	MaxKeys  int64   // 租户在本节点的 key 数量上限
+	QPS      float64 // 每秒请求数上限
+	Burst    int     // 允许的突发请求数，0 时取 QPS（至少为 1）
+}
+
+// TenantQuotaExceededError 写入会导致租户的用量超过配额时返回
+type TenantQuotaExceededError struct {
+	Tenant    string // 租户
+	Resource  string // 超出的配额项："bytes" 或 "keys"
+	Used      int64  // 当前用量
+	Requested int64  // 本次写入新增的用量
+	Quota     int64  // 配额
+}
+
+func (e *TenantQuotaExceededError) Error() string {
+	return fmt.Sprintf("cache: tenant [%s] %s quota exceeded: used=%d requested=%d quota=%d",
+		e.Tenant, e.Resource, e.Used, e.Requested, e.Quota)
+}
+
+// Is 使 errors.Is(err, ErrQuotaExceeded) 成立
+func (e *TenantQuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// TenantUsage 租户在某个节点上的用量和配额
+type TenantUsage struct {
+	Tenant   string
+	Bytes    int64 // 占用的字节数（含命名空间前缀）
+	Keys     int64 // key 数量
+	Requests int64 // 已接受的请求数
+	Rejected int64 // 因超出配额被拒绝的请求数
+	Quota    TenantQuota
+}
+
+// tenantKey 租户标识在 context 中的键
+type tenantKey struct{}
+
+// WithTenant 返回携带租户标识的 ctx，供自定义的 AuthFunc 在认证通过后标记请求所属的租户
+// 租户名不能包含 '{'、'}' 或 NUL 字符
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext 返回请求所属的租户，未标记时返回空字符串
+// 租户的 key 未命中缓存时，DataSource 收到的 ctx 同样可以取得租户
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// TenantTokenAuth 返回按 token 识别租户的 AuthFunc，tokens 为 token 到租户的映射
+// 映射到空字符串的 token 不属于任何租户，用于节点间请求和运维工具
+func TenantTokenAuth(tokens map[string]string) AuthFunc {
+	list := make([]string, 0, len(tokens))
+	for token := range tokens {
+		list = append(list, token)
+	}
+	check := StaticTokenAuth(list...)
+	return func(ctx context.Context, token string) (context.Context, error) {
+		ctx, err := check(ctx, token)
+		if err != nil {
+			return ctx, err
+		}
+		if tenant := tokens[token]; tenant != "" {
+			ctx = WithTenant(ctx, tenant)
+		}
+		return ctx, nil
+	}
+}
+
+// WithTenants 启用多租户：认证时标记了租户（见 WithTenant、TenantTokenAuth，JWTAuth 取 sub 声明）的请求
+// 只能访问自己命名空间下的 key，并按配额限制用量和请求速率
+//
+// 租户的 key 在服务端存储为 "\x00tenant\x00key"，对客户端透明；未命中缓存时 DataSource 收到原始的 key，
+// 租户通过 TenantFromContext 获取。key 自带的哈希标签仍然有效。quotas 指定各租户的配额，
+// 未列出的租户使用 defaultQuota。配额按节点计算：每个节点只统计本地缓存中的用量。
+// 未标记租户的请求（包括节点间的转发和同步）不受影响，因此节点间请求应使用不属于任何租户的凭证；
+// 其中不是来自其他节点的请求不能使用以 "\x00" 开头的 key，以免冒充租户。
+// 需要配合 WithAuth 使用。
+func WithTenants(defaultQuota TenantQuota, quotas map[string]TenantQuota) ServerOption {
+	return func(o *ServerOptions) {
+		o.Tenants = &tenantConfig{defaultQuota: defaultQuota, quotas: quotas}
+	}
+}
+
+// tenantConfig WithTenants 设置的配额
+type tenantConfig struct {
+	defaultQuota TenantQuota
+	quotas       map[string]TenantQuota
+}
+
+// tenantSeparator 租户命名空间的分隔符
+const tenantSeparator = "\x00"
+
+// namespacedKey 返回租户命名空间下的 key
+// 不使用 "{tenant}" 作为前缀：启用哈希标签时它会被当作标签，使租户的所有 key 路由到同一个节点
+func namespacedKey(tenant, key string) string {
+	return tenantSeparator + tenant + tenantSeparator + key
+}
+
+// splitTenantKey 将租户命名空间下的 key 拆分为租户和原始 key，不在命名空间下时租户为空、原样返回 key
+func splitTenantKey(key string) (tenant, userKey string) {
+	if !strings.HasPrefix(key, tenantSeparator) {
+		return "", key
+	}
+	end := strings.Index(key[1:], tenantSeparator)
+	if end <= 0 {
+		return "", key
+	}
+	return key[1 : end+1], key[end+2:]
+}
+
+// checkReservedKey 拒绝既没有租户、也不是来自其他节点的请求使用租户命名空间前缀的 key
+// 加载时按 key 的前缀确定租户，否则普通客户端可以构造 "\x00租户\x00key" 以该租户的身份访问数据源
+func checkReservedKey(ctx context.Context, key string) error {
+	if !strings.HasPrefix(key, tenantSeparator) || TenantFromContext(ctx) != "" || isPeerRequest(ctx) {
+		return nil
+	}
+	return &PolicyViolationError{Field: "key", Size: len(key), Char: rune(tenantSeparator[0]), Err: ErrInvalidKey}
+}
+
+// checkReservedRequest 对请求中的所有 key 执行 checkReservedKey
+func checkReservedRequest(ctx context.Context, req interface{}) error {
+	switch r := req.(type) {
+	case *pb.Request:
+		return checkReservedKey(ctx, r.Key)
+	case *pb.BatchRequest:
+		for _, key := range r.Keys {
+			if err := checkReservedKey(ctx, key); err != nil {
+				return err
+			}
+		}
+		for _, entry := range r.Entries {
+			if err := checkReservedKey(ctx, entry.Key); err != nil {
+				return err
+			}
+		}
+	case *pb.LockRequest:
+		return checkReservedKey(ctx, r.Key)
+	}
+	return nil
+}
+
+// reservedKeyUnaryInterceptor 未启用多租户时拒绝普通客户端使用租户命名空间前缀的 key
+func reservedKeyUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := checkReservedRequest(ctx, req); err != nil {
+		return nil, toStatusError(err)
+	}
+	return handler(ctx, req)
+}
+
+// reservedKeyStreamInterceptor 对流式请求执行 reservedKeyUnaryInterceptor 的校验
+func reservedKeyStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &reservedKeyServerStream{ServerStream: ss})
+}
+
+// reservedKeyServerStream 校验流中收到的请求
+type reservedKeyServerStream struct {
+	grpc.ServerStream
+}
+
+func (s *reservedKeyServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return toStatusError(checkReservedRequest(s.Context(), m))
+}
+
+// tenantState 单个租户的用量和限流状态
+type tenantState struct {
+	quota   TenantQuota
+	limiter *rateLimiter
+
+	bytes        int64 // 最近一次扫描得到的用量，由 tenantManager.mu 保护
+	keys         int64
+	pendingBytes int64 // 最近一次扫描后接受的写入
+	pendingKeys  int64
+
+	requests atomic.Int64
+	rejected atomic.Int64
+}
+
+// tenantManager 命名租户的 key，并检查配额和请求速率
+type tenantManager struct {
+	config tenantConfig
+	groups func() []*Group          // 统计用量时扫描的组
+	group  func(name string) *Group // 按组名查找组，覆盖写入时查询已有 key 的大小
+
+	mu        sync.Mutex
+	tenants   map[string]*tenantState
+	scannedAt time.Time
+}
+
+// newTenantManager 创建租户管理器，使用前需设置 groups 和 group
+func newTenantManager(config tenantConfig) *tenantManager {
+	return &tenantManager{config: config, tenants: make(map[string]*tenantState)}
+}
+
+// state 返回租户的状态，首次访问时创建，调用方需持有 m.mu
+func (m *tenantManager) state(tenant string) *tenantState {
+	st, ok := m.tenants[tenant]
+	if !ok {
+		quota, ok := m.config.quotas[tenant]
+		if !ok {
+			quota = m.config.defaultQuota
+		}
+		st = &tenantState{quota: quota}
+		if quota.QPS > 0 {
+			st.limiter = newRateLimiter(quota.QPS, quota.Burst)
+		}
+		m.tenants[tenant] = st
+	}
+	return st
+}
+
+// refresh 距离上次扫描超过 tenantUsageRefresh 时重新统计各租户的用量，调用方需持有 m.mu
+func (m *tenantManager) refresh(now time.Time) {
+	if now.Sub(m.scannedAt) < tenantUsageRefresh || m.groups == nil {
+		return
+	}
+	m.scannedAt = now
+
+	type usage struct{ bytes, keys int64 }
+	usages := make(map[string]*usage)
+	for _, group := range m.groups() {
+		group.localCache.Range(func(key string, view ByteView) bool {
+			if tenant, _ := splitTenantKey(key); tenant != "" {
+				u, ok := usages[tenant]
+				if !ok {
+					u = &usage{}
+					usages[tenant] = u
+				}
+				u.bytes += int64(len(key) + view.Len())
+				u.keys++
+			}
+			return true
+		})
+	}
+	for tenant, st := range m.tenants {
+		st.bytes, st.keys = 0, 0
+		if u, ok := usages[tenant]; ok {
+			st.bytes, st.keys = u.bytes, u.keys
+		}
+		st.pendingBytes, st.pendingKeys = 0, 0
+	}
+}
+
+// admit 检查请求速率，超出时返回 ErrRateLimited
+func (m *tenantManager) admit(tenant string) error {
+	if strings.ContainsAny(tenant, "{}"+tenantSeparator) {
+		return errInvalidTenant
+	}
+
+	m.mu.Lock()
+	st := m.state(tenant)
+	m.mu.Unlock()
+
+	if st.limiter != nil && !st.limiter.allow(time.Now()) {
+		st.rejected.Add(1)
+		return ErrRateLimited
+	}
+	st.requests.Add(1)
+	return nil
+}
+
+// reserve 检查写入 keys 个共 bytes 字节后是否超出配额，未超出时计入用量
+// keys 或 bytes 不为正数（覆盖写入不大于原值）时不检查对应的配额项
+func (m *tenantManager) reserve(tenant string, keys, bytes int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st := m.state(tenant)
+	m.refresh(time.Now())
+
+	var err error
+	if used := st.bytes + st.pendingBytes; st.quota.MaxBytes > 0 && bytes > 0 && used+bytes > st.quota.MaxBytes {
+		err = &TenantQuotaExceededError{Tenant: tenant, Resource: "bytes", Used: used, Requested: bytes, Quota: st.quota.MaxBytes}
+	} else if used := st.keys + st.pendingKeys; st.quota.MaxKeys > 0 && keys > 0 && used+keys > st.quota.MaxKeys {
+		err = &TenantQuotaExceededError{Tenant: tenant, Resource: "keys", Used: used, Requested: keys, Quota: st.quota.MaxKeys}
+	}
+	if err != nil {
+		st.rejected.Add(1)
+		return err
+	}
+	st.pendingBytes += bytes
+	st.pendingKeys += keys
+	return nil
+}
+
+// storedSize 返回组中已存在的 keys 的数量和占用的字节数，覆盖写入时只按差额计入配额
+func (m *tenantManager) storedSize(group string, keys ...string) (count, bytes int64) {
+	if m.group == nil {
+		return 0, 0
+	}
+	g := m.group(group)
+	if g == nil {
+		return 0, 0
+	}
+	for _, key := range keys {
+		if size := g.localCache.entrySize(key); size > 0 {
+			count++
+			bytes += size
+		}
+	}
+	return count, bytes
+}
+
+// usage 返回租户的用量，tenant 为空时返回所有访问过的租户，按租户名排序
+func (m *tenantManager) usage(tenant string) []TenantUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.refresh(time.Now())
+	var usages []TenantUsage
+	for name, st := range m.tenants {
+		if tenant != "" && name != tenant {
+			continue
+		}
+		usages = append(usages, TenantUsage{
+			Tenant:   name,
+			Bytes:    st.bytes + st.pendingBytes,
+			Keys:     st.keys + st.pendingKeys,
+			Requests: st.requests.Load(),
+			Rejected: st.rejected.Load(),
+			Quota:    st.quota,
+		})
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		return usages[i].Tenant < usages[j].Tenant
+	})
+	return usages
+}
+
+// unaryInterceptor 为租户的请求加上命名空间，并检查配额
+func (m *tenantManager) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	tenant := TenantFromContext(ctx)
+	if strings.HasPrefix(info.FullMethod, healthServicePrefix) {
+		return handler(ctx, req)
+	}
+	if tenant == "" {
+		return reservedKeyUnaryInterceptor(ctx, req, info, handler)
+	}
+	if err := m.admit(tenant); err != nil {
+		return nil, tenantStatusError(err)
+	}
+
+	switch r := req.(type) {
+	case *pb.Request:
+		r.Key = namespacedKey(tenant, r.Key)
+		if info.FullMethod == pb.CacheService_Set_FullMethodName {
+			keys, bytes := m.storedSize(r.Group, r.Key)
+			if err := m.reserve(tenant, 1-keys, int64(len(r.Key)+len(r.Value))-bytes); err != nil {
+				return nil, tenantStatusError(err)
+			}
+		}
+	case *pb.BatchRequest:
+		var bytes int64
+		for i, key := range r.Keys {
+			r.Keys[i] = namespacedKey(tenant, key)
+		}
+		keys := make([]string, len(r.Entries))
+		for i, entry := range r.Entries {
+			entry.Key = namespacedKey(tenant, entry.Key)
+			keys[i] = entry.Key
+			bytes += int64(len(entry.Key) + len(entry.Value))
+		}
+		if info.FullMethod == pb.CacheService_MSet_FullMethodName {
+			stored, storedBytes := m.storedSize(r.Group, keys...)
+			if err := m.reserve(tenant, int64(len(r.Entries))-stored, bytes-storedBytes); err != nil {
+				return nil, tenantStatusError(err)
+			}
+		}
+	case *pb.LockRequest:
+		r.Key = namespacedKey(tenant, r.Key)
+	case *pb.StatsRequest:
+	default:
+		return nil, status.Error(codes.PermissionDenied, errTenantForbidden.Error())
+	}
+
+	resp, err := handler(ctx, req)
+	if r, ok := resp.(*pb.BatchResponse); ok {
+		stripBatchResponse(r, tenant)
+	}
+	return resp, err
+}
+
+// streamInterceptor 为租户的 GetStream 请求加上命名空间，其他流式接口仅供节点间使用
+func (m *tenantManager) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	tenant := TenantFromContext(ss.Context())
+	if strings.HasPrefix(info.FullMethod, healthServicePrefix) {
+		return handler(srv, ss)
+	}
+	if tenant == "" {
+		return reservedKeyStreamInterceptor(srv, ss, info, handler)
+	}
+	if info.FullMethod != pb.CacheService_GetStream_FullMethodName {
+		return status.Error(codes.PermissionDenied, errTenantForbidden.Error())
+	}
+	if err := m.admit(tenant); err != nil {
+		return tenantStatusError(err)
+	}
+	return handler(srv, &tenantServerStream{ServerStream: ss, tenant: tenant})
+}
+
+// tenantServerStream 为接收到的请求加上租户命名空间
+type tenantServerStream struct {
+	grpc.ServerStream
+	tenant string
+}
+
+func (s *tenantServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if req, ok := m.(*pb.Request); ok {
+		req.Key = namespacedKey(s.tenant, req.Key)
+	}
+	return nil
+}
+
+// stripBatchResponse 去掉批量响应中 key 的命名空间前缀
+func stripBatchResponse(resp *pb.BatchResponse, tenant string) {
+	prefix := namespacedKey(tenant, "")
+	for _, entry := range resp.Entries {
+		entry.Key = strings.TrimPrefix(entry.Key, prefix)
+	}
+	if len(resp.Errors) > 0 {
+		errs := make(map[string]string, len(resp.Errors))
+		for key, msg := range resp.Errors {
+			errs[strings.TrimPrefix(key, prefix)] = msg
+		}
+		resp.Errors = errs
+	}
+}
+
+// tenantStatusError 将配额错误转换为带有错误详情的 gRPC 状态
+func tenantStatusError(err error) error {
+	if errors.Is(err, errInvalidTenant) {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return toStatusError(err)
+}
+
+// rateLimiter 令牌桶限流器
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // 每秒补充的令牌数
+	burst  float64 // 桶容量
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter 创建每秒 qps 个请求、允许 burst 个突发请求的限流器，初始时桶是满的
+func newRateLimiter(qps float64, burst int) *rateLimiter {
+	b := float64(burst)
+	if b <= 0 {
+		b = qps
+		if b < 1 {
+			b = 1
+		}
+	}
+	return &rateLimiter{rate: qps, burst: b, tokens: b}
+}
+
+// allow 取出一个令牌，桶为空时返回 false
+func (l *rateLimiter) allow(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -0,0 +1,229 @@
+package mycache
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/linhx1999/MyCache-Go/consistenthash"
+	pb "github.com/linhx1999/MyCache-Go/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// freeAddr 返回一个当前没有服务监听的本地地址
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+	return addr
+}
+
+func TestTenant_CacheMissLoadsOriginalKey(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		keys    []string
+		tenants []string
+	)
+	g := NewGroup("tenant-miss", 1<<20, DataSourceFunc(func(ctx context.Context, key string) ([]byte, error) {
+		mu.Lock()
+		keys = append(keys, key)
+		tenants = append(tenants, TenantFromContext(ctx))
+		mu.Unlock()
+		return []byte("value-" + key), nil
+	}))
+	defer g.Close()
+
+	addr := freeAddr(t)
+	srv, err := NewServer(addr, "tenant-test",
+		WithoutRegistry(),
+		WithAuth(TenantTokenAuth(map[string]string{"acme-token": "acme"})),
+		WithTenants(TenantQuota{}, nil),
+	)
+	if err != nil {
+		t.Fatalf("创建服务器失败: %v", err)
+	}
+	srv.RegisterGroup(g)
+	go srv.Start()
+	defer srv.Stop()
+
+	client, err := DialNode(addr, WithClientToken("acme-token"), WithWaitForReady(true))
+	if err != nil {
+		t.Fatalf("DialNode 失败: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	value, err := client.Get(ctx, g.name, "user42")
+	if err != nil {
+		t.Fatalf("Get 失败: %v", err)
+	}
+	if string(value) != "value-user42" {
+		t.Errorf("返回值应为 value-user42，实际为 %q", value)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(keys) != 1 || keys[0] != "user42" {
+		t.Fatalf("数据源应收到原始 key user42，实际为 %q", keys)
+	}
+	if tenants[0] != "acme" {
+		t.Errorf("数据源应能通过 TenantFromContext 取得租户 acme，实际为 %q", tenants[0])
+	}
+	if _, ok := g.localCache.Get(ctx, namespacedKey("acme", "user42")); !ok {
+		t.Errorf("值应缓存在租户命名空间下")
+	}
+}
+
+func TestTenant_RejectsReservedKeyWithoutTenant(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		tenants []string
+	)
+	g := NewGroup("tenant-reserved", 1<<20, DataSourceFunc(func(ctx context.Context, key string) ([]byte, error) {
+		mu.Lock()
+		tenants = append(tenants, TenantFromContext(ctx))
+		mu.Unlock()
+		return []byte("value-" + key), nil
+	}))
+	defer g.Close()
+
+	addr, httpAddr := freeAddr(t), freeAddr(t)
+	srv, err := NewServer(addr, "tenant-test",
+		WithoutRegistry(),
+		WithAuth(TenantTokenAuth(map[string]string{"acme-token": "acme", "ops-token": ""})),
+		WithTenants(TenantQuota{}, nil),
+		WithHTTPAddr(httpAddr),
+	)
+	if err != nil {
+		t.Fatalf("创建服务器失败: %v", err)
+	}
+	srv.RegisterGroup(g)
+	go srv.Start()
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer conn.Close()
+	cli := pb.NewCacheServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, authorizationMetadataKey, "Bearer ops-token")
+	key := namespacedKey("acme", "secret")
+
+	// 不属于任何租户的普通客户端不能构造租户前缀冒充租户
+	_, err = cli.Get(ctx, &pb.Request{Group: g.name, Key: key}, grpc.WaitForReady(true))
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Get 应返回 InvalidArgument，实际为 %v", err)
+	}
+	_, err = cli.Set(ctx, &pb.Request{Group: g.name, Key: key, Value: []byte("forged")})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Set 应返回 InvalidArgument，实际为 %v", err)
+	}
+
+	resp, err := http.Get("http://" + httpAddr + "/api/groups/" + g.name + "/keys/" + url.PathEscape(key))
+	if err != nil {
+		t.Fatalf("REST 请求失败: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("REST 接口应返回 400，实际为 %d", resp.StatusCode)
+	}
+
+	mu.Lock()
+	if len(tenants) != 0 {
+		t.Errorf("被拒绝的请求不应访问数据源，实际以租户 %q 加载", tenants)
+	}
+	mu.Unlock()
+	if _, ok := g.localCache.Get(ctx, key); ok {
+		t.Errorf("被拒绝的写入不应出现在租户命名空间下")
+	}
+
+	// 其他节点转发的请求携带节点标记，仍按 key 的前缀以租户身份加载
+	peerCtx := metadata.AppendToOutgoingContext(ctx, peerMetadataKey, "true")
+	if _, err := cli.Get(peerCtx, &pb.Request{Group: g.name, Key: key}); err != nil {
+		t.Fatalf("节点间的 Get 失败: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(tenants) != 1 || tenants[0] != "acme" {
+		t.Errorf("节点间的请求应以租户 acme 加载，实际为 %q", tenants)
+	}
+}
+
+func TestTenant_OverwriteWithinQuota(t *testing.T) {
+	g := NewGroup("tenant-overwrite", 1<<20, DataSourceFunc(func(ctx context.Context, key string) ([]byte, error) {
+		return nil, ErrNotFound
+	}))
+	defer g.Close()
+
+	addr := freeAddr(t)
+	srv, err := NewServer(addr, "tenant-test",
+		WithoutRegistry(),
+		WithAuth(TenantTokenAuth(map[string]string{"acme-token": "acme"})),
+		WithTenants(TenantQuota{MaxKeys: 1, MaxBytes: 64}, nil),
+	)
+	if err != nil {
+		t.Fatalf("创建服务器失败: %v", err)
+	}
+	srv.RegisterGroup(g)
+	go srv.Start()
+	defer srv.Stop()
+
+	client, err := DialNode(addr, WithClientToken("acme-token"), WithWaitForReady(true))
+	if err != nil {
+		t.Fatalf("DialNode 失败: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if err := client.Set(ctx, g.name, "k", []byte("v1")); err != nil {
+		t.Fatalf("Set 失败: %v", err)
+	}
+	// 覆盖已有的 key 不增加 key 的数量，只计入新旧值的差额
+	for _, value := range []string{"v2", "v3", "v"} {
+		if err := client.Set(ctx, g.name, "k", []byte(value)); err != nil {
+			t.Errorf("覆盖写入 %s 不应超出配额: %v", value, err)
+		}
+	}
+	if err := client.Set(ctx, g.name, "other", []byte("v")); err == nil {
+		t.Errorf("新增 key 应超出 MaxKeys")
+	}
+}
+
+func TestTenant_NamespacedKey(t *testing.T) {
+	key := namespacedKey("acme", "user42")
+	tenant, userKey := splitTenantKey(key)
+	if tenant != "acme" || userKey != "user42" {
+		t.Errorf("拆分结果应为 (acme, user42)，实际为 (%q, %q)", tenant, userKey)
+	}
+	if tenant, userKey := splitTenantKey("{acme}user42"); tenant != "" || userKey != "{acme}user42" {
+		t.Errorf("不在命名空间下的 key 应原样返回，实际为 (%q, %q)", tenant, userKey)
+	}
+
+	// 租户前缀不能被当作哈希标签，否则租户的所有 key 会路由到同一个节点
+	if tag := consistenthash.HashTag(key); tag != key {
+		t.Errorf("没有哈希标签的租户 key 应整体参与哈希，实际为 %q", tag)
+	}
+	if tag := consistenthash.HashTag(namespacedKey("acme", "{u1}:profile")); tag != "u1" {
+		t.Errorf("租户 key 自带的哈希标签应保持有效，实际为 %q", tag)
+	}
+}
@@ -0,0 +1,231 @@
+package mycache
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// certCheckInterval 检查证书文件是否变化的最短间隔
+	certCheckInterval = 5 * time.Second
+	// certExpiryWarning 证书剩余有效期低于该值时在加载时打印警告
+	certExpiryWarning = 24 * time.Hour
+)
+
+// WithTLSConfig 使用自定义的 TLS 配置，优先于 WithTLS 和 WithClientCA
+// 用于接入 SPIFFE（如 go-spiffe 的 tlsconfig.MTLSServerConfig）或 ACME（如 autocert.Manager.TLSConfig）
+// 等自行管理证书轮换的方案：配置的 GetCertificate、GetConfigForClient 和 VerifyPeerCertificate
+// 在每次握手时调用，证书更新后新连接立即生效，无需重启节点。
+func WithTLSConfig(config *tls.Config) ServerOption {
+	return func(o *ServerOptions) {
+		o.TLSConfig = config
+	}
+}
+
+// WithClientTLSConfig 使用自定义的 TLS 配置连接节点，优先于 WithClientTLS
+// 与 WithTLSConfig 对应，配置的 GetClientCertificate 在每次握手时调用以获取最新的客户端证书
+func WithClientTLSConfig(config *tls.Config) ClientOption {
+	return func(o *clientOptions) {
+		o.tlsConfig = config
+	}
+}
+
+// WithClientCA 要求客户端出示证书，并使用 caFile 中的 CA 校验，需同时启用 WithTLS
+// 节点间的请求因此是双向认证的；客户端通过 WithClientTLS 配置证书
+func WithClientCA(caFile string) ServerOption {
+	return func(o *ServerOptions) {
+		o.ClientCAFile = caFile
+	}
+}
+
+// WithClientTLS 使用 TLS 连接节点，出示 certFile/keyFile 中的证书，并使用 caFile 校验服务端证书
+// 对端通过 WithClientCA 要求双向认证时必须配置证书；只需加密时 certFile 和 keyFile 可为空。
+// caFile 为空时使用系统根证书。证书文件变化后自动重新加载。
+func WithClientTLS(certFile, keyFile, caFile string) ClientOption {
+	return func(o *clientOptions) {
+		o.tls = &certReloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	}
+}
+
+// certReloader 在证书或 CA 文件修改后重新加载，无需重启即可轮换证书
+// 每次握手时检查文件修改时间，检查间隔不小于 certCheckInterval
+type certReloader struct {
+	certFile, keyFile string
+	caFile            string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	certMod   time.Time
+	caMod     time.Time
+	lastCheck time.Time
+}
+
+// newCertReloader 创建并立即加载证书，文件无效时返回错误
+func newCertReloader(certFile, keyFile, caFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load 重新读取修改过的文件
+func (r *certReloader) load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastCheck = time.Now()
+
+	if r.certFile != "" {
+		mod, err := latestModTime(r.certFile, r.keyFile)
+		if err != nil {
+			return err
+		}
+		if r.cert == nil || mod.After(r.certMod) {
+			cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+			if err != nil {
+				return err
+			}
+			if r.cert != nil {
+				log.Printf("[MyCache] reloaded TLS certificate from %s", r.certFile)
+			}
+			r.cert, r.certMod = &cert, mod
+			warnCertExpiry(r.certFile, &cert)
+		}
+	}
+
+	if r.caFile != "" {
+		mod, err := latestModTime(r.caFile)
+		if err != nil {
+			return err
+		}
+		if r.pool == nil || mod.After(r.caMod) {
+			data, err := os.ReadFile(r.caFile)
+			if err != nil {
+				return err
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(data) {
+				return fmt.Errorf("no valid certificates in %s", r.caFile)
+			}
+			if r.pool != nil {
+				log.Printf("[MyCache] reloaded CA certificates from %s", r.caFile)
+			}
+			r.pool, r.caMod = pool, mod
+		}
+	}
+	return nil
+}
+
+// maybeReload 距上次检查超过 certCheckInterval 时重新加载，失败时继续使用旧证书
+func (r *certReloader) maybeReload() {
+	r.mu.Lock()
+	due := time.Since(r.lastCheck) >= certCheckInterval
+	r.mu.Unlock()
+	if !due {
+		return
+	}
+	if err := r.load(); err != nil {
+		log.Printf("[MyCache] ERROR: failed to reload TLS certificates, keeping the previous ones: %v", err)
+	}
+}
+
+// current 返回当前的证书和 CA
+func (r *certReloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.maybeReload()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert, r.pool
+}
+
+// serverConfig 返回服务端的 TLS 配置，配置了 CA 时要求并校验客户端证书
+func (r *certReloader) serverConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := r.current()
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				NextProtos:   []string{"h2"}, // 替换后的配置需保留 gRPC 要求的 ALPN
+			}
+			if pool != nil {
+				config.ClientCAs = pool
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return config, nil
+		},
+	}
+}
+
+// clientConfig 返回客户端的 TLS 配置
+// 客户端证书和校验服务端证书使用的 CA 都在每次握手时重新获取，轮换后重连即生效
+func (r *certReloader) clientConfig() *tls.Config {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if r.caFile != "" {
+		// RootCAs 在创建连接时固定，改为在握手时使用当前的 CA 自行校验
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			_, pool := r.current()
+			return verifyServerCert(cs, pool)
+		}
+	}
+	if r.certFile != "" {
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := r.current()
+			return cert, nil
+		}
+	}
+	return config
+}
+
+// verifyServerCert 使用 pool 校验服务端的证书链和主机名
+func verifyServerCert(cs tls.ConnectionState, pool *x509.CertPool) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("cache: server presented no certificate")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         pool,
+		DNSName:       cs.ServerName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// warnCertExpiry 证书即将过期时打印警告
+func warnCertExpiry(file string, cert *tls.Certificate) {
+	if len(cert.Certificate) == 0 {
+		return
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return
+	}
+	if left := time.Until(leaf.NotAfter); left < certExpiryWarning {
+		log.Printf("[MyCache] WARN: TLS certificate %s expires at %s", file, leaf.NotAfter.Format(time.RFC3339))
+	}
+}
+
+// latestModTime 返回多个文件中最晚的修改时间
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
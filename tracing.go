@@ -0,0 +1,185 @@
+package mycache
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// tracerName 创建 Tracer 时使用的名称
+const tracerName = "github.com/linhx1999/MyCache-Go"
+
+// WithTracing 为所有 RPC（健康检查除外）创建 OpenTelemetry 服务端 span
+//
+// 请求元数据中的 trace 上下文（如 W3C traceparent）由 prop 解析，span 作为其子 span，
+// 并随 ctx 传递给 Group 的读写和 DataSource 调用，使一次请求能从应用经缓存节点追踪到数据库。
+// tp 或 prop 为 nil 时使用 otel 的全局设置。节点间请求需配合 WithClientTracing 继续传播。
+func WithTracing(tp trace.TracerProvider, prop propagation.TextMapPropagator) ServerOption {
+	return func(o *ServerOptions) {
+		o.Tracing = newTracing(tp, prop)
+	}
+}
+
+// WithClientTracing 为客户端的每个请求创建 span，并将 trace 上下文注入到请求元数据中
+// 通过 ClientPicker 使用时配合 WithClientOptions，节点间的转发和同步也会被追踪
+func WithClientTracing(tp trace.TracerProvider, prop propagation.TextMapPropagator) ClientOption {
+	return func(o *clientOptions) {
+		o.tracing = newTracing(tp, prop)
+	}
+}
+
+// tracing 创建 span 和传播 trace 上下文
+type tracing struct {
+	tracer trace.Tracer
+	prop   propagation.TextMapPropagator
+}
+
+// newTracing 创建 tracing，参数为 nil 时使用全局设置
+func newTracing(tp trace.TracerProvider, prop propagation.TextMapPropagator) *tracing {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	if prop == nil {
+		prop = otel.GetTextMapPropagator()
+	}
+	return &tracing{tracer: tp.Tracer(tracerName), prop: prop}
+}
+
+// metadataCarrier 将 gRPC 元数据适配为 propagation.TextMapCarrier
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// rpcAttributes 返回 RPC span 的属性
+func rpcAttributes(method string) []attribute.KeyValue {
+	service, name, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	return []attribute.KeyValue{
+		attribute.String("rpc.system", "grpc"),
+		attribute.String("rpc.service", service),
+		attribute.String("rpc.method", name),
+	}
+}
+
+// startServer 从请求元数据中解析 trace 上下文并开始服务端 span
+func (t *tracing) startServer(ctx context.Context, method string) (context.Context, trace.Span) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = t.prop.Extract(ctx, metadataCarrier(md))
+	}
+	return t.tracer.Start(ctx, strings.TrimPrefix(method, "/"),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(rpcAttributes(method)...))
+}
+
+// startClient 开始客户端 span，并将 trace 上下文注入到请求元数据中
+func (t *tracing) startClient(ctx context.Context, method string) (context.Context, trace.Span) {
+	ctx, span := t.tracer.Start(ctx, strings.TrimPrefix(method, "/"),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(rpcAttributes(method)...))
+
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	t.prop.Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md), span
+}
+
+// endSpan 记录 RPC 的状态码并结束 span
+func endSpan(span trace.Span, err error) {
+	st := status.Convert(err)
+	span.SetAttributes(attribute.Int64("rpc.grpc.status_code", int64(st.Code())))
+	if err != nil {
+		span.SetStatus(otelcodes.Error, st.Message())
+	}
+	span.End()
+}
+
+// setGroupAttribute 记录请求访问的组
+func setGroupAttribute(span trace.Span, req interface{}) {
+	if r, ok := req.(interface{ GetGroup() string }); ok && r.GetGroup() != "" {
+		span.SetAttributes(attribute.String("mycache.group", r.GetGroup()))
+	}
+}
+
+// unaryServerInterceptor 为一元请求创建服务端 span
+func (t *tracing) unaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if strings.HasPrefix(info.FullMethod, healthServicePrefix) {
+		return handler(ctx, req)
+	}
+	ctx, span := t.startServer(ctx, info.FullMethod)
+	setGroupAttribute(span, req)
+	resp, err := handler(ctx, req)
+	endSpan(span, err)
+	return resp, err
+}
+
+// streamServerInterceptor 为流式请求创建服务端 span
+func (t *tracing) streamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if strings.HasPrefix(info.FullMethod, healthServicePrefix) {
+		return handler(srv, ss)
+	}
+	ctx, span := t.startServer(ss.Context(), info.FullMethod)
+	err := handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+	endSpan(span, err)
+	return err
+}
+
+// unaryClientInterceptor 为一元请求创建客户端 span
+func (t *tracing) unaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	ctx, span := t.startClient(ctx, method)
+	setGroupAttribute(span, req)
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	endSpan(span, err)
+	return err
+}
+
+// streamClientInterceptor 为流式请求创建客户端 span，span 在建立流时结束
+func (t *tracing) streamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	ctx, span := t.startClient(ctx, method)
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	endSpan(span, err)
+	return stream, err
+}
+
+// startSpan 在 ctx 中已有 span 时为组内的操作开始一个子 span，否则返回不记录的 span
+// 使用 ctx 中 span 所属的 TracerProvider，未启用追踪时没有额外开销
+func startSpan(ctx context.Context, name, group string) (context.Context, trace.Span) {
+	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName)
+	return tracer.Start(ctx, name, trace.WithAttributes(attribute.String("mycache.group", group)))
+}
+
+// finishSpan 记录错误并结束 startSpan 开始的 span
+func finishSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	span.End()
+}
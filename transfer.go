@@ -0,0 +1,200 @@
+package mycache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/linhx1999/MyCache-Go/pb"
+)
+
+const (
+	transferBatchSize = 128 // 每批迁移的缓存项数量
+	transferWindow    = 4   // 未确认的批次上限，超过后发送方等待接收方确认
+)
+
+// TransferEntry 节点间迁移的缓存项
+type TransferEntry struct {
+	Key      string
+	Value    []byte
+	ExpireAt time.Time // 过期时间，零值表示永不过期
+}
+
+// TransferResult 一次迁移的结果
+type TransferResult struct {
+	Sent   int // 已发送的缓存项数量
+	Stored int // 接收方成功写入的数量
+	Failed int // 接收方写入失败的数量（如超出配额）
+}
+
+// Transfer 通过双向流将 entries 中的缓存项批量迁移到该节点，直到 entries 关闭
+//
+// 缓存项按批发送，未确认的批次达到上限时等待接收方确认，避免接收方处理不过来时
+// 数据在内存中堆积。接收方按原有的过期时间写入，已过期的缓存项会被跳过，不会同步到其他节点。
+// 用于节点加入或离开时的数据重平衡和预热。对端不支持 Transfer 时逐个 Set。
+func (c *Client) Transfer(ctx context.Context, group string, entries <-chan TransferEntry) (TransferResult, error) {
+	if !c.Supports(FeatureTransfer) {
+		// 对端是不支持 Transfer 的旧版本节点
+		return c.transferEach(ctx, group, entries)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var result TransferResult
+	stream, err := c.pick().Transfer(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to open transfer stream: %w", err)
+	}
+
+	// 确认由接收 goroutine 统计，出错提前返回时它可能仍在运行
+	var stored, failed atomic.Int64
+	done := func(err error) (TransferResult, error) {
+		result.Stored = int(stored.Load())
+		result.Failed = int(failed.Load())
+		if err != nil {
+			return result, fmt.Errorf("failed to transfer entries: %w", err)
+		}
+		return result, nil
+	}
+
+	// 接收确认，每收到一个确认释放一个发送窗口
+	window := make(chan struct{}, transferWindow)
+	recvDone := make(chan error, 1)
+	go func() {
+		for {
+			ack, err := stream.Recv()
+			if err == io.EOF {
+				recvDone <- nil
+				return
+			}
+			if err != nil {
+				recvDone <- err
+				return
+			}
+			stored.Add(int64(ack.GetStored()))
+			failed.Add(int64(ack.GetFailed()))
+			<-window
+		}
+	}()
+
+	var seq uint64
+	send := func(batch []*pb.TransferEntry) error {
+		select {
+		case window <- struct{}{}:
+		case err := <-recvDone:
+			if err == nil {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		seq++
+		if err := stream.Send(&pb.TransferBatch{Group: group, Seq: seq, Entries: batch}); err != nil {
+			return err
+		}
+		result.Sent += len(batch)
+		return nil
+	}
+
+	batch := make([]*pb.TransferEntry, 0, transferBatchSize)
+	for entry := range entries {
+		batch = append(batch, toTransferEntry(entry))
+		if len(batch) < transferBatchSize {
+			continue
+		}
+		if err := send(batch); err != nil {
+			return done(err)
+		}
+		batch = make([]*pb.TransferEntry, 0, transferBatchSize)
+	}
+	if len(batch) > 0 {
+		if err := send(batch); err != nil {
+			return done(err)
+		}
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return done(err)
+	}
+	// 等待所有确认
+	return done(<-recvDone)
+}
+
+// toTransferEntry 转换为传输格式
+func toTransferEntry(entry TransferEntry) *pb.TransferEntry {
+	out := &pb.TransferEntry{Key: entry.Key, Value: entry.Value}
+	if !entry.ExpireAt.IsZero() {
+		out.ExpireAt = entry.ExpireAt.UnixNano()
+	}
+	return out
+}
+
+// Transfer 实现Cache服务的Transfer方法，逐批写入迁移过来的缓存项并回复确认
+func (s *Server) Transfer(stream pb.CacheService_TransferServer) error {
+	ctx := context.WithValue(stream.Context(), "from_peer", true)
+
+	for {
+		batch, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		group := s.group(batch.Group)
+		if group == nil {
+			return errGroupNotFound(batch.Group)
+		}
+
+		ack := &pb.TransferAck{Seq: batch.Seq}
+		now := time.Now()
+		for _, entry := range batch.Entries {
+			var expireAt time.Time
+			if entry.ExpireAt != 0 {
+				expireAt = time.Unix(0, entry.ExpireAt)
+				if !expireAt.After(now) {
+					continue
+				}
+			}
+			if err := group.storeTransferred(ctx, entry.Key, entry.Value, expireAt); err != nil {
+				ack.Failed++
+				continue
+			}
+			ack.Stored++
+		}
+
+		if err := stream.Send(ack); err != nil {
+			return err
+		}
+	}
+}
+
+// storeTransferred 写入从其他节点迁移过来的缓存项，保留原有的过期时间且不再同步到其他节点
+func (g *Group) storeTransferred(ctx context.Context, key string, value []byte, expireAt time.Time) error {
+	if g.closed.Load() == 1 {
+		return ErrGroupClosed
+	}
+	if key == "" {
+		return ErrKeyRequired
+	}
+	if err := g.checkQuota(key, len(value)); err != nil {
+		g.stats.quotaRejects.Add(1)
+		return err
+	}
+
+	byteView := ByteView{b: cloneBytes(value)}
+	if expireAt.IsZero() {
+		byteView = g.saveToLocal(key, byteView)
+	} else {
+		byteView = g.saveWithExpire(key, byteView, expireAt)
+	}
+	g.forgetLoads(key)
+
+	g.publish(EventSet, key, byteView, originFromContext(ctx))
+	return nil
+}
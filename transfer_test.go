@@ -0,0 +1,104 @@
+package mycache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// transferEntries 返回依次发送 entries 的通道
+func transferEntries(entries []TransferEntry) <-chan TransferEntry {
+	ch := make(chan TransferEntry, len(entries))
+	for _, entry := range entries {
+		ch <- entry
+	}
+	close(ch)
+	return ch
+}
+
+// newTransferEntries 生成 n 个缓存项，偶数 key 带过期时间，另加一个已过期和一个空 key 的缓存项
+func newTransferEntries(n int, expire time.Time) []TransferEntry {
+	entries := make([]TransferEntry, 0, n+2)
+	for i := 0; i < n; i++ {
+		entry := TransferEntry{Key: fmt.Sprintf("key-%d", i), Value: []byte(fmt.Sprintf("value-%d", i))}
+		if i%2 == 0 {
+			entry.ExpireAt = expire
+		}
+		entries = append(entries, entry)
+	}
+	entries = append(entries,
+		TransferEntry{Key: "expired", Value: []byte("v"), ExpireAt: time.Now().Add(-time.Second)},
+		TransferEntry{Key: "", Value: []byte("v")},
+	)
+	return entries
+}
+
+// checkTransferred 检查 g 中的 n 个缓存项及其过期时间
+func checkTransferred(t *testing.T, g *Group, n int, expire time.Time) {
+	t.Helper()
+	ctx := context.Background()
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		view, ok := g.localCache.Get(ctx, key)
+		if !ok || view.String() != fmt.Sprintf("value-%d", i) {
+			t.Errorf("%s 应已迁移，实际为 %q（found=%v）", key, view.String(), ok)
+			continue
+		}
+		if i%2 == 0 && (view.expire.After(expire) || expire.Sub(view.expire) > time.Second) {
+			t.Errorf("%s 应保留过期时间 %v，实际为 %v", key, expire, view.expire)
+		}
+		if i%2 == 1 && !view.expire.IsZero() {
+			t.Errorf("%s 永不过期，实际过期时间为 %v", key, view.expire)
+		}
+	}
+	if _, ok := g.localCache.Get(ctx, "expired"); ok {
+		t.Error("已过期的缓存项不应写入")
+	}
+}
+
+func TestTransfer_Stream(t *testing.T) {
+	g := newBatchGroup("transfer-stream")
+	defer g.Close()
+	client := startBatchServer(t, g)
+
+	// 超过发送窗口的批次数，发送方需要等待确认
+	n := transferBatchSize*transferWindow*2 + 10
+	expire := time.Now().Add(time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := client.Transfer(ctx, g.name, transferEntries(newTransferEntries(n, expire)))
+	if err != nil {
+		t.Fatalf("Transfer 失败: %v", err)
+	}
+
+	if result.Sent != n+2 {
+		t.Errorf("Sent 应为 %d，实际为 %d", n+2, result.Sent)
+	}
+	if result.Stored != n || result.Failed != 1 {
+		t.Errorf("应写入 %d 个、失败 1 个（空 key），实际为 %+v", n, result)
+	}
+	checkTransferred(t, g, n, expire)
+}
+
+func TestTransfer_FallsBackToSet(t *testing.T) {
+	g := newBatchGroup("transfer-fallback")
+	defer g.Close()
+	client := startBatchServer(t, g)
+	// 对端是不支持 Transfer 的旧版本节点
+	client.proto.Store(&peerProtocol{version: ProtocolVersion, features: map[string]bool{FeatureBatch: true}})
+
+	n := 20
+	expire := time.Now().Add(time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := client.Transfer(ctx, g.name, transferEntries(newTransferEntries(n, expire)))
+	if err != nil {
+		t.Fatalf("Transfer 失败: %v", err)
+	}
+
+	if result.Stored != n || result.Failed != 1 {
+		t.Errorf("应写入 %d 个、失败 1 个（空 key），实际为 %+v", n, result)
+	}
+	checkTransferred(t, g, n, expire)
+}
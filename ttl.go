@@ -0,0 +1,90 @@
+package mycache
+
+import (
+	"context"
+	"time"
+
+	pb "github.com/linhx1999/MyCache-Go/pb"
+)
+
+// writeExpireKey 写操作的过期时间在 context 中的键
+type writeExpireKey struct{}
+
+// withWriteExpire 返回携带写操作过期时间的 ctx，零值表示使用组的默认过期时间
+func withWriteExpire(ctx context.Context, expire time.Time) context.Context {
+	if expire.IsZero() {
+		return ctx
+	}
+	return context.WithValue(ctx, writeExpireKey{}, expire)
+}
+
+// writeExpireFromContext 返回 ctx 中写操作的过期时间，未设置时为零值
+func writeExpireFromContext(ctx context.Context) time.Time {
+	expire, _ := ctx.Value(writeExpireKey{}).(time.Time)
+	return expire
+}
+
+// SetWithTTL 设置缓存值并指定存活时间，覆盖组的默认过期时间；ttl <= 0 时与 Set 相同
+// 同步到其他节点时会携带过期时间，owner 上的副本与本节点同时过期
+func (g *Group) SetWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl > 0 {
+		ctx = withWriteExpire(ctx, time.Now().Add(ttl))
+	}
+	return g.Set(ctx, key, value)
+}
+
+// SetWithTTL 设置缓存值并指定存活时间，对端按该时间过期而不是使用组的默认过期时间
+func (c *Client) SetWithTTL(ctx context.Context, group, key string, value []byte, ttl time.Duration) error {
+	if ttl > 0 {
+		ctx = withWriteExpire(ctx, time.Now().Add(ttl))
+	}
+	return c.Set(ctx, group, key, value)
+}
+
+// setRequest 根据 ctx 中的过期时间和来源构造 Set 请求
+// 同时发送剩余时间和绝对时间，接收方取较早者，减小传输延迟和时钟偏差的影响
+func setRequest(ctx context.Context, group, key string, value []byte) *pb.Request {
+	req := &pb.Request{Group: group, Key: key, Value: value}
+	req.TtlMs, req.ExpireAt = expireFields(writeExpireFromContext(ctx))
+	if ctx.Value("from_peer") != nil {
+		req.Flags |= uint32(pb.RequestFlag_FLAG_FROM_PEER)
+	}
+	return req
+}
+
+// expireFields 返回请求中表示 expire 的剩余毫秒数和 Unix 纳秒时间戳，expire 为零值时都为 0
+func expireFields(expire time.Time) (ttlMs, expireAt int64) {
+	if expire.IsZero() {
+		return 0, 0
+	}
+	ttlMs = time.Until(expire).Milliseconds()
+	if ttlMs <= 0 {
+		// 已过期的值仍然发送，由接收方丢弃
+		ttlMs = -1
+	}
+	return ttlMs, expire.UnixNano()
+}
+
+// requestExpire 返回 Set 请求的过期时间，未指定时为零值
+func requestExpire(req *pb.Request, now time.Time) time.Time {
+	return expireFromFields(req.GetTtlMs(), req.GetExpireAt(), now)
+}
+
+// expireFromFields 根据剩余毫秒数和 Unix 纳秒时间戳计算过期时间，取较早者，都为 0 时为零值
+func expireFromFields(ttl, at int64, now time.Time) time.Time {
+	var expire time.Time
+	if ttl != 0 {
+		expire = now.Add(time.Duration(ttl) * time.Millisecond)
+	}
+	if at != 0 {
+		if deadline := time.Unix(0, at); expire.IsZero() || deadline.Before(expire) {
+			expire = deadline
+		}
+	}
+	return expire
+}
+
+// isFromPeer 判断请求是否为节点间同步的写操作
+func isFromPeer(req *pb.Request) bool {
+	return req.GetFlags()&uint32(pb.RequestFlag_FLAG_FROM_PEER) != 0
+}
@@ -0,0 +1,312 @@
+package mycache
+
+import (
+	"context"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/linhx1999/MyCache-Go/pb"
+	"github.com/linhx1999/MyCache-Go/registry"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ProtocolVersion 节点间协议的版本，新增 RPC 或改变已有 RPC 的语义时递增
+// 版本 1 只有 Get、Set、Delete；不携带版本信息的节点视为未知版本
+const ProtocolVersion = 2
+
+const (
+	// versionMetadataKey 请求和响应头中携带协议版本的元数据键
+	versionMetadataKey = "x-mycache-version"
+	// featuresMetadataKey 响应头中携带服务端支持的功能的元数据键，逗号分隔
+	featuresMetadataKey = "x-mycache-features"
+)
+
+// 节点间可协商的可选功能，对端不支持时客户端自动降级
+const (
+	FeatureBatch       = "batch"       // MGet、MSet、MDelete，降级为逐个 key 请求
+	FeatureStream      = "stream"      // GetStream，降级为返回值过大的错误
+	FeatureTransfer    = "transfer"    // Transfer 双向流迁移，降级为逐个 Set
+	FeatureCompression = "compression" // 请求压缩，降级为不压缩
+	FeatureRebalance   = "rebalance"   // Rebalance 再平衡，不支持时该节点不参与集群再平衡
+	FeatureBackup      = "backup"      // Backup 集群备份，不支持时该节点不参与集群备份
+	FeatureLock        = "lock"        // Lock 分布式锁，不支持时无法获取 owner 为该节点的锁
+	FeatureSyncBatch   = "sync_batch"  // MSet 中每个 key 携带过期时间，用于批量同步，降级为逐个 key 同步
+)
+
+// Features 返回本版本支持的全部功能，随注册信息和响应头发布
+func Features() []string {
+	return []string{FeatureBatch, FeatureStream, FeatureTransfer, FeatureCompression, FeatureRebalance, FeatureBackup, FeatureLock, FeatureSyncBatch}
+}
+
+// featureMethods RPC 方法到所属功能的映射
+var featureMethods = map[string]string{
+	pb.CacheService_MGet_FullMethodName:      FeatureBatch,
+	pb.CacheService_MSet_FullMethodName:      FeatureBatch,
+	pb.CacheService_MDelete_FullMethodName:   FeatureBatch,
+	pb.CacheService_GetStream_FullMethodName: FeatureStream,
+	pb.CacheService_Transfer_FullMethodName:  FeatureTransfer,
+	pb.CacheService_Rebalance_FullMethodName: FeatureRebalance,
+	pb.CacheService_Backup_FullMethodName:    FeatureBackup,
+	pb.CacheService_Lock_FullMethodName:      FeatureLock,
+}
+
+// peerProtocol 对端的协议版本和支持的功能
+type peerProtocol struct {
+	version  int             // 0 表示未知
+	features map[string]bool // nil 表示未知，视为全部支持
+}
+
+// versionHeader 服务端在响应头中返回的协议信息，同一进程内不变
+var versionHeader = metadata.Pairs(
+	versionMetadataKey, strconv.Itoa(ProtocolVersion),
+	featuresMetadataKey, strings.Join(Features(), ","),
+)
+
+// versionUnaryServerInterceptor 在响应头中返回本节点的协议版本和功能
+func versionUnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	grpc.SetHeader(ctx, versionHeader)
+	return handler(ctx, req)
+}
+
+// versionStreamServerInterceptor 在流的响应头中返回本节点的协议版本和功能
+func versionStreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ss.SetHeader(versionHeader)
+	return handler(srv, ss)
+}
+
+// PeerVersion 返回对端的协议版本，尚未与对端通信或对端不携带版本信息时返回 0
+func (c *Client) PeerVersion() int {
+	if p := c.proto.Load(); p != nil {
+		return p.version
+	}
+	return 0
+}
+
+// Supports 判断对端是否支持某项功能，尚未得知对端的功能时返回 true
+func (c *Client) Supports(feature string) bool {
+	p := c.proto.Load()
+	return p == nil || p.features == nil || p.features[feature]
+}
+
+// setProtocol 记录对端的协议版本和功能，来自注册信息或响应头
+// 版本不变时保留已有的信息，不会重新启用请求中发现对端不支持的功能
+func (c *Client) setProtocol(version int, features []string) {
+	if version <= 0 {
+		return
+	}
+	if old := c.proto.Load(); old != nil && old.version == version {
+		return
+	}
+	p := &peerProtocol{version: version, features: make(map[string]bool, len(features))}
+	for _, f := range features {
+		p.features[f] = true
+	}
+	c.proto.Store(p)
+	log.Printf("[Client] peer %s speaks protocol version %d (features: %s)", c.addr, version, strings.Join(features, ","))
+}
+
+// disableFeature 对端返回 Unimplemented 时标记其不支持该功能，之后的请求直接降级
+func (c *Client) disableFeature(feature string) {
+	for {
+		old := c.proto.Load()
+		p := &peerProtocol{features: make(map[string]bool)}
+		if old != nil {
+			p.version = old.version
+		}
+		if old == nil || old.features == nil {
+			for _, f := range Features() {
+				p.features[f] = true
+			}
+		} else {
+			for f, ok := range old.features {
+				p.features[f] = ok
+			}
+		}
+		if !p.features[feature] {
+			return
+		}
+		p.features[feature] = false
+		if c.proto.CompareAndSwap(old, p) {
+			log.Printf("[Client] WARN: peer %s does not support %s, falling back", c.addr, feature)
+			return
+		}
+	}
+}
+
+// setProtocol 按注册信息设置对端客户端的协议版本和功能，调用者必须持有写锁
+// 注册信息中没有版本时（节点回滚到旧版本）重置为未知，由后续请求重新探测
+func (p *ClientPicker) setProtocol(inst registry.Instance) {
+	client, ok := p.clients[inst.Addr]
+	if !ok {
+		return
+	}
+	if inst.Version <= 0 {
+		client.proto.Store(nil)
+		return
+	}
+	client.setProtocol(inst.Version, inst.Features)
+}
+
+// learnProtocol 从响应头中解析对端的协议版本和功能，旧版本节点的响应头中没有这些信息
+func (c *Client) learnProtocol(header metadata.MD) {
+	v := header.Get(versionMetadataKey)
+	if len(v) == 0 {
+		return
+	}
+	version, err := strconv.Atoi(v[0])
+	if err != nil {
+		return
+	}
+	if p := c.proto.Load(); p != nil && p.version == version {
+		return
+	}
+	var features []string
+	if f := header.Get(featuresMetadataKey); len(f) > 0 && f[0] != "" {
+		features = strings.Split(f[0], ",")
+	}
+	c.setProtocol(version, features)
+}
+
+// noteUnimplemented 对端返回 Unimplemented 时标记对应的功能不受支持
+func (c *Client) noteUnimplemented(method string, err error) {
+	if status.Code(err) != codes.Unimplemented {
+		return
+	}
+	if feature, ok := featureMethods[method]; ok {
+		c.disableFeature(feature)
+	}
+}
+
+// isCompressionUnsupported 判断错误是否由对端不支持请求使用的压缩算法导致
+func isCompressionUnsupported(err error) bool {
+	st, ok := status.FromError(err)
+	return ok && st.Code() == codes.Unimplemented && strings.Contains(st.Message(), "compress")
+}
+
+// versionUnaryClientInterceptor 携带本节点的协议版本，从响应头中获取对端的协议信息，
+// 对端支持时压缩请求，对端不支持压缩时去掉压缩重试一次
+func (c *Client) versionUnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	ctx = metadata.AppendToOutgoingContext(ctx, versionMetadataKey, strconv.Itoa(ProtocolVersion))
+
+	compress := c.opts.compressor != "" && c.Supports(FeatureCompression)
+	call := func(compress bool) error {
+		var header metadata.MD
+		callOpts := append(opts[:len(opts):len(opts)], grpc.Header(&header))
+		if compress {
+			callOpts = append(callOpts, grpc.UseCompressor(c.opts.compressor))
+		}
+		err := invoker(ctx, method, req, reply, cc, callOpts...)
+		c.learnProtocol(header)
+		return err
+	}
+
+	err := call(compress)
+	if compress && isCompressionUnsupported(err) {
+		c.disableFeature(FeatureCompression)
+		err = call(false)
+	}
+	c.noteUnimplemented(method, err)
+	return err
+}
+
+// versionStreamClientInterceptor 携带本节点的协议版本，对端支持时压缩流中的消息
+func (c *Client) versionStreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, versionMetadataKey, strconv.Itoa(ProtocolVersion))
+	if c.opts.compressor != "" && c.Supports(FeatureCompression) {
+		opts = append(opts[:len(opts):len(opts)], grpc.UseCompressor(c.opts.compressor))
+	}
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		c.noteUnimplemented(method, err)
+		return nil, err
+	}
+	return &versionClientStream{ClientStream: stream, client: c, method: method}, nil
+}
+
+// versionClientStream 流式 RPC 的状态在首次接收时才返回，在接收时识别对端不支持的方法并获取响应头
+type versionClientStream struct {
+	grpc.ClientStream
+	client *Client
+	method string
+	once   sync.Once
+}
+
+func (s *versionClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	s.once.Do(func() {
+		if header, herr := s.ClientStream.Header(); herr == nil {
+			s.client.learnProtocol(header)
+		}
+	})
+	if err != nil && err != io.EOF {
+		s.client.noteUnimplemented(s.method, err)
+	}
+	return err
+}
+
+// mgetEach 对端不支持批量请求时逐个获取，获取失败的 key 不出现在结果中
+func (c *Client) mgetEach(ctx context.Context, group string, keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		value, err := c.Get(ctx, group, key)
+		if err != nil {
+			if ctx.Err() != nil {
+				return values, ctx.Err()
+			}
+			continue
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// msetEach 对端不支持批量请求时逐个设置，返回各 key 错误的汇总
+func (c *Client) msetEach(ctx context.Context, group string, entries map[string][]byte) error {
+	errs := make(map[string]string)
+	for key, value := range entries {
+		if err := c.Set(ctx, group, key, value); err != nil {
+			errs[key] = err.Error()
+		}
+	}
+	return batchErrors(&pb.BatchResponse{Errors: errs})
+}
+
+// mdeleteEach 对端不支持批量请求时逐个删除，返回各 key 错误的汇总
+func (c *Client) mdeleteEach(ctx context.Context, group string, keys []string) error {
+	errs := make(map[string]string)
+	for _, key := range keys {
+		if _, err := c.Delete(ctx, group, key); err != nil {
+			errs[key] = err.Error()
+		}
+	}
+	return batchErrors(&pb.BatchResponse{Errors: errs})
+}
+
+// transferEach 对端不支持 Transfer 时逐个 Set，保留原有的过期时间，已过期的缓存项被跳过
+func (c *Client) transferEach(ctx context.Context, group string, entries <-chan TransferEntry) (TransferResult, error) {
+	var result TransferResult
+	// 标记为节点间同步，对端不会再次转发
+	ctx = context.WithValue(ctx, "from_peer", true)
+	for entry := range entries {
+		if !entry.ExpireAt.IsZero() && !entry.ExpireAt.After(time.Now()) {
+			continue
+		}
+		result.Sent++
+		if err := c.Set(withWriteExpire(ctx, entry.ExpireAt), group, entry.Key, entry.Value); err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			result.Failed++
+			continue
+		}
+		result.Stored++
+	}
+	return result, nil
+}
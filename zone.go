@@ -0,0 +1,89 @@
+package mycache
+
+import (
+	"github.com/linhx1999/MyCache-Go/consistenthash"
+)
+
+// ZonePolicy 多机房部署时选择副本的策略
+type ZonePolicy struct {
+	// PreferLocalReads key 的副本中有与本节点同机房的节点时，从该节点读取而不是跨机房访问 owner
+	// 需要组启用多副本（WithReplicationFactor 或 WithConsistency），副本不可达时回退到 owner 和其他副本
+	PreferLocalReads bool
+	// SpreadReplicas 选择副本时优先选择不同机房的节点，使写入分散到各机房，单个机房故障时仍有副本可用
+	// 集群中所有节点需使用相同的设置，否则对副本位置的计算结果不一致
+	SpreadReplicas bool
+}
+
+// LocalityPicker 是 PeerPicker 的可选扩展，能从 key 的副本中选出与本节点同机房的节点
+type LocalityPicker interface {
+	// PickNearest 返回 key 的前 n 个副本中与本节点同机房的远程节点，没有时 ok 为 false
+	PickNearest(key string, n int) (peer Peer, ok bool)
+}
+
+// WithLocalZone 设置本节点所在的机房（可用区）和副本选择策略
+// 其他节点的机房来自 etcd 中的注册信息（见 registry.WithZone），或通过 WithPeerZones 指定
+func WithLocalZone(zone string, policy ZonePolicy) PickerOption {
+	return func(p *ClientPicker) {
+		p.zone = zone
+		p.zonePolicy = policy
+		if policy.SpreadReplicas {
+			p.ringOpts = append(p.ringOpts, consistenthash.WithZoneAware())
+		}
+	}
+}
+
+// WithPeerZones 指定节点地址所在的机房，用于静态节点、DNS 等注册信息中没有机房的服务发现方式
+func WithPeerZones(zones map[string]string) PickerOption {
+	return func(p *ClientPicker) {
+		p.peerZones = zones
+	}
+}
+
+// applyZones 将本节点和 WithPeerZones 指定的机房设置到哈希环上
+func (p *ClientPicker) applyZones() {
+	for addr, zone := range p.peerZones {
+		p.consHash.SetZone(addr, zone)
+	}
+	if p.zone != "" && p.selfAddr != "" {
+		p.consHash.SetZone(p.selfAddr, p.zone)
+	}
+}
+
+// PickNearest 实现 LocalityPicker，未设置本节点机房或未启用 PreferLocalReads 时总是返回 false
+// 本节点也是同机房的副本时同样返回 false，由 owner 负责回源，避免绕过 owner 加载；
+// 同机房的副本发布的负载显示过载时也返回 false，改为读取 owner
+func (p *ClientPicker) PickNearest(key string, n int) (Peer, bool) {
+	if p.zone == "" || !p.zonePolicy.PreferLocalReads || n < 2 {
+		return nil, false
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	addr := p.consHash.GetInZone(key, p.zone, n)
+	if addr == "" || addr == p.selfAddr || p.consHash.Zone(addr) != p.zone || p.overloaded(addr) {
+		return nil, false
+	}
+	client, ok := p.clients[addr]
+	if !ok {
+		return nil, false
+	}
+	return client, true
+}
+
+// pickReadPeer 返回读取 key 时访问的远程节点，默认为 owner，启用就近读取时优先同机房的副本
+func (g *Group) pickReadPeer(key string, owner Peer) Peer {
+	if g.replicas < 2 || g.ownerOnlyLoad {
+		// 同机房的副本未命中时会自行回源，与 owner 独占回源冲突
+		return owner
+	}
+	picker, ok := g.peers.(LocalityPicker)
+	if !ok {
+		return owner
+	}
+	if peer, ok := picker.PickNearest(key, g.replicas); ok {
+		g.stats.zoneReads.Add(1)
+		return peer
+	}
+	return owner
+}